@@ -3,8 +3,11 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 )
@@ -14,20 +17,82 @@ var Version = "dev"
 
 // Options represents parsed command-line options.
 type Options struct {
-	Task        string
-	ShowHelp    bool
-	ShowVersion bool
-	RemoteURL   string // URL for "ttt remote <url>" command
-	Sync        bool   // true when "ttt sync" command is used
+	Task         string
+	UnderLine    *int // parent task line from "ttt -t <task> --under N" (nil = top level)
+	ShowHelp     bool
+	ShowVersion  bool
+	RemoteURL    string // URL for "ttt remote <url>" command
+	Sync         bool   // true when "ttt sync" command is used
+	Workspace    string // workspace name from --workspace (empty = default)
+	ListWS       bool   // true when "ttt workspace list" command is used
+	Doctor       bool   // true when "ttt doctor" command is used
+	Fix          bool   // true when "ttt doctor --fix" is used
+	Force        bool   // true when --force skips the tasks.md guard
+	Report       bool   // true when "ttt report" command is used
+	ReportWeek   bool   // true when "ttt report --week" is used (default: today)
+	ReportJSON   bool   // true when "ttt report --json" is used (default: Markdown)
+	ReportPipe   string // command from "ttt report --pipe <command>"
+	ReportOut    string // file from "ttt report --out <file>"
+	ReportMonth  string // month from "ttt report --month YYYY-MM" (archive summary)
+	Archive      bool   // true when "ttt archive" command is used
+	ArchiveTo    string // file from "ttt archive --to <path>" (empty = archive.md and routes)
+	Consolidate  bool   // true when "ttt archive --consolidate" cleans up archive.md
+	DryRun       bool   // true when "ttt archive --consolidate --dry-run" only prints the summary
+	DebugTiming  bool   // true when --debug-timing prints startup timings to stderr
+	Stats        bool   // true when "ttt stats" command is used
+	Weekdays     bool   // true when "ttt stats --weekdays" skips weekends in the streak
+	Estimates    bool   // true when "ttt stats --estimates" prints remaining @est totals
+	Search       string // text from "ttt search <text>"
+	InArchive    bool   // true when "ttt search --archive" searches the archive files instead
+	ListByDue    bool   // true when "ttt list --by-due" is used
+	Move         bool   // true when "ttt move" command is used
+	MoveTo       string // target workspace from "ttt move --to <name>"
+	MovePattern  string // text of the task to move
+	Done         bool   // true when "ttt done" command is used
+	DoneMatch    string // text from "ttt done --match <text>"
+	DoneAll      bool   // true when "ttt done --all" completes every matching task
+	Reset        bool   // true when "ttt reset" command is used
+	ResetHeading string // heading from "ttt reset --heading <heading>"
+	Check        bool   // true when "ttt check" command is used
+	Migrate      bool   // true when "ttt migrate" command is used
+	MigrateFrom  string // format from "ttt migrate --from <format>", e.g. "ephe"
+	MigrateFile  string // file to convert with "ttt migrate"
+	MigrateOut   string // file from "ttt migrate --out <file>" (empty = tasks.md)
+	StrictConfig bool   // true when --strict-config makes unknown config keys an error
+	SafeMode     bool   // true when --safe-mode ignores config.toml and uses the defaults
+	Verbose      bool   // true when --verbose (-V) logs debug events
+}
+
+// LaunchesTUI reports whether opts start the TUI rather than run a command.
+func (o *Options) LaunchesTUI() bool {
+	return !o.ShowHelp && !o.ShowVersion && !o.ListWS && o.RemoteURL == "" && !o.Sync &&
+		!o.Doctor && !o.Report && !o.Archive && !o.Stats && o.Search == "" &&
+		!o.ListByDue && !o.Move && !o.Done && !o.Reset && !o.Migrate && !o.Check && o.Task == ""
 }
 
 // Parse parses command-line arguments and returns Options.
 func Parse(args []string) (*Options, error) {
 	opts := &Options{}
 
+	// --workspace applies to every command, so it is extracted before subcommand detection
+	workspace, args, err := extractWorkspace(args)
+	if err != nil {
+		return nil, err
+	}
+	opts.Workspace = workspace
+	opts.StrictConfig, args = extractFlag(args, "--strict-config")
+	opts.SafeMode, args = extractFlag(args, "--safe-mode")
+	opts.Verbose, args = extractFlag(args, "--verbose", "-V")
+
 	// Check for subcommands first (before flag parsing)
 	if len(args) > 0 {
 		switch args[0] {
+		case "workspace":
+			if len(args) < 2 || args[1] != "list" {
+				return nil, fmt.Errorf("unknown workspace command. Usage: ttt workspace list")
+			}
+			opts.ListWS = true
+			return opts, nil
 		case "remote":
 			if len(args) < 2 {
 				return nil, fmt.Errorf("missing URL for 'remote' command. Usage: ttt remote <url>")
@@ -37,6 +102,81 @@ func Parse(args []string) (*Options, error) {
 		case "sync":
 			opts.Sync = true
 			return opts, nil
+		case "doctor":
+			opts.Doctor = true
+			for _, arg := range args[1:] {
+				if arg != "--fix" {
+					return nil, fmt.Errorf("unknown option %q for 'doctor'. Usage: ttt doctor [--fix]", arg)
+				}
+				opts.Fix = true
+			}
+			return opts, nil
+		case "check":
+			if len(args) > 1 {
+				return nil, fmt.Errorf("unexpected argument %q for 'check'. Usage: ttt check", args[1])
+			}
+			opts.Check = true
+			return opts, nil
+		case "report":
+			if err := parseReport(opts, args[1:]); err != nil {
+				return nil, err
+			}
+			return opts, nil
+		case "archive":
+			if err := parseArchive(opts, args[1:]); err != nil {
+				return nil, err
+			}
+			return opts, nil
+		case "search":
+			words := args[1:]
+			if len(words) > 0 && words[0] == "--archive" {
+				opts.InArchive = true
+				words = words[1:]
+			}
+			if len(words) == 0 {
+				return nil, fmt.Errorf("missing text for 'search' command. Usage: ttt search [--archive] <text>")
+			}
+			opts.Search = strings.Join(words, " ")
+			return opts, nil
+		case "list":
+			if len(args) != 2 || args[1] != "--by-due" {
+				return nil, fmt.Errorf("unknown list command. Usage: ttt list --by-due")
+			}
+			opts.ListByDue = true
+			return opts, nil
+		case "done":
+			if err := parseDone(opts, args[1:]); err != nil {
+				return nil, err
+			}
+			return opts, nil
+		case "reset":
+			if err := parseReset(opts, args[1:]); err != nil {
+				return nil, err
+			}
+			return opts, nil
+		case "migrate":
+			if err := parseMigrate(opts, args[1:]); err != nil {
+				return nil, err
+			}
+			return opts, nil
+		case "move":
+			if err := parseMove(opts, args[1:]); err != nil {
+				return nil, err
+			}
+			return opts, nil
+		case "stats":
+			opts.Stats = true
+			for _, arg := range args[1:] {
+				switch arg {
+				case "--weekdays":
+					opts.Weekdays = true
+				case "--estimates":
+					opts.Estimates = true
+				default:
+					return nil, fmt.Errorf("unknown option %q for 'stats'. Usage: ttt stats [--weekdays] [--estimates]", arg)
+				}
+			}
+			return opts, nil
 		}
 	}
 
@@ -44,6 +184,9 @@ func Parse(args []string) (*Options, error) {
 	fs.StringVarP(&opts.Task, "task", "t", "", "Add a task (TUI is not launched)")
 	fs.BoolVarP(&opts.ShowHelp, "help", "h", false, "Show help message")
 	fs.BoolVarP(&opts.ShowVersion, "version", "v", false, "Show version")
+	fs.BoolVar(&opts.Force, "force", false, "Write even if tasks.md does not look like a task list")
+	fs.BoolVar(&opts.DebugTiming, "debug-timing", false, "Print startup timings to stderr")
+	under := fs.Int("under", 0, "Add the task as a subtask of the task on this line")
 
 	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, Usage())
@@ -62,9 +205,249 @@ func Parse(args []string) (*Options, error) {
 		}
 	}
 
+	if fs.Changed("under") {
+		if !fs.Changed("task") {
+			return nil, fmt.Errorf("--under requires -t. Usage: ttt -t <task> --under <line>")
+		}
+		if *under < 1 {
+			return nil, fmt.Errorf("invalid line %d for '--under': must be 1 or more", *under)
+		}
+		opts.UnderLine = under
+	}
+
 	return opts, nil
 }
 
+// parseReport parses the options of "ttt report".
+func parseReport(opts *Options, args []string) error {
+	opts.Report = true
+
+	fs := pflag.NewFlagSet("report", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.ReportWeek, "week", false, "Report this week instead of today")
+	fs.BoolVar(&opts.ReportJSON, "json", false, "Output JSON instead of Markdown")
+	fs.StringVar(&opts.ReportPipe, "pipe", "", "Pipe the report to a command's stdin")
+	fs.StringVar(&opts.ReportOut, "out", "", "Write the report to a file")
+	fs.StringVar(&opts.ReportMonth, "month", "", "Summarize a month of the archive (YYYY-MM)")
+
+	const usage = "Usage: ttt report [--week | --month YYYY-MM] [--json] [--pipe <command> | --out <file>]"
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v. %s", err, usage)
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q for 'report'. %s", fs.Arg(0), usage)
+	}
+	if opts.ReportPipe != "" && opts.ReportOut != "" {
+		return fmt.Errorf("--pipe and --out cannot be used together")
+	}
+	if fs.Changed("month") {
+		if _, err := time.Parse("2006-01", opts.ReportMonth); err != nil {
+			return fmt.Errorf("invalid --month %q: use YYYY-MM, e.g. 2026-01", opts.ReportMonth)
+		}
+		if opts.ReportWeek || opts.ReportJSON {
+			return fmt.Errorf("--month cannot be used with --week or --json")
+		}
+	}
+	return nil
+}
+
+// parseArchive parses the options of "ttt archive".
+func parseArchive(opts *Options, args []string) error {
+	opts.Archive = true
+	const usage = "Usage: ttt archive [--to <path> | --consolidate [--dry-run]]"
+
+	fs := pflag.NewFlagSet("archive", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.ArchiveTo, "to", "", "Archive into this file instead of archive.md")
+	fs.BoolVar(&opts.Consolidate, "consolidate", false, "Merge duplicate sections of archive.md")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Print the consolidation summary without writing")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v. %s", err, usage)
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q for 'archive'. %s", fs.Arg(0), usage)
+	}
+	if fs.Changed("to") && opts.ArchiveTo == "" {
+		return fmt.Errorf("missing path for '--to'. %s", usage)
+	}
+	if opts.Consolidate && fs.Changed("to") {
+		return fmt.Errorf("--to and --consolidate cannot be used together")
+	}
+	if opts.DryRun && !opts.Consolidate {
+		return fmt.Errorf("--dry-run requires --consolidate. %s", usage)
+	}
+	return nil
+}
+
+// parseDone parses the options of "ttt done". Words after --match <text> are part of
+// the text, as with "ttt -t".
+func parseDone(opts *Options, args []string) error {
+	opts.Done = true
+	const usage = "Usage: ttt done --match <text> [--all]"
+
+	fs := pflag.NewFlagSet("done", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.DoneMatch, "match", "", "Complete the open task containing this text")
+	fs.BoolVar(&opts.DoneAll, "all", false, "Complete every matching task")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v. %s", err, usage)
+	}
+	if !fs.Changed("match") {
+		return fmt.Errorf("missing '--match' for 'done' command. %s", usage)
+	}
+	opts.DoneMatch = strings.TrimSpace(strings.Join(append([]string{opts.DoneMatch}, fs.Args()...), " "))
+	if opts.DoneMatch == "" {
+		return fmt.Errorf("missing text for '--match'. %s", usage)
+	}
+	return nil
+}
+
+// parseReset parses the options of "ttt reset". Words after --heading <heading> are
+// part of the heading, as with "ttt done".
+func parseReset(opts *Options, args []string) error {
+	opts.Reset = true
+	const usage = "Usage: ttt reset --heading <heading>"
+
+	fs := pflag.NewFlagSet("reset", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.ResetHeading, "heading", "", "Uncheck every task of this ## section")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v. %s", err, usage)
+	}
+	if !fs.Changed("heading") {
+		return fmt.Errorf("missing '--heading' for 'reset' command. %s", usage)
+	}
+	opts.ResetHeading = strings.TrimSpace(strings.Join(append([]string{opts.ResetHeading}, fs.Args()...), " "))
+	if opts.ResetHeading == "" {
+		return fmt.Errorf("missing text for '--heading'. %s", usage)
+	}
+	return nil
+}
+
+// parseMove parses the options of "ttt move". --from selects the source workspace
+// like --workspace does, so giving both with different names is an error.
+func parseMove(opts *Options, args []string) error {
+	opts.Move = true
+	const usage = "Usage: ttt move [--from <workspace>] --to <workspace> <text>"
+
+	fs := pflag.NewFlagSet("move", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	from := fs.String("from", "", "Workspace to move the task from (default: the active one)")
+	fs.StringVar(&opts.MoveTo, "to", "", "Workspace to move the task to")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v. %s", err, usage)
+	}
+	if opts.MoveTo == "" {
+		return fmt.Errorf("missing workspace for '--to'. %s", usage)
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("missing text for 'move' command. %s", usage)
+	}
+	opts.MovePattern = strings.Join(fs.Args(), " ")
+
+	if *from != "" {
+		if opts.Workspace != "" && opts.Workspace != *from {
+			return fmt.Errorf("--from %q and --workspace %q cannot both be used", *from, opts.Workspace)
+		}
+		opts.Workspace = *from
+	}
+	return nil
+}
+
+// parseMigrate parses the options of "ttt migrate". ephe is the only format so far.
+func parseMigrate(opts *Options, args []string) error {
+	opts.Migrate = true
+	const usage = "Usage: ttt migrate --from ephe [--out <file>] <file>"
+
+	fs := pflag.NewFlagSet("migrate", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.MigrateFrom, "from", "", "Format of the file to convert")
+	fs.StringVar(&opts.MigrateOut, "out", "", "Write to a new file instead of tasks.md")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v. %s", err, usage)
+	}
+	switch {
+	case !fs.Changed("from"):
+		return fmt.Errorf("missing '--from' for 'migrate' command. %s", usage)
+	case opts.MigrateFrom != "ephe":
+		return fmt.Errorf("unknown format %q for '--from': use \"ephe\"", opts.MigrateFrom)
+	case fs.Changed("out") && opts.MigrateOut == "":
+		return fmt.Errorf("missing file for '--out'. %s", usage)
+	case fs.NArg() == 0:
+		return fmt.Errorf("missing file for 'migrate' command. %s", usage)
+	case fs.NArg() > 1:
+		return fmt.Errorf("unexpected argument %q for 'migrate'. %s", fs.Arg(1), usage)
+	}
+	opts.MigrateFile = fs.Arg(0)
+	return nil
+}
+
+// extractWorkspace removes "--workspace <name>", "--workspace=<name>", or "-w <name>"
+// from args and returns the name with the remaining arguments. Only the options before
+// the command are scanned, so "-w" in task text ("ttt -t use -w flag") stays text.
+func extractWorkspace(args []string) (string, []string, error) {
+	var name string
+	rest := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--" || !isGlobalFlag(arg):
+			rest = append(rest, args[i:]...)
+			return name, rest, nil
+		case arg == "--workspace" || arg == "-w":
+			if i+1 >= len(args) || args[i+1] == "" {
+				return "", nil, fmt.Errorf("missing name for '%s'. Usage: ttt --workspace <name>", arg)
+			}
+			name = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--workspace="):
+			name = strings.TrimPrefix(arg, "--workspace=")
+			if name == "" {
+				return "", nil, fmt.Errorf("missing name for '--workspace'. Usage: ttt --workspace <name>")
+			}
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return name, rest, nil
+}
+
+// globalFlags are the flags valid with any command besides --workspace, extracted by
+// Parse with extractFlag.
+var globalFlags = []string{"--strict-config", "--safe-mode", "--verbose", "-V"}
+
+// isGlobalFlag reports whether arg is one of the options that may come before the
+// command: --workspace in any form, or one of globalFlags.
+func isGlobalFlag(arg string) bool {
+	return arg == "--workspace" || arg == "-w" || strings.HasPrefix(arg, "--workspace=") ||
+		slices.Contains(globalFlags, arg)
+}
+
+// extractFlag removes a flag valid with any command, such as "--strict-config", from
+// args and reports whether it was given under one of names. Scanning stops at "--".
+func extractFlag(args []string, names ...string) (bool, []string) {
+	found := false
+	rest := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return found, append(rest, args[i:]...)
+		}
+		if slices.Contains(names, arg) {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return found, rest
+}
+
 // Usage returns the help text.
 func Usage() string {
 	return `ttt - Tiny Task Tool
@@ -75,22 +458,76 @@ Usage:
   ttt --task "<task>"     Add a task with quotes
   ttt remote <url>        Set remote repository URL
   ttt sync                Sync with remote (pull, commit, push)
+  ttt workspace list      List configured workspaces
+  ttt doctor [--fix]      Check task files for problems (and repair them)
+  ttt check               Print the open task count (exit 3 above tasks.open_limit)
+  ttt report [options]    Print a summary of completed and open tasks
+  ttt archive [--to <path>]  Archive old completed tasks
+  ttt stats [--weekdays]  Show the current completion streak
+  ttt stats --estimates   Show remaining @est estimates per heading
+  ttt search <text>       Print the lines of tasks.md containing text
+  ttt search --archive <text>  Print matching lines of the archive files
+  ttt list --by-due       List open tasks by @due date
+  ttt move --to <ws> <text>  Move a task to another workspace
+  ttt done --match <text>  Complete the open task containing text
+  ttt reset --heading <heading>  Uncheck every task of a section
+  ttt migrate --from ephe <file>  Convert an ephe file into tasks.md
 
 Options:
-  -t, --task <text>   Add a task to the task file
-  -h, --help          Show this help message
-  -v, --version       Show version
+  -t, --task <text>        Add a task to the task file
+      --under <line>       With -t, add the task as a subtask of the task on this line
+  -w, --workspace <name>   Use the named workspace from config
+      --force              Write even if tasks.md does not look like a task list
+      --debug-timing       Print startup timings to stderr
+      --strict-config      Fail on unknown keys in config.toml (any command)
+      --safe-mode          Ignore config.toml and use the defaults (any command)
+  -V, --verbose            Log debug events to stderr (the TUI logs to a file)
+  -h, --help               Show this help message
+  -v, --version            Show version
 
 Commands:
   remote <url>        Set or update the remote repository (origin)
   sync                Sync with remote: pull -> commit -> push
+  workspace list      List workspaces (* marks the active one)
+  doctor [--fix]      Check archive headers and malformed tasks; --fix repairs
+  report              Summarize tasks done today (--week: since Monday)
+                      --month YYYY-MM    Summarize the month's archived tasks by
+                                         day, project, priority, and #tag
+                      --json             Output JSON instead of Markdown
+                      --pipe <command>   Send the report to a command's stdin
+                      --out <file>       Write the report to a file
+  archive             Archive completed tasks (routed by heading per [archive] routes)
+                      --to <path>        Archive everything into <path> instead
+                      --consolidate      Merge duplicate date sections and tasks
+                                         in archive.md and sort it (no archiving)
+                      --dry-run          With --consolidate: only print the summary
+  search <text>       Search tasks.md (width and kana folding per [search])
+                      --archive          Search archive.md and archive-YYYY-MM.md
+  list --by-due       List open tasks by @due date, undated last, each with the
+                      days left ("(3d)") or overdue ("(overdue 2d)")
+  move <text>         Move the task containing text, with its subtasks, to the
+                      [tasks] inbox_heading section of another workspace
+                      --from <workspace> Workspace to move from (default: active)
+                      --to <workspace>   Workspace to move to (required)
+  done --match <text>  Complete the open task containing text (cascades and
+                      commits like completing it in the TUI)
+                      --all              Complete every matching task
+  reset --heading <heading>  Uncheck the completed tasks of the ## section and
+                      remove their @done tags (for reusable checklists)
+  stats               Count consecutive days with archived completions
+                      --weekdays         Skip Saturdays and Sundays
+                      --estimates        Sum the @est of open tasks per heading
+                                         instead of the streak
 
 Examples:
   ttt                                    # Launch TUI
   ttt -t buy kitchen paper and wasabi    # Add task
   ttt --task "buy kitchen paper"         # Add task with quotes
   ttt remote git@github.com:user/tasks.git  # Set remote
-  ttt sync                               # Sync with remote`
+  ttt sync                               # Sync with remote
+  ttt --workspace work -t review PR      # Add task to "work" workspace
+  ttt move --from work --to personal dentist  # Move a task between workspaces
+  ttt report --json --pipe "standup-bot --channel dev"  # Post today's report`
 }
 
 // VersionString returns the version string.
@@ -190,6 +190,575 @@ func TestParseSubcommandPriority(t *testing.T) {
 	}
 }
 
+// TestParseWorkspace verifies that --workspace / -w selects a workspace for any command
+// when given before it, and that "-w" after the command is left to the command.
+func TestParseWorkspace(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		workspace string
+		task      string
+		sync      bool
+	}{
+		{"long flag", []string{"--workspace", "work"}, "work", "", false},
+		{"equals form", []string{"--workspace=work"}, "work", "", false},
+		{"short flag", []string{"-w", "work"}, "work", "", false},
+		{"before subcommand", []string{"--workspace", "work", "sync"}, "work", "", true},
+		{"after another global flag", []string{"--verbose", "-w", "work", "sync"}, "work", "", true},
+		{"with task", []string{"-w", "work", "-t", "review", "PR"}, "work", "review PR", false},
+		{"in task text", []string{"-t", "use -w flag"}, "", "use -w flag", false},
+		{"not given", []string{"-t", "buy", "milk"}, "", "buy milk", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Parse(%v) error: %v", tt.args, err)
+			}
+			if opts.Workspace != tt.workspace {
+				t.Errorf("Workspace = %q, want %q", opts.Workspace, tt.workspace)
+			}
+			if opts.Task != tt.task {
+				t.Errorf("Task = %q, want %q", opts.Task, tt.task)
+			}
+			if opts.Sync != tt.sync {
+				t.Errorf("Sync = %v, want %v", opts.Sync, tt.sync)
+			}
+		})
+	}
+
+	// Unquoted, "-w" is an unknown option of -t rather than a workspace taking "flag"
+	if opts, err := Parse([]string{"-t", "use", "-w", "flag"}); err == nil {
+		t.Errorf("Parse([-t use -w flag]) = Workspace %q, Task %q; want an error", opts.Workspace, opts.Task)
+	}
+}
+
+// TestParseWorkspaceMissingName verifies that --workspace without a name is an error.
+func TestParseWorkspaceMissingName(t *testing.T) {
+	for _, args := range [][]string{{"--workspace"}, {"-w"}, {"--workspace="}} {
+		if _, err := Parse(args); err == nil {
+			t.Errorf("Parse(%v) should return error when workspace name is missing", args)
+		}
+	}
+}
+
+// TestParseWorkspaceList verifies that "ttt workspace list" sets ListWS,
+// and that other workspace subcommands are rejected.
+func TestParseWorkspaceList(t *testing.T) {
+	opts, err := Parse([]string{"workspace", "list"})
+	if err != nil {
+		t.Fatalf("Parse([workspace list]) error: %v", err)
+	}
+	if !opts.ListWS {
+		t.Error("Parse([workspace list]) ListWS = false, want true")
+	}
+
+	for _, args := range [][]string{{"workspace"}, {"workspace", "add"}} {
+		if _, err := Parse(args); err == nil {
+			t.Errorf("Parse(%v) should return error", args)
+		}
+	}
+}
+
+// TestParseDoctor verifies that "ttt doctor" sets Doctor, "--fix" sets Fix,
+// and unknown options are rejected.
+func TestParseDoctor(t *testing.T) {
+	opts, err := Parse([]string{"doctor"})
+	if err != nil {
+		t.Fatalf("Parse([doctor]) error: %v", err)
+	}
+	if !opts.Doctor || opts.Fix {
+		t.Errorf("Parse([doctor]) = Doctor %v, Fix %v; want true, false", opts.Doctor, opts.Fix)
+	}
+
+	opts, err = Parse([]string{"doctor", "--fix"})
+	if err != nil {
+		t.Fatalf("Parse([doctor --fix]) error: %v", err)
+	}
+	if !opts.Doctor || !opts.Fix {
+		t.Errorf("Parse([doctor --fix]) = Doctor %v, Fix %v; want true, true", opts.Doctor, opts.Fix)
+	}
+
+	if _, err := Parse([]string{"doctor", "--all"}); err == nil {
+		t.Error("Parse([doctor --all]) should return error")
+	}
+}
+
+// TestParseCheck verifies "ttt check", which takes no arguments.
+func TestParseCheck(t *testing.T) {
+	opts, err := Parse([]string{"check"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if !opts.Check || opts.LaunchesTUI() {
+		t.Errorf("Parse(check) = Check %v, LaunchesTUI %v", opts.Check, opts.LaunchesTUI())
+	}
+	if _, err := Parse([]string{"check", "--all"}); err == nil {
+		t.Error("Parse(check --all) error = nil, want error")
+	}
+}
+
+// TestParseForce verifies that --force is accepted alone and together with -t.
+func TestParseForce(t *testing.T) {
+	opts, err := Parse([]string{"--force", "-t", "buy", "milk"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if !opts.Force {
+		t.Error("Force = false, want true")
+	}
+	if opts.Task != "buy milk" {
+		t.Errorf("Task = %q, want %q", opts.Task, "buy milk")
+	}
+
+	opts, err = Parse([]string{})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if opts.Force {
+		t.Error("Force = true without --force, want false")
+	}
+}
+
+// TestParseUnder verifies that --under sets the parent line of -t and is rejected
+// without -t or with a line below 1.
+func TestParseUnder(t *testing.T) {
+	opts, err := Parse([]string{"-t", "write", "tests", "--under", "3"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if opts.Task != "write tests" || opts.UnderLine == nil || *opts.UnderLine != 3 {
+		t.Errorf("Task, UnderLine = %q, %v; want %q, 3", opts.Task, opts.UnderLine, "write tests")
+	}
+
+	opts, err = Parse([]string{"-t", "buy milk"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if opts.UnderLine != nil {
+		t.Errorf("UnderLine = %d without --under, want nil", *opts.UnderLine)
+	}
+
+	for _, args := range [][]string{
+		{"--under", "3"},
+		{"-t", "x", "--under", "0"},
+		{"-t", "x", "--under", "abc"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Errorf("Parse(%v) should return error", args)
+		}
+	}
+}
+
+// TestParseDebugTiming verifies that --debug-timing is off by default and set by the flag.
+func TestParseDebugTiming(t *testing.T) {
+	opts, err := Parse([]string{"--debug-timing"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if !opts.DebugTiming {
+		t.Error("DebugTiming = false, want true")
+	}
+
+	opts, err = Parse([]string{})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if opts.DebugTiming {
+		t.Error("DebugTiming = true without --debug-timing, want false")
+	}
+}
+
+// TestParseReport verifies "ttt report" options and that --pipe and --out are exclusive.
+func TestParseReport(t *testing.T) {
+	opts, err := Parse([]string{"report", "--week", "--json", "--pipe", "standup-bot --channel dev"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if !opts.Report || !opts.ReportWeek || !opts.ReportJSON {
+		t.Errorf("Report, ReportWeek, ReportJSON = %v, %v, %v; want all true", opts.Report, opts.ReportWeek, opts.ReportJSON)
+	}
+	if opts.ReportPipe != "standup-bot --channel dev" {
+		t.Errorf("ReportPipe = %q, want %q", opts.ReportPipe, "standup-bot --channel dev")
+	}
+
+	opts, err = Parse([]string{"report", "--out=today.md"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if opts.ReportOut != "today.md" || opts.ReportWeek || opts.ReportJSON {
+		t.Errorf("Parse([report --out=today.md]) = %+v", opts)
+	}
+
+	opts, err = Parse([]string{"report", "--month", "2026-01", "--out", "jan.md"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if opts.ReportMonth != "2026-01" || opts.ReportOut != "jan.md" {
+		t.Errorf("Parse([report --month 2026-01 --out jan.md]) = %+v", opts)
+	}
+
+	for _, args := range [][]string{
+		{"report", "--pipe", "a", "--out", "b"},
+		{"report", "--month"},
+		{"report", "--month", "2026-13"},
+		{"report", "--month", "jan"},
+		{"report", "--month", "2026-01", "--week"},
+		{"report", "--month", "2026-01", "--json"},
+		{"report", "extra"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Errorf("Parse(%v) should return error", args)
+		}
+	}
+}
+
+// TestParseArchive verifies "ttt archive" and its --to option.
+func TestParseArchive(t *testing.T) {
+	opts, err := Parse([]string{"archive"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if !opts.Archive || opts.ArchiveTo != "" {
+		t.Errorf("Archive, ArchiveTo = %v, %q; want true, empty", opts.Archive, opts.ArchiveTo)
+	}
+
+	opts, err = Parse([]string{"-w", "work", "archive", "--to", "done/project-x.md"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if !opts.Archive || opts.ArchiveTo != "done/project-x.md" || opts.Workspace != "work" {
+		t.Errorf("Parse([-w work archive --to ...]) = %+v", opts)
+	}
+
+	opts, err = Parse([]string{"archive", "--consolidate", "--dry-run"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if !opts.Archive || !opts.Consolidate || !opts.DryRun {
+		t.Errorf("Parse([archive --consolidate --dry-run]) = %+v", opts)
+	}
+
+	for _, args := range [][]string{
+		{"archive", "--to"},
+		{"archive", "--to="},
+		{"archive", "--all"},
+		{"archive", "extra"},
+		{"archive", "--dry-run"},
+		{"archive", "--consolidate", "--to", "x.md"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Errorf("Parse(%v) should return error", args)
+		}
+	}
+}
+
+// TestParseMove verifies "ttt move": --from selects the source workspace and --to is required.
+func TestParseMove(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		wantWorkspace string
+		wantTo        string
+		wantPattern   string
+		wantErr       bool
+	}{
+		{"from and to", []string{"move", "--from", "work", "--to", "personal", "dentist", "appointment"}, "work", "personal", "dentist appointment", false},
+		{"active workspace", []string{"move", "--to", "personal", "dentist"}, "", "personal", "dentist", false},
+		{"workspace flag", []string{"-w", "work", "move", "--to=personal", "dentist"}, "work", "personal", "dentist", false},
+		{"same from and workspace", []string{"-w", "work", "move", "--from", "work", "--to", "personal", "x"}, "work", "personal", "x", false},
+		{"conflicting from and workspace", []string{"-w", "side", "move", "--from", "work", "--to", "personal", "x"}, "", "", "", true},
+		{"missing to", []string{"move", "dentist"}, "", "", "", true},
+		{"missing text", []string{"move", "--to", "personal"}, "", "", "", true},
+		{"unknown option", []string{"move", "--all", "--to", "personal", "x"}, "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !opts.Move || opts.Workspace != tt.wantWorkspace || opts.MoveTo != tt.wantTo || opts.MovePattern != tt.wantPattern {
+				t.Errorf("Parse(%v) = Move %v, Workspace %q, MoveTo %q, MovePattern %q", tt.args, opts.Move, opts.Workspace, opts.MoveTo, opts.MovePattern)
+			}
+		})
+	}
+}
+
+// TestParseDone verifies "ttt done --match <text>" and its --all option.
+func TestParseDone(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantMatch string
+		wantAll   bool
+		wantErr   bool
+	}{
+		{"match", []string{"done", "--match", "牛乳"}, "牛乳", false, false},
+		{"match with spaces", []string{"done", "--match", "buy", "milk"}, "buy milk", false, false},
+		{"all", []string{"done", "--all", "--match=milk"}, "milk", true, false},
+		{"missing match", []string{"done", "milk"}, "", false, true},
+		{"empty match", []string{"done", "--match", ""}, "", false, true},
+		{"unknown option", []string{"done", "--to", "x", "--match", "milk"}, "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !opts.Done || opts.DoneMatch != tt.wantMatch || opts.DoneAll != tt.wantAll || opts.LaunchesTUI() {
+				t.Errorf("Parse(%v) = Done %v, DoneMatch %q, DoneAll %v", tt.args, opts.Done, opts.DoneMatch, opts.DoneAll)
+			}
+		})
+	}
+}
+
+// TestParseReset verifies "ttt reset --heading <heading>".
+func TestParseReset(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantHeading string
+		wantErr     bool
+	}{
+		{"heading", []string{"reset", "--heading", "Packing"}, "Packing", false},
+		{"heading with spaces", []string{"reset", "--heading", "Release", "steps"}, "Release steps", false},
+		{"workspace flag", []string{"-w", "home", "reset", "--heading=Packing"}, "Packing", false},
+		{"missing heading", []string{"reset", "Packing"}, "", true},
+		{"empty heading", []string{"reset", "--heading", ""}, "", true},
+		{"unknown option", []string{"reset", "--all", "--heading", "Packing"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !opts.Reset || opts.ResetHeading != tt.wantHeading || opts.LaunchesTUI() {
+				t.Errorf("Parse(%v) = Reset %v, ResetHeading %q", tt.args, opts.Reset, opts.ResetHeading)
+			}
+		})
+	}
+}
+
+// TestParseMigrate verifies "ttt migrate --from ephe [--out <file>] <file>".
+func TestParseMigrate(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantFile string
+		wantOut  string
+		wantErr  bool
+	}{
+		{"into tasks.md", []string{"migrate", "--from", "ephe", "todo.md"}, "todo.md", "", false},
+		{"to a new file", []string{"migrate", "--from=ephe", "--out", "new.md", "todo.md"}, "todo.md", "new.md", false},
+		{"file first", []string{"migrate", "todo.md", "--from", "ephe"}, "todo.md", "", false},
+		{"missing from", []string{"migrate", "todo.md"}, "", "", true},
+		{"unknown format", []string{"migrate", "--from", "todoist", "todo.md"}, "", "", true},
+		{"missing file", []string{"migrate", "--from", "ephe"}, "", "", true},
+		{"two files", []string{"migrate", "--from", "ephe", "a.md", "b.md"}, "", "", true},
+		{"empty out", []string{"migrate", "--from", "ephe", "--out", "", "todo.md"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !opts.Migrate || opts.MigrateFrom != "ephe" || opts.MigrateFile != tt.wantFile ||
+				opts.MigrateOut != tt.wantOut || opts.LaunchesTUI() {
+				t.Errorf("Parse(%v) = Migrate %v, MigrateFile %q, MigrateOut %q",
+					tt.args, opts.Migrate, opts.MigrateFile, opts.MigrateOut)
+			}
+		})
+	}
+}
+
+// TestParseStats verifies "ttt stats" and its --weekdays option.
+func TestParseStats(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		wantWeekdays  bool
+		wantEstimates bool
+		wantErr       bool
+	}{
+		{"plain", []string{"stats"}, false, false, false},
+		{"weekdays", []string{"stats", "--weekdays"}, true, false, false},
+		{"estimates", []string{"stats", "--estimates"}, false, true, false},
+		{"unknown option", []string{"stats", "--all"}, false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := Parse(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !opts.Stats || opts.Weekdays != tt.wantWeekdays || opts.Estimates != tt.wantEstimates {
+				t.Errorf("Stats, Weekdays, Estimates = %v, %v, %v; want true, %v, %v",
+					opts.Stats, opts.Weekdays, opts.Estimates, tt.wantWeekdays, tt.wantEstimates)
+			}
+		})
+	}
+}
+
+// TestParseSearch verifies that "ttt search" joins its words and requires text.
+func TestParseSearch(t *testing.T) {
+	opts, err := Parse([]string{"search", "review", "ＰＲ"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if opts.Search != "review ＰＲ" {
+		t.Errorf("Search = %q, want %q", opts.Search, "review ＰＲ")
+	}
+
+	if _, err := Parse([]string{"search"}); err == nil {
+		t.Error("Parse([search]) should return error")
+	}
+
+	opts, err = Parse([]string{"search", "--archive", "review"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if opts.Search != "review" || !opts.InArchive {
+		t.Errorf("Search = %q, InArchive = %v, want %q, true", opts.Search, opts.InArchive, "review")
+	}
+	if _, err := Parse([]string{"search", "--archive"}); err == nil {
+		t.Error("Parse([search --archive]) should return error")
+	}
+}
+
+// TestParseList verifies that "ttt list" requires --by-due.
+func TestParseList(t *testing.T) {
+	opts, err := Parse([]string{"list", "--by-due"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if !opts.ListByDue {
+		t.Error("ListByDue = false, want true")
+	}
+
+	for _, args := range [][]string{{"list"}, {"list", "--by-date"}, {"list", "--by-due", "extra"}} {
+		if _, err := Parse(args); err == nil {
+			t.Errorf("Parse(%v) should return error", args)
+		}
+	}
+}
+
+// TestParseStrictConfig verifies that --strict-config is accepted with any command.
+func TestParseStrictConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{"alone", []string{"--strict-config"}, true},
+		{"with subcommand", []string{"report", "--strict-config", "--week"}, true},
+		{"before subcommand", []string{"--strict-config", "sync"}, true},
+		{"with task", []string{"--strict-config", "-t", "buy", "milk"}, true},
+		{"absent", []string{"sync"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if opts.StrictConfig != tt.want {
+				t.Errorf("StrictConfig = %v, want %v", opts.StrictConfig, tt.want)
+			}
+		})
+	}
+
+	opts, _ := Parse([]string{"report", "--strict-config", "--week"})
+	if !opts.Report || !opts.ReportWeek {
+		t.Errorf("Parse() = %+v, want report --week", opts)
+	}
+}
+
+// TestParseSafeMode verifies that --safe-mode is accepted with any command.
+func TestParseSafeMode(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{"alone", []string{"--safe-mode"}, true},
+		{"with subcommand", []string{"doctor", "--safe-mode"}, true},
+		{"with strict config", []string{"--safe-mode", "--strict-config"}, true},
+		{"absent", []string{"doctor"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if opts.SafeMode != tt.want {
+				t.Errorf("SafeMode = %v, want %v", opts.SafeMode, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseVerbose verifies that --verbose and -V are accepted with any command, and
+// which options launch the TUI.
+func TestParseVerbose(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    bool
+		wantTUI bool
+	}{
+		{"alone", []string{"--verbose"}, true, true},
+		{"short", []string{"-V"}, true, true},
+		{"with subcommand", []string{"archive", "-V"}, true, false},
+		{"before subcommand", []string{"--verbose", "sync"}, true, false},
+		{"with task", []string{"-V", "-t", "buy", "milk"}, true, false},
+		{"task text after --", []string{"-t", "fix", "--", "-V"}, false, false},
+		{"absent", []string{}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if opts.Verbose != tt.want {
+				t.Errorf("Verbose = %v, want %v", opts.Verbose, tt.want)
+			}
+			if opts.LaunchesTUI() != tt.wantTUI {
+				t.Errorf("LaunchesTUI() = %v, want %v", opts.LaunchesTUI(), tt.wantTUI)
+			}
+		})
+	}
+}
+
 // helper function to check if string contains substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsHelper(s, substr))
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSplitCommand verifies shell-like splitting of command lines without invoking a shell.
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected []string
+	}{
+		{"simple", "vim {file}", []string{"vim", "{file}"}},
+		{"extra spaces", "  code   --wait  {file} ", []string{"code", "--wait", "{file}"}},
+		{"double quotes", `my-script "standup bot" --channel dev`, []string{"my-script", "standup bot", "--channel", "dev"}},
+		{"single quotes keep backslash", `echo 'a\b "c"'`, []string{"echo", `a\b "c"`}},
+		{"escaped quote in double quotes", `say "He said \"hi\""`, []string{"say", `He said "hi"`}},
+		{"backslash escapes space", `open My\ File.md`, []string{"open", "My File.md"}},
+		{"empty quoted argument", `cmd ""`, []string{"cmd", ""}},
+		{"adjacent quotes join", `cmd a"b c"'d'`, []string{"cmd", "ab cd"}},
+		{"shell syntax is literal", "cat $HOME | wc", []string{"cat", "$HOME", "|", "wc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitCommand(tt.command)
+			if err != nil {
+				t.Fatalf("SplitCommand(%q) error: %v", tt.command, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("SplitCommand(%q) = %q, want %q", tt.command, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSplitCommandErrors verifies that unterminated quotes and empty commands are rejected.
+func TestSplitCommandErrors(t *testing.T) {
+	for _, command := range []string{"", "   ", `echo "unterminated`, `echo 'unterminated`} {
+		if _, err := SplitCommand(command); err == nil {
+			t.Errorf("SplitCommand(%q) should return error", command)
+		}
+	}
+}
+
+// TestEditorArgs verifies that {file} is substituted after splitting,
+// so file paths with spaces stay one argument.
+func TestEditorArgs(t *testing.T) {
+	cfg := &Config{Editor: EditorConfig{Command: `code --wait "{file}"`}}
+	got, err := cfg.EditorArgs("/My Tasks/tasks.md")
+	if err != nil {
+		t.Fatalf("EditorArgs() error: %v", err)
+	}
+	expected := []string{"code", "--wait", "/My Tasks/tasks.md"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("EditorArgs() = %q, want %q", got, expected)
+	}
+
+	cfg.Editor.Command = ""
+	t.Setenv("EDITOR", "code --wait")
+	got, err = cfg.EditorArgs("/My Tasks/tasks.md")
+	if err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("EditorArgs() with empty command = %q, %v; want $EDITOR split", got, err)
+	}
+}
@@ -2,35 +2,132 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/pelletier/go-toml/v2"
+
+	"github.com/yostos/tiny-task-tool/internal/git"
+	"github.com/yostos/tiny-task-tool/internal/task"
 )
 
 // Config represents the application configuration.
 type Config struct {
 	File        FileConfig        `toml:"file"`
 	Archive     ArchiveConfig     `toml:"archive"`
+	Tasks       TasksConfig       `toml:"tasks"`
 	Editor      EditorConfig      `toml:"editor"`
 	Keybindings KeybindingsConfig `toml:"keybindings"`
 	Git         GitConfig         `toml:"git"`
+	Display     DisplayConfig     `toml:"display"`
+	UI          UIConfig          `toml:"ui"`
+	Search      SearchConfig      `toml:"search"`
+	Theme       ThemeConfig       `toml:"theme"`
+	Workspaces  []WorkspaceConfig `toml:"workspaces,omitempty"`
+
+	// Keys in config.toml that ttt does not know, e.g. "archive.dely_days (line 3)".
+	// Set by Load; unknown keys are ignored so a config written for a newer ttt still loads.
+	UnknownKeys []string `toml:"-"`
+
+	// Lines of config.toml that could not be read, e.g. "line 2: incomplete number", and
+	// settings that are not valid, e.g. an unknown [tasks] cascade. Set by Load, which
+	// skips them so the rest of the file still applies; those settings keep their defaults.
+	ConfigErrors []string `toml:"-"`
+
+	// SafeMode is set when config.toml was ignored (ttt --safe-mode) and the defaults are in use.
+	SafeMode bool `toml:"-"`
+
+	// Active workspace and [file] working_dir as loaded, set by UseWorkspace.
+	workspace         string
+	defaultWorkingDir string
 }
 
 // FileConfig defines file location settings.
 type FileConfig struct {
 	WorkingDir string `toml:"working_dir"`
+	// Guard against rewriting a file that is not a task list (see task.LooksLikeTaskFileWith).
+	GuardLines     int     `toml:"guard_lines"`      // 0 disables the guard
+	GuardTaskRatio float64 `toml:"guard_task_ratio"` // minimum share of task lines, e.g. 0.01
+	// Skip adding a task whose text matches an open task (see task.HasDuplicate).
+	PreventDuplicates   bool `toml:"prevent_duplicates"`
+	DuplicateIgnoreTags bool `toml:"duplicate_ignore_tags"` // compare text with tags removed
+	// Heading put at the top of tasks.md when a task is added to a file without headings ("" = off).
+	AutoTitle string `toml:"auto_title"`
+	// Refuse to load tasks.md and archive.md above this size, in megabytes (0 = no limit).
+	MaxSizeMB int `toml:"max_size_mb"`
+	// Deepest level a task may be nested at when adding subtasks, top level = 1 (0 = no limit).
+	MaxDepth int `toml:"max_depth"`
+	// Collapse blank line runs and drop emptied headings whenever ttt writes tasks.md
+	// (see task.Tidy).
+	TidyOnWrite bool `toml:"tidy_on_write"`
 }
 
 // ArchiveConfig defines archive behavior settings.
 type ArchiveConfig struct {
 	Auto      bool `toml:"auto"`
 	DelayDays int  `toml:"delay_days"`
+	// Experimental: commit tasks.md and archive.md together right after each archive
+	// (requires git.auto_commit), so the two files never diverge across commits.
+	Tombstone bool `toml:"tombstone"`
+	// Heading text to archive file, e.g. { "Project X" = "archives/project-x.md" }.
+	// Relative paths are inside the working directory (see task.PartitionArchive).
+	Routes map[string]string `toml:"routes,omitempty"`
+	// Heading text to archive delay in days, e.g. { "Errands" = 1, "Projects" = 7 }.
+	// Tasks under other headings, or under no heading, use delay_days.
+	DelayOverrides map[string]int `toml:"delay_overrides,omitempty"`
+	// Also archive an open parent once all of its subtasks are done
+	// (see task.Options.ArchiveWhenChildrenDone).
+	ArchiveWhenChildrenDone bool `toml:"archive_when_children_done"`
+	// Complete an open parent, with @done, once all of its subtasks are done
+	// (see task.Options.AutoCompleteParent).
+	AutoCompleteParent bool `toml:"auto_complete_parent"`
+	// Archive when q quits the TUI, instead of (or as well as) at startup with auto.
+	// A failed archive cancels the quit.
+	OnQuit bool `toml:"on_quit"`
+	// Add @done(YYYY-MM-DD) to completed tasks. Off, a plain [x] marks a task done
+	// (see task.Options.DoneDateOptional).
+	DoneDateRequired bool `toml:"done_date_required"`
+}
+
+// TasksConfig defines how task lines are recognized.
+type TasksConfig struct {
+	// List bullets accepted before "[ ]" / "[x]": any of "-", "*", "+".
+	// Tasks added by ttt always use "-".
+	BulletStyles []string `toml:"bullet_styles"`
+	// Also treat ordered list items with a checkbox ("1. [ ]", "2) [x]") as tasks
+	// (see task.Options.Numbered).
+	Numbered bool `toml:"numbered"`
+	// Raise open tasks whose @due date is more than this many days past to @p1 (0 = off).
+	EscalateOverdueDays int `toml:"escalate_overdue_days"`
+	// How far checking a parent completes its subtasks: task.CascadeAll,
+	// task.CascadeDirect, or task.CascadeOff.
+	Cascade string `toml:"cascade"`
+	// With cascade "all", leave open the subtasks reopened by hand below a parent
+	// completed on an earlier day (see task.Options.CascadeIgnoreManual).
+	CascadeRespectManual bool `toml:"cascade_respect_manual"`
+	// "## " heading that tasks moved in from another workspace are added under;
+	// when tasks.md has no such heading they go to the end of the file.
+	InboxHeading string `toml:"inbox_heading"`
+	// Hard-wrap the text of added tasks wider than this many columns into continuation
+	// note lines (0 = off; see task.WrapTaskLine).
+	WrapColumn int `toml:"wrap_column"`
+	// What ttt -t does with a weekday @due such as @due(fri): task.WeekdayDueKeep or
+	// task.WeekdayDueExpand.
+	WeekdayDue string `toml:"weekday_due"`
+	// Warn when tasks.md has more open tasks than this (0 = off; see task.OpenLimitMessage).
+	OpenLimit int `toml:"open_limit"`
+	// ttt doctor reports open tasks whose text is wider than this many columns, as
+	// a task to split up (0 = off; see task.LongTasks).
+	MaxTaskLength int `toml:"max_task_length"`
 }
 
 // EditorConfig defines editor settings.
 type EditorConfig struct {
+	// Launch command template with {file}; empty uses $EDITOR as set when the editor
+	// is launched (see Config.EditorCommand).
 	Command string `toml:"command"`
 }
 
@@ -44,35 +141,140 @@ type KeybindingsConfig struct {
 	HalfPageDown []string `toml:"half_page_down"`
 }
 
+// DisplayConfig defines TUI display settings.
+// These affect rendering only; the task file is never changed.
+type DisplayConfig struct {
+	RelativeDoneDate bool `toml:"relative_done_date"` // show @done(3日前) instead of @done(YYYY-MM-DD)
+	Hyperlinks       bool `toml:"hyperlinks"`         // render links as OSC 8 terminal hyperlinks
+	// Show completed tasks below the open ones (see task.PartitionByCompletion)
+	CompletedToBottom bool `toml:"completed_to_bottom"`
+	// Also move them down in tasks.md when ttt tags newly completed tasks
+	CompletedToBottomFile bool `toml:"completed_to_bottom_file"`
+	// Hide open tasks whose @start date is still ahead in the TUI (see task.FutureLines)
+	HideFuture bool `toml:"hide_future"`
+	// Show open tasks longer than [tasks] max_task_length in yellow (see task.IsLongTask)
+	WarnLongTasks bool `toml:"warn_long_tasks"`
+}
+
+// SearchConfig defines how "/" in the TUI and "ttt search" compare text (see task.FindMatches).
+type SearchConfig struct {
+	NormalizeWidth bool `toml:"normalize_width"` // ＡＢＣ１ matches ABC1, ｶﾀｶﾅ matches カタカナ
+	IgnoreKana     bool `toml:"ignore_kana"`     // ひらがな matches ヒラガナ
+}
+
+// ThemeConfig defines TUI colors.
+// Values are lipgloss colors: ANSI numbers ("1") or hex codes ("#ff0000").
+type ThemeConfig struct {
+	PriorityA string `toml:"priority_a"`
+	PriorityB string `toml:"priority_b"`
+	PriorityC string `toml:"priority_c"`
+	Done      string `toml:"done"`
+}
+
+// UIConfig defines TUI behavior settings.
+type UIConfig struct {
+	Filters []FilterConfig `toml:"filters,omitempty"`
+	// Regex to URL template, e.g. "JIRA-(\\d+)" = "https://jira.example.com/browse/JIRA-$1".
+	// Matching tokens open with "o" in select mode (see task.FindLinks).
+	LinkPatterns map[string]string `toml:"link_patterns,omitempty"`
+	// Show a one-column scrollbar on the right edge of the viewport.
+	Scrollbar bool `toml:"scrollbar"`
+	// Show today's completions and the current streak in the footer (see task.Streak).
+	ShowStreak bool `toml:"show_streak"`
+	// Show "(done/total)" after each "## " heading in the TUI (see task.Sections).
+	ShowProgress bool `toml:"show_progress"`
+	// Where @pin tasks go to the top: one of the PinnedFirst constants.
+	PinnedFirst string `toml:"pinned_first"`
+	// Show archive.md in a pane right of tasks.md; Tab switches the focus.
+	SplitView bool `toml:"split_view"`
+}
+
+// Values of ui.pinned_first (see task.PinnedFirst).
+const (
+	PinnedFirstOff  = "off"  // pinned tasks stay where they are
+	PinnedFirstView = "view" // shown at the top; tasks.md is not changed
+	PinnedFirstFile = "file" // shown at the top, and moved there in tasks.md when pinned with *
+)
+
+// FilterConfig defines a named filter applied with a number key in the TUI.
+// Query uses the task query syntax (see task.CompileQuery).
+// Key is "1"-"9"; when omitted, the first unused number is assigned in order.
+type FilterConfig struct {
+	Name  string `toml:"name"`
+	Query string `toml:"query"`
+	Key   string `toml:"key,omitempty"`
+}
+
+// WorkspaceConfig defines a named working directory selectable with --workspace.
+// Each workspace has its own tasks.md, archive.md, and git repository.
+type WorkspaceConfig struct {
+	Name       string `toml:"name"`
+	WorkingDir string `toml:"working_dir"`
+}
+
 // GitConfig defines git integration settings.
 type GitConfig struct {
-	AutoCommit bool `toml:"auto_commit"`
+	AutoCommit      bool `toml:"auto_commit"`
+	AutoSyncMinutes int  `toml:"auto_sync_minutes"` // 0 disables scheduled sync in the TUI
+	IntegrityCheck  bool `toml:"integrity_check"`   // warn on startup when tasks.md lost many lines since HEAD
+	// Ask before quitting the TUI with uncommitted or unpushed changes.
+	ConfirmQuitIfDirty bool `toml:"confirm_quit_if_dirty"`
+	// Which repository ttt uses: one of the GitMode constants. "auto" is replaced by
+	// the detected mode at startup (see main.resolveGitMode).
+	Mode string `toml:"mode"`
+	// Command run after a sync that pulled or committed changes ("" = none);
+	// see Config.PostSyncHookArgs.
+	PostSyncHook string `toml:"post_sync_hook"`
+	// Put in front of every commit message ttt generates, e.g. "📝" ("" = none).
+	CommitPrefix string `toml:"commit_prefix"`
+	// Language of generated commit messages: git.LanguageEnglish or git.LanguageJapanese.
+	CommitLanguage string `toml:"commit_language"`
+	// Fast-forward pull from origin before the editor opens (e) or a task is added
+	// (ttt -t), so the edit starts from the latest remote state.
+	PullBeforeEdit bool `toml:"pull_before_edit"`
+	// Commit identity set in ttt's own repository when it has none of its own
+	// ("" = use the global git config); see main.ensureWorkingDir.
+	UserName  string `toml:"user_name"`
+	UserEmail string `toml:"user_email"`
 }
 
+// Values of git.mode.
+const (
+	GitModeAuto       = "auto"        // own-repo, or parent-repo when working_dir is inside another repository
+	GitModeOwnRepo    = "own-repo"    // working_dir is its own repository (created with git init)
+	GitModeParentRepo = "parent-repo" // commit only working_dir, inside the enclosing repository
+	GitModeDisabled   = "disabled"    // never run git
+)
+
 // Fixed file names (not configurable).
 const (
 	TasksFileName   = "tasks.md"
 	ArchiveFileName = "archive.md"
 )
 
+// DefaultWorkspaceName is the name of the workspace defined by [file] working_dir.
+const DefaultWorkspaceName = "default"
+
 // Default returns a Config with default values.
 func Default() *Config {
-	editorCmd := os.Getenv("EDITOR")
-	if editorCmd == "" {
-		editorCmd = "vi"
-	}
-	editorCmd += " {file}"
-
 	return &Config{
 		File: FileConfig{
-			WorkingDir: "~/.ttt",
+			WorkingDir:     "~/.ttt",
+			GuardLines:     task.DefaultGuardLines,
+			GuardTaskRatio: task.DefaultGuardTaskRatio,
+			MaxSizeMB:      task.DefaultMaxFileSizeMB,
 		},
 		Archive: ArchiveConfig{
-			Auto:      false,
-			DelayDays: 2,
+			Auto:             false,
+			DelayDays:        2,
+			DoneDateRequired: true,
 		},
-		Editor: EditorConfig{
-			Command: editorCmd,
+		Tasks: TasksConfig{
+			BulletStyles:         append([]string(nil), task.DefaultBulletStyles...),
+			InboxHeading:         "Inbox",
+			Cascade:              task.CascadeAll,
+			CascadeRespectManual: true,
+			WeekdayDue:           task.WeekdayDueKeep,
 		},
 		Keybindings: KeybindingsConfig{
 			Up:           []string{"k"},
@@ -83,7 +285,29 @@ func Default() *Config {
 			HalfPageDown: []string{"ctrl+d"},
 		},
 		Git: GitConfig{
-			AutoCommit: true,
+			AutoCommit:      true,
+			AutoSyncMinutes: 0,
+			IntegrityCheck:  false,
+			Mode:            GitModeAuto,
+			CommitLanguage:  git.LanguageEnglish,
+		},
+		UI: UIConfig{
+			PinnedFirst:  PinnedFirstOff,
+			ShowProgress: true,
+		},
+		Display: DisplayConfig{
+			RelativeDoneDate: false,
+			Hyperlinks:       true,
+		},
+		Search: SearchConfig{
+			NormalizeWidth: false,
+			IgnoreKana:     false,
+		},
+		Theme: ThemeConfig{
+			PriorityA: "1", // red
+			PriorityB: "3", // yellow
+			PriorityC: "4", // blue
+			Done:      "240",
 		},
 	}
 }
@@ -108,7 +332,9 @@ func ConfigPath() (string, error) {
 }
 
 // Load reads the configuration from the config file.
-// If the file doesn't exist, it creates one with default values.
+// If the file doesn't exist, it creates one with default values. Lines that cannot be
+// read and settings that are not valid keep their defaults and are reported in
+// ConfigErrors; only a config file that cannot be read or created is an error.
 func Load() (*Config, error) {
 	cfg := Default()
 
@@ -129,11 +355,262 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	if err := toml.Unmarshal(data, cfg); err != nil {
-		return nil, err
+	cfg = decode(data)
+	cfg.resetInvalid()
+	return cfg, nil
+}
+
+// resetInvalid puts each setting of c that is not valid back to its default and
+// reports it in ConfigErrors, so one bad value does not keep the rest of config.toml
+// from applying. Settings that belong together, such as the two [file] guard values,
+// are reset together.
+func (c *Config) resetInvalid() {
+	def := Default()
+	invalid := func(err error, reset func()) {
+		c.ConfigErrors = append(c.ConfigErrors, err.Error())
+		reset()
 	}
 
-	return cfg, nil
+	if err := c.Keybindings.Normalize(); err != nil {
+		invalid(err, func() { c.Keybindings = def.Keybindings })
+	}
+
+	if c.File.GuardLines < 0 || c.File.GuardTaskRatio < 0 || c.File.GuardTaskRatio > 1 {
+		invalid(errors.New("invalid [file] guard: guard_lines must be >= 0 and guard_task_ratio between 0 and 1"), func() {
+			c.File.GuardLines, c.File.GuardTaskRatio = def.File.GuardLines, def.File.GuardTaskRatio
+		})
+	}
+
+	if c.File.MaxSizeMB < 0 {
+		invalid(errors.New("invalid [file] max_size_mb: must be >= 0"), func() { c.File.MaxSizeMB = def.File.MaxSizeMB })
+	}
+	if c.File.MaxDepth < 0 {
+		invalid(errors.New("invalid [file] max_depth: must be >= 0"), func() { c.File.MaxDepth = def.File.MaxDepth })
+	}
+
+	if err := task.ValidateBulletStyles(c.Tasks.BulletStyles); err != nil {
+		invalid(fmt.Errorf("invalid [tasks] bullet_styles: %w", err), func() { c.Tasks.BulletStyles = def.Tasks.BulletStyles })
+	}
+
+	if c.Tasks.EscalateOverdueDays < 0 {
+		invalid(errors.New("invalid [tasks] escalate_overdue_days: must be >= 0"), func() {
+			c.Tasks.EscalateOverdueDays = def.Tasks.EscalateOverdueDays
+		})
+	}
+	if c.Tasks.WrapColumn < 0 {
+		invalid(errors.New("invalid [tasks] wrap_column: must be >= 0"), func() { c.Tasks.WrapColumn = def.Tasks.WrapColumn })
+	}
+	if c.Tasks.OpenLimit < 0 {
+		invalid(errors.New("invalid [tasks] open_limit: must be >= 0"), func() { c.Tasks.OpenLimit = def.Tasks.OpenLimit })
+	}
+	if c.Tasks.MaxTaskLength < 0 {
+		invalid(errors.New("invalid [tasks] max_task_length: must be >= 0"), func() { c.Tasks.MaxTaskLength = def.Tasks.MaxTaskLength })
+	}
+
+	if _, err := c.PostSyncHookArgs(); err != nil {
+		invalid(err, func() { c.Git.PostSyncHook = def.Git.PostSyncHook })
+	}
+
+	switch c.Tasks.Cascade {
+	case task.CascadeAll, task.CascadeDirect, task.CascadeOff:
+	default:
+		invalid(fmt.Errorf("invalid [tasks] cascade %q: use \"all\", \"direct\", or \"off\"", c.Tasks.Cascade), func() {
+			c.Tasks.Cascade = def.Tasks.Cascade
+		})
+	}
+
+	switch c.Tasks.WeekdayDue {
+	case task.WeekdayDueKeep, task.WeekdayDueExpand:
+	default:
+		invalid(fmt.Errorf("invalid [tasks] weekday_due %q: use \"keep\" or \"expand\"", c.Tasks.WeekdayDue), func() {
+			c.Tasks.WeekdayDue = def.Tasks.WeekdayDue
+		})
+	}
+
+	switch c.Git.Mode {
+	case GitModeAuto, GitModeOwnRepo, GitModeParentRepo, GitModeDisabled:
+	default:
+		invalid(fmt.Errorf("invalid [git] mode %q: use \"auto\", \"own-repo\", \"parent-repo\", or \"disabled\"", c.Git.Mode), func() {
+			c.Git.Mode = def.Git.Mode
+		})
+	}
+
+	switch c.Git.CommitLanguage {
+	case git.LanguageEnglish, git.LanguageJapanese:
+	default:
+		invalid(fmt.Errorf("invalid [git] commit_language %q: use \"en\" or \"ja\"", c.Git.CommitLanguage), func() {
+			c.Git.CommitLanguage = def.Git.CommitLanguage
+		})
+	}
+	if strings.ContainsAny(c.Git.CommitPrefix, "\r\n") {
+		invalid(fmt.Errorf("invalid [git] commit_prefix %q: must be a single line", c.Git.CommitPrefix), func() {
+			c.Git.CommitPrefix = def.Git.CommitPrefix
+		})
+	}
+	if strings.ContainsAny(c.Git.UserName+c.Git.UserEmail, "\r\n") {
+		invalid(errors.New("invalid [git] user_name or user_email: must be a single line"), func() {
+			c.Git.UserName, c.Git.UserEmail = def.Git.UserName, def.Git.UserEmail
+		})
+	}
+
+	switch c.UI.PinnedFirst {
+	case PinnedFirstOff, PinnedFirstView, PinnedFirstFile:
+	default:
+		invalid(fmt.Errorf("invalid [ui] pinned_first %q: use \"off\", \"view\", or \"file\"", c.UI.PinnedFirst), func() {
+			c.UI.PinnedFirst = def.UI.PinnedFirst
+		})
+	}
+
+	if err := validateWorkspaces(c.Workspaces); err != nil {
+		invalid(err, func() { c.Workspaces = def.Workspaces })
+	}
+
+	for heading, path := range c.Archive.Routes {
+		if heading == "" || path == "" {
+			invalid(errors.New("invalid [archive] routes: heading and file must not be empty"), func() {
+				c.Archive.Routes = def.Archive.Routes
+			})
+			break
+		}
+	}
+
+	for heading, days := range c.Archive.DelayOverrides {
+		if heading == "" || days < 0 {
+			invalid(errors.New("invalid [archive] delay_overrides: heading must not be empty and days must be >= 0"), func() {
+				c.Archive.DelayOverrides = def.Archive.DelayOverrides
+			})
+			break
+		}
+	}
+
+	if err := resolveFilters(c.UI.Filters); err != nil {
+		invalid(err, func() { c.UI.Filters = def.UI.Filters })
+	}
+
+	if _, err := task.CompileLinkPatterns(c.UI.LinkPatterns); err != nil {
+		invalid(fmt.Errorf("invalid [ui.link_patterns]: %w", err), func() { c.UI.LinkPatterns = def.UI.LinkPatterns })
+	}
+}
+
+// maxConfigErrors is how many unreadable lines decode skips before it gives up on
+// config.toml and uses the defaults.
+const maxConfigErrors = 10
+
+// decode reads config.toml over the defaults. A line that cannot be read (a syntax
+// error, or a value of the wrong type) is reported in ConfigErrors and skipped, and
+// decoding starts over without it, so the valid keys still apply. The file itself is
+// left untouched. When too many lines fail, the defaults are used as they are.
+func decode(data []byte) *Config {
+	lines := strings.Split(string(data), "\n")
+	var configErrors []string
+	for {
+		cfg := Default()
+		decoder := toml.NewDecoder(strings.NewReader(strings.Join(lines, "\n")))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(cfg)
+		// Strict mode still decodes every known key; only report the rest
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) {
+			cfg.UnknownKeys = unknownKeys(strict)
+			err = nil
+		}
+		var decodeErr *toml.DecodeError
+		if err == nil || !errors.As(err, &decodeErr) || len(configErrors) == maxConfigErrors {
+			if err != nil {
+				cfg = Default()
+				configErrors = append(configErrors, err.Error())
+			}
+			cfg.ConfigErrors = configErrors
+			return cfg
+		}
+		row, _ := decodeErr.Position()
+		configErrors = append(configErrors, fmt.Sprintf("line %d: %s", row, strings.TrimPrefix(decodeErr.Error(), "toml: ")))
+		if row < 1 || row > len(lines) || lines[row-1] == "" {
+			cfg = Default()
+			cfg.ConfigErrors = configErrors
+			return cfg
+		}
+		lines[row-1] = ""
+	}
+}
+
+// LoadError returns the lines of config.toml that could not be read and the settings
+// that are not valid (see ConfigErrors) as one error, or nil.
+func (c *Config) LoadError() error {
+	if len(c.ConfigErrors) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(c.ConfigErrors, "; "))
+}
+
+// unknownKeys lists the keys of a strict decoding error as "section.key (line N)".
+func unknownKeys(strict *toml.StrictMissingError) []string {
+	keys := make([]string, 0, len(strict.Errors))
+	for _, e := range strict.Errors {
+		line, _ := e.Position()
+		keys = append(keys, fmt.Sprintf("%s (line %d)", strings.Join(e.Key(), "."), line))
+	}
+	return keys
+}
+
+// validateWorkspaces checks that every workspace has a unique name and a working_dir.
+func validateWorkspaces(workspaces []WorkspaceConfig) error {
+	seen := make(map[string]bool)
+	for i, ws := range workspaces {
+		if ws.Name == "" {
+			return fmt.Errorf("invalid [[workspaces]] entry %d: name is required", i+1)
+		}
+		if ws.WorkingDir == "" {
+			return fmt.Errorf("invalid workspace %q: working_dir is required", ws.Name)
+		}
+		if seen[ws.Name] {
+			return fmt.Errorf("duplicate workspace name %q", ws.Name)
+		}
+		seen[ws.Name] = true
+	}
+	return nil
+}
+
+// resolveFilters validates saved filters and assigns number keys to filters without one.
+// Queries are compiled here so that a bad query fails at startup, not on key press.
+// Key "0" is reserved for clearing the filter.
+func resolveFilters(filters []FilterConfig) error {
+	used := make(map[string]bool)
+	for i, f := range filters {
+		if f.Name == "" {
+			return fmt.Errorf("invalid [[ui.filters]] entry %d: name is required", i+1)
+		}
+		if _, err := task.CompileQuery(f.Query, task.DefaultOptions()); err != nil {
+			return fmt.Errorf("invalid filter %q: %w", f.Name, err)
+		}
+		if f.Key == "" {
+			continue
+		}
+		if len(f.Key) != 1 || f.Key[0] < '1' || f.Key[0] > '9' {
+			return fmt.Errorf("invalid filter %q: key must be 1-9, got %q", f.Name, f.Key)
+		}
+		if used[f.Key] {
+			return fmt.Errorf("invalid filter %q: key %s is already used", f.Name, f.Key)
+		}
+		used[f.Key] = true
+	}
+
+	next := '1'
+	for i := range filters {
+		if filters[i].Key != "" {
+			continue
+		}
+		for next <= '9' && used[string(next)] {
+			next++
+		}
+		if next > '9' {
+			return fmt.Errorf("invalid filter %q: no free number key left", filters[i].Name)
+		}
+		filters[i].Key = string(next)
+		used[string(next)] = true
+	}
+
+	return nil
 }
 
 // ExpandPath expands ~ to the user's home directory.
@@ -148,11 +625,147 @@ func ExpandPath(path string) (string, error) {
 	return path, nil
 }
 
+// LooksLikeTaskFile reports whether content passes the configured tasks.md guard.
+func (c *Config) LooksLikeTaskFile(content string) bool {
+	return task.LooksLikeTaskFileWith(content, c.File.GuardLines, c.File.GuardTaskRatio, c.TaskOptions())
+}
+
+// IsDuplicateTask reports whether adding text would duplicate an open task in content.
+// Always false unless file.prevent_duplicates is enabled.
+func (c *Config) IsDuplicateTask(content, text string) bool {
+	if !c.File.PreventDuplicates {
+		return false
+	}
+	if c.File.DuplicateIgnoreTags {
+		return task.HasDuplicateIgnoringTags(content, text, c.TaskOptions())
+	}
+	return task.HasDuplicateWith(content, text, c.TaskOptions())
+}
+
+// WithTitle returns content with file.auto_title prepended when content has no heading yet.
+// Used by every code path that adds a task so they all title the file the same way.
+func (c *Config) WithTitle(content string) string {
+	return task.EnsureTitle(content, c.File.AutoTitle)
+}
+
+// LinkPatterns returns the compiled [ui.link_patterns].
+// Load has already validated them; patterns set after loading that fail to compile yield none.
+func (c *Config) LinkPatterns() []task.LinkPattern {
+	patterns, err := task.CompileLinkPatterns(c.UI.LinkPatterns)
+	if err != nil {
+		return nil
+	}
+	return patterns
+}
+
+// TaskOptions returns the settings that govern parsing, completing, archiving,
+// and writing task files as task.Options. Logger is left nil.
+func (c *Config) TaskOptions() task.Options {
+	return task.Options{
+		BulletStyles:            c.Tasks.BulletStyles,
+		Numbered:                c.Tasks.Numbered,
+		Cascade:                 c.Tasks.Cascade,
+		CascadeIgnoreManual:     !c.Tasks.CascadeRespectManual,
+		AutoCompleteParent:      c.Archive.AutoCompleteParent,
+		EscalateOverdueDays:     c.Tasks.EscalateOverdueDays,
+		CompletedToBottom:       c.Display.CompletedToBottomFile,
+		DoneDateOptional:        !c.Archive.DoneDateRequired,
+		ArchiveWhenChildrenDone: c.Archive.ArchiveWhenChildrenDone,
+		MaxFileSizeMB:           maxFileSizeMB(c.File.MaxSizeMB),
+		MaxDepth:                c.File.MaxDepth,
+		TidyOnWrite:             c.File.TidyOnWrite,
+		WrapColumn:              c.Tasks.WrapColumn,
+	}
+}
+
+// maxFileSizeMB converts [file] max_size_mb, where 0 is no limit, to
+// task.Options.MaxFileSizeMB, where 0 is the default limit.
+func maxFileSizeMB(mb int) int {
+	if mb == 0 {
+		return -1
+	}
+	return mb
+}
+
+// SearchOptions returns the [search] settings as task.SearchOptions.
+func (c *Config) SearchOptions() task.SearchOptions {
+	return task.SearchOptions{
+		NormalizeWidth: c.Search.NormalizeWidth,
+		IgnoreKana:     c.Search.IgnoreKana,
+	}
+}
+
+// GitPaths returns the paths, relative to the working directory, that git operations
+// are limited to: "." (the working directory only) in parent-repo mode, none (the
+// whole repository) otherwise.
+func (c *Config) GitPaths() []string {
+	if c.Git.Mode == GitModeParentRepo {
+		return []string{"."}
+	}
+	return nil
+}
+
 // WorkingDir returns the expanded working directory path.
 func (c *Config) WorkingDir() (string, error) {
 	return ExpandPath(c.File.WorkingDir)
 }
 
+// UseWorkspace switches the working directory to the named workspace.
+// "default" selects [file] working_dir unless a workspace with that name is defined.
+// Returns an error listing the available names when the workspace is not found.
+func (c *Config) UseWorkspace(name string) error {
+	if c.workspace == "" {
+		c.defaultWorkingDir = c.File.WorkingDir
+	}
+	for _, ws := range c.Workspaces {
+		if ws.Name == name {
+			c.File.WorkingDir = ws.WorkingDir
+			c.workspace = name
+			return nil
+		}
+	}
+	if name == DefaultWorkspaceName {
+		c.File.WorkingDir = c.defaultWorkingDir
+		c.workspace = name
+		return nil
+	}
+
+	names := []string{DefaultWorkspaceName}
+	for _, ws := range c.Workspaces {
+		names = append(names, ws.Name)
+	}
+	return fmt.Errorf("unknown workspace %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// ForWorkspace returns a copy of c switched to the named workspace (see UseWorkspace).
+// c itself is not changed.
+func (c *Config) ForWorkspace(name string) (*Config, error) {
+	ws := *c
+	if err := ws.UseWorkspace(name); err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+// Workspace returns the name of the active workspace.
+func (c *Config) Workspace() string {
+	if c.workspace == "" {
+		return DefaultWorkspaceName
+	}
+	return c.workspace
+}
+
+// WorkspaceNames returns "default" followed by the configured workspace names.
+func (c *Config) WorkspaceNames() []string {
+	names := []string{DefaultWorkspaceName}
+	for _, ws := range c.Workspaces {
+		if ws.Name != DefaultWorkspaceName {
+			names = append(names, ws.Name)
+		}
+	}
+	return names
+}
+
 // TasksPath returns the full path to the tasks file.
 func (c *Config) TasksPath() (string, error) {
 	dir, err := c.WorkingDir()
@@ -171,9 +784,86 @@ func (c *Config) ArchivePath() (string, error) {
 	return filepath.Join(dir, ArchiveFileName), nil
 }
 
+// ArchiveDelay returns the archive delay resolver for task.FilterArchivable:
+// the [archive] delay_overrides entry for the heading, or delay_days.
+func (c *Config) ArchiveDelay() func(heading string) int {
+	overrides := c.Archive.DelayOverrides
+	days := c.Archive.DelayDays
+	return func(heading string) int {
+		if d, ok := overrides[heading]; ok {
+			return d
+		}
+		return days
+	}
+}
+
+// ArchiveRoutes returns [archive] routes with each file resolved to a full path.
+// Relative paths are taken from the working directory; "~/" is expanded.
+func (c *Config) ArchiveRoutes() (map[string]string, error) {
+	if len(c.Archive.Routes) == 0 {
+		return nil, nil
+	}
+	dir, err := c.WorkingDir()
+	if err != nil {
+		return nil, err
+	}
+	routes := make(map[string]string, len(c.Archive.Routes))
+	for heading, path := range c.Archive.Routes {
+		expanded, err := ExpandPath(path)
+		if err != nil {
+			return nil, err
+		}
+		if !filepath.IsAbs(expanded) {
+			expanded = filepath.Join(dir, expanded)
+		}
+		routes[heading] = expanded
+	}
+	return routes, nil
+}
+
 // EditorCommand returns the editor command with the file path substituted.
 func (c *Config) EditorCommand(filePath string) string {
-	return strings.ReplaceAll(c.Editor.Command, "{file}", filePath)
+	return strings.ReplaceAll(c.editorTemplate(), "{file}", filePath)
+}
+
+// editorTemplate returns [editor] command, or when it is empty "$EDITOR {file}"
+// with $EDITOR read now, so a change made after startup is used ("vi" if unset).
+func (c *Config) editorTemplate() string {
+	if strings.TrimSpace(c.Editor.Command) != "" {
+		return c.Editor.Command
+	}
+	editor := os.Getenv("EDITOR")
+	if strings.TrimSpace(editor) == "" {
+		editor = "vi"
+	}
+	return editor + " {file}"
+}
+
+// EditorArgs returns the editor program and arguments with the file path substituted.
+// The template is split with SplitCommand before substitution, so paths containing
+// spaces stay a single argument. An empty [editor] command uses $EDITOR as in EditorCommand.
+func (c *Config) EditorArgs(filePath string) ([]string, error) {
+	args, err := SplitCommand(c.editorTemplate())
+	if err != nil {
+		return nil, fmt.Errorf("invalid editor command: %w", err)
+	}
+	for i, arg := range args {
+		args[i] = strings.ReplaceAll(arg, "{file}", filePath)
+	}
+	return args, nil
+}
+
+// PostSyncHookArgs returns [git] post_sync_hook split into program and arguments with
+// SplitCommand (no shell is involved), or nil when no hook is set.
+func (c *Config) PostSyncHookArgs() ([]string, error) {
+	if strings.TrimSpace(c.Git.PostSyncHook) == "" {
+		return nil, nil
+	}
+	args, err := SplitCommand(c.Git.PostSyncHook)
+	if err != nil {
+		return nil, fmt.Errorf("invalid [git] post_sync_hook: %w", err)
+	}
+	return args, nil
 }
 
 // Save writes the configuration to the config file.
@@ -3,7 +3,12 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/yostos/tiny-task-tool/internal/task"
 )
 
 // TestDefault verifies that Default() returns a Config with all expected default values.
@@ -23,11 +28,73 @@ func TestDefault(t *testing.T) {
 	if cfg.Archive.DelayDays != 2 {
 		t.Errorf("Archive.DelayDays = %d, want %d", cfg.Archive.DelayDays, 2)
 	}
+	if !cfg.Archive.DoneDateRequired {
+		t.Error("Archive.DoneDateRequired = false, want true")
+	}
 
 	// Verify git settings
 	if cfg.Git.AutoCommit != true {
 		t.Errorf("Git.AutoCommit = %v, want %v", cfg.Git.AutoCommit, true)
 	}
+	if cfg.Git.Mode != GitModeAuto {
+		t.Errorf("Git.Mode = %q, want %q", cfg.Git.Mode, GitModeAuto)
+	}
+	if cfg.Tasks.InboxHeading != "Inbox" {
+		t.Errorf("Tasks.InboxHeading = %q, want %q", cfg.Tasks.InboxHeading, "Inbox")
+	}
+	if cfg.Tasks.Cascade != "all" {
+		t.Errorf("Tasks.Cascade = %q, want %q", cfg.Tasks.Cascade, "all")
+	}
+	if !cfg.Tasks.CascadeRespectManual {
+		t.Error("Tasks.CascadeRespectManual = false, want true")
+	}
+	if cfg.UI.PinnedFirst != PinnedFirstOff {
+		t.Errorf("UI.PinnedFirst = %q, want %q", cfg.UI.PinnedFirst, PinnedFirstOff)
+	}
+	if cfg.File.GuardLines != 100 {
+		t.Errorf("File.GuardLines = %d, want %d", cfg.File.GuardLines, 100)
+	}
+	if cfg.File.GuardTaskRatio != 0.01 {
+		t.Errorf("File.GuardTaskRatio = %v, want %v", cfg.File.GuardTaskRatio, 0.01)
+	}
+	if cfg.File.MaxSizeMB != 10 {
+		t.Errorf("File.MaxSizeMB = %d, want %d", cfg.File.MaxSizeMB, 10)
+	}
+	if len(cfg.Tasks.BulletStyles) != 1 || cfg.Tasks.BulletStyles[0] != "-" {
+		t.Errorf("Tasks.BulletStyles = %q, want [\"-\"]", cfg.Tasks.BulletStyles)
+	}
+	if cfg.Git.AutoSyncMinutes != 0 {
+		t.Errorf("Git.AutoSyncMinutes = %d, want %d", cfg.Git.AutoSyncMinutes, 0)
+	}
+	if cfg.Git.IntegrityCheck {
+		t.Error("Git.IntegrityCheck = true, want false")
+	}
+	if cfg.Search.NormalizeWidth || cfg.Search.IgnoreKana {
+		t.Errorf("Search = %+v, want both false", cfg.Search)
+	}
+
+	// Verify display settings
+	if cfg.Display.RelativeDoneDate != false {
+		t.Errorf("Display.RelativeDoneDate = %v, want %v", cfg.Display.RelativeDoneDate, false)
+	}
+	if cfg.Display.CompletedToBottom || cfg.Display.CompletedToBottomFile {
+		t.Errorf("Display.CompletedToBottom = %v, CompletedToBottomFile = %v, want false", cfg.Display.CompletedToBottom, cfg.Display.CompletedToBottomFile)
+	}
+	if cfg.Display.HideFuture {
+		t.Errorf("Display.HideFuture = %v, want false", cfg.Display.HideFuture)
+	}
+	if cfg.Display.Hyperlinks != true {
+		t.Errorf("Display.Hyperlinks = %v, want %v", cfg.Display.Hyperlinks, true)
+	}
+
+	// Verify theme colors
+	if cfg.Theme.PriorityA != "1" || cfg.Theme.PriorityB != "3" || cfg.Theme.PriorityC != "4" {
+		t.Errorf("Theme priorities = (%q, %q, %q), want (\"1\", \"3\", \"4\")",
+			cfg.Theme.PriorityA, cfg.Theme.PriorityB, cfg.Theme.PriorityC)
+	}
+	if cfg.Theme.Done != "240" {
+		t.Errorf("Theme.Done = %q, want %q", cfg.Theme.Done, "240")
+	}
 
 	// Verify keybindings
 	expectedUp := []string{"k"}
@@ -221,6 +288,53 @@ func TestEditorCommand(t *testing.T) {
 	}
 }
 
+// TestEditorCommandFromEnv verifies that an empty [editor] command reads $EDITOR each
+// time the command is built, while a command written in config.toml always wins.
+func TestEditorCommandFromEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("EDITOR", "nano")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if got := cfg.EditorCommand("/tmp/tasks.md"); got != "nano /tmp/tasks.md" {
+		t.Errorf("EditorCommand() = %q, want $EDITOR", got)
+	}
+
+	// The auto-created config.toml does not pin $EDITOR as it was on first launch
+	t.Setenv("EDITOR", "hx")
+	if got := cfg.EditorCommand("/tmp/tasks.md"); got != "hx /tmp/tasks.md" {
+		t.Errorf("EditorCommand() after changing $EDITOR = %q, want the new value", got)
+	}
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if got := cfg.EditorCommand("/tmp/tasks.md"); got != "hx /tmp/tasks.md" {
+		t.Errorf("EditorCommand() after reloading the created config = %q, want $EDITOR", got)
+	}
+
+	t.Setenv("EDITOR", "")
+	if got := cfg.EditorCommand("/tmp/tasks.md"); got != "vi /tmp/tasks.md" {
+		t.Errorf("EditorCommand() without $EDITOR = %q, want vi", got)
+	}
+
+	configPath := filepath.Join(tmpDir, "ttt", "config.toml")
+	if err := os.WriteFile(configPath, []byte("[editor]\ncommand = \"emacs -nw {file}\"\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	t.Setenv("EDITOR", "nano")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if got := cfg.EditorCommand("/tmp/tasks.md"); got != "emacs -nw /tmp/tasks.md" {
+		t.Errorf("EditorCommand() with an explicit command = %q, want the config value", got)
+	}
+}
+
 // TestLoadNonExistentConfig verifies that Load() creates config file with defaults when it doesn't exist.
 // Spec: docs/specification.md "設定ファイル仕様 > 自動作成" section.
 // 設定ファイルが存在しない場合、初回起動時にデフォルト値で自動作成する。
@@ -314,3 +428,564 @@ working_dir = "~/custom-tasks"
 		t.Errorf("WorkingDir = %q, want %q", cfg.File.WorkingDir, "~/custom-tasks")
 	}
 }
+
+// TestUseWorkspace verifies that UseWorkspace() switches working_dir to the named
+// workspace, treats "default" as [file] working_dir, and rejects unknown names.
+func TestUseWorkspace(t *testing.T) {
+	newConfig := func() *Config {
+		cfg := Default()
+		cfg.Workspaces = []WorkspaceConfig{
+			{Name: "work", WorkingDir: "~/work-tasks"},
+			{Name: "side", WorkingDir: "/srv/side"},
+		}
+		return cfg
+	}
+
+	tests := []struct {
+		name     string
+		use      string
+		expected string
+	}{
+		{"named workspace", "work", "~/work-tasks"},
+		{"another workspace", "side", "/srv/side"},
+		{"default workspace", "default", "~/.ttt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig()
+			if err := cfg.UseWorkspace(tt.use); err != nil {
+				t.Fatalf("UseWorkspace(%q) error: %v", tt.use, err)
+			}
+			if cfg.File.WorkingDir != tt.expected {
+				t.Errorf("WorkingDir = %q, want %q", cfg.File.WorkingDir, tt.expected)
+			}
+		})
+	}
+
+	cfg := newConfig()
+	err := cfg.UseWorkspace("personal")
+	if err == nil {
+		t.Fatal("UseWorkspace() should return error for unknown workspace")
+	}
+	if err.Error() != `unknown workspace "personal" (available: default, work, side)` {
+		t.Errorf("error = %q", err.Error())
+	}
+
+	// Switching back to default restores [file] working_dir
+	cfg = newConfig()
+	if err := cfg.UseWorkspace("work"); err != nil {
+		t.Fatalf("UseWorkspace() error: %v", err)
+	}
+	if err := cfg.UseWorkspace("default"); err != nil || cfg.File.WorkingDir != "~/.ttt" {
+		t.Errorf("UseWorkspace(default) after work: WorkingDir = %q, err = %v", cfg.File.WorkingDir, err)
+	}
+}
+
+// TestForWorkspace verifies that ForWorkspace switches a copy and leaves the config alone.
+func TestForWorkspace(t *testing.T) {
+	cfg := Default()
+	cfg.Workspaces = []WorkspaceConfig{{Name: "work", WorkingDir: "~/work-tasks"}}
+	if err := cfg.UseWorkspace("work"); err != nil {
+		t.Fatalf("UseWorkspace() error: %v", err)
+	}
+
+	def, err := cfg.ForWorkspace("default")
+	if err != nil {
+		t.Fatalf("ForWorkspace() error: %v", err)
+	}
+	if def.File.WorkingDir != "~/.ttt" || def.Workspace() != "default" {
+		t.Errorf("ForWorkspace(default) = %q (%s), want ~/.ttt", def.File.WorkingDir, def.Workspace())
+	}
+	if cfg.File.WorkingDir != "~/work-tasks" || cfg.Workspace() != "work" {
+		t.Errorf("config changed to %q (%s)", cfg.File.WorkingDir, cfg.Workspace())
+	}
+	if _, err := cfg.ForWorkspace("personal"); err == nil {
+		t.Error("ForWorkspace() should return error for unknown workspace")
+	}
+	if got := strings.Join(cfg.WorkspaceNames(), ","); got != "default,work" {
+		t.Errorf("WorkspaceNames() = %s", got)
+	}
+}
+
+// TestLoadWorkspaces verifies that Load() reads [[workspaces]] entries and reports
+// entries without a name or working_dir, or with duplicate names.
+func TestLoadWorkspaces(t *testing.T) {
+	tests := []struct {
+		name    string
+		toml    string
+		wantErr bool
+	}{
+		{"valid", "[[workspaces]]\nname = \"work\"\nworking_dir = \"~/work\"\n", false},
+		{"missing name", "[[workspaces]]\nworking_dir = \"~/work\"\n", true},
+		{"missing working_dir", "[[workspaces]]\nname = \"work\"\n", true},
+		{"duplicate", "[[workspaces]]\nname = \"a\"\nworking_dir = \"~/a\"\n[[workspaces]]\nname = \"a\"\nworking_dir = \"~/b\"\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", tmpDir)
+			configDir := filepath.Join(tmpDir, "ttt")
+			if err := os.MkdirAll(configDir, 0755); err != nil {
+				t.Fatalf("MkdirAll() error: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(tt.toml), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if (cfg.LoadError() != nil) != tt.wantErr {
+				t.Fatalf("ConfigErrors = %q, want errors: %v", cfg.ConfigErrors, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(cfg.Workspaces) != 1 || cfg.Workspaces[0].Name != "work" || cfg.Workspaces[0].WorkingDir != "~/work" {
+				t.Errorf("Workspaces = %+v, want [{work ~/work}]", cfg.Workspaces)
+			}
+		})
+	}
+}
+
+// TestLoadFilters verifies that Load() compiles [[ui.filters]] queries at startup,
+// assigns free number keys to filters without one, and reports invalid entries.
+func TestLoadFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		toml     string
+		wantErr  bool
+		wantKeys []string
+	}{
+		{
+			"keys assigned in order around explicit keys",
+			"[[ui.filters]]\nname = \"a\"\nquery = \"@a\"\n" +
+				"[[ui.filters]]\nname = \"b\"\nquery = \"@b\"\nkey = \"1\"\n" +
+				"[[ui.filters]]\nname = \"c\"\nquery = \"@c\"\n",
+			false,
+			[]string{"2", "1", "3"},
+		},
+		{"bad query", "[[ui.filters]]\nname = \"a\"\nquery = \"priority:high\"\n", true, nil},
+		{"empty query", "[[ui.filters]]\nname = \"a\"\nquery = \"\"\n", true, nil},
+		{"missing name", "[[ui.filters]]\nquery = \"@a\"\n", true, nil},
+		{"key 0 reserved", "[[ui.filters]]\nname = \"a\"\nquery = \"@a\"\nkey = \"0\"\n", true, nil},
+		{"non-number key", "[[ui.filters]]\nname = \"a\"\nquery = \"@a\"\nkey = \"x\"\n", true, nil},
+		{
+			"duplicate key",
+			"[[ui.filters]]\nname = \"a\"\nquery = \"@a\"\nkey = \"1\"\n" +
+				"[[ui.filters]]\nname = \"b\"\nquery = \"@b\"\nkey = \"1\"\n",
+			true,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", tmpDir)
+			configDir := filepath.Join(tmpDir, "ttt")
+			if err := os.MkdirAll(configDir, 0755); err != nil {
+				t.Fatalf("MkdirAll() error: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(tt.toml), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if (cfg.LoadError() != nil) != tt.wantErr {
+				t.Fatalf("ConfigErrors = %q, want errors: %v", cfg.ConfigErrors, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var keys []string
+			for _, f := range cfg.UI.Filters {
+				keys = append(keys, f.Key)
+			}
+			if len(keys) != len(tt.wantKeys) {
+				t.Fatalf("keys = %v, want %v", keys, tt.wantKeys)
+			}
+			for i := range keys {
+				if keys[i] != tt.wantKeys[i] {
+					t.Errorf("keys = %v, want %v", keys, tt.wantKeys)
+					break
+				}
+			}
+		})
+	}
+}
+
+// TestLoadLinkPatterns verifies that [ui.link_patterns] is read and that invalid
+// regexes are reported at load time naming the pattern, leaving no patterns.
+func TestLoadLinkPatterns(t *testing.T) {
+	tests := []struct {
+		name      string
+		toml      string
+		wantErr   string
+		wantCount int
+	}{
+		{"none", "", "", 0},
+		{
+			"valid",
+			"[ui.link_patterns]\n\"JIRA-(\\\\d+)\" = \"https://jira.example.com/browse/JIRA-$1\"\n\"#(\\\\d+)\" = \"https://example.com/issues/$1\"\n",
+			"",
+			2,
+		},
+		{"invalid regex", "[ui.link_patterns]\n\"JIRA-(\" = \"https://example.com\"\n", `"JIRA-("`, 0},
+		{"empty template", "[ui.link_patterns]\n\"#1\" = \"\"\n", "URL template is empty", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", tmpDir)
+			configDir := filepath.Join(tmpDir, "ttt")
+			if err := os.MkdirAll(configDir, 0755); err != nil {
+				t.Fatalf("MkdirAll() error: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(tt.toml), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if tt.wantErr != "" {
+				if err := cfg.LoadError(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadError() = %v, want error containing %q", err, tt.wantErr)
+				}
+			}
+			if got := len(cfg.LinkPatterns()); got != tt.wantCount {
+				t.Errorf("LinkPatterns() count = %d, want %d", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+// TestLoadGuard verifies that [file] guard settings and [tasks] bullet_styles are read
+// and that invalid values are reported and reset, leaving the guard at its default.
+func TestLoadGuard(t *testing.T) {
+	tests := []struct {
+		name      string
+		toml      string
+		wantErr   bool
+		wantLines int
+	}{
+		{"defaults kept", "[file]\nworking_dir = \"~/tasks\"\n", false, 100},
+		{"disabled", "[file]\nguard_lines = 0\n", false, 0},
+		{"negative lines", "[file]\nguard_lines = -1\n", true, 100},
+		{"ratio above 1", "[file]\nguard_task_ratio = 1.5\n", true, 100},
+		{"extra bullet styles", "[tasks]\nbullet_styles = [\"-\", \"*\"]\n", false, 100},
+		{"invalid bullet style", "[tasks]\nbullet_styles = [\"#\"]\n", true, 100},
+		{"empty bullet styles", "[tasks]\nbullet_styles = []\n", true, 100},
+		{"numbered tasks", "[tasks]\nnumbered = true\n", false, 100},
+		{"size limit", "[file]\nmax_size_mb = 50\n", false, 100},
+		{"no size limit", "[file]\nmax_size_mb = 0\n", false, 100},
+		{"negative size limit", "[file]\nmax_size_mb = -1\n", true, 100},
+		{"max depth", "[file]\nmax_depth = 3\n", false, 100},
+		{"negative max depth", "[file]\nmax_depth = -1\n", true, 100},
+		{"tidy on write", "[file]\ntidy_on_write = true\n", false, 100},
+		{"escalation", "[tasks]\nescalate_overdue_days = 3\n", false, 100},
+		{"negative escalation", "[tasks]\nescalate_overdue_days = -1\n", true, 100},
+		{"wrap column", "[tasks]\nwrap_column = 80\n", false, 100},
+		{"negative wrap column", "[tasks]\nwrap_column = -1\n", true, 100},
+		{"open limit", "[tasks]\nopen_limit = 30\n", false, 100},
+		{"negative open limit", "[tasks]\nopen_limit = -1\n", true, 100},
+		{"max task length", "[tasks]\nmax_task_length = 80\n[display]\nwarn_long_tasks = true\n", false, 100},
+		{"negative max task length", "[tasks]\nmax_task_length = -1\n", true, 100},
+		{"archive routes", "[archive.routes]\n\"Project X\" = \"archives/x.md\"\n", false, 100},
+		{"empty archive route", "[archive.routes]\n\"Project X\" = \"\"\n", true, 100},
+		{"archive delay overrides", "[archive.delay_overrides]\nErrands = 1\nProjects = 7\n", false, 100},
+		{"negative archive delay override", "[archive.delay_overrides]\nErrands = -1\n", true, 100},
+		{"git parent repo", "[git]\nmode = \"parent-repo\"\n", false, 100},
+		{"git disabled", "[git]\nmode = \"disabled\"\n", false, 100},
+		{"unknown git mode", "[git]\nmode = \"nested\"\n", true, 100},
+		{"pinned first in view", "[ui]\npinned_first = \"view\"\n", false, 100},
+		{"pinned first in file", "[ui]\npinned_first = \"file\"\n", false, 100},
+		{"unknown pinned first", "[ui]\npinned_first = \"top\"\n", true, 100},
+		{"direct cascade", "[tasks]\ncascade = \"direct\"\n", false, 100},
+		{"cascade off", "[tasks]\ncascade = \"off\"\n", false, 100},
+		{"unknown cascade", "[tasks]\ncascade = \"children\"\n", true, 100},
+		{"expand weekday due", "[tasks]\nweekday_due = \"expand\"\n", false, 100},
+		{"unknown weekday due", "[tasks]\nweekday_due = \"date\"\n", true, 100},
+		{"post-sync hook", "[git]\npost_sync_hook = \"make -C ~/site 'tasks page'\"\n", false, 100},
+		{"unterminated post-sync hook", "[git]\npost_sync_hook = \"make 'site\"\n", true, 100},
+		{"japanese commit messages", "[git]\ncommit_language = \"ja\"\ncommit_prefix = \"📝\"\n", false, 100},
+		{"unknown commit language", "[git]\ncommit_language = \"de\"\n", true, 100},
+		{"multi-line commit prefix", "[git]\ncommit_prefix = \"a\\nb\"\n", true, 100},
+		{"git user", "[git]\nuser_name = \"Task Bot\"\nuser_email = \"bot@example.com\"\n", false, 100},
+		{"multi-line git user", "[git]\nuser_name = \"a\\nb\"\n", true, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", tmpDir)
+			configDir := filepath.Join(tmpDir, "ttt")
+			if err := os.MkdirAll(configDir, 0755); err != nil {
+				t.Fatalf("MkdirAll() error: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(tt.toml), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if (cfg.LoadError() != nil) != tt.wantErr {
+				t.Errorf("ConfigErrors = %q, want errors: %v", cfg.ConfigErrors, tt.wantErr)
+			}
+			if cfg.File.GuardLines != tt.wantLines {
+				t.Errorf("GuardLines = %d, want %d", cfg.File.GuardLines, tt.wantLines)
+			}
+		})
+	}
+}
+
+// TestLoadInvalidValue verifies that a config.toml with one invalid value still loads:
+// that setting keeps its default and is reported, the others apply.
+func TestLoadInvalidValue(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	configDir := filepath.Join(tmpDir, "ttt")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	data := "[tasks]\ncascade = \"children\"\nwrap_column = 80\nbullet_styles = [\"#\"]\n"
+	if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Tasks.Cascade != task.CascadeAll || !slices.Equal(cfg.Tasks.BulletStyles, task.DefaultBulletStyles) {
+		t.Errorf("cascade = %q, bullet_styles = %q, want the defaults", cfg.Tasks.Cascade, cfg.Tasks.BulletStyles)
+	}
+	if cfg.Tasks.WrapColumn != 80 {
+		t.Errorf("wrap_column = %d, want 80", cfg.Tasks.WrapColumn)
+	}
+	if len(cfg.ConfigErrors) != 2 || !strings.Contains(cfg.ConfigErrors[0], "bullet_styles") ||
+		!strings.Contains(cfg.ConfigErrors[1], `cascade "children"`) {
+		t.Errorf("ConfigErrors = %q, want the bullet_styles and cascade errors", cfg.ConfigErrors)
+	}
+}
+
+// TestGitPaths verifies that git operations are limited to the working directory
+// only in parent-repo mode.
+func TestGitPaths(t *testing.T) {
+	cfg := Default()
+	for mode, want := range map[string]int{GitModeOwnRepo: 0, GitModeDisabled: 0, GitModeParentRepo: 1} {
+		cfg.Git.Mode = mode
+		if got := cfg.GitPaths(); len(got) != want {
+			t.Errorf("GitPaths() with mode %q = %q, want %d path(s)", mode, got, want)
+		}
+	}
+}
+
+// TestIsDuplicateTask verifies that duplicate checks follow file.prevent_duplicates
+// and file.duplicate_ignore_tags.
+func TestIsDuplicateTask(t *testing.T) {
+	content := "- [ ] Buy milk @errand\n"
+	cfg := Default()
+
+	if cfg.IsDuplicateTask(content, "Buy milk @errand") {
+		t.Error("IsDuplicateTask() should be false when prevent_duplicates is off")
+	}
+
+	cfg.File.PreventDuplicates = true
+	if !cfg.IsDuplicateTask(content, "Buy milk @errand") {
+		t.Error("IsDuplicateTask() should detect an exact duplicate")
+	}
+	if cfg.IsDuplicateTask(content, "Buy milk") {
+		t.Error("IsDuplicateTask() should compare tags unless duplicate_ignore_tags is set")
+	}
+
+	cfg.File.DuplicateIgnoreTags = true
+	if !cfg.IsDuplicateTask(content, "Buy milk") {
+		t.Error("IsDuplicateTask() should ignore tags when duplicate_ignore_tags is set")
+	}
+}
+
+// TestWithTitle verifies that file.auto_title is applied only when set.
+func TestWithTitle(t *testing.T) {
+	cfg := Default()
+	if got := cfg.WithTitle("- [ ] a\n"); got != "- [ ] a\n" {
+		t.Errorf("WithTitle() with empty auto_title = %q, want content unchanged", got)
+	}
+
+	cfg.File.AutoTitle = "# Tasks"
+	if got := cfg.WithTitle("- [ ] a\n"); got != "# Tasks\n\n- [ ] a\n" {
+		t.Errorf("WithTitle() = %q, want title prepended", got)
+	}
+}
+
+// TestArchiveDelay verifies that delay_overrides win over delay_days for their heading only.
+func TestArchiveDelay(t *testing.T) {
+	cfg := Default()
+	cfg.Archive.DelayOverrides = map[string]int{"Errands": 1, "Projects": 7}
+	delayFor := cfg.ArchiveDelay()
+
+	tests := []struct {
+		heading string
+		want    int
+	}{
+		{"Errands", 1},
+		{"Projects", 7},
+		{"errands", 2},
+		{"Inbox", 2},
+		{"", 2},
+	}
+	for _, tt := range tests {
+		if got := delayFor(tt.heading); got != tt.want {
+			t.Errorf("ArchiveDelay()(%q) = %d, want %d", tt.heading, got, tt.want)
+		}
+	}
+}
+
+// TestTaskOptions verifies that the default config maps to task.DefaultOptions, and
+// that the settings reach their task.Options fields.
+func TestTaskOptions(t *testing.T) {
+	if got, want := Default().TaskOptions(), task.DefaultOptions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Default().TaskOptions() = %+v, want %+v", got, want)
+	}
+
+	cfg := Default()
+	cfg.Tasks.Cascade = task.CascadeOff
+	cfg.Archive.DoneDateRequired = false
+	cfg.Display.CompletedToBottomFile = true
+	cfg.File.MaxDepth = 3
+	opts := cfg.TaskOptions()
+	if opts.Cascade != task.CascadeOff || !opts.DoneDateOptional || !opts.CompletedToBottom || opts.MaxDepth != 3 {
+		t.Errorf("TaskOptions() = %+v, want the changed settings", opts)
+	}
+}
+
+// TestArchiveRoutes verifies that route files resolve against the working directory.
+func TestArchiveRoutes(t *testing.T) {
+	cfg := Default()
+	cfg.File.WorkingDir = "/tasks"
+
+	routes, err := cfg.ArchiveRoutes()
+	if err != nil || routes != nil {
+		t.Errorf("ArchiveRoutes() without routes = %v, %v, want nil", routes, err)
+	}
+
+	cfg.Archive.Routes = map[string]string{
+		"Project X": "archives/project-x.md",
+		"Project Y": "/elsewhere/y.md",
+	}
+	routes, err = cfg.ArchiveRoutes()
+	if err != nil {
+		t.Fatalf("ArchiveRoutes() error: %v", err)
+	}
+	if got := routes["Project X"]; got != filepath.Join("/tasks", "archives", "project-x.md") {
+		t.Errorf("Project X route = %q", got)
+	}
+	if got := routes["Project Y"]; got != "/elsewhere/y.md" {
+		t.Errorf("Project Y route = %q", got)
+	}
+}
+
+// TestLoadUnknownKeys verifies that unknown keys are listed with their line while
+// every known key is still applied, and that maps accept any key.
+func TestLoadUnknownKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	configDir := filepath.Join(tmpDir, "ttt")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	content := `[archive]
+auto = true
+dely_days = 5
+
+[archive.routes]
+"Anything Goes" = "a.md"
+
+[future]
+setting = 1
+`
+	if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	want := []string{"archive.dely_days (line 3)", "future (line 8)"}
+	if strings.Join(cfg.UnknownKeys, ", ") != strings.Join(want, ", ") {
+		t.Errorf("UnknownKeys = %q, want %q", cfg.UnknownKeys, want)
+	}
+	if !cfg.Archive.Auto || cfg.Archive.DelayDays != 2 {
+		t.Errorf("Archive = %+v, want auto on and the default delay", cfg.Archive)
+	}
+	if cfg.Archive.Routes["Anything Goes"] != "a.md" {
+		t.Errorf("Archive.Routes = %v, want the route kept", cfg.Archive.Routes)
+	}
+}
+
+// TestLoadBrokenConfig verifies that lines config.toml cannot read are reported and
+// skipped, the valid keys still apply, and the file is left as it was.
+func TestLoadBrokenConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		toml       string
+		wantErrors []string // prefixes of ConfigErrors
+		wantAuto   bool
+		wantDelay  int
+	}{
+		{"valid", "[archive]\nauto = true\ndelay_days = 5\n", nil, true, 5},
+		{"wrong type", "[archive]\ndelay_days = \"soon\"\nauto = true\n", []string{"line 2: "}, true, 2},
+		{"syntax error", "[archive]\nauto = true\ndelay_days = 5 5\n", []string{"line 3: "}, true, 2},
+		{"two bad lines", "[archive]\nauto = yes\ndelay_days = \"soon\"\n[ui]\nscrollbar = false\n", []string{"line 2: ", "line 3: "}, false, 2},
+		{"unreadable", "[archive\nauto = true\n", []string{"line 1: "}, false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", tmpDir)
+			configDir := filepath.Join(tmpDir, "ttt")
+			if err := os.MkdirAll(configDir, 0755); err != nil {
+				t.Fatalf("MkdirAll() error: %v", err)
+			}
+			configPath := filepath.Join(configDir, "config.toml")
+			if err := os.WriteFile(configPath, []byte(tt.toml), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if len(cfg.ConfigErrors) != len(tt.wantErrors) {
+				t.Fatalf("ConfigErrors = %q, want %d error(s)", cfg.ConfigErrors, len(tt.wantErrors))
+			}
+			for i, prefix := range tt.wantErrors {
+				if !strings.HasPrefix(cfg.ConfigErrors[i], prefix) {
+					t.Errorf("ConfigErrors[%d] = %q, want prefix %q", i, cfg.ConfigErrors[i], prefix)
+				}
+			}
+			if (cfg.LoadError() != nil) != (len(tt.wantErrors) > 0) {
+				t.Errorf("LoadError() = %v with ConfigErrors %q", cfg.LoadError(), cfg.ConfigErrors)
+			}
+			if cfg.Archive.Auto != tt.wantAuto || cfg.Archive.DelayDays != tt.wantDelay {
+				t.Errorf("Archive = %+v, want auto %v and delay %d", cfg.Archive, tt.wantAuto, tt.wantDelay)
+			}
+			if data, err := os.ReadFile(configPath); err != nil || string(data) != tt.toml {
+				t.Errorf("config.toml changed to %q (error: %v)", data, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// namedKeys lists the key names reported by bubbletea's KeyMsg.String().
+var namedKeys = map[string]bool{
+	"up": true, "down": true, "left": true, "right": true,
+	"home": true, "end": true, "pgup": true, "pgdown": true,
+	"tab": true, "shift+tab": true, "enter": true, "esc": true,
+	"backspace": true, "delete": true, "insert": true, " ": true,
+	"shift+up": true, "shift+down": true, "shift+left": true, "shift+right": true,
+	"shift+home": true, "shift+end": true,
+	"ctrl+up": true, "ctrl+down": true, "ctrl+left": true, "ctrl+right": true,
+	"ctrl+home": true, "ctrl+end": true, "ctrl+pgup": true, "ctrl+pgdown": true,
+	"ctrl+shift+up": true, "ctrl+shift+down": true, "ctrl+shift+left": true,
+	"ctrl+shift+right": true, "ctrl+shift+home": true, "ctrl+shift+end": true,
+	"ctrl+@": true, "ctrl+\\": true, "ctrl+]": true, "ctrl+^": true, "ctrl+_": true,
+}
+
+// keyAliases maps common alternative spellings to bubbletea key names.
+var keyAliases = map[string]string{
+	"escape":        "esc",
+	"space":         " ",
+	"return":        "enter",
+	"del":           "delete",
+	"ins":           "insert",
+	"pageup":        "pgup",
+	"pagedown":      "pgdown",
+	"pgdn":          "pgdown",
+	"ctrl+pageup":   "ctrl+pgup",
+	"ctrl+pagedown": "ctrl+pgdown",
+	"ctrl+pgdn":     "ctrl+pgdown",
+	"ctrl+i":        "tab",
+	"ctrl+m":        "enter",
+	"ctrl+[":        "esc",
+}
+
+func init() {
+	for c := 'a'; c <= 'z'; c++ {
+		namedKeys["ctrl+"+string(c)] = true
+	}
+	for i := 1; i <= 20; i++ {
+		namedKeys[fmt.Sprintf("f%d", i)] = true
+	}
+	// Terminals deliver these as other keys; keep the aliases above authoritative.
+	delete(namedKeys, "ctrl+i")
+	delete(namedKeys, "ctrl+m")
+}
+
+// NormalizeKey converts a keybinding string to the form bubbletea reports for a key press.
+// Single characters are kept as-is (case sensitive: G ≠ g).
+// Named keys and modifier prefixes are case-insensitive ("Ctrl+P" → "ctrl+p", "Home" → "home"),
+// and common aliases are mapped ("escape" → "esc", "space" → " ", "pageup" → "pgup").
+// Returns an error for unknown key names.
+func NormalizeKey(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("empty key")
+	}
+
+	prefix := ""
+	rest := key
+	if len(rest) > len("alt+") && strings.EqualFold(rest[:len("alt+")], "alt+") {
+		prefix = "alt+"
+		rest = rest[len("alt+"):]
+	}
+
+	if utf8.RuneCountInString(rest) == 1 {
+		return prefix + rest, nil
+	}
+
+	name := strings.ToLower(rest)
+	if alias, ok := keyAliases[name]; ok {
+		name = alias
+	}
+	if !namedKeys[name] {
+		return "", fmt.Errorf("unknown key %q (valid forms: a single character like \"k\" or \"G\", "+
+			"ctrl+<letter>, alt+<key>, f1-f20, or a named key such as up, down, left, right, "+
+			"home, end, pgup, pgdown, tab, enter, esc, space, backspace, delete)", key)
+	}
+	return prefix + name, nil
+}
+
+// Normalize normalizes every configured key with NormalizeKey.
+// Returns an error naming the setting that contains an unknown key.
+func (k *KeybindingsConfig) Normalize() error {
+	fields := []struct {
+		name string
+		keys []string
+	}{
+		{"up", k.Up},
+		{"down", k.Down},
+		{"top", k.Top},
+		{"bottom", k.Bottom},
+		{"half_page_up", k.HalfPageUp},
+		{"half_page_down", k.HalfPageDown},
+	}
+
+	for _, f := range fields {
+		for i, key := range f.keys {
+			normalized, err := NormalizeKey(key)
+			if err != nil {
+				return fmt.Errorf("invalid keybinding [keybindings] %s: %w", f.name, err)
+			}
+			f.keys[i] = normalized
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestNormalizeKey verifies that NormalizeKey() converts configured keys to the
+// strings bubbletea reports: modifiers and named keys are lowercased, aliases are
+// mapped, and single characters keep their case.
+// Spec: docs/specification.md "Configuration File Structure" (modifier key notation).
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		expected string
+	}{
+		{"lowercase letter unchanged", "k", "k"},
+		{"uppercase letter unchanged", "G", "G"},
+		{"symbol unchanged", "<", "<"},
+		{"capitalized ctrl", "Ctrl+P", "ctrl+p"},
+		{"upper ctrl", "CTRL+N", "ctrl+n"},
+		{"already normalized ctrl", "ctrl+u", "ctrl+u"},
+		{"alt keeps character case", "Alt+G", "alt+G"},
+		{"alt with symbol", "alt+<", "alt+<"},
+		{"alt with ctrl", "Alt+Ctrl+P", "alt+ctrl+p"},
+		{"Home", "Home", "home"},
+		{"End", "End", "end"},
+		{"arrow up", "Up", "up"},
+		{"arrow down", "DOWN", "down"},
+		{"shift arrow", "Shift+Up", "shift+up"},
+		{"escape alias", "escape", "esc"},
+		{"Esc", "Esc", "esc"},
+		{"space alias", "space", " "},
+		{"pageup alias", "PageUp", "pgup"},
+		{"pagedown alias", "pagedown", "pgdown"},
+		{"pgdn alias", "pgdn", "pgdown"},
+		{"pgup", "PgUp", "pgup"},
+		{"return alias", "Return", "enter"},
+		{"ctrl+i is tab", "ctrl+i", "tab"},
+		{"function key", "F5", "f5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NormalizeKey(tt.key)
+			if err != nil {
+				t.Fatalf("NormalizeKey(%q) error: %v", tt.key, err)
+			}
+			if result != tt.expected {
+				t.Errorf("NormalizeKey(%q) = %q, want %q", tt.key, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestNormalizeKeyInvalid verifies that NormalizeKey() rejects unknown key names
+// with an error that names the key and lists valid forms.
+func TestNormalizeKeyInvalid(t *testing.T) {
+	tests := []string{"", "Ctrl+Foo", "upp", "shift+g", "f21", "hyper+k"}
+
+	for _, key := range tests {
+		t.Run(key, func(t *testing.T) {
+			_, err := NormalizeKey(key)
+			if err == nil {
+				t.Fatalf("NormalizeKey(%q) should return error", key)
+			}
+			if key != "" && !strings.Contains(err.Error(), "valid forms") {
+				t.Errorf("error should list valid forms, got: %v", err)
+			}
+		})
+	}
+}
+
+// TestKeybindingsNormalize verifies that Normalize() rewrites every keybinding list
+// in place and reports which setting holds an invalid key.
+func TestKeybindingsNormalize(t *testing.T) {
+	k := KeybindingsConfig{
+		Up:           []string{"k", "Ctrl+P"},
+		Down:         []string{"j", "Ctrl+N"},
+		Top:          []string{"g", "Home"},
+		Bottom:       []string{"G", "End"},
+		HalfPageUp:   []string{"PageUp"},
+		HalfPageDown: []string{"PgDn"},
+	}
+
+	if err := k.Normalize(); err != nil {
+		t.Fatalf("Normalize() error: %v", err)
+	}
+
+	expected := KeybindingsConfig{
+		Up:           []string{"k", "ctrl+p"},
+		Down:         []string{"j", "ctrl+n"},
+		Top:          []string{"g", "home"},
+		Bottom:       []string{"G", "end"},
+		HalfPageUp:   []string{"pgup"},
+		HalfPageDown: []string{"pgdown"},
+	}
+	if strings.Join(k.Up, ",") != strings.Join(expected.Up, ",") ||
+		strings.Join(k.Down, ",") != strings.Join(expected.Down, ",") ||
+		strings.Join(k.Top, ",") != strings.Join(expected.Top, ",") ||
+		strings.Join(k.Bottom, ",") != strings.Join(expected.Bottom, ",") ||
+		strings.Join(k.HalfPageUp, ",") != strings.Join(expected.HalfPageUp, ",") ||
+		strings.Join(k.HalfPageDown, ",") != strings.Join(expected.HalfPageDown, ",") {
+		t.Errorf("Normalize() = %+v, want %+v", k, expected)
+	}
+
+	bad := KeybindingsConfig{Down: []string{"j", "Ctrl+Foo"}}
+	err := bad.Normalize()
+	if err == nil {
+		t.Fatal("Normalize() should return error for unknown key")
+	}
+	if !strings.Contains(err.Error(), "[keybindings] down") {
+		t.Errorf("error should name the setting, got: %v", err)
+	}
+}
+
+// TestLoadNormalizesKeybindings verifies that Load() normalizes keybindings so that
+// `up = ["Ctrl+P"]` matches bubbletea's "ctrl+p".
+func TestLoadNormalizesKeybindings(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+	configDir := filepath.Join(tmpDir, "ttt")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	customConfig := `[keybindings]
+up = ["Ctrl+P"]
+top = ["Home"]
+`
+	if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(customConfig), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(cfg.Keybindings.Up) != 1 || cfg.Keybindings.Up[0] != "ctrl+p" {
+		t.Errorf("Keybindings.Up = %v, want [ctrl+p]", cfg.Keybindings.Up)
+	}
+	if len(cfg.Keybindings.Top) != 1 || cfg.Keybindings.Top[0] != "home" {
+		t.Errorf("Keybindings.Top = %v, want [home]", cfg.Keybindings.Top)
+	}
+}
+
+// TestLoadRejectsUnknownKeybinding verifies that Load() reports a helpful error and
+// keeps the default keybindings when a keybinding names an unknown key.
+func TestLoadRejectsUnknownKeybinding(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+	configDir := filepath.Join(tmpDir, "ttt")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	customConfig := `[keybindings]
+up = ["Ctrl+Foo"]
+`
+	if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(customConfig), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if err := cfg.LoadError(); err == nil || !strings.Contains(err.Error(), `"Ctrl+Foo"`) {
+		t.Errorf("LoadError() should quote the invalid key, got: %v", err)
+	}
+	if !slices.Equal(cfg.Keybindings.Up, Default().Keybindings.Up) {
+		t.Errorf("Keybindings.Up = %v, want the default", cfg.Keybindings.Up)
+	}
+}
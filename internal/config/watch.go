@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long Watch waits after a change to config.toml for more changes
+// before it loads the file, so an editor that saves in several writes is read once.
+var watchDelay = 100 * time.Millisecond
+
+// Watch watches config.toml and, once it has changed, loads it again and calls
+// onChange with the new config. When the changed file fails to load, or has lines
+// that cannot be read or settings that are not valid, onError gets the error
+// instead, so the caller can keep the config it has. A missing config.toml is not
+// reported (an editor may be replacing it) and is not recreated.
+//
+// The config directory is watched rather than the file, filtered to config.toml, so
+// editors that save by renaming a new file over the old one are followed too. Errors of
+// the watch itself also go to onError. Callbacks run on the watching goroutine. Watch
+// fails when config.toml cannot be found or watched; stop ends the watch.
+func Watch(onChange func(*Config), onError func(error)) (stop func(), err error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer watcher.Close()
+		var settled <-chan time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					settled = time.After(watchDelay)
+				}
+				continue
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				onError(err)
+				continue
+			case <-settled:
+				settled = nil
+			}
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			cfg, err := Load()
+			if err == nil {
+				err = cfg.LoadError()
+			}
+			if err != nil {
+				onError(err)
+				continue
+			}
+			onChange(cfg)
+		}
+	}()
+
+	var stopped bool
+	return func() {
+		if !stopped {
+			stopped = true
+			close(done)
+		}
+	}, nil
+}
+
+// Reloaded returns a copy of c with the settings of next that take effect while the
+// TUI is running: [editor], [keybindings], [display], [ui], [search], and [theme].
+// The files, archive, task, and git settings, and the active workspace, stay as in c
+// until ttt restarts.
+func (c *Config) Reloaded(next *Config) *Config {
+	cfg := *c
+	cfg.Editor = next.Editor
+	cfg.Keybindings = next.Keybindings
+	cfg.Display = next.Display
+	cfg.UI = next.UI
+	cfg.Search = next.Search
+	cfg.Theme = next.Theme
+	cfg.UnknownKeys = next.UnknownKeys
+	cfg.ConfigErrors = next.ConfigErrors
+	return &cfg
+}
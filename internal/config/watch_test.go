@@ -0,0 +1,144 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWatch verifies that Watch reports each change to config.toml, also when it is
+// saved by a rename: a config that loads goes to onChange, one that does not or has an
+// unreadable line goes to onError, and a missing config.toml is neither reported nor recreated.
+func TestWatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	defer func(delay time.Duration) { watchDelay = delay }(watchDelay)
+	watchDelay = 10 * time.Millisecond
+
+	configPath := filepath.Join(tmpDir, "ttt", "config.toml")
+	if _, err := Load(); err != nil { // creates config.toml
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	changes := make(chan *Config, 1)
+	errs := make(chan error, 1)
+	stop, err := Watch(func(cfg *Config) { changes <- cfg }, func(err error) { errs <- err })
+	if err != nil {
+		t.Fatalf("Watch() error: %v", err)
+	}
+	defer stop()
+
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	timeout := time.After(5 * time.Second)
+
+	write("[theme]\ndone = \"8\"\n")
+	select {
+	case cfg := <-changes:
+		if cfg.Theme.Done != "8" {
+			t.Errorf("Theme.Done = %q, want %q", cfg.Theme.Done, "8")
+		}
+	case err := <-errs:
+		t.Fatalf("onError(%v), want onChange", err)
+	case <-timeout:
+		t.Fatal("config change not reported")
+	}
+
+	// Editors that save by renaming a new file over config.toml
+	renamed := filepath.Join(tmpDir, "ttt", "config.toml.tmp")
+	if err := os.WriteFile(renamed, []byte("[theme]\ndone = \"9\"\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if err := os.Rename(renamed, configPath); err != nil {
+		t.Fatalf("Rename() error: %v", err)
+	}
+	select {
+	case cfg := <-changes:
+		if cfg.Theme.Done != "9" {
+			t.Errorf("Theme.Done = %q after a rename, want %q", cfg.Theme.Done, "9")
+		}
+	case err := <-errs:
+		t.Fatalf("onError(%v) after a rename, want onChange", err)
+	case <-timeout:
+		t.Fatal("config saved by a rename not reported")
+	}
+
+	write("[ui]\npinned_first = \"sideways\"\n")
+	select {
+	case cfg := <-changes:
+		t.Fatalf("onChange(%+v), want onError", cfg.UI)
+	case <-errs:
+	case <-timeout:
+		t.Fatal("config error not reported")
+	}
+
+	write("[theme]\ndone = 8\n")
+	select {
+	case cfg := <-changes:
+		t.Fatalf("onChange(%+v) with an unreadable line, want onError", cfg.Theme)
+	case <-errs:
+	case <-timeout:
+		t.Fatal("unreadable line not reported")
+	}
+
+	if err := os.Remove(configPath); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	time.Sleep(5 * watchDelay)
+	select {
+	case cfg := <-changes:
+		t.Errorf("onChange(%+v) for a missing config.toml", cfg.UI)
+	case err := <-errs:
+		t.Errorf("onError(%v) for a missing config.toml", err)
+	default:
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Errorf("config.toml recreated while watching (Stat error: %v)", err)
+	}
+}
+
+// TestWatchMissingConfig verifies that Watch fails when config.toml does not exist.
+func TestWatchMissingConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if _, err := Watch(func(*Config) {}, func(error) {}); err == nil {
+		t.Error("Watch() error = nil, want error")
+	}
+}
+
+// TestReloaded verifies that Reloaded takes the display-related sections from the new
+// config and keeps the file, task, and git settings and the active workspace.
+func TestReloaded(t *testing.T) {
+	cfg := Default()
+	cfg.File.WorkingDir = "~/tasks"
+	cfg.Workspaces = []WorkspaceConfig{{Name: "work", WorkingDir: "~/work"}}
+	if err := cfg.UseWorkspace("work"); err != nil {
+		t.Fatalf("UseWorkspace() error: %v", err)
+	}
+
+	next := Default()
+	next.File.WorkingDir = "~/elsewhere"
+	next.Git.AutoCommit = !cfg.Git.AutoCommit
+	next.Keybindings.Up = []string{"w"}
+	next.Theme.Done = "8"
+	next.UI.Scrollbar = !cfg.UI.Scrollbar
+
+	got := cfg.Reloaded(next)
+	if got.Keybindings.Up[0] != "w" || got.Theme.Done != "8" || got.UI.Scrollbar != next.UI.Scrollbar {
+		t.Errorf("Reloaded() did not take [keybindings], [theme], and [ui]: %+v", got)
+	}
+	if got.File.WorkingDir != "~/work" || got.Workspace() != "work" {
+		t.Errorf("Reloaded() working_dir = %q in workspace %q, want %q in %q",
+			got.File.WorkingDir, got.Workspace(), "~/work", "work")
+	}
+	if got.Git.AutoCommit != cfg.Git.AutoCommit {
+		t.Errorf("Reloaded() Git.AutoCommit = %v, want %v", got.Git.AutoCommit, cfg.Git.AutoCommit)
+	}
+	if cfg.Theme.Done == "8" {
+		t.Error("Reloaded() changed the original config")
+	}
+}
@@ -2,11 +2,100 @@
 package git
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"time"
 )
 
+// logger receives the debug events of --verbose (see SetLogger).
+var logger = slog.New(slog.DiscardHandler)
+
+// SetLogger makes the package log every command it runs, with its duration, to l as
+// a debug event. By default they are discarded. It is meant to be called once at startup.
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
+// runCmd, cmdOutput, and cmdCombinedOutput are cmd.Run, cmd.Output, and
+// cmd.CombinedOutput, logging the command (see SetLogger).
+func runCmd(cmd *exec.Cmd) error {
+	_, err := logged(cmd, func() ([]byte, error) { return nil, cmd.Run() })
+	return err
+}
+
+func cmdOutput(cmd *exec.Cmd) ([]byte, error) {
+	return logged(cmd, cmd.Output)
+}
+
+func cmdCombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	return logged(cmd, cmd.CombinedOutput)
+}
+
+// logged runs cmd with run and logs its arguments, directory, duration, and error.
+// The clock is not read when nothing is logged.
+func logged(cmd *exec.Cmd, run func() ([]byte, error)) ([]byte, error) {
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return run()
+	}
+	start := time.Now()
+	output, err := run()
+	attrs := []any{"command", strings.Join(cmd.Args, " "), "dir", cmd.Dir, "duration", time.Since(start)}
+	if err != nil {
+		attrs = append(attrs, "err", err)
+	}
+	logger.Debug("run", attrs...)
+	return output, err
+}
+
+// Init creates an empty repository in dir (git init).
+func Init(dir string) error {
+	cmd := exec.Command("git", "init")
+	cmd.Dir = dir
+	return runCmd(cmd)
+}
+
+// SetUser sets user.name and user.email in the repository at dir to name and email,
+// each only when the repository has no value of its own, so a value set there is never
+// overwritten. An empty name or email is skipped (git then uses the global setting).
+func SetUser(dir, name, email string) error {
+	for _, kv := range [][2]string{{"user.name", name}, {"user.email", email}} {
+		if kv[1] == "" {
+			continue
+		}
+		if value, err := runGit(dir, "config", "--local", "--get", kv[0]); err == nil && value != "" {
+			continue
+		}
+		if output, err := runGit(dir, "config", "--local", kv[0], kv[1]); err != nil {
+			return fmt.Errorf("failed to set %s: %s", kv[0], output)
+		}
+	}
+	return nil
+}
+
+// CheckUser returns an error that explains how to set user.name and user.email when
+// git finds neither in the repository at dir nor in the global or system config, so
+// commits would fail. Returns nil when both are set.
+func CheckUser(dir string) error {
+	var missing []string
+	for _, key := range []string{"user.name", "user.email"} {
+		if value, err := runGit(dir, "config", "--get", key); err != nil || value == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("git %s not set, so ttt cannot commit: run git config --global user.name \"Your Name\" "+
+		"and git config --global user.email you@example.com, or set user_name and user_email under [git] in config.toml",
+		strings.Join(missing, " and "))
+}
+
 // SetRemote sets or updates the remote URL for origin.
 // If origin already exists, it updates the URL using set-url.
 func SetRemote(dir, url string) error {
@@ -14,14 +103,14 @@ func SetRemote(dir, url string) error {
 		// Update existing remote
 		cmd := exec.Command("git", "remote", "set-url", "origin", url)
 		cmd.Dir = dir
-		if err := cmd.Run(); err != nil {
+		if err := runCmd(cmd); err != nil {
 			return fmt.Errorf("failed to update remote: %w", err)
 		}
 	} else {
 		// Add new remote
 		cmd := exec.Command("git", "remote", "add", "origin", url)
 		cmd.Dir = dir
-		if err := cmd.Run(); err != nil {
+		if err := runCmd(cmd); err != nil {
 			return fmt.Errorf("failed to add remote: %w", err)
 		}
 	}
@@ -32,24 +121,339 @@ func SetRemote(dir, url string) error {
 func HasRemote(dir, name string) bool {
 	cmd := exec.Command("git", "remote", "get-url", name)
 	cmd.Dir = dir
-	return cmd.Run() == nil
+	return runCmd(cmd) == nil
+}
+
+// Toplevel returns the root of the git repository that contains dir, which may be
+// a parent directory of dir. Returns an error if dir is not inside a repository.
+func Toplevel(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	output, err := cmdOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("%s is not inside a git repository", dir)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// pathspec returns "--" followed by paths, or nothing when paths is empty
+// (the whole repository).
+func pathspec(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	return append([]string{"--"}, paths...)
 }
 
 // GetCurrentBranch returns the current branch name.
 func GetCurrentBranch(dir string) (string, error) {
 	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
 	cmd.Dir = dir
-	output, err := cmd.Output()
+	output, err := cmdOutput(cmd)
 	if err != nil {
 		return "", fmt.Errorf("failed to get current branch: %w", err)
 	}
 	return strings.TrimSpace(string(output)), nil
 }
 
+// Diff returns the uncommitted changes in the working tree as unified diff text,
+// limited to paths (relative to dir) when given.
+// Returns an empty string when there are no changes.
+func Diff(dir string, paths ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"diff", "--no-color", "--no-ext-diff"}, pathspec(paths)...)...)
+	cmd.Dir = dir
+	output, err := cmdOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get diff: %w", err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// DiffFile returns the changes to path since the last commit (HEAD) as unified diff text,
+// including staged changes. Returns an empty string when the file is unchanged.
+func DiffFile(dir, path string) (string, error) {
+	cmd := exec.Command("git", "diff", "--no-color", "--no-ext-diff", "HEAD", "--", path)
+	cmd.Dir = dir
+	output, err := cmdOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get diff: %w", err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// DiffStat returns the number of lines added to and deleted from path since
+// the last commit (HEAD). Returns zeros when the file is unchanged or the
+// repository has no commits yet.
+func DiffStat(dir, path string) (added, deleted int, err error) {
+	if !revExists(dir, "HEAD") {
+		return 0, 0, nil
+	}
+	cmd := exec.Command("git", "diff", "--numstat", "HEAD", "--", path)
+	cmd.Dir = dir
+	output, err := cmdOutput(cmd)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get diff stat: %w", err)
+	}
+	if len(strings.TrimSpace(string(output))) == 0 {
+		return 0, 0, nil
+	}
+	// "<added>\t<deleted>\t<path>"; binary files report "-" and fail to parse
+	if _, err := fmt.Sscanf(string(output), "%d\t%d", &added, &deleted); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse diff stat %q: %w", output, err)
+	}
+	return added, deleted, nil
+}
+
+// IsDirty reports whether the working tree has uncommitted changes (including untracked files),
+// looking only at paths (relative to dir) when given.
+func IsDirty(dir string, paths ...string) (bool, error) {
+	cmd := exec.Command("git", append([]string{"status", "--porcelain"}, pathspec(paths)...)...)
+	cmd.Dir = dir
+	output, err := cmdOutput(cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to check status: %w", err)
+	}
+	return len(strings.TrimSpace(string(output))) > 0, nil
+}
+
+// RepoStatus is the state of a working tree relative to its last commit and its remote.
+type RepoStatus struct {
+	HasRemote bool // origin is configured
+	Dirty     bool // uncommitted changes, including untracked files
+	Ahead     int  // commits not pushed yet; 0 without a remote
+}
+
+// Status returns the state of the repository at dir. When paths (relative to dir)
+// are given, only changes to them count as uncommitted.
+func Status(dir string, paths ...string) (RepoStatus, error) {
+	var status RepoStatus
+	dirty, err := IsDirty(dir, paths...)
+	if err != nil {
+		return status, err
+	}
+	status.Dirty = dirty
+	status.HasRemote = HasRemote(dir, "origin")
+	if status.HasRemote {
+		status.Ahead, _, err = AheadBehind(dir)
+	}
+	return status, err
+}
+
+// AheadBehind returns how many commits the current branch is ahead of and behind
+// its upstream. Before the first push (no upstream and no origin/<branch>),
+// every commit counts as ahead. The result reflects the last fetch; no network access is made.
+func AheadBehind(dir string) (ahead, behind int, err error) {
+	upstream := "@{upstream}"
+	if !revExists(dir, upstream) {
+		branch, err := GetCurrentBranch(dir)
+		if err != nil {
+			return 0, 0, err
+		}
+		upstream = "refs/remotes/origin/" + branch
+		if !revExists(dir, upstream) {
+			// Nothing pushed yet
+			count, err := revCount(dir, "HEAD")
+			return count, 0, err
+		}
+	}
+
+	cmd := exec.Command("git", "rev-list", "--left-right", "--count", "HEAD..."+upstream)
+	cmd.Dir = dir
+	output, err := cmdOutput(cmd)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to compare with upstream: %w", err)
+	}
+	if _, err := fmt.Sscanf(string(output), "%d %d", &ahead, &behind); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse rev-list output %q: %w", output, err)
+	}
+	return ahead, behind, nil
+}
+
+// revCount returns the number of commits reachable from rev (0 in a repository without commits).
+func revCount(dir, rev string) (int, error) {
+	cmd := exec.Command("git", "rev-list", "--count", rev)
+	cmd.Dir = dir
+	output, err := cmdOutput(cmd)
+	if err != nil {
+		if !revExists(dir, rev) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to count commits: %w", err)
+	}
+	var count int
+	if _, err := fmt.Sscanf(string(output), "%d", &count); err != nil {
+		return 0, fmt.Errorf("failed to parse commit count %q: %w", output, err)
+	}
+	return count, nil
+}
+
+// CommitAll stages the changes to paths (relative to dir; the whole repository when
+// none are given), including new and deleted files, and records them in one commit,
+// leaving any other changes in the working tree uncommitted. Does nothing if paths
+// have no changes.
+//
+// The repository's hooks run as for any git commit, wherever core.hooksPath points.
+// A failing hook stops the commit, and its output is part of the error. When a hook
+// changes files that had no uncommitted changes before the commit (say, a pre-commit
+// hook that regenerates a badge), those changes are amended into the commit, without
+// running the hooks again, so the work tree is left as clean as the hook intended.
+func CommitAll(dir, message string, paths ...string) error {
+	if output, err := runGit(dir, append([]string{"add", "-A"}, pathspec(paths)...)...); err != nil {
+		return fmt.Errorf("failed to stage changes: %s", output)
+	}
+
+	cmd := exec.Command("git", append([]string{"diff", "--cached", "--quiet"}, pathspec(paths)...)...)
+	cmd.Dir = dir
+	if err := runCmd(cmd); err == nil {
+		// No changes to commit
+		return nil
+	}
+
+	before, err := statusEntries(dir)
+	if err != nil {
+		return err
+	}
+	if output, err := runGit(dir, append([]string{"commit", "-m", message}, pathspec(paths)...)...); err != nil {
+		if err := CheckUser(dir); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to commit: %s", output)
+	}
+
+	after, err := statusEntries(dir)
+	if err != nil {
+		return err
+	}
+	var changed []string
+	for path, state := range after {
+		if before[path] != state {
+			changed = append(changed, path)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	sort.Strings(changed)
+	if output, err := runGit(dir, append([]string{"add", "-A"}, pathspec(changed)...)...); err != nil {
+		return fmt.Errorf("committed, but failed to stage the changes of a commit hook: %s", output)
+	}
+	if output, err := runGit(dir, append([]string{"commit", "--amend", "--no-edit", "--no-verify"}, pathspec(changed)...)...); err != nil {
+		return fmt.Errorf("committed, but failed to add the changes of a commit hook: %s", output)
+	}
+	return nil
+}
+
+// runGit runs git with args in dir and returns its combined output, trimmed.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmdCombinedOutput(cmd)
+	return strings.TrimSpace(string(output)), err
+}
+
+// statusEntries returns the uncommitted changes of the repository at dir, as the
+// two-letter "git status --porcelain" state of each path.
+func statusEntries(dir string) (map[string]string, error) {
+	cmd := exec.Command("git", "status", "--porcelain", "-z")
+	cmd.Dir = dir
+	output, err := cmdOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check status: %w", err)
+	}
+	entries := make(map[string]string)
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i < len(fields); i++ {
+		entry := fields[i]
+		if len(entry) < 4 {
+			continue
+		}
+		entries[entry[3:]] = entry[:2]
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++ // the original path of a rename or copy follows
+		}
+	}
+	return entries, nil
+}
+
+// revExists reports whether rev resolves to a commit.
+func revExists(dir, rev string) bool {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", rev)
+	cmd.Dir = dir
+	return runCmd(cmd) == nil
+}
+
+// HeadCommit returns the commit HEAD points to, or "" when the repository has no
+// commits yet (or dir is not a repository).
+func HeadCommit(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", "HEAD")
+	cmd.Dir = dir
+	output, err := cmdOutput(cmd)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// PostSyncHook runs hook (program and arguments) in dir with env ("KEY=value") added
+// to the environment, when HEAD has moved from before, i.e. the sync pulled or
+// committed something. Nothing runs when hook is empty or HEAD is unchanged.
+// Reports whether the hook ran; a failed hook's output is part of the error.
+func PostSyncHook(dir, before string, hook []string, env ...string) (bool, error) {
+	if len(hook) == 0 || HeadCommit(dir) == before {
+		return false, nil
+	}
+
+	cmd := exec.Command(hook[0], hook[1:]...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	if output, err := cmdCombinedOutput(cmd); err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return true, fmt.Errorf("%w: %s", err, out)
+		}
+		return true, err
+	}
+	return true, nil
+}
+
+// Sync steps, reported to the progress callback of SyncContext as each one starts.
+const (
+	SyncPulling    = "Pulling"
+	SyncCommitting = "Committing"
+	SyncPushing    = "Pushing"
+)
+
 // Sync performs pull, commit (if needed), and push.
 // Returns an error if no remote 'origin' is configured.
 // If pull fails (e.g., remote branch doesn't exist), it skips pull and proceeds to push.
 func Sync(dir string) error {
+	return SyncAfterPull(dir, nil)
+}
+
+// SyncAfterPull is Sync with afterPull (if non-nil) run between the pull and the
+// commit, so files it rewrites after merging remote changes are committed and
+// pushed in the same sync. An error from afterPull stops the sync before committing.
+// When paths (relative to dir) are given, only changes to them are committed; other
+// changes in the repository, staged or not, are left alone.
+func SyncAfterPull(dir string, afterPull func() error, paths ...string) error {
+	return SyncContext(context.Background(), dir, nil, afterPull, paths...)
+}
+
+// SyncContext is SyncAfterPull with progress and cancellation. progress (if non-nil)
+// is called with SyncPulling, SyncCommitting (only when there is something to commit),
+// and SyncPushing as each step starts. Cancelling ctx kills a running pull or push
+// and stops the sync with an error wrapping ctx.Err(); a local commit that has
+// started is never interrupted, so the index is not left locked.
+func SyncContext(ctx context.Context, dir string, progress func(step string), afterPull func() error, paths ...string) error {
+	report := func(step string) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("sync cancelled: %w", err)
+		}
+		if progress != nil {
+			progress(step)
+		}
+		return nil
+	}
+
 	// Check if remote exists
 	if !HasRemote(dir, "origin") {
 		return fmt.Errorf("no remote 'origin' configured. Use 'ttt remote <url>' first")
@@ -61,9 +465,15 @@ func Sync(dir string) error {
 	}
 
 	// Pull from remote (skip if fails, e.g., remote branch doesn't exist yet)
-	cmd := exec.Command("git", "pull", "origin", branch)
+	if err := report(SyncPulling); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "git", "pull", "origin", branch)
 	cmd.Dir = dir
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := cmdCombinedOutput(cmd); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("sync cancelled: %w", ctx.Err())
+		}
 		outputStr := string(output)
 		// Check for merge conflict - this is a real error
 		if strings.Contains(outputStr, "CONFLICT") {
@@ -73,37 +483,167 @@ func Sync(dir string) error {
 		// This handles the case of first sync when remote branch doesn't exist
 	}
 
+	if afterPull != nil {
+		if err := afterPull(); err != nil {
+			return err
+		}
+	}
+
 	// Check for uncommitted changes
-	cmd = exec.Command("git", "status", "--porcelain")
-	cmd.Dir = dir
-	output, err := cmd.Output()
+	dirty, err := IsDirty(dir, paths...)
 	if err != nil {
-		return fmt.Errorf("failed to check status: %w", err)
+		return err
 	}
 
 	// If there are changes, commit them
-	if len(strings.TrimSpace(string(output))) > 0 {
-		// Stage all changes
-		cmd = exec.Command("git", "add", "-A")
-		cmd.Dir = dir
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to stage changes: %w", err)
+	if dirty {
+		if err := report(SyncCommitting); err != nil {
+			return err
 		}
 
-		// Commit
-		cmd = exec.Command("git", "commit", "-m", "Sync changes")
-		cmd.Dir = dir
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to commit: %w", err)
+		if err := CommitAll(dir, Message(ActionSync, time.Time{}), paths...); err != nil {
+			return err
 		}
 	}
 
 	// Push to remote
-	cmd = exec.Command("git", "push", "-u", "origin", branch)
+	if err := report(SyncPushing); err != nil {
+		return err
+	}
+	return push(ctx, dir, branch)
+}
+
+// ErrNonFastForward is returned by a sync whose push the remote rejected because the
+// histories have diverged, e.g. after the remote branch was rebased or force-pushed.
+// Nothing was pushed; RebaseAndPush integrates the remote commits and pushes again.
+var ErrNonFastForward = errors.New("push rejected: the remote branch has diverged from yours (was it rebased or force-pushed?)")
+
+// IsNonFastForward reports whether a failed push, given its error and combined output,
+// was rejected because the remote has commits the local branch does not: the
+// "(non-fast-forward)" and "(fetch first)" rejections of git push.
+func IsNonFastForward(err error, output string) bool {
+	if err == nil {
+		return false
+	}
+	return strings.Contains(output, "(non-fast-forward)") ||
+		strings.Contains(output, "(fetch first)") ||
+		strings.Contains(output, "Updates were rejected because the tip of your current branch is behind")
+}
+
+// push pushes branch to origin, never with --force. A rejection because the histories
+// diverged is returned as ErrNonFastForward.
+func push(ctx context.Context, dir, branch string) error {
+	cmd := exec.CommandContext(ctx, "git", "push", "-u", "origin", branch)
 	cmd.Dir = dir
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := cmdCombinedOutput(cmd); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("sync cancelled: %w", ctx.Err())
+		}
+		if IsNonFastForward(err, string(output)) {
+			return fmt.Errorf("%w; nothing was pushed", ErrNonFastForward)
+		}
 		return fmt.Errorf("push failed: %s", output)
 	}
-
 	return nil
 }
+
+// RebaseAndPush recovers from ErrNonFastForward: the local commits are rebased onto
+// the remote branch (git pull --rebase, stashing other changes meanwhile) and pushed
+// again. progress (if non-nil) is called with SyncPulling and SyncPushing. A rebase
+// that stops on a conflict is aborted, leaving the branch as it was, and the error
+// explains how to finish by hand (see RebaseHint). The push is never forced.
+func RebaseAndPush(ctx context.Context, dir string, progress func(step string)) error {
+	branch, err := GetCurrentBranch(dir)
+	if err != nil {
+		return err
+	}
+
+	if progress != nil {
+		progress(SyncPulling)
+	}
+	cmd := exec.CommandContext(ctx, "git", "pull", "--rebase", "--autostash", "origin", branch)
+	cmd.Dir = dir
+	if output, err := cmdCombinedOutput(cmd); err != nil {
+		abort := exec.Command("git", "rebase", "--abort")
+		abort.Dir = dir
+		_ = runCmd(abort) // fails harmlessly when no rebase is in progress
+		if ctx.Err() != nil {
+			return fmt.Errorf("sync cancelled: %w", ctx.Err())
+		}
+		return fmt.Errorf("rebase failed, nothing was pushed; %s:\n%s", RebaseHint(dir), output)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("sync cancelled: %w", err)
+	}
+	if progress != nil {
+		progress(SyncPushing)
+	}
+	return push(ctx, dir, branch)
+}
+
+// ErrOffline is returned by FastForwardPull when the remote could not be reached in
+// time, and ErrRemoteAhead when the remote branch has commits that cannot be
+// fast-forwarded onto the local one. Both leave the working directory as it was.
+var (
+	ErrOffline     = errors.New("remote not reachable")
+	ErrRemoteAhead = errors.New("remote branch has diverged, sync to merge it")
+)
+
+// PullBeforeEditTimeout is how long git.pull_before_edit waits for FastForwardPull
+// before editing goes ahead offline.
+const PullBeforeEditTimeout = 3 * time.Second
+
+// FastForwardPull brings the current branch up to date with origin before the task
+// file is edited (git pull --ff-only), giving up after timeout. It never merges or
+// rebases: a remote that diverged returns ErrRemoteAhead, and a remote that cannot be
+// reached, or does not answer within timeout, returns ErrOffline. A remote without
+// the branch yet has nothing to pull and is not an error. git never prompts for
+// credentials here, so a remote that needs them counts as unreachable.
+func FastForwardPull(dir string, timeout time.Duration) error {
+	if !HasRemote(dir, "origin") {
+		return fmt.Errorf("no remote 'origin' configured. Use 'ttt remote <url>' first")
+	}
+	branch, err := GetCurrentBranch(dir)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "pull", "--ff-only", "origin", branch)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	// Killing git does not end a transport it started (ssh, a helper) that still holds
+	// its output; WaitDelay stops waiting for them
+	cmd.WaitDelay = 100 * time.Millisecond
+	output, err := cmdCombinedOutput(cmd)
+	if err == nil {
+		return nil
+	}
+	out := string(output)
+	switch {
+	case ctx.Err() != nil:
+		return fmt.Errorf("%w: no answer within %s", ErrOffline, timeout)
+	case strings.Contains(out, "couldn't find remote ref"):
+		return nil
+	case strings.Contains(out, "Not possible to fast-forward"), strings.Contains(out, "diverging branches"):
+		return ErrRemoteAhead
+	case strings.Contains(out, "Could not read from remote repository"),
+		strings.Contains(out, "unable to access"),
+		strings.Contains(out, "Could not resolve host"):
+		return fmt.Errorf("%w: %s", ErrOffline, strings.TrimSpace(out))
+	}
+	return fmt.Errorf("pull failed: %s", strings.TrimSpace(out))
+}
+
+// RebaseHint tells how to integrate diverged remote commits by hand, for errors after
+// ErrNonFastForward: "run 'git pull --rebase origin main' in <dir>, resolve any
+// conflicts, then sync again".
+func RebaseHint(dir string) string {
+	branch, err := GetCurrentBranch(dir)
+	if err != nil {
+		branch = "<branch>"
+	}
+	return fmt.Sprintf("run 'git pull --rebase origin %s' in %s, resolve any conflicts, then sync again", branch, dir)
+}
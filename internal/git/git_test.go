@@ -1,11 +1,16 @@
 package git
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // setupTestRepo creates a temporary git repository for testing.
@@ -142,6 +147,33 @@ func TestHasRemote(t *testing.T) {
 	}
 }
 
+// TestSetLogger verifies that every git command is logged with its duration, and its
+// error when it fails, once a logger is set.
+func TestSetLogger(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	var buf bytes.Buffer
+	SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer SetLogger(slog.New(slog.DiscardHandler))
+
+	HasRemote(dir, "origin")
+	if _, err := GetCurrentBranch(dir); err != nil {
+		t.Fatalf("GetCurrentBranch() error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2:\n%s", len(lines), buf.String())
+	}
+	if want := `msg=run command="git remote get-url origin" dir=` + dir + ` duration=`; !strings.Contains(lines[0], want) || !strings.Contains(lines[0], " err=") {
+		t.Errorf("line 0 = %q, want %q with an error", lines[0], want)
+	}
+	if want := `msg=run command="git rev-parse --abbrev-ref HEAD"`; !strings.Contains(lines[1], want) || strings.Contains(lines[1], " err=") {
+		t.Errorf("line 1 = %q, want %q without an error", lines[1], want)
+	}
+}
+
 // TestGetCurrentBranch verifies that GetCurrentBranch() returns the current branch name.
 // Spec: docs/specification.md "手動同期（v0.3.0）" section - sync uses current branch
 func TestGetCurrentBranch(t *testing.T) {
@@ -207,3 +239,888 @@ func TestSyncPullFailureSkipsToPush(t *testing.T) {
 		t.Errorf("Sync() should succeed on first sync, got error: %v", err)
 	}
 }
+
+// TestSyncAfterPull verifies that files changed by the afterPull hook are committed
+// and pushed, and that a hook error stops the sync.
+func TestSyncAfterPull(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	remoteDir := t.TempDir()
+	cmd := exec.Command("git", "init", "--bare")
+	cmd.Dir = remoteDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to init bare repo: %v", err)
+	}
+	if err := SetRemote(dir, remoteDir); err != nil {
+		t.Fatalf("SetRemote() error: %v", err)
+	}
+
+	err := SyncAfterPull(dir, func() error {
+		return os.WriteFile(filepath.Join(dir, "tasks.md"), []byte("- [x] Task @done(2026-01-15)\n"), 0644)
+	})
+	if err != nil {
+		t.Fatalf("SyncAfterPull() error: %v", err)
+	}
+	if dirty, _ := IsDirty(dir); dirty {
+		t.Error("file written by afterPull should be committed")
+	}
+	if ahead, _, _ := AheadBehind(dir); ahead != 0 {
+		t.Errorf("ahead = %d after sync, want 0", ahead)
+	}
+
+	if err := SyncAfterPull(dir, func() error { return errors.New("boom") }); err == nil || err.Error() != "boom" {
+		t.Errorf("SyncAfterPull() error = %v, want the hook's error", err)
+	}
+}
+
+// TestSyncAfterPullPaths verifies that a sync limited to paths commits only changes
+// under them and leaves other changes, even staged ones, uncommitted.
+func TestSyncAfterPullPaths(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	remoteDir := t.TempDir()
+	cmd := exec.Command("git", "init", "--bare")
+	cmd.Dir = remoteDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to init bare repo: %v", err)
+	}
+	if err := SetRemote(dir, remoteDir); err != nil {
+		t.Fatalf("SetRemote() error: %v", err)
+	}
+
+	sub := filepath.Join(dir, ".ttt")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatalf("Mkdir() error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "tasks.md"), []byte("- [ ] Task\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test.txt"), []byte("staged elsewhere"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	cmd = exec.Command("git", "add", "test.txt")
+	cmd.Dir = dir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("git add error: %v", err)
+	}
+
+	if err := SyncAfterPull(sub, nil, "."); err != nil {
+		t.Fatalf("SyncAfterPull() error: %v", err)
+	}
+	if dirty, _ := IsDirty(sub, "."); dirty {
+		t.Error("changes under the path should be committed")
+	}
+
+	cmd = exec.Command("git", "status", "--porcelain")
+	cmd.Dir = dir
+	output, _ := cmd.Output()
+	if strings.TrimSpace(string(output)) != "M  test.txt" {
+		t.Errorf("status outside the path = %q, want the staged change kept", output)
+	}
+}
+
+// TestDiff verifies that Diff() returns unified diff text for uncommitted changes
+// and an empty string when the working tree is clean.
+func TestDiff(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	diff, err := Diff(dir)
+	if err != nil {
+		t.Fatalf("Diff() error: %v", err)
+	}
+	if diff != "" {
+		t.Errorf("Diff() on clean tree = %q, want empty", diff)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "test.txt"), []byte("changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	diff, err = Diff(dir)
+	if err != nil {
+		t.Fatalf("Diff() error: %v", err)
+	}
+	if !strings.Contains(diff, "-test") {
+		t.Errorf("Diff() should contain removed line \"-test\", got: %q", diff)
+	}
+	if !strings.Contains(diff, "+changed") {
+		t.Errorf("Diff() should contain added line \"+changed\", got: %q", diff)
+	}
+}
+
+// TestDiffNotARepository verifies that Diff() returns an error outside a git repository.
+func TestDiffNotARepository(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+
+	if _, err := Diff(dir); err == nil {
+		t.Error("Diff() should return error outside a git repository")
+	}
+}
+
+// TestDiffStat verifies line counts against HEAD for an unchanged, edited, and truncated file.
+func TestDiffStat(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(path, []byte("a\nb\nc\nd\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := CommitAll(dir, "Four lines", "test.txt"); err != nil {
+		t.Fatalf("CommitAll() error: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		content     string
+		wantAdded   int
+		wantDeleted int
+	}{
+		{"unchanged", "a\nb\nc\nd\n", 0, 0},
+		{"line added", "a\nb\nc\nd\ne\n", 1, 0},
+		{"lines deleted", "a\n", 0, 3},
+		{"line replaced", "a\nb\nc\nx\n", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+			added, deleted, err := DiffStat(dir, "test.txt")
+			if err != nil {
+				t.Fatalf("DiffStat() error: %v", err)
+			}
+			if added != tt.wantAdded || deleted != tt.wantDeleted {
+				t.Errorf("DiffStat() = (%d, %d), want (%d, %d)", added, deleted, tt.wantAdded, tt.wantDeleted)
+			}
+		})
+	}
+
+	diff, err := DiffFile(dir, "test.txt")
+	if err != nil {
+		t.Fatalf("DiffFile() error: %v", err)
+	}
+	if !strings.Contains(diff, "-d") || !strings.Contains(diff, "+x") {
+		t.Errorf("DiffFile() should show the replaced line, got: %q", diff)
+	}
+}
+
+// TestDiffStatNoCommits verifies that a repository without commits reports no changes.
+func TestDiffStatNoCommits(t *testing.T) {
+	dir := t.TempDir()
+	cmd := exec.Command("git", "init")
+	cmd.Dir = dir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to init git repo: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tasks.md"), []byte("- [ ] Task\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	added, deleted, err := DiffStat(dir, "tasks.md")
+	if err != nil || added != 0 || deleted != 0 {
+		t.Errorf("DiffStat() = (%d, %d, %v), want (0, 0, nil)", added, deleted, err)
+	}
+}
+
+// TestIsDirty verifies that IsDirty() detects modified and untracked files.
+func TestIsDirty(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	dirty, err := IsDirty(dir)
+	if err != nil {
+		t.Fatalf("IsDirty() error: %v", err)
+	}
+	if dirty {
+		t.Error("IsDirty() on clean tree = true, want false")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "new.md"), []byte("- [ ] Task\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if dirty, _ := IsDirty(dir); !dirty {
+		t.Error("IsDirty() with untracked file = false, want true")
+	}
+}
+
+// TestIsDirtyPaths verifies that IsDirty looks only at the given paths.
+func TestIsDirtyPaths(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	sub := filepath.Join(dir, ".ttt")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatalf("Mkdir() error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dotfile"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if dirty, _ := IsDirty(sub, "."); dirty {
+		t.Error("IsDirty(sub, \".\") with a change outside sub = true, want false")
+	}
+
+	if err := os.WriteFile(filepath.Join(sub, "tasks.md"), []byte("- [ ] Task\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if dirty, _ := IsDirty(sub, "."); !dirty {
+		t.Error("IsDirty(sub, \".\") with a change in sub = false, want true")
+	}
+}
+
+// TestStatus verifies the remote, uncommitted, and unpushed state reported by Status.
+func TestStatus(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	status, err := Status(dir)
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+	if status != (RepoStatus{}) {
+		t.Errorf("Status() without remote on a clean tree = %+v, want zero", status)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "tasks.md"), []byte("- [ ] Task\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	remoteDir := t.TempDir()
+	cmd := exec.Command("git", "init", "--bare")
+	cmd.Dir = remoteDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to init bare repo: %v", err)
+	}
+	if err := SetRemote(dir, remoteDir); err != nil {
+		t.Fatalf("SetRemote() error: %v", err)
+	}
+
+	status, err = Status(dir)
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+	if !status.HasRemote || !status.Dirty || status.Ahead != 1 {
+		t.Errorf("Status() = %+v, want remote, dirty, 1 ahead", status)
+	}
+
+	if _, err := Status(t.TempDir()); err == nil {
+		t.Error("Status() outside a repository should return error")
+	}
+}
+
+// TestToplevel verifies that Toplevel finds the enclosing repository of a subdirectory
+// and fails outside any repository.
+func TestToplevel(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	sub := filepath.Join(dir, "dotfiles", ".ttt")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	top, err := Toplevel(sub)
+	if err != nil {
+		t.Fatalf("Toplevel() error: %v", err)
+	}
+	want, _ := filepath.EvalSymlinks(dir)
+	if got, _ := filepath.EvalSymlinks(top); got != want {
+		t.Errorf("Toplevel() = %q, want %q", top, dir)
+	}
+
+	if _, err := Toplevel(t.TempDir()); err == nil {
+		t.Error("Toplevel() outside a repository should return error")
+	}
+}
+
+// TestAheadBehind verifies commit counts before the first push, after pushing,
+// and after committing locally.
+func TestAheadBehind(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	remoteDir := t.TempDir()
+	cmd := exec.Command("git", "init", "--bare")
+	cmd.Dir = remoteDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to init bare repo: %v", err)
+	}
+	if err := SetRemote(dir, remoteDir); err != nil {
+		t.Fatalf("SetRemote() error: %v", err)
+	}
+
+	// Nothing pushed yet: the initial commit is ahead
+	ahead, behind, err := AheadBehind(dir)
+	if err != nil {
+		t.Fatalf("AheadBehind() error: %v", err)
+	}
+	if ahead != 1 || behind != 0 {
+		t.Errorf("AheadBehind() before push = %d, %d; want 1, 0", ahead, behind)
+	}
+
+	if err := Sync(dir); err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if ahead, behind, _ := AheadBehind(dir); ahead != 0 || behind != 0 {
+		t.Errorf("AheadBehind() after push = %d, %d; want 0, 0", ahead, behind)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "test.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-m", "local"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("git %v error: %v", args, err)
+		}
+	}
+	if ahead, behind, _ := AheadBehind(dir); ahead != 1 || behind != 0 {
+		t.Errorf("AheadBehind() after local commit = %d, %d; want 1, 0", ahead, behind)
+	}
+}
+
+// TestCommitAll verifies that CommitAll() records all given files in one commit,
+// including new ones, and leaves other changes uncommitted.
+func TestCommitAll(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	for name, content := range map[string]string{
+		"tasks.md":   "- [ ] Open\n",
+		"archive.md": "## 2026-01-20\n- [x] Done @done(2026-01-20)\n",
+		"other.txt":  "unrelated\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	if err := CommitAll(dir, "Archive 1 task(s)", "tasks.md", "archive.md"); err != nil {
+		t.Fatalf("CommitAll() error: %v", err)
+	}
+
+	cmd := exec.Command("git", "show", "--name-only", "--format=%s", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git show error: %v", err)
+	}
+	got := strings.Fields(string(output))
+	expected := []string{"Archive", "1", "task(s)", "archive.md", "tasks.md"}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("HEAD = %q, want %q", got, expected)
+	}
+
+	if dirty, _ := IsDirty(dir); !dirty {
+		t.Error("other.txt should remain uncommitted")
+	}
+
+	// No changes: no new commit
+	if err := CommitAll(dir, "Nothing", "tasks.md", "archive.md"); err != nil {
+		t.Fatalf("CommitAll() without changes error: %v", err)
+	}
+	cmd = exec.Command("git", "log", "-1", "--format=%s")
+	cmd.Dir = dir
+	output, _ = cmd.Output()
+	if strings.TrimSpace(string(output)) != "Archive 1 task(s)" {
+		t.Errorf("CommitAll() without changes created commit %q", output)
+	}
+}
+
+// newRepoWithoutUser creates a repository in a temporary directory while git sees no
+// user.name or user.email anywhere, and refuses to guess them for commits.
+func newRepoWithoutUser(t *testing.T) string {
+	t.Helper()
+	global := filepath.Join(t.TempDir(), "gitconfig")
+	if err := os.WriteFile(global, []byte("[user]\n\tuseConfigOnly = true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write global config: %v", err)
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", global)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	dir := t.TempDir()
+	if err := Init(dir); err != nil {
+		t.Fatalf("Init() error: %v", err)
+	}
+	return dir
+}
+
+// TestSetUser verifies that SetUser fills in only the identity the repository lacks,
+// and that CheckUser reports what is missing.
+func TestSetUser(t *testing.T) {
+	dir := newRepoWithoutUser(t)
+	get := func(key string) string {
+		t.Helper()
+		value, _ := runGit(dir, "config", "--local", "--get", key)
+		return value
+	}
+
+	err := CheckUser(dir)
+	if err == nil || !strings.Contains(err.Error(), "user.name and user.email") {
+		t.Errorf("CheckUser() without a user = %v, want both keys missing", err)
+	}
+
+	if err := SetUser(dir, "", ""); err != nil {
+		t.Fatalf("SetUser() without values error: %v", err)
+	}
+	if get("user.name") != "" || get("user.email") != "" {
+		t.Error("SetUser() without values set the repository config")
+	}
+
+	if _, err := runGit(dir, "config", "user.name", "Existing"); err != nil {
+		t.Fatalf("git config error: %v", err)
+	}
+	if err := CheckUser(dir); err == nil || !strings.Contains(err.Error(), "git user.email not set") {
+		t.Errorf("CheckUser() without user.email = %v, want user.email missing", err)
+	}
+
+	if err := SetUser(dir, "Task Bot", "bot@example.com"); err != nil {
+		t.Fatalf("SetUser() error: %v", err)
+	}
+	if get("user.name") != "Existing" {
+		t.Errorf("user.name = %q, want the existing value kept", get("user.name"))
+	}
+	if get("user.email") != "bot@example.com" {
+		t.Errorf("user.email = %q, want %q", get("user.email"), "bot@example.com")
+	}
+	if err := CheckUser(dir); err != nil {
+		t.Errorf("CheckUser() with a user error: %v", err)
+	}
+}
+
+// TestCommitAllWithoutUser verifies that a commit without a git user explains how to
+// set one, and works once SetUser has set it.
+func TestCommitAllWithoutUser(t *testing.T) {
+	dir := newRepoWithoutUser(t)
+	if err := os.WriteFile(filepath.Join(dir, "tasks.md"), []byte("- [ ] Task\n"), 0644); err != nil {
+		t.Fatalf("Failed to write tasks.md: %v", err)
+	}
+
+	err := CommitAll(dir, "Add task")
+	if err == nil || !strings.Contains(err.Error(), "git config --global user.name") {
+		t.Fatalf("CommitAll() without a user = %v, want how to set one", err)
+	}
+
+	if err := SetUser(dir, "Task Bot", "bot@example.com"); err != nil {
+		t.Fatalf("SetUser() error: %v", err)
+	}
+	if err := CommitAll(dir, "Add task"); err != nil {
+		t.Errorf("CommitAll() with a user error: %v", err)
+	}
+}
+
+// TestCommitAllHooks verifies that CommitAll runs the pre-commit hook from
+// core.hooksPath, amends the files the hook changed into the commit without touching
+// files that were already modified, and reports the output of a failing hook.
+func TestCommitAllHooks(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	git := func(args ...string) string {
+		t.Helper()
+		output, err := runGit(dir, args...)
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+		return output
+	}
+	writeFile := func(name, content string, perm os.FileMode) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), perm); err != nil {
+			t.Fatalf("WriteFile(%s) error: %v", name, err)
+		}
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "hooks"), 0755); err != nil {
+		t.Fatalf("Mkdir() error: %v", err)
+	}
+	writeFile("hooks/pre-commit", "#!/bin/sh\necho \"tasks: $(grep -c . tasks.md)\" > badge.txt\n", 0755)
+	writeFile("other.txt", "unrelated\n", 0644)
+	git("add", "hooks", "other.txt")
+	git("commit", "-m", "Add hook", "--no-verify")
+	git("config", "core.hooksPath", "hooks")
+
+	writeFile("other.txt", "edited by hand\n", 0644)
+	writeFile("tasks.md", "- [ ] A\n- [ ] B\n", 0644)
+	if err := CommitAll(dir, "Add tasks", "tasks.md"); err != nil {
+		t.Fatalf("CommitAll() error: %v", err)
+	}
+
+	if got := git("log", "-1", "--format=%s"); got != "Add tasks" {
+		t.Errorf("HEAD subject = %q, want the commit amended in place", got)
+	}
+	if got := git("show", "--name-only", "--format=", "HEAD"); got != "badge.txt\ntasks.md" {
+		t.Errorf("HEAD files = %q, want badge.txt and tasks.md", got)
+	}
+	if got := git("show", "HEAD:badge.txt"); got != "tasks: 2" {
+		t.Errorf("committed badge.txt = %q, want the hook's output", got)
+	}
+	if got := git("status", "--porcelain"); got != "M other.txt" {
+		t.Errorf("status = %q, want only other.txt left modified", got)
+	}
+
+	writeFile("hooks/pre-commit", "#!/bin/sh\necho 'badge generator missing' >&2\nexit 1\n", 0755)
+	writeFile("tasks.md", "- [ ] A\n", 0644)
+	err := CommitAll(dir, "Remove task", "tasks.md")
+	if err == nil || !strings.Contains(err.Error(), "badge generator missing") {
+		t.Errorf("CommitAll() with a failing hook error = %v, want the hook's output", err)
+	}
+	if got := git("log", "-1", "--format=%s"); got != "Add tasks" {
+		t.Errorf("HEAD subject after a failing hook = %q, want no new commit", got)
+	}
+}
+
+// TestPostSyncHook verifies that the hook runs in dir with the extra environment only
+// when HEAD moved, and that its output is part of the error when it fails.
+func TestPostSyncHook(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	before := HeadCommit(dir)
+	if before == "" {
+		t.Fatal("HeadCommit() is empty after the initial commit")
+	}
+	hook := []string{"sh", "-c", `echo "$TTT_TASKS_FILE" > hook.out`}
+
+	ran, err := PostSyncHook(dir, before, hook, "TTT_TASKS_FILE=/tmp/tasks.md")
+	if ran || err != nil {
+		t.Errorf("PostSyncHook() with HEAD unchanged = (%v, %v), want (false, nil)", ran, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "tasks.md"), []byte("- [ ] A\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if err := CommitAll(dir, "Add task", "tasks.md"); err != nil {
+		t.Fatalf("CommitAll() error: %v", err)
+	}
+
+	if ran, err := PostSyncHook(dir, before, nil); ran || err != nil {
+		t.Errorf("PostSyncHook() without a hook = (%v, %v), want (false, nil)", ran, err)
+	}
+
+	ran, err = PostSyncHook(dir, before, hook, "TTT_TASKS_FILE=/tmp/tasks.md")
+	if !ran || err != nil {
+		t.Fatalf("PostSyncHook() after a commit = (%v, %v), want (true, nil)", ran, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "hook.out")); string(got) != "/tmp/tasks.md\n" {
+		t.Errorf("hook output = %q, want the TTT_TASKS_FILE path", got)
+	}
+
+	failing := []string{"sh", "-c", "echo boom >&2; exit 3"}
+	ran, err = PostSyncHook(dir, before, failing)
+	if !ran || err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("failing hook = (%v, %v), want an error with its output", ran, err)
+	}
+}
+
+// TestSyncContext verifies that each step is reported as it starts, and that a
+// cancelled context stops the sync before running git.
+func TestSyncContext(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	remoteDir := t.TempDir()
+	cmd := exec.Command("git", "init", "--bare")
+	cmd.Dir = remoteDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to init bare repo: %v", err)
+	}
+	if err := SetRemote(dir, remoteDir); err != nil {
+		t.Fatalf("SetRemote() error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tasks.md"), []byte("- [ ] A\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	var steps []string
+	progress := func(step string) { steps = append(steps, step) }
+	if err := SyncContext(context.Background(), dir, progress, nil); err != nil {
+		t.Fatalf("SyncContext() error: %v", err)
+	}
+	if got, want := strings.Join(steps, ","), "Pulling,Committing,Pushing"; got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+
+	// Nothing to commit: no commit step
+	steps = nil
+	if err := SyncContext(context.Background(), dir, progress, nil); err != nil {
+		t.Fatalf("second SyncContext() error: %v", err)
+	}
+	if got, want := strings.Join(steps, ","), "Pulling,Pushing"; got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	steps = nil
+	err := SyncContext(ctx, dir, progress, nil)
+	if !errors.Is(err, context.Canceled) || len(steps) != 0 {
+		t.Errorf("cancelled SyncContext() = %v with steps %v, want context.Canceled and none", err, steps)
+	}
+}
+
+// TestIsNonFastForward verifies which push failures count as diverged histories.
+func TestIsNonFastForward(t *testing.T) {
+	failed := errors.New("exit status 1")
+	tests := []struct {
+		name   string
+		err    error
+		output string
+		want   bool
+	}{
+		{"diverged", failed, " ! [rejected]        main -> main (non-fast-forward)\n", true},
+		{"remote ahead", failed, " ! [rejected]        main -> main (fetch first)\n", true},
+		{"behind hint", failed, "hint: Updates were rejected because the tip of your current branch is behind\n", true},
+		{"no error", nil, " ! [rejected]        main -> main (non-fast-forward)\n", false},
+		{"auth failure", failed, "fatal: Authentication failed for 'https://example.com/repo.git/'\n", false},
+		{"hook declined", failed, " ! [remote rejected] main -> main (pre-receive hook declined)\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsNonFastForward(tt.err, tt.output); got != tt.want {
+				t.Errorf("IsNonFastForward() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSyncDiverged creates divergent histories against a bare remote: a clone rewrites
+// a pushed commit and force-pushes. Sync must then fail with ErrNonFastForward without
+// touching the remote, and RebaseAndPush must integrate the remote commits and push
+// without forcing. A rebase that conflicts is aborted.
+func TestSyncDiverged(t *testing.T) {
+	run := func(t *testing.T, dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v error: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	write := func(t *testing.T, path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+
+	// setup returns a local repository with a synced tasks.md, its bare remote, and a
+	// second clone whose last commit was rewritten and force-pushed.
+	setup := func(t *testing.T) (dir, remoteDir, other string) {
+		dir, cleanup := setupTestRepo(t)
+		t.Cleanup(cleanup)
+		remoteDir = t.TempDir()
+		run(t, remoteDir, "init", "--bare")
+		if err := SetRemote(dir, remoteDir); err != nil {
+			t.Fatalf("SetRemote() error: %v", err)
+		}
+		// Make the pull fail on divergence whatever the user's global pull settings are
+		run(t, dir, "config", "pull.ff", "only")
+		write(t, filepath.Join(dir, "tasks.md"), "- [ ] A\n")
+		if err := Sync(dir); err != nil {
+			t.Fatalf("Sync() error: %v", err)
+		}
+
+		branch, _ := GetCurrentBranch(dir)
+		other = filepath.Join(t.TempDir(), "other")
+		run(t, filepath.Dir(other), "clone", "-q", "-b", branch, remoteDir, other)
+		run(t, other, "config", "user.email", "other@example.com")
+		run(t, other, "config", "user.name", "Other")
+		run(t, other, "commit", "-q", "--amend", "-m", "Rewritten")
+		run(t, other, "push", "-q", "--force", "origin", branch)
+		return dir, remoteDir, other
+	}
+
+	t.Run("rebase and push", func(t *testing.T) {
+		dir, remoteDir, other := setup(t)
+		write(t, filepath.Join(other, "remote.txt"), "from the other clone\n")
+		run(t, other, "add", "remote.txt")
+		run(t, other, "commit", "-q", "-m", "Remote change")
+		run(t, other, "push", "-q", "origin", "HEAD")
+		remoteHead := run(t, other, "rev-parse", "HEAD")
+
+		write(t, filepath.Join(dir, "tasks.md"), "- [ ] A\n- [ ] B\n")
+		err := Sync(dir)
+		if !errors.Is(err, ErrNonFastForward) {
+			t.Fatalf("Sync() error = %v, want ErrNonFastForward", err)
+		}
+		if got := run(t, remoteDir, "rev-parse", "HEAD"); got != remoteHead {
+			t.Error("a rejected sync changed the remote")
+		}
+
+		var steps []string
+		if err := RebaseAndPush(context.Background(), dir, func(step string) { steps = append(steps, step) }); err != nil {
+			t.Fatalf("RebaseAndPush() error: %v", err)
+		}
+		if got, want := strings.Join(steps, ","), "Pulling,Pushing"; got != want {
+			t.Errorf("steps = %s, want %s", got, want)
+		}
+		// The remote history is kept: its old head is an ancestor of the new one
+		run(t, remoteDir, "merge-base", "--is-ancestor", remoteHead, "HEAD")
+		if got := run(t, remoteDir, "rev-parse", "HEAD"); got != HeadCommit(dir) {
+			t.Errorf("remote HEAD = %s, want the local HEAD %s", got, HeadCommit(dir))
+		}
+		if _, err := os.Stat(filepath.Join(dir, "remote.txt")); err != nil {
+			t.Errorf("remote commit missing locally: %v", err)
+		}
+	})
+
+	t.Run("conflict is aborted", func(t *testing.T) {
+		dir, remoteDir, other := setup(t)
+		write(t, filepath.Join(other, "tasks.md"), "- [ ] A\n- [ ] Remote\n")
+		run(t, other, "commit", "-q", "-am", "Remote edit")
+		run(t, other, "push", "-q", "origin", "HEAD")
+		remoteHead := run(t, other, "rev-parse", "HEAD")
+
+		write(t, filepath.Join(dir, "tasks.md"), "- [ ] A\n- [ ] Local\n")
+		if err := Sync(dir); !errors.Is(err, ErrNonFastForward) {
+			t.Fatalf("Sync() error = %v, want ErrNonFastForward", err)
+		}
+		localHead := HeadCommit(dir)
+
+		err := RebaseAndPush(context.Background(), dir, nil)
+		if err == nil || !strings.Contains(err.Error(), "rebase failed") || !strings.Contains(err.Error(), "git pull --rebase origin") {
+			t.Fatalf("RebaseAndPush() error = %v, want a rebase failure with instructions", err)
+		}
+		if HeadCommit(dir) != localHead {
+			t.Error("an aborted rebase should leave the local branch as it was")
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git", "rebase-merge")); !os.IsNotExist(err) {
+			t.Error("the rebase should be aborted")
+		}
+		if got := run(t, remoteDir, "rev-parse", "HEAD"); got != remoteHead {
+			t.Error("a failed rebase changed the remote")
+		}
+	})
+}
+
+// TestFastForwardPull verifies the pull before editing against a bare remote: new
+// remote commits are fast-forwarded, diverged histories and unreachable or silent
+// remotes are reported without touching the local branch.
+func TestFastForwardPull(t *testing.T) {
+	run := func(t *testing.T, dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v error: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	write := func(t *testing.T, path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+
+	// setup returns a local repository synced with a bare remote, and a second clone
+	// of that remote.
+	setup := func(t *testing.T) (dir, remoteDir, other string) {
+		dir, cleanup := setupTestRepo(t)
+		t.Cleanup(cleanup)
+		remoteDir = t.TempDir()
+		run(t, remoteDir, "init", "--bare")
+		if err := SetRemote(dir, remoteDir); err != nil {
+			t.Fatalf("SetRemote() error: %v", err)
+		}
+		write(t, filepath.Join(dir, "tasks.md"), "- [ ] A\n")
+		if err := Sync(dir); err != nil {
+			t.Fatalf("Sync() error: %v", err)
+		}
+		branch, _ := GetCurrentBranch(dir)
+		other = filepath.Join(t.TempDir(), "other")
+		run(t, filepath.Dir(other), "clone", "-q", "-b", branch, remoteDir, other)
+		run(t, other, "config", "user.email", "other@example.com")
+		run(t, other, "config", "user.name", "Other")
+		return dir, remoteDir, other
+	}
+	pushFromOther := func(t *testing.T, other, content string) {
+		write(t, filepath.Join(other, "tasks.md"), content)
+		run(t, other, "commit", "-q", "-am", "Remote edit")
+		run(t, other, "push", "-q", "origin", "HEAD")
+	}
+
+	t.Run("up to date", func(t *testing.T) {
+		dir, _, _ := setup(t)
+		if err := FastForwardPull(dir, 5*time.Second); err != nil {
+			t.Errorf("FastForwardPull() error: %v", err)
+		}
+	})
+
+	t.Run("remote commits fast-forwarded", func(t *testing.T) {
+		dir, _, other := setup(t)
+		pushFromOther(t, other, "- [ ] A\n- [ ] Remote\n")
+		if err := FastForwardPull(dir, 5*time.Second); err != nil {
+			t.Fatalf("FastForwardPull() error: %v", err)
+		}
+		if got, _ := os.ReadFile(filepath.Join(dir, "tasks.md")); string(got) != "- [ ] A\n- [ ] Remote\n" {
+			t.Errorf("tasks.md = %q, want the remote edit", got)
+		}
+	})
+
+	t.Run("diverged", func(t *testing.T) {
+		dir, _, other := setup(t)
+		pushFromOther(t, other, "- [ ] A\n- [ ] Remote\n")
+		write(t, filepath.Join(dir, "notes.md"), "local\n")
+		run(t, dir, "add", "notes.md")
+		run(t, dir, "commit", "-q", "-m", "Local edit")
+		localHead := HeadCommit(dir)
+
+		if err := FastForwardPull(dir, 5*time.Second); !errors.Is(err, ErrRemoteAhead) {
+			t.Fatalf("FastForwardPull() error = %v, want ErrRemoteAhead", err)
+		}
+		if HeadCommit(dir) != localHead {
+			t.Error("a diverged pull should leave the local branch as it was")
+		}
+	})
+
+	t.Run("remote branch missing", func(t *testing.T) {
+		dir, _, _ := setup(t)
+		run(t, dir, "checkout", "-q", "-b", "unpushed")
+		if err := FastForwardPull(dir, 5*time.Second); err != nil {
+			t.Errorf("FastForwardPull() error: %v", err)
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		dir, _, _ := setup(t)
+		if err := SetRemote(dir, filepath.Join(t.TempDir(), "missing")); err != nil {
+			t.Fatalf("SetRemote() error: %v", err)
+		}
+		if err := FastForwardPull(dir, 5*time.Second); !errors.Is(err, ErrOffline) {
+			t.Errorf("FastForwardPull() error = %v, want ErrOffline", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		dir, _, _ := setup(t)
+		// A remote that never answers: the ext transport runs a command as the server
+		run(t, dir, "config", "protocol.ext.allow", "always")
+		if err := SetRemote(dir, "ext::sleep 10"); err != nil {
+			t.Fatalf("SetRemote() error: %v", err)
+		}
+		start := time.Now()
+		err := FastForwardPull(dir, 200*time.Millisecond)
+		if !errors.Is(err, ErrOffline) {
+			t.Errorf("FastForwardPull() error = %v, want ErrOffline", err)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("FastForwardPull() took %s, want it to give up after the timeout", elapsed)
+		}
+	})
+
+	t.Run("no remote", func(t *testing.T) {
+		dir, cleanup := setupTestRepo(t)
+		t.Cleanup(cleanup)
+		if err := FastForwardPull(dir, time.Second); err == nil {
+			t.Error("FastForwardPull() without a remote should fail")
+		}
+	})
+}
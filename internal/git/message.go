@@ -0,0 +1,115 @@
+package git
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Languages of the commit messages ttt generates (git.commit_language).
+const (
+	LanguageEnglish  = "en"
+	LanguageJapanese = "ja"
+)
+
+// MaxMessageLength is the most characters a generated commit message has (see Message).
+const MaxMessageLength = 72
+
+// Action is a change ttt commits with a generated message.
+type Action int
+
+const (
+	ActionAdd         Action = iota // a task added with ttt -t (arg: task text)
+	ActionArchive                   // an archive pass (arg: number of tasks)
+	ActionConsolidate               // ttt archive --consolidate
+	ActionDone                      // tasks completed with ttt done (arg: task text)
+	ActionMigrate                   // a file converted into tasks.md with ttt migrate (args: number of tasks, format)
+	ActionMoveFrom                  // a task moved in from a workspace (args: workspace, task text)
+	ActionMoveTo                    // a task moved out to a workspace (args: workspace, task text)
+	ActionReset                     // a section reset with ttt reset (args: number of tasks, heading)
+	ActionSync                      // local changes committed by a sync
+)
+
+// messages are the Sprintf formats of each Action, by language.
+var messages = map[string]map[Action]string{
+	LanguageEnglish: {
+		ActionAdd:         "Add task: %s",
+		ActionArchive:     "Archive %d task(s)",
+		ActionConsolidate: "Consolidate archive",
+		ActionDone:        "Complete task: %s",
+		ActionMigrate:     "Migrate %d task(s) from %s",
+		ActionMoveFrom:    "Move task from %s: %s",
+		ActionMoveTo:      "Move task to %s: %s",
+		ActionReset:       "Reset %d task(s): %s",
+		ActionSync:        "Sync changes",
+	},
+	LanguageJapanese: {
+		ActionAdd:         "タスク追加: %s",
+		ActionArchive:     "タスクを%d件アーカイブ",
+		ActionConsolidate: "アーカイブを統合",
+		ActionDone:        "タスク完了: %s",
+		ActionMigrate:     "%[2]sから%[1]d件のタスクを移行",
+		ActionMoveFrom:    "%sからタスクを移動: %s",
+		ActionMoveTo:      "%sへタスクを移動: %s",
+		ActionReset:       "%d件のタスクをリセット: %s",
+		ActionSync:        "変更を同期",
+	},
+}
+
+// messagePrefix and messageLanguage are the git.commit_prefix and git.commit_language settings.
+var (
+	messagePrefix   string
+	messageLanguage = LanguageEnglish
+)
+
+// SetMessageStyle makes Message put prefix (if not "") in front of every message and
+// word it in language, one of the Language constants; an unknown language falls back
+// to English. It is meant to be called once at startup.
+func SetMessageStyle(prefix, language string) {
+	messagePrefix = strings.TrimSpace(prefix)
+	messageLanguage = language
+}
+
+// Message returns the commit message for action with args filled in, in the language
+// and with the prefix set by SetMessageStyle, followed by the time now unless now is
+// zero: "📝 Add task: buy milk (2026-02-10 09:00)". The message is a single line of at
+// most MaxMessageLength characters: line breaks in args become spaces, and a message
+// that is too long has its last text argument (the task text) cut short with "…".
+func Message(action Action, now time.Time, args ...any) string {
+	formats, ok := messages[messageLanguage]
+	if !ok {
+		formats = messages[LanguageEnglish]
+	}
+	head := ""
+	if messagePrefix != "" {
+		head = messagePrefix + " "
+	}
+	tail := ""
+	if !now.IsZero() {
+		tail = " (" + now.Format("2006-01-02 15:04") + ")"
+	}
+
+	args = slices.Clone(args)
+	last := -1 // index of the last string argument
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			args[i] = strings.Join(strings.Fields(s), " ")
+			last = i
+		}
+	}
+	message := head + fmt.Sprintf(formats[action], args...) + tail
+	over := utf8.RuneCountInString(message) - MaxMessageLength
+	if over <= 0 {
+		return message
+	}
+	if last >= 0 {
+		text := []rune(args[last].(string))
+		if keep := len(text) - over - 1; keep > 0 {
+			args[last] = string(text[:keep]) + "…"
+			return head + fmt.Sprintf(formats[action], args...) + tail
+		}
+	}
+	return string([]rune(message)[:MaxMessageLength-1]) + "…"
+}
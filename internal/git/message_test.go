@@ -0,0 +1,99 @@
+package git
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// TestMessage verifies the message of each action in both languages, with and without
+// the prefix and the timestamp.
+func TestMessage(t *testing.T) {
+	now := time.Date(2026, 2, 10, 9, 5, 0, 0, time.UTC)
+	tests := []struct {
+		language string
+		prefix   string
+		action   Action
+		args     []any
+		want     string
+	}{
+		{LanguageEnglish, "", ActionAdd, []any{"buy milk"}, "Add task: buy milk (2026-02-10 09:05)"},
+		{LanguageEnglish, "", ActionArchive, []any{3}, "Archive 3 task(s) (2026-02-10 09:05)"},
+		{LanguageEnglish, "", ActionConsolidate, nil, "Consolidate archive (2026-02-10 09:05)"},
+		{LanguageEnglish, "", ActionDone, []any{"buy milk"}, "Complete task: buy milk (2026-02-10 09:05)"},
+		{LanguageEnglish, "", ActionReset, []any{3, "Packing"}, "Reset 3 task(s): Packing (2026-02-10 09:05)"},
+		{LanguageEnglish, "", ActionMigrate, []any{9, "ephe"}, "Migrate 9 task(s) from ephe (2026-02-10 09:05)"},
+		{LanguageEnglish, "", ActionMoveFrom, []any{"home", "call mom"}, "Move task from home: call mom (2026-02-10 09:05)"},
+		{LanguageEnglish, "", ActionMoveTo, []any{"work", "call mom"}, "Move task to work: call mom (2026-02-10 09:05)"},
+		{LanguageEnglish, "", ActionSync, nil, "Sync changes (2026-02-10 09:05)"},
+		{LanguageEnglish, "📝", ActionAdd, []any{"buy milk"}, "📝 Add task: buy milk (2026-02-10 09:05)"},
+		{LanguageJapanese, "", ActionAdd, []any{"牛乳を買う"}, "タスク追加: 牛乳を買う (2026-02-10 09:05)"},
+		{LanguageJapanese, "", ActionArchive, []any{3}, "タスクを3件アーカイブ (2026-02-10 09:05)"},
+		{LanguageJapanese, "", ActionConsolidate, nil, "アーカイブを統合 (2026-02-10 09:05)"},
+		{LanguageJapanese, "", ActionDone, []any{"牛乳を買う"}, "タスク完了: 牛乳を買う (2026-02-10 09:05)"},
+		{LanguageJapanese, "", ActionReset, []any{3, "持ち物"}, "3件のタスクをリセット: 持ち物 (2026-02-10 09:05)"},
+		{LanguageJapanese, "", ActionMigrate, []any{9, "ephe"}, "epheから9件のタスクを移行 (2026-02-10 09:05)"},
+		{LanguageJapanese, "", ActionMoveFrom, []any{"home", "電話"}, "homeからタスクを移動: 電話 (2026-02-10 09:05)"},
+		{LanguageJapanese, "", ActionMoveTo, []any{"work", "電話"}, "workへタスクを移動: 電話 (2026-02-10 09:05)"},
+		{LanguageJapanese, "", ActionSync, nil, "変更を同期 (2026-02-10 09:05)"},
+		{LanguageJapanese, "📝", ActionArchive, []any{1}, "📝 タスクを1件アーカイブ (2026-02-10 09:05)"},
+		{"fr", "", ActionSync, nil, "Sync changes (2026-02-10 09:05)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language+" "+tt.want, func(t *testing.T) {
+			SetMessageStyle(tt.prefix, tt.language)
+			defer SetMessageStyle("", LanguageEnglish)
+
+			if got := Message(tt.action, now, tt.args...); got != tt.want {
+				t.Errorf("Message() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := Message(ActionSync, time.Time{}); got != "Sync changes" {
+		t.Errorf("Message() without time = %q, want %q", got, "Sync changes")
+	}
+}
+
+// TestMessageLength verifies that messages stay a single line of at most
+// MaxMessageLength characters, eliding the task text first.
+func TestMessageLength(t *testing.T) {
+	now := time.Date(2026, 2, 10, 9, 5, 0, 0, time.UTC)
+	long := strings.Repeat("long task text ", 10)
+	tests := []struct {
+		language string
+		prefix   string
+		action   Action
+		args     []any
+		prefixOf string // what the message starts with
+	}{
+		{LanguageEnglish, "", ActionAdd, []any{long}, "Add task: long task text"},
+		{LanguageEnglish, "📝", ActionMoveTo, []any{"work", long}, "📝 Move task to work: long"},
+		{LanguageJapanese, "", ActionAdd, []any{strings.Repeat("長いタスク", 20)}, "タスク追加: 長いタスク"},
+		{LanguageEnglish, "", ActionAdd, []any{"multi\nline\r\ntask"}, "Add task: multi line task ("},
+		{LanguageEnglish, strings.Repeat("#", 80), ActionSync, nil, "####"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefixOf, func(t *testing.T) {
+			SetMessageStyle(tt.prefix, tt.language)
+			defer SetMessageStyle("", LanguageEnglish)
+
+			got := Message(tt.action, now, tt.args...)
+			if n := utf8.RuneCountInString(got); n > MaxMessageLength {
+				t.Errorf("Message() = %q has %d characters, want at most %d", got, n, MaxMessageLength)
+			}
+			if strings.ContainsAny(got, "\r\n") {
+				t.Errorf("Message() = %q, want a single line", got)
+			}
+			if !strings.HasPrefix(got, tt.prefixOf) {
+				t.Errorf("Message() = %q, want it to start with %q", got, tt.prefixOf)
+			}
+			if tt.prefix == "" && !strings.HasSuffix(got, "(2026-02-10 09:05)") {
+				t.Errorf("Message() = %q, want the timestamp kept", got)
+			}
+		})
+	}
+}
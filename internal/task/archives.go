@@ -0,0 +1,126 @@
+package task
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// archiveFileName is the archive file ttt writes to (config.ArchiveFileName).
+const archiveFileName = "archive.md"
+
+// monthlyArchivePattern matches the name of a monthly archive file: archive-YYYY-MM.md.
+var monthlyArchivePattern = regexp.MustCompile(`^archive-\d{4}-(0[1-9]|1[0-2])\.md$`)
+
+// Archives is the archive of a working directory: archive.md and the monthly
+// archive-YYYY-MM.md files next to it, joined into one content (see LoadArchives).
+type Archives struct {
+	Content string
+	Files   []ArchiveFile // the files in Content, in order
+	More    bool          // older monthly files were left out to stay within the budget
+}
+
+// ArchiveFile is a file joined in Archives.Content, with the range of its lines there.
+type ArchiveFile struct {
+	Path       string
+	Start, End int // lines [Start, End) of Content (0-indexed)
+}
+
+// Locate returns the file that line (0-indexed) of Content comes from, and the line
+// in that file. Reports false for a line past the end of every file.
+func (a Archives) Locate(line int) (path string, fileLine int, ok bool) {
+	for _, f := range a.Files {
+		if line >= f.Start && line < f.End {
+			return f.Path, line - f.Start, true
+		}
+	}
+	return "", 0, false
+}
+
+// ArchiveFiles returns the archive files in dir, newest first: archive.md, which ttt
+// archives to, then the monthly archive-YYYY-MM.md files by month, latest first.
+// Files that do not exist are left out.
+func ArchiveFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var monthly []string
+	var files []string
+	for _, entry := range entries {
+		switch name := entry.Name(); {
+		case entry.IsDir():
+		case name == archiveFileName:
+			files = append(files, filepath.Join(dir, name))
+		case monthlyArchivePattern.MatchString(name):
+			monthly = append(monthly, name)
+		}
+	}
+	// archive-YYYY-MM.md names sort by month
+	sort.Sort(sort.Reverse(sort.StringSlice(monthly)))
+	for _, name := range monthly {
+		files = append(files, filepath.Join(dir, name))
+	}
+	return files, nil
+}
+
+// LoadArchives reads the archive files of dir (see ArchiveFiles) and joins them, newest
+// first, so the date sections of all of them read as one archive.md. With a budget
+// (in bytes, 0 = none), files are read only until their total size reaches it: the
+// newest file is always read, and More reports that older ones were left out. With
+// only archive.md in dir, Content is archive.md unchanged; with no archive files, it
+// is empty.
+func LoadArchives(dir string, budget int64, opts Options) (Archives, error) {
+	paths, err := ArchiveFiles(dir)
+	if err != nil {
+		return Archives{}, err
+	}
+
+	var archives Archives
+	var b strings.Builder
+	var size int64
+	line := 0
+	open := false // the content so far ends without a line break
+	for i, path := range paths {
+		if budget > 0 && i > 0 && size >= budget {
+			archives.More = true
+			break
+		}
+		content, err := LoadFile(path, opts)
+		if err != nil {
+			return Archives{}, err
+		}
+		size += int64(len(content))
+		if open {
+			b.WriteString("\n") // end the last line of the previous file
+		}
+		b.WriteString(content)
+		n := strings.Count(content, "\n")
+		open = content != "" && !strings.HasSuffix(content, "\n")
+		if open {
+			n++
+		}
+		archives.Files = append(archives.Files, ArchiveFile{Path: path, Start: line, End: line + n})
+		line += n
+	}
+	archives.Content = b.String()
+	return archives, nil
+}
+
+// LoadAllArchives is LoadAllArchivesWith with DefaultOptions.
+func LoadAllArchives(dir string) (string, error) {
+	return LoadAllArchivesWith(dir, DefaultOptions())
+}
+
+// LoadAllArchivesWith returns the content of every archive file of dir, joined newest
+// first (see LoadArchives). It is "" when dir has no archive files.
+func LoadAllArchivesWith(dir string, opts Options) (string, error) {
+	archives, err := LoadArchives(dir, 0, opts)
+	return archives.Content, err
+}
@@ -0,0 +1,116 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeArchives writes files (name to content) into a new temporary directory.
+func writeArchives(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	return dir
+}
+
+// TestArchiveFiles verifies that archive.md comes first and monthly files follow,
+// latest month first, ignoring other files.
+func TestArchiveFiles(t *testing.T) {
+	dir := writeArchives(t, map[string]string{
+		"archive.md":         "",
+		"archive-2025-12.md": "",
+		"archive-2026-02.md": "",
+		"archive-2026-01.md": "",
+		"archive-2026-13.md": "",
+		"archive-old.md":     "",
+		"tasks.md":           "",
+	})
+	paths, err := ArchiveFiles(dir)
+	if err != nil {
+		t.Fatalf("ArchiveFiles() error: %v", err)
+	}
+	var names []string
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	want := "archive.md archive-2026-02.md archive-2026-01.md archive-2025-12.md"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("ArchiveFiles() = %s, want %s", got, want)
+	}
+}
+
+// TestLoadArchives verifies joining the files, locating lines in them, and the budget.
+func TestLoadArchives(t *testing.T) {
+	dir := writeArchives(t, map[string]string{
+		"archive.md":         "## 2026-02-10\n\n- [x] A @done(2026-02-10)\n",
+		"archive-2026-01.md": "## 2026-01-31\n\n- [x] B @done(2026-01-31)", // no trailing newline
+		"archive-2025-12.md": "## 2025-12-01\n\n- [x] C @done(2025-12-01)\n",
+	})
+
+	archives, err := LoadArchives(dir, 0, DefaultOptions())
+	if err != nil {
+		t.Fatalf("LoadArchives() error: %v", err)
+	}
+	want := "## 2026-02-10\n\n- [x] A @done(2026-02-10)\n" +
+		"## 2026-01-31\n\n- [x] B @done(2026-01-31)\n" +
+		"## 2025-12-01\n\n- [x] C @done(2025-12-01)\n"
+	if archives.Content != want || archives.More {
+		t.Errorf("LoadArchives() = %q, more %v; want %q", archives.Content, archives.More, want)
+	}
+	lines := strings.Split(archives.Content, "\n")
+	for _, tt := range []struct {
+		line int
+		file string
+		at   int
+	}{
+		{2, "archive.md", 2},
+		{3, "archive-2026-01.md", 0},
+		{5, "archive-2026-01.md", 2},
+		{8, "archive-2025-12.md", 2},
+	} {
+		path, at, ok := archives.Locate(tt.line)
+		if !ok || filepath.Base(path) != tt.file || at != tt.at {
+			t.Errorf("Locate(%d) = %s:%d, %v; want %s:%d", tt.line, filepath.Base(path), at, ok, tt.file, tt.at)
+			continue
+		}
+		data, _ := os.ReadFile(path)
+		if got := strings.Split(string(data), "\n")[at]; got != lines[tt.line] {
+			t.Errorf("line %d is %q in the file, %q in the content", tt.line, got, lines[tt.line])
+		}
+	}
+	if _, _, ok := archives.Locate(9); ok {
+		t.Error("Locate() past the end reported a file")
+	}
+
+	// The newest file is always read; older ones only within the budget
+	archives, err = LoadArchives(dir, 10, DefaultOptions())
+	if err != nil {
+		t.Fatalf("LoadArchives() error: %v", err)
+	}
+	if len(archives.Files) != 1 || !archives.More || !strings.Contains(archives.Content, "- [x] A") {
+		t.Errorf("LoadArchives() with a budget = %d file(s), more %v", len(archives.Files), archives.More)
+	}
+}
+
+// TestLoadAllArchivesSingleFile verifies that archive.md alone is returned unchanged,
+// and that a directory without archive files gives no content.
+func TestLoadAllArchivesSingleFile(t *testing.T) {
+	content := "## 2026-02-10\n\n- [x] A @done(2026-02-10)\n\nnotes without newline"
+	dir := writeArchives(t, map[string]string{"archive.md": content})
+	if got, err := LoadAllArchives(dir); err != nil || got != content {
+		t.Errorf("LoadAllArchivesWith() = %q, %v; want archive.md unchanged", got, err)
+	}
+
+	if got, err := LoadAllArchives(t.TempDir()); err != nil || got != "" {
+		t.Errorf("LoadAllArchivesWith() without archives = %q, %v", got, err)
+	}
+	if got, err := LoadAllArchives(filepath.Join(t.TempDir(), "missing")); err != nil || got != "" {
+		t.Errorf("LoadAllArchivesWith() of a missing directory = %q, %v", got, err)
+	}
+}
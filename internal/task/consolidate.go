@@ -0,0 +1,222 @@
+package task
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// unsortedHeader holds archive content that belongs to no date section.
+const unsortedHeader = "## Unsorted"
+
+// ArchiveSection is one date section of archive.md.
+type ArchiveSection struct {
+	Date  time.Time
+	Lines []string // lines below the header, without leading and trailing blank lines
+}
+
+// ConsolidateResult counts what ConsolidateArchive changed.
+type ConsolidateResult struct {
+	MergedSections int // sections merged into an earlier section with the same date
+	RemovedLines   int // lines of duplicate tasks removed
+	UnsortedLines  int // lines kept under "## Unsorted"
+}
+
+// ParseArchive splits archive.md content into its date sections, in file order.
+// Headers in any form RepairArchiveHeaders understands start a section.
+// Lines that belong to no date section (text before the first header, and
+// "## " sections whose text is not a date, headers included) are returned
+// verbatim in unsorted; the body of an earlier "## Unsorted" section is
+// returned without its header.
+func ParseArchive(content string) (sections []ArchiveSection, unsorted []string) {
+	current := -1 // index into sections, -1 outside a date section
+	for _, line := range splitContentLines(content) {
+		if date, ok := parseArchiveHeader(line); ok {
+			sections = append(sections, ArchiveSection{Date: date})
+			current = len(sections) - 1
+			continue
+		}
+		if archiveHeaderPattern.MatchString(line) {
+			current = -1
+			if strings.TrimSpace(line) == unsortedHeader {
+				continue
+			}
+		}
+		if current >= 0 {
+			sections[current].Lines = append(sections[current].Lines, line)
+		} else {
+			unsorted = append(unsorted, line)
+		}
+	}
+
+	for i := range sections {
+		sections[i].Lines = trimBlankLines(sections[i].Lines)
+	}
+	return sections, trimBlankLines(unsorted)
+}
+
+// ConsolidateArchive cleans up archive.md content: sections with the same date are
+// merged, a task repeated within a section (the same task line with the same
+// indented lines below it) is kept once, and sections are sorted newest first in
+// the "## YYYY-MM-DD" form. Content that belongs to no date section is kept
+// verbatim at the bottom under "## Unsorted".
+func ConsolidateArchive(content string, opts Options) (string, ConsolidateResult) {
+	sections, unsorted := ParseArchive(content)
+	var result ConsolidateResult
+
+	byDate := make(map[time.Time]*ArchiveSection)
+	var merged []*ArchiveSection
+	for i := range sections {
+		s := &sections[i]
+		if existing, ok := byDate[s.Date]; ok {
+			existing.Lines = append(existing.Lines, s.Lines...)
+			result.MergedSections++
+			continue
+		}
+		byDate[s.Date] = s
+		merged = append(merged, s)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Date.After(merged[j].Date)
+	})
+
+	var b strings.Builder
+	for _, s := range merged {
+		lines, removed := removeDuplicateTasks(s.Lines, opts)
+		result.RemovedLines += removed
+		b.WriteString("## " + s.Date.Format("2006-01-02") + "\n\n")
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+	if len(unsorted) > 0 {
+		result.UnsortedLines = len(unsorted)
+		b.WriteString(unsortedHeader + "\n\n")
+		for _, line := range unsorted {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	// Sections are separated by a blank line; the file ends with a single newline
+	out := strings.TrimRight(b.String(), "\n")
+	if out == "" {
+		return "", result
+	}
+	return out + "\n", result
+}
+
+// removeDuplicateTasks drops top-level tasks that repeat an earlier one exactly,
+// together with their indented lines. Children are compared only as part of their
+// parent, so the same subtask under two different parents is kept.
+// Returns the remaining lines and the count of lines removed.
+func removeDuplicateTasks(lines []string, opts Options) ([]string, int) {
+	seen := make(map[string]bool)
+	var result []string
+	removed := 0
+
+	for i := 0; i < len(lines); {
+		end := i + 1
+		if IsTask(lines[i], opts) && GetIndentLevel(lines[i]) == 0 {
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "" && GetIndentLevel(lines[end]) > 0 {
+				end++
+			}
+			block := strings.Join(lines[i:end], "\n")
+			if seen[block] {
+				removed += end - i
+				i = end
+				continue
+			}
+			seen[block] = true
+		}
+		result = append(result, lines[i:end]...)
+		i = end
+	}
+	return result, removed
+}
+
+// trimBlankLines removes leading and trailing blank lines.
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// SortArchiveSections returns archive.md content with its date sections ordered by
+// date, oldest first when ascending is set and newest first otherwise. Lines inside
+// a section keep their order. Text before the first "## " header stays at the top,
+// and sections whose header is not a date stay at the bottom in file order.
+// Only the display is meant to change: archive.md itself is kept newest first.
+func SortArchiveSections(content string, ascending bool) string {
+	lines := splitContentLines(content)
+	order := ArchiveSectionOrder(content, ascending)
+	sorted := make([]string, len(order))
+	for i, n := range order {
+		if n >= 0 {
+			sorted[i] = lines[n]
+		}
+	}
+	result := strings.Join(sorted, "\n")
+	if strings.HasSuffix(content, "\n") && result != "" {
+		result += "\n"
+	}
+	return result
+}
+
+// ArchiveSectionOrder returns the line numbers (0-indexed) of content in the order
+// SortArchiveSections puts them, so a line shown sorted can be found in the file.
+// -1 stands for a blank line added between two sections that had none.
+func ArchiveSectionOrder(content string, ascending bool) []int {
+	type section struct {
+		date       time.Time
+		dated      bool
+		start, end int // line range [start, end)
+	}
+
+	lines := splitContentLines(content)
+	var sections []section
+	preamble := len(lines)
+	for i, line := range lines {
+		if !archiveHeaderPattern.MatchString(line) {
+			continue
+		}
+		if len(sections) == 0 {
+			preamble = i
+		} else {
+			sections[len(sections)-1].end = i
+		}
+		date, ok := parseArchiveHeader(line)
+		sections = append(sections, section{date: date, dated: ok, start: i, end: len(lines)})
+	}
+
+	sort.SliceStable(sections, func(i, j int) bool {
+		a, b := sections[i], sections[j]
+		if a.dated != b.dated {
+			return a.dated
+		}
+		if ascending {
+			return a.date.Before(b.date)
+		}
+		return a.date.After(b.date)
+	})
+
+	order := make([]int, 0, len(lines))
+	for n := range preamble {
+		order = append(order, n)
+	}
+	for i, s := range sections {
+		for n := s.start; n < s.end; n++ {
+			order = append(order, n)
+		}
+		if i < len(sections)-1 && strings.TrimSpace(lines[s.end-1]) != "" {
+			order = append(order, -1)
+		}
+	}
+	return order
+}
@@ -0,0 +1,183 @@
+package task
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// messyArchive has a duplicate date section (in two header forms), a task repeated
+// within a section, a preamble, and a section whose header is not a date.
+const messyArchive = `Copied from the old notes app
+
+## 2026-01-15
+
+- [x] Write changelog @done(2026-01-15)
+  - [x] Review wording @done(2026-01-15)
+
+## 2026-01-20
+
+- [x] Plan sprint @done(2026-01-20)
+
+## Ideas
+
+- someday: learn Rust
+
+## 2026/1/15
+
+- [x] Write changelog @done(2026-01-15)
+  - [x] Review wording @done(2026-01-15)
+- [x] Ship release @done(2026-01-15)
+  - [x] Review wording @done(2026-01-15)
+`
+
+// TestParseArchive verifies sections in file order and verbatim unsorted content.
+func TestParseArchive(t *testing.T) {
+	sections, unsorted := ParseArchive(messyArchive)
+
+	wantDates := []string{"2026-01-15", "2026-01-20", "2026-01-15"}
+	if len(sections) != len(wantDates) {
+		t.Fatalf("ParseArchive() returned %d sections, want %d", len(sections), len(wantDates))
+	}
+	for i, want := range wantDates {
+		if got := sections[i].Date.Format("2006-01-02"); got != want {
+			t.Errorf("section %d date = %s, want %s", i, got, want)
+		}
+	}
+	if got := len(sections[2].Lines); got != 4 {
+		t.Errorf("section 2 has %d lines, want 4: %q", got, sections[2].Lines)
+	}
+	if got := sections[0].Date; !got.Equal(time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("section 0 date = %v, want 2026-01-15 UTC", got)
+	}
+
+	wantUnsorted := []string{"Copied from the old notes app", "", "## Ideas", "", "- someday: learn Rust"}
+	if len(unsorted) != len(wantUnsorted) {
+		t.Fatalf("unsorted = %q, want %q", unsorted, wantUnsorted)
+	}
+	for i := range unsorted {
+		if unsorted[i] != wantUnsorted[i] {
+			t.Errorf("unsorted[%d] = %q, want %q", i, unsorted[i], wantUnsorted[i])
+		}
+	}
+}
+
+// TestConsolidateArchive verifies merging, duplicate removal, sorting, and the Unsorted section.
+func TestConsolidateArchive(t *testing.T) {
+	want := `## 2026-01-20
+
+- [x] Plan sprint @done(2026-01-20)
+
+## 2026-01-15
+
+- [x] Write changelog @done(2026-01-15)
+  - [x] Review wording @done(2026-01-15)
+- [x] Ship release @done(2026-01-15)
+  - [x] Review wording @done(2026-01-15)
+
+## Unsorted
+
+Copied from the old notes app
+
+## Ideas
+
+- someday: learn Rust
+`
+
+	got, result := ConsolidateArchive(messyArchive, DefaultOptions())
+	if got != want {
+		t.Errorf("ConsolidateArchive() =\n%s\nwant\n%s", got, want)
+	}
+	if result.MergedSections != 1 || result.RemovedLines != 2 || result.UnsortedLines != 5 {
+		t.Errorf("ConsolidateArchive() result = %+v, want 1 merged, 2 removed, 5 unsorted", result)
+	}
+
+	// Consolidating again changes nothing, and "## Unsorted" is not nested
+	again, result := ConsolidateArchive(got, DefaultOptions())
+	if again != got {
+		t.Errorf("second ConsolidateArchive() =\n%s\nwant\n%s", again, got)
+	}
+	if result.MergedSections != 0 || result.RemovedLines != 0 {
+		t.Errorf("second ConsolidateArchive() result = %+v, want nothing merged or removed", result)
+	}
+}
+
+// TestConsolidateArchiveIdempotent verifies that the output ends with a single
+// newline and that consolidating it again leaves it unchanged.
+func TestConsolidateArchiveIdempotent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"messy archive", messyArchive},
+		{"one section", "## 2026-01-20\n\n- [x] Plan sprint @done(2026-01-20)\n"},
+		{"trailing blank lines", "## 2026-01-20\n\n- [x] Plan sprint @done(2026-01-20)\n\n\n\n"},
+		{"no final newline", "## 2026-01-20\n- [x] Plan sprint @done(2026-01-20)"},
+		{"only unsorted", "Notes\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			once, _ := ConsolidateArchive(tt.content, DefaultOptions())
+			if !strings.HasSuffix(once, "\n") || strings.HasSuffix(once, "\n\n") {
+				t.Errorf("ConsolidateArchive() = %q, want a single trailing newline", once)
+			}
+			if twice, _ := ConsolidateArchive(once, DefaultOptions()); twice != once {
+				t.Errorf("second ConsolidateArchive() = %q, want %q", twice, once)
+			}
+		})
+	}
+}
+
+// TestConsolidateArchiveEmpty verifies that an empty archive stays empty.
+func TestConsolidateArchiveEmpty(t *testing.T) {
+	if got, result := ConsolidateArchive("", DefaultOptions()); got != "" || result != (ConsolidateResult{}) {
+		t.Errorf("ConsolidateArchive(\"\") = %q, %+v", got, result)
+	}
+}
+
+// TestSortArchiveSections verifies ordering date sections while keeping task order,
+// the text above the first header, and non-date sections at the bottom.
+func TestSortArchiveSections(t *testing.T) {
+	content := "# Archive\n\n" +
+		"## 2026-01-20\n\n- [x] C1 @done(2026-01-20)\n- [x] C2 @done(2026-01-20)\n\n" +
+		"## Someday\n\n- [x] S\n\n" +
+		"## 2026-01-18\n\n- [x] A @done(2026-01-18)\n\n" +
+		"## 2026/1/19\n\n- [x] B @done(2026-01-19)\n  - [x] B1 @done(2026-01-19)\n"
+
+	ascending := "# Archive\n\n" +
+		"## 2026-01-18\n\n- [x] A @done(2026-01-18)\n\n" +
+		"## 2026/1/19\n\n- [x] B @done(2026-01-19)\n  - [x] B1 @done(2026-01-19)\n\n" +
+		"## 2026-01-20\n\n- [x] C1 @done(2026-01-20)\n- [x] C2 @done(2026-01-20)\n\n" +
+		"## Someday\n\n- [x] S\n\n"
+	if got := SortArchiveSections(content, true); got != ascending {
+		t.Errorf("SortArchiveSections(ascending) =\n%s\nwant:\n%s", got, ascending)
+	}
+
+	descending := "# Archive\n\n" +
+		"## 2026-01-20\n\n- [x] C1 @done(2026-01-20)\n- [x] C2 @done(2026-01-20)\n\n" +
+		"## 2026/1/19\n\n- [x] B @done(2026-01-19)\n  - [x] B1 @done(2026-01-19)\n\n" +
+		"## 2026-01-18\n\n- [x] A @done(2026-01-18)\n\n" +
+		"## Someday\n\n- [x] S\n\n"
+	if got := SortArchiveSections(content, false); got != descending {
+		t.Errorf("SortArchiveSections(descending) =\n%s\nwant:\n%s", got, descending)
+	}
+
+	// Every shown line maps back to the same line of the file
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	shown := strings.Split(strings.TrimSuffix(ascending, "\n"), "\n")
+	for i, n := range ArchiveSectionOrder(content, true) {
+		if n >= 0 && lines[n] != shown[i] {
+			t.Errorf("line %d maps to file line %d %q, want %q", i, n, lines[n], shown[i])
+		}
+		if n < 0 && shown[i] != "" {
+			t.Errorf("added line %d = %q, want blank", i, shown[i])
+		}
+	}
+
+	for _, empty := range []string{"", "\n"} {
+		if got := SortArchiveSections(empty, true); got != "" {
+			t.Errorf("SortArchiveSections(%q) = %q, want empty", empty, got)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package task
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values of [tasks] weekday_due: what ttt -t does with a weekday @due such as
+// @due(fri).
+const (
+	WeekdayDueKeep   = "keep"   // leave it in the file; it is resolved whenever it is read
+	WeekdayDueExpand = "expand" // replace it with the date it stands for when the task is added
+)
+
+// weekdayNames maps the weekday names ParseDueDate accepts, in lower case, to their day.
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday, "日": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday, "月": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday, "火": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday, "水": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday, "木": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday, "金": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday, "土": time.Saturday,
+}
+
+// ParseDueDate parses the value of a @due tag relative to now: a date (YYYY-MM-DD),
+// or a weekday, in English ("fri", "friday", any case) or Japanese ("金", "金曜",
+// "金曜日"), which stands for the next such day: today on that weekday, and the
+// next week's once it has passed. Reports false for anything else.
+func ParseDueDate(value string, now time.Time) (time.Time, bool) {
+	if due, err := time.Parse("2006-01-02", value); err == nil {
+		return due, true
+	}
+	name := strings.ToLower(value)
+	day, ok := weekdayNames[name]
+	for _, suffix := range []string{"曜日", "曜"} {
+		if !ok && strings.HasSuffix(name, suffix) {
+			day, ok = weekdayNames[strings.TrimSuffix(name, suffix)]
+		}
+	}
+	if !ok {
+		return time.Time{}, false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return today.AddDate(0, 0, (int(day)-int(today.Weekday())+7)%7), true
+}
+
+// DueDate returns the date of the @due tag on line (see ParseDueDate for weekdays).
+// Reports false when there is no tag or its value is not a date.
+func DueDate(line string, now time.Time) (time.Time, bool) {
+	m := dueTagPattern.FindStringSubmatch(line)
+	if m == nil {
+		return time.Time{}, false
+	}
+	return ParseDueDate(m[1], now)
+}
+
+// ExpandWeekdayDue replaces a weekday @due on line, such as @due(fri), with the date
+// it stands for relative to now, e.g. @due(2026-10-23) (tasks.weekday_due = "expand").
+// Other lines are returned unchanged.
+func ExpandWeekdayDue(line string, now time.Time) string {
+	loc := dueTagPattern.FindStringSubmatchIndex(line)
+	if loc == nil {
+		return line
+	}
+	value := line[loc[2]:loc[3]]
+	if _, err := time.Parse("2006-01-02", value); err == nil {
+		return line
+	}
+	due, ok := ParseDueDate(value, now)
+	if !ok {
+		return line
+	}
+	return line[:loc[2]] + due.Format("2006-01-02") + line[loc[3]:]
+}
+
+// DueLabel describes the @due date of line relative to now by calendar day:
+// "(3d)" for a date ahead, "(today)", or "(overdue 2d)". Returns "" for a line
+// without a valid @due date.
+func DueLabel(line string, now time.Time) string {
+	due, ok := DueDate(line, now)
+	if !ok {
+		return ""
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(due.Sub(today).Hours() / 24)
+	switch {
+	case days > 0:
+		return "(" + strconv.Itoa(days) + "d)"
+	case days < 0:
+		return "(overdue " + strconv.Itoa(-days) + "d)"
+	}
+	return "(today)"
+}
+
+// SortByDue returns the open tasks of lines ordered by @due date, earliest first,
+// with undated tasks last and ties kept in file order. Each task stays below its
+// parent: siblings are ordered by the earliest @due among them and their open
+// subtasks, so a parent rises with its most urgent child. A completed parent is kept
+// when it has open subtasks. The returned lines are copies whose Content has the
+// DueLabel inserted after the indentation ("  (3d) - [ ] Task @due(...)"); their
+// LineNumber still points into lines.
+func SortByDue(lines []ParsedLine, now time.Time) []ParsedLine {
+	var result []ParsedLine
+	var emit func(trees []*TaskTree)
+	emit = func(trees []*TaskTree) {
+		var shown []dueGroup
+		for _, tree := range trees {
+			if group, ok := newDueGroup(tree, now); ok {
+				shown = append(shown, group)
+			}
+		}
+		slices.SortStableFunc(shown, compareDueGroups)
+		for _, group := range shown {
+			line := *group.tree.Line
+			if label := DueLabel(line.Content, now); label != "" && !line.IsCompleted {
+				rest := strings.TrimLeft(line.Content, " \t")
+				line.Content = line.Content[:len(line.Content)-len(rest)] + label + " " + rest
+			}
+			result = append(result, line)
+			emit(group.tree.Children)
+		}
+	}
+	emit(BuildTaskTrees(lines))
+	return result
+}
+
+// dueGroup is a task of SortByDue with the earliest @due among it and its open subtasks.
+type dueGroup struct {
+	tree  *TaskTree
+	due   time.Time
+	dated bool
+}
+
+// newDueGroup returns the dueGroup of tree. Reports false when tree has no open task,
+// so it is left out of SortByDue.
+func newDueGroup(tree *TaskTree, now time.Time) (dueGroup, bool) {
+	group := dueGroup{tree: tree}
+	open := !tree.Line.IsCompleted
+	if open {
+		group.due, group.dated = DueDate(tree.Line.Content, now)
+	}
+	for _, child := range tree.Children {
+		c, ok := newDueGroup(child, now)
+		if !ok {
+			continue
+		}
+		open = true
+		if c.dated && (!group.dated || c.due.Before(group.due)) {
+			group.due, group.dated = c.due, true
+		}
+	}
+	return group, open
+}
+
+// compareDueGroups orders dated groups by date before undated ones.
+func compareDueGroups(a, b dueGroup) int {
+	switch {
+	case a.dated && b.dated:
+		return a.due.Compare(b.due)
+	case a.dated:
+		return -1
+	case b.dated:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,169 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDueLabel verifies labels for future, today, overdue, and missing due dates.
+func TestDueLabel(t *testing.T) {
+	now := time.Date(2026, 2, 10, 18, 30, 0, 0, time.Local)
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"- [ ] Soon @due(2026-02-13)", "(3d)"},
+		{"- [ ] Tomorrow @due(2026-02-11)", "(1d)"},
+		{"- [ ] Now @due(2026-02-10)", "(today)"},
+		{"- [ ] Late @due(2026-02-08)", "(overdue 2d)"},
+		{"- [ ] Next month @due(2026-03-10)", "(28d)"},
+		{"- [ ] Undated", ""},
+		{"- [ ] Invalid @due(2026-02-30)", ""},
+		{"- [ ] This week @due(fri)", "(3d)"},
+		{"- [ ] Unknown word @due(someday)", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := DueLabel(tt.line, now); got != tt.want {
+				t.Errorf("DueLabel(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseDueDate verifies dates and English and Japanese weekdays, which stand for
+// the next such day.
+func TestParseDueDate(t *testing.T) {
+	now := time.Date(2026, 2, 10, 18, 30, 0, 0, time.Local) // a Tuesday
+	tests := []struct {
+		value string
+		want  string // "" for not a date
+	}{
+		{"2026-02-13", "2026-02-13"},
+		{"2026-02-30", ""},
+		{"fri", "2026-02-13"},
+		{"Friday", "2026-02-13"},
+		{"FRI", "2026-02-13"},
+		{"tue", "2026-02-10"}, // today
+		{"mon", "2026-02-16"}, // passed this week: next week
+		{"sunday", "2026-02-15"},
+		{"金", "2026-02-13"},
+		{"金曜", "2026-02-13"},
+		{"金曜日", "2026-02-13"},
+		{"月", "2026-02-16"},
+		{"日", "2026-02-15"},
+		{"日曜日", "2026-02-15"},
+		{"fr", ""},
+		{"曜日", ""},
+		{"tomorrow", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			due, ok := ParseDueDate(tt.value, now)
+			got := ""
+			if ok {
+				got = due.Format("2006-01-02")
+			}
+			if got != tt.want {
+				t.Errorf("ParseDueDate(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExpandWeekdayDue verifies that only a weekday @due is replaced by its date.
+func TestExpandWeekdayDue(t *testing.T) {
+	now := time.Date(2026, 2, 10, 9, 0, 0, 0, time.UTC) // a Tuesday
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"- [ ] Report @due(fri) @priority(A)", "- [ ] Report @due(2026-02-13) @priority(A)"},
+		{"- [ ] Report @due(金曜)", "- [ ] Report @due(2026-02-13)"},
+		{"- [ ] Report @due(2026-03-01)", "- [ ] Report @due(2026-03-01)"},
+		{"- [ ] Report @due(someday)", "- [ ] Report @due(someday)"},
+		{"- [ ] Report", "- [ ] Report"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := ExpandWeekdayDue(tt.line, now); got != tt.want {
+				t.Errorf("ExpandWeekdayDue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSortByDue verifies the order, the labels, and that parents stay above their children.
+func TestSortByDue(t *testing.T) {
+	now := time.Date(2026, 2, 10, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+		lines []int
+	}{
+		{
+			name: "dated first, undated last",
+			input: "- [ ] Undated\n" +
+				"- [ ] Later @due(2026-02-20)\n" +
+				"- [ ] Late @due(2026-02-08)\n" +
+				"- [ ] Also undated",
+			want: []string{
+				"(overdue 2d) - [ ] Late @due(2026-02-08)",
+				"(10d) - [ ] Later @due(2026-02-20)",
+				"- [ ] Undated",
+				"- [ ] Also undated",
+			},
+			lines: []int{2, 1, 0, 3},
+		},
+		{
+			name: "parent rises with its child",
+			input: "- [ ] Soon @due(2026-02-12)\n" +
+				"- [ ] Project\n" +
+				"  - [ ] Step @due(2026-02-15)\n" +
+				"  - [ ] Urgent step @due(2026-02-11)",
+			want: []string{
+				"- [ ] Project",
+				"  (1d) - [ ] Urgent step @due(2026-02-11)",
+				"  (5d) - [ ] Step @due(2026-02-15)",
+				"(2d) - [ ] Soon @due(2026-02-12)",
+			},
+			lines: []int{1, 3, 2, 0},
+		},
+		{
+			name: "completed tasks left out",
+			input: "- [x] Done @due(2026-02-01) @done(2026-02-01)\n" +
+				"- [x] Done parent @done(2026-02-01)\n" +
+				"  - [ ] Open child @due(2026-02-10)\n" +
+				"  - [x] Done child @done(2026-02-01)\n" +
+				"# Notes\n" +
+				"```\n- [ ] In code @due(2026-01-01)\n```",
+			want: []string{
+				"- [x] Done parent @done(2026-02-01)",
+				"  (today) - [ ] Open child @due(2026-02-10)",
+			},
+			lines: []int{1, 2},
+		},
+		{
+			name:  "no open tasks",
+			input: "# Inbox\n- [x] Done @done(2026-02-01)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SortByDue(ParseLines(tt.input, DefaultOptions()), now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("SortByDue() returned %d lines, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i].Content != tt.want[i] || got[i].LineNumber != tt.lines[i] {
+					t.Errorf("line %d = %d %q, want %d %q", i, got[i].LineNumber, got[i].Content, tt.lines[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package task
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WorkDay is the length of "1d" in an effort duration: a day of work, not 24 hours.
+const WorkDay = 8 * time.Hour
+
+var (
+	// estTagPattern matches an @est(...) tag with any text inside, capturing the text
+	estTagPattern = regexp.MustCompile(`(?:^|\s)@est\(([^)]*)\)`)
+
+	// effortPattern matches an effort duration: "1d", "2h", "30m", "1d4h", "1h30m"
+	effortPattern = regexp.MustCompile(`^(?:(\d+)d)?(?:(\d+)h)?(?:(\d+)m)?$`)
+)
+
+// ParseEffort parses an effort duration made of days, hours, and minutes in that
+// order, each at most once: "30m", "2h", "1d", "1h30m", "1d2h30m". A day is WorkDay.
+// Reports false for anything else, including an empty string.
+func ParseEffort(s string) (time.Duration, bool) {
+	m := effortPattern.FindStringSubmatch(s)
+	if m == nil || s == "" {
+		return 0, false
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{WorkDay, time.Hour, time.Minute} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, false
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, true
+}
+
+// FormatEffort formats d, rounded to the minute, in hours and minutes: "4h30m", "2h",
+// "45m", "0m". Days are not used, so the result reads the same for any day length.
+func FormatEffort(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	hours, minutes := minutes/60, minutes%60
+	switch {
+	case hours > 0 && minutes > 0:
+		return strconv.Itoa(hours) + "h" + strconv.Itoa(minutes) + "m"
+	case hours > 0:
+		return strconv.Itoa(hours) + "h"
+	}
+	return strconv.Itoa(minutes) + "m"
+}
+
+// Estimate returns the effort of the first @est(...) tag on line. Reports false when
+// there is no tag or its duration is malformed (see ParseEffort).
+func Estimate(line string) (time.Duration, bool) {
+	m := estTagPattern.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	return ParseEffort(strings.TrimSpace(m[1]))
+}
+
+// RemainingEstimate sums the estimates of the open tasks among lines.
+// Tasks without a valid estimate count as zero.
+func RemainingEstimate(lines []ParsedLine) time.Duration {
+	var total time.Duration
+	for _, line := range lines {
+		if !line.IsTask || line.IsCompleted {
+			continue
+		}
+		if d, ok := Estimate(line.Content); ok {
+			total += d
+		}
+	}
+	return total
+}
+
+// HeadingEstimate is the remaining estimate of the open tasks under one heading.
+type HeadingEstimate struct {
+	Heading   string        // nearest heading text ("" for tasks above the first heading)
+	Remaining time.Duration // sum of the @est of open tasks
+	Tasks     int           // open tasks with a valid @est
+}
+
+// EstimatesByHeading groups the remaining estimates of content's open tasks by their
+// nearest heading, in the order the headings first appear. Headings without any
+// estimated open task are left out.
+func EstimatesByHeading(content string, opts Options) []HeadingEstimate {
+	lines := ParseLines(content, opts)
+	headings := nearestHeadings(lines)
+	index := make(map[string]int)
+	var result []HeadingEstimate
+	for i, line := range lines {
+		if !line.IsTask || line.IsCompleted {
+			continue
+		}
+		d, ok := Estimate(line.Content)
+		if !ok {
+			continue
+		}
+		n, seen := index[headings[i]]
+		if !seen {
+			n = len(result)
+			index[headings[i]] = n
+			result = append(result, HeadingEstimate{Heading: headings[i]})
+		}
+		result[n].Remaining += d
+		result[n].Tasks++
+	}
+	return result
+}
+
+// MalformedEstimates returns the task lines of content with an @est(...) tag that
+// ParseEffort does not accept, e.g. "@est(soon)" or "@est(2 hours)".
+// Lines in code blocks and front matter are skipped.
+func MalformedEstimates(content string, opts Options) []ParsedLine {
+	var malformed []ParsedLine
+	for _, line := range ParseLines(content, opts) {
+		if !line.IsTask || !estTagPattern.MatchString(line.Content) {
+			continue
+		}
+		if _, ok := Estimate(line.Content); !ok {
+			malformed = append(malformed, line)
+		}
+	}
+	return malformed
+}
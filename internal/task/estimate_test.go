@@ -0,0 +1,145 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestParseEffort verifies the d/h/m units, their combinations, and rejected forms.
+func TestParseEffort(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"30m", 30 * time.Minute, true},
+		{"2h", 2 * time.Hour, true},
+		{"1d", 8 * time.Hour, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"1d4h", 12 * time.Hour, true},
+		{"2d30m", 16*time.Hour + 30*time.Minute, true},
+		{"1d2h30m", 10*time.Hour + 30*time.Minute, true},
+		{"90m", 90 * time.Minute, true},
+		{"0m", 0, true},
+		{"", 0, false},
+		{"soon", 0, false},
+		{"2", 0, false},
+		{"1.5h", 0, false},
+		{"30m1h", 0, false},
+		{"2 hours", 0, false},
+		{"1h 30m", 0, false},
+		{"2H", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := ParseEffort(tt.input)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseEffort(%q) = %v, %v; want %v, %v", tt.input, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestFormatEffort verifies that efforts are written in hours and minutes only.
+func TestFormatEffort(t *testing.T) {
+	tests := []struct {
+		input time.Duration
+		want  string
+	}{
+		{0, "0m"},
+		{45 * time.Minute, "45m"},
+		{2 * time.Hour, "2h"},
+		{4*time.Hour + 30*time.Minute, "4h30m"},
+		{2 * WorkDay, "16h"},
+		{90*time.Second + 29*time.Second, "2m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatEffort(tt.input); got != tt.want {
+				t.Errorf("FormatEffort(%v) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEstimate verifies reading the @est tag of a line.
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"- [ ] Write report @est(2h)", 2 * time.Hour, true},
+		{"- [ ] Review @est(1h30m) @due(2026-02-01)", 90 * time.Minute, true},
+		{"- [ ] Review @est( 30m )", 30 * time.Minute, true},
+		{"- [ ] No estimate", 0, false},
+		{"- [ ] Bad @est(soon)", 0, false},
+		{"- [ ] Mail me@est(2h)", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok := Estimate(tt.line)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Estimate(%q) = %v, %v; want %v, %v", tt.line, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestRemainingEstimate verifies that only open tasks with a valid estimate count.
+func TestRemainingEstimate(t *testing.T) {
+	content := "- [ ] A @est(2h)\n" +
+		"  - [ ] A1 @est(30m)\n" +
+		"- [x] B @est(1d) @done(2026-01-18)\n" +
+		"- [ ] C @est(2h)\n" +
+		"- [ ] D @est(later)\n" +
+		"- Note @est(3h)\n" +
+		"```\n- [ ] In code @est(5h)\n```"
+	if got, want := RemainingEstimate(ParseLines(content, DefaultOptions())), 4*time.Hour+30*time.Minute; got != want {
+		t.Errorf("RemainingEstimate() = %v, want %v", got, want)
+	}
+}
+
+// TestEstimatesByHeading verifies per-heading totals in heading order.
+func TestEstimatesByHeading(t *testing.T) {
+	content := "- [ ] Loose @est(15m)\n" +
+		"# Work\n" +
+		"- [ ] A @est(2h)\n" +
+		"- [ ] B @est(1h30m)\n" +
+		"- [x] Done @est(1d) @done(2026-01-18)\n" +
+		"## Errands\n" +
+		"- [ ] No estimate\n" +
+		"## Home\n" +
+		"- [ ] Paint @est(1d)\n"
+
+	got := fmt.Sprint(EstimatesByHeading(content, DefaultOptions()))
+	want := fmt.Sprint([]HeadingEstimate{
+		{Heading: "", Remaining: 15 * time.Minute, Tasks: 1},
+		{Heading: "Work", Remaining: 3*time.Hour + 30*time.Minute, Tasks: 2},
+		{Heading: "Home", Remaining: 8 * time.Hour, Tasks: 1},
+	})
+	if got != want {
+		t.Errorf("EstimatesByHeading() = %s, want %s", got, want)
+	}
+}
+
+// TestMalformedEstimates verifies that only task lines with an unparsable @est are returned.
+func TestMalformedEstimates(t *testing.T) {
+	content := "- [ ] Fine @est(2h)\n" +
+		"- [ ] Words @est(2 hours)\n" +
+		"- [x] Empty @est()\n" +
+		"- Note @est(soon)\n" +
+		"```\n- [ ] Code @est(x)\n```"
+
+	var got []int
+	for _, line := range MalformedEstimates(content, DefaultOptions()) {
+		got = append(got, line.LineNumber)
+	}
+	if fmt.Sprint(got) != "[1 2]" {
+		t.Errorf("MalformedEstimates() lines = %v, want [1 2]", got)
+	}
+}
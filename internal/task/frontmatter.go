@@ -0,0 +1,67 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseFrontmatter returns the settings in the leading front matter block of content
+// (see SplitFrontMatter) and content without the block. Top-level "key: value" (YAML)
+// and "key = value" (TOML) lines are read, with surrounding quotes removed; comments,
+// indented lines, and list items are skipped. The map is empty when there is no block.
+func ParseFrontmatter(content string) (map[string]string, string) {
+	frontMatter, body := SplitFrontMatter(content)
+	values := make(map[string]string)
+	lines := strings.Split(frontMatter, "\n")
+	for _, line := range lines[min(1, len(lines)):] {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || line == "---" || line == "..." || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, "-") || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		i := strings.IndexAny(line, ":=")
+		if i <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	return values, body
+}
+
+// FileSettings are the settings a task file sets for itself in its front matter.
+// Each one overrides config.toml for that file only; unset ones follow config.toml.
+type FileSettings struct {
+	DelayDays *int   // delay_days: archive delay for every task in the file (nil = not set)
+	Cascade   string // cascade: one of the Cascade constants ("" = not set)
+}
+
+// FrontmatterSettings reads the FileSettings of content's front matter. Invalid values
+// are left unset and returned as errors, one per key; other keys are ignored.
+func FrontmatterSettings(content string) (FileSettings, []error) {
+	values, _ := ParseFrontmatter(content)
+	var settings FileSettings
+	var errs []error
+	if value, ok := values["delay_days"]; ok {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			errs = append(errs, fmt.Errorf("invalid delay_days %q: must be a number of days >= 0", value))
+		} else {
+			settings.DelayDays = &days
+		}
+	}
+	if value, ok := values["cascade"]; ok {
+		switch value {
+		case CascadeAll, CascadeDirect, CascadeOff:
+			settings.Cascade = value
+		default:
+			errs = append(errs, fmt.Errorf("invalid cascade %q: use \"all\", \"direct\", or \"off\"", value))
+		}
+	}
+	return settings, errs
+}
@@ -0,0 +1,135 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseFrontmatter verifies reading YAML and TOML style values and splitting off the body.
+func TestParseFrontmatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		want     map[string]string
+		wantBody string
+	}{
+		{
+			name:     "yaml",
+			content:  "---\ndelay_days: 7\ntitle: \"Work: Q1\"\n---\n# Tasks\n",
+			want:     map[string]string{"delay_days": "7", "title": "Work: Q1"},
+			wantBody: "# Tasks\n",
+		},
+		{
+			name:     "toml",
+			content:  "---\ncascade = 'direct'\nurl = \"https://example.com\"\n---\n- [ ] A",
+			want:     map[string]string{"cascade": "direct", "url": "https://example.com"},
+			wantBody: "- [ ] A",
+		},
+		{
+			name:     "comments, lists, and nested keys skipped",
+			content:  "---\n# comment\ntags:\n  - work\n- loose\nparent:\n  child: 1\n...\nbody",
+			want:     map[string]string{"tags": "", "parent": ""},
+			wantBody: "body",
+		},
+		{
+			name:     "no front matter",
+			content:  "# Tasks\n---\ndelay_days: 7\n---\n",
+			want:     map[string]string{},
+			wantBody: "# Tasks\n---\ndelay_days: 7\n---\n",
+		},
+		{
+			name:     "unclosed block",
+			content:  "---\ndelay_days: 7\n- [ ] A\n",
+			want:     map[string]string{},
+			wantBody: "---\ndelay_days: 7\n- [ ] A\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, body := ParseFrontmatter(tt.content)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || body != tt.wantBody {
+				t.Errorf("ParseFrontmatter() = %v, %q; want %v, %q", got, body, tt.want, tt.wantBody)
+			}
+		})
+	}
+}
+
+// TestFrontmatterSettings verifies the recognized keys and the errors for invalid values.
+func TestFrontmatterSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantDelay   string // "" when unset
+		wantCascade string
+		wantErrs    int
+	}{
+		{"both set", "---\ndelay_days: 7\ncascade: off\n---\n", "7", CascadeOff, 0},
+		{"zero delay", "---\ndelay_days = 0\n---\n", "0", "", 0},
+		{"other keys ignored", "---\ntitle: Work\n---\n", "", "", 0},
+		{"negative delay", "---\ndelay_days: -1\n---\n", "", "", 1},
+		{"invalid values", "---\ndelay_days: soon\ncascade: some\n---\n", "", "", 2},
+		{"no front matter", "- [ ] A\n", "", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, errs := FrontmatterSettings(tt.content)
+			delay := ""
+			if settings.DelayDays != nil {
+				delay = fmt.Sprint(*settings.DelayDays)
+			}
+			if delay != tt.wantDelay || settings.Cascade != tt.wantCascade || len(errs) != tt.wantErrs {
+				t.Errorf("FrontmatterSettings() = delay %q, cascade %q, errors %v; want %q, %q, %d errors",
+					delay, settings.Cascade, errs, tt.wantDelay, tt.wantCascade, tt.wantErrs)
+			}
+		})
+	}
+}
+
+// TestFrontmatterOverrides verifies that front matter settings override the global
+// ones for their file, and that tagging and archiving leave the front matter as is.
+func TestFrontmatterOverrides(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	old := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+
+	t.Run("cascade", func(t *testing.T) {
+		frontMatter := "---\ncascade: off\n---\n"
+		got, count := ProcessContent(frontMatter+"- [x] Parent\n  - [ ] Child\n", DefaultOptions())
+		want := frontMatter + "- [x] Parent @done(" + today + ")\n  - [ ] Child\n"
+		if got != want || count != 1 {
+			t.Errorf("ProcessContent() = %q, %d; want %q, 1", got, count, want)
+		}
+	})
+
+	t.Run("delay_days", func(t *testing.T) {
+		frontMatter := "---\ntitle: Work\ndelay_days: 7\n---\n"
+		content := frontMatter + "- [x] Done @done(" + old + ")\n"
+		archivable, remaining := FilterArchivable(content, FixedDelay(2), DefaultOptions())
+		if len(archivable) != 0 || remaining != content {
+			t.Errorf("FilterArchivable() with delay_days 7 archived %v, remaining %q", archivable, remaining)
+		}
+
+		content = "---\ndelay_days: 1\n---\n- [x] Done @done(" + old + ")\n- [ ] Open"
+		archivable, remaining = FilterArchivable(content, FixedDelay(30), DefaultOptions())
+		if len(archivable) != 1 || remaining != "---\ndelay_days: 1\n---\n- [ ] Open" {
+			t.Errorf("FilterArchivable() with delay_days 1 archived %v, remaining %q", archivable, remaining)
+		}
+	})
+
+	t.Run("invalid values follow the config", func(t *testing.T) {
+		// The parent was done days ago, so the cascade only reaches Child without this
+		opts := DefaultOptions()
+		opts.CascadeIgnoreManual = true
+		content := "---\ndelay_days: soon\ncascade: sideways\n---\n- [x] Parent @done(" + old + ")\n  - [ ] Child\n"
+		got, _ := ProcessContent(content, opts)
+		if !strings.Contains(got, "  - [x] Child @done("+today+")") {
+			t.Errorf("ProcessContent() = %q, want the tasks.cascade default", got)
+		}
+		if archivable, _ := FilterArchivable(content, FixedDelay(2), DefaultOptions()); len(archivable) != 2 {
+			t.Errorf("FilterArchivable() archived %d lines, want 2 with the config delay", len(archivable))
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package task
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// importItemPattern matches a list item to turn into a task: "* note", "1. step",
+	// capturing the indentation, the marker, and the text
+	importItemPattern = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])(?:\s+(.*))?$`)
+
+	// importBoxPattern matches a checkbox at the start of a list item's text: "[X] text"
+	importBoxPattern = regexp.MustCompile(`^\[([xX ])\]\s*(.*)$`)
+)
+
+// LinesToTasks is LinesToTasksWith with DefaultOptions.
+func LinesToTasks(text string) string {
+	return LinesToTasksWith(text, DefaultOptions())
+}
+
+// LinesToTasksWith turns text pasted from another application, one item per line, into
+// tasks:
+//
+//   - plain text becomes "- [ ] text"
+//   - a list item ("- ", "* ", "1. ") keeps its marker and gets "[ ] ", or keeps the
+//     checkbox it has; a marker that is not a task marker in opts (see
+//     Options.BulletStyles) is replaced by "-"
+//   - tasks and headings are kept as they are
+//
+// Indentation and blank lines between items are kept; empty list items become blank
+// lines, and trailing spaces, "\r", and blank lines at the start and end are removed.
+func LinesToTasksWith(text string, opts Options) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		lines[i] = line
+		if line == "" || IsTask(line, opts) || headingPattern.MatchString(line) {
+			continue
+		}
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		box, body := "[ ]", strings.TrimLeft(line, " \t")
+		if m := importItemPattern.FindStringSubmatch(line); m != nil {
+			if m[3] == "" {
+				lines[i] = "" // an empty list item
+				continue
+			}
+			body = m[3]
+			if b := importBoxPattern.FindStringSubmatch(body); b != nil {
+				box, body = "["+strings.ToLower(b[1])+"]", b[2]
+			}
+			if item := strings.TrimRight(indent+m[2]+" "+box+" "+body, " "); IsTask(item, opts) {
+				lines[i] = item
+				continue
+			}
+		}
+		lines[i] = strings.TrimRight(indent+"- "+box+" "+body, " ")
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,42 @@
+package task
+
+import (
+	"testing"
+)
+
+// TestLinesToTasks verifies how pasted lines become tasks, with the default "-" bullet
+// and with "*" accepted as a task bullet.
+func TestLinesToTasks(t *testing.T) {
+	tests := []struct {
+		name   string
+		styles []string
+		text   string
+		want   string
+	}{
+		{"plain lines", nil, "Buy milk\nCall Bob", "- [ ] Buy milk\n- [ ] Call Bob"},
+		{"dash items", nil, "- Buy milk\n- Call Bob", "- [ ] Buy milk\n- [ ] Call Bob"},
+		{"tasks kept", nil, "- [ ] Open\n- [x] Done", "- [ ] Open\n- [x] Done"},
+		{"star item becomes dash", nil, "* Buy milk", "- [ ] Buy milk"},
+		{"star item kept when accepted", []string{"-", "*"}, "* Buy milk", "* [ ] Buy milk"},
+		{"checkbox of a star item kept", nil, "* [X] Done", "- [x] Done"},
+		{"numbered item", nil, "1. First step", "- [ ] First step"},
+		{"indentation kept", nil, "Project\n  - Step one\n\tStep two", "- [ ] Project\n  - [ ] Step one\n\t- [ ] Step two"},
+		{"inner blank lines kept", nil, "A\n\nB", "- [ ] A\n\n- [ ] B"},
+		{"outer blank lines and CRLF removed", nil, "\r\n\r\nA  \r\nB\r\n\r\n", "- [ ] A\n- [ ] B"},
+		{"headings kept", nil, "## Errands\nBuy milk", "## Errands\n- [ ] Buy milk"},
+		{"empty item", nil, "A\n-\nB", "- [ ] A\n\n- [ ] B"},
+		{"empty", nil, "\n\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			if tt.styles != nil {
+				opts.BulletStyles = tt.styles
+			}
+			if got := LinesToTasksWith(tt.text, opts); got != tt.want {
+				t.Errorf("LinesToTasksWith(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package task
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// urlPattern matches plain http(s) URLs written in a task
+var urlPattern = regexp.MustCompile(`https?://[^\s<>()\[\]]+`)
+
+// tagStartPattern matches a tag as written in a task: "@" at the start or after whitespace
+var tagStartPattern = regexp.MustCompile(`(^|\s)@[\w-]+(\([^)]*\))?`)
+
+// LinkPattern turns matches of Pattern into URLs by expanding Template
+// ("$1", "${1}", or "${name}" refer to submatches, as in regexp.Expand).
+type LinkPattern struct {
+	Pattern  *regexp.Regexp
+	Template string
+}
+
+// Link is a linkable token in a line: a plain URL or a match of a LinkPattern.
+type Link struct {
+	Start int // byte offset of the token in the line
+	End   int // byte offset just after the token
+	Text  string
+	URL   string
+}
+
+// CompileLinkPatterns compiles a regex to URL template map (the [ui.link_patterns] table).
+// Patterns are ordered by their source text, so overlapping matches resolve the same way
+// on every run. Returns an error naming the first invalid pattern or empty template.
+func CompileLinkPatterns(patterns map[string]string) ([]LinkPattern, error) {
+	sources := make([]string, 0, len(patterns))
+	for source := range patterns {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	compiled := make([]LinkPattern, 0, len(sources))
+	for _, source := range sources {
+		if source == "" {
+			return nil, fmt.Errorf("invalid link pattern: pattern is empty")
+		}
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid link pattern %q: %w", source, err)
+		}
+		if patterns[source] == "" {
+			return nil, fmt.Errorf("invalid link pattern %q: URL template is empty", source)
+		}
+		compiled = append(compiled, LinkPattern{Pattern: re, Template: patterns[source]})
+	}
+	return compiled, nil
+}
+
+// FindLinks returns the links in line, in order and without overlaps.
+// Plain URLs are always linked; patterns add links for tokens such as "JIRA-1234".
+// When matches overlap, the leftmost wins, then the longest, then plain URLs
+// before patterns (in CompileLinkPatterns order). Matches inside tags such as
+// "@due(...)" are skipped so tags keep their meaning.
+func FindLinks(line string, patterns []LinkPattern) []Link {
+	var candidates []Link
+	for _, loc := range urlPattern.FindAllStringIndex(line, -1) {
+		text := line[loc[0]:loc[1]]
+		candidates = append(candidates, Link{Start: loc[0], End: loc[1], Text: text, URL: text})
+	}
+	for _, p := range patterns {
+		for _, match := range p.Pattern.FindAllStringSubmatchIndex(line, -1) {
+			if match[0] == match[1] {
+				continue
+			}
+			url := string(p.Pattern.ExpandString(nil, p.Template, line, match))
+			candidates = append(candidates, Link{Start: match[0], End: match[1], Text: line[match[0]:match[1]], URL: url})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Start != candidates[j].Start {
+			return candidates[i].Start < candidates[j].Start
+		}
+		return candidates[i].End-candidates[i].Start > candidates[j].End-candidates[j].Start
+	})
+
+	tags := tagStartPattern.FindAllStringIndex(line, -1)
+	var links []Link
+	end := 0
+	for _, c := range candidates {
+		if c.Start < end || overlapsAny(c, tags) {
+			continue
+		}
+		links = append(links, c)
+		end = c.End
+	}
+	return links
+}
+
+// overlapsAny reports whether link overlaps any of the [start, end) spans.
+func overlapsAny(link Link, spans [][]int) bool {
+	for _, s := range spans {
+		if link.Start < s[1] && s[0] < link.End {
+			return true
+		}
+	}
+	return false
+}
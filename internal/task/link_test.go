@@ -0,0 +1,111 @@
+package task
+
+import (
+	"testing"
+)
+
+// TestCompileLinkPatterns verifies that invalid regexes and empty templates are rejected.
+func TestCompileLinkPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns map[string]string
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"valid", map[string]string{`JIRA-(\d+)`: "https://jira.example.com/browse/JIRA-$1"}, false},
+		{"invalid regex", map[string]string{`JIRA-(\d+`: "https://jira.example.com/browse/JIRA-$1"}, true},
+		{"empty pattern", map[string]string{"": "https://example.com"}, true},
+		{"empty template", map[string]string{`#(\d+)`: ""}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileLinkPatterns(tt.patterns)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompileLinkPatterns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestFindLinks verifies URL expansion, overlap resolution, and that tags are left alone.
+func TestFindLinks(t *testing.T) {
+	patterns, err := CompileLinkPatterns(map[string]string{
+		`JIRA-(\d+)`:          "https://jira.example.com/browse/JIRA-$1",
+		`#(\d+)`:              "https://github.com/org/repo/issues/$1",
+		`(?P<key>[A-Z]+)-\d+`: "https://tracker.example.com/${key}",
+		`\d+`:                 "https://example.com/n/$0",
+	})
+	if err != nil {
+		t.Fatalf("CompileLinkPatterns() error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		line     string
+		expected []Link
+	}{
+		{
+			name: "issue number",
+			line: "- [ ] Fix #567",
+			expected: []Link{
+				{Start: 10, End: 14, Text: "#567", URL: "https://github.com/org/repo/issues/567"},
+			},
+		},
+		{
+			name: "overlapping patterns: same start, first in source order wins",
+			line: "- [ ] JIRA-1234 review",
+			expected: []Link{
+				{Start: 6, End: 15, Text: "JIRA-1234", URL: "https://tracker.example.com/JIRA"},
+			},
+		},
+		{
+			name: "overlapping patterns: leftmost wins over inner match",
+			line: "see ABC-12",
+			expected: []Link{
+				{Start: 4, End: 10, Text: "ABC-12", URL: "https://tracker.example.com/ABC"},
+			},
+		},
+		{
+			name: "plain URL wins over patterns inside it",
+			line: "- [ ] Read https://example.com/issues/42",
+			expected: []Link{
+				{Start: 11, End: 40, Text: "https://example.com/issues/42", URL: "https://example.com/issues/42"},
+			},
+		},
+		{
+			name:     "tags are not linked",
+			line:     "- [ ] Ship @due(2026-01-20) @done(2026-01-21)",
+			expected: nil,
+		},
+		{
+			name: "tag next to a link",
+			line: "- [x] JIRA-9 @done(2026-01-21)",
+			expected: []Link{
+				{Start: 6, End: 12, Text: "JIRA-9", URL: "https://tracker.example.com/JIRA"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindLinks(tt.line, patterns)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("FindLinks() = %+v, want %+v", got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("link %d = %+v, want %+v", i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+// TestFindLinksWithoutPatterns verifies that plain URLs are linked with no patterns configured.
+func TestFindLinksWithoutPatterns(t *testing.T) {
+	got := FindLinks("- [ ] Check https://example.com/a?b=1 and #12", nil)
+	if len(got) != 1 || got[0].URL != "https://example.com/a?b=1" {
+		t.Errorf("FindLinks() = %+v, want only the plain URL", got)
+	}
+}
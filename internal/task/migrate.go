@@ -0,0 +1,93 @@
+package task
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MigrateFromEphe is the ephe format accepted by "ttt migrate --from".
+const MigrateFromEphe = "ephe"
+
+var (
+	// epheTaskPattern matches an ephe task with any list bullet: "* [X] text",
+	// capturing the indentation, the checkbox state, and the text
+	epheTaskPattern = regexp.MustCompile(`^(\s*)[-*+]\s*\[([xX ])\]\s*(.*)$`)
+
+	// epheDonePattern matches ephe's completion date: "✅ 2026-01-18" (capturing the date)
+	epheDonePattern = regexp.MustCompile(`\s*✅\x{FE0F}?\s*(\d{4}-\d{2}-\d{2})`)
+)
+
+// MigrateResult counts what MigrateEphe did, for the conversion report.
+type MigrateResult struct {
+	Tasks     int // task lines found
+	Dates     int // "✅ date" completion dates turned into @done(date)
+	Untouched int // non-blank lines left exactly as they were
+}
+
+// MigrateEphe converts a file written with ephe to the tasks.md format:
+//
+//   - "* [ ]" and "+ [ ]" tasks get the "-" bullet, and "[X]" becomes "[x]"
+//   - a "✅ 2026-01-18" completion date becomes a trailing @done(2026-01-18), and
+//     marks the task completed
+//   - list items are reindented TabWidth spaces per nesting level, whatever
+//     indentation ephe used
+//
+// Other lines, code blocks, block quotes, and front matter are kept as they are.
+func MigrateEphe(content string, opts Options) (string, MigrateResult) {
+	var result MigrateResult
+	parsed := ParseLines(content, opts)
+	lines := make([]string, len(parsed))
+
+	var indents []int // indentation of the enclosing list items, outermost first
+	for i, pl := range parsed {
+		line := pl.Content
+		lines[i] = line
+		if pl.FrontMatter || pl.InCodeBlock || quotePattern.MatchString(line) {
+			indents = nil
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		m := epheTaskPattern.FindStringSubmatch(line)
+		if m == nil && !listItemPattern.MatchString(line) {
+			if pl.Indent == 0 {
+				indents = nil // a heading or paragraph ends the list
+			}
+			continue
+		}
+
+		for len(indents) > 0 && indents[len(indents)-1] > pl.Indent {
+			indents = indents[:len(indents)-1]
+		}
+		if len(indents) == 0 || indents[len(indents)-1] < pl.Indent {
+			indents = append(indents, pl.Indent)
+		}
+		indent := strings.Repeat(" ", (len(indents)-1)*TabWidth)
+
+		if m == nil {
+			lines[i] = indent + strings.TrimLeft(line, " \t")
+		} else {
+			result.Tasks++
+			box, text := strings.ToLower(m[2]), m[3]
+			if d := epheDonePattern.FindStringSubmatch(text); d != nil {
+				result.Dates++
+				text = strings.TrimSpace(epheDonePattern.ReplaceAllString(text, ""))
+				box = "x"
+				if !doneTagPattern.MatchString(text) {
+					text += " @done(" + d[1] + ")"
+				}
+			}
+			lines[i] = strings.TrimRight(indent+"- ["+box+"] "+text, " ")
+		}
+	}
+
+	for i, pl := range parsed {
+		if strings.TrimSpace(pl.Content) != "" && lines[i] == pl.Content {
+			result.Untouched++
+		}
+	}
+
+	return strings.Join(lines, "\n"), result
+}
@@ -0,0 +1,58 @@
+package task
+
+import (
+	"testing"
+)
+
+// TestMigrateEpheFixture verifies the conversion of a realistic ephe export against
+// the expected tasks.md, and the counts of the conversion report.
+func TestMigrateEpheFixture(t *testing.T) {
+	content, err := LoadFile("testdata/ephe.md", DefaultOptions())
+	if err != nil {
+		t.Fatalf("LoadFile() fixture error: %v", err)
+	}
+	want, err := LoadFile("testdata/ephe_migrated.md", DefaultOptions())
+	if err != nil {
+		t.Fatalf("LoadFile() fixture error: %v", err)
+	}
+
+	got, result := MigrateEphe(content, DefaultOptions())
+	if got != want {
+		t.Errorf("MigrateEphe() =\n%s\nwant\n%s", got, want)
+	}
+	wantResult := MigrateResult{Tasks: 9, Dates: 4, Untouched: 8}
+	if result != wantResult {
+		t.Errorf("MigrateEphe() result = %+v, want %+v", result, wantResult)
+	}
+}
+
+// TestMigrateEphe verifies the individual conversion rules.
+func TestMigrateEphe(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"open task kept", "- [ ] Task", "- [ ] Task"},
+		{"star bullet", "* [ ] Task", "- [ ] Task"},
+		{"plus bullet and upper X", "+ [X] Task", "- [x] Task"},
+		{"done date", "- [x] Task ✅ 2026-01-18", "- [x] Task @done(2026-01-18)"},
+		{"done date before tags", "- [x] Task ✅ 2026-01-18 @work", "- [x] Task @work @done(2026-01-18)"},
+		{"done date completes an open task", "- [ ] Task ✅ 2026-01-18", "- [x] Task @done(2026-01-18)"},
+		{"existing @done wins", "- [x] Task @done(2026-01-10) ✅ 2026-01-18", "- [x] Task @done(2026-01-10)"},
+		{"four-space nesting", "- [ ] A\n    - [ ] B\n        - [ ] C", "- [ ] A\n  - [ ] B\n    - [ ] C"},
+		{"tab nesting", "- [ ] A\n\t- [ ] B", "- [ ] A\n  - [ ] B"},
+		{"back to a shallower level", "- [ ] A\n    - [ ] B\n  - [ ] C", "- [ ] A\n  - [ ] B\n  - [ ] C"},
+		{"indented list starts at the top level", "    - [ ] A\n    - [ ] B", "- [ ] A\n- [ ] B"},
+		{"code block kept", "```\n* [ ] Task ✅ 2026-01-18\n```", "```\n* [ ] Task ✅ 2026-01-18\n```"},
+		{"plain text kept", "Notes ✅ 2026-01-18", "Notes ✅ 2026-01-18"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := MigrateEphe(tt.content, DefaultOptions()); got != tt.want {
+				t.Errorf("MigrateEphe(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package task
+
+import "strings"
+
+// PartitionByCompletion returns lines with completed tasks moved below the open tasks
+// next to them. Each task moves together with the lines nested under it, and tasks
+// are only reordered among their siblings: a run of tasks at the same indentation,
+// which ends at a heading, a blank line, or any other line that is not a task.
+// Headings and other lines therefore keep their position, and subtasks are reordered
+// the same way below their parent. Open and completed tasks each keep their order.
+// Lines keep their LineNumber, so callers can map the new order back to the file.
+func PartitionByCompletion(lines []ParsedLine) []ParsedLine {
+	result := make([]ParsedLine, 0, len(lines))
+	for i := 0; i < len(lines); {
+		if !lines[i].IsTask {
+			result = append(result, lines[i])
+			i++
+			continue
+		}
+		indent := lines[i].Indent
+		var open, done []ParsedLine
+		for i < len(lines) && lines[i].IsTask && lines[i].Indent == indent {
+			end := i + 1
+			for end < len(lines) && strings.TrimSpace(lines[end].Content) != "" && lines[end].Indent > indent {
+				end++
+			}
+			block := append([]ParsedLine{lines[i]}, PartitionByCompletion(lines[i+1:end])...)
+			if lines[i].IsCompleted {
+				done = append(done, block...)
+			} else {
+				open = append(open, block...)
+			}
+			i = end
+		}
+		result = append(result, open...)
+		result = append(result, done...)
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestPartitionByCompletion verifies that completed tasks move below their open siblings
+// with their subtasks, and that headings and other lines stay in place.
+func TestPartitionByCompletion(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []int // line numbers in the new order
+	}{
+		{
+			name:    "nothing completed",
+			content: "# Tasks\n- [ ] a\n- [ ] b",
+			want:    []int{0, 1, 2},
+		},
+		{
+			name:    "completed below open, each in file order",
+			content: "- [x] a\n- [ ] b\n- [x] c\n- [ ] d",
+			want:    []int{1, 3, 0, 2},
+		},
+		{
+			name:    "headings keep their position",
+			content: "## Work\n- [x] a\n- [ ] b\n## Home\n- [x] c\n- [ ] d",
+			want:    []int{0, 2, 1, 3, 5, 4},
+		},
+		{
+			name:    "subtasks and notes move with their parent",
+			content: "- [x] a\n  - [ ] a1\n  note\n- [ ] b",
+			want:    []int{3, 0, 1, 2},
+		},
+		{
+			name:    "subtasks reordered below their parent",
+			content: "- [ ] a\n  - [x] a1\n  - [ ] a2\n- [ ] b",
+			want:    []int{0, 2, 1, 3},
+		},
+		{
+			name:    "blank lines and text end a run",
+			content: "- [x] a\n- [ ] b\n\n- [x] c\ntext\n- [x] d\n- [ ] e",
+			want:    []int{1, 0, 2, 3, 4, 6, 5},
+		},
+		{
+			name:    "code blocks ignored",
+			content: "- [ ] a\n```\n- [x] b\n- [ ] c\n```",
+			want:    []int{0, 1, 2, 3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, line := range PartitionByCompletion(ParseLines(tt.content, DefaultOptions())) {
+				got = append(got, line.LineNumber)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("PartitionByCompletion() order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestProcessContentCompletedToBottom verifies that ProcessContent moves completed tasks
+// down only with Options.CompletedToBottom and it tagged newly completed tasks.
+func TestProcessContentCompletedToBottom(t *testing.T) {
+	today := "@done(" + time.Now().Format("2006-01-02") + ")"
+	tests := []struct {
+		name    string
+		enabled bool
+		input   string
+		want    string
+	}{
+		{
+			name:  "off keeps the order",
+			input: "- [x] a\n- [ ] b",
+			want:  "- [x] a " + today + "\n- [ ] b",
+		},
+		{
+			name:    "newly completed task moves down",
+			enabled: true,
+			input:   "## Work\n- [x] a\n- [ ] b\n## Home\n- [ ] c",
+			want:    "## Work\n- [ ] b\n- [x] a " + today + "\n## Home\n- [ ] c",
+		},
+		{
+			name:    "nothing tagged, nothing moved",
+			enabled: true,
+			input:   "- [x] a @done(2026-01-30)\n- [ ] b",
+			want:    "- [x] a @done(2026-01-30)\n- [ ] b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.CompletedToBottom = tt.enabled
+
+			if got, _ := ProcessContent(tt.input, opts); got != tt.want {
+				t.Errorf("ProcessContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
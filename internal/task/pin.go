@@ -0,0 +1,93 @@
+package task
+
+import (
+	"regexp"
+	"strings"
+)
+
+// pinTagPattern matches a @pin tag as a whole word, with its surrounding whitespace.
+var pinTagPattern = regexp.MustCompile(`(^|\s)@pin(\s|$)`)
+
+// IsPinned is IsPinnedWith with DefaultOptions.
+func IsPinned(line string) bool {
+	return IsPinnedWith(line, DefaultOptions())
+}
+
+// IsPinnedWith reports whether line is a task tagged @pin.
+func IsPinnedWith(line string, opts Options) bool {
+	return IsTask(line, opts) && pinTagPattern.MatchString(line)
+}
+
+// TogglePin adds a @pin tag to a task line, or removes it if the task is pinned.
+// A line that is not a task is returned unchanged.
+func TogglePin(line string, opts Options) string {
+	if !IsTask(line, opts) {
+		return line
+	}
+	if pinTagPattern.MatchString(line) {
+		return strings.TrimRight(pinTagPattern.ReplaceAllString(line, "$1"), " \t")
+	}
+	return strings.TrimRight(line, " \t") + " @pin"
+}
+
+// PinnedFirst is PinnedFirstWith with DefaultOptions.
+func PinnedFirst(lines []ParsedLine) []ParsedLine {
+	return PinnedFirstWith(lines, DefaultOptions())
+}
+
+// PinnedFirstWith returns lines with every pinned task (see IsPinned) moved to the top,
+// together with the lines nested under it, keeping the pinned tasks in file order.
+// They are placed after the front matter, blank lines, and "# " title at the top,
+// and everything else keeps its order. A pinned task nested under another pinned
+// task moves with its parent. Lines keep their LineNumber, so callers can map
+// the new order back to the file.
+func PinnedFirstWith(lines []ParsedLine, opts Options) []ParsedLine {
+	var pinned, rest []ParsedLine
+	for i := 0; i < len(lines); {
+		if !lines[i].IsTask || !IsPinnedWith(lines[i].Content, opts) {
+			rest = append(rest, lines[i])
+			i++
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end].Content) != "" && lines[end].Indent > lines[i].Indent {
+			end++
+		}
+		pinned = append(pinned, lines[i:end]...)
+		i = end
+	}
+	if len(pinned) == 0 {
+		return lines
+	}
+
+	top := 0
+	for top < len(rest) && isPreamble(rest[top]) {
+		top++
+	}
+	result := make([]ParsedLine, 0, len(lines))
+	result = append(result, rest[:top]...)
+	result = append(result, pinned...)
+	return append(result, rest[top:]...)
+}
+
+// PinnedFirstContent applies PinnedFirst to content, for writing the new order back
+// to the file. Returns content unchanged when nothing is pinned.
+func PinnedFirstContent(content string, opts Options) string {
+	trailing := strings.HasSuffix(content, "\n")
+	lines := ParseLines(strings.TrimSuffix(content, "\n"), opts)
+	result := ReconstructContent(PinnedFirstWith(lines, opts))
+	if trailing {
+		result += "\n"
+	}
+	return result
+}
+
+// isPreamble reports whether line belongs to the top of a file that pinned tasks
+// are placed below: front matter, a blank line, or a "# " heading.
+func isPreamble(line ParsedLine) bool {
+	if line.FrontMatter || strings.TrimSpace(line.Content) == "" {
+		return true
+	}
+	level, _, ok := headingLevel(line)
+	return ok && level == 1
+}
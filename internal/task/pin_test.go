@@ -0,0 +1,112 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestIsPinned verifies that only tasks with a whole @pin tag are pinned.
+func TestIsPinned(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"- [ ] Renew passport @pin", true},
+		{"- [x] Done but pinned @pin @done(2026-01-20)", true},
+		{"  - [ ] @pin nested", true},
+		{"- [ ] Not pinned", false},
+		{"- [ ] Pinned later @pinned", false},
+		{"- [ ] mail@pin.example", false},
+		{"Note @pin", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPinned(tt.line); got != tt.want {
+			t.Errorf("IsPinnedWith(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+// TestTogglePin verifies adding and removing the @pin tag.
+func TestTogglePin(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"- [ ] Renew passport", "- [ ] Renew passport @pin"},
+		{"- [ ] Renew passport ", "- [ ] Renew passport @pin"},
+		{"- [ ] Renew passport @pin", "- [ ] Renew passport"},
+		{"- [ ] Renew @pin @due(2026-02-01)", "- [ ] Renew @due(2026-02-01)"},
+		{"Note", "Note"},
+	}
+
+	for _, tt := range tests {
+		if got := TogglePin(tt.line, DefaultOptions()); got != tt.want {
+			t.Errorf("TogglePin(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+// TestPinnedFirst verifies that pinned tasks and their subtasks move to the top.
+func TestPinnedFirst(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []int // line numbers in the new order
+	}{
+		{
+			name:    "nothing pinned",
+			content: "# Tasks\n- [ ] a\n- [ ] b",
+			want:    []int{0, 1, 2},
+		},
+		{
+			name:    "pinned below the title",
+			content: "# Tasks\n\n## Work\n- [ ] a\n- [ ] b @pin\n## Home\n- [ ] c @pin",
+			want:    []int{0, 1, 4, 6, 2, 3, 5},
+		},
+		{
+			name:    "subtasks move with the pinned task",
+			content: "- [ ] a\n- [ ] b @pin\n  - [ ] b1\n  note\n- [ ] c",
+			want:    []int{1, 2, 3, 0, 4},
+		},
+		{
+			name:    "pinned subtask moves alone",
+			content: "- [ ] a\n  - [ ] a1 @pin\n  - [ ] a2",
+			want:    []int{1, 0, 2},
+		},
+		{
+			name:    "after front matter",
+			content: "---\ntitle: x\n---\n- [ ] a\n- [ ] b @pin",
+			want:    []int{0, 1, 2, 4, 3},
+		},
+		{
+			name:    "code blocks ignored",
+			content: "- [ ] a\n```\n- [ ] b @pin\n```",
+			want:    []int{0, 1, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, line := range PinnedFirst(ParseLines(tt.content, DefaultOptions())) {
+				got = append(got, line.LineNumber)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("PinnedFirstWith() order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPinnedFirstContent verifies writing the pinned order back, keeping the trailing newline.
+func TestPinnedFirstContent(t *testing.T) {
+	content := "# Tasks\n- [ ] a\n- [ ] b @pin\n"
+	want := "# Tasks\n- [ ] b @pin\n- [ ] a\n"
+	if got := PinnedFirstContent(content, DefaultOptions()); got != want {
+		t.Errorf("PinnedFirstContent() = %q, want %q", got, want)
+	}
+	if got := PinnedFirstContent("- [ ] a\n", DefaultOptions()); got != "- [ ] a\n" {
+		t.Errorf("PinnedFirstContent() changed content without pins: %q", got)
+	}
+}
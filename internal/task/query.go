@@ -0,0 +1,306 @@
+package task
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dueTagPattern matches @due(...) with its value: a date (YYYY-MM-DD) or a weekday
+// (see ParseDueDate)
+var dueTagPattern = regexp.MustCompile(`@due\(([^()\s]+)\)`)
+
+// headingPattern matches Markdown ATX headings: "# Title", "## Title", ...
+var headingPattern = regexp.MustCompile(`^\s*#{1,6}\s+(.*)$`)
+
+// Query is a compiled task filter. All terms must match (AND).
+//
+// Supported terms:
+//   - @tag            task contains the tag (e.g. @waiting, also matches @waiting(bob))
+//   - section:<name>  task is under a heading containing <name> (case-insensitive)
+//   - due:today       task has @due(YYYY-MM-DD) equal to today
+//   - due:week        task is due in the current week (Monday to Sunday)
+//   - due:overdue     task is due before today
+//   - done:today      task has @done(YYYY-MM-DD) equal to today
+//   - done:week       task was completed in the current week (Monday to Sunday)
+//   - is:open         task is not completed
+//   - is:done         task is completed
+//   - "some words"    task text contains the phrase (case-insensitive)
+//   - word            task text contains the word (case-insensitive)
+type Query struct {
+	source  string
+	terms   []queryTerm
+	parents bool    // FilterLines keeps the parent tasks of matches (see WithParents)
+	opts    Options // how FilterLines reads tasks
+}
+
+// DoneTodayQuery is the query of the TUI's T quick filter: the tasks completed
+// today, shown with the tasks they are nested under (see WithParents).
+const DoneTodayQuery = "done:today"
+
+// queryTerm matches a single task line, given the heading it appears under.
+type queryTerm func(line ParsedLine, section string, now time.Time) bool
+
+// CompileQuery parses a filter query, whose FilterLines reads tasks as opts sets.
+// Returns an error for empty queries, unknown "key:value" terms, and unterminated
+// quotes.
+func CompileQuery(query string, opts Options) (*Query, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	q := &Query{source: query, opts: opts}
+	for _, tok := range tokens {
+		term, err := compileTerm(tok)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query %q: %w", query, err)
+		}
+		q.terms = append(q.terms, term)
+	}
+	return q, nil
+}
+
+// WithParents returns a copy of q whose FilterLines also keeps the parent tasks of
+// each matching subtask, up to the top level, so matches are shown in context. A
+// parent is kept once, above its first match, whether it matches itself or not.
+func (q *Query) WithParents() *Query {
+	c := *q
+	c.parents = true
+	return &c
+}
+
+// String returns the query source text.
+func (q *Query) String() string {
+	return q.source
+}
+
+// queryToken is a query word; quoted phrases are always plain text.
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeQuery splits a query on whitespace, keeping "double quoted" phrases together.
+func tokenizeQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	var current strings.Builder
+	inQuote := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, queryToken{text: current.String()})
+			current.Reset()
+		}
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"' && inQuote:
+			tokens = append(tokens, queryToken{text: current.String(), quoted: true})
+			current.Reset()
+			inQuote = false
+		case r == '"':
+			flush()
+			inQuote = true
+		case !inQuote && (r == ' ' || r == '\t'):
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("invalid query %q: unterminated quote", query)
+	}
+	flush()
+
+	return tokens, nil
+}
+
+// compileTerm converts a token into a matcher.
+func compileTerm(tok queryToken) (queryTerm, error) {
+	text := tok.text
+	if tok.quoted {
+		return textTerm(text), nil
+	}
+
+	if strings.HasPrefix(text, "@") && len(text) > 1 {
+		tag := regexp.MustCompile(regexp.QuoteMeta(text) + `(\(|\s|$)`)
+		return func(line ParsedLine, _ string, _ time.Time) bool {
+			return tag.MatchString(line.Content)
+		}, nil
+	}
+
+	key, value, found := strings.Cut(text, ":")
+	if !found || key == "" || value == "" {
+		return textTerm(text), nil
+	}
+
+	switch key {
+	case "section":
+		name := strings.ToLower(value)
+		return func(_ ParsedLine, section string, _ time.Time) bool {
+			return strings.Contains(strings.ToLower(section), name)
+		}, nil
+	case "due":
+		return compileDueTerm(value)
+	case "done":
+		return compileDoneTerm(value)
+	case "is":
+		switch value {
+		case "open":
+			return func(line ParsedLine, _ string, _ time.Time) bool { return !line.IsCompleted }, nil
+		case "done":
+			return func(line ParsedLine, _ string, _ time.Time) bool { return line.IsCompleted }, nil
+		}
+		return nil, fmt.Errorf("unknown value %q for is: (use open or done)", value)
+	}
+
+	return nil, fmt.Errorf("unknown term %q (use @tag, section:, due:, done:, is:, or plain words)", text)
+}
+
+// compileDueTerm builds a matcher for due:today, due:week, and due:overdue.
+func compileDueTerm(value string) (queryTerm, error) {
+	var inRange func(due, today time.Time) bool
+
+	switch value {
+	case "today":
+		inRange = func(due, today time.Time) bool { return due.Equal(today) }
+	case "week":
+		inRange = func(due, today time.Time) bool {
+			start := weekStart(today)
+			end := start.AddDate(0, 0, 6)
+			return !due.Before(start) && !due.After(end)
+		}
+	case "overdue":
+		inRange = func(due, today time.Time) bool { return due.Before(today) }
+	default:
+		return nil, fmt.Errorf("unknown value %q for due: (use today, week, or overdue)", value)
+	}
+
+	return func(line ParsedLine, _ string, now time.Time) bool {
+		due, ok := DueDate(line.Content, now)
+		if !ok {
+			return false
+		}
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return inRange(due, today)
+	}, nil
+}
+
+// compileDoneTerm builds a matcher for done:today and done:week. The date comes
+// from the @done tag, so a checked task without one does not match.
+func compileDoneTerm(value string) (queryTerm, error) {
+	var inRange func(done, today time.Time) bool
+
+	switch value {
+	case "today":
+		inRange = func(done, today time.Time) bool { return done.Equal(today) }
+	case "week":
+		inRange = func(done, today time.Time) bool {
+			return !done.Before(weekStart(today)) && !done.After(today)
+		}
+	default:
+		return nil, fmt.Errorf("unknown value %q for done: (use today or week)", value)
+	}
+
+	return func(line ParsedLine, _ string, now time.Time) bool {
+		done, ok := ParseDoneDate(line.Content)
+		return ok && line.IsCompleted && inRange(done, calendarDay(now))
+	}, nil
+}
+
+// weekStart returns the Monday of the week containing day.
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// textTerm matches task lines containing text, case-insensitively.
+func textTerm(text string) queryTerm {
+	lower := strings.ToLower(text)
+	return func(line ParsedLine, _ string, _ time.Time) bool {
+		return strings.Contains(strings.ToLower(line.Content), lower)
+	}
+}
+
+// Match reports whether a task line under the given section heading matches every term.
+// Non-task lines never match.
+func (q *Query) Match(line ParsedLine, section string, now time.Time) bool {
+	if !line.IsTask {
+		return false
+	}
+	for _, term := range q.terms {
+		if !term(line, section, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns only the task lines of content that match the query.
+// Each heading that has matching tasks beneath it is kept once, above them, for context.
+func (q *Query) Filter(content string, now time.Time) string {
+	lines := strings.Split(content, "\n")
+	var result []string
+	for _, n := range q.FilterLines(content, now) {
+		result = append(result, lines[n])
+	}
+	return strings.Join(result, "\n")
+}
+
+// FilterLines returns the 0-indexed line numbers of the lines Filter keeps, in order.
+func (q *Query) FilterLines(content string, now time.Time) []int {
+	var result []int
+	section := ""
+	sectionLine := -1
+	sectionShown := false
+
+	// Tasks the current task is nested under, outermost first (see WithParents)
+	type parent struct {
+		line, indent int
+		shown        bool
+	}
+	var parents []parent
+
+	for _, line := range ParseLines(content, q.opts) {
+		if line.FrontMatter {
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(line.Content); m != nil && !line.IsTask {
+			section = strings.TrimSpace(m[1])
+			sectionLine = line.LineNumber
+			sectionShown = false
+			parents = nil
+			continue
+		}
+		if line.IsTask {
+			for len(parents) > 0 && parents[len(parents)-1].indent >= line.Indent {
+				parents = parents[:len(parents)-1]
+			}
+		}
+		matched := q.Match(line, section, now)
+		if matched {
+			if sectionLine >= 0 && !sectionShown {
+				result = append(result, sectionLine)
+				sectionShown = true
+			}
+			for i := range parents {
+				if q.parents && !parents[i].shown {
+					result = append(result, parents[i].line)
+					parents[i].shown = true
+				}
+			}
+			result = append(result, line.LineNumber)
+		}
+		if line.IsTask {
+			parents = append(parents, parent{line: line.LineNumber, indent: line.Indent, shown: matched})
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,188 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCompileQueryErrors verifies that CompileQuery() rejects empty queries,
+// unknown key:value terms, bad values, and unterminated quotes.
+func TestCompileQueryErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"   ",
+		"priority:high",
+		"due:someday",
+		"is:maybe",
+		"done:yesterday",
+		`"unterminated`,
+	}
+
+	for _, query := range tests {
+		t.Run(query, func(t *testing.T) {
+			if _, err := CompileQuery(query, DefaultOptions()); err == nil {
+				t.Errorf("CompileQuery(%q) should return error", query)
+			}
+		})
+	}
+}
+
+// TestQueryString verifies that String() returns the original query text.
+func TestQueryString(t *testing.T) {
+	q, err := CompileQuery("@waiting is:open", DefaultOptions())
+	if err != nil {
+		t.Fatalf("CompileQuery() error: %v", err)
+	}
+	if q.String() != "@waiting is:open" {
+		t.Errorf("String() = %q, want %q", q.String(), "@waiting is:open")
+	}
+}
+
+// TestQueryMatch verifies each supported term against single task lines.
+// Now is Wednesday 2026-01-21, so the current week is 2026-01-19 to 2026-01-25.
+func TestQueryMatch(t *testing.T) {
+	now := time.Date(2026, 1, 21, 10, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		query    string
+		line     string
+		section  string
+		expected bool
+	}{
+		{"tag present", "@waiting", "- [ ] Reply @waiting", "", true},
+		{"tag with argument", "@waiting", "- [ ] Reply @waiting(bob)", "", true},
+		{"tag prefix does not match", "@wait", "- [ ] Reply @waiting", "", false},
+		{"tag absent", "@waiting", "- [ ] Reply", "", false},
+		{"section match", "section:work", "- [ ] Review", "Work", true},
+		{"section partial match", "section:work", "- [ ] Review", "Work projects", true},
+		{"section mismatch", "section:work", "- [ ] Review", "Home", false},
+		{"due today", "due:today", "- [ ] Pay @due(2026-01-21)", "", true},
+		{"due today mismatch", "due:today", "- [ ] Pay @due(2026-01-22)", "", false},
+		{"due this week monday", "due:week", "- [ ] Pay @due(2026-01-19)", "", true},
+		{"due this week sunday", "due:week", "- [ ] Pay @due(2026-01-25)", "", true},
+		{"due next week", "due:week", "- [ ] Pay @due(2026-01-26)", "", false},
+		{"due weekday today", "due:today", "- [ ] Pay @due(wed)", "", true},
+		{"due weekday this week", "due:week", "- [ ] Pay @due(金)", "", true},
+		{"due weekday never overdue", "due:overdue", "- [ ] Pay @due(mon)", "", false},
+		{"overdue", "due:overdue", "- [ ] Pay @due(2026-01-20)", "", true},
+		{"not overdue today", "due:overdue", "- [ ] Pay @due(2026-01-21)", "", false},
+		{"no due tag", "due:today", "- [ ] Pay", "", false},
+		{"is open", "is:open", "- [ ] Pay", "", true},
+		{"is open completed", "is:open", "- [x] Pay", "", false},
+		{"is done", "is:done", "- [x] Pay", "", true},
+		{"done today", "done:today", "- [x] Pay @done(2026-01-21)", "", true},
+		{"done yesterday", "done:today", "- [x] Pay @done(2026-01-20)", "", false},
+		{"done without tag", "done:today", "- [x] Pay", "", false},
+		{"reopened with done tag", "done:today", "- [ ] Pay @done(2026-01-21)", "", false},
+		{"done this week monday", "done:week", "- [x] Pay @done(2026-01-19)", "", true},
+		{"done last week", "done:week", "- [x] Pay @done(2026-01-18)", "", false},
+		{"plain word case-insensitive", "milk", "- [ ] Buy MILK", "", true},
+		{"quoted phrase", `"buy milk"`, "- [ ] Buy milk today", "", true},
+		{"quoted phrase order matters", `"milk buy"`, "- [ ] Buy milk today", "", false},
+		{"all terms must match", "@waiting is:open", "- [x] Reply @waiting", "", false},
+		{"non-task never matches", "notes", "Some notes", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := CompileQuery(tt.query, DefaultOptions())
+			if err != nil {
+				t.Fatalf("CompileQuery(%q) error: %v", tt.query, err)
+			}
+			line := ParseLines(tt.line, DefaultOptions())[0]
+			if got := q.Match(line, tt.section, now); got != tt.expected {
+				t.Errorf("Match(%q, section %q) = %v, want %v", tt.line, tt.section, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestQueryFilter verifies that Filter() keeps matching task lines and the headings
+// they appear under, and drops everything else.
+func TestQueryFilter(t *testing.T) {
+	content := strings.Join([]string{
+		"# Work",
+		"",
+		"- [ ] Review PR @waiting",
+		"- [ ] Write docs",
+		"",
+		"# Home",
+		"- [ ] Buy milk",
+		"- [ ] Call plumber @waiting",
+		"",
+		"# Someday",
+		"- [ ] Learn piano",
+	}, "\n")
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"tag across sections", "@waiting", "# Work\n- [ ] Review PR @waiting\n# Home\n- [ ] Call plumber @waiting"},
+		{"section only", "section:home", "# Home\n- [ ] Buy milk\n- [ ] Call plumber @waiting"},
+		{"no matches", "@nothing", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := CompileQuery(tt.query, DefaultOptions())
+			if err != nil {
+				t.Fatalf("CompileQuery(%q) error: %v", tt.query, err)
+			}
+			if got := q.Filter(content, time.Now()); got != tt.expected {
+				t.Errorf("Filter(%q) = %q, want %q", tt.query, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestQueryFilterWithParents verifies that WithParents keeps the parent tasks of
+// matching subtasks, each once and above its first match, and that the plain query
+// does not.
+func TestQueryFilterWithParents(t *testing.T) {
+	now := time.Date(2026, 1, 21, 10, 0, 0, 0, time.Local)
+	content := strings.Join([]string{
+		"# Work",
+		"- [ ] Release",
+		"  - [x] Changelog @done(2026-01-21)",
+		"  - [ ] Tag",
+		"    - [x] Sign tag @done(2026-01-21)",
+		"  - [x] Announce @done(2026-01-21)",
+		"- [x] Standup @done(2026-01-21)",
+		"  - [x] Notes @done(2026-01-21)",
+		"- [ ] Other",
+		"  - [x] Old step @done(2026-01-20)",
+		"# Home",
+		"  - [x] Orphan @done(2026-01-21)",
+	}, "\n")
+
+	tests := []struct {
+		name     string
+		parents  bool
+		expected []int
+	}{
+		{"matches only", false, []int{0, 2, 4, 5, 6, 7, 10, 11}},
+		// Release and Tag once each; Standup matches itself and is not repeated for Notes
+		{"with parents", true, []int{0, 1, 2, 3, 4, 5, 6, 7, 10, 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := CompileQuery(DoneTodayQuery, DefaultOptions())
+			if err != nil {
+				t.Fatalf("CompileQuery() error: %v", err)
+			}
+			if tt.parents {
+				q = q.WithParents()
+			}
+			got := q.FilterLines(content, now)
+			if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Errorf("FilterLines() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
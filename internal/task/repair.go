@@ -0,0 +1,158 @@
+package task
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// archiveHeaderPattern matches a level-2 heading: "## <text>"
+	archiveHeaderPattern = regexp.MustCompile(`^##\s+(.*?)\s*$`)
+
+	// standardHeaderPattern matches the canonical archive header: "## YYYY-MM-DD"
+	standardHeaderPattern = regexp.MustCompile(`^## \d{4}-\d{2}-\d{2}$`)
+
+	// looseDatePattern matches dates like 2026/1/18, 2026-1-18, 2026.01.18, or 2026年1月18日
+	looseDatePattern = regexp.MustCompile(`^(\d{4})\s*[-/.年]\s*(\d{1,2})\s*[-/.月]\s*(\d{1,2})\s*日?$`)
+
+	// brokenTaskPattern matches near-miss checkboxes: stray spaces inside the brackets
+	// ("[x ]", "[]"), full-width brackets and spaces, check marks instead of "x",
+	// any bullet or none. Groups: indent, mark, text.
+	brokenTaskPattern = regexp.MustCompile(`^([ \t]*)(?:[-*+][ \t\x{3000}]*)?[\[［][ \t\x{3000}]*([xX✓✔]?)[ \t\x{3000}]*[\]］][ \t\x{3000}]*(.*)$`)
+)
+
+// RepairArchiveHeaders rewrites non-standard archive date headers to "## YYYY-MM-DD".
+// Recognized forms include "## 2026/1/18", "## 2026-1-18", "## 2026.01.18",
+// and "## 2026年1月18日". Only header lines change, so every task stays under
+// the same header. Headers that cannot be parsed are left as-is
+// (see InvalidArchiveHeaders). Returns the repaired content and the count of
+// headers rewritten.
+func RepairArchiveHeaders(content string) (string, int) {
+	lines := strings.Split(content, "\n")
+	count := 0
+
+	for i, line := range lines {
+		if standardHeaderPattern.MatchString(line) && validHeaderDate(line) {
+			continue
+		}
+		date, ok := parseArchiveHeader(line)
+		if !ok {
+			continue
+		}
+		repaired := "## " + date.Format("2006-01-02")
+		if repaired != line {
+			lines[i] = repaired
+			count++
+		}
+	}
+
+	return strings.Join(lines, "\n"), count
+}
+
+// InvalidArchiveHeaders returns the level-2 headers whose text is not a valid
+// date in any form RepairArchiveHeaders understands.
+func InvalidArchiveHeaders(content string) []ParsedLine {
+	var invalid []ParsedLine
+	for i, line := range strings.Split(content, "\n") {
+		if !archiveHeaderPattern.MatchString(line) {
+			continue
+		}
+		if _, ok := parseArchiveHeader(line); !ok {
+			invalid = append(invalid, ParsedLine{LineNumber: i, Content: line})
+		}
+	}
+	return invalid
+}
+
+// LooksLikeBrokenTask is LooksLikeBrokenTaskWith with DefaultOptions.
+func LooksLikeBrokenTask(line string) (suggestion string, ok bool) {
+	return LooksLikeBrokenTaskWith(line, DefaultOptions())
+}
+
+// LooksLikeBrokenTaskWith reports whether line is meant as a task but is not one:
+// "- [x ] task", "- [] task", "[ ] task", "- ［x］ task", "- [✓] task", or a bullet
+// that [tasks] bullet_styles does not accept. suggestion is the line written as a
+// task, "- [ ] text" or "- [x] text" with the indentation kept. Lines that already
+// are tasks, and Markdown links such as "[x](url)", are not broken.
+func LooksLikeBrokenTaskWith(line string, opts Options) (suggestion string, ok bool) {
+	if IsTask(line, opts) {
+		return "", false
+	}
+	m := brokenTaskPattern.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+	text := strings.TrimSpace(m[3])
+	if text == "" || strings.HasPrefix(text, "(") || strings.HasPrefix(text, ":") {
+		return "", false
+	}
+
+	mark := " "
+	switch m[2] {
+	case "":
+	case "X":
+		mark = "X"
+	default:
+		mark = "x"
+	}
+	return m[1] + "- [" + mark + "] " + text, true
+}
+
+// BrokenTasks returns the lines of content that LooksLikeBrokenTask flags, skipping
+// code blocks and front matter.
+func BrokenTasks(content string, opts Options) []ParsedLine {
+	var broken []ParsedLine
+	for _, line := range ParseLines(content, opts) {
+		if line.InCodeBlock || line.FrontMatter {
+			continue
+		}
+		if _, ok := LooksLikeBrokenTaskWith(line.Content, opts); ok {
+			broken = append(broken, line)
+		}
+	}
+	return broken
+}
+
+// RepairBrokenTasks rewrites every line BrokenTasks returns to its suggested form.
+// Returns the repaired content and the count of lines rewritten.
+func RepairBrokenTasks(content string, opts Options) (string, int) {
+	broken := BrokenTasks(content, opts)
+	if len(broken) == 0 {
+		return content, 0
+	}
+	lines := strings.Split(content, "\n")
+	for _, line := range broken {
+		lines[line.LineNumber], _ = LooksLikeBrokenTaskWith(line.Content, opts)
+	}
+	return strings.Join(lines, "\n"), len(broken)
+}
+
+// parseArchiveHeader parses the date from a level-2 heading in any recognized form.
+func parseArchiveHeader(line string) (time.Time, bool) {
+	m := archiveHeaderPattern.FindStringSubmatch(line)
+	if m == nil {
+		return time.Time{}, false
+	}
+	d := looseDatePattern.FindStringSubmatch(m[1])
+	if d == nil {
+		return time.Time{}, false
+	}
+
+	year, _ := strconv.Atoi(d[1])
+	month, _ := strconv.Atoi(d[2])
+	day, _ := strconv.Atoi(d[3])
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	// Reject dates that time.Date normalized (e.g. 2026-02-30 → 2026-03-02)
+	if date.Year() != year || int(date.Month()) != month || date.Day() != day {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// validHeaderDate reports whether a standard-form header holds a real calendar date.
+func validHeaderDate(line string) bool {
+	_, err := time.Parse("2006-01-02", strings.TrimPrefix(line, "## "))
+	return err == nil
+}
@@ -0,0 +1,160 @@
+package task
+
+import (
+	"testing"
+)
+
+// TestRepairArchiveHeaders verifies that RepairArchiveHeaders() normalizes date headers
+// to "## YYYY-MM-DD" while leaving tasks under the same header and unparseable headers as-is.
+func TestRepairArchiveHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expected      string
+		expectedCount int
+	}{
+		{
+			"slash separated",
+			"## 2026/1/18\n\n- [x] Task @done(2026-01-18)\n",
+			"## 2026-01-18\n\n- [x] Task @done(2026-01-18)\n",
+			1,
+		},
+		{
+			"single digit dash",
+			"## 2026-1-8\n- [x] Task\n",
+			"## 2026-01-08\n- [x] Task\n",
+			1,
+		},
+		{
+			"dot separated with trailing space",
+			"## 2026.01.18  \n- [x] Task\n",
+			"## 2026-01-18\n- [x] Task\n",
+			1,
+		},
+		{
+			"japanese format",
+			"## 2026年1月18日\n- [x] Task\n",
+			"## 2026-01-18\n- [x] Task\n",
+			1,
+		},
+		{
+			"extra spaces after hashes",
+			"##   2026-01-18\n- [x] Task\n",
+			"## 2026-01-18\n- [x] Task\n",
+			1,
+		},
+		{
+			"standard header unchanged",
+			"## 2026-01-18\n- [x] Task\n",
+			"## 2026-01-18\n- [x] Task\n",
+			0,
+		},
+		{
+			"unparseable header left alone",
+			"## Someday\n- [x] Task\n## 2026-02-30\n",
+			"## Someday\n- [x] Task\n## 2026-02-30\n",
+			0,
+		},
+		{
+			"multiple sections keep their tasks",
+			"## 2026/1/19\n- [x] B\n\n## 2026/1/18\n- [x] A\n",
+			"## 2026-01-19\n- [x] B\n\n## 2026-01-18\n- [x] A\n",
+			2,
+		},
+		{
+			"task lines are never rewritten",
+			"## 2026-01-18\n- [x] Paid 2026/1/18 invoice\n",
+			"## 2026-01-18\n- [x] Paid 2026/1/18 invoice\n",
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, count := RepairArchiveHeaders(tt.content)
+			if result != tt.expected {
+				t.Errorf("RepairArchiveHeaders() = %q, want %q", result, tt.expected)
+			}
+			if count != tt.expectedCount {
+				t.Errorf("RepairArchiveHeaders() count = %d, want %d", count, tt.expectedCount)
+			}
+		})
+	}
+}
+
+// TestInvalidArchiveHeaders verifies that InvalidArchiveHeaders() reports level-2 headers
+// that are not valid dates, with their 0-indexed line numbers.
+func TestInvalidArchiveHeaders(t *testing.T) {
+	content := "## 2026-01-18\n- [x] A\n## Someday\n- [x] B\n## 2026/2/30\n### 2026 notes\n## 2026/1/17\n"
+
+	invalid := InvalidArchiveHeaders(content)
+
+	if len(invalid) != 2 {
+		t.Fatalf("InvalidArchiveHeaders() returned %d headers, want 2: %+v", len(invalid), invalid)
+	}
+	if invalid[0].LineNumber != 2 || invalid[0].Content != "## Someday" {
+		t.Errorf("invalid[0] = %+v, want line 2 \"## Someday\"", invalid[0])
+	}
+	if invalid[1].LineNumber != 4 || invalid[1].Content != "## 2026/2/30" {
+		t.Errorf("invalid[1] = %+v, want line 4 \"## 2026/2/30\"", invalid[1])
+	}
+}
+
+// TestLooksLikeBrokenTask verifies near-miss checkbox lines and their suggested fixes.
+func TestLooksLikeBrokenTask(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		suggestion string
+		ok         bool
+	}{
+		{"space after mark", "- [x ] Buy milk", "- [x] Buy milk", true},
+		{"space before mark", "- [ x] Buy milk", "- [x] Buy milk", true},
+		{"empty brackets", "- [] Buy milk", "- [ ] Buy milk", true},
+		{"two spaces", "- [  ] Buy milk", "- [ ] Buy milk", true},
+		{"no bullet", "[ ] Buy milk", "- [ ] Buy milk", true},
+		{"no bullet, done", "[x] Buy milk @done(2026-01-20)", "- [x] Buy milk @done(2026-01-20)", true},
+		{"indented", "    - [x ] Child", "    - [x] Child", true},
+		{"full-width brackets", "- ［x］ 牛乳を買う", "- [x] 牛乳を買う", true},
+		{"full-width space", "- [　] 牛乳を買う", "- [ ] 牛乳を買う", true},
+		{"check mark", "- [✓] Buy milk", "- [x] Buy milk", true},
+		{"capital X kept", "- [X ] Buy milk", "- [X] Buy milk", true},
+		{"bullet not in bullet_styles", "* [ ] Buy milk", "- [ ] Buy milk", true},
+		{"valid task", "- [x] Buy milk", "", false},
+		{"valid without space", "-[x] Buy milk", "", false},
+		{"no text", "- [ x]", "", false},
+		{"markdown link", "[x](https://example.com)", "", false},
+		{"link reference", "[x]: https://example.com", "", false},
+		{"other bracket text", "- [WIP] Buy milk", "", false},
+		{"plain text", "Buy milk", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestion, ok := LooksLikeBrokenTask(tt.line)
+			if ok != tt.ok || suggestion != tt.suggestion {
+				t.Errorf("LooksLikeBrokenTaskWith(%q) = %q, %v; want %q, %v", tt.line, suggestion, ok, tt.suggestion, tt.ok)
+			}
+		})
+	}
+}
+
+// TestRepairBrokenTasks verifies that only broken lines outside code blocks are rewritten.
+func TestRepairBrokenTasks(t *testing.T) {
+	content := "# Tasks\n- [x ] Parent\n  - [] Child\n```\n- [x ] example\n```\n- [ ] Fine\n"
+
+	broken := BrokenTasks(content, DefaultOptions())
+	if len(broken) != 2 || broken[0].LineNumber != 1 || broken[1].LineNumber != 2 {
+		t.Fatalf("BrokenTasks() = %+v, want lines 1 and 2", broken)
+	}
+
+	got, count := RepairBrokenTasks(content, DefaultOptions())
+	want := "# Tasks\n- [x] Parent\n  - [ ] Child\n```\n- [x ] example\n```\n- [ ] Fine\n"
+	if got != want || count != 2 {
+		t.Errorf("RepairBrokenTasks() = %q, %d; want %q, 2", got, count, want)
+	}
+
+	if got, count := RepairBrokenTasks(want, DefaultOptions()); got != want || count != 0 {
+		t.Errorf("RepairBrokenTasks() on repaired content = %q, %d", got, count)
+	}
+}
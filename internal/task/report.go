@@ -0,0 +1,221 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report period names accepted by BuildReport.
+const (
+	ReportToday = "today"
+	ReportWeek  = "week"
+)
+
+// Report summarizes tasks completed in a period and the tasks still open.
+type Report struct {
+	Period    string       `json:"period"` // ReportToday or ReportWeek
+	From      string       `json:"from"`   // first day of the period (YYYY-MM-DD)
+	To        string       `json:"to"`     // last day of the period (YYYY-MM-DD)
+	Completed []ReportTask `json:"completed"`
+	Open      []ReportTask `json:"open"`
+}
+
+// ReportTask is a task in a report, without its checkbox and @done tag.
+type ReportTask struct {
+	Text string `json:"text"`
+	Done string `json:"done,omitempty"` // completion date (YYYY-MM-DD), empty for open tasks
+}
+
+// BuildReport collects tasks completed in period from tasks.md and archive.md content,
+// plus the open tasks in tasks.md. Completion dates come from @done tags.
+// The week period runs from Monday to today.
+func BuildReport(tasksContent, archiveContent, period string, now time.Time, opts Options) (Report, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var from time.Time
+	switch period {
+	case ReportToday:
+		from = today
+	case ReportWeek:
+		from = weekStart(today)
+	default:
+		return Report{}, fmt.Errorf("unknown report period %q (use %s or %s)", period, ReportToday, ReportWeek)
+	}
+
+	report := Report{
+		Period:    period,
+		From:      from.Format("2006-01-02"),
+		To:        today.Format("2006-01-02"),
+		Completed: []ReportTask{},
+		Open:      []ReportTask{},
+	}
+
+	for _, content := range []string{tasksContent, archiveContent} {
+		for _, line := range ParseLines(content, opts) {
+			if !line.IsCompleted {
+				continue
+			}
+			date, ok := ParseDoneDate(line.Content)
+			if !ok || date.Before(from) || date.After(today) {
+				continue
+			}
+			report.Completed = append(report.Completed, ReportTask{
+				Text: taskText(line.Content, opts),
+				Done: date.Format("2006-01-02"),
+			})
+		}
+	}
+
+	for _, line := range ParseLines(tasksContent, opts) {
+		if line.IsTask && !line.IsCompleted {
+			report.Open = append(report.Open, ReportTask{Text: taskText(line.Content, opts)})
+		}
+	}
+
+	return report, nil
+}
+
+// Markdown formats the report as a Markdown document.
+func (r Report) Markdown() string {
+	var b strings.Builder
+
+	if r.From == r.To {
+		fmt.Fprintf(&b, "# Report: %s\n", r.To)
+	} else {
+		fmt.Fprintf(&b, "# Report: %s to %s\n", r.From, r.To)
+	}
+
+	fmt.Fprintf(&b, "\n## Completed (%d)\n\n", len(r.Completed))
+	for _, t := range r.Completed {
+		if r.From == r.To {
+			fmt.Fprintf(&b, "- [x] %s\n", t.Text)
+		} else {
+			fmt.Fprintf(&b, "- [x] %s (%s)\n", t.Text, t.Done)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Open (%d)\n\n", len(r.Open))
+	for _, t := range r.Open {
+		fmt.Fprintf(&b, "- [ ] %s\n", t.Text)
+	}
+
+	return b.String()
+}
+
+// JSON formats the report as indented JSON with a trailing newline.
+func (r Report) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// noProject and noPriority label the tasks MonthlyReport counts outside any project
+// or priority.
+const (
+	noProject  = "(no project)"
+	noPriority = "(none)"
+)
+
+// MonthlyReport is MonthlyReportWith with DefaultOptions.
+func MonthlyReport(archiveContent string, year, month int) string {
+	return MonthlyReportWith(archiveContent, year, month, DefaultOptions())
+}
+
+// MonthlyReportWith summarizes the tasks archiveContent has tagged @done in the given
+// month as a Markdown document: the total, then the count per day, per project, per
+// @priority, and per #tag. A project is a top-level task with subtasks; it counts
+// itself and the completed tasks nested under it, and every other task is counted
+// under "(no project)". Sections with nothing to count are left out.
+func MonthlyReportWith(archiveContent string, year, month int, opts Options) string {
+	days := make(map[string]int)
+	projects := make(map[string]int)
+	priorities := make(map[string]int)
+	tags := make(map[string]int)
+	total := 0
+
+	lines := ParseLines(archiveContent, opts)
+	var walk func(trees []*TaskTree, project string)
+	walk = func(trees []*TaskTree, project string) {
+		for _, tree := range trees {
+			p := project
+			if p == "" && len(tree.Children) > 0 {
+				p = withoutTags(taskText(tree.Line.Content, opts))
+			}
+			walk(tree.Children, p)
+
+			line := tree.Line.Content
+			date, ok := ParseDoneDate(line)
+			if !tree.Line.IsCompleted || !ok || date.Year() != year || int(date.Month()) != month {
+				continue
+			}
+			total++
+			days[date.Format("2006-01-02 (Mon)")]++
+			if p == "" {
+				p = noProject
+			}
+			projects[p]++
+			priority, ok := ParsePriority(line)
+			if !ok {
+				priority = noPriority
+			}
+			priorities[priority]++
+			for _, m := range hashTagPattern.FindAllStringSubmatch(line, -1) {
+				tags[m[1]]++
+			}
+		}
+	}
+	walk(BuildTaskTrees(lines), "")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Report: %04d-%02d\n\n", year, month)
+	if total == 0 {
+		b.WriteString("No tasks completed.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d task(s) completed.\n", total)
+
+	writeCounts(&b, "By Day", days, func(a, b string) bool { return a < b })
+	writeCounts(&b, "By Project", projects, byCount(projects, noProject))
+	writeCounts(&b, "By Priority", priorities, func(a, b string) bool {
+		return a != noPriority && (b == noPriority || a < b)
+	})
+	writeCounts(&b, "By Tag", tags, byCount(tags, ""))
+	return b.String()
+}
+
+// byCount orders the keys of counts by count, highest first, then by name, with last
+// (if not empty) at the end.
+func byCount(counts map[string]int, last string) func(a, b string) bool {
+	return func(a, b string) bool {
+		if (a == last) != (b == last) {
+			return b == last
+		}
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		return a < b
+	}
+}
+
+// writeCounts writes a "## title" section listing counts as "- key: n" lines in the
+// order less gives. Nothing is written for empty counts.
+func writeCounts(b *strings.Builder, title string, counts map[string]int, less func(a, b string) bool) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+
+	fmt.Fprintf(b, "\n## %s\n\n", title)
+	for _, key := range keys {
+		fmt.Fprintf(b, "- %s: %d\n", key, counts[key])
+	}
+}
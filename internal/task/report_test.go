@@ -0,0 +1,165 @@
+package task
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+const reportTasks = `# Work
+- [x] Ship release @done(2026-01-21)
+  - [x] Write changelog @done(2026-01-21)
+- [x] Fix login bug @done(2026-01-19)
+- [ ] Review PR
+- [x] Old task @done(2026-01-10)
+`
+
+const reportArchive = `## 2026-01-20
+- [x] Plan sprint @done(2026-01-20)
+
+## 2026-01-12
+- [x] Last week task @done(2026-01-12)
+`
+
+// reportNow is Wednesday 2026-01-21; the week starts on Monday 2026-01-19.
+var reportNow = time.Date(2026, 1, 21, 9, 0, 0, 0, time.Local)
+
+// TestBuildReportToday verifies that the today report lists only tasks done today,
+// from tasks.md and archive.md, and all open tasks.
+func TestBuildReportToday(t *testing.T) {
+	report, err := BuildReport(reportTasks, reportArchive, ReportToday, reportNow, DefaultOptions())
+	if err != nil {
+		t.Fatalf("BuildReport() error: %v", err)
+	}
+
+	if report.From != "2026-01-21" || report.To != "2026-01-21" {
+		t.Errorf("period = %s..%s, want 2026-01-21..2026-01-21", report.From, report.To)
+	}
+	assertReportTexts(t, report.Completed, []string{"Ship release", "Write changelog"})
+	assertReportTexts(t, report.Open, []string{"Review PR"})
+}
+
+// TestBuildReportWeek verifies that the week report covers Monday through today,
+// including archived tasks.
+func TestBuildReportWeek(t *testing.T) {
+	report, err := BuildReport(reportTasks, reportArchive, ReportWeek, reportNow, DefaultOptions())
+	if err != nil {
+		t.Fatalf("BuildReport() error: %v", err)
+	}
+
+	if report.From != "2026-01-19" || report.To != "2026-01-21" {
+		t.Errorf("period = %s..%s, want 2026-01-19..2026-01-21", report.From, report.To)
+	}
+	assertReportTexts(t, report.Completed, []string{"Ship release", "Write changelog", "Fix login bug", "Plan sprint"})
+}
+
+// TestBuildReportUnknownPeriod verifies that unsupported periods are rejected.
+func TestBuildReportUnknownPeriod(t *testing.T) {
+	if _, err := BuildReport("", "", "month", reportNow, DefaultOptions()); err == nil {
+		t.Error("BuildReport() should return error for unknown period")
+	}
+}
+
+// TestReportMarkdown verifies the Markdown layout for single-day and multi-day reports.
+func TestReportMarkdown(t *testing.T) {
+	today, _ := BuildReport(reportTasks, "", ReportToday, reportNow, DefaultOptions())
+	expected := "# Report: 2026-01-21\n\n" +
+		"## Completed (2)\n\n- [x] Ship release\n- [x] Write changelog\n\n" +
+		"## Open (1)\n\n- [ ] Review PR\n"
+	if got := today.Markdown(); got != expected {
+		t.Errorf("Markdown() =\n%s\nwant:\n%s", got, expected)
+	}
+
+	week, _ := BuildReport(reportTasks, "", ReportWeek, reportNow, DefaultOptions())
+	md := week.Markdown()
+	if !strings.HasPrefix(md, "# Report: 2026-01-19 to 2026-01-21\n") {
+		t.Errorf("week Markdown() header = %q", strings.SplitN(md, "\n", 2)[0])
+	}
+	if !strings.Contains(md, "- [x] Fix login bug (2026-01-19)\n") {
+		t.Error("week Markdown() should include completion dates")
+	}
+}
+
+// TestReportJSON verifies that the JSON output round-trips and uses empty arrays, not null.
+func TestReportJSON(t *testing.T) {
+	report, _ := BuildReport("", "", ReportToday, reportNow, DefaultOptions())
+	out, err := report.JSON()
+	if err != nil {
+		t.Fatalf("JSON() error: %v", err)
+	}
+	if !strings.Contains(out, `"completed": []`) {
+		t.Errorf("JSON() should encode no tasks as [], got:\n%s", out)
+	}
+
+	var decoded Report
+	full, _ := BuildReport(reportTasks, reportArchive, ReportWeek, reportNow, DefaultOptions())
+	out, _ = full.JSON()
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error: %v", err)
+	}
+	if len(decoded.Completed) != 4 || decoded.Completed[0].Done != "2026-01-21" {
+		t.Errorf("decoded completed = %+v", decoded.Completed)
+	}
+}
+
+// TestMonthlyReport verifies the monthly summary of the archive: only tasks done in
+// the month are counted, subtasks count under their top-level task's project, and
+// empty sections are left out.
+func TestMonthlyReport(t *testing.T) {
+	archive := `## 2026-02-01
+- [x] Next month @done(2026-02-01)
+
+## 2026-01-21
+- [x] Ship release #work @priority(A) @done(2026-01-21)
+  - [x] Write changelog #docs @done(2026-01-21)
+  - [ ] Skipped step
+- [x] Call mom @done(2026-01-21)
+
+## 2026-01-05
+- [x] Plan sprint #work @priority(b) @done(2026-01-05)
+- [x] Pay rent @done(2026-01-05)
+
+## 2025-01-05
+- [x] Last year @done(2025-01-05)
+`
+
+	tests := []struct {
+		name  string
+		year  int
+		month int
+		want  string
+	}{
+		{"month with tasks", 2026, 1, "# Report: 2026-01\n\n" +
+			"5 task(s) completed.\n\n" +
+			"## By Day\n\n- 2026-01-05 (Mon): 2\n- 2026-01-21 (Wed): 3\n\n" +
+			"## By Project\n\n- Ship release #work: 2\n- (no project): 3\n\n" +
+			"## By Priority\n\n- A: 1\n- B: 1\n- (none): 3\n\n" +
+			"## By Tag\n\n- #work: 2\n- #docs: 1\n"},
+		{"no tags or priorities", 2026, 2, "# Report: 2026-02\n\n" +
+			"1 task(s) completed.\n\n" +
+			"## By Day\n\n- 2026-02-01 (Sun): 1\n\n" +
+			"## By Project\n\n- (no project): 1\n\n" +
+			"## By Priority\n\n- (none): 1\n"},
+		{"empty month", 2026, 3, "# Report: 2026-03\n\nNo tasks completed.\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MonthlyReport(archive, tt.year, tt.month); got != tt.want {
+				t.Errorf("MonthlyReportWith() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func assertReportTexts(t *testing.T, tasks []ReportTask, expected []string) {
+	t.Helper()
+	var got []string
+	for _, task := range tasks {
+		got = append(got, task.Text)
+	}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("tasks = %q, want %q", got, expected)
+	}
+}
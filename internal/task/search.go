@@ -0,0 +1,141 @@
+package task
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchOptions controls how search text is compared. Matching is always case-insensitive.
+type SearchOptions struct {
+	NormalizeWidth bool // full-width ASCII and half-width katakana match their usual forms
+	IgnoreKana     bool // hiragana and katakana match each other
+}
+
+// Match is a search hit as byte offsets into the original (not normalized) text.
+type Match struct {
+	Start int
+	End   int
+}
+
+// halfwidthKana maps U+FF61..U+FF9F (half-width katakana and punctuation) to their full-width forms.
+var halfwidthKana = []rune("。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン゛゜")
+
+const (
+	halfwidthVoiced     = 'ﾞ' // ﾞ
+	halfwidthSemiVoiced = 'ﾟ' // ﾟ
+)
+
+// NormalizeForSearch folds s the way FindMatches compares text, for the "/" filter
+// in the TUI and "ttt search" alike. Letters are always lowercased. With
+// opts.NormalizeWidth, full-width ASCII letters, digits, and symbols become half-width
+// ("ＡＢＣ１" → "abc1"), the ideographic space becomes a space, and half-width
+// katakana become full-width (voiced marks included: "ｶﾞ" → "ガ"). With
+// opts.IgnoreKana, katakana become hiragana.
+func NormalizeForSearch(s string, opts SearchOptions) string {
+	normalized, _ := normalizeSearch(s, opts)
+	return normalized
+}
+
+// FindMatches returns the non-overlapping occurrences of query in text after
+// normalizing both with opts (see NormalizeForSearch). Offsets refer to text as
+// given, so highlighting stays on the right characters even where normalization
+// changed lengths.
+func FindMatches(text, query string, opts SearchOptions) []Match {
+	needle := NormalizeForSearch(query, opts)
+	if needle == "" {
+		return nil
+	}
+	haystack, offsets := normalizeSearch(text, opts)
+
+	var matches []Match
+	pos := 0
+	for {
+		i := strings.Index(haystack[pos:], needle)
+		if i < 0 {
+			return matches
+		}
+		start := pos + i
+		end := start + len(needle)
+		matches = append(matches, Match{Start: offsets[start], End: offsets[end]})
+		pos = end
+	}
+}
+
+// FindTaskByText returns the lines (0-indexed) of the open tasks of content whose
+// text (see TaskBody) contains query, compared case-insensitively. See
+// FindTaskByTextWith for the [search] width and kana folding.
+func FindTaskByText(content, query string) ([]int, error) {
+	return FindTaskByTextWith(content, query, SearchOptions{}, DefaultOptions())
+}
+
+// FindTaskByTextWith is FindTaskByText with query compared as search sets and tasks
+// read as opts sets. Completed tasks and tasks in code blocks never match, and
+// neither do tags, so "due" does not find every task with a @due date. Returns an
+// error if query is empty.
+func FindTaskByTextWith(content, query string, search SearchOptions, opts Options) ([]int, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("empty search text")
+	}
+	var lines []int
+	for _, line := range ParseLines(content, opts) {
+		if line.IsTask && !line.IsCompleted && len(FindMatches(TaskBody(line.Content, opts), query, search)) > 0 {
+			lines = append(lines, line.LineNumber)
+		}
+	}
+	return lines, nil
+}
+
+// normalizeSearch normalizes s with opts. offsets[i] is the byte offset in s of the
+// character that produced byte i of the result; offsets[len(result)] is len(s).
+func normalizeSearch(s string, opts SearchOptions) (string, []int) {
+	var b strings.Builder
+	offsets := make([]int, 0, len(s)+1)
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if opts.NormalizeWidth {
+			r, size = foldWidth(s[i:], r, size)
+		}
+		if opts.IgnoreKana && r >= 'ァ' && r <= 'ヶ' {
+			r -= 'ァ' - 'ぁ'
+		}
+		r = unicode.ToLower(r)
+
+		before := b.Len()
+		b.WriteRune(r)
+		for j := before; j < b.Len(); j++ {
+			offsets = append(offsets, i)
+		}
+		i += size
+	}
+
+	offsets = append(offsets, len(s))
+	return b.String(), offsets
+}
+
+// foldWidth maps one full-width ASCII or half-width katakana character at the start of s
+// to its usual form. A half-width katakana followed by a voiced mark is combined into
+// one character, so the returned size may cover two characters of s.
+func foldWidth(s string, r rune, size int) (rune, int) {
+	switch {
+	case r >= '！' && r <= '～':
+		return r - ('！' - '!'), size
+	case r == '　':
+		return ' ', size
+	case r >= '｡' && r <= 'ﾟ':
+		kana := halfwidthKana[r-'｡']
+		next, nextSize := utf8.DecodeRuneInString(s[size:])
+		switch {
+		case next == halfwidthVoiced && kana == 'ウ':
+			return 'ヴ', size + nextSize
+		case next == halfwidthVoiced && strings.ContainsRune("カキクケコサシスセソタチツテトハヒフヘホ", kana):
+			return kana + 1, size + nextSize
+		case next == halfwidthSemiVoiced && strings.ContainsRune("ハヒフヘホ", kana):
+			return kana + 2, size + nextSize
+		}
+		return kana, size
+	}
+	return r, size
+}
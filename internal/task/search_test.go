@@ -0,0 +1,129 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestNormalizeForSearch verifies width folding of ASCII and katakana, kana folding,
+// and lowercasing.
+func TestNormalizeForSearch(t *testing.T) {
+	width := SearchOptions{NormalizeWidth: true}
+
+	tests := []struct {
+		input    string
+		opts     SearchOptions
+		expected string
+	}{
+		{"ＡＢＣ１２３", width, "abc123"},
+		{"Ｒｅｖｉｅｗ　ＰＲ＃４２", width, "review pr#42"},
+		{"ｶﾀｶﾅ", width, "カタカナ"},
+		{"ｶﾞｷﾞﾊﾟｳﾞ", width, "ガギパヴ"},
+		{"ﾞ", width, "゛"},
+		{"ｱﾞ", width, "ア゛"},
+		{"ひらがな", width, "ひらがな"},
+		{"already ascii", width, "already ascii"},
+		{"ＡＢＣ Review", SearchOptions{}, "ａｂｃ review"},
+		{"カタカナ", SearchOptions{IgnoreKana: true}, "かたかな"},
+		{"ｶﾀｶﾅ", SearchOptions{NormalizeWidth: true, IgnoreKana: true}, "かたかな"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := NormalizeForSearch(tt.input, tt.opts); got != tt.expected {
+				t.Errorf("NormalizeForSearch(%q, %+v) = %q, want %q", tt.input, tt.opts, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestFindMatches verifies that matches are reported as offsets into the original text.
+func TestFindMatches(t *testing.T) {
+	width := SearchOptions{NormalizeWidth: true}
+
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		opts  SearchOptions
+		want  []string // matched substrings of text
+	}{
+		{"case-insensitive", "- [ ] Review PR", "review", SearchOptions{}, []string{"Review"}},
+		{"full-width text, half-width query", "- [ ] ＰＲ４２をレビュー", "pr42", width, []string{"ＰＲ４２"}},
+		{"half-width text, full-width query", "- [ ] PR42", "ＰＲ４２", width, []string{"PR42"}},
+		{"width differs without the option", "- [ ] ＰＲ４２", "pr42", SearchOptions{}, nil},
+		{"half-width katakana with voiced marks", "- [ ] ﾃﾞｰﾀﾍﾞｰｽ移行", "データベース", width, []string{"ﾃﾞｰﾀﾍﾞｰｽ"}},
+		{"kana distinguished by default", "- [ ] カタカナ", "かたかな", width, nil},
+		{"kana ignored", "- [ ] カタカナとひらがな", "かたかな", SearchOptions{IgnoreKana: true}, []string{"カタカナ"}},
+		{"kana ignored with half-width", "- [ ] ｶﾀｶﾅ", "かたかな", SearchOptions{NormalizeWidth: true, IgnoreKana: true}, []string{"ｶﾀｶﾅ"}},
+		{"every occurrence", "ab ＡＢ ab", "ab", width, []string{"ab", "ＡＢ", "ab"}},
+		{"empty query", "anything", "", width, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := FindMatches(tt.text, tt.query, tt.opts)
+			var got []string
+			for _, m := range matches {
+				got = append(got, tt.text[m.Start:m.End])
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("FindMatches() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("match %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestFindTaskByText verifies that only open tasks whose text contains the query match.
+func TestFindTaskByText(t *testing.T) {
+	content := "# Tasks\n- [ ] 牛乳を買う @due(2026-10-20)\n- [x] 牛乳を注文 @done(2026-10-01)\n  - [ ] 低脂肪の牛乳\n```\n- [ ] 牛乳 in code\n```\n牛乳 notes\n"
+
+	tests := []struct {
+		name    string
+		query   string
+		want    []int
+		wantErr bool
+	}{
+		{"open tasks", "牛乳", []int{1, 3}, false},
+		{"single match", "買う", []int{1}, false},
+		{"completed task ignored", "注文", nil, false},
+		{"tags ignored", "due", nil, false},
+		{"no match", "卵", nil, false},
+		{"empty query", " ", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindTaskByText(content, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindTaskByText() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("FindTaskByText(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFindTaskByTextWith verifies that the query is compared as the SearchOptions say
+// and tasks are read as the Options say.
+func TestFindTaskByTextWith(t *testing.T) {
+	content := "- [ ] ミルクを買う\n* [ ] ミルクを飲む\n"
+
+	if got, _ := FindTaskByTextWith(content, "ﾐﾙｸ", SearchOptions{}, DefaultOptions()); got != nil {
+		t.Errorf("without width folding = %v, want none", got)
+	}
+	if got, _ := FindTaskByTextWith(content, "ﾐﾙｸ", SearchOptions{NormalizeWidth: true}, DefaultOptions()); fmt.Sprint(got) != "[0]" {
+		t.Errorf("with width folding = %v, want [0]", got)
+	}
+	opts := DefaultOptions()
+	opts.BulletStyles = []string{"-", "*"}
+	if got, _ := FindTaskByTextWith(content, "ミルク", SearchOptions{}, opts); fmt.Sprint(got) != "[0 1]" {
+		t.Errorf("with \"*\" bullets = %v, want [0 1]", got)
+	}
+}
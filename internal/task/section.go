@@ -0,0 +1,89 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Section is a "## " section of a task file: the heading line and every line below it
+// up to the next "#" or "##" heading, so "###" and deeper headings belong to it.
+type Section struct {
+	Heading string // heading text without the "## " marker
+	Line    int    // 0-indexed line of the heading
+	End     int    // line after the last line of the section
+	Tasks   int    // task lines in the section, subtasks included
+	Open    int    // tasks not completed
+}
+
+// Sections returns the "## " sections of content in file order. Headings in code blocks
+// and front matter are ignored. Line numbers ignore a trailing newline, so the last
+// section ends at the number of lines in the file.
+func Sections(content string, opts Options) []Section {
+	var sections []Section
+	current := -1 // index into sections, -1 outside a section
+	for _, line := range ParseLines(strings.TrimSuffix(content, "\n"), opts) {
+		if level, text, ok := headingLevel(line); ok && level <= 2 {
+			current = -1
+			if level == 2 {
+				sections = append(sections, Section{Heading: text, Line: line.LineNumber, End: line.LineNumber + 1})
+				current = len(sections) - 1
+			}
+			continue
+		}
+		if current >= 0 && line.IsTask {
+			sections[current].Tasks++
+			if !line.IsCompleted {
+				sections[current].Open++
+			}
+		}
+		if current >= 0 {
+			sections[current].End = line.LineNumber + 1
+		}
+	}
+	return sections
+}
+
+// ResetSection is ResetSectionWith with DefaultOptions.
+func ResetSection(content, heading string) (string, int, error) {
+	return ResetSectionWith(content, heading, DefaultOptions())
+}
+
+// ResetSectionWith unchecks every completed task in the "## " section whose heading text
+// is heading, at any depth, and removes their @done tags, so a reusable checklist
+// (packing, release steps) starts over. Notes and the other sections are left as they
+// are; with two sections of the same heading, the first is reset. Returns the new
+// content and the number of tasks reset, or an error if there is no such section.
+func ResetSectionWith(content, heading string, opts Options) (string, int, error) {
+	heading = strings.TrimSpace(heading)
+	for _, s := range Sections(content, opts) {
+		if s.Heading != heading {
+			continue
+		}
+		lines := ParseLines(content, opts)
+		split := strings.Split(content, "\n")
+		count := 0
+		for _, line := range lines[s.Line+1 : s.End] {
+			if line.IsCompleted {
+				split[line.LineNumber] = reopenLine(line.Content, opts)
+				count++
+			}
+		}
+		return strings.Join(split, "\n"), count, nil
+	}
+	return content, 0, fmt.Errorf("no ## heading %q", heading)
+}
+
+// headingLevel returns the level (number of "#") and text of a heading line.
+// Lines in code blocks and front matter are not headings.
+func headingLevel(line ParsedLine) (int, string, bool) {
+	if line.InCodeBlock || line.FrontMatter {
+		return 0, "", false
+	}
+	m := headingPattern.FindStringSubmatch(line.Content)
+	if m == nil {
+		return 0, "", false
+	}
+	trimmed := strings.TrimSpace(line.Content)
+	level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+	return level, strings.TrimSpace(m[1]), true
+}
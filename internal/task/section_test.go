@@ -0,0 +1,122 @@
+package task
+
+import "testing"
+
+// TestSections verifies "## " section boundaries and their task counts.
+func TestSections(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Section
+	}{
+		{
+			name:    "two sections",
+			content: "# Tasks\n\n## Work\n- [ ] a\n- [x] b\n\n## Home\n- [ ] c\n",
+			want: []Section{
+				{Heading: "Work", Line: 2, End: 6, Tasks: 2, Open: 1},
+				{Heading: "Home", Line: 6, End: 8, Tasks: 1, Open: 1},
+			},
+		},
+		{
+			name:    "nested headings stay in the section",
+			content: "## Work\n### Backend\n- [ ] a\n  - [x] a1\n### Frontend\n- [ ] b\n## Home\n",
+			want: []Section{
+				{Heading: "Work", Line: 0, End: 6, Tasks: 3, Open: 2},
+				{Heading: "Home", Line: 6, End: 7},
+			},
+		},
+		{
+			name:    "level 1 heading ends a section",
+			content: "## Work\n- [ ] a\n# Other\n- [ ] b\n",
+			want:    []Section{{Heading: "Work", Line: 0, End: 2, Tasks: 1, Open: 1}},
+		},
+		{
+			name:    "heading at end of file",
+			content: "- [ ] a\n## Empty",
+			want:    []Section{{Heading: "Empty", Line: 1, End: 2}},
+		},
+		{
+			name:    "headings in code blocks ignored",
+			content: "## Notes\n```\n## not a heading\n- [ ] not a task\n```\n",
+			want:    []Section{{Heading: "Notes", Line: 0, End: 5}},
+		},
+		{
+			name:    "no sections",
+			content: "- [ ] a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sections(tt.content, DefaultOptions())
+			if len(got) != len(tt.want) {
+				t.Fatalf("Sections() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("section %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestResetSection verifies that only the completed tasks of the named section are
+// reopened, nested ones included, with notes and other sections left alone.
+func TestResetSection(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		heading   string
+		want      string
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:      "completed tasks reopened",
+			content:   "## Packing\n- [x] Passport @done(2026-10-01)\n- [ ] Charger\n- [x] Socks\n## Work\n- [x] Report @done(2026-10-01)\n",
+			heading:   "Packing",
+			want:      "## Packing\n- [ ] Passport\n- [ ] Charger\n- [ ] Socks\n## Work\n- [x] Report @done(2026-10-01)\n",
+			wantCount: 2,
+		},
+		{
+			name:      "nested tasks and subsections",
+			content:   "## Release\n- [x] Build @est(1h) @done(2026-10-01)\n  - [x] Tag @done(2026-10-01)\n### Afterwards\n- [x] Announce @done(2026-10-02)\n",
+			heading:   "Release",
+			want:      "## Release\n- [ ] Build @est(1h)\n  - [ ] Tag\n### Afterwards\n- [ ] Announce\n",
+			wantCount: 3,
+		},
+		{
+			name:      "notes and code blocks preserved",
+			content:   "## Packing\nCheck the weather first.\n- [x] Umbrella @done(2026-10-01)\n  pack it last\n```\n- [x] not a task\n```\n",
+			heading:   " Packing ",
+			want:      "## Packing\nCheck the weather first.\n- [ ] Umbrella\n  pack it last\n```\n- [x] not a task\n```\n",
+			wantCount: 1,
+		},
+		{
+			name:    "nothing to reset",
+			content: "## Packing\n- [ ] Passport\n",
+			heading: "Packing",
+			want:    "## Packing\n- [ ] Passport\n",
+		},
+		{
+			name:    "heading not found",
+			content: "## Packing\n- [x] Passport\n",
+			heading: "Travel",
+			want:    "## Packing\n- [x] Passport\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count, err := ResetSection(tt.content, tt.heading)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResetSectionWith() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want || count != tt.wantCount {
+				t.Errorf("ResetSectionWith() = %q, %d; want %q, %d", got, count, tt.want, tt.wantCount)
+			}
+		})
+	}
+}
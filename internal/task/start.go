@@ -0,0 +1,57 @@
+package task
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// startTagPattern matches @start(YYYY-MM-DD), the day a task should be started on
+var startTagPattern = regexp.MustCompile(`@start\((\d{4}-\d{2}-\d{2})\)`)
+
+// ParseStartDate returns the date of the @start tag on line, e.g. @start(2026-02-01).
+// Reports false when there is no tag or its value is not a valid date.
+func ParseStartDate(line string) (time.Time, bool) {
+	m := startTagPattern.FindStringSubmatch(line)
+	if m == nil {
+		return time.Time{}, false
+	}
+	start, err := time.Parse("2006-01-02", m[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+// FutureLines returns the lines (0-indexed) of the open tasks in content whose @start
+// date is after today, with everything nested under them, and how many tasks those
+// lines hold. From the start date on, a task is no longer in the future. Completed
+// tasks and lines in code blocks are never future tasks.
+func FutureLines(content string, now time.Time, opts Options) (map[int]bool, int) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	parsed := ParseLines(content, opts)
+	lines := strings.Split(content, "\n")
+
+	future := make(map[int]bool)
+	tasks := 0
+	for i := 0; i < len(parsed); i++ {
+		pl := parsed[i]
+		if !pl.IsTask || pl.IsCompleted {
+			continue
+		}
+		start, ok := ParseStartDate(pl.Content)
+		if !ok || !start.After(today) {
+			continue
+		}
+		_, end := subtreeRange(lines, i)
+		end = max(end, i+1)
+		for n := i; n < end; n++ {
+			future[n] = true
+			if parsed[n].IsTask {
+				tasks++
+			}
+		}
+		i = end - 1
+	}
+	return future, tasks
+}
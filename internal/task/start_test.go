@@ -0,0 +1,65 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseStartDate verifies that ParseStartDate reads the @start date of a line and
+// rejects lines without one or with an invalid date.
+func TestParseStartDate(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   string
+		wantOK bool
+	}{
+		{"- [ ] Plan trip @start(2026-02-01)", "2026-02-01", true},
+		{"- [ ] Plan trip @start(2026-02-01) @due(2026-02-10)", "2026-02-01", true},
+		{"- [ ] Plan trip", "", false},
+		{"- [ ] Plan trip @start(fri)", "", false},
+		{"- [ ] Plan trip @start(2026-02-30)", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok := ParseStartDate(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseStartDate(%q) ok = %v, want %v", tt.line, ok, tt.wantOK)
+			}
+			if ok && got.Format("2006-01-02") != tt.want {
+				t.Errorf("ParseStartDate(%q) = %s, want %s", tt.line, got.Format("2006-01-02"), tt.want)
+			}
+		})
+	}
+}
+
+// TestFutureLines verifies that FutureLines finds open tasks starting after today with
+// their nested lines, and counts the tasks among them.
+func TestFutureLines(t *testing.T) {
+	now := time.Date(2026, 1, 20, 15, 0, 0, 0, time.Local)
+	content := "# Tasks\n" +
+		"- [ ] Later @start(2026-02-01)\n" + // 1
+		"  - [ ] Sub\n" + // 2
+		"    note\n" + // 3
+		"- [ ] Today @start(2026-01-20)\n" + // 4
+		"- [ ] Past @start(2026-01-10)\n" + // 5
+		"- [x] Done early @start(2026-02-01) @done(2026-01-19)\n" + // 6
+		"- [ ] Parent\n" + // 7
+		"  - [ ] Child later @start(2026-03-01)\n" + // 8
+		"- [ ] Next\n" + // 9
+		"```\n- [ ] Code @start(2026-02-01)\n```\n" // 10-12
+
+	got, tasks := FutureLines(content, now, DefaultOptions())
+	want := []int{1, 2, 3, 8}
+	if len(got) != len(want) {
+		t.Errorf("FutureLines() = %v, want lines %v", got, want)
+	}
+	for _, n := range want {
+		if !got[n] {
+			t.Errorf("FutureLines() misses line %d", n)
+		}
+	}
+	if tasks != 3 {
+		t.Errorf("FutureLines() tasks = %d, want 3", tasks)
+	}
+}
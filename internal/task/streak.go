@@ -0,0 +1,108 @@
+package task
+
+import (
+	"strings"
+	"time"
+)
+
+// CurrentStreak returns the number of consecutive days, ending today or yesterday,
+// that have a date section in archive.md. A streak whose last day is before
+// yesterday is broken and counts as 0, so completions archived with a delay of
+// one day still keep the streak going.
+func CurrentStreak(archiveContent string, now time.Time) int {
+	return currentStreak(completionDays(archiveContent), now, false)
+}
+
+// CurrentWeekdayStreak is like CurrentStreak but skips Saturdays and Sundays:
+// weekend days neither extend nor break the streak, so Friday is "yesterday" on Monday.
+func CurrentWeekdayStreak(archiveContent string, now time.Time) int {
+	return currentStreak(completionDays(archiveContent), now, true)
+}
+
+// Streak is StreakWith with DefaultOptions.
+func Streak(contents []string, now time.Time) int {
+	return StreakWith(contents, now, DefaultOptions())
+}
+
+// StreakWith returns the number of consecutive days, ending today or yesterday, on which
+// at least one task in contents (e.g. tasks.md and archive.md) was tagged @done.
+// Today counts once something is done today; until then a streak ending yesterday
+// is still alive. Days are calendar days in now's location, matching @done dates.
+func StreakWith(contents []string, now time.Time, opts Options) int {
+	days := make(map[time.Time]bool)
+	for day := range doneCounts(contents, opts) {
+		days[day] = true
+	}
+	return currentStreak(days, now, false)
+}
+
+// DoneOn returns the number of tasks in contents tagged @done on now's calendar day.
+func DoneOn(contents []string, now time.Time, opts Options) int {
+	return doneCounts(contents, opts)[calendarDay(now)]
+}
+
+// doneCounts returns the number of tasks tagged @done on each date in contents.
+// Lines in code blocks and front matter are not tasks.
+func doneCounts(contents []string, opts Options) map[time.Time]int {
+	counts := make(map[time.Time]int)
+	for _, content := range contents {
+		for _, line := range ParseLines(content, opts) {
+			if !line.IsTask {
+				continue
+			}
+			if date, ok := ParseDoneDate(line.Content); ok {
+				counts[date]++
+			}
+		}
+	}
+	return counts
+}
+
+// calendarDay returns t's date in its own location as midnight UTC, the form
+// ParseDoneDate and parseArchiveHeader return, so dates compare by calendar day.
+func calendarDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func currentStreak(days map[time.Time]bool, now time.Time, skipWeekends bool) int {
+	day := calendarDay(now)
+	if skipWeekends && isWeekend(day) {
+		day = previousDay(day, true)
+	}
+	if !days[day] {
+		// Nothing yet today: the streak is still alive if yesterday counts
+		day = previousDay(day, skipWeekends)
+	}
+
+	streak := 0
+	for days[day] {
+		streak++
+		day = previousDay(day, skipWeekends)
+	}
+	return streak
+}
+
+// completionDays returns the dates of the archive date sections.
+// Headers in any form RepairArchiveHeaders understands are accepted.
+func completionDays(archiveContent string) map[time.Time]bool {
+	days := make(map[time.Time]bool)
+	for _, line := range strings.Split(archiveContent, "\n") {
+		if date, ok := parseArchiveHeader(line); ok {
+			days[date] = true
+		}
+	}
+	return days
+}
+
+// previousDay returns the day before day, skipping weekends when skipWeekends is set.
+func previousDay(day time.Time, skipWeekends bool) time.Time {
+	day = day.AddDate(0, 0, -1)
+	for skipWeekends && isWeekend(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+func isWeekend(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+}
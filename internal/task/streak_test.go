@@ -0,0 +1,128 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+// streakArchive has completions on Tue 13, Thu 15, Fri 16 (loose header), Mon 19, and Tue 20 January 2026.
+const streakArchive = `## 2026-01-20
+- [x] Plan sprint @done(2026-01-20)
+
+## 2026-01-19
+- [x] Review PR @done(2026-01-19)
+
+## 2026/1/16
+- [x] Ship release @done(2026-01-16)
+
+## 2026-01-15
+- [x] Write changelog @done(2026-01-15)
+
+## 2026-01-13
+- [x] Fix login bug @done(2026-01-13)
+`
+
+func streakDay(day int) time.Time {
+	return time.Date(2026, 1, day, 21, 0, 0, 0, time.Local)
+}
+
+// TestCurrentStreak verifies that the streak continues from today or yesterday and breaks on a gap.
+func TestCurrentStreak(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+		now     time.Time
+		want    int
+	}{
+		{"completed today", streakArchive, streakDay(20), 2},
+		{"nothing yet today, yesterday counts", streakArchive, streakDay(21), 2},
+		{"broken after a missed day", streakArchive, streakDay(22), 0},
+		{"weekend breaks the streak", streakArchive, streakDay(19), 1},
+		{"empty archive", "", streakDay(20), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CurrentStreak(tt.archive, tt.now); got != tt.want {
+				t.Errorf("CurrentStreak() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCurrentWeekdayStreak verifies that weekends neither extend nor break the streak.
+func TestCurrentWeekdayStreak(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		want int
+	}{
+		{"across the weekend", streakDay(21), 4},
+		{"Monday continues from Friday", streakDay(19), 3},
+		{"on Sunday, Friday counts", streakDay(18), 2},
+		{"broken after a missed weekday", streakDay(23), 0},
+		{"Saturday after a missed Friday", streakDay(24), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CurrentWeekdayStreak(streakArchive, tt.now); got != tt.want {
+				t.Errorf("CurrentWeekdayStreak() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStreak verifies streaks counted from @done dates across several files.
+func TestStreak(t *testing.T) {
+	tasks := "# Tasks\n- [x] Deploy @done(2026-01-21)\n- [ ] Open task\n```\n- [x] In code @done(2026-01-22)\n```\n"
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	tests := []struct {
+		name     string
+		contents []string
+		now      time.Time
+		want     int
+	}{
+		{"tasks and archive together", []string{tasks, streakArchive}, streakDay(21), 3},
+		{"nothing yet today, yesterday counts", []string{tasks, streakArchive}, streakDay(22), 3},
+		{"code blocks are not tasks", []string{tasks, streakArchive}, streakDay(23), 0},
+		{"gap breaks the streak", []string{streakArchive}, streakDay(16), 2},
+		{"late evening in a zone ahead of UTC", []string{tasks}, time.Date(2026, 1, 21, 23, 30, 0, 0, tokyo), 1},
+		{"early morning in a zone ahead of UTC", []string{tasks}, time.Date(2026, 1, 22, 0, 30, 0, 0, tokyo), 1},
+		{"empty history", nil, streakDay(21), 0},
+		{"empty files", []string{"", ""}, streakDay(21), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Streak(tt.contents, tt.now); got != tt.want {
+				t.Errorf("StreakWith() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDoneOn verifies counting the tasks completed on the current day.
+func TestDoneOn(t *testing.T) {
+	tasks := "- [x] a @done(2026-01-20)\n  - [x] a1 @done(2026-01-20)\n- [x] b @done(2026-01-19)\n"
+	tests := []struct {
+		name     string
+		contents []string
+		now      time.Time
+		want     int
+	}{
+		{"subtasks count", []string{tasks}, streakDay(20), 2},
+		{"across files", []string{tasks, streakArchive}, streakDay(20), 3},
+		{"nothing today", []string{tasks}, streakDay(21), 0},
+		{"empty history", nil, streakDay(20), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DoneOn(tt.contents, tt.now, DefaultOptions()); got != tt.want {
+				t.Errorf("DoneOn() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
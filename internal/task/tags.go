@@ -0,0 +1,60 @@
+package task
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// KnownTags are the tags ttt itself reads or writes, without the leading "@".
+var KnownTags = []string{
+	"done",     // @done(YYYY-MM-DD): completion date, added when a task is checked
+	"due",      // @due(YYYY-MM-DD) or @due(fri): due date, used by filters and overdue escalation
+	"est",      // @est(1h30m): effort estimate
+	"pin",      // @pin: shown first with ui.pinned_first
+	"priority", // @priority(A): priority A, B, or C
+	"start",    // @start(YYYY-MM-DD): start date, hidden until then with display.hide_future
+	"track",    // @track(1h23m): time tracked with T
+}
+
+// hashTagPattern matches a "#tag" word, capturing the tag. Headings ("# Work") and
+// numbers ("#12") do not match because the tag must start with a letter or "_".
+var hashTagPattern = regexp.MustCompile(`(?:^|\s)(#[\p{L}_][\p{L}\p{N}_-]*)`)
+
+// SuggestTags returns the known tags (see KnownTags) that complete prefix, a partly
+// typed tag such as "@d" ("@done", "@due"), in KnownTags order. A prefix that does
+// not start with "@" or already names a whole tag has no suggestions.
+func SuggestTags(prefix string) []string {
+	name, ok := strings.CutPrefix(prefix, "@")
+	if !ok {
+		return nil
+	}
+	var suggestions []string
+	for _, tag := range KnownTags {
+		if strings.HasPrefix(tag, name) && tag != name {
+			suggestions = append(suggestions, "@"+tag)
+		}
+	}
+	return suggestions
+}
+
+// FileTags returns the tags used on the task lines of content, sorted and without
+// duplicates: "#tag" words and "@tag" names without their arguments ("@waiting" for
+// "@waiting(bob)"). Lines in code blocks and front matter are skipped.
+func FileTags(content string, opts Options) []string {
+	var tags []string
+	for _, line := range ParseLines(content, opts) {
+		if !line.IsTask {
+			continue
+		}
+		for _, m := range hashTagPattern.FindAllStringSubmatch(line.Content, -1) {
+			tags = append(tags, m[1])
+		}
+		for _, tag := range findTags(line.Content) {
+			name, _, _ := strings.Cut(tag, "(")
+			tags = append(tags, name)
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
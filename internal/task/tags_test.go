@@ -0,0 +1,47 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestSuggestTags verifies completing a partly typed known tag.
+func TestSuggestTags(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"@d", []string{"@done", "@due"}},
+		{"@du", []string{"@due"}},
+		{"@p", []string{"@pin", "@priority"}},
+		{"@", []string{"@done", "@due", "@est", "@pin", "@priority", "@start", "@track"}},
+		{"@due", nil},
+		{"@x", nil},
+		{"d", nil},
+		{"#d", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			if got := SuggestTags(tt.prefix); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("SuggestTags(%q) = %v, want %v", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFileTags verifies collecting the #tags and @tags used on task lines.
+func TestFileTags(t *testing.T) {
+	content := "# Work #notatag\n" +
+		"- [ ] Call Bob #phone @waiting(bob)\n" +
+		"- [x] Write report #work @done(2026-01-18)\n" +
+		"- [ ] Fix #12 and #phone\n" +
+		"- Note #hidden\n" +
+		"```\n- [ ] Code #code\n```"
+
+	want := "[#phone #work @done @waiting]"
+	if got := fmt.Sprint(FileTags(content, DefaultOptions())); got != want {
+		t.Errorf("FileTags() = %s, want %s", got, want)
+	}
+}
@@ -2,29 +2,221 @@
 package task
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 const (
 	// TabWidth is the number of spaces a tab character represents for indentation.
 	TabWidth = 2
+
+	// DefaultGuardLines is the line count above which LooksLikeTaskFile checks the task ratio.
+	DefaultGuardLines = 100
+
+	// DefaultGuardTaskRatio is the minimum share of task lines for a large file to look like a task list.
+	DefaultGuardTaskRatio = 0.01
+
+	// DefaultMaxFileSizeMB is the size limit LoadFile applies by default, in megabytes.
+	DefaultMaxFileSizeMB = 10
+
+	// binarySniffLen is how much of a file LoadFile checks for NUL bytes.
+	binarySniffLen = 8 << 10
 )
 
-var (
-	// completedPattern matches completed task lines: "- [x]" or "- [X]"
-	completedPattern = regexp.MustCompile(`^\s*-\s*\[[xX]\]`)
+// DefaultBulletStyles is the list bullet recognized in front of task checkboxes by default.
+// Tasks created by ttt always use "-".
+var DefaultBulletStyles = []string{"-"}
 
-	// taskPattern matches any task line: "- [ ]" or "- [x]" (with optional leading whitespace)
-	taskPattern = regexp.MustCompile(`^\s*-\s*\[[xX ]\]`)
+// validBulletStyles are the Markdown list bullets that ValidateBulletStyles accepts.
+var validBulletStyles = map[string]bool{"-": true, "*": true, "+": true}
 
+var (
 	// doneTagPattern matches @done(YYYY-MM-DD) format
 	doneTagPattern = regexp.MustCompile(`@done\((\d{4}-\d{2}-\d{2})\)`)
+
+	// priorityTagPattern matches @priority(A), @priority(B), or @priority(C) (case-insensitive letter)
+	priorityTagPattern = regexp.MustCompile(`@priority\(([AaBbCc])\)`)
+
+	// numberedPriorityTagPattern matches a whole @p1, @p2, or @p3 tag (capturing the tag and its number)
+	numberedPriorityTagPattern = regexp.MustCompile(`(?:^|\s)(@p([123]))(?:\s|$)`)
+
+	// trackTagPattern matches @track(1h23m), @track(2h), or @track(45m)
+	trackTagPattern = regexp.MustCompile(`@track\((\d+h\d+m|\d+h|\d+m)\)`)
+
+	// anyTagPattern matches any tag at the start of the text or after whitespace:
+	// "@waiting", " @due(2026-01-20)" (capturing the tag). The "@b" of "a@b.com" is not a tag.
+	anyTagPattern = regexp.MustCompile(`(?:^|\s)(@[\w-]+(?:\([^)]*\))?)`)
+
+	// codeFencePattern matches a code fence line: "```", "~~~", "```go" (capturing the fence)
+	codeFencePattern = regexp.MustCompile("^\\s*(`{3,}|~{3,})")
+
+	// quotePattern matches a Markdown block quote line: "> ..."
+	quotePattern = regexp.MustCompile(`^\s*>`)
+
+	// listItemPattern matches a list item of any kind: "- note", "* note", "1. step", "2) step"
+	listItemPattern = regexp.MustCompile(`^\s*([-*+]|\d+[.)])(\s|$)`)
 )
 
+// ValidateBulletStyles checks that styles is a non-empty list of "-", "*", or "+".
+func ValidateBulletStyles(styles []string) error {
+	if len(styles) == 0 {
+		return fmt.Errorf("at least one bullet style is required")
+	}
+	for _, style := range styles {
+		if !validBulletStyles[style] {
+			return fmt.Errorf("invalid bullet style %q (use \"-\", \"*\", or \"+\")", style)
+		}
+	}
+	return nil
+}
+
+// Values of Options.Cascade (tasks.cascade): how far completing a parent completes
+// the tasks below it.
+const (
+	CascadeAll    = "all"    // every descendant
+	CascadeDirect = "direct" // first-level children only
+	CascadeOff    = "off"    // no cascade; only @done tags are added
+)
+
+// Options are the settings that change how this package reads, completes, archives,
+// and writes tasks. config.Config.TaskOptions builds them from config.toml. The zero
+// value behaves like DefaultOptions, the settings of a config.toml that sets nothing.
+type Options struct {
+	// The list bullets accepted in front of a checkbox, e.g. {"-", "*"} to also
+	// recognize "* [ ]" tasks, and whether ordered list items with a checkbox ("1. [ ]
+	// step", "2) [x] step") are tasks too. Tasks created by ttt always use "-".
+	BulletStyles []string
+	Numbered     bool
+
+	// How ProcessContent completes tasks: how far a completed parent cascades (one of
+	// the Cascade constants, "" being CascadeAll; see CascadeCompletion), whether
+	// CascadeAll also completes the subtasks reopened by hand below a parent done on
+	// an earlier day (see cascadeFires), and whether an open parent is completed once
+	// every task below it is done (see PropagateCompletionUpward).
+	Cascade             string
+	CascadeIgnoreManual bool
+	AutoCompleteParent  bool
+
+	EscalateOverdueDays int  // raise open tasks overdue by more than this many days (see EscalatePriority); 0 = off
+	CompletedToBottom   bool // move completed tasks below the open ones when tagging (see PartitionByCompletion)
+
+	// DoneDateOptional stops adding @done(today) to completed tasks. FilterArchivable
+	// then treats a completed task without one as done on the day the file was last
+	// modified (see filterArchivable).
+	DoneDateOptional bool
+	// ArchiveWhenChildrenDone makes FilterArchivable also archive an open root task
+	// once every task below it is done (see allDescendantsDone).
+	ArchiveWhenChildrenDone bool
+
+	MaxFileSizeMB int  // LoadFile refuses larger files; 0 = DefaultMaxFileSizeMB, negative = no limit
+	MaxDepth      int  // InsertChild refuses to nest deeper, a top-level task being level 1; 0 = no limit
+	TidyOnWrite   bool // WriteTasksFile tidies the content first (see Tidy)
+	WrapColumn    int  // WrapTaskLine, and so InsertChild, wraps task text wider than this; 0 = off
+
+	// Logger receives the debug events of --verbose: the counts of every
+	// ProcessContent and FilterArchivable pass, the archive cutoff of each heading,
+	// and the size of every file written. Nil discards them.
+	Logger *slog.Logger
+}
+
+// DefaultOptions returns the Options of a config.toml that sets nothing.
+func DefaultOptions() Options {
+	return Options{
+		BulletStyles:  DefaultBulletStyles,
+		Cascade:       CascadeAll,
+		MaxFileSizeMB: DefaultMaxFileSizeMB,
+	}
+}
+
+// cascade returns o.Cascade, or CascadeAll when it is unset.
+func (o Options) cascade() string {
+	if o.Cascade == "" {
+		return CascadeAll
+	}
+	return o.Cascade
+}
+
+// maxFileSizeMB returns the size limit of LoadFile in megabytes, 0 for none.
+func (o Options) maxFileSizeMB() int {
+	switch {
+	case o.MaxFileSizeMB == 0:
+		return DefaultMaxFileSizeMB
+	case o.MaxFileSizeMB < 0:
+		return 0
+	}
+	return o.MaxFileSizeMB
+}
+
+// discardLogger is the logger of Options without one.
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// logger returns o.Logger, or discardLogger when it is nil.
+func (o Options) logger() *slog.Logger {
+	if o.Logger == nil {
+		return discardLogger
+	}
+	return o.Logger
+}
+
+// taskPatterns are the task line patterns for one set of task markers.
+type taskPatterns struct {
+	// task matches any task line: "- [ ]" or "- [x]" (with optional leading whitespace)
+	task *regexp.Regexp
+	// completed matches completed task lines: "- [x]" or "- [X]"
+	completed *regexp.Regexp
+	// completedBox matches the checkbox of a completed task, capturing the part before it
+	completedBox *regexp.Regexp
+}
+
+// compiledPatterns caches the taskPatterns of each task marker pattern, so they are
+// compiled once rather than for every line.
+var compiledPatterns sync.Map
+
+// patterns returns the task line patterns for the task markers of o. Bullets other
+// than "-", "*", and "+" are ignored (see ValidateBulletStyles), and without any the
+// DefaultBulletStyles apply.
+func (o Options) patterns() *taskPatterns {
+	var class strings.Builder
+	for _, style := range o.BulletStyles {
+		if validBulletStyles[style] {
+			// Escape every bullet so "-" is never read as a character range
+			class.WriteString(`\` + style)
+		}
+	}
+	if class.Len() == 0 {
+		class.WriteString(`\-`)
+	}
+	marker := `[` + class.String() + `]`
+	if o.Numbered {
+		marker = `(?:` + marker + `|\d+[.)])`
+	}
+
+	if p, ok := compiledPatterns.Load(marker); ok {
+		return p.(*taskPatterns)
+	}
+	p := &taskPatterns{
+		task:         regexp.MustCompile(`^\s*` + marker + `\s*\[[xX ]\]`),
+		completed:    regexp.MustCompile(`^\s*` + marker + `\s*\[[xX]\]`),
+		completedBox: regexp.MustCompile(`^(\s*` + marker + `\s*)\[[xX]\]`),
+	}
+	compiledPatterns.Store(marker, p)
+	return p
+}
+
 // ParsedLine represents a line with its hierarchical context.
 type ParsedLine struct {
 	LineNumber  int    // 0-indexed position in file
@@ -33,6 +225,8 @@ type ParsedLine struct {
 	IsTask      bool   // Whether this is a task line (- [ ] or - [x])
 	IsCompleted bool   // Whether the task is completed
 	HasDoneTag  bool   // Whether @done tag exists
+	InCodeBlock bool   // Whether the line is a code fence or inside a fenced code block
+	FrontMatter bool   // Whether the line belongs to the leading YAML front matter block
 }
 
 // TaskTree represents a task with its children for hierarchical operations.
@@ -46,6 +240,42 @@ type TaskTree struct {
 type ArchiveTask struct {
 	Content   string    // Original line content
 	GroupDate time.Time // Date to use for archive section grouping
+	Heading   string    // Text of the nearest heading above the line ("" if none)
+	IsTask    bool      // Whether the line is a task (false for notes moved with their parent)
+}
+
+// CountArchiveTasks returns how many of the archived lines are tasks, leaving out
+// notes and other non-task lines that moved with their parent.
+func CountArchiveTasks(tasks []ArchiveTask) int {
+	count := 0
+	for _, t := range tasks {
+		if t.IsTask {
+			count++
+		}
+	}
+	return count
+}
+
+// CountOpenTasks returns the number of open tasks in content, subtasks included.
+// Lines in code blocks and block quotes are not counted (see ParseLines).
+func CountOpenTasks(content string, opts Options) int {
+	count := 0
+	for _, pl := range ParseLines(content, opts) {
+		if pl.IsTask && !pl.IsCompleted {
+			count++
+		}
+	}
+	return count
+}
+
+// OpenLimitMessage returns the warning for open tasks over the tasks.open_limit
+// setting limit, e.g. "You have 43 open tasks (limit 30) — consider archiving or
+// pruning", or "" when limit is 0 (off) or not exceeded.
+func OpenLimitMessage(open, limit int) string {
+	if limit <= 0 || open <= limit {
+		return ""
+	}
+	return fmt.Sprintf("You have %d open tasks (limit %d) — consider archiving or pruning", open, limit)
 }
 
 // GetIndentLevel returns the number of leading spaces in a line.
@@ -65,14 +295,15 @@ func GetIndentLevel(line string) int {
 	return count
 }
 
-// IsTask returns true if the line is a task (- [ ] or - [x]).
-func IsTask(line string) bool {
-	return taskPattern.MatchString(line)
+// IsTask returns true if the line is a task (- [ ] or - [x]), behind one of the task
+// markers of opts (see Options.BulletStyles).
+func IsTask(line string, opts Options) bool {
+	return opts.patterns().task.MatchString(line)
 }
 
 // IsCompleted returns true if the line is a completed task (- [x] or - [X]).
-func IsCompleted(line string) bool {
-	return completedPattern.MatchString(line)
+func IsCompleted(line string, opts Options) bool {
+	return opts.patterns().completed.MatchString(line)
 }
 
 // HasDoneTag returns true if the line contains a valid @done(YYYY-MM-DD) tag.
@@ -80,10 +311,11 @@ func HasDoneTag(line string) bool {
 	return doneTagPattern.MatchString(line)
 }
 
-// AddDoneTag adds @done(today) to a completed task if it doesn't already have one.
+// AddDoneTag adds @done(today) to a completed task if it doesn't already have one,
+// unless opts.DoneDateOptional is set.
 // Returns the modified line and whether it was changed.
-func AddDoneTag(line string) (string, bool) {
-	if !IsCompleted(line) {
+func AddDoneTag(line string, opts Options) (string, bool) {
+	if !IsCompleted(line, opts) || opts.DoneDateOptional {
 		return line, false
 	}
 
@@ -92,7 +324,18 @@ func AddDoneTag(line string) (string, bool) {
 	}
 
 	today := time.Now().Format("2006-01-02")
-	return line + " @done(" + today + ")", true
+	return appendDoneTag(line, today, opts), true
+}
+
+// appendDoneTag appends @done(date) as the last element of the line.
+// Trailing whitespace is removed first so the tag is separated by exactly one space.
+// Other tags (e.g. @due) are left in place, so @done always follows them.
+// With opts.DoneDateOptional, the line is returned unchanged.
+func appendDoneTag(line, date string, opts Options) string {
+	if opts.DoneDateOptional {
+		return line
+	}
+	return strings.TrimRight(line, " \t") + " @done(" + date + ")"
 }
 
 // ParseDoneDate extracts the date from a @done(YYYY-MM-DD) tag.
@@ -111,26 +354,636 @@ func ParseDoneDate(line string) (time.Time, bool) {
 	return date, true
 }
 
+// ParsePriority extracts the priority from a @priority(A|B|C) tag, or from a @p1,
+// @p2, or @p3 tag, which stand for A, B, and C.
+// Returns the uppercase priority letter and true if found, "" and false otherwise.
+func ParsePriority(line string) (string, bool) {
+	_, _, priority, ok := findPriority(line)
+	return priority, ok
+}
+
+// findPriority returns the position of the priority tag of line (see ParsePriority)
+// and its priority letter. @priority(X) is used when a line has both forms.
+func findPriority(line string) (start, end int, priority string, ok bool) {
+	if m := priorityTagPattern.FindStringSubmatchIndex(line); m != nil {
+		return m[0], m[1], strings.ToUpper(line[m[2]:m[3]]), true
+	}
+	if m := numberedPriorityTagPattern.FindStringSubmatchIndex(line); m != nil {
+		return m[2], m[3], string(rune('A' + line[m[4]] - '1')), true
+	}
+	return 0, 0, "", false
+}
+
+// EscalatePriority raises a task to @p1, the highest priority: @p2 or @p3 (or
+// @priority(B) or @priority(C)) is replaced, and a task without priority gets @p1 appended.
+// Returns the line unchanged and false if it already has @p1 or @priority(A).
+func EscalatePriority(line string) (string, bool) {
+	start, end, priority, ok := findPriority(line)
+	if !ok {
+		return strings.TrimRight(line, " \t") + " @p1", true
+	}
+	if priority == "A" {
+		return line, false
+	}
+	return line[:start] + "@p1" + line[end:], true
+}
+
+// TaskBody returns the text of a task line without its marker and tags:
+// "  - [x] Review PR @done(2026-01-20)" → "Review PR".
+func TaskBody(line string, opts Options) string {
+	return withoutTags(opts.patterns().task.ReplaceAllString(line, ""))
+}
+
+// ReplaceBody is ReplaceBodyWith with DefaultOptions.
+func ReplaceBody(line, newBody string) string {
+	return ReplaceBodyWith(line, newBody, DefaultOptions())
+}
+
+// ReplaceBodyWith replaces the text of a task line (see TaskBody) with newBody, keeping
+// the indentation, the "- [ ]" marker, and every tag. Tags are kept in their order
+// and placed after the new text. A line that is not a task is returned unchanged.
+func ReplaceBodyWith(line, newBody string, opts Options) string {
+	marker := opts.patterns().task.FindString(line)
+	if marker == "" {
+		return line
+	}
+	parts := []string{marker}
+	if body := strings.TrimSpace(newBody); body != "" {
+		parts = append(parts, body)
+	}
+	parts = append(parts, findTags(line[len(marker):])...)
+	return strings.Join(parts, " ")
+}
+
+// AddTrackedTime adds d, rounded to the minute, to the @track(1h23m) tag of line.
+// An existing @track tag is replaced by the sum; otherwise the tag is appended.
+func AddTrackedTime(line string, d time.Duration) string {
+	loc := trackTagPattern.FindStringSubmatchIndex(line)
+	if loc == nil {
+		return strings.TrimRight(line, " \t") + " @track(" + FormatEffort(d) + ")"
+	}
+	// The pattern only matches valid durations
+	existing, _ := ParseEffort(line[loc[2]:loc[3]])
+	return line[:loc[0]] + "@track(" + FormatEffort(existing+d) + ")" + line[loc[1]:]
+}
+
+// overdueBy reports whether line has a @due date more than days before today.
+func overdueBy(line string, days int, today time.Time) bool {
+	due, ok := DueDate(line, today)
+	return ok && due.Before(today.AddDate(0, 0, -days))
+}
+
+// HumanizeDate describes date relative to now by calendar day:
+// "今日", "昨日", "N日前", or "明日" / "N日後" for future dates.
+func HumanizeDate(date, now time.Time) string {
+	d := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	n := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(n.Sub(d).Hours() / 24)
+
+	switch {
+	case days == 0:
+		return "今日"
+	case days == 1:
+		return "昨日"
+	case days == -1:
+		return "明日"
+	case days < 0:
+		return strconv.Itoa(-days) + "日後"
+	default:
+		return strconv.Itoa(days) + "日前"
+	}
+}
+
+// FormatDoneTagsRelative replaces every @done(YYYY-MM-DD) in content with
+// @done(<relative date>) using HumanizeDate. Used for display only.
+func FormatDoneTagsRelative(content string, now time.Time) string {
+	return doneTagPattern.ReplaceAllStringFunc(content, func(tag string) string {
+		date, ok := ParseDoneDate(tag)
+		if !ok {
+			return tag
+		}
+		return "@done(" + HumanizeDate(date, now) + ")"
+	})
+}
+
+// taskText returns the task description without indentation, checkbox, and @done tag.
+func taskText(line string, opts Options) string {
+	text := opts.patterns().task.ReplaceAllString(line, "")
+	text = doneTagPattern.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
+
+// withoutTags removes all tags from text and collapses the remaining whitespace.
+func withoutTags(text string) string {
+	return strings.Join(strings.Fields(anyTagPattern.ReplaceAllString(text, " ")), " ")
+}
+
+// findTags returns the tags in text in their order, without the whitespace before them.
+func findTags(text string) []string {
+	var tags []string
+	for _, m := range anyTagPattern.FindAllStringSubmatch(text, -1) {
+		tags = append(tags, m[1])
+	}
+	return tags
+}
+
+// HasDuplicate is HasDuplicateWith with DefaultOptions.
+func HasDuplicate(content, taskText string) bool {
+	return HasDuplicateWith(content, taskText, DefaultOptions())
+}
+
+// HasDuplicateWith reports whether content already has an open task whose text is exactly taskText.
+// Completed tasks are ignored, so a finished task can be added again.
+func HasDuplicateWith(content, taskText string, opts Options) bool {
+	return hasDuplicate(content, strings.TrimSpace(taskText), strings.TrimSpace, opts)
+}
+
+// HasDuplicateIgnoringTags is like HasDuplicate, but compares the text with all tags removed,
+// so "Buy milk @errand" duplicates "Buy milk".
+func HasDuplicateIgnoringTags(content, taskText string, opts Options) bool {
+	return hasDuplicate(content, withoutTags(taskText), withoutTags, opts)
+}
+
+func hasDuplicate(content, text string, normalize func(string) string, opts Options) bool {
+	if text == "" {
+		return false
+	}
+	for _, line := range ParseLines(content, opts) {
+		if line.IsTask && !line.IsCompleted && normalize(taskText(line.Content, opts)) == text {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveDoneConflicts merges completed tasks that were done on several devices and
+// came back from a sync as separate lines with different @done dates. Completed
+// tasks with the same indentation and the same text ignoring tags become one line:
+// the first one, dated with the oldest @done date. Tasks with indented lines below
+// them are left alone, so no subtree loses its parent.
+// Returns the resolved content and the count of lines removed.
+func ResolveDoneConflicts(content string, opts Options) (string, int) {
+	lines := ParseLines(content, opts)
+
+	type first struct {
+		index int
+		date  string
+	}
+	seen := make(map[string]*first)
+	remove := make(map[int]bool)
+
+	for i, line := range lines {
+		if !line.IsCompleted || !line.HasDoneTag || hasChildren(lines, i) {
+			continue
+		}
+		date := doneTagPattern.FindStringSubmatch(line.Content)[1]
+		key := strconv.Itoa(line.Indent) + "\x00" + withoutTags(taskText(line.Content, opts))
+		f, ok := seen[key]
+		if !ok {
+			seen[key] = &first{index: i, date: date}
+			continue
+		}
+		if date < f.date {
+			f.date = date
+			lines[f.index].Content = doneTagPattern.ReplaceAllString(lines[f.index].Content, "@done("+date+")")
+		}
+		remove[i] = true
+	}
+
+	if len(remove) == 0 {
+		return content, 0
+	}
+	var result []ParsedLine
+	for i, line := range lines {
+		if !remove[i] {
+			result = append(result, line)
+		}
+	}
+	return ReconstructContent(result), len(remove)
+}
+
+// hasChildren reports whether lines[i] has a non-blank, more indented line right below it.
+func hasChildren(lines []ParsedLine, i int) bool {
+	return i+1 < len(lines) && strings.TrimSpace(lines[i+1].Content) != "" && lines[i+1].Indent > lines[i].Indent
+}
+
+// ResolveDoneConflictsFile applies ResolveDoneConflicts to the file at path and
+// writes it back when anything changed. A missing file resolves nothing.
+func ResolveDoneConflictsFile(path string, opts Options) (int, error) {
+	content, err := LoadFile(path, opts)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	resolved, count := ResolveDoneConflicts(content, opts)
+	if count > 0 {
+		if err := WriteTasksFile(path, resolved, opts); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+// DiffLines counts the lines added and removed between two versions of content.
+// A changed line counts as one removal and one addition. Lines are matched with
+// a longest common subsequence after trimming the common prefix and suffix.
+func DiffLines(before, after string) (added, removed int) {
+	a := splitContentLines(before)
+	b := splitContentLines(after)
+
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	// lcs[j] holds the LCS length of a[i:] and b[j:] for the current row i
+	lcs := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		prev := 0 // lcs of a[i+1:] and b[j+1:]
+		for j := len(b) - 1; j >= 0; j-- {
+			current := lcs[j]
+			if a[i] == b[j] {
+				lcs[j] = prev + 1
+			} else if lcs[j+1] > lcs[j] {
+				lcs[j] = lcs[j+1]
+			}
+			prev = current
+		}
+	}
+
+	common := lcs[0]
+	return len(b) - common, len(a) - common
+}
+
+// splitContentLines splits content into lines, ignoring a trailing newline.
+func splitContentLines(content string) []string {
+	content = strings.TrimSuffix(content, "\n")
+	if content == "" {
+		return nil
+	}
+	return strings.Split(content, "\n")
+}
+
+// EnsureTitle puts title (e.g. "# Tasks") at the top of content, followed by a blank line,
+// unless content already has a Markdown heading anywhere. YAML front matter stays first,
+// with the title below it. An empty title changes nothing.
+func EnsureTitle(content, title string) string {
+	if title == "" {
+		return content
+	}
+	frontMatter, body := SplitFrontMatter(content)
+	for _, line := range strings.Split(body, "\n") {
+		if headingPattern.MatchString(line) {
+			return content
+		}
+	}
+	if frontMatter != "" && !strings.HasSuffix(frontMatter, "\n") {
+		frontMatter += "\n"
+	}
+	return frontMatter + title + "\n\n" + strings.TrimLeft(body, "\n")
+}
+
+// SplitFrontMatter splits content into its leading YAML front matter block, including
+// both "---" delimiters and the newline after the closing one, and the rest.
+// frontMatter is "" when content does not start with a closed front matter block.
+func SplitFrontMatter(content string) (frontMatter, body string) {
+	n := frontMatterLines(strings.Split(content, "\n"))
+	if n == 0 {
+		return "", content
+	}
+	end := 0
+	for i := 0; i < n; i++ {
+		next := strings.IndexByte(content[end:], '\n')
+		if next < 0 {
+			return content, ""
+		}
+		end += next + 1
+	}
+	return content[:end], content[end:]
+}
+
+// frontMatterLines returns how many of lines form a leading YAML front matter block:
+// a "---" first line through the next "---" (or "...") line. It returns 0 when the
+// first line is not "---" or the block is never closed, so a lone "---" horizontal
+// rule is not mistaken for front matter.
+func frontMatterLines(lines []string) int {
+	if len(lines) == 0 || strings.TrimRight(lines[0], " \t\r") != "---" {
+		return 0
+	}
+	for i := 1; i < len(lines); i++ {
+		switch strings.TrimRight(lines[i], " \t\r") {
+		case "---", "...":
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// prefixPattern matches a quick-add prefix: "work: review PR" → "work", "review PR"
+var prefixPattern = regexp.MustCompile(`^([^\s:]+):\s*(\S.*)$`)
+
+// headingLevelPattern captures the "#" run of a Markdown heading
+var headingLevelPattern = regexp.MustCompile(`^(#{1,6})\s`)
+
+// RouteByPrefix is RouteByPrefixWith with DefaultOptions.
+func RouteByPrefix(content, text string) (heading string, remainder string, matched bool) {
+	return RouteByPrefixWith(content, text, DefaultOptions())
+}
+
+// RouteByPrefixWith splits a leading "word:" prefix off text when word matches the
+// text of a "##" heading in content, ignoring case. Only an exact match counts:
+// "work:" picks "## Work", not "## Workshop". Returns the heading as written in
+// content and the rest of text; when nothing matches, text is returned unchanged
+// with matched false, so colons inside a task are left alone.
+func RouteByPrefixWith(content, text string, opts Options) (heading string, remainder string, matched bool) {
+	m := prefixPattern.FindStringSubmatch(text)
+	if m == nil {
+		return "", text, false
+	}
+	for _, line := range ParseLines(content, opts) {
+		if line.InCodeBlock || line.FrontMatter {
+			continue
+		}
+		h := archiveHeaderPattern.FindStringSubmatch(line.Content)
+		if h != nil && strings.EqualFold(h[1], m[1]) {
+			return h[1], m[2], true
+		}
+	}
+	return "", text, false
+}
+
+// Insertion tells where InsertUnderHeading or InsertChild put the new line: Line is
+// its 1-indexed line number, and Heading the nearest heading line above it, such as
+// "## Inbox" ("" when there is none).
+type Insertion struct {
+	Line    int
+	Heading string
+}
+
+// insertionAt returns the Insertion for a line inserted at index i of lines.
+func insertionAt(lines []string, i int, opts Options) Insertion {
+	ins := Insertion{Line: i + 1}
+	parsed := ParseLines(strings.Join(lines[:i], "\n"), opts)
+	for j := i - 1; j >= 0 && j < len(parsed); j-- {
+		if !parsed[j].InCodeBlock && !parsed[j].FrontMatter && headingLevelPattern.MatchString(parsed[j].Content) {
+			ins.Heading = strings.TrimSpace(parsed[j].Content)
+			break
+		}
+	}
+	return ins
+}
+
+// InsertUnderHeading adds line at the end of the "## heading" section, after its
+// last non-blank line and before the next "#" or "##" heading. If heading is
+// empty or not found, line is appended to the end of content. Front matter is
+// never searched for headings, so line never lands inside it. The Insertion says
+// where line went.
+func InsertUnderHeading(content, heading, line string, opts Options) (string, Insertion) {
+	lines := ParseLines(content, opts)
+	start := -1
+	for i, l := range lines {
+		if l.InCodeBlock || l.FrontMatter {
+			continue
+		}
+		if h := archiveHeaderPattern.FindStringSubmatch(l.Content); h != nil && heading != "" && h[1] == heading {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		existing := strings.Split(content, "\n")
+		return content + line + "\n", insertionAt(existing, len(existing)-1, opts)
+	}
+
+	end := len(lines)
+	for i := start + 1; i < len(lines); i++ {
+		if lines[i].InCodeBlock {
+			continue
+		}
+		if m := headingLevelPattern.FindStringSubmatch(lines[i].Content); m != nil && len(m[1]) <= 2 {
+			end = i
+			break
+		}
+	}
+	insert := end
+	for insert > start+1 && strings.TrimSpace(lines[insert-1].Content) == "" {
+		insert--
+	}
+
+	result := make([]string, 0, len(lines)+1)
+	for _, l := range lines[:insert] {
+		result = append(result, l.Content)
+	}
+	result = append(result, line)
+	for _, l := range lines[insert:] {
+		result = append(result, l.Content)
+	}
+	return strings.Join(result, "\n"), insertionAt(result, insert, opts)
+}
+
+// InsertChild adds "- [ ] taskText" as the last subtask of the task on parentLine
+// (1-indexed, as printed by "ttt search"): below the parent's existing subtasks and
+// indented TabWidth spaces deeper than the parent, wrapped by WrapTaskLine, and says
+// where it went. Returns an error if parentLine is not a task line, or if the subtask
+// would be nested deeper than opts.MaxDepth allows.
+func InsertChild(content string, parentLine int, taskText string, opts Options) (string, Insertion, error) {
+	lines := ParseLines(content, opts)
+	parent := parentLine - 1
+	if parent < 0 || parent >= len(lines) || !lines[parent].IsTask {
+		return "", Insertion{}, fmt.Errorf("line %d is not a task", parentLine)
+	}
+	if depth := TaskDepths(lines)[parent] + 1; opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return "", Insertion{}, fmt.Errorf("a subtask of line %d would be %d levels deep, over the limit of %d", parentLine, depth, opts.MaxDepth)
+	}
+
+	insert := parent + 1
+	for insert < len(lines) && strings.TrimSpace(lines[insert].Content) != "" && lines[insert].Indent > lines[parent].Indent {
+		insert++
+	}
+
+	child := WrapTaskLine(strings.Repeat(" ", lines[parent].Indent+TabWidth)+"- [ ] "+taskText, opts)
+	result := make([]string, 0, len(lines)+1)
+	for _, l := range lines[:insert] {
+		result = append(result, l.Content)
+	}
+	result = append(result, child)
+	for _, l := range lines[insert:] {
+		result = append(result, l.Content)
+	}
+	return strings.Join(result, "\n"), insertionAt(result, insert, opts), nil
+}
+
+// ExtractSubtree is ExtractSubtreeWith with DefaultOptions.
+func ExtractSubtree(content string, line int) (subtree, remaining string, err error) {
+	return ExtractSubtreeWith(content, line, DefaultOptions())
+}
+
+// ExtractSubtreeWith cuts the task on line (0-indexed) out of content together with the
+// lines nested under it (see subtreeRange). subtree is the cut block, shifted left by
+// the task's indentation so it can be inserted at the top level of another file, and
+// without a trailing newline; remaining is content without the block.
+// Returns an error if line is not a task line.
+func ExtractSubtreeWith(content string, line int, opts Options) (subtree, remaining string, err error) {
+	parsed := ParseLines(content, opts)
+	if line < 0 || line >= len(parsed) || !parsed[line].IsTask {
+		return "", "", fmt.Errorf("line %d is not a task", line+1)
+	}
+
+	lines := strings.Split(content, "\n")
+	_, end := subtreeRange(lines, line)
+	indent := lines[line][:len(lines[line])-len(strings.TrimLeft(lines[line], " \t"))]
+	block := make([]string, 0, end-line)
+	for _, l := range lines[line:end] {
+		if strings.TrimSpace(l) == "" {
+			l = ""
+		}
+		block = append(block, strings.TrimPrefix(l, indent))
+	}
+
+	rest := append(lines[:line:line], lines[end:]...)
+	return strings.Join(block, "\n"), strings.Join(rest, "\n"), nil
+}
+
+// ReindentBlock shifts the lines of block so that its first line is indented by
+// targetIndent spaces, keeping the indentation of the other lines relative to it.
+// Leading tabs count as TabWidth spaces and are written as spaces; lines that would
+// move left of column 0 start at column 0, and blank lines become empty.
+func ReindentBlock(block string, targetIndent int) string {
+	lines := strings.Split(block, "\n")
+	base := -1
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			lines[i] = ""
+			continue
+		}
+		indent := GetIndentLevel(l)
+		if base < 0 {
+			base = indent
+		}
+		lines[i] = strings.Repeat(" ", max(indent-base+targetIndent, 0)) + strings.TrimLeft(l, " \t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PasteBlock inserts block (such as a subtree cut by ExtractSubtree) below line
+// (0-indexed) of content, indented like that line (see ReindentBlock). Below a task,
+// the block goes after the lines nested under it, so it becomes the task's sibling
+// instead of taking over its subtasks. Returns an error if line is out of range.
+func PasteBlock(content string, line int, block string, opts Options) (string, error) {
+	lines := strings.Split(content, "\n")
+	if line < 0 || line >= len(lines) {
+		return "", fmt.Errorf("line %d is out of range", line+1)
+	}
+
+	insert := line + 1
+	if IsTask(lines[line], opts) {
+		if _, end := subtreeRange(lines, line); end > insert {
+			insert = end
+		}
+	}
+	pasted := strings.Split(ReindentBlock(block, GetIndentLevel(lines[line])), "\n")
+	result := make([]string, 0, len(lines)+len(pasted))
+	result = append(result, lines[:insert]...)
+	result = append(result, pasted...)
+	result = append(result, lines[insert:]...)
+	return strings.Join(result, "\n"), nil
+}
+
+// LooksLikeTaskFile reports whether content plausibly is a task list, using
+// DefaultGuardLines and DefaultGuardTaskRatio. See LooksLikeTaskFileWith.
+func LooksLikeTaskFile(content string, opts Options) bool {
+	return LooksLikeTaskFileWith(content, DefaultGuardLines, DefaultGuardTaskRatio, opts)
+}
+
+// LooksLikeTaskFileWith reports whether content plausibly is a task list.
+// Files with at most maxLines lines always pass. Larger files pass only if at least
+// minRatio of their lines are tasks. A maxLines of 0 or less disables the check.
+// Used to avoid rewriting an unrelated file (e.g. a README) when working_dir is wrong.
+func LooksLikeTaskFileWith(content string, maxLines int, minRatio float64, opts Options) bool {
+	if maxLines <= 0 {
+		return true
+	}
+
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if len(lines) <= maxLines {
+		return true
+	}
+
+	tasks := 0
+	for _, line := range lines {
+		if IsTask(line, opts) {
+			tasks++
+		}
+	}
+	return float64(tasks) >= float64(len(lines))*minRatio
+}
+
 // ParseLines parses content into a slice of ParsedLine structs.
-// Each line is annotated with its indent level, task status, and completion state.
-func ParseLines(content string) []ParsedLine {
+// Each line is annotated with its indent level, task status (see IsTask), and
+// completion state. Lines in fenced code blocks (``` or ~~~) and block quotes ("> ...") are never tasks,
+// so examples such as "> - [ ] item" are left alone by tagging and archiving.
+// Leading YAML front matter (as added by Obsidian) is marked FrontMatter and holds no tasks.
+func ParseLines(content string, opts Options) []ParsedLine {
 	rawLines := strings.Split(content, "\n")
 	result := make([]ParsedLine, len(rawLines))
+	frontMatter := frontMatterLines(rawLines)
+	patterns := opts.patterns()
 
+	fence := "" // opening fence of the code block we are in, "" outside
 	for i, line := range rawLines {
+		if i < frontMatter {
+			result[i] = ParsedLine{
+				LineNumber:  i,
+				Content:     line,
+				Indent:      GetIndentLevel(line),
+				FrontMatter: true,
+			}
+			continue
+		}
+
+		inCode := fence != ""
+		if m := codeFencePattern.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[1]
+				inCode = true
+			} else if closesFence(line, fence) {
+				fence = ""
+			}
+		}
+
+		isTask := !inCode && !quotePattern.MatchString(line) && patterns.task.MatchString(line)
 		result[i] = ParsedLine{
 			LineNumber:  i,
 			Content:     line,
 			Indent:      GetIndentLevel(line),
-			IsTask:      IsTask(line),
-			IsCompleted: IsCompleted(line),
+			IsTask:      isTask,
+			IsCompleted: isTask && patterns.completed.MatchString(line),
 			HasDoneTag:  HasDoneTag(line),
+			InCodeBlock: inCode,
 		}
 	}
 
 	return result
 }
 
+// closesFence reports whether line closes a code block opened with fence:
+// the same fence character, at least as many of them, and nothing else on the line.
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) < len(fence) {
+		return false
+	}
+	return strings.Trim(trimmed, fence[:1]) == ""
+}
+
 // BuildTaskTrees builds a forest of task trees from parsed lines.
 // Children are determined by having greater indentation than their parent.
 // Non-task lines are ignored for hierarchy building but preserved in content.
@@ -166,62 +1019,321 @@ func BuildTaskTrees(lines []ParsedLine) []*TaskTree {
 	return forest
 }
 
-// CascadeCompletion cascades completion status from parent tasks to children.
-// When a parent is completed, all children are marked completed with @done(today).
+// TaskDepths returns the nesting level of each task in lines, keyed by index into
+// lines: 1 for a top-level task, 2 for its subtasks, and so on (see BuildTaskTrees).
+func TaskDepths(lines []ParsedLine) map[int]int {
+	depths := make(map[int]int)
+	var walk func(trees []*TaskTree, depth int)
+	walk = func(trees []*TaskTree, depth int) {
+		for _, tree := range trees {
+			depths[tree.Line.LineNumber] = depth
+			walk(tree.Children, depth+1)
+		}
+	}
+	walk(BuildTaskTrees(lines), 1)
+	return depths
+}
+
+// TooDeepTasks returns the task lines of content nested more than maxDepth levels
+// deep (see TaskDepths). A maxDepth of 0 or less reports nothing.
+func TooDeepTasks(content string, maxDepth int, opts Options) []ParsedLine {
+	if maxDepth <= 0 {
+		return nil
+	}
+	lines := ParseLines(content, opts)
+	var deep []ParsedLine
+	for i, depth := range TaskDepths(lines) {
+		if depth > maxDepth {
+			deep = append(deep, lines[i])
+		}
+	}
+	sort.Slice(deep, func(a, b int) bool { return deep[a].LineNumber < deep[b].LineNumber })
+	return deep
+}
+
+// CascadeCompletion cascades completion status from parent tasks to children, as
+// far as opts.Cascade says. With CascadeAll, when a parent is completed, all
+// descendants are marked completed with @done(today), except below a parent done on
+// an earlier day unless opts.CascadeIgnoreManual is set (see cascadeFires). With
+// CascadeDirect, a parent completed since the last pass (no @done tag yet) completes
+// only its first-level children, so the children it completes never cascade further
+// on later passes. CascadeOff changes nothing.
 // Returns the modified lines and the count of newly completed tasks.
-func CascadeCompletion(lines []ParsedLine, today string) ([]ParsedLine, int) {
+func CascadeCompletion(lines []ParsedLine, today string, opts Options) ([]ParsedLine, int) {
 	trees := BuildTaskTrees(lines)
 	count := 0
 
 	for _, tree := range trees {
-		count += cascadeCompletionRecursive(tree, lines, today)
+		switch opts.cascade() {
+		case CascadeAll:
+			count += cascadeCompletionRecursive(tree, lines, today, opts)
+		case CascadeDirect:
+			count += cascadeDirectRecursive(tree, lines, today, opts)
+		}
 	}
 
 	return lines, count
 }
 
+// cascadeDirectRecursive completes the direct children of every task in tree that
+// was checked without a @done tag.
+func cascadeDirectRecursive(tree *TaskTree, lines []ParsedLine, today string, opts Options) int {
+	count := 0
+
+	if tree.Line.IsCompleted && !tree.Line.HasDoneTag {
+		for _, child := range tree.Children {
+			count += markCompleted(child.Line, lines, today, opts)
+		}
+	}
+
+	// Children completed above now have @done, so they don't cascade further
+	for _, child := range tree.Children {
+		count += cascadeDirectRecursive(child, lines, today, opts)
+	}
+
+	return count
+}
+
 // cascadeCompletionRecursive recursively cascades completion to children.
-func cascadeCompletionRecursive(tree *TaskTree, lines []ParsedLine, today string) int {
+func cascadeCompletionRecursive(tree *TaskTree, lines []ParsedLine, today string, opts Options) int {
 	count := 0
 
-	if tree.Line.IsCompleted {
+	if cascadeFires(tree.Line, today, opts) {
 		// Cascade to all children
 		for _, child := range tree.Children {
-			count += markTreeCompleted(child, lines, today)
+			count += markTreeCompleted(child, lines, today, opts)
+		}
+	}
+
+	// Continue to check children (in case they are independently completed)
+	for _, child := range tree.Children {
+		count += cascadeCompletionRecursive(child, lines, today, opts)
+	}
+
+	return count
+}
+
+// cascadeFires reports whether the completed task line cascades to the open tasks
+// below it under CascadeAll. With opts.CascadeIgnoreManual every completed task does;
+// otherwise only a task checked since the last pass (no @done tag yet) or one with
+// @done(today) does. Once a parent's @done date is in the past, the cascade
+// already completed everything below it on that day, so an open task below it now
+// was reopened (or added) by hand afterwards and is left open, with its subtasks.
+// A subtask reopened on the day its parent was completed is still completed again.
+func cascadeFires(line *ParsedLine, today string, opts Options) bool {
+	if !line.IsCompleted {
+		return false
+	}
+	if opts.CascadeIgnoreManual {
+		return true
+	}
+	done, ok := ParseDoneDate(line.Content)
+	return !ok || done.Format("2006-01-02") >= today
+}
+
+// CompleteTasks completes the open tasks on lines (0-indexed) of content with
+// @done(today), along with their subtasks as opts.Cascade (or a cascade set in the
+// front matter) completes them below a task checked in the editor. Nothing else in
+// content changes. Returns the new content and the number of tasks completed,
+// subtasks included.
+func CompleteTasks(content string, lines []int, opts Options) (string, int) {
+	today := time.Now().Format("2006-01-02")
+	if settings, _ := FrontmatterSettings(content); settings.Cascade != "" {
+		opts.Cascade = settings.Cascade
+	}
+
+	targets := make(map[int]bool, len(lines))
+	for _, i := range lines {
+		targets[i] = true
+	}
+	parsed := ParseLines(content, opts)
+	count := 0
+	var complete func(trees []*TaskTree)
+	complete = func(trees []*TaskTree) {
+		for _, tree := range trees {
+			if targets[tree.Line.LineNumber] && !tree.Line.IsCompleted && !tree.Line.InCodeBlock {
+				switch opts.cascade() {
+				case CascadeAll:
+					count += markTreeCompleted(tree, parsed, today, opts)
+				case CascadeDirect:
+					count += markCompleted(tree.Line, parsed, today, opts)
+					for _, child := range tree.Children {
+						count += markCompleted(child.Line, parsed, today, opts)
+					}
+				default:
+					count += markCompleted(tree.Line, parsed, today, opts)
+				}
+			}
+			complete(tree.Children)
+		}
+	}
+	complete(BuildTaskTrees(parsed))
+	return ReconstructContent(parsed), count
+}
+
+// markCompleted changes an open task line to [x] with @done(today) and reports
+// whether it did (1) or the task was already completed (0).
+func markCompleted(line *ParsedLine, lines []ParsedLine, today string, opts Options) int {
+	if line.IsCompleted {
+		return 0
+	}
+	newContent := strings.Replace(line.Content, "[ ]", "[x]", 1)
+	newContent = appendDoneTag(newContent, today, opts)
+
+	lines[line.LineNumber].Content = newContent
+	lines[line.LineNumber].IsCompleted = true
+	lines[line.LineNumber].HasDoneTag = !opts.DoneDateOptional
+	return 1
+}
+
+// markTreeCompleted marks a task and all its descendants as completed.
+func markTreeCompleted(tree *TaskTree, lines []ParsedLine, today string, opts Options) int {
+	count := markCompleted(tree.Line, lines, today, opts)
+
+	// Recursively mark children
+	for _, child := range tree.Children {
+		count += markTreeCompleted(child, lines, today, opts)
+	}
+
+	return count
+}
+
+// PropagateCompletionUpward is PropagateCompletionUpwardWith with DefaultOptions.
+func PropagateCompletionUpward(lines []ParsedLine, today string) (int, error) {
+	return PropagateCompletionUpwardWith(lines, today, DefaultOptions())
+}
+
+// PropagateCompletionUpwardWith completes, with @done(today), every open task whose
+// descendants are all completed, the reverse of CascadeCompletion. Trees are walked
+// bottom-up, so a parent completed here can in turn complete its own parent; a task
+// without subtasks is never completed. lines must come from ParseLines (LineNumber
+// is the index into lines). Returns the count of newly completed tasks.
+func PropagateCompletionUpwardWith(lines []ParsedLine, today string, opts Options) (int, error) {
+	for i, line := range lines {
+		if line.LineNumber != i {
+			return 0, fmt.Errorf("line %d is numbered %d: lines must come from ParseLines", i, line.LineNumber)
+		}
+	}
+
+	count := 0
+	for _, tree := range BuildTaskTrees(lines) {
+		completed, _ := propagateUpwardRecursive(tree, lines, today, opts)
+		count += completed
+	}
+	return count, nil
+}
+
+// propagateUpwardRecursive completes the open tasks of tree whose descendants are all
+// completed, children first. Reports the count of newly completed tasks and whether
+// every task in tree is now completed.
+func propagateUpwardRecursive(tree *TaskTree, lines []ParsedLine, today string, opts Options) (int, bool) {
+	count := 0
+	allDone := true
+	for _, child := range tree.Children {
+		completed, done := propagateUpwardRecursive(child, lines, today, opts)
+		count += completed
+		allDone = allDone && done
+	}
+
+	if len(tree.Children) > 0 && allDone {
+		count += markCompleted(tree.Line, lines, today, opts)
+	}
+	return count, tree.Line.IsCompleted && allDone
+}
+
+// subtreeRange returns the line range [start, end) of the lines nested under parentLine:
+// the following lines indented deeper than the parent. Blank lines inside the block
+// are included; the range ends at the first non-blank line at or above the parent's indent.
+func subtreeRange(lines []string, parentLine int) (int, int) {
+	start := parentLine + 1
+	end := start
+	parentIndent := GetIndentLevel(lines[parentLine])
+	for i := start; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if GetIndentLevel(lines[i]) <= parentIndent {
+			break
+		}
+		end = i + 1
+	}
+	return start, end
+}
+
+// ChildrenCompleted reports whether the task at parentLine (0-indexed) has descendant
+// tasks and all of them are completed. Used to decide the direction of a subtree toggle:
+// if every child is done, the next toggle reopens them all; otherwise it completes them all.
+func ChildrenCompleted(content string, parentLine int, opts Options) bool {
+	lines := strings.Split(content, "\n")
+	if parentLine < 0 || parentLine >= len(lines) {
+		return false
+	}
+
+	start, end := subtreeRange(lines, parentLine)
+	found := false
+	for _, line := range lines[start:end] {
+		if !IsTask(line, opts) {
+			continue
 		}
+		if !IsCompleted(line, opts) {
+			return false
+		}
+		found = true
 	}
+	return found
+}
 
-	// Continue to check children (in case they are independently completed)
-	for _, child := range tree.Children {
-		count += cascadeCompletionRecursive(child, lines, today)
+// ToggleSubtreeChildren completes (or reopens) every descendant task of the task at
+// parentLine (0-indexed), leaving the parent itself unchanged.
+// Completed tasks get @done(today); reopened tasks lose their @done tag.
+// Returns the new content and the number of lines changed.
+func ToggleSubtreeChildren(content string, parentLine int, complete bool, opts Options) (string, int) {
+	lines := strings.Split(content, "\n")
+	if parentLine < 0 || parentLine >= len(lines) || !IsTask(lines[parentLine], opts) {
+		return content, 0
 	}
 
-	return count
-}
-
-// markTreeCompleted marks a task and all its descendants as completed.
-func markTreeCompleted(tree *TaskTree, lines []ParsedLine, today string) int {
+	today := time.Now().Format("2006-01-02")
+	start, end := subtreeRange(lines, parentLine)
 	count := 0
-	line := tree.Line
-
-	// Only modify if not already completed
-	if !line.IsCompleted {
-		// Change [ ] to [x] and add @done
-		newContent := strings.Replace(line.Content, "[ ]", "[x]", 1)
-		newContent = newContent + " @done(" + today + ")"
-
-		lines[line.LineNumber].Content = newContent
-		lines[line.LineNumber].IsCompleted = true
-		lines[line.LineNumber].HasDoneTag = true
+	for i := start; i < end; i++ {
+		line := lines[i]
+		if !IsTask(line, opts) || IsCompleted(line, opts) == complete {
+			continue
+		}
+		if complete {
+			line = strings.Replace(line, "[ ]", "[x]", 1)
+			if !HasDoneTag(line) {
+				line = appendDoneTag(line, today, opts)
+			}
+		} else {
+			line = reopenLine(line, opts)
+		}
+		lines[i] = line
 		count++
 	}
 
-	// Recursively mark children
-	for _, child := range tree.Children {
-		count += markTreeCompleted(child, lines, today)
-	}
+	return strings.Join(lines, "\n"), count
+}
 
-	return count
+// reopenLine unchecks a completed task line and removes its @done tag.
+func reopenLine(line string, opts Options) string {
+	line = opts.patterns().completedBox.ReplaceAllString(line, "${1}[ ]")
+	return strings.TrimRight(doneTagPattern.ReplaceAllString(line, ""), " \t")
+}
+
+// ReopenTasks unchecks every completed task in content and removes their @done tags.
+// Returns the new content and the number of tasks reopened.
+func ReopenTasks(content string, opts Options) (string, int) {
+	lines := strings.Split(content, "\n")
+	count := 0
+	for i, line := range lines {
+		if IsCompleted(line, opts) {
+			lines[i] = reopenLine(line, opts)
+			count++
+		}
+	}
+	return strings.Join(lines, "\n"), count
 }
 
 // ReconstructContent rebuilds content string from ParsedLines.
@@ -234,71 +1346,176 @@ func ReconstructContent(lines []ParsedLine) string {
 }
 
 // ProcessContent adds @done(today) tags to all completed tasks that don't have one.
-// It also cascades completion from parent tasks to children.
+// It also cascades completion from parent tasks to children (opts.Cascade), completes
+// parents whose subtasks are all done (opts.AutoCompleteParent), escalates overdue
+// tasks (opts.EscalateOverdueDays), and moves completed tasks down
+// (opts.CompletedToBottom). The front matter is never changed, but a cascade set in
+// it overrides opts.Cascade (see FrontmatterSettings).
 // Returns the processed content and the count of tasks modified.
-func ProcessContent(content string) (string, int) {
-	today := time.Now().Format("2006-01-02")
-	lines := ParseLines(content)
-	count := 0
+func ProcessContent(content string, opts Options) (string, int) {
+	processed, tagged, escalated := processContent(content, time.Now(), opts)
+	return processed, tagged + escalated
+}
+
+// processContent is ProcessContent, counting tasks tagged @done and tasks escalated separately.
+func processContent(content string, now time.Time, opts Options) (processed string, tagged, escalated int) {
+	today := now.Format("2006-01-02")
+	lines := ParseLines(content, opts)
 
-	// First, cascade completion from parents to children
-	lines, cascadeCount := CascadeCompletion(lines, today)
-	count += cascadeCount
+	// First, cascade completion from parents to children. A cascade set in the
+	// front matter overrides opts.Cascade for this file.
+	if settings, _ := FrontmatterSettings(content); settings.Cascade != "" {
+		opts.Cascade = settings.Cascade
+	}
+	lines, tagged = CascadeCompletion(lines, today, opts)
+
+	// Then complete parents whose subtasks are now all done. This runs once, after the
+	// cascade: a parent completed here has no open descendants left to cascade to, and
+	// its @done tag keeps CascadeDirect from treating it as newly checked next time.
+	if opts.AutoCompleteParent {
+		completed, _ := PropagateCompletionUpwardWith(lines, today, opts) // lines come from ParseLines
+		tagged += completed
+	}
 
 	// Then, add @done tags to completed tasks that don't have one
 	for i := range lines {
-		if lines[i].IsCompleted && !lines[i].HasDoneTag {
-			lines[i].Content = lines[i].Content + " @done(" + today + ")"
+		if !opts.DoneDateOptional && lines[i].IsCompleted && !lines[i].HasDoneTag {
+			lines[i].Content = appendDoneTag(lines[i].Content, today, opts)
 			lines[i].HasDoneTag = true
-			count++
+			tagged++
+		}
+	}
+
+	// Raise open tasks overdue by more than opts.EscalateOverdueDays; never lowered automatically
+	if opts.EscalateOverdueDays > 0 {
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		for i := range lines {
+			if !lines[i].IsTask || lines[i].IsCompleted || lines[i].InCodeBlock {
+				continue
+			}
+			if !overdueBy(lines[i].Content, opts.EscalateOverdueDays, midnight) {
+				continue
+			}
+			if line, changed := EscalatePriority(lines[i].Content); changed {
+				lines[i].Content = line
+				escalated++
+			}
 		}
 	}
 
-	return ReconstructContent(lines), count
+	// Last, move completed tasks down once some were newly completed. Reordering
+	// after the passes above keeps their line indexes valid.
+	if opts.CompletedToBottom && tagged > 0 {
+		lines = PartitionByCompletion(lines)
+	}
+
+	opts.logger().Debug("process content", "lines", len(lines), "cascade", opts.cascade(), "tagged", tagged, "escalated", escalated)
+	return ReconstructContent(lines), tagged, escalated
 }
 
 // FilterArchivable separates tasks into archivable and remaining based on delay_days.
-// Tasks completed more than delayDays ago are archivable.
+// Tasks completed more than delayFor(heading) days ago are archivable, where heading is
+// the nearest heading above the root task ("" if none); see FixedDelay for a single delay.
 // When a parent task is archivable, all its children (including non-task lines) are archived with it.
 // Children cannot be archived independently - they only archive when parent is archivable.
+// With opts.ArchiveWhenChildrenDone, an open root task whose descendants are all done
+// is archivable too, dated by the newest child @done.
+// A delay_days set in the front matter (see FrontmatterSettings) replaces delayFor for
+// every task of the file. The front matter itself always stays in remaining.
+// With opts.DoneDateOptional, completed root tasks without @done are archivable as if
+// content had been last modified today (see filterArchivable).
 // Returns (archivable tasks with group dates, remaining content as string).
-func FilterArchivable(content string, delayDays int) ([]ArchiveTask, string) {
-	lines := ParseLines(content)
+func FilterArchivable(content string, delayFor func(heading string) int, opts Options) ([]ArchiveTask, string) {
+	return filterArchivable(content, delayFor, time.Now(), opts)
+}
+
+// filterArchivable is FilterArchivable for content last modified at modified. With
+// opts.DoneDateOptional, a completed root task without @done has no completion date, so
+// it counts as completed on the day of modified, the latest day it can have been
+// checked: with delay_days = 0 it is archived by the next pass, otherwise once the file
+// has not changed for delay_days. Such a task is filed under the day it is archived.
+func filterArchivable(content string, delayFor func(heading string) int, modified time.Time, opts Options) ([]ArchiveTask, string) {
+	if settings, _ := FrontmatterSettings(content); settings.DelayDays != nil {
+		delayFor = FixedDelay(*settings.DelayDays)
+	}
+	lines := ParseLines(content, opts)
 	trees := BuildTaskTrees(lines)
-	cutoff := time.Now().AddDate(0, 0, -delayDays)
+	headings := nearestHeadings(lines)
+	now := time.Now()
+	var undated time.Time // zero: tasks without @done are never archived
+	if opts.DoneDateOptional {
+		undated = time.Date(modified.Year(), modified.Month(), modified.Day(), 0, 0, 0, 0, time.UTC)
+	}
 
 	// Mark which line numbers should be archived and their group dates
 	archiveSet := make(map[int]bool)
 	groupDates := make(map[int]time.Time)
 
+	verbose := opts.logger().Enabled(context.Background(), slog.LevelDebug)
+	logged := make(map[string]bool)
 	for _, tree := range trees {
-		markArchivableRecursive(tree, cutoff, archiveSet, groupDates, false, time.Time{}, true)
+		// The whole tree follows the delay of its root task's heading
+		heading := headings[tree.Line.LineNumber]
+		delay := delayFor(heading)
+		cutoff := now.AddDate(0, 0, -delay)
+		if verbose && !logged[heading] {
+			logged[heading] = true
+			opts.logger().Debug("archive cutoff", "heading", heading, "delay_days", delay, "cutoff", cutoff.Format("2006-01-02"))
+		}
+		markArchivableRecursive(tree, cutoff, undated, archiveSet, groupDates, false, time.Time{}, true, opts)
 	}
 
 	// Include non-task lines that belong to archived task subtrees
-	includeNonTaskChildren(lines, archiveSet, groupDates)
+	includeNonTaskChildren(lines, archiveSet, groupDates, opts)
 
 	var archivable []ArchiveTask
 	var remaining []string
 
 	for i, line := range lines {
 		if archiveSet[i] {
+			groupDate := groupDates[i]
+			if groupDate.IsZero() {
+				groupDate = now // an undated task, filed under the day it is archived
+			}
 			archivable = append(archivable, ArchiveTask{
 				Content:   line.Content,
-				GroupDate: groupDates[i],
+				GroupDate: groupDate,
+				Heading:   headings[i],
+				IsTask:    line.IsTask,
 			})
 		} else {
 			remaining = append(remaining, line.Content)
 		}
 	}
 
+	opts.logger().Debug("filter archivable", "lines", len(lines), "archivable", len(archivable), "remaining", len(remaining))
 	return archivable, strings.Join(remaining, "\n")
 }
 
-// includeNonTaskChildren marks non-task lines for archiving when they are children of archived tasks.
-// A non-task line is considered a child of a task if it has greater indentation and appears
-// between the task and the next task at the same or lesser indentation level.
-func includeNonTaskChildren(lines []ParsedLine, archiveSet map[int]bool, groupDates map[int]time.Time) {
+// FixedDelay returns a FilterArchivable delay resolver that uses days under every heading.
+func FixedDelay(days int) func(heading string) int {
+	return func(string) int { return days }
+}
+
+// nearestHeadings returns, for each line, the text of the nearest heading at or
+// above it ("" before the first heading). Headings in code blocks and front matter don't count.
+func nearestHeadings(lines []ParsedLine) []string {
+	headings := make([]string, len(lines))
+	heading := ""
+	for i, line := range lines {
+		if m := headingPattern.FindStringSubmatch(line.Content); m != nil && !line.InCodeBlock && !line.FrontMatter {
+			heading = strings.TrimSpace(m[1])
+		}
+		headings[i] = heading
+	}
+	return headings
+}
+
+// includeNonTaskChildren marks non-task lines for archiving when they belong to the block
+// of an archived task. The block is the task line followed by every line up to the first
+// blank line that is either indented deeper than the task (notes, wrapped text, subtasks)
+// or a continuation line (see isContinuation).
+func includeNonTaskChildren(lines []ParsedLine, archiveSet map[int]bool, groupDates map[int]time.Time, opts Options) {
 	for i := 0; i < len(lines); i++ {
 		if !archiveSet[i] || !lines[i].IsTask {
 			continue
@@ -311,8 +1528,9 @@ func includeNonTaskChildren(lines []ParsedLine, archiveSet map[int]bool, groupDa
 		for j := i + 1; j < len(lines); j++ {
 			childLine := lines[j]
 
-			// Stop if we reach a line with same or lesser indentation
-			if childLine.Indent <= parentIndent {
+			// Stop at a blank line, or at a line with same or lesser indentation
+			// that does not continue the text above
+			if childLine.Indent <= parentIndent && !isContinuation(childLine, opts) {
 				break
 			}
 
@@ -325,18 +1543,40 @@ func includeNonTaskChildren(lines []ParsedLine, archiveSet map[int]bool, groupDa
 	}
 }
 
+// isContinuation reports whether line continues the text of the line above it without
+// being indented (a Markdown lazy continuation line), as when a long task is wrapped by
+// hand. Blank lines, tasks, other list items, headings, block quotes, code blocks, and
+// front matter are never continuation lines.
+func isContinuation(line ParsedLine, opts Options) bool {
+	return !line.IsTask && !line.InCodeBlock && !line.FrontMatter && isContinuationText(line.Content, opts)
+}
+
+// isContinuationText is isContinuation for a line outside code blocks and front matter.
+func isContinuationText(line string, opts Options) bool {
+	return strings.TrimSpace(line) != "" &&
+		!IsTask(line, opts) &&
+		!listItemPattern.MatchString(line) &&
+		!headingPattern.MatchString(line) &&
+		!quotePattern.MatchString(line) &&
+		!codeFencePattern.MatchString(line)
+}
+
 // markArchivableRecursive marks a task tree for archiving if the root task is old enough.
 // Only root tasks (isRoot=true) can independently qualify for archiving.
 // Children are only archived when their parent is archivable.
 // groupDates tracks the completion date to use for archive grouping (parent's date).
+// A non-zero undated is the completion date of completed tasks without @done (see
+// filterArchivable); their group date is left zero.
 func markArchivableRecursive(
 	tree *TaskTree,
 	cutoff time.Time,
+	undated time.Time,
 	archiveSet map[int]bool,
 	groupDates map[int]time.Time,
 	parentArchivable bool,
 	parentDate time.Time,
 	isRoot bool,
+	opts Options,
 ) {
 	line := tree.Line
 	shouldArchive := parentArchivable
@@ -351,6 +1591,19 @@ func markArchivableRecursive(
 			groupDate = doneDate // Use this task's date for grouping
 		}
 	}
+	if isRoot && !shouldArchive && line.IsCompleted && !line.HasDoneTag && !undated.IsZero() && undated.Before(cutoff) {
+		shouldArchive = true
+		groupDate = time.Time{}
+	}
+
+	// An open root is archivable once all of its subtasks are done; a done root
+	// keeps its own date above, even if some children are still open
+	if isRoot && !shouldArchive && !line.IsCompleted && opts.ArchiveWhenChildrenDone {
+		if doneDate, ok := allDescendantsDone(tree); ok && doneDate.Before(cutoff) {
+			shouldArchive = true
+			groupDate = doneDate
+		}
+	}
 
 	if shouldArchive {
 		archiveSet[line.LineNumber] = true
@@ -359,22 +1612,73 @@ func markArchivableRecursive(
 
 	// Recursively process children - they are never "root" for archive purposes
 	for _, child := range tree.Children {
-		markArchivableRecursive(child, cutoff, archiveSet, groupDates, shouldArchive, groupDate, false)
+		markArchivableRecursive(child, cutoff, undated, archiveSet, groupDates, shouldArchive, groupDate, false, opts)
+	}
+}
+
+// allDescendantsDone reports whether tree has subtasks and all of them, at every depth,
+// are completed with a @done date. It returns the newest of those dates.
+func allDescendantsDone(tree *TaskTree) (time.Time, bool) {
+	if len(tree.Children) == 0 {
+		return time.Time{}, false
+	}
+	var newest time.Time
+	for _, child := range tree.Children {
+		if !child.Line.IsCompleted || !child.Line.HasDoneTag {
+			return time.Time{}, false
+		}
+		doneDate, found := ParseDoneDate(child.Line.Content)
+		if !found {
+			return time.Time{}, false
+		}
+		if doneDate.After(newest) {
+			newest = doneDate
+		}
+		if len(child.Children) > 0 {
+			childDate, ok := allDescendantsDone(child)
+			if !ok {
+				return time.Time{}, false
+			}
+			if childDate.After(newest) {
+				newest = childDate
+			}
+		}
+	}
+	return newest, true
+}
+
+// PartitionArchive groups archivable tasks by destination file: the route for the
+// task's nearest heading (exact heading text), or defaultPath when it has none.
+// Task order is kept within each file.
+func PartitionArchive(tasks []ArchiveTask, routes map[string]string, defaultPath string) map[string][]ArchiveTask {
+	parts := make(map[string][]ArchiveTask)
+	for _, t := range tasks {
+		path := defaultPath
+		if route, ok := routes[t.Heading]; ok && t.Heading != "" {
+			path = route
+		}
+		parts[path] = append(parts[path], t)
 	}
+	return parts
 }
 
 // FormatArchiveEntry formats tasks for the archive file, grouped by GroupDate.
 // Tasks are grouped under "## YYYY-MM-DD" headers, sorted by date descending.
 // Each task's GroupDate determines which section it appears in (typically parent's completion date).
-func FormatArchiveEntry(tasks []ArchiveTask) string {
+// Non-task lines (notes and continuation lines) always stay in the section of the task
+// line above them, so a task's block is never split across sections.
+func FormatArchiveEntry(tasks []ArchiveTask, opts Options) string {
 	if len(tasks) == 0 {
 		return ""
 	}
 
-	// Group tasks by GroupDate
+	// Group tasks by GroupDate, one block at a time
 	byDate := make(map[string][]string)
-	for _, task := range tasks {
-		dateStr := task.GroupDate.Format("2006-01-02")
+	dateStr := ""
+	for i, task := range tasks {
+		if i == 0 || IsTask(task.Content, opts) {
+			dateStr = task.GroupDate.Format("2006-01-02")
+		}
 		byDate[dateStr] = append(byDate[dateStr], task.Content)
 	}
 
@@ -398,77 +1702,515 @@ func FormatArchiveEntry(tasks []ArchiveTask) string {
 	return builder.String()
 }
 
+// RestoreTask is RestoreTaskWith with DefaultOptions.
+func RestoreTask(archiveContent string, lineNumber int) (newArchive, restoredTask string) {
+	return RestoreTaskWith(archiveContent, lineNumber, DefaultOptions())
+}
+
+// RestoreTaskWith takes the task at lineNumber (0-indexed) out of archive content, together
+// with its indented children and continuation lines, so it can be put back into tasks.md.
+// The restored block is dedented to the task's own indent and keeps its checkboxes
+// and @done tags. A date header left without entries is removed as well.
+// Returns the archive unchanged and an empty task if lineNumber is not a task.
+func RestoreTaskWith(archiveContent string, lineNumber int, opts Options) (newArchive, restoredTask string) {
+	lines := strings.Split(archiveContent, "\n")
+	if lineNumber < 0 || lineNumber >= len(lines) || !IsTask(lines[lineNumber], opts) {
+		return archiveContent, ""
+	}
+
+	_, end := subtreeRange(lines, lineNumber)
+	// Continuation lines right after the block came with it from tasks.md
+	for end < len(lines) && isContinuationText(lines[end], opts) {
+		end++
+	}
+	root := lines[lineNumber]
+	indent := root[:len(root)-len(strings.TrimLeft(root, " \t"))]
+	restored := make([]string, 0, end-lineNumber)
+	for _, line := range lines[lineNumber:end] {
+		restored = append(restored, strings.TrimPrefix(line, indent))
+	}
+
+	rest := append(append([]string{}, lines[:lineNumber]...), lines[end:]...)
+	rest = removeEmptyArchiveSection(rest, lineNumber)
+
+	return strings.Join(rest, "\n"), strings.Join(restored, "\n") + "\n"
+}
+
+// removeEmptyArchiveSection drops the "## " header above line pos (and the blank lines
+// after it) when no entries remain between it and the next header.
+func removeEmptyArchiveSection(lines []string, pos int) []string {
+	header := -1
+	for i := pos - 1; i >= 0; i-- {
+		if archiveHeaderPattern.MatchString(lines[i]) {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return lines
+	}
+
+	next := header + 1
+	for next < len(lines) && !archiveHeaderPattern.MatchString(lines[next]) {
+		if strings.TrimSpace(lines[next]) != "" {
+			return lines
+		}
+		next++
+	}
+	return append(lines[:header], lines[next:]...)
+}
+
 // LoadFile reads the content of a file and returns it as a string.
-// Returns an error if the file cannot be read.
-func LoadFile(path string) (string, error) {
-	data, err := os.ReadFile(path)
+// Returns an error if the file cannot be read, is larger than opts.MaxFileSizeMB,
+// or looks binary (a NUL byte in its first 8KB). The size is
+// checked before reading, so a huge file is never loaded into memory.
+func LoadFile(path string, opts Options) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if limit := int64(opts.maxFileSizeMB()) << 20; limit > 0 && info.Size() > limit {
+		return "", fmt.Errorf("%s is %s, over the %s limit; check working_dir or raise [file] max_size_mb",
+			path, formatSize(info.Size()), formatSize(limit))
+	}
+
+	data, err := io.ReadAll(f)
 	if err != nil {
 		return "", err
 	}
+	if bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+		return "", fmt.Errorf("%s looks like a binary file, not a task list; check working_dir", path)
+	}
 	return string(data), nil
 }
 
+// formatSize formats a byte count for error messages: "10MB", "200.5MB", "512KB", "12B".
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return strconv.FormatFloat(math.Round(float64(n)/(1<<20)*10)/10, 'f', -1, 64) + "MB"
+	case n >= 1<<10:
+		return strconv.FormatFloat(math.Round(float64(n)/(1<<10)*10)/10, 'f', -1, 64) + "KB"
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
 // WriteFile writes content to a file, creating it if it doesn't exist
 // or overwriting it if it does.
-func WriteFile(path string, content string) error {
-	return os.WriteFile(path, []byte(content), 0644)
+func WriteFile(path string, content string, opts Options) error {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return err
+	}
+	opts.logger().Debug("write file", "path", path, "bytes", len(content))
+	return nil
+}
+
+// rename is os.Rename, replaceable in tests to simulate write failures.
+var rename = os.Rename
+
+// openForAppend opens an existing file for appending, replaceable in tests to
+// simulate read-only files (permission checks do not apply to root).
+var openForAppend = func(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+}
+
+// moveFile renames from to to. Where that fails because they are on different
+// filesystems, from is copied over to, synced to disk, and then removed.
+func moveFile(from, to string) error {
+	err := rename(from, to)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyFile(from, to); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+// copyFile replaces the contents of to with those of from and syncs it to disk.
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// resolveWritable returns the file that writing path changes (its target when path
+// is a symlink), after checking that it can be opened for writing. A missing file is
+// accepted, since it is created, but a symlink into a missing directory (such as an
+// unmounted drive) is not. Errors name the file: "archive.md is not writable: ...".
+func resolveWritable(path string) (string, error) {
+	fail := func(err error) (string, error) {
+		return "", fmt.Errorf("%s is not writable: %w", filepath.Base(path), err)
+	}
+
+	target := path
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil && info.Mode()&os.ModeSymlink != 0:
+		target, err = filepath.EvalSymlinks(path)
+		if os.IsNotExist(err) {
+			target, err = resolveDangling(path)
+		}
+		if err != nil {
+			return fail(err)
+		}
+	case err != nil && !os.IsNotExist(err):
+		return fail(err)
+	}
+
+	f, err := openForAppend(target)
+	if os.IsNotExist(err) {
+		return target, nil
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if err := f.Close(); err != nil {
+		return fail(err)
+	}
+	return target, nil
+}
+
+// resolveDangling resolves a symlink whose target does not exist to that target,
+// provided the target's directory exists.
+func resolveDangling(path string) (string, error) {
+	link, err := os.Readlink(path)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(link) {
+		link = filepath.Join(filepath.Dir(path), link)
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(link))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(link)), nil
+}
+
+// ReplaceFile atomically replaces the contents of path: content is written to a
+// temporary file in the same directory, which is then renamed over path.
+// A failure leaves the original file unchanged.
+func ReplaceFile(path string, content string, opts Options) error {
+	tmpPath, err := writeTemp(path, content, nil)
+	if err != nil {
+		return err
+	}
+	if err := rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	opts.logger().Debug("write file", "path", path, "bytes", len(content))
+	return nil
 }
 
 // PrependToFile adds content to the beginning of a file.
 // Used for archive entries where newest dates should appear first.
-func PrependToFile(path string, content string) error {
-	existing, err := LoadFile(path)
-	if err != nil && !os.IsNotExist(err) {
+// The new content is written to a temporary file in the same directory,
+// the existing file is streamed after it, and the result is renamed over
+// the original. Memory use stays proportional to content, not file size.
+func PrependToFile(path string, content string, opts Options) error {
+	tmpPath, err := writePrependedTemp(path, content)
+	if err != nil {
+		return err
+	}
+	if err := rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
 		return err
 	}
-	return WriteFile(path, content+existing)
+	opts.logger().Debug("prepend file", "path", path, "bytes", len(content))
+	return nil
+}
+
+// writePrependedTemp writes content followed by the current contents of path
+// to a new temporary file next to path, and returns the temporary file's path.
+// A missing file at path is treated as empty.
+func writePrependedTemp(path string, content string) (string, error) {
+	existing, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if existing == nil {
+		return writeTemp(path, content, nil)
+	}
+	defer existing.Close()
+	return writeTemp(path, content, existing)
+}
+
+// writeTemp writes content, then everything from tail (if non-nil), to a new
+// temporary file in the same directory as path so it can be renamed over path.
+// The temporary file gets the permissions of the file at path, so a private 0600
+// tasks.md stays private, or 0644 when path is being created.
+// Returns the temporary file's path; the file is removed on error.
+func writeTemp(path string, content string, tail io.Reader) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+
+	fail := func(err error) (string, error) {
+		tmp.Close()
+		_ = os.Remove(tmpPath)
+		return "", err
+	}
+
+	if _, err := io.WriteString(tmp, content); err != nil {
+		return fail(err)
+	}
+	if tail != nil {
+		if _, err := io.Copy(tmp, tail); err != nil {
+			return fail(err)
+		}
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", err
+	}
+
+	return tmpPath, nil
 }
 
 // ProcessFileWithDoneTags reads a file, adds @done tags to completed tasks,
 // and writes the result back. Returns the count of modified tasks.
-func ProcessFileWithDoneTags(path string) (int, error) {
-	content, err := LoadFile(path)
+func ProcessFileWithDoneTags(path string, opts Options) (int, error) {
+	tagged, escalated, err := ProcessFile(path, opts)
+	return tagged + escalated, err
+}
+
+// ProcessFile is ProcessFileWithDoneTags, returning the count of tasks tagged @done
+// and the count of overdue tasks escalated (see Options.EscalateOverdueDays) separately.
+func ProcessFile(path string, opts Options) (tagged, escalated int, err error) {
+	content, err := LoadFile(path, opts)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	processed, count := ProcessContent(content)
-	if count > 0 {
-		if err := WriteFile(path, processed); err != nil {
-			return 0, err
+	processed, tagged, escalated := processContent(content, time.Now(), opts)
+	if tagged+escalated > 0 {
+		if err := WriteTasksFile(path, processed, opts); err != nil {
+			return 0, 0, err
 		}
 	}
 
-	return count, nil
+	return tagged, escalated, nil
 }
 
 // Archive moves old completed tasks from the tasks file to the archive file.
 // Tasks completed more than delayDays ago are archived.
 // Children are only archived when their parent is archivable.
-// Returns the count of archived tasks.
-func Archive(tasksPath, archivePath string, delayDays int) (int, error) {
-	content, err := LoadFile(tasksPath)
+// Returns the count of archived tasks and the count of lines moved, which also
+// includes the non-task lines (notes) archived with their parent.
+func Archive(tasksPath, archivePath string, delayDays int, opts Options) (tasks, lines int, err error) {
+	content, err := LoadFile(tasksPath, opts)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	archivableTasks, remaining := FilterArchivable(content, delayDays)
+	archivableTasks, remaining := filterArchivable(content, FixedDelay(delayDays), modTime(tasksPath), opts)
 	if len(archivableTasks) == 0 {
-		return 0, nil
+		return 0, 0, nil
 	}
 
-	// Format archive entry
-	archiveEntry := FormatArchiveEntry(archivableTasks)
+	if err := writeArchiveResult(tasksPath, archivePath, remaining, FormatArchiveEntry(archivableTasks, opts), opts); err != nil {
+		return 0, 0, err
+	}
 
-	// Prepend to archive file
-	if err := PrependToFile(archivePath, archiveEntry); err != nil {
-		return 0, err
+	return CountArchiveTasks(archivableTasks), len(archivableTasks), nil
+}
+
+// ProcessAndArchive adds @done tags and archives old completed tasks in one step.
+// The tasks file is read once, tagged and filtered in memory, and both files are
+// written with rollback-safe ordering (see writeArchiveResult): on error, neither
+// file is left partially updated.
+// Tasks tagged in this pass are dated today, so they are never archived by the same call.
+// Returns the count of tagged tasks and the count of archived tasks.
+func ProcessAndArchive(tasksPath, archivePath string, delayDays int) (tagged, archived int, err error) {
+	tagged, counts, err := ProcessAndArchiveRouted(tasksPath, archivePath, nil, FixedDelay(delayDays), DefaultOptions())
+	return tagged, counts[archivePath], err
+}
+
+// ProcessAndArchiveRouted works like ProcessAndArchive, but sends each archived task to
+// the file that routes maps its nearest heading to (see PartitionArchive), and the rest
+// to archivePath. All files are replaced together or not at all. delayFor gives the
+// archive delay per heading, as in FilterArchivable, and opts the task settings.
+// Returns the count of tagged tasks and the count of tasks archived per file
+// (non-task lines moved with their parent are not counted).
+// When nothing is tagged or archived, no file is written or created, so a no-op
+// pass leaves modification times and the git working tree unchanged.
+func ProcessAndArchiveRouted(tasksPath, archivePath string, routes map[string]string, delayFor func(heading string) int, opts Options) (tagged int, archived map[string]int, err error) {
+	content, err := LoadFile(tasksPath, opts)
+	if err != nil {
+		return 0, nil, err
 	}
 
-	// Write remaining tasks back
-	if err := WriteFile(tasksPath, remaining); err != nil {
-		return 0, err
+	processed, tagged := ProcessContent(content, opts)
+	modified := modTime(tasksPath)
+	if processed != content {
+		modified = time.Now() // this pass changed tasks.md, e.g. completed a parent
+	}
+	archivableTasks, remaining := filterArchivable(processed, delayFor, modified, opts)
+
+	if len(archivableTasks) == 0 {
+		if tagged == 0 {
+			return 0, nil, nil
+		}
+		if err := WriteTasksFile(tasksPath, processed, opts); err != nil {
+			return 0, nil, fmt.Errorf("failed to write tasks file: %w", err)
+		}
+		return tagged, nil, nil
+	}
+
+	entries := make(map[string]string)
+	archived = make(map[string]int)
+	for path, tasks := range PartitionArchive(archivableTasks, routes, archivePath) {
+		entries[path] = FormatArchiveEntry(tasks, opts)
+		archived[path] = CountArchiveTasks(tasks)
+	}
+	if err := writeArchiveResults(tasksPath, remaining, entries, opts); err != nil {
+		return 0, nil, err
+	}
+
+	return tagged, archived, nil
+}
+
+// modTime returns the modification time of the file at path, or now if it cannot be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Now()
+	}
+	return info.ModTime()
+}
+
+// writeArchiveResult prepends entry to the archive file and replaces the tasks file
+// with remaining, so that a failure leaves both files as they were.
+// Both new files are fully written to temporary files first. The original archive is
+// kept as a backup until the tasks file has been replaced, and restored if that fails.
+func writeArchiveResult(tasksPath, archivePath, remaining, entry string, opts Options) error {
+	return writeArchiveResults(tasksPath, remaining, map[string]string{archivePath: entry}, opts)
+}
+
+// writeArchiveResults is writeArchiveResult for several archive files: each entry is
+// prepended to its file (keyed by path), and on failure every file is restored.
+// Missing directories of archive files are created, and remaining is tidied with
+// opts.TidyOnWrite (see WriteTasksFile).
+// Every file is checked for writability first (see resolveWritable), so an error such
+// as a read-only archive or an unmounted drive leaves all files untouched. Symlinks are
+// followed, so the files they point to are replaced rather than the links themselves.
+func writeArchiveResults(tasksPath, remaining string, entries map[string]string, opts Options) error {
+	if opts.TidyOnWrite {
+		remaining = TidyWith(remaining, opts)
+	}
+	tasksPath, err := resolveWritable(tasksPath)
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(entries))
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	resolved := make(map[string]string, len(entries))
+	for i, path := range paths {
+		target, err := resolveWritable(path)
+		if err != nil {
+			return err
+		}
+		resolved[target] = entries[path]
+		paths[i] = target
+	}
+	entries = resolved
+
+	temps := make(map[string]string, len(paths))
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create archive directory: %w", err)
+		}
+		tmp, err := writePrependedTemp(path, entries[path])
+		if err != nil {
+			return fmt.Errorf("failed to write archive file: %w", err)
+		}
+		defer os.Remove(tmp) // no-op after a successful rename
+		temps[path] = tmp
 	}
 
-	return len(archivableTasks), nil
+	tasksTmp, err := writeTemp(tasksPath, remaining, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write tasks file: %w", err)
+	}
+	defer os.Remove(tasksTmp)
+
+	// Archives replaced so far, with their backups ("" when the file did not exist)
+	type replaced struct{ path, backup string }
+	var done []replaced
+	restore := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			if done[i].backup != "" {
+				_ = moveFile(done[i].backup, done[i].path)
+			} else {
+				_ = os.Remove(done[i].path)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		backup := ""
+		if _, err := os.Stat(path); err == nil {
+			backup = temps[path] + ".bak"
+			if err := moveFile(path, backup); err != nil {
+				restore()
+				return fmt.Errorf("failed to back up archive file: %w", err)
+			}
+		}
+		done = append(done, replaced{path: path, backup: backup})
+
+		if err := moveFile(temps[path], path); err != nil {
+			restore()
+			return fmt.Errorf("failed to write archive file: %w", err)
+		}
+	}
+
+	if err := moveFile(tasksTmp, tasksPath); err != nil {
+		restore()
+		return fmt.Errorf("failed to write tasks file: %w", err)
+	}
+
+	for _, r := range done {
+		if r.backup != "" {
+			_ = os.Remove(r.backup)
+		}
+	}
+	return nil
 }
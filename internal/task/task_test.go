@@ -1,7 +1,11 @@
 package task
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -36,7 +40,7 @@ func TestIsCompleted(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := IsCompleted(tt.line)
+			result := IsCompleted(tt.line, DefaultOptions())
 			if result != tt.expected {
 				t.Errorf("IsCompleted(%q) = %v, want %v", tt.line, result, tt.expected)
 			}
@@ -105,11 +109,35 @@ func TestAddDoneTag(t *testing.T) {
 			"# Header",
 			false,
 		},
+		{
+			"trailing spaces trimmed",
+			"- [x] Buy milk   ",
+			"- [x] Buy milk @done(" + today + ")",
+			true,
+		},
+		{
+			"trailing tab trimmed",
+			"- [x] Buy milk\t",
+			"- [x] Buy milk @done(" + today + ")",
+			true,
+		},
+		{
+			"done follows other trailing tags",
+			"- [x] Pay rent @due(2026-02-01)",
+			"- [x] Pay rent @due(2026-02-01) @done(" + today + ")",
+			true,
+		},
+		{
+			"done follows trailing comment text",
+			"- [x] Call Bob (ask about invoice) ",
+			"- [x] Call Bob (ask about invoice) @done(" + today + ")",
+			true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, changed := AddDoneTag(tt.line)
+			result, changed := AddDoneTag(tt.line, DefaultOptions())
 			if result != tt.expected {
 				t.Errorf("AddDoneTag(%q) = %q, want %q", tt.line, result, tt.expected)
 			}
@@ -120,332 +148,2452 @@ func TestAddDoneTag(t *testing.T) {
 	}
 }
 
-// TestParseDoneDate verifies that ParseDoneDate() extracts the date from @done tag.
-// Returns the date and true if found, zero time and false otherwise.
-func TestParseDoneDate(t *testing.T) {
+// TestParsePriority verifies that ParsePriority() extracts A/B/C from @priority(X) tags.
+func TestParsePriority(t *testing.T) {
 	tests := []struct {
-		name        string
-		line        string
-		expectedDay int // day of month, 0 if not found
-		found       bool
+		name     string
+		line     string
+		expected string
+		found    bool
 	}{
-		{"valid done tag", "- [x] Task @done(2026-01-18)", 18, true},
-		{"no done tag", "- [x] Task", 0, false},
-		{"invalid date", "- [x] Task @done(invalid)", 0, false},
-		{"empty line", "", 0, false},
+		{"priority A", "- [ ] Ship release @priority(A)", "A", true},
+		{"priority B", "- [ ] Review @priority(B) today", "B", true},
+		{"priority C", "- [ ] Tidy desk @priority(C)", "C", true},
+		{"lowercase letter", "- [ ] Ship @priority(a)", "A", true},
+		{"unknown letter", "- [ ] Ship @priority(D)", "", false},
+		{"no priority", "- [ ] Ship", "", false},
+		{"malformed", "- [ ] Ship @priority()", "", false},
+		{"p1", "- [ ] Ship release @p1", "A", true},
+		{"p3 before text", "- [ ] @p3 tidy desk", "C", true},
+		{"p4", "- [ ] Ship @p4", "", false},
+		{"longer tag", "- [ ] Ship @p12 @p2x", "", false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			date, found := ParseDoneDate(tt.line)
-			if found != tt.found {
-				t.Errorf("ParseDoneDate(%q) found = %v, want %v", tt.line, found, tt.found)
+			got, found := ParsePriority(tt.line)
+			if got != tt.expected || found != tt.found {
+				t.Errorf("ParsePriority(%q) = (%q, %v), want (%q, %v)", tt.line, got, found, tt.expected, tt.found)
 			}
-			if found && date.Day() != tt.expectedDay {
-				t.Errorf("ParseDoneDate(%q) day = %d, want %d", tt.line, date.Day(), tt.expectedDay)
+		})
+	}
+}
+
+// TestEscalatePriority verifies that escalation sets @p1, replacing a lower priority instead of adding a second tag.
+func TestEscalatePriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+		changed  bool
+	}{
+		{"p2", "- [ ] Review @p2 @due(2026-01-10)", "- [ ] Review @p1 @due(2026-01-10)", true},
+		{"p3 at the end", "- [ ] Tidy desk @p3", "- [ ] Tidy desk @p1", true},
+		{"already p1", "- [ ] Ship @p1", "- [ ] Ship @p1", false},
+		{"priority B", "- [ ] Review @priority(B) @due(2026-01-10)", "- [ ] Review @p1 @due(2026-01-10)", true},
+		{"priority C", "- [ ] Tidy desk @priority(c)", "- [ ] Tidy desk @p1", true},
+		{"already A", "- [ ] Ship @priority(A)", "- [ ] Ship @priority(A)", false},
+		{"no priority", "- [ ] Pay rent @due(2026-01-10)", "- [ ] Pay rent @due(2026-01-10) @p1", true},
+		{"no due date", "- [ ] Pay rent ", "- [ ] Pay rent @p1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := EscalatePriority(tt.line)
+			if got != tt.expected || changed != tt.changed {
+				t.Errorf("EscalatePriority(%q) = (%q, %v), want (%q, %v)", tt.line, got, changed, tt.expected, tt.changed)
 			}
 		})
 	}
 }
 
-// TestProcessContent verifies that ProcessContent() adds @done tags to all
-// newly completed tasks in the content. Returns the processed content and
-// the count of tasks that were modified.
-func TestProcessContent(t *testing.T) {
-	input := `# Tasks
+// TestReplaceBody verifies that only the task text changes; indentation, marker, and tags stay.
+func TestReplaceBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		newBody  string
+		body     string // TaskBody(line)
+		expected string
+	}{
+		{"open task", "- [ ] Buy milk", "Buy oat milk", "Buy milk", "- [ ] Buy oat milk"},
+		{"indented done task", "  - [x] Review PR @done(2026-01-20)", "Review PR #42", "Review PR", "  - [x] Review PR #42 @done(2026-01-20)"},
+		{"tags in the middle", "- [ ] Call @waiting Bob @due(2026-01-25)", "Call Alice", "Call Bob", "- [ ] Call Alice @waiting @due(2026-01-25)"},
+		{"spaces trimmed", "- [ ] Old", "  New text  ", "Old", "- [ ] New text"},
+		{"not a task", "Some note", "Changed", "Some note", "Some note"},
+		{"email address", "- [ ] Email a@b.com @due(2026-01-25)", "Email c@d.com", "Email a@b.com", "- [ ] Email c@d.com @due(2026-01-25)"},
+	}
 
-- [ ] Incomplete task
-- [x] Completed without done
-- [x] Already has @done(2026-01-15)
-- [x] Another completed
-`
-	result, count := ProcessContent(input)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TaskBody(tt.line, DefaultOptions()); got != tt.body {
+				t.Errorf("TaskBody(%q) = %q, want %q", tt.line, got, tt.body)
+			}
+			if got := ReplaceBody(tt.line, tt.newBody); got != tt.expected {
+				t.Errorf("ReplaceBodyWith(%q, %q) = %q, want %q", tt.line, tt.newBody, got, tt.expected)
+			}
+		})
+	}
+}
 
-	// Should have modified 2 tasks (the two completed without @done)
-	if count != 2 {
-		t.Errorf("ProcessContent() count = %d, want 2", count)
+// TestAddTrackedTime verifies that tracked time is appended or summed into an existing @track tag.
+func TestAddTrackedTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		d        time.Duration
+		expected string
+	}{
+		{"no tag", "- [ ] Write report", 83 * time.Minute, "- [ ] Write report @track(1h23m)"},
+		{"trailing space", "- [ ] Write report ", 45 * time.Minute, "- [ ] Write report @track(45m)"},
+		{"summed", "- [ ] Write report @track(1h23m) @due(2026-01-20)", 50 * time.Minute, "- [ ] Write report @track(2h13m) @due(2026-01-20)"},
+		{"whole hours", "- [ ] Write report @track(45m)", 15 * time.Minute, "- [ ] Write report @track(1h)"},
+		{"hours only tag", "- [ ] Write report @track(2h)", 5 * time.Minute, "- [ ] Write report @track(2h5m)"},
+		{"rounded to the minute", "- [ ] Write report", 90 * time.Second, "- [ ] Write report @track(2m)"},
+		{"under half a minute", "- [ ] Write report", 20 * time.Second, "- [ ] Write report @track(0m)"},
 	}
 
-	// Result should contain @done tags for the modified tasks
-	if !containsString(result, "@done(") {
-		t.Error("ProcessContent() should add @done tags")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AddTrackedTime(tt.line, tt.d); got != tt.expected {
+				t.Errorf("AddTrackedTime(%q, %v) = %q, want %q", tt.line, tt.d, got, tt.expected)
+			}
+		})
 	}
+}
 
-	// Original @done tag should be preserved
-	if !containsString(result, "@done(2026-01-15)") {
-		t.Error("ProcessContent() should preserve existing @done tags")
+// TestResolveDoneConflicts verifies that a task completed on two devices is merged
+// into one line with the oldest @done date.
+func TestResolveDoneConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		count    int
+	}{
+		{
+			"oldest date wins",
+			"- [x] Pay rent @done(2026-01-16)\n- [ ] Call mom\n- [x] Pay rent @done(2026-01-15)\n",
+			"- [x] Pay rent @done(2026-01-15)\n- [ ] Call mom\n",
+			1,
+		},
+		{
+			"tags ignored when comparing",
+			"- [x] Pay rent @due(2026-01-15) @done(2026-01-15)\n- [x] Pay rent @done(2026-01-17)\n- [x] Pay rent @priority(A) @done(2026-01-16)\n",
+			"- [x] Pay rent @due(2026-01-15) @done(2026-01-15)\n",
+			2,
+		},
+		{
+			"different indentation kept",
+			"- [x] Parent @done(2026-01-15)\n  - [x] Review @done(2026-01-15)\n- [x] Review @done(2026-01-16)\n",
+			"- [x] Parent @done(2026-01-15)\n  - [x] Review @done(2026-01-15)\n- [x] Review @done(2026-01-16)\n",
+			0,
+		},
+		{
+			"open duplicate kept",
+			"- [ ] Pay rent\n- [x] Pay rent @done(2026-01-15)\n",
+			"- [ ] Pay rent\n- [x] Pay rent @done(2026-01-15)\n",
+			0,
+		},
+		{
+			"task with subtasks kept",
+			"- [x] Ship @done(2026-01-15)\n  - [x] Test @done(2026-01-15)\n- [x] Ship @done(2026-01-16)\n  - [x] Docs @done(2026-01-16)\n",
+			"- [x] Ship @done(2026-01-15)\n  - [x] Test @done(2026-01-15)\n- [x] Ship @done(2026-01-16)\n  - [x] Docs @done(2026-01-16)\n",
+			0,
+		},
 	}
 
-	// Incomplete task should remain unchanged
-	if !containsString(result, "- [ ] Incomplete task") {
-		t.Error("ProcessContent() should not modify incomplete tasks")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := ResolveDoneConflicts(tt.content, DefaultOptions())
+			if got != tt.expected || count != tt.count {
+				t.Errorf("ResolveDoneConflicts() = (%q, %d), want (%q, %d)", got, count, tt.expected, tt.count)
+			}
+		})
 	}
 }
 
-// TestFilterArchivable verifies that FilterArchivable() correctly identifies
-// tasks that should be archived based on the delay_days setting.
-func TestFilterArchivable(t *testing.T) {
-	// Create dates for testing
-	now := time.Now()
-	oldDate := now.AddDate(0, 0, -5).Format("2006-01-02")    // 5 days ago
-	recentDate := now.AddDate(0, 0, -1).Format("2006-01-02") // 1 day ago
+// TestHasDuplicate verifies exact duplicate detection against open tasks only.
+func TestHasDuplicate(t *testing.T) {
+	content := "# Inbox\n- [ ] Buy milk\n  - [ ] Call Bob @phone\n- [x] Pay rent @done(2026-01-20)\n"
 
-	content := `# Tasks
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{"exact match", "Buy milk", true},
+		{"surrounding spaces", "  Buy milk ", true},
+		{"indented task", "Call Bob @phone", true},
+		{"different case", "buy milk", false},
+		{"tag differs", "Call Bob", false},
+		{"completed task", "Pay rent", false},
+		{"heading text", "Inbox", false},
+		{"empty text", "", false},
+	}
 
-- [ ] Incomplete task
-- [x] Old completed @done(` + oldDate + `)
-- [x] Recent completed @done(` + recentDate + `)
-- [x] No done tag
-`
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasDuplicate(content, tt.text); got != tt.expected {
+				t.Errorf("HasDuplicate(%q) = %v, want %v", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
 
-	archivableTasks, remaining := FilterArchivable(content, 2) // 2 day delay
-	archivable := archiveTasksToString(archivableTasks)
+// TestHasDuplicateIgnoringTags verifies that tags are ignored on both sides of the comparison.
+func TestHasDuplicateIgnoringTags(t *testing.T) {
+	content := "- [ ] Call Bob @phone\n- [ ] Buy milk\n- [ ] Email a@b.com\n"
 
-	// Old task should be archivable
-	if !containsString(archivable, "Old completed") {
-		t.Error("FilterArchivable() should include old completed task")
+	tests := []struct {
+		text     string
+		expected bool
+	}{
+		{"Call Bob", true},
+		{"Email a@b.com @due(2026-01-21)", true},
+		{"Email a@c.com", false},
+		{"Email a", false},
+		{"Buy milk @errand @due(2026-01-21)", true},
+		{"Buy  @errand milk", true},
+		{"Call Alice @phone", false},
+		{"@phone", false},
 	}
 
-	// Recent task should remain
-	if !containsString(remaining, "Recent completed") {
-		t.Error("FilterArchivable() should keep recent completed task")
+	for _, tt := range tests {
+		if got := HasDuplicateIgnoringTags(content, tt.text, DefaultOptions()); got != tt.expected {
+			t.Errorf("HasDuplicateIgnoringTags(%q) = %v, want %v", tt.text, got, tt.expected)
+		}
 	}
+}
 
-	// Task without done tag should remain
-	if !containsString(remaining, "No done tag") {
-		t.Error("FilterArchivable() should keep task without @done tag")
+// TestDiffLines verifies added/removed line counts between two file versions.
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name           string
+		before         string
+		after          string
+		added, removed int
+	}{
+		{"identical", "a\nb\n", "a\nb\n", 0, 0},
+		{"both empty", "", "", 0, 0},
+		{"append", "a\n", "a\nb\nc\n", 2, 0},
+		{"delete", "a\nb\nc\n", "a\nc\n", 0, 1},
+		{"change line", "- [ ] a\nb\n", "- [x] a\nb\n", 1, 1},
+		{"from empty", "", "a\nb\n", 2, 0},
+		{"to empty", "a\nb\n", "", 0, 2},
+		{"move line", "a\nb\nc\n", "b\nc\na\n", 1, 1},
+		{"trailing newline only", "a", "a\n", 0, 0},
+		{"mixed", "a\nb\nc\nd\n", "a\nx\nc\nd\ne\n", 2, 1},
 	}
 
-	// Incomplete task should remain
-	if !containsString(remaining, "Incomplete task") {
-		t.Error("FilterArchivable() should keep incomplete tasks")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := DiffLines(tt.before, tt.after)
+			if added != tt.added || removed != tt.removed {
+				t.Errorf("DiffLines() = +%d -%d, want +%d -%d", added, removed, tt.added, tt.removed)
+			}
+		})
 	}
 }
 
-// TestFormatArchiveEntry verifies that FormatArchiveEntry() creates properly
-// formatted archive entries grouped by GroupDate.
-func TestFormatArchiveEntry(t *testing.T) {
-	date18, _ := time.Parse("2006-01-02", "2026-01-18")
-	date17, _ := time.Parse("2006-01-02", "2026-01-17")
+// TestRestoreTask verifies that an archived task and its children are taken out of
+// the archive, and that a date header left empty goes with them.
+func TestRestoreTask(t *testing.T) {
+	archive := "## 2026-01-20\n\n" +
+		"- [x] Plan sprint @done(2026-01-20)\n" +
+		"  - [x] Book room @done(2026-01-20)\n" +
+		"- [x] Send notes @done(2026-01-20)\n" +
+		"\n" +
+		"## 2026-01-19\n\n" +
+		"- [x] Fix bug @done(2026-01-19)\n" +
+		"\n"
 
-	tasks := []ArchiveTask{
-		{Content: "- [x] Task A @done(2026-01-18)", GroupDate: date18},
-		{Content: "- [x] Task B @done(2026-01-18)", GroupDate: date18},
-		{Content: "- [x] Task C @done(2026-01-17)", GroupDate: date17},
+	tests := []struct {
+		name         string
+		line         int
+		wantArchive  string
+		wantRestored string
+	}{
+		{
+			name: "task with child",
+			line: 2,
+			wantArchive: "## 2026-01-20\n\n" +
+				"- [x] Send notes @done(2026-01-20)\n\n" +
+				"## 2026-01-19\n\n- [x] Fix bug @done(2026-01-19)\n\n",
+			wantRestored: "- [x] Plan sprint @done(2026-01-20)\n  - [x] Book room @done(2026-01-20)\n",
+		},
+		{
+			name: "child is dedented",
+			line: 3,
+			wantArchive: "## 2026-01-20\n\n" +
+				"- [x] Plan sprint @done(2026-01-20)\n- [x] Send notes @done(2026-01-20)\n\n" +
+				"## 2026-01-19\n\n- [x] Fix bug @done(2026-01-19)\n\n",
+			wantRestored: "- [x] Book room @done(2026-01-20)\n",
+		},
+		{
+			name: "last task removes its header",
+			line: 8,
+			wantArchive: "## 2026-01-20\n\n" +
+				"- [x] Plan sprint @done(2026-01-20)\n  - [x] Book room @done(2026-01-20)\n" +
+				"- [x] Send notes @done(2026-01-20)\n",
+			wantRestored: "- [x] Fix bug @done(2026-01-19)\n",
+		},
+		{
+			name:         "header line is not a task",
+			line:         0,
+			wantArchive:  archive,
+			wantRestored: "",
+		},
+		{
+			name:         "out of range",
+			line:         99,
+			wantArchive:  archive,
+			wantRestored: "",
+		},
 	}
 
-	result := FormatArchiveEntry(tasks)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotArchive, gotRestored := RestoreTask(archive, tt.line)
+			if gotArchive != tt.wantArchive {
+				t.Errorf("archive = %q, want %q", gotArchive, tt.wantArchive)
+			}
+			if gotRestored != tt.wantRestored {
+				t.Errorf("restored = %q, want %q", gotRestored, tt.wantRestored)
+			}
+		})
+	}
 
-	// Should have date headers
-	if !containsString(result, "## 2026-01-18") {
-		t.Error("FormatArchiveEntry() should include date header for 2026-01-18")
+	// Continuation lines of a wrapped task come back with it
+	wrapped := "## 2026-01-20\n\n- [x] Write the report @done(2026-01-20)\nfor the finance team\n- [x] Other @done(2026-01-20)\n"
+	gotArchive, gotRestored := RestoreTask(wrapped, 2)
+	if want := "## 2026-01-20\n\n- [x] Other @done(2026-01-20)\n"; gotArchive != want {
+		t.Errorf("wrapped: archive = %q, want %q", gotArchive, want)
 	}
-	if !containsString(result, "## 2026-01-17") {
-		t.Error("FormatArchiveEntry() should include date header for 2026-01-17")
+	if want := "- [x] Write the report @done(2026-01-20)\nfor the finance team\n"; gotRestored != want {
+		t.Errorf("wrapped: restored = %q, want %q", gotRestored, want)
+	}
+}
+
+// TestReopenTasks verifies that completed tasks are unchecked and lose their @done tags.
+func TestReopenTasks(t *testing.T) {
+	content := "- [x] Plan sprint @done(2026-01-20)\n  - [ ] Book room\n  note\n"
+	got, count := ReopenTasks(content, DefaultOptions())
+	if want := "- [ ] Plan sprint\n  - [ ] Book room\n  note\n"; got != want {
+		t.Errorf("ReopenTasks() = %q, want %q", got, want)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
 	}
+}
 
-	// Tasks should be included
-	if !containsString(result, "Task A") {
-		t.Error("FormatArchiveEntry() should include Task A")
+// TestToggleSubtreeChildren verifies that all descendant tasks are completed or reopened
+// while the parent, non-task lines, and lines outside the subtree are left unchanged.
+func TestToggleSubtreeChildren(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	content := "- [ ] Parent\n" +
+		"  - [ ] Child 1\n" +
+		"  - [x] Child 2 @done(2026-01-19)\n" +
+		"\n" +
+		"    - [ ] Grandchild\n" +
+		"  - Note\n" +
+		"- [ ] Sibling\n"
+
+	completed, count := ToggleSubtreeChildren(content, 0, true, DefaultOptions())
+	expected := "- [ ] Parent\n" +
+		"  - [x] Child 1 @done(" + today + ")\n" +
+		"  - [x] Child 2 @done(2026-01-19)\n" +
+		"\n" +
+		"    - [x] Grandchild @done(" + today + ")\n" +
+		"  - Note\n" +
+		"- [ ] Sibling\n"
+	if completed != expected {
+		t.Errorf("ToggleSubtreeChildren(complete) =\n%s\nwant:\n%s", completed, expected)
+	}
+	if count != 2 {
+		t.Errorf("ToggleSubtreeChildren(complete) count = %d, want 2", count)
+	}
+
+	reopened, count := ToggleSubtreeChildren(completed, 0, false, DefaultOptions())
+	expected = "- [ ] Parent\n" +
+		"  - [ ] Child 1\n" +
+		"  - [ ] Child 2\n" +
+		"\n" +
+		"    - [ ] Grandchild\n" +
+		"  - Note\n" +
+		"- [ ] Sibling\n"
+	if reopened != expected {
+		t.Errorf("ToggleSubtreeChildren(reopen) =\n%s\nwant:\n%s", reopened, expected)
+	}
+	if count != 3 {
+		t.Errorf("ToggleSubtreeChildren(reopen) count = %d, want 3", count)
 	}
 }
 
-// helper function
-func containsString(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
+// TestToggleSubtreeChildrenNoChildren verifies that non-task lines, leaf tasks,
+// and out-of-range lines are left unchanged.
+func TestToggleSubtreeChildrenNoChildren(t *testing.T) {
+	content := "# Heading\n  - [ ] Not a child of the heading\n- [ ] Leaf\n"
+	for _, line := range []int{-1, 0, 2, 10} {
+		if got, count := ToggleSubtreeChildren(content, line, true, DefaultOptions()); got != content || count != 0 {
+			t.Errorf("ToggleSubtreeChildren(line %d) changed %d line(s)", line, count)
 		}
 	}
-	return false
 }
 
-// =============================================================================
-// File Operations Tests
-// =============================================================================
+// TestChildrenCompleted verifies the toggle direction: true only when there are
+// child tasks and every one of them is completed.
+func TestChildrenCompleted(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{"all done", "- [ ] P\n  - [x] A\n    - [x] B\n", true},
+		{"one open", "- [ ] P\n  - [x] A\n    - [ ] B\n", false},
+		{"no children", "- [ ] P\n- [x] Q\n", false},
+		{"only notes", "- [ ] P\n  - note\n", false},
+	}
 
-// TestLoadFile verifies that LoadFile() reads file content correctly.
-// It should return the file content as a string, or an error if the file doesn't exist.
-func TestLoadFile(t *testing.T) {
-	// Create a temporary file
-	tmpDir := t.TempDir()
-	testFile := tmpDir + "/test-tasks.md"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ChildrenCompleted(tt.content, 0, DefaultOptions()); got != tt.expected {
+				t.Errorf("ChildrenCompleted() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
 
-	content := "- [ ] Task 1\n- [x] Task 2\n"
-	if err := WriteFile(testFile, content); err != nil {
-		t.Fatalf("WriteFile() setup error: %v", err)
+// TestBulletStyles verifies that extra bullet styles are recognized by IsTask and IsCompleted,
+// and that the default only accepts "-".
+func TestBulletStyles(t *testing.T) {
+	lines := []string{"- [ ] dash", "* [x] star", "+ [ ] plus", "  * [X] indented star"}
+
+	for _, line := range lines[1:] {
+		if IsTask(line, DefaultOptions()) {
+			t.Errorf("IsTask(%q) with default styles = true, want false", line)
+		}
 	}
 
-	// Test loading existing file
-	result, err := LoadFile(testFile)
-	if err != nil {
-		t.Fatalf("LoadFile() error: %v", err)
+	opts := DefaultOptions()
+	opts.BulletStyles = []string{"*", "-", "+"}
+	for _, line := range lines {
+		if !IsTask(line, opts) {
+			t.Errorf("IsTask(%q) = false, want true", line)
+		}
 	}
-	if result != content {
-		t.Errorf("LoadFile() = %q, want %q", result, content)
+	if !IsCompleted("* [x] star", opts) || !IsCompleted("  * [X] indented star", opts) || IsCompleted("+ [ ] plus", opts) {
+		t.Error("IsCompleted() should follow the configured bullet styles")
+	}
+	if IsTask(", [ ] comma", opts) || IsTask("[ ] no bullet", opts) {
+		t.Error("characters between bullets must not be matched as a range")
 	}
 
-	// Test loading non-existent file
-	_, err = LoadFile(tmpDir + "/nonexistent.md")
-	if err == nil {
-		t.Error("LoadFile() should return error for non-existent file")
+	for _, styles := range [][]string{nil, {"-", "#"}, {"--"}} {
+		if err := ValidateBulletStyles(styles); err == nil {
+			t.Errorf("ValidateBulletStyles(%q) should return error", styles)
+		}
 	}
 }
 
-// TestWriteFile verifies that WriteFile() writes content to a file correctly.
-// It should create the file if it doesn't exist, or overwrite if it does.
-func TestWriteFile(t *testing.T) {
-	tmpDir := t.TempDir()
-	testFile := tmpDir + "/test-output.md"
+// TestMixedBulletHierarchy verifies cascade completion and archiving with a "*" parent
+// and "-" children when both bullet styles are enabled.
+func TestMixedBulletHierarchy(t *testing.T) {
+	opts := DefaultOptions()
+	opts.BulletStyles = []string{"-", "*"}
 
-	content := "- [ ] New task\n"
+	today := time.Now().Format("2006-01-02")
+	content := "* [x] Parent\n  - [ ] Child\n    * [ ] Grandchild\n- [ ] Other\n"
 
-	// Write to new file
-	err := WriteFile(testFile, content)
+	processed, count := ProcessContent(content, opts)
+	expected := "* [x] Parent @done(" + today + ")\n" +
+		"  - [x] Child @done(" + today + ")\n" +
+		"    * [x] Grandchild @done(" + today + ")\n" +
+		"- [ ] Other\n"
+	if processed != expected {
+		t.Errorf("ProcessContent() =\n%s\nwant:\n%s", processed, expected)
+	}
+	if count != 3 {
+		t.Errorf("ProcessContent() count = %d, want 3", count)
+	}
+
+	old := "* [x] Parent @done(2020-01-01)\n  - [x] Child @done(2020-01-01)\n- [ ] Other\n"
+	archivable, remaining := FilterArchivable(old, FixedDelay(2), opts)
+	if len(archivable) != 2 {
+		t.Errorf("FilterArchivable() archived %d line(s), want 2", len(archivable))
+	}
+	if remaining != "- [ ] Other\n" {
+		t.Errorf("FilterArchivable() remaining = %q, want %q", remaining, "- [ ] Other\n")
+	}
+
+	reopened, n := ToggleSubtreeChildren("- [ ] P\n  * [x] C @done(2020-01-01)\n", 0, false, opts)
+	if reopened != "- [ ] P\n  * [ ] C\n" || n != 1 {
+		t.Errorf("ToggleSubtreeChildren() = %q, %d", reopened, n)
+	}
+}
+
+// TestNumberedTasks verifies that "1. [ ]" and "2) [x]" lines are tasks only with
+// Options.Numbered, and that cascade completion, archiving, and editing work in
+// hierarchies mixing numbered and "-" tasks.
+func TestNumberedTasks(t *testing.T) {
+	lines := []string{"1. [ ] step", "2) [x] step", "  10. [X] nested step"}
+	for _, line := range lines {
+		if IsTask(line, DefaultOptions()) {
+			t.Errorf("IsTask(%q) with numbered tasks off = true, want false", line)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.Numbered = true
+	for _, line := range lines {
+		if !IsTask(line, opts) {
+			t.Errorf("IsTask(%q) = false, want true", line)
+		}
+	}
+	for _, line := range []string{"1. step", "1 [ ] step", "a. [ ] step", "* [ ] star", "- [ ] dash"} {
+		if IsTask(line, opts) != (line == "- [ ] dash") {
+			t.Errorf("IsTask(%q) = %v", line, IsTask(line, opts))
+		}
+	}
+	if !IsCompleted("2) [x] step", opts) || IsCompleted("1. [ ] step", opts) {
+		t.Error("IsCompleted() should accept numbered tasks")
+	}
+	if got := TaskBody("1. [x] Boil water @done(2026-01-20)", opts); got != "Boil water" {
+		t.Errorf("TaskBody() = %q, want %q", got, "Boil water")
+	}
+	if got := ReplaceBodyWith("3) [ ] Boil water @est(5m)", "Boil tea", opts); got != "3) [ ] Boil tea @est(5m)" {
+		t.Errorf("ReplaceBodyWith() = %q", got)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	content := "1. [x] Parent\n   - [ ] Child\n     1) [ ] Grandchild\n2. [ ] Other\n- [x] Dash parent\n  1. [ ] Numbered child\n"
+	processed, count := ProcessContent(content, opts)
+	expected := "1. [x] Parent @done(" + today + ")\n" +
+		"   - [x] Child @done(" + today + ")\n" +
+		"     1) [x] Grandchild @done(" + today + ")\n" +
+		"2. [ ] Other\n" +
+		"- [x] Dash parent @done(" + today + ")\n" +
+		"  1. [x] Numbered child @done(" + today + ")\n"
+	if processed != expected {
+		t.Errorf("ProcessContent() =\n%s\nwant:\n%s", processed, expected)
+	}
+	if count != 5 {
+		t.Errorf("ProcessContent() count = %d, want 5", count)
+	}
+
+	old := "1. [x] Parent @done(2020-01-01)\n   - [x] Child @done(2020-01-01)\n2. [ ] Other\n"
+	archivable, remaining := FilterArchivable(old, FixedDelay(2), opts)
+	if len(archivable) != 2 {
+		t.Errorf("FilterArchivable() archived %d line(s), want 2", len(archivable))
+	}
+	if remaining != "2. [ ] Other\n" {
+		t.Errorf("FilterArchivable() remaining = %q, want %q", remaining, "2. [ ] Other\n")
+	}
+
+	reopened, n := ToggleSubtreeChildren("- [ ] P\n  1. [x] C @done(2020-01-01)\n", 0, false, opts)
+	if reopened != "- [ ] P\n  1. [ ] C\n" || n != 1 {
+		t.Errorf("ToggleSubtreeChildren() = %q, %d", reopened, n)
+	}
+}
+
+// TestEnsureTitle verifies that the title is added only when the file has no heading.
+func TestEnsureTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		title    string
+		expected string
+	}{
+		{"empty file", "", "# Tasks", "# Tasks\n\n"},
+		{"tasks without heading", "- [ ] a\n", "# Tasks", "# Tasks\n\n- [ ] a\n"},
+		{"leading blank lines", "\n\n- [ ] a\n", "# Tasks", "# Tasks\n\n- [ ] a\n"},
+		{"heading at top", "# Inbox\n- [ ] a\n", "# Tasks", "# Inbox\n- [ ] a\n"},
+		{"heading further down", "- [ ] a\n\n## Later\n", "# Tasks", "- [ ] a\n\n## Later\n"},
+		{"empty title", "- [ ] a\n", "", "- [ ] a\n"},
+		{"hashtag is not a heading", "- [ ] a #home\n#tag\n", "# Tasks", "# Tasks\n\n- [ ] a #home\n#tag\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EnsureTitle(tt.content, tt.title); got != tt.expected {
+				t.Errorf("EnsureTitle() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRouteByPrefix verifies prefix matching against "##" headings.
+func TestRouteByPrefix(t *testing.T) {
+	content := "# Tasks\n\n## Work\n- [ ] a\n\n## Workshop\n\n## Home\n```\n## Code\n```\n"
+
+	tests := []struct {
+		name          string
+		content       string
+		text          string
+		wantHeading   string
+		wantRemainder string
+		wantMatched   bool
+	}{
+		{"matching prefix", content, "work: review PR 42", "Work", "review PR 42", true},
+		{"case-insensitive", content, "HOME: buy milk", "Home", "buy milk", true},
+		{"no space after colon", content, "home:buy milk", "Home", "buy milk", true},
+		{"ambiguous prefix picks the exact match", content, "workshop: book room", "Workshop", "book room", true},
+		{"partial prefix does not match", content, "wor: review", "", "wor: review", false},
+		{"colon inside the task text", content, "Fix bug: crash on start", "", "Fix bug: crash on start", false},
+		{"unknown prefix", content, "misc: call mom", "", "misc: call mom", false},
+		{"level-1 heading does not match", content, "tasks: x", "", "tasks: x", false},
+		{"heading in a code block", content, "code: x", "", "code: x", false},
+		{"prefix without task text", content, "work:", "", "work:", false},
+		{"url", content, "https://example.com", "", "https://example.com", false},
+		{"no headings", "- [ ] a\n", "work: review", "", "work: review", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heading, remainder, matched := RouteByPrefix(tt.content, tt.text)
+			if heading != tt.wantHeading || remainder != tt.wantRemainder || matched != tt.wantMatched {
+				t.Errorf("RouteByPrefixWith() = (%q, %q, %v), want (%q, %q, %v)",
+					heading, remainder, matched, tt.wantHeading, tt.wantRemainder, tt.wantMatched)
+			}
+		})
+	}
+}
+
+// TestInsertUnderHeading verifies that a task is added at the end of its section.
+func TestInsertUnderHeading(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		heading  string
+		expected string
+	}{
+		{
+			name:     "section followed by another",
+			content:  "## Work\n- [ ] a\n\n## Home\n- [ ] b\n",
+			heading:  "Work",
+			expected: "## Work\n- [ ] a\n- [ ] new\n\n## Home\n- [ ] b\n",
+		},
+		{
+			name:     "last section",
+			content:  "## Work\n- [ ] a\n\n## Home\n- [ ] b\n",
+			heading:  "Home",
+			expected: "## Work\n- [ ] a\n\n## Home\n- [ ] b\n- [ ] new\n",
+		},
+		{
+			name:     "subsections stay in the section",
+			content:  "## Work\n### Later\n- [ ] a\n# Next\n",
+			heading:  "Work",
+			expected: "## Work\n### Later\n- [ ] a\n- [ ] new\n# Next\n",
+		},
+		{
+			name:     "empty section",
+			content:  "## Work\n\n## Home\n",
+			heading:  "Work",
+			expected: "## Work\n- [ ] new\n\n## Home\n",
+		},
+		{
+			name:     "missing heading appends",
+			content:  "- [ ] a",
+			heading:  "Work",
+			expected: "- [ ] a\n- [ ] new\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := InsertUnderHeading(tt.content, tt.heading, "- [ ] new", DefaultOptions()); got != tt.expected {
+				t.Errorf("InsertUnderHeading() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestInsertionPosition verifies the line and heading InsertUnderHeading and
+// InsertChild report for the new line.
+func TestInsertionPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		heading string
+		parent  int // 1-indexed parent line for InsertChild, 0 for InsertUnderHeading
+		want    Insertion
+	}{
+		{"under a section", "# Tasks\n\n## Work\n- [ ] a\n\n## Inbox\n- [ ] b\n", "Work", 0, Insertion{Line: 5, Heading: "## Work"}},
+		{"end of the last section", "# Tasks\n## Inbox\n- [ ] b\n", "", 0, Insertion{Line: 4, Heading: "## Inbox"}},
+		{"no heading", "- [ ] a\n- [ ] b", "", 0, Insertion{Line: 3}},
+		{"empty file", "", "", 0, Insertion{Line: 1}},
+		{"heading in a code block ignored", "```\n# not a heading\n```\n", "", 0, Insertion{Line: 4}},
+		{"subheading", "## Work\n### Later\n- [ ] a\n", "Work", 0, Insertion{Line: 4, Heading: "### Later"}},
+		{"subtask", "## Home\n- [ ] Parent\n  - [ ] a\n- [ ] Other\n", "", 2, Insertion{Line: 4, Heading: "## Home"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Insertion
+			if tt.parent > 0 {
+				var err error
+				if _, got, err = InsertChild(tt.content, tt.parent, "new", DefaultOptions()); err != nil {
+					t.Fatalf("InsertChild() error: %v", err)
+				}
+			} else {
+				_, got = InsertUnderHeading(tt.content, tt.heading, "- [ ] new", DefaultOptions())
+			}
+			if got != tt.want {
+				t.Errorf("insertion = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestInsertChild verifies that a subtask is added below the parent's existing subtasks,
+// one level deeper than the parent.
+func TestInsertChild(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		line     int
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "first child",
+			content:  "- [ ] Parent\n- [ ] Other\n",
+			line:     1,
+			expected: "- [ ] Parent\n  - [ ] new\n- [ ] Other\n",
+		},
+		{
+			name:     "after existing subtasks and notes",
+			content:  "- [ ] Parent\n  - [ ] a\n    - [ ] a1\n  note\n- [ ] Other\n",
+			line:     1,
+			expected: "- [ ] Parent\n  - [ ] a\n    - [ ] a1\n  note\n  - [ ] new\n- [ ] Other\n",
+		},
+		{
+			name:     "nested parent",
+			content:  "- [ ] Parent\n  - [x] Child\n",
+			line:     2,
+			expected: "- [ ] Parent\n  - [x] Child\n    - [ ] new\n",
+		},
+		{
+			name:     "tab-indented parent",
+			content:  "- [ ] Parent\n\t- [ ] Child\n",
+			line:     2,
+			expected: "- [ ] Parent\n\t- [ ] Child\n    - [ ] new\n",
+		},
+		{
+			name:     "last line without newline",
+			content:  "# Tasks\n- [ ] Parent",
+			line:     2,
+			expected: "# Tasks\n- [ ] Parent\n  - [ ] new",
+		},
+		{"heading line", "# Tasks\n- [ ] Parent\n", 1, "", true},
+		{"line zero", "- [ ] Parent\n", 0, "", true},
+		{"past the end", "- [ ] Parent\n", 5, "", true},
+		{"task in a code block", "```\n- [ ] example\n```\n", 2, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := InsertChild(tt.content, tt.line, "new", DefaultOptions())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InsertChild() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("InsertChild() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestInsertChildMaxDepth verifies that Options.MaxDepth refuses subtasks nested too deep.
+func TestInsertChildMaxDepth(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxDepth = 2
+	content := "- [ ] Parent\n  - [ ] Child\n"
+
+	if _, _, err := InsertChild(content, 1, "new", opts); err != nil {
+		t.Errorf("InsertChild() at depth 2 error = %v, want nil", err)
+	}
+	_, _, err := InsertChild(content, 2, "new", opts)
+	if err == nil || !strings.Contains(err.Error(), "3 levels deep, over the limit of 2") {
+		t.Errorf("InsertChild() at depth 3 error = %v, want the depth limit", err)
+	}
+
+	if _, _, err := InsertChild(content, 2, "new", DefaultOptions()); err != nil {
+		t.Errorf("InsertChild() without a limit error = %v", err)
+	}
+}
+
+// TestTaskDepths verifies nesting levels, including tab and uneven indentation.
+func TestTaskDepths(t *testing.T) {
+	content := "# Tasks\n- [ ] A\n    - [ ] A1\n\t\t\t- [ ] A1a\n  note\n- [ ] B\n  - [ ] B1\n"
+	if got, want := fmt.Sprint(TaskDepths(ParseLines(content, DefaultOptions()))), "map[1:1 2:2 3:3 5:1 6:2]"; got != want {
+		t.Errorf("TaskDepths() = %s, want %s", got, want)
+	}
+
+	var deep []int
+	for _, line := range TooDeepTasks(content, 1, DefaultOptions()) {
+		deep = append(deep, line.LineNumber)
+	}
+	if fmt.Sprint(deep) != "[2 3 6]" {
+		t.Errorf("TooDeepTasks(1) lines = %v, want [2 3 6]", deep)
+	}
+	if got := TooDeepTasks(content, 0, DefaultOptions()); got != nil {
+		t.Errorf("TooDeepTasks(0) = %v, want nil", got)
+	}
+}
+
+// TestExtractSubtree verifies that a task is cut out with everything nested under it,
+// shifted to the top level, and that the rest of the file is left as it was.
+func TestExtractSubtree(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		line          int
+		wantSubtree   string
+		wantRemaining string
+		wantErr       bool
+	}{
+		{
+			name:          "single task",
+			content:       "# Tasks\n- [ ] a\n- [ ] b\n",
+			line:          1,
+			wantSubtree:   "- [ ] a",
+			wantRemaining: "# Tasks\n- [ ] b\n",
+		},
+		{
+			name:          "subtasks, notes, and blank lines inside",
+			content:       "- [ ] a\n  - [ ] a1\n\n    note\n  - [x] a2 @done(2026-01-20)\n- [ ] b\n",
+			line:          0,
+			wantSubtree:   "- [ ] a\n  - [ ] a1\n\n    note\n  - [x] a2 @done(2026-01-20)",
+			wantRemaining: "- [ ] b\n",
+		},
+		{
+			name:          "trailing blank line stays",
+			content:       "- [ ] a\n  - [ ] a1\n\n- [ ] b",
+			line:          0,
+			wantSubtree:   "- [ ] a\n  - [ ] a1",
+			wantRemaining: "\n- [ ] b",
+		},
+		{
+			name:          "nested task is dedented",
+			content:       "- [ ] a\n  - [ ] a1\n    - [ ] a1x\n  - [ ] a2\n",
+			line:          1,
+			wantSubtree:   "- [ ] a1\n  - [ ] a1x",
+			wantRemaining: "- [ ] a\n  - [ ] a2\n",
+		},
+		{
+			name:          "tab-indented task",
+			content:       "- [ ] a\n\t- [ ] a1\n\t\t- [ ] a1x\n",
+			line:          1,
+			wantSubtree:   "- [ ] a1\n\t- [ ] a1x",
+			wantRemaining: "- [ ] a\n",
+		},
+		{
+			name:          "last line without newline",
+			content:       "- [ ] a\n- [ ] b",
+			line:          1,
+			wantSubtree:   "- [ ] b",
+			wantRemaining: "- [ ] a",
+		},
+		{name: "heading line", content: "# Tasks\n- [ ] a\n", line: 0, wantErr: true},
+		{name: "negative line", content: "- [ ] a\n", line: -1, wantErr: true},
+		{name: "past the end", content: "- [ ] a\n", line: 5, wantErr: true},
+		{name: "task in a code block", content: "```\n- [ ] example\n```\n", line: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subtree, remaining, err := ExtractSubtree(tt.content, tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtractSubtreeWith() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if subtree != tt.wantSubtree {
+				t.Errorf("ExtractSubtreeWith() subtree = %q, want %q", subtree, tt.wantSubtree)
+			}
+			if remaining != tt.wantRemaining {
+				t.Errorf("ExtractSubtreeWith() remaining = %q, want %q", remaining, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+// TestReindentBlock verifies that a block is moved to the target indentation with
+// the nesting inside it kept.
+func TestReindentBlock(t *testing.T) {
+	tests := []struct {
+		name   string
+		block  string
+		indent int
+		want   string
+	}{
+		{name: "top level to nested", block: "- [ ] a\n  - [ ] a1", indent: 2, want: "  - [ ] a\n    - [ ] a1"},
+		{name: "nested to top level", block: "    - [ ] a\n      - [ ] a1", indent: 0, want: "- [ ] a\n  - [ ] a1"},
+		{name: "unchanged", block: "- [ ] a\n  note", indent: 0, want: "- [ ] a\n  note"},
+		{name: "tabs become spaces", block: "- [ ] a\n\t- [ ] a1", indent: 2, want: "  - [ ] a\n    - [ ] a1"},
+		{name: "blank lines are emptied", block: "- [ ] a\n   \n  - [ ] a1", indent: 4, want: "    - [ ] a\n\n      - [ ] a1"},
+		{name: "lines left of the first stop at column 0", block: "  - [ ] a\n- [ ] b", indent: 0, want: "- [ ] a\n- [ ] b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReindentBlock(tt.block, tt.indent); got != tt.want {
+				t.Errorf("ReindentBlock() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPasteBlock verifies that a block is pasted below a line, after the subtasks of
+// a task and at its indentation.
+func TestPasteBlock(t *testing.T) {
+	block := "- [ ] x\n  - [ ] x1"
+
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "below a task",
+			content: "- [ ] a\n- [ ] b\n",
+			line:    0,
+			want:    "- [ ] a\n- [ ] x\n  - [ ] x1\n- [ ] b\n",
+		},
+		{
+			name:    "after the subtasks of a task",
+			content: "- [ ] a\n  - [ ] a1\n\n    note\n- [ ] b\n",
+			line:    0,
+			want:    "- [ ] a\n  - [ ] a1\n\n    note\n- [ ] x\n  - [ ] x1\n- [ ] b\n",
+		},
+		{
+			name:    "below a subtask, at its indentation",
+			content: "- [ ] a\n  - [ ] a1\n  - [ ] a2\n",
+			line:    1,
+			want:    "- [ ] a\n  - [ ] a1\n  - [ ] x\n    - [ ] x1\n  - [ ] a2\n",
+		},
+		{
+			name:    "below a heading",
+			content: "## Work\n- [ ] a\n",
+			line:    0,
+			want:    "## Work\n- [ ] x\n  - [ ] x1\n- [ ] a\n",
+		},
+		{
+			name:    "last line without newline",
+			content: "- [ ] a",
+			line:    0,
+			want:    "- [ ] a\n- [ ] x\n  - [ ] x1",
+		},
+		{name: "negative line", content: "- [ ] a\n", line: -1, wantErr: true},
+		{name: "past the end", content: "- [ ] a\n", line: 5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PasteBlock(tt.content, tt.line, block, DefaultOptions())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PasteBlock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PasteBlock() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCountOpenTasks verifies that open tasks are counted at any depth, leaving out
+// completed tasks, notes, and examples in code blocks and quotes.
+func TestCountOpenTasks(t *testing.T) {
+	content := "# Tasks\n- [ ] A\n  - [ ] A1\n  - [x] A2 @done(2026-01-18)\n- note\n" +
+		"```\n- [ ] Example\n```\n> - [ ] Quoted\n- [ ] B\n"
+	if got := CountOpenTasks(content, DefaultOptions()); got != 3 {
+		t.Errorf("CountOpenTasks() = %d, want 3", got)
+	}
+	if got := CountOpenTasks("", DefaultOptions()); got != 0 {
+		t.Errorf("CountOpenTasks(\"\") = %d, want 0", got)
+	}
+}
+
+// TestOpenLimitMessage verifies the warning is given only above a configured limit.
+func TestOpenLimitMessage(t *testing.T) {
+	tests := []struct {
+		open, limit int
+		want        string
+	}{
+		{43, 30, "You have 43 open tasks (limit 30) — consider archiving or pruning"},
+		{30, 30, ""},
+		{12, 30, ""},
+		{43, 0, ""},
+	}
+
+	for _, tt := range tests {
+		if got := OpenLimitMessage(tt.open, tt.limit); got != tt.want {
+			t.Errorf("OpenLimitMessage(%d, %d) = %q, want %q", tt.open, tt.limit, got, tt.want)
+		}
+	}
+}
+
+// TestLooksLikeTaskFileReadme verifies that a large README with a couple of checkbox
+// examples is not mistaken for a task list.
+func TestLooksLikeTaskFileReadme(t *testing.T) {
+	content, err := LoadFile("testdata/README.md", DefaultOptions())
+	if err != nil {
+		t.Fatalf("LoadFile() fixture error: %v", err)
+	}
+
+	if LooksLikeTaskFile(content, DefaultOptions()) {
+		t.Error("LooksLikeTaskFile(README fixture) = true, want false")
+	}
+}
+
+// TestLooksLikeTaskFile verifies the heuristic on task files of varying sizes:
+// small files always pass, and large files pass when at least 1% of lines are tasks.
+func TestLooksLikeTaskFile(t *testing.T) {
+	taskFile := func(tasks, notes int) string {
+		var lines []string
+		for i := 0; i < tasks; i++ {
+			lines = append(lines, "- [ ] Task")
+		}
+		for i := 0; i < notes; i++ {
+			lines = append(lines, "Some notes")
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{"empty file", "", true},
+		{"small file without tasks", taskFile(0, 100), true},
+		{"typical task file", taskFile(40, 10), true},
+		{"large task file", taskFile(2000, 500), true},
+		{"large file exactly 1% tasks", taskFile(2, 198), true},
+		{"large file below 1% tasks", taskFile(1, 199), false},
+		{"large file without tasks", taskFile(0, 3000), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LooksLikeTaskFile(tt.content, DefaultOptions()); got != tt.expected {
+				t.Errorf("LooksLikeTaskFile() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestLooksLikeTaskFileWith verifies custom thresholds, including disabling the check.
+func TestLooksLikeTaskFileWith(t *testing.T) {
+	content := strings.Repeat("notes\n", 20) + "- [ ] Task\n"
+
+	if LooksLikeTaskFileWith(content, 10, 0.1, DefaultOptions()) {
+		t.Error("21 lines with 1 task should fail a 10%/10-line guard")
+	}
+	if !LooksLikeTaskFileWith(content, 10, 0.01, DefaultOptions()) {
+		t.Error("21 lines with 1 task should pass a 1%/10-line guard")
+	}
+	if !LooksLikeTaskFileWith(content, 0, 0.5, DefaultOptions()) {
+		t.Error("maxLines 0 should disable the check")
+	}
+}
+
+// TestHumanizeDate verifies that HumanizeDate() describes a date relative to now
+// by calendar day, ignoring the time of day.
+func TestHumanizeDate(t *testing.T) {
+	now := time.Date(2026, 1, 18, 9, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		date     time.Time
+		expected string
+	}{
+		{"same day", time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC), "今日"},
+		{"one day before", time.Date(2026, 1, 17, 0, 0, 0, 0, time.UTC), "昨日"},
+		{"three days before", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), "3日前"},
+		{"across month boundary", time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), "18日前"},
+		{"one day after", time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC), "明日"},
+		{"future", time.Date(2026, 1, 21, 0, 0, 0, 0, time.UTC), "3日後"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HumanizeDate(tt.date, now); got != tt.expected {
+				t.Errorf("HumanizeDate(%v) = %q, want %q", tt.date, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestFormatDoneTagsRelative verifies that FormatDoneTagsRelative() rewrites only valid
+// @done(YYYY-MM-DD) tags and leaves everything else unchanged.
+func TestFormatDoneTagsRelative(t *testing.T) {
+	now := time.Date(2026, 1, 18, 12, 0, 0, 0, time.Local)
+	content := "# Tasks\n" +
+		"- [x] A @done(2026-01-18)\n" +
+		"- [x] B @done(2026-01-15)\n" +
+		"- [x] C @done(invalid)\n" +
+		"- [ ] D"
+	expected := "# Tasks\n" +
+		"- [x] A @done(今日)\n" +
+		"- [x] B @done(3日前)\n" +
+		"- [x] C @done(invalid)\n" +
+		"- [ ] D"
+
+	if got := FormatDoneTagsRelative(content, now); got != expected {
+		t.Errorf("FormatDoneTagsRelative() = %q, want %q", got, expected)
+	}
+}
+
+// TestParseDoneDate verifies that ParseDoneDate() extracts the date from @done tag.
+// Returns the date and true if found, zero time and false otherwise.
+func TestParseDoneDate(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		expectedDay int // day of month, 0 if not found
+		found       bool
+	}{
+		{"valid done tag", "- [x] Task @done(2026-01-18)", 18, true},
+		{"no done tag", "- [x] Task", 0, false},
+		{"invalid date", "- [x] Task @done(invalid)", 0, false},
+		{"empty line", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, found := ParseDoneDate(tt.line)
+			if found != tt.found {
+				t.Errorf("ParseDoneDate(%q) found = %v, want %v", tt.line, found, tt.found)
+			}
+			if found && date.Day() != tt.expectedDay {
+				t.Errorf("ParseDoneDate(%q) day = %d, want %d", tt.line, date.Day(), tt.expectedDay)
+			}
+		})
+	}
+}
+
+// TestProcessContent verifies that ProcessContent() adds @done tags to all
+// newly completed tasks in the content. Returns the processed content and
+// the count of tasks that were modified.
+func TestProcessContent(t *testing.T) {
+	input := `# Tasks
+
+- [ ] Incomplete task
+- [x] Completed without done
+- [x] Already has @done(2026-01-15)
+- [x] Another completed
+`
+	result, count := ProcessContent(input, DefaultOptions())
+
+	// Should have modified 2 tasks (the two completed without @done)
+	if count != 2 {
+		t.Errorf("ProcessContent() count = %d, want 2", count)
+	}
+
+	// Result should contain @done tags for the modified tasks
+	if !containsString(result, "@done(") {
+		t.Error("ProcessContent() should add @done tags")
+	}
+
+	// Original @done tag should be preserved
+	if !containsString(result, "@done(2026-01-15)") {
+		t.Error("ProcessContent() should preserve existing @done tags")
+	}
+
+	// Incomplete task should remain unchanged
+	if !containsString(result, "- [ ] Incomplete task") {
+		t.Error("ProcessContent() should not modify incomplete tasks")
+	}
+}
+
+// TestProcessContentEscalation verifies that only open tasks overdue by more than
+// escalate_overdue_days are raised to @priority(A), and that nothing is lowered.
+func TestProcessContentEscalation(t *testing.T) {
+	opts := DefaultOptions()
+	opts.EscalateOverdueDays = 3
+
+	now := time.Date(2026, 1, 20, 9, 0, 0, 0, time.Local)
+	input := `# Tasks
+
+- [ ] Overdue by 5 days @due(2026-01-15) @p3
+- [ ] Overdue by 6 days @p2 @due(2026-01-14)
+- [ ] Overdue by 4 days @due(2026-01-16)
+- [ ] Overdue by 3 days @due(2026-01-17) @p2
+- [ ] Already urgent @due(2026-01-01) @p1
+- [ ] No due date @p3
+- [x] Done late @due(2026-01-01) @done(2026-01-19)
+`
+	expected := `# Tasks
+
+- [ ] Overdue by 5 days @due(2026-01-15) @p1
+- [ ] Overdue by 6 days @p1 @due(2026-01-14)
+- [ ] Overdue by 4 days @due(2026-01-16) @p1
+- [ ] Overdue by 3 days @due(2026-01-17) @p2
+- [ ] Already urgent @due(2026-01-01) @p1
+- [ ] No due date @p3
+- [x] Done late @due(2026-01-01) @done(2026-01-19)
+`
+
+	result, tagged, escalated := processContent(input, now, opts)
+	if result != expected {
+		t.Errorf("processContent() =\n%s\nwant\n%s", result, expected)
+	}
+	if tagged != 0 || escalated != 3 {
+		t.Errorf("processContent() counts = (%d, %d), want (0, 3)", tagged, escalated)
+	}
+
+	if _, _, escalated := processContent(input, now, DefaultOptions()); escalated != 0 {
+		t.Errorf("escalated %d task(s) with escalation off, want 0", escalated)
+	}
+}
+
+// TestFilterArchivable verifies that FilterArchivable() correctly identifies
+// tasks that should be archived based on the delay_days setting.
+func TestFilterArchivable(t *testing.T) {
+	// Create dates for testing
+	now := time.Now()
+	oldDate := now.AddDate(0, 0, -5).Format("2006-01-02")    // 5 days ago
+	recentDate := now.AddDate(0, 0, -1).Format("2006-01-02") // 1 day ago
+
+	content := `# Tasks
+
+- [ ] Incomplete task
+- [x] Old completed @done(` + oldDate + `)
+- [x] Recent completed @done(` + recentDate + `)
+- [x] No done tag
+`
+
+	archivableTasks, remaining := FilterArchivable(content, FixedDelay(2), DefaultOptions()) // 2 day delay
+	archivable := archiveTasksToString(archivableTasks)
+
+	// Old task should be archivable
+	if !containsString(archivable, "Old completed") {
+		t.Error("FilterArchivable() should include old completed task")
+	}
+
+	// Recent task should remain
+	if !containsString(remaining, "Recent completed") {
+		t.Error("FilterArchivable() should keep recent completed task")
+	}
+
+	// Task without done tag should remain
+	if !containsString(remaining, "No done tag") {
+		t.Error("FilterArchivable() should keep task without @done tag")
+	}
+
+	// Incomplete task should remain
+	if !containsString(remaining, "Incomplete task") {
+		t.Error("FilterArchivable() should keep incomplete tasks")
+	}
+}
+
+// TestFilterArchivableDelayPerHeading verifies that each root task uses the delay of
+// its nearest heading, and the default delay elsewhere.
+func TestFilterArchivableDelayPerHeading(t *testing.T) {
+	daysAgo := func(n int) string { return time.Now().AddDate(0, 0, -n).Format("2006-01-02") }
+	delayFor := func(heading string) int {
+		switch heading {
+		case "Errands":
+			return 1
+		case "Projects":
+			return 7
+		}
+		return 3
+	}
+
+	content := "- [x] No heading, past default @done(" + daysAgo(4) + ")\n" +
+		"- [x] No heading, within default @done(" + daysAgo(2) + ")\n" +
+		"## Errands\n" +
+		"- [x] Errand, past 1 day @done(" + daysAgo(2) + ")\n" +
+		"- [x] Errand, today @done(" + daysAgo(0) + ")\n" +
+		"## Projects\n" +
+		"- [x] Project, past 7 days @done(" + daysAgo(8) + ")\n" +
+		"  - [x] Project child @done(" + daysAgo(8) + ")\n" +
+		"- [x] Project, within 7 days @done(" + daysAgo(6) + ")\n" +
+		"### Notes\n" +
+		"- [x] Unmatched heading, past default @done(" + daysAgo(4) + ")\n"
+
+	archivable, remaining := FilterArchivable(content, delayFor, DefaultOptions())
+
+	var got []string
+	for _, task := range archivable {
+		got = append(got, taskText(task.Content, DefaultOptions()))
+	}
+	want := []string{
+		"No heading, past default",
+		"Errand, past 1 day",
+		"Project, past 7 days",
+		"Project child",
+		"Unmatched heading, past default",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("FilterArchivable() archived %q, want %q", got, want)
+	}
+	for _, kept := range []string{"within default", "Errand, today", "Project, within 7 days"} {
+		if !strings.Contains(remaining, kept) {
+			t.Errorf("remaining should keep %q:\n%s", kept, remaining)
+		}
+	}
+}
+
+// TestFormatArchiveEntry verifies that FormatArchiveEntry() creates properly
+// formatted archive entries grouped by GroupDate.
+func TestFormatArchiveEntry(t *testing.T) {
+	date18, _ := time.Parse("2006-01-02", "2026-01-18")
+	date17, _ := time.Parse("2006-01-02", "2026-01-17")
+
+	tasks := []ArchiveTask{
+		{Content: "- [x] Task A @done(2026-01-18)", GroupDate: date18},
+		{Content: "- [x] Task B @done(2026-01-18)", GroupDate: date18},
+		{Content: "- [x] Task C @done(2026-01-17)", GroupDate: date17},
+	}
+
+	result := FormatArchiveEntry(tasks, DefaultOptions())
+
+	// Should have date headers
+	if !containsString(result, "## 2026-01-18") {
+		t.Error("FormatArchiveEntry() should include date header for 2026-01-18")
+	}
+	if !containsString(result, "## 2026-01-17") {
+		t.Error("FormatArchiveEntry() should include date header for 2026-01-17")
+	}
+
+	// Tasks should be included
+	if !containsString(result, "Task A") {
+		t.Error("FormatArchiveEntry() should include Task A")
+	}
+}
+
+// helper function
+func containsString(s, substr string) bool {
+	for i := 0; i <= len(s)-len(substr); i++ {
+		if s[i:i+len(substr)] == substr {
+			return true
+		}
+	}
+	return false
+}
+
+// =============================================================================
+// File Operations Tests
+// =============================================================================
+
+// TestLoadFile verifies that LoadFile() reads file content correctly.
+// It should return the file content as a string, or an error if the file doesn't exist.
+func TestLoadFile(t *testing.T) {
+	// Create a temporary file
+	tmpDir := t.TempDir()
+	testFile := tmpDir + "/test-tasks.md"
+
+	content := "- [ ] Task 1\n- [x] Task 2\n"
+	if err := WriteFile(testFile, content, DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
+	}
+
+	// Test loading existing file
+	result, err := LoadFile(testFile, DefaultOptions())
+	if err != nil {
+		t.Fatalf("LoadFile() error: %v", err)
+	}
+	if result != content {
+		t.Errorf("LoadFile() = %q, want %q", result, content)
+	}
+
+	// Test loading non-existent file
+	_, err = LoadFile(tmpDir+"/nonexistent.md", DefaultOptions())
+	if err == nil {
+		t.Error("LoadFile() should return error for non-existent file")
+	}
+}
+
+// TestLoadFileRefusesWrongFiles verifies that LoadFile refuses files over the size
+// limit without reading them, and files with a NUL byte near the start.
+func TestLoadFileRefusesWrongFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A generated file just over 1MB of task lines
+	large := tmpDir + "/large.md"
+	line := "- [ ] Exported task with some padding text\n"
+	if err := WriteFile(large, strings.Repeat(line, (1<<20)/len(line)+1), DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.MaxFileSizeMB = 1
+	_, err := LoadFile(large, opts)
+	if err == nil || !strings.Contains(err.Error(), "over the 1MB limit") {
+		t.Errorf("LoadFile(large) error = %v, want size limit error", err)
+	}
+
+	opts.MaxFileSizeMB = 2
+	if _, err := LoadFile(large, opts); err != nil {
+		t.Errorf("LoadFile(large) under the limit error: %v", err)
+	}
+
+	opts.MaxFileSizeMB = -1
+	if _, err := LoadFile(large, opts); err != nil {
+		t.Errorf("LoadFile(large) without a limit error: %v", err)
+	}
+
+	_, err = LoadFile("testdata/binary.md", DefaultOptions())
+	if err == nil || !strings.Contains(err.Error(), "looks like a binary file") {
+		t.Errorf("LoadFile(binary) error = %v, want binary file error", err)
+	}
+
+	// A NUL byte after the first 8KB is not checked
+	lateNUL := tmpDir + "/late-nul.md"
+	if err := WriteFile(lateNUL, strings.Repeat(line, 8<<10/len(line)+1)+"\x00\n", DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
+	}
+	if _, err := LoadFile(lateNUL, DefaultOptions()); err != nil {
+		t.Errorf("LoadFile(late NUL) error: %v", err)
+	}
+}
+
+// TestFormatSize verifies the sizes shown in LoadFile errors.
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{12, "12B"},
+		{512 << 10, "512KB"},
+		{10 << 20, "10MB"},
+		{200<<20 + 512<<10, "200.5MB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatSize(tt.n); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+// TestWriteFile verifies that WriteFile() writes content to a file correctly.
+// It should create the file if it doesn't exist, or overwrite if it does.
+func TestWriteFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := tmpDir + "/test-output.md"
+
+	content := "- [ ] New task\n"
+
+	// Write to new file
+	err := WriteFile(testFile, content, DefaultOptions())
+	if err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	// Verify content
+	result, err := LoadFile(testFile, DefaultOptions())
+	if err != nil {
+		t.Fatalf("LoadFile() verification error: %v", err)
+	}
+	if result != content {
+		t.Errorf("WriteFile() wrote %q, want %q", result, content)
+	}
+
+	// Overwrite existing file
+	newContent := "- [x] Updated task\n"
+	err = WriteFile(testFile, newContent, DefaultOptions())
+	if err != nil {
+		t.Fatalf("WriteFile() overwrite error: %v", err)
+	}
+
+	result, err = LoadFile(testFile, DefaultOptions())
 	if err != nil {
+		t.Fatalf("LoadFile() verification error: %v", err)
+	}
+	if result != newContent {
+		t.Errorf("WriteFile() overwrite wrote %q, want %q", result, newContent)
+	}
+}
+
+// TestWriteFileKeepsMode verifies that replacing a file keeps its permissions, and
+// that a new file is created with 0644.
+func TestWriteFileKeepsMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	newFile := filepath.Join(tmpDir, "new.md")
+	if err := WriteFile(newFile, "- [ ] a\n", DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if info, _ := os.Stat(newFile); info.Mode().Perm() != 0644 {
+		t.Errorf("new file mode = %v, want 0644", info.Mode().Perm())
+	}
+
+	private := filepath.Join(tmpDir, "private.md")
+	if err := os.WriteFile(private, []byte("- [ ] a\n"), 0600); err != nil {
+		t.Fatalf("os.WriteFile() error: %v", err)
+	}
+	if err := WriteFile(private, "- [x] a\n", DefaultOptions()); err != nil {
 		t.Fatalf("WriteFile() error: %v", err)
 	}
+	if err := PrependToFile(private, "## 2026-01-18\n\n", DefaultOptions()); err != nil {
+		t.Fatalf("PrependToFile() error: %v", err)
+	}
+	if info, _ := os.Stat(private); info.Mode().Perm() != 0600 {
+		t.Errorf("private file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+// TestAppendToFile verifies that AppendToFile() adds content to the beginning of a file.
+// New content should be prepended, not appended, for archive entries.
+func TestAppendToFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := tmpDir + "/test-archive.md"
+
+	// Write initial content
+	initial := "## 2026-01-17\n\n- [x] Old task @done(2026-01-17)\n\n"
+	if err := WriteFile(testFile, initial, DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
+	}
+
+	// Prepend new content
+	newContent := "## 2026-01-18\n\n- [x] New task @done(2026-01-18)\n\n"
+	err := PrependToFile(testFile, newContent, DefaultOptions())
+	if err != nil {
+		t.Fatalf("PrependToFile() error: %v", err)
+	}
+
+	// Verify new content is at the beginning
+	result, err := LoadFile(testFile, DefaultOptions())
+	if err != nil {
+		t.Fatalf("LoadFile() verification error: %v", err)
+	}
+
+	// New content should come first
+	if !containsString(result, "## 2026-01-18") {
+		t.Error("PrependToFile() should include new date header")
+	}
+	if !containsString(result, "## 2026-01-17") {
+		t.Error("PrependToFile() should preserve old date header")
+	}
+}
+
+// TestPrependToFileExactContent verifies that PrependToFile() produces exactly
+// new content followed by the previous file content, for both existing and missing files.
+func TestPrependToFileExactContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		initial  *string
+		prepend  string
+		expected string
+	}{
+		{"missing file", nil, "## 2026-01-18\n\n", "## 2026-01-18\n\n"},
+		{"empty file", ptr(""), "new\n", "new\n"},
+		{"existing content", ptr("old\n"), "new\n", "new\nold\n"},
+		{"empty prepend", ptr("old\n"), "", "old\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testFile := t.TempDir() + "/archive.md"
+			if tt.initial != nil {
+				if err := WriteFile(testFile, *tt.initial, DefaultOptions()); err != nil {
+					t.Fatalf("WriteFile() setup error: %v", err)
+				}
+			}
+
+			if err := PrependToFile(testFile, tt.prepend, DefaultOptions()); err != nil {
+				t.Fatalf("PrependToFile() error: %v", err)
+			}
+
+			result, err := LoadFile(testFile, DefaultOptions())
+			if err != nil {
+				t.Fatalf("LoadFile() verification error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("PrependToFile() result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestPrependToFileLeavesNoTempFiles verifies that PrependToFile() cleans up
+// its temporary file, so only the target file remains in the directory.
+func TestPrependToFileLeavesNoTempFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := tmpDir + "/archive.md"
+
+	if err := WriteFile(testFile, "old\n", DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
+	}
+	if err := PrependToFile(testFile, "new\n", DefaultOptions()); err != nil {
+		t.Fatalf("PrependToFile() error: %v", err)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadDir() error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "archive.md" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory contains %v, want only archive.md", names)
+	}
+}
+
+// BenchmarkPrependToFileLargeArchive measures prepending a small entry to a
+// 50MB archive. Allocations per op should stay near the entry size rather than
+// growing with the archive, since the existing content is streamed.
+func BenchmarkPrependToFileLargeArchive(b *testing.B) {
+	testFile := b.TempDir() + "/archive.md"
+
+	section := "## 2024-01-01\n\n- [x] Synthetic archived task @done(2024-01-01)\n\n"
+	archive := strings.Repeat(section, 50*1024*1024/len(section))
+	if err := WriteFile(testFile, archive, DefaultOptions()); err != nil {
+		b.Fatalf("WriteFile() setup error: %v", err)
+	}
+	entry := "## 2026-01-18\n\n- [x] New task @done(2026-01-18)\n\n"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := PrependToFile(testFile, entry, DefaultOptions()); err != nil {
+			b.Fatalf("PrependToFile() error: %v", err)
+		}
+	}
+}
+
+// ptr returns a pointer to s. Used to distinguish "no file" from "empty file".
+func ptr(s string) *string {
+	return &s
+}
+
+// TestArchive verifies the complete archive workflow.
+// It should move old completed tasks from tasks file to archive file.
+func TestArchive(t *testing.T) {
+	tmpDir := t.TempDir()
+	tasksFile := tmpDir + "/tasks.md"
+	archiveFile := tmpDir + "/archive.md"
+
+	// Create dates for testing
+	now := time.Now()
+	oldDate := now.AddDate(0, 0, -5).Format("2006-01-02")
+	recentDate := now.AddDate(0, 0, -1).Format("2006-01-02")
+
+	tasksContent := `# Tasks
+
+- [ ] Incomplete task
+- [x] Old task @done(` + oldDate + `)
+- [x] Recent task @done(` + recentDate + `)
+`
+
+	if err := WriteFile(tasksFile, tasksContent, DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
+	}
+
+	// Run archive with 2-day delay
+	count, lines, err := Archive(tasksFile, archiveFile, 2, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Archive() error: %v", err)
+	}
+
+	// Should have archived 1 task (the old one)
+	if count != 1 || lines != 1 {
+		t.Errorf("Archive() = (%d tasks, %d lines), want (1, 1)", count, lines)
+	}
+
+	// Verify tasks file no longer contains old task
+	remaining, err := LoadFile(tasksFile, DefaultOptions())
+	if err != nil {
+		t.Fatalf("LoadFile() tasks error: %v", err)
+	}
+	if containsString(remaining, "Old task") {
+		t.Error("Archive() should remove old task from tasks file")
+	}
+	if !containsString(remaining, "Recent task") {
+		t.Error("Archive() should keep recent task in tasks file")
+	}
+	if !containsString(remaining, "Incomplete task") {
+		t.Error("Archive() should keep incomplete task in tasks file")
+	}
 
-	// Verify content
-	result, err := LoadFile(testFile)
+	// Verify archive file contains old task
+	archived, err := LoadFile(archiveFile, DefaultOptions())
 	if err != nil {
-		t.Fatalf("LoadFile() verification error: %v", err)
+		t.Fatalf("LoadFile() archive error: %v", err)
 	}
-	if result != content {
-		t.Errorf("WriteFile() wrote %q, want %q", result, content)
+	if !containsString(archived, "Old task") {
+		t.Error("Archive() should add old task to archive file")
 	}
+	if !containsString(archived, "## "+oldDate) {
+		t.Error("Archive() should include date header in archive")
+	}
+}
 
-	// Overwrite existing file
-	newContent := "- [x] Updated task\n"
-	err = WriteFile(testFile, newContent)
-	if err != nil {
-		t.Fatalf("WriteFile() overwrite error: %v", err)
+// TestArchiveNoTasks verifies Archive() behavior when there are no tasks to archive.
+// It should return 0 count and not modify files unnecessarily.
+func TestArchiveNoTasks(t *testing.T) {
+	tmpDir := t.TempDir()
+	tasksFile := tmpDir + "/tasks.md"
+	archiveFile := tmpDir + "/archive.md"
+
+	tasksContent := "- [ ] Incomplete task\n- [x] Recent task @done(" + time.Now().Format("2006-01-02") + ")\n"
+	if err := WriteFile(tasksFile, tasksContent, DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
 	}
 
-	result, err = LoadFile(testFile)
+	count, lines, err := Archive(tasksFile, archiveFile, 2, DefaultOptions())
 	if err != nil {
-		t.Fatalf("LoadFile() verification error: %v", err)
+		t.Fatalf("Archive() error: %v", err)
 	}
-	if result != newContent {
-		t.Errorf("WriteFile() overwrite wrote %q, want %q", result, newContent)
+
+	if count != 0 || lines != 0 {
+		t.Errorf("Archive() = (%d tasks, %d lines), want (0, 0)", count, lines)
 	}
 }
 
-// TestAppendToFile verifies that AppendToFile() adds content to the beginning of a file.
-// New content should be prepended, not appended, for archive entries.
-func TestAppendToFile(t *testing.T) {
+// TestProcessAndArchive verifies that ProcessAndArchive() tags newly completed tasks
+// and archives old ones in one pass, reporting both counts.
+func TestProcessAndArchive(t *testing.T) {
 	tmpDir := t.TempDir()
-	testFile := tmpDir + "/test-archive.md"
+	tasksFile := tmpDir + "/tasks.md"
+	archiveFile := tmpDir + "/archive.md"
 
-	// Write initial content
-	initial := "## 2026-01-17\n\n- [x] Old task @done(2026-01-17)\n\n"
-	if err := WriteFile(testFile, initial); err != nil {
+	today := time.Now().Format("2006-01-02")
+	oldDate := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+
+	tasksContent := "- [ ] Incomplete\n" +
+		"- [x] Old task @done(" + oldDate + ")\n" +
+		"- [x] Just finished\n" +
+		"- [x] Also finished\n"
+	if err := WriteFile(tasksFile, tasksContent, DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
+	}
+	if err := WriteFile(archiveFile, "## 2026-01-01\n\n- [x] Ancient @done(2026-01-01)\n\n", DefaultOptions()); err != nil {
 		t.Fatalf("WriteFile() setup error: %v", err)
 	}
 
-	// Prepend new content
-	newContent := "## 2026-01-18\n\n- [x] New task @done(2026-01-18)\n\n"
-	err := PrependToFile(testFile, newContent)
+	tagged, archived, err := ProcessAndArchive(tasksFile, archiveFile, 2)
 	if err != nil {
-		t.Fatalf("PrependToFile() error: %v", err)
+		t.Fatalf("ProcessAndArchive() error: %v", err)
+	}
+	if tagged != 2 {
+		t.Errorf("tagged = %d, want 2", tagged)
+	}
+	if archived != 1 {
+		t.Errorf("archived = %d, want 1", archived)
 	}
 
-	// Verify new content is at the beginning
-	result, err := LoadFile(testFile)
-	if err != nil {
-		t.Fatalf("LoadFile() verification error: %v", err)
+	remaining, _ := LoadFile(tasksFile, DefaultOptions())
+	expectedRemaining := "- [ ] Incomplete\n" +
+		"- [x] Just finished @done(" + today + ")\n" +
+		"- [x] Also finished @done(" + today + ")\n"
+	if remaining != expectedRemaining {
+		t.Errorf("tasks file = %q, want %q", remaining, expectedRemaining)
 	}
 
-	// New content should come first
-	if !containsString(result, "## 2026-01-18") {
-		t.Error("PrependToFile() should include new date header")
+	archivedContent, _ := LoadFile(archiveFile, DefaultOptions())
+	expectedArchive := "## " + oldDate + "\n\n- [x] Old task @done(" + oldDate + ")\n\n" +
+		"## 2026-01-01\n\n- [x] Ancient @done(2026-01-01)\n\n"
+	if archivedContent != expectedArchive {
+		t.Errorf("archive file = %q, want %q", archivedContent, expectedArchive)
 	}
-	if !containsString(result, "## 2026-01-17") {
-		t.Error("PrependToFile() should preserve old date header")
+}
+
+// TestProcessAndArchiveCascadeModes pins what each tasks.cascade mode completes before
+// archiving: an old done parent takes its open subtasks to the archive as they are
+// (cascaded first only with "all"), and a newly checked parent completes all
+// descendants, its direct children, or nothing.
+func TestProcessAndArchiveCascadeModes(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	oldDate := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+
+	tasksContent := "- [x] Old project @done(" + oldDate + ")\n" +
+		"  - [ ] Step\n" +
+		"    - [ ] Detail\n" +
+		"- [x] New project\n" +
+		"  - [ ] Child\n" +
+		"    - [ ] Grandchild\n"
+
+	tests := []struct {
+		mode          string
+		wantTagged    int
+		wantRemaining string
+		wantArchive   string
+	}{
+		{
+			mode:       CascadeAll,
+			wantTagged: 5,
+			wantRemaining: "- [x] New project @done(" + today + ")\n" +
+				"  - [x] Child @done(" + today + ")\n" +
+				"    - [x] Grandchild @done(" + today + ")\n",
+			wantArchive: "## " + oldDate + "\n\n- [x] Old project @done(" + oldDate + ")\n" +
+				"  - [x] Step @done(" + today + ")\n" +
+				"    - [x] Detail @done(" + today + ")\n\n",
+		},
+		{
+			mode:       CascadeDirect,
+			wantTagged: 2,
+			wantRemaining: "- [x] New project @done(" + today + ")\n" +
+				"  - [x] Child @done(" + today + ")\n" +
+				"    - [ ] Grandchild\n",
+			wantArchive: "## " + oldDate + "\n\n- [x] Old project @done(" + oldDate + ")\n" +
+				"  - [ ] Step\n" +
+				"    - [ ] Detail\n\n",
+		},
+		{
+			mode:       CascadeOff,
+			wantTagged: 1,
+			wantRemaining: "- [x] New project @done(" + today + ")\n" +
+				"  - [ ] Child\n" +
+				"    - [ ] Grandchild\n",
+			wantArchive: "## " + oldDate + "\n\n- [x] Old project @done(" + oldDate + ")\n" +
+				"  - [ ] Step\n" +
+				"    - [ ] Detail\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			// Old project was done days ago; compare the modes without
+			// tasks.cascade_respect_manual, which would leave its steps open
+			opts := DefaultOptions()
+			opts.Cascade = tt.mode
+			opts.CascadeIgnoreManual = true
+
+			tmpDir := t.TempDir()
+			tasksFile := tmpDir + "/tasks.md"
+			archiveFile := tmpDir + "/archive.md"
+			if err := WriteFile(tasksFile, tasksContent, opts); err != nil {
+				t.Fatalf("WriteFile() setup error: %v", err)
+			}
+
+			tagged, _, err := ProcessAndArchiveRouted(tasksFile, archiveFile, nil, FixedDelay(2), opts)
+			if err != nil {
+				t.Fatalf("ProcessAndArchiveRouted() error: %v", err)
+			}
+			if tagged != tt.wantTagged {
+				t.Errorf("tagged = %d, want %d", tagged, tt.wantTagged)
+			}
+			if remaining, _ := LoadFile(tasksFile, opts); remaining != tt.wantRemaining {
+				t.Errorf("tasks file = %q, want %q", remaining, tt.wantRemaining)
+			}
+			if archived, _ := LoadFile(archiveFile, opts); archived != tt.wantArchive {
+				t.Errorf("archive file = %q, want %q", archived, tt.wantArchive)
+			}
+
+			// A second pass changes nothing: direct never reaches the grandchild later
+			if tagged, archived, err := ProcessAndArchiveRouted(tasksFile, archiveFile, nil, FixedDelay(2), opts); err != nil || tagged != 0 || len(archived) != 0 {
+				t.Errorf("second pass = (%d, %v, %v), want (0, none, nil)", tagged, archived, err)
+			}
+		})
 	}
 }
 
-// TestArchive verifies the complete archive workflow.
-// It should move old completed tasks from tasks file to archive file.
-func TestArchive(t *testing.T) {
+// TestCascadeCompletionModes verifies how far each mode cascades from a parent
+// checked since the last pass.
+func TestCascadeCompletionModes(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	input := "- [x] Parent\n  - [ ] Child\n    - [ ] Grandchild\n  - [x] Checked child\n    - [ ] Its child"
+
+	tests := []struct {
+		mode      string
+		wantCount int
+		completed []bool // per line
+	}{
+		{CascadeAll, 3, []bool{true, true, true, true, true}},
+		{CascadeDirect, 2, []bool{true, true, false, true, true}},
+		{CascadeOff, 0, []bool{true, false, false, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Cascade = tt.mode
+			result, count := CascadeCompletion(ParseLines(input, opts), today, opts)
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+			for i, want := range tt.completed {
+				if result[i].IsCompleted != want {
+					t.Errorf("line %d %q completed = %v, want %v", i, result[i].Content, result[i].IsCompleted, want)
+				}
+			}
+		})
+	}
+}
+
+// TestProcessContentCascadeOption verifies that processContent cascades as the
+// Options of each call say, so calls with different modes do not affect each other.
+func TestProcessContentCascadeOption(t *testing.T) {
+	now := time.Date(2026, 1, 20, 9, 0, 0, 0, time.Local)
+	input := "- [x] Parent\n  - [ ] Child\n    - [ ] Grandchild\n"
+
+	direct := DefaultOptions()
+	direct.Cascade = CascadeDirect
+	if _, tagged, _ := processContent(input, now, direct); tagged != 2 {
+		t.Errorf("processContent() with %q tagged %d, want 2", CascadeDirect, tagged)
+	}
+	if _, tagged, _ := processContent(input, now, DefaultOptions()); tagged != 3 {
+		t.Errorf("processContent() with %q tagged %d, want 3", CascadeAll, tagged)
+	}
+	if _, tagged, _ := processContent("---\ncascade: off\n---\n"+input, now, direct); tagged != 1 {
+		t.Errorf("processContent() with front matter cascade: off tagged %d, want 1", tagged)
+	}
+}
+
+// TestZeroOptions verifies that the zero Options behave like DefaultOptions.
+func TestZeroOptions(t *testing.T) {
+	old := time.Now().AddDate(0, 0, -3).Format("2006-01-02")
+	content := "- [x] Parent\n  - [ ] Child\n- [x] Old @done(" + old + ")\n  - [ ] Reopened\n* [x] star\n"
+
+	got, count := ProcessContent(content, Options{})
+	want, wantCount := ProcessContent(content, DefaultOptions())
+	if got != want || count != wantCount {
+		t.Errorf("ProcessContent(Options{}) = %q, %d; want %q, %d", got, count, want, wantCount)
+	}
+	if got, want := (Options{}).maxFileSizeMB(), DefaultOptions().maxFileSizeMB(); got != want {
+		t.Errorf("maxFileSizeMB() of Options{} = %d, want %d", got, want)
+	}
+}
+
+// TestPropagateCompletionUpward verifies that open parents are completed bottom-up once
+// all of their descendants are, and that leaves and partly done parents are left open.
+func TestPropagateCompletionUpward(t *testing.T) {
+	today := "2026-02-01"
+	tests := []struct {
+		name      string
+		input     string
+		wantCount int
+		completed []bool // per line
+	}{
+		{
+			name:      "all children done",
+			input:     "- [ ] Parent\n  - [x] A @done(2026-01-30)\n  - [x] B @done(2026-01-31)",
+			wantCount: 1,
+			completed: []bool{true, true, true},
+		},
+		{
+			name:      "one child open",
+			input:     "- [ ] Parent\n  - [x] A @done(2026-01-30)\n  - [ ] B",
+			wantCount: 0,
+			completed: []bool{false, true, false},
+		},
+		{
+			name:      "chains up through levels",
+			input:     "- [ ] Project\n  - [ ] Step\n    - [x] Sub @done(2026-01-30)\n  - [x] Other @done(2026-01-30)",
+			wantCount: 2,
+			completed: []bool{true, true, true, true},
+		},
+		{
+			name:      "done child with open grandchild",
+			input:     "- [ ] Parent\n  - [x] Child @done(2026-01-30)\n    - [ ] Grandchild",
+			wantCount: 0,
+			completed: []bool{false, true, false},
+		},
+		{
+			name:      "task without subtasks",
+			input:     "- [ ] Leaf\n- [x] Done @done(2026-01-30)",
+			wantCount: 0,
+			completed: []bool{false, true},
+		},
+		{
+			name:      "notes between subtasks",
+			input:     "- [ ] Parent\n  Some note\n  - [x] A @done(2026-01-30)",
+			wantCount: 1,
+			completed: []bool{true, false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := ParseLines(tt.input, DefaultOptions())
+			count, err := PropagateCompletionUpward(lines, today)
+			if err != nil {
+				t.Fatalf("PropagateCompletionUpwardWith() error: %v", err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+			for i, want := range tt.completed {
+				if lines[i].IsCompleted != want {
+					t.Errorf("line %d %q completed = %v, want %v", i, lines[i].Content, lines[i].IsCompleted, want)
+				}
+			}
+		})
+	}
+
+	t.Run("adds done tag", func(t *testing.T) {
+		lines := ParseLines("- [ ] Parent #work\n  - [x] A @done(2026-01-30)", DefaultOptions())
+		if _, err := PropagateCompletionUpward(lines, today); err != nil {
+			t.Fatalf("PropagateCompletionUpwardWith() error: %v", err)
+		}
+		if got, want := lines[0].Content, "- [x] Parent #work @done(2026-02-01)"; got != want {
+			t.Errorf("parent = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("renumbered lines", func(t *testing.T) {
+		lines := ParseLines("- [ ] Parent\n  - [x] A @done(2026-01-30)", DefaultOptions())[1:]
+		if _, err := PropagateCompletionUpward(lines, today); err == nil {
+			t.Error("PropagateCompletionUpwardWith() error = nil, want an error for lines not from ParseLines")
+		}
+	})
+}
+
+// TestProcessContentAutoCompleteParent verifies how upward completion and the downward
+// cascade interact in one pass, and that a second pass changes nothing.
+func TestProcessContentAutoCompleteParent(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	tests := []struct {
+		name      string
+		mode      string
+		enabled   bool
+		input     string
+		wantCount int
+		want      string
+	}{
+		{
+			name:      "off leaves parent open",
+			mode:      CascadeAll,
+			input:     "- [ ] Parent\n  - [x] A",
+			wantCount: 1,
+			want:      "- [ ] Parent\n  - [x] A @done(" + today + ")",
+		},
+		{
+			name:      "last child checked",
+			mode:      CascadeAll,
+			enabled:   true,
+			input:     "- [ ] Parent\n  - [x] A @done(2026-01-30)\n  - [x] B",
+			wantCount: 2,
+			want:      "- [x] Parent @done(" + today + ")\n  - [x] A @done(2026-01-30)\n  - [x] B @done(" + today + ")",
+		},
+		{
+			name:      "cascade down then up",
+			mode:      CascadeAll,
+			enabled:   true,
+			input:     "- [ ] Project\n  - [x] Step\n    - [ ] Sub\n  - [x] Other @done(2026-01-30)",
+			wantCount: 3,
+			want: "- [x] Project @done(" + today + ")\n  - [x] Step @done(" + today + ")\n" +
+				"    - [x] Sub @done(" + today + ")\n  - [x] Other @done(2026-01-30)",
+		},
+		{
+			name:      "direct cascade leaves grandchild open",
+			mode:      CascadeDirect,
+			enabled:   true,
+			input:     "- [ ] Project\n  - [x] Step\n    - [ ] Sub\n      - [ ] Note",
+			wantCount: 2,
+			want: "- [ ] Project\n  - [x] Step @done(" + today + ")\n" +
+				"    - [x] Sub @done(" + today + ")\n      - [ ] Note",
+		},
+		{
+			name:      "checked parent with open child",
+			mode:      CascadeOff,
+			enabled:   true,
+			input:     "- [ ] Project\n  - [x] Step\n    - [ ] Sub",
+			wantCount: 1,
+			want:      "- [ ] Project\n  - [x] Step @done(" + today + ")\n    - [ ] Sub",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.Cascade = tt.mode
+			opts.AutoCompleteParent = tt.enabled
+
+			got, count := ProcessContent(tt.input, opts)
+			if got != tt.want || count != tt.wantCount {
+				t.Errorf("ProcessContent() = %q, %d\nwant %q, %d", got, count, tt.want, tt.wantCount)
+			}
+			if again, count := ProcessContent(got, opts); again != got || count != 0 {
+				t.Errorf("second ProcessContent() = %q, %d; want it unchanged", again, count)
+			}
+		})
+	}
+}
+
+// TestProcessAndArchiveNothingToDo verifies that ProcessAndArchive() leaves both files
+// untouched (and does not create the archive) when nothing needs tagging or archiving.
+func TestProcessAndArchiveNothingToDo(t *testing.T) {
 	tmpDir := t.TempDir()
 	tasksFile := tmpDir + "/tasks.md"
 	archiveFile := tmpDir + "/archive.md"
 
-	// Create dates for testing
-	now := time.Now()
-	oldDate := now.AddDate(0, 0, -5).Format("2006-01-02")
-	recentDate := now.AddDate(0, 0, -1).Format("2006-01-02")
+	tasksContent := "- [ ] Incomplete\n"
+	if err := WriteFile(tasksFile, tasksContent, DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
+	}
 
-	tasksContent := `# Tasks
+	tagged, archived, err := ProcessAndArchive(tasksFile, archiveFile, 2)
+	if err != nil {
+		t.Fatalf("ProcessAndArchive() error: %v", err)
+	}
+	if tagged != 0 || archived != 0 {
+		t.Errorf("counts = (%d, %d), want (0, 0)", tagged, archived)
+	}
+	if _, err := os.Stat(archiveFile); !os.IsNotExist(err) {
+		t.Error("archive file should not be created")
+	}
+}
 
-- [ ] Incomplete task
-- [x] Old task @done(` + oldDate + `)
-- [x] Recent task @done(` + recentDate + `)
-`
+// TestNothingToDoWritesNothing verifies that no tagging or archiving entry point
+// writes (or creates) any file when nothing needs tagging or archiving, so a no-op
+// pass leaves modification times alone and produces no git diff.
+func TestNothingToDoWritesNothing(t *testing.T) {
+	recent := time.Now().Format("2006-01-02")
+	contents := map[string]string{
+		"open tasks only":      "# Inbox\n- [ ] Open\n  - [ ] Child\n",
+		"done, not yet due":    "# Inbox\n- [x] Done today @done(" + recent + ")\n- [ ] Open\n",
+		"done under a route":   "# Project X\n- [x] Ship X @done(" + recent + ")\n",
+		"empty file":           "",
+		"done in a code block": "```\n- [x] Example\n```\n",
+	}
+	entryPoints := map[string]func(tasksPath, archivePath string) error{
+		"ProcessFile": func(tasksPath, _ string) error {
+			_, _, err := ProcessFile(tasksPath, DefaultOptions())
+			return err
+		},
+		"Archive": func(tasksPath, archivePath string) error {
+			_, _, err := Archive(tasksPath, archivePath, 2, DefaultOptions())
+			return err
+		},
+		"ProcessAndArchive": func(tasksPath, archivePath string) error {
+			_, _, err := ProcessAndArchive(tasksPath, archivePath, 2)
+			return err
+		},
+		"ProcessAndArchiveRouted": func(tasksPath, archivePath string) error {
+			routes := map[string]string{"Project X": filepath.Dir(tasksPath) + "/archives/x.md"}
+			delayFor := func(heading string) int {
+				if heading == "Project X" {
+					return 1
+				}
+				return 2
+			}
+			_, _, err := ProcessAndArchiveRouted(tasksPath, archivePath, routes, delayFor, DefaultOptions())
+			return err
+		},
+	}
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for contentName, content := range contents {
+		for entryName, run := range entryPoints {
+			t.Run(contentName+"/"+entryName, func(t *testing.T) {
+				tmpDir := t.TempDir()
+				tasksFile := tmpDir + "/tasks.md"
+				if err := WriteFile(tasksFile, content, DefaultOptions()); err != nil {
+					t.Fatalf("WriteFile() setup error: %v", err)
+				}
+				if err := os.Chtimes(tasksFile, old, old); err != nil {
+					t.Fatalf("Chtimes() error: %v", err)
+				}
+
+				if err := run(tasksFile, tmpDir+"/archive.md"); err != nil {
+					t.Fatalf("%s() error: %v", entryName, err)
+				}
+
+				info, err := os.Stat(tasksFile)
+				if err != nil {
+					t.Fatalf("Stat() error: %v", err)
+				}
+				if !info.ModTime().Equal(old) {
+					t.Errorf("tasks file was rewritten (mtime %v, want %v)", info.ModTime(), old)
+				}
+				if got, _ := LoadFile(tasksFile, DefaultOptions()); got != content {
+					t.Errorf("tasks file = %q, want %q", got, content)
+				}
+				entries, _ := os.ReadDir(tmpDir)
+				if len(entries) != 1 {
+					t.Errorf("files in working dir = %d, want only tasks.md", len(entries))
+				}
+			})
+		}
+	}
+}
+
+// TestProcessAndArchivePartialFailure verifies rollback-safe ordering: when replacing
+// the tasks file fails after the archive was written, the archive is restored and
+// the tasks file keeps its original content (no new tags, nothing removed).
+func TestProcessAndArchivePartialFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	tasksFile := tmpDir + "/tasks.md"
+	archiveFile := tmpDir + "/archive.md"
 
-	if err := WriteFile(tasksFile, tasksContent); err != nil {
+	oldDate := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+	tasksContent := "- [x] Old task @done(" + oldDate + ")\n- [x] Just finished\n"
+	archiveContent := "## 2026-01-01\n\n- [x] Ancient @done(2026-01-01)\n\n"
+	if err := WriteFile(tasksFile, tasksContent, DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
+	}
+	if err := WriteFile(archiveFile, archiveContent, DefaultOptions()); err != nil {
 		t.Fatalf("WriteFile() setup error: %v", err)
 	}
-
-	// Run archive with 2-day delay
-	count, err := Archive(tasksFile, archiveFile, 2)
-	if err != nil {
-		t.Fatalf("Archive() error: %v", err)
+
+	// Fail only when the tasks file is being replaced
+	defer func(orig func(string, string) error) { rename = orig }(rename)
+	rename = func(from, to string) error {
+		if to == tasksFile {
+			return os.ErrPermission
+		}
+		return os.Rename(from, to)
+	}
+
+	_, _, err := ProcessAndArchive(tasksFile, archiveFile, 2)
+	if err == nil {
+		t.Fatal("ProcessAndArchive() should return error when tasks file cannot be written")
+	}
+	if !strings.Contains(err.Error(), "tasks file") {
+		t.Errorf("error should mention the tasks file, got: %v", err)
+	}
+
+	if got, _ := LoadFile(tasksFile, DefaultOptions()); got != tasksContent {
+		t.Errorf("tasks file = %q, want unchanged %q", got, tasksContent)
+	}
+	if got, _ := LoadFile(archiveFile, DefaultOptions()); got != archiveContent {
+		t.Errorf("archive file = %q, want restored %q", got, archiveContent)
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 2 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory contains %v, want only tasks.md and archive.md", names)
+	}
+}
+
+// TestArchivePreflight verifies that an archive.md that cannot be written fails before
+// any file is changed, and that a symlinked archive.md is followed rather than replaced.
+func TestArchivePreflight(t *testing.T) {
+	oldDate := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+	tasksContent := "- [x] Old task @done(" + oldDate + ")\n- [ ] Open\n"
+	archiveContent := "## 2026-01-01\n\n- [x] Ancient @done(2026-01-01)\n\n"
+
+	setup := func(t *testing.T) (dir, tasksFile, archiveFile string) {
+		dir = t.TempDir()
+		tasksFile = filepath.Join(dir, "tasks.md")
+		archiveFile = filepath.Join(dir, "archive.md")
+		if err := WriteFile(tasksFile, tasksContent, DefaultOptions()); err != nil {
+			t.Fatalf("WriteFile() setup error: %v", err)
+		}
+		return dir, tasksFile, archiveFile
+	}
+	assertUntouched := func(t *testing.T, err error, tasksFile string) {
+		t.Helper()
+		if err == nil || !strings.Contains(err.Error(), "archive.md is not writable: ") {
+			t.Fatalf("ProcessAndArchiveRouted() error = %v, want archive.md is not writable", err)
+		}
+		if got, _ := LoadFile(tasksFile, DefaultOptions()); got != tasksContent {
+			t.Errorf("tasks file = %q, want unchanged %q", got, tasksContent)
+		}
+	}
+
+	t.Run("read-only archive", func(t *testing.T) {
+		dir, tasksFile, archiveFile := setup(t)
+		if err := WriteFile(archiveFile, archiveContent, DefaultOptions()); err != nil {
+			t.Fatalf("WriteFile() setup error: %v", err)
+		}
+		defer func(orig func(string) (*os.File, error)) { openForAppend = orig }(openForAppend)
+		openForAppend = func(path string) (*os.File, error) {
+			if path == archiveFile {
+				return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrPermission}
+			}
+			return os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		}
+
+		_, _, err := ProcessAndArchiveRouted(tasksFile, archiveFile, nil, FixedDelay(2), DefaultOptions())
+		assertUntouched(t, err, tasksFile)
+		if got, _ := LoadFile(archiveFile, DefaultOptions()); got != archiveContent {
+			t.Errorf("archive file = %q, want unchanged", got)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+			t.Errorf("directory has %d entries, want only tasks.md and archive.md", len(entries))
+		}
+	})
+
+	t.Run("symlink to unmounted drive", func(t *testing.T) {
+		dir, tasksFile, archiveFile := setup(t)
+		if err := os.Symlink(filepath.Join(dir, "drive", "archive.md"), archiveFile); err != nil {
+			t.Fatalf("Symlink() error: %v", err)
+		}
+
+		_, _, err := ProcessAndArchiveRouted(tasksFile, archiveFile, nil, FixedDelay(2), DefaultOptions())
+		assertUntouched(t, err, tasksFile)
+		if info, err := os.Lstat(archiveFile); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("archive.md should still be a symlink, got %v, %v", info, err)
+		}
+	})
+
+	t.Run("symlink is followed", func(t *testing.T) {
+		dir, tasksFile, archiveFile := setup(t)
+		drive := filepath.Join(dir, "drive")
+		if err := os.Mkdir(drive, 0755); err != nil {
+			t.Fatalf("Mkdir() error: %v", err)
+		}
+		target := filepath.Join(drive, "archive.md")
+		if err := WriteFile(target, archiveContent, DefaultOptions()); err != nil {
+			t.Fatalf("WriteFile() setup error: %v", err)
+		}
+		if err := os.Symlink(target, archiveFile); err != nil {
+			t.Fatalf("Symlink() error: %v", err)
+		}
+
+		if _, archived, err := ProcessAndArchiveRouted(tasksFile, archiveFile, nil, FixedDelay(2), DefaultOptions()); err != nil || archived[archiveFile] != 1 {
+			t.Fatalf("ProcessAndArchiveRouted() = %v, %v; want 1 archived", archived, err)
+		}
+		if info, err := os.Lstat(archiveFile); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("archive.md should still be a symlink, got %v, %v", info, err)
+		}
+		if got, _ := LoadFile(target, DefaultOptions()); !strings.Contains(got, "Old task") || !strings.HasSuffix(got, archiveContent) {
+			t.Errorf("symlink target = %q, want the new entry before the old content", got)
+		}
+	})
+
+	t.Run("rename across filesystems", func(t *testing.T) {
+		dir, tasksFile, archiveFile := setup(t)
+		if err := WriteFile(archiveFile, archiveContent, DefaultOptions()); err != nil {
+			t.Fatalf("WriteFile() setup error: %v", err)
+		}
+		defer func(orig func(string, string) error) { rename = orig }(rename)
+		rename = func(from, to string) error {
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EXDEV}
+		}
+
+		if _, archived, err := ProcessAndArchiveRouted(tasksFile, archiveFile, nil, FixedDelay(2), DefaultOptions()); err != nil || archived[archiveFile] != 1 {
+			t.Fatalf("ProcessAndArchiveRouted() = %v, %v; want 1 archived", archived, err)
+		}
+		if got, _ := LoadFile(archiveFile, DefaultOptions()); !strings.Contains(got, "Old task") || !strings.HasSuffix(got, archiveContent) {
+			t.Errorf("archive file = %q, want the new entry before the old content", got)
+		}
+		if got, _ := LoadFile(tasksFile, DefaultOptions()); got != "- [ ] Open\n" {
+			t.Errorf("tasks file = %q, want only the open task", got)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+			t.Errorf("directory has %d entries, want no temporary files left", len(entries))
+		}
+	})
+}
+
+// TestPartitionArchive verifies that tasks are grouped by the route of their nearest heading.
+func TestPartitionArchive(t *testing.T) {
+	tasks := []ArchiveTask{
+		{Content: "- [x] A", Heading: "Project X"},
+		{Content: "- [x] B", Heading: "Inbox"},
+		{Content: "- [x] C", Heading: ""},
+		{Content: "- [x] D", Heading: "Project X"},
+		{Content: "- [x] E", Heading: "Project Y"},
+	}
+	routes := map[string]string{"Project X": "x.md", "Project Y": "y.md", "": "never.md"}
+
+	parts := PartitionArchive(tasks, routes, "archive.md")
+
+	expected := map[string][]string{
+		"x.md":       {"- [x] A", "- [x] D"},
+		"y.md":       {"- [x] E"},
+		"archive.md": {"- [x] B", "- [x] C"},
+	}
+	if len(parts) != len(expected) {
+		t.Fatalf("PartitionArchive() returned %d files, want %d: %+v", len(parts), len(expected), parts)
+	}
+	for path, want := range expected {
+		var got []string
+		for _, task := range parts[path] {
+			got = append(got, task.Content)
+		}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
 	}
+}
 
-	// Should have archived 1 task (the old one)
-	if count != 1 {
-		t.Errorf("Archive() count = %d, want 1", count)
+// TestProcessAndArchiveRouted verifies that one run archives tasks under several headings
+// into their routed files, records the nearest heading (not one inside a code block),
+// and sends unmapped headings to the default archive.
+func TestProcessAndArchiveRouted(t *testing.T) {
+	tmpDir := t.TempDir()
+	tasksFile := tmpDir + "/tasks.md"
+	archiveFile := tmpDir + "/archive.md"
+	projectFile := tmpDir + "/project-x.md"
+
+	oldDate := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+	tasksContent := "# Project X\n" +
+		"- [x] Ship X @done(" + oldDate + ")\n" +
+		"  - [x] Write docs @done(" + oldDate + ")\n" +
+		"```\n# Not a heading\n```\n" +
+		"- [x] Tag X release @done(" + oldDate + ")\n" +
+		"# Inbox\n" +
+		"- [x] Buy milk @done(" + oldDate + ")\n" +
+		"  - oat milk\n" +
+		"- [ ] Call mom\n"
+	if err := WriteFile(tasksFile, tasksContent, DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
 	}
 
-	// Verify tasks file no longer contains old task
-	remaining, err := LoadFile(tasksFile)
+	routes := map[string]string{"Project X": projectFile}
+	_, archived, err := ProcessAndArchiveRouted(tasksFile, archiveFile, routes, FixedDelay(2), DefaultOptions())
 	if err != nil {
-		t.Fatalf("LoadFile() tasks error: %v", err)
+		t.Fatalf("ProcessAndArchiveRouted() error: %v", err)
 	}
-	if containsString(remaining, "Old task") {
-		t.Error("Archive() should remove old task from tasks file")
+	if archived[projectFile] != 3 || archived[archiveFile] != 1 {
+		t.Errorf("archived = %v, want 3 in project file and 1 in archive", archived)
 	}
-	if !containsString(remaining, "Recent task") {
-		t.Error("Archive() should keep recent task in tasks file")
+
+	project, _ := LoadFile(projectFile, DefaultOptions())
+	for _, want := range []string{"Ship X", "Write docs", "Tag X release"} {
+		if !strings.Contains(project, want) {
+			t.Errorf("project file missing %q:\n%s", want, project)
+		}
 	}
-	if !containsString(remaining, "Incomplete task") {
-		t.Error("Archive() should keep incomplete task in tasks file")
+	if strings.Contains(project, "Buy milk") {
+		t.Errorf("project file should not contain Inbox tasks:\n%s", project)
 	}
 
-	// Verify archive file contains old task
-	archived, err := LoadFile(archiveFile)
-	if err != nil {
-		t.Fatalf("LoadFile() archive error: %v", err)
-	}
-	if !containsString(archived, "Old task") {
-		t.Error("Archive() should add old task to archive file")
+	archive, _ := LoadFile(archiveFile, DefaultOptions())
+	if !strings.Contains(archive, "Buy milk") || strings.Contains(archive, "Ship X") {
+		t.Errorf("archive file = %q, want only the Inbox task", archive)
 	}
-	if !containsString(archived, "## "+oldDate) {
-		t.Error("Archive() should include date header in archive")
+
+	remaining, _ := LoadFile(tasksFile, DefaultOptions())
+	if want := "# Project X\n```\n# Not a heading\n```\n# Inbox\n- [ ] Call mom\n"; remaining != want {
+		t.Errorf("tasks file = %q, want %q", remaining, want)
 	}
 }
 
-// TestArchiveNoTasks verifies Archive() behavior when there are no tasks to archive.
-// It should return 0 count and not modify files unnecessarily.
-func TestArchiveNoTasks(t *testing.T) {
+// TestProcessAndArchiveRoutedRollback verifies that a failure to replace the tasks file
+// restores every routed archive file.
+func TestProcessAndArchiveRoutedRollback(t *testing.T) {
 	tmpDir := t.TempDir()
 	tasksFile := tmpDir + "/tasks.md"
 	archiveFile := tmpDir + "/archive.md"
+	projectFile := tmpDir + "/project-x.md"
 
-	tasksContent := "- [ ] Incomplete task\n- [x] Recent task @done(" + time.Now().Format("2006-01-02") + ")\n"
-	if err := WriteFile(tasksFile, tasksContent); err != nil {
+	oldDate := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+	tasksContent := "# Project X\n- [x] Ship X @done(" + oldDate + ")\n# Inbox\n- [x] Buy milk @done(" + oldDate + ")\n"
+	archiveContent := "## 2026-01-01\n\n- [x] Ancient @done(2026-01-01)\n\n"
+	if err := WriteFile(tasksFile, tasksContent, DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() setup error: %v", err)
+	}
+	if err := WriteFile(archiveFile, archiveContent, DefaultOptions()); err != nil {
 		t.Fatalf("WriteFile() setup error: %v", err)
 	}
 
-	count, err := Archive(tasksFile, archiveFile, 2)
-	if err != nil {
-		t.Fatalf("Archive() error: %v", err)
+	defer func(orig func(string, string) error) { rename = orig }(rename)
+	rename = func(from, to string) error {
+		if to == tasksFile {
+			return os.ErrPermission
+		}
+		return os.Rename(from, to)
 	}
 
-	if count != 0 {
-		t.Errorf("Archive() count = %d, want 0", count)
+	routes := map[string]string{"Project X": projectFile}
+	if _, _, err := ProcessAndArchiveRouted(tasksFile, archiveFile, routes, FixedDelay(2), DefaultOptions()); err == nil {
+		t.Fatal("ProcessAndArchiveRouted() should return error when tasks file cannot be written")
+	}
+
+	if got, _ := LoadFile(tasksFile, DefaultOptions()); got != tasksContent {
+		t.Errorf("tasks file = %q, want unchanged", got)
+	}
+	if got, _ := LoadFile(archiveFile, DefaultOptions()); got != archiveContent {
+		t.Errorf("archive file = %q, want restored %q", got, archiveContent)
+	}
+	if _, err := os.Stat(projectFile); !os.IsNotExist(err) {
+		t.Errorf("project file should be removed again, stat error: %v", err)
 	}
 }
 
@@ -500,7 +2648,7 @@ func TestIsTask(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := IsTask(tt.line)
+			result := IsTask(tt.line, DefaultOptions())
 			if result != tt.expected {
 				t.Errorf("IsTask(%q) = %v, want %v", tt.line, result, tt.expected)
 			}
@@ -517,7 +2665,7 @@ func TestParseLines(t *testing.T) {
 - [x] Task 2
 Some text`
 
-	lines := ParseLines(content)
+	lines := ParseLines(content, DefaultOptions())
 
 	if len(lines) != 5 {
 		t.Fatalf("ParseLines() returned %d lines, want 5", len(lines))
@@ -549,6 +2697,197 @@ Some text`
 	}
 }
 
+// TestParseLinesSkipsCodeAndQuotes verifies that task-like lines inside fenced code blocks
+// and block quotes are not tasks, and that fences are tracked until they close.
+func TestParseLinesSkipsCodeAndQuotes(t *testing.T) {
+	content := "- [ ] Real 1\n" +
+		"```markdown\n" +
+		"- [ ] Fake in code\n" +
+		"~~~\n" +
+		"- [x] Still code\n" +
+		"```\n" +
+		"- [ ] Real 2\n" +
+		"> - [ ] Fake in quote\n" +
+		"  ~~~~\n" +
+		"  - [ ] Fake indented code\n" +
+		"  ~~~\n" +
+		"  - [ ] Still code (short fence does not close)\n" +
+		"  ~~~~\n" +
+		"- [x] Real 3"
+
+	tests := []struct {
+		line       int
+		wantTask   bool
+		wantInCode bool
+	}{
+		{0, true, false},
+		{1, false, true},
+		{2, false, true},
+		{3, false, true},
+		{4, false, true},
+		{5, false, true},
+		{6, true, false},
+		{7, false, false},
+		{8, false, true},
+		{9, false, true},
+		{10, false, true},
+		{11, false, true},
+		{12, false, true},
+		{13, true, false},
+	}
+
+	lines := ParseLines(content, DefaultOptions())
+	for _, tt := range tests {
+		got := lines[tt.line]
+		if got.IsTask != tt.wantTask || got.InCodeBlock != tt.wantInCode {
+			t.Errorf("line %d %q: IsTask=%v InCodeBlock=%v, want %v %v",
+				tt.line, got.Content, got.IsTask, got.InCodeBlock, tt.wantTask, tt.wantInCode)
+		}
+		if !got.IsTask && got.IsCompleted {
+			t.Errorf("line %d: IsCompleted set on a non-task line", tt.line)
+		}
+	}
+}
+
+// frontMatterTasks is a tasks.md with Obsidian-style front matter whose YAML
+// holds a task-like list item and heading-like comments.
+const frontMatterTasks = "---\n" +
+	"tags: [tasks]\n" +
+	"## Work\n" +
+	"checklist:\n" +
+	"  - [x] not a task\n" +
+	"---\n" +
+	"## Work\n" +
+	"- [x] Ship release @done(2020-01-01)\n" +
+	"- [x] Review PR\n" +
+	"- [ ] Plan sprint\n"
+
+// TestParseLinesFrontMatter verifies that only a closed block at the very top is front matter.
+func TestParseLinesFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int // number of FrontMatter lines
+	}{
+		{"front matter", frontMatterTasks, 6},
+		{"dots close the block", "---\ntitle: x\n...\n- [ ] Task", 3},
+		{"CRLF", "---\r\ntitle: x\r\n---\r\n- [ ] Task", 3},
+		{"unclosed", "---\n- [ ] Task\n", 0},
+		{"not on the first line", "# Tasks\n---\ntitle: x\n---\n", 0},
+		{"none", "- [ ] Task\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := 0
+			for i, line := range ParseLines(tt.content, DefaultOptions()) {
+				if line.FrontMatter {
+					got++
+					if i >= tt.want {
+						t.Errorf("line %d %q marked FrontMatter", i, line.Content)
+					}
+				}
+				if line.FrontMatter && line.IsTask {
+					t.Errorf("line %d %q: front matter line is a task", i, line.Content)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("FrontMatter lines = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSplitFrontMatter verifies the split keeps every byte on one side or the other.
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		frontMatter string
+	}{
+		{"front matter", "---\ntitle: x\n---\n- [ ] Task\n", "---\ntitle: x\n---\n"},
+		{"front matter only, no newline", "---\ntitle: x\n---", "---\ntitle: x\n---"},
+		{"none", "- [ ] Task\n", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frontMatter, body := SplitFrontMatter(tt.content)
+			if frontMatter != tt.frontMatter || frontMatter+body != tt.content {
+				t.Errorf("SplitFrontMatter() = (%q, %q), want front matter %q", frontMatter, body, tt.frontMatter)
+			}
+		})
+	}
+}
+
+// TestFrontMatterPreserved verifies that functions rewriting tasks.md keep the front
+// matter byte-for-byte at the top and never put tasks or titles inside it.
+func TestFrontMatterPreserved(t *testing.T) {
+	frontMatter, _ := SplitFrontMatter(frontMatterTasks)
+
+	processed, count := ProcessContent(frontMatterTasks, DefaultOptions())
+	if count != 1 {
+		t.Errorf("ProcessContent() count = %d, want 1", count)
+	}
+	if !strings.HasPrefix(processed, frontMatter) {
+		t.Errorf("ProcessContent() changed the front matter:\n%s", processed)
+	}
+
+	archivable, remaining := FilterArchivable(frontMatterTasks, FixedDelay(2), DefaultOptions())
+	if len(archivable) != 1 || archivable[0].Heading != "Work" {
+		t.Errorf("FilterArchivable() archived %+v, want the shipped task under Work", archivable)
+	}
+	if !strings.HasPrefix(remaining, frontMatter+"## Work\n- [x] Review PR\n") {
+		t.Errorf("FilterArchivable() remaining =\n%s", remaining)
+	}
+
+	inserted, _ := InsertUnderHeading(frontMatterTasks, "Work", "- [ ] New", DefaultOptions())
+	if !strings.HasPrefix(inserted, frontMatter+"## Work\n") || !strings.HasSuffix(inserted, "- [ ] Plan sprint\n- [ ] New\n") {
+		t.Errorf("InsertUnderHeading() =\n%s", inserted)
+	}
+
+	heading, _, matched := RouteByPrefix("---\n## Home\n---\n- [ ] Task\n", "home: call mom")
+	if matched {
+		t.Errorf("RouteByPrefixWith() matched %q inside front matter", heading)
+	}
+	onlyFrontMatter := "---\ntitle: x\n---"
+	if got, _ := InsertUnderHeading(onlyFrontMatter, "", "- [ ] New", DefaultOptions()); got != onlyFrontMatter+"\n- [ ] New\n" {
+		t.Errorf("InsertUnderHeading() on front matter only = %q", got)
+	}
+
+	titled := EnsureTitle("---\n# comment\n---\n- [ ] Task\n", "# Tasks")
+	if want := "---\n# comment\n---\n# Tasks\n\n- [ ] Task\n"; titled != want {
+		t.Errorf("EnsureTitle() = %q, want %q", titled, want)
+	}
+	if got := EnsureTitle(onlyFrontMatter, "# Tasks"); got != onlyFrontMatter+"\n# Tasks\n\n" {
+		t.Errorf("EnsureTitle() on front matter only = %q", got)
+	}
+}
+
+// TestProcessContentIgnoresCodeBlocks verifies that @done tagging and archiving leave
+// task-like lines in code blocks and quotes untouched.
+func TestProcessContentIgnoresCodeBlocks(t *testing.T) {
+	content := "- [x] Real\n```\n- [x] Example\n```\n> - [x] Quoted\n"
+
+	processed, count := ProcessContent(content, DefaultOptions())
+	if count != 1 {
+		t.Errorf("ProcessContent() count = %d, want 1", count)
+	}
+	if !strings.Contains(processed, "\n- [x] Example\n") || !strings.Contains(processed, "\n> - [x] Quoted\n") {
+		t.Errorf("ProcessContent() changed code or quote lines:\n%s", processed)
+	}
+
+	old := "- [x] Real @done(2020-01-01)\n```\n- [x] Example @done(2020-01-01)\n```\n"
+	archivable, remaining := FilterArchivable(old, FixedDelay(2), DefaultOptions())
+	if len(archivable) != 1 || archivable[0].Content != "- [x] Real @done(2020-01-01)" {
+		t.Errorf("FilterArchivable() archived %+v, want only the real task", archivable)
+	}
+	if !strings.Contains(remaining, "- [x] Example @done(2020-01-01)") {
+		t.Errorf("remaining = %q, want the code block kept", remaining)
+	}
+}
+
 // TestBuildTaskTrees verifies tree construction from parsed lines.
 // Children should be correctly associated with parents based on indentation.
 func TestBuildTaskTrees(t *testing.T) {
@@ -559,7 +2898,7 @@ func TestBuildTaskTrees(t *testing.T) {
 - [ ] Parent 2
   - [ ] Child 2.1`
 
-	lines := ParseLines(content)
+	lines := ParseLines(content, DefaultOptions())
 	trees := BuildTaskTrees(lines)
 
 	// Should have 2 top-level trees
@@ -589,7 +2928,7 @@ func TestBuildTaskTreesWithNonTaskLines(t *testing.T) {
 Some note
   - [ ] Child`
 
-	lines := ParseLines(content)
+	lines := ParseLines(content, DefaultOptions())
 	trees := BuildTaskTrees(lines)
 
 	// Should have 1 top-level tree with 1 child
@@ -615,8 +2954,8 @@ func TestCascadeCompletion(t *testing.T) {
   - [ ] Child 1
   - [ ] Child 2`
 
-	lines := ParseLines(input)
-	result, count := CascadeCompletion(lines, today)
+	lines := ParseLines(input, DefaultOptions())
+	result, count := CascadeCompletion(lines, today, DefaultOptions())
 
 	// Should have cascaded to 2 children
 	if count != 2 {
@@ -649,8 +2988,8 @@ func TestCascadeCompletionDeepNesting(t *testing.T) {
   - [ ] Parent
     - [ ] Child`
 
-	lines := ParseLines(input)
-	result, count := CascadeCompletion(lines, today)
+	lines := ParseLines(input, DefaultOptions())
+	result, count := CascadeCompletion(lines, today, DefaultOptions())
 
 	// Should cascade to parent and child
 	if count != 2 {
@@ -670,8 +3009,8 @@ func TestCascadeCompletionIncompleteParent(t *testing.T) {
 	input := `- [ ] Parent task
   - [ ] Child 1`
 
-	lines := ParseLines(input)
-	_, count := CascadeCompletion(lines, today)
+	lines := ParseLines(input, DefaultOptions())
+	_, count := CascadeCompletion(lines, today, DefaultOptions())
 
 	// Should not cascade anything
 	if count != 0 {
@@ -686,8 +3025,8 @@ func TestCascadeCompletionAlreadyCompleted(t *testing.T) {
 	input := `- [x] Parent task
   - [x] Already done @done(2026-01-15)`
 
-	lines := ParseLines(input)
-	result, count := CascadeCompletion(lines, today)
+	lines := ParseLines(input, DefaultOptions())
+	result, count := CascadeCompletion(lines, today, DefaultOptions())
 
 	// Should not modify already completed child
 	if count != 0 {
@@ -700,13 +3039,118 @@ func TestCascadeCompletionAlreadyCompleted(t *testing.T) {
 	}
 }
 
+// TestCascadeRespectManual verifies that a subtask reopened under a parent completed
+// on an earlier day stays open, unless tasks.cascade_respect_manual is off.
+func TestCascadeRespectManual(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+
+	tests := []struct {
+		name      string
+		respect   bool
+		input     string
+		wantCount int
+	}{
+		{
+			name:      "reopened child stays open",
+			respect:   true,
+			input:     "- [x] Parent @done(2026-01-10)\n  - [ ] Child",
+			wantCount: 0,
+		},
+		{
+			name:      "reopened child and its subtasks stay open",
+			respect:   true,
+			input:     "- [x] Parent @done(2026-01-10)\n  - [ ] Child\n    - [ ] Grandchild",
+			wantCount: 0,
+		},
+		{
+			name:      "parent completed today cascades",
+			respect:   true,
+			input:     "- [x] Parent @done(" + today + ")\n  - [ ] Child",
+			wantCount: 1,
+		},
+		{
+			name:      "newly checked parent cascades",
+			respect:   true,
+			input:     "- [x] Parent\n  - [ ] Child\n    - [ ] Grandchild",
+			wantCount: 2,
+		},
+		{
+			name:      "off: earlier parent cascades",
+			respect:   false,
+			input:     "- [x] Parent @done(2026-01-10)\n  - [ ] Child",
+			wantCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.CascadeIgnoreManual = !tt.respect
+
+			_, count := CascadeCompletion(ParseLines(tt.input, opts), today, opts)
+			if count != tt.wantCount {
+				t.Errorf("CascadeCompletion() count = %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
+
+	t.Run("stays open across passes", func(t *testing.T) {
+		content := "- [x] Parent @done(2026-01-10)\n  - [ ] Child\n"
+		for i := 0; i < 2; i++ {
+			result, _ := ProcessContent(content, DefaultOptions())
+			if !strings.Contains(result, "  - [ ] Child") {
+				t.Fatalf("pass %d: child was completed again:\n%s", i+1, result)
+			}
+			content = result
+		}
+	})
+}
+
+// TestDoneTagPlacementConsistent verifies that cascaded children and directly
+// completed tasks get @done placed identically: at the very end of the line,
+// after trimming trailing whitespace and after any other tags.
+func TestDoneTagPlacementConsistent(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			"completed task with trailing spaces",
+			"- [x] Task  ",
+			"- [x] Task @done(" + today + ")",
+		},
+		{
+			"cascaded child with trailing spaces",
+			"- [x] Parent @done(" + today + ")\n  - [ ] Child  ",
+			"- [x] Parent @done(" + today + ")\n  - [x] Child @done(" + today + ")",
+		},
+		{
+			"cascaded child with due tag",
+			"- [x] Parent @done(" + today + ")\n  - [ ] Child @due(2026-02-01)\t",
+			"- [x] Parent @done(" + today + ")\n  - [x] Child @due(2026-02-01) @done(" + today + ")",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := ProcessContent(tt.content, DefaultOptions())
+			if result != tt.expected {
+				t.Errorf("ProcessContent() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
 // TestReconstructContent verifies content reconstruction from ParsedLines.
 func TestReconstructContent(t *testing.T) {
 	input := `# Header
 - [ ] Task 1
   - [x] Subtask`
 
-	lines := ParseLines(input)
+	lines := ParseLines(input, DefaultOptions())
 	result := ReconstructContent(lines)
 
 	if result != input {
@@ -723,7 +3167,7 @@ func TestProcessContentWithHierarchy(t *testing.T) {
   - [ ] Child 2
 - [ ] Other task`
 
-	result, count := ProcessContent(input)
+	result, count := ProcessContent(input, DefaultOptions())
 
 	// Should have modified: parent (@done) + 2 children (cascade)
 	if count != 3 {
@@ -766,7 +3210,7 @@ func TestFilterArchivableWithHierarchy(t *testing.T) {
   - [x] Recent child @done(` + recentDate + `)
 - [ ] Incomplete task`
 
-	archivableTasks, remaining := FilterArchivable(content, 2)
+	archivableTasks, remaining := FilterArchivable(content, FixedDelay(2), DefaultOptions())
 	archivable := archiveTasksToString(archivableTasks)
 
 	// Old parent and child should be archived together
@@ -791,6 +3235,230 @@ func TestFilterArchivableWithHierarchy(t *testing.T) {
 	}
 }
 
+// TestFilterArchivableWhenChildrenDone verifies that with Options.ArchiveWhenChildrenDone
+// an open parent is archived once every descendant is done, dated by the newest child,
+// while an open parent with an open descendant stays.
+func TestFilterArchivableWhenChildrenDone(t *testing.T) {
+	now := time.Now()
+	older := now.AddDate(0, 0, -9).Format("2006-01-02")
+	old := now.AddDate(0, 0, -5).Format("2006-01-02")
+	recent := now.AddDate(0, 0, -1).Format("2006-01-02")
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		content   string
+		archived  bool
+		groupDate string
+	}{
+		{
+			name:      "done parent archives as before",
+			enabled:   true,
+			content:   "- [x] Parent @done(" + old + ")\n  - [ ] Child",
+			archived:  true,
+			groupDate: old,
+		},
+		{
+			name:      "open parent with all children done",
+			enabled:   true,
+			content:   "- [ ] Parent\n  - [x] A @done(" + older + ")\n  - [x] B @done(" + old + ")",
+			archived:  true,
+			groupDate: old,
+		},
+		{
+			name:    "open parent with some children done",
+			enabled: true,
+			content: "- [ ] Parent\n  - [x] A @done(" + old + ")\n  - [ ] B",
+		},
+		{
+			name:    "open grandchild keeps parent",
+			enabled: true,
+			content: "- [ ] Parent\n  - [x] A @done(" + old + ")\n    - [ ] A1",
+		},
+		{
+			name:    "newest child too recent",
+			enabled: true,
+			content: "- [ ] Parent\n  - [x] A @done(" + old + ")\n  - [x] B @done(" + recent + ")",
+		},
+		{
+			name:    "open task without children",
+			enabled: true,
+			content: "- [ ] Parent",
+		},
+		{
+			name:    "disabled",
+			enabled: false,
+			content: "- [ ] Parent\n  - [x] A @done(" + old + ")",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.ArchiveWhenChildrenDone = tt.enabled
+
+			archivable, remaining := FilterArchivable(tt.content, FixedDelay(2), opts)
+			if !tt.archived {
+				if len(archivable) != 0 || remaining != tt.content {
+					t.Errorf("archivable = %v, remaining = %q; want nothing archived", archivable, remaining)
+				}
+				return
+			}
+			if len(archivable) != strings.Count(tt.content, "\n")+1 || strings.TrimSpace(remaining) != "" {
+				t.Fatalf("archivable = %v, remaining = %q; want the whole tree archived", archivable, remaining)
+			}
+			for _, a := range archivable {
+				if got := a.GroupDate.Format("2006-01-02"); got != tt.groupDate {
+					t.Errorf("%q GroupDate = %s, want %s", a.Content, got, tt.groupDate)
+				}
+			}
+		})
+	}
+}
+
+// TestDoneDateNotRequired verifies that with Options.DoneDateOptional nothing adds @done,
+// and that a completed task without one counts as done on the day the file was last
+// modified, filed under the day it is archived.
+func TestDoneDateNotRequired(t *testing.T) {
+	opts := DefaultOptions()
+	opts.DoneDateOptional = true
+
+	if got, _ := ProcessContent("- [x] Parent\n  - [ ] Child", opts); got != "- [x] Parent\n  - [x] Child" {
+		t.Errorf("ProcessContent() = %q, want the cascade without @done", got)
+	}
+	if got, changed := AddDoneTag("- [x] Task", opts); changed || got != "- [x] Task" {
+		t.Errorf("AddDoneTag() = %q, %v; want the line unchanged", got, changed)
+	}
+
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	old := now.AddDate(0, 0, -5).Format("2006-01-02")
+	tests := []struct {
+		name      string
+		content   string
+		modified  time.Time
+		delay     int
+		archived  int    // archivable lines
+		groupDate string // of the first archivable line
+	}{
+		{"undated, no delay", "- [x] Task\n- [ ] Open", now, 0, 1, today},
+		{"undated, file changed within delay", "- [x] Task", now.AddDate(0, 0, -1), 2, 0, ""},
+		{"undated, file unchanged past delay", "- [x] Task\n  note", now.AddDate(0, 0, -3), 2, 2, today},
+		{"undated parent takes its children", "- [x] Parent\n  - [ ] Child", now, 0, 2, today},
+		{"dated task keeps its date", "- [x] Task @done(" + old + ")", now, 2, 1, old},
+		{"open task stays", "- [ ] Task", now.AddDate(0, 0, -30), 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archivable, _ := filterArchivable(tt.content, FixedDelay(tt.delay), tt.modified, opts)
+			if len(archivable) != tt.archived {
+				t.Fatalf("archivable = %v, want %d line(s)", archivable, tt.archived)
+			}
+			if tt.archived > 0 {
+				if got := archivable[0].GroupDate.Format("2006-01-02"); got != tt.groupDate {
+					t.Errorf("GroupDate = %s, want %s", got, tt.groupDate)
+				}
+			}
+		})
+	}
+
+	// ProcessAndArchiveRouted goes by the modification time of tasks.md
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	archivePath := filepath.Join(dir, "archive.md")
+	if err := os.WriteFile(tasksPath, []byte("- [x] Task\n- [ ] Open\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if _, archived, err := ProcessAndArchiveRouted(tasksPath, archivePath, nil, FixedDelay(2), opts); err != nil || archived[archivePath] != 0 {
+		t.Fatalf("ProcessAndArchiveRouted() of a fresh file = %v, %v; want nothing archived", archived, err)
+	}
+	lastWeek := now.AddDate(0, 0, -7)
+	if err := os.Chtimes(tasksPath, lastWeek, lastWeek); err != nil {
+		t.Fatalf("Chtimes() error: %v", err)
+	}
+	if _, archived, err := ProcessAndArchiveRouted(tasksPath, archivePath, nil, FixedDelay(2), opts); err != nil || archived[archivePath] != 1 {
+		t.Fatalf("ProcessAndArchiveRouted() of a week-old file = %v, %v; want the task archived", archived, err)
+	}
+	if got, _ := os.ReadFile(archivePath); string(got) != "## "+today+"\n\n- [x] Task\n\n" {
+		t.Errorf("archive.md = %q, want the task under today", got)
+	}
+
+	if archivable, _ := filterArchivable("- [x] Task", FixedDelay(0), now.AddDate(0, 0, -30), DefaultOptions()); len(archivable) != 0 {
+		t.Errorf("with @done dates required, an undated task was archived: %v", archivable)
+	}
+}
+
+// TestFilterArchivableContinuationLines verifies that lines continuing a wrapped task
+// are archived with it as one block: deeper-indented lines and unindented text lines up
+// to the first blank line, list item, heading, quote, or code fence.
+func TestFilterArchivableContinuationLines(t *testing.T) {
+	oldDate := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+	done := " @done(" + oldDate + ")"
+
+	tests := []struct {
+		name          string
+		content       string
+		wantArchived  string
+		wantRemaining string
+	}{
+		{
+			name:          "indented continuation",
+			content:       "- [x] Write the report" + done + "\n  for the finance team\n- [ ] Open",
+			wantArchived:  "- [x] Write the report" + done + "\n  for the finance team",
+			wantRemaining: "- [ ] Open",
+		},
+		{
+			name:          "unindented continuation",
+			content:       "- [x] Write the report" + done + "\nfor the finance team\nand legal\n- [ ] Open",
+			wantArchived:  "- [x] Write the report" + done + "\nfor the finance team\nand legal",
+			wantRemaining: "- [ ] Open",
+		},
+		{
+			name:          "continuation of a subtask",
+			content:       "- [x] Parent" + done + "\n  - [x] Child\n  wrapped child text\n- [ ] Open",
+			wantArchived:  "- [x] Parent" + done + "\n  - [x] Child\n  wrapped child text",
+			wantRemaining: "- [ ] Open",
+		},
+		{
+			name:          "blank line ends the block",
+			content:       "- [x] Done" + done + "\n\nA paragraph",
+			wantArchived:  "- [x] Done" + done,
+			wantRemaining: "\nA paragraph",
+		},
+		{
+			name:          "list item ends the block",
+			content:       "- [x] Done" + done + "\n- a plain bullet\n1. a numbered item",
+			wantArchived:  "- [x] Done" + done,
+			wantRemaining: "- a plain bullet\n1. a numbered item",
+		},
+		{
+			name:          "heading, quote, and fence end the block",
+			content:       "- [x] A" + done + "\n## Next\n- [x] B" + done + "\n> quote\n- [x] C" + done + "\n```\ncode\n```",
+			wantArchived:  "- [x] A" + done + "\n- [x] B" + done + "\n- [x] C" + done,
+			wantRemaining: "## Next\n> quote\n```\ncode\n```",
+		},
+		{
+			name:          "open task keeps its continuation",
+			content:       "- [ ] Write the report\nfor the finance team",
+			wantArchived:  "",
+			wantRemaining: "- [ ] Write the report\nfor the finance team",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archivable, remaining := FilterArchivable(tt.content, FixedDelay(2), DefaultOptions())
+			if got := archiveTasksToString(archivable); got != tt.wantArchived {
+				t.Errorf("archived = %q, want %q", got, tt.wantArchived)
+			}
+			if remaining != tt.wantRemaining {
+				t.Errorf("remaining = %q, want %q", remaining, tt.wantRemaining)
+			}
+		})
+	}
+}
+
 // TestFilterArchivablePreservesIndentation verifies archived tasks keep their indentation.
 func TestFilterArchivablePreservesIndentation(t *testing.T) {
 	now := time.Now()
@@ -799,7 +3467,7 @@ func TestFilterArchivablePreservesIndentation(t *testing.T) {
 	content := `- [x] Parent @done(` + oldDate + `)
   - [x] Child @done(` + oldDate + `)`
 
-	archivableTasks, _ := FilterArchivable(content, 2)
+	archivableTasks, _ := FilterArchivable(content, FixedDelay(2), DefaultOptions())
 	archivable := archiveTasksToString(archivableTasks)
 
 	// Indentation should be preserved
@@ -817,7 +3485,7 @@ func TestFilterArchivableDeepNesting(t *testing.T) {
   - [x] Parent @done(` + oldDate + `)
     - [x] Child @done(` + oldDate + `)`
 
-	archivableTasks, remaining := FilterArchivable(content, 2)
+	archivableTasks, remaining := FilterArchivable(content, FixedDelay(2), DefaultOptions())
 	archivable := archiveTasksToString(archivableTasks)
 
 	// All three should be archived
@@ -849,25 +3517,26 @@ func TestArchiveWithHierarchy(t *testing.T) {
 
 	tasksContent := `- [x] Old parent @done(` + oldDate + `)
   - [x] Old child @done(` + oldDate + `)
+  - Old note
 - [ ] Incomplete task
 `
 
-	if err := WriteFile(tasksFile, tasksContent); err != nil {
+	if err := WriteFile(tasksFile, tasksContent, DefaultOptions()); err != nil {
 		t.Fatalf("WriteFile() setup error: %v", err)
 	}
 
-	count, err := Archive(tasksFile, archiveFile, 2)
+	count, lines, err := Archive(tasksFile, archiveFile, 2, DefaultOptions())
 	if err != nil {
 		t.Fatalf("Archive() error: %v", err)
 	}
 
-	// Should have archived 2 tasks (parent + child)
-	if count != 2 {
-		t.Errorf("Archive() count = %d, want 2", count)
+	// Should have archived 2 tasks (parent + child); the note moves too but is not a task
+	if count != 2 || lines != 3 {
+		t.Errorf("Archive() = (%d tasks, %d lines), want (2, 3)", count, lines)
 	}
 
 	// Verify tasks file
-	remaining, _ := LoadFile(tasksFile)
+	remaining, _ := LoadFile(tasksFile, DefaultOptions())
 	if containsString(remaining, "Old parent") || containsString(remaining, "Old child") {
 		t.Error("Old tasks should be removed from tasks file")
 	}
@@ -876,7 +3545,7 @@ func TestArchiveWithHierarchy(t *testing.T) {
 	}
 
 	// Verify archive file
-	archived, _ := LoadFile(archiveFile)
+	archived, _ := LoadFile(archiveFile, DefaultOptions())
 	if !containsString(archived, "Old parent") {
 		t.Error("Old parent should be in archive")
 	}
@@ -895,7 +3564,7 @@ func TestChildNotArchivedWhenParentIncomplete(t *testing.T) {
 	content := `- [ ] Incomplete parent
   - [x] Old child @done(` + oldDate + `)`
 
-	archivableTasks, remaining := FilterArchivable(content, 2)
+	archivableTasks, remaining := FilterArchivable(content, FixedDelay(2), DefaultOptions())
 	archivable := archiveTasksToString(archivableTasks)
 
 	// Child should NOT be archived because parent is incomplete
@@ -923,7 +3592,7 @@ func TestChildNotArchivedWhenParentNotOldEnough(t *testing.T) {
 	content := `- [x] Recent parent @done(` + recentDate + `)
   - [x] Old child @done(` + oldDate + `)`
 
-	archivableTasks, remaining := FilterArchivable(content, 2)
+	archivableTasks, remaining := FilterArchivable(content, FixedDelay(2), DefaultOptions())
 	archivable := archiveTasksToString(archivableTasks)
 
 	// Neither should be archived - parent is too recent
@@ -943,6 +3612,26 @@ func TestChildNotArchivedWhenParentNotOldEnough(t *testing.T) {
 	}
 }
 
+// TestFormatArchiveEntryKeepsBlocks verifies that non-task lines stay in the section of
+// the task above them, even when their own GroupDate differs.
+func TestFormatArchiveEntryKeepsBlocks(t *testing.T) {
+	date18, _ := time.Parse("2006-01-02", "2026-01-18")
+	date17, _ := time.Parse("2006-01-02", "2026-01-17")
+
+	tasks := []ArchiveTask{
+		{Content: "- [x] Task A @done(2026-01-18)", GroupDate: date18, IsTask: true},
+		{Content: "wrapped A text"},
+		{Content: "- [x] Task B @done(2026-01-17)", GroupDate: date17, IsTask: true},
+		{Content: "  note on B", GroupDate: date18},
+	}
+
+	want := "## 2026-01-18\n\n- [x] Task A @done(2026-01-18)\nwrapped A text\n\n" +
+		"## 2026-01-17\n\n- [x] Task B @done(2026-01-17)\n  note on B\n\n"
+	if got := FormatArchiveEntry(tasks, DefaultOptions()); got != want {
+		t.Errorf("FormatArchiveEntry() = %q, want %q", got, want)
+	}
+}
+
 // TestFormatArchiveEntryUsesParentDate verifies that child tasks are grouped
 // under parent's date in archive, not their own @done date.
 // Spec: Archive sections use parent task's completion date for grouping.
@@ -955,7 +3644,7 @@ func TestFormatArchiveEntryUsesParentDate(t *testing.T) {
 		{Content: "  - [x] Child @done(" + childDate + ")", GroupDate: parentDate}, // Uses parent's date!
 	}
 
-	result := FormatArchiveEntry(tasks)
+	result := FormatArchiveEntry(tasks, DefaultOptions())
 
 	// Both should be under parent's date section
 	if !containsString(result, "## 2026-01-18") {
@@ -985,7 +3674,7 @@ func TestChildDoneTagPreserved(t *testing.T) {
 		{Content: "  - [x] Child @done(" + childDateStr + ")", GroupDate: parentDate},
 	}
 
-	result := FormatArchiveEntry(tasks)
+	result := FormatArchiveEntry(tasks, DefaultOptions())
 
 	// Child's original @done tag should be preserved
 	if !containsString(result, "@done("+childDateStr+")") {
@@ -1004,7 +3693,7 @@ func TestNonTaskChildArchivedWithParent(t *testing.T) {
   - Note line without checkbox
   - Another note`
 
-	archivableTasks, remaining := FilterArchivable(content, 2)
+	archivableTasks, remaining := FilterArchivable(content, FixedDelay(2), DefaultOptions())
 	archivable := archiveTasksToString(archivableTasks)
 
 	// Parent should be archived
@@ -1012,6 +3701,11 @@ func TestNonTaskChildArchivedWithParent(t *testing.T) {
 		t.Error("Old parent should be archivable")
 	}
 
+	// Only the parent counts as a task
+	if got := CountArchiveTasks(archivableTasks); got != 1 || len(archivableTasks) != 3 {
+		t.Errorf("CountArchiveTasks() = %d of %d lines, want 1 of 3", got, len(archivableTasks))
+	}
+
 	// Non-task children should be archived with parent
 	if !containsString(archivable, "Note line without checkbox") {
 		t.Error("Non-task child should be archived with parent")
@@ -1034,7 +3728,7 @@ func TestNonTaskChildNotArchivedWhenParentIncomplete(t *testing.T) {
 	content := `- [ ] Incomplete parent
   - Note line without checkbox`
 
-	archivableTasks, remaining := FilterArchivable(content, 2)
+	archivableTasks, remaining := FilterArchivable(content, FixedDelay(2), DefaultOptions())
 	archivable := archiveTasksToString(archivableTasks)
 
 	// Nothing should be archived
@@ -1062,11 +3756,11 @@ func TestProcessFileWithDoneTags(t *testing.T) {
 	testFile := tmpDir + "/tasks.md"
 
 	content := "- [ ] Incomplete\n- [x] Completed without done\n- [x] Has done @done(2026-01-15)\n"
-	if err := WriteFile(testFile, content); err != nil {
+	if err := WriteFile(testFile, content, DefaultOptions()); err != nil {
 		t.Fatalf("WriteFile() setup error: %v", err)
 	}
 
-	count, err := ProcessFileWithDoneTags(testFile)
+	count, err := ProcessFileWithDoneTags(testFile, DefaultOptions())
 	if err != nil {
 		t.Fatalf("ProcessFileWithDoneTags() error: %v", err)
 	}
@@ -1077,7 +3771,7 @@ func TestProcessFileWithDoneTags(t *testing.T) {
 	}
 
 	// Verify file was updated
-	result, err := LoadFile(testFile)
+	result, err := LoadFile(testFile, DefaultOptions())
 	if err != nil {
 		t.Fatalf("LoadFile() verification error: %v", err)
 	}
@@ -1090,3 +3784,30 @@ func TestProcessFileWithDoneTags(t *testing.T) {
 		t.Error("ProcessFileWithDoneTags() should preserve existing @done tags")
 	}
 }
+
+// TestCompleteTasks verifies that completed lines get @done(today) and cascade to
+// their subtasks, that completed or non-task lines are left alone, and that the rest
+// of the file (an untagged completed task, an overdue task) is not processed.
+func TestCompleteTasks(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	content := "# Tasks\n- [ ] a\n  - [ ] a1\n- [ ] b\n- [x] c @done(2026-01-01)\n- [x] d\n- [ ] e @due(2020-01-01)\n"
+
+	opts := DefaultOptions()
+	opts.EscalateOverdueDays = 1
+	got, completed := CompleteTasks(content, []int{1, 4, 0}, opts)
+	want := "# Tasks\n- [x] a @done(" + today + ")\n  - [x] a1 @done(" + today + ")\n- [ ] b\n- [x] c @done(2026-01-01)\n- [x] d\n- [ ] e @due(2020-01-01)\n"
+	if got != want {
+		t.Errorf("CompleteTasks() = %q, want %q", got, want)
+	}
+	if completed != 2 {
+		t.Errorf("CompleteTasks() completed = %d, want 2", completed)
+	}
+
+	// The front matter's cascade limits how far the subtasks are completed
+	content = "---\ncascade: direct\n---\n- [ ] a\n  - [ ] a1\n    - [ ] a2\n"
+	got, completed = CompleteTasks(content, []int{3}, opts)
+	want = "---\ncascade: direct\n---\n- [x] a @done(" + today + ")\n  - [x] a1 @done(" + today + ")\n    - [ ] a2\n"
+	if got != want || completed != 2 {
+		t.Errorf("CompleteTasks() with cascade: direct = %q, %d; want %q, 2", got, completed, want)
+	}
+}
@@ -0,0 +1,81 @@
+package task
+
+import "strings"
+
+// WriteTasksFile is WriteFile for a task file such as tasks.md: with opts.TidyOnWrite,
+// content is tidied first. Archive files are written with WriteFile as they are.
+func WriteTasksFile(path string, content string, opts Options) error {
+	if opts.TidyOnWrite {
+		content = TidyWith(content, opts)
+	}
+	return WriteFile(path, content, opts)
+}
+
+// Tidy is TidyWith with DefaultOptions.
+func Tidy(content string) string {
+	return TidyWith(content, DefaultOptions())
+}
+
+// TidyWith cleans up what archiving and moving tasks leave behind in a task file: runs
+// of blank lines become a single blank line, headings below the "# " title whose
+// section has nothing left in it (no line but blank lines and other such headings)
+// are removed, and blank lines at the end are dropped, keeping one final newline.
+// A single blank line is kept wherever it is, and lines in code blocks and the front
+// matter are never changed or removed.
+func TidyWith(content string, opts Options) string {
+	lines := ParseLines(content, opts)
+
+	var result []string
+	blank := false // the last line kept is a blank line outside code blocks
+	for i, line := range lines {
+		if line.InCodeBlock || line.FrontMatter {
+			result = append(result, line.Content)
+			blank = false
+			continue
+		}
+		if strings.TrimSpace(line.Content) == "" {
+			// A blank line left at the top by removed headings goes too
+			if !blank && (len(result) > 0 || i == 0) {
+				result = append(result, line.Content)
+			}
+			blank = true
+			continue
+		}
+		if emptyHeading(lines, i) {
+			continue
+		}
+		result = append(result, line.Content)
+		blank = false
+	}
+
+	// Blank lines at the end, and the trailing newline that ParseLines keeps as one
+	for len(result) > 0 && strings.TrimSpace(result[len(result)-1]) == "" && !lines[len(lines)-1].InCodeBlock {
+		result = result[:len(result)-1]
+	}
+	if len(result) > 0 && strings.HasSuffix(content, "\n") {
+		return strings.Join(result, "\n") + "\n"
+	}
+	return strings.Join(result, "\n")
+}
+
+// emptyHeading reports whether line i of lines is a "##" or deeper heading whose
+// section, up to the next heading of the same or a higher level, holds only blank
+// lines and deeper headings.
+func emptyHeading(lines []ParsedLine, i int) bool {
+	level, _, ok := headingLevel(lines[i])
+	if !ok || level < 2 {
+		return false
+	}
+	for _, line := range lines[i+1:] {
+		if next, _, ok := headingLevel(line); ok {
+			if next <= level {
+				return true
+			}
+			continue
+		}
+		if strings.TrimSpace(line.Content) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestTidy verifies that blank line runs collapse, emptied headings below the title
+// go, and trailing blank lines are dropped, leaving code blocks and front matter alone.
+func TestTidy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "tidy file unchanged",
+			content: "# Tasks\n\n## Work\n- [ ] a\n\n- [ ] b\n",
+			want:    "# Tasks\n\n## Work\n- [ ] a\n\n- [ ] b\n",
+		},
+		{
+			name:    "blank line runs collapse",
+			content: "- [ ] a\n\n\n\n- [ ] b\n",
+			want:    "- [ ] a\n\n- [ ] b\n",
+		},
+		{
+			name:    "trailing blank lines dropped",
+			content: "- [ ] a\n\n\n",
+			want:    "- [ ] a\n",
+		},
+		{
+			name:    "no trailing newline added",
+			content: "- [ ] a",
+			want:    "- [ ] a",
+		},
+		{
+			name:    "emptied heading removed",
+			content: "## Work\n- [ ] a\n\n## Errands\n\n## Home\n- [ ] b\n",
+			want:    "## Work\n- [ ] a\n\n## Home\n- [ ] b\n",
+		},
+		{
+			name:    "emptied heading at the end removed",
+			content: "## Work\n- [ ] a\n\n## Errands\n\n",
+			want:    "## Work\n- [ ] a\n",
+		},
+		{
+			name:    "emptied heading at the top removed",
+			content: "## Errands\n\n## Home\n- [ ] b\n",
+			want:    "## Home\n- [ ] b\n",
+		},
+		{
+			name:    "heading with only empty subsections removed",
+			content: "## Work\n### Later\n\n## Home\n- [ ] b\n",
+			want:    "## Home\n- [ ] b\n",
+		},
+		{
+			name:    "heading kept for a subsection with tasks",
+			content: "## Work\n### Later\n- [ ] a\n",
+			want:    "## Work\n### Later\n- [ ] a\n",
+		},
+		{
+			name:    "heading kept for notes",
+			content: "## Ideas\nsome notes\n",
+			want:    "## Ideas\nsome notes\n",
+		},
+		{
+			name:    "title kept without tasks",
+			content: "# Tasks\n\n",
+			want:    "# Tasks\n",
+		},
+		{
+			name:    "code block untouched",
+			content: "```\n## not a heading\n\n\n```\n\n\n- [ ] a\n",
+			want:    "```\n## not a heading\n\n\n```\n\n- [ ] a\n",
+		},
+		{
+			name:    "heading kept for a code block",
+			content: "## Snippets\n```\n\n```\n",
+			want:    "## Snippets\n```\n\n```\n",
+		},
+		{
+			name:    "front matter untouched",
+			content: "---\ncascade: direct\n---\n\n\n## Empty\n\n## Work\n- [ ] a\n",
+			want:    "---\ncascade: direct\n---\n\n## Work\n- [ ] a\n",
+		},
+		{name: "empty file", content: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Tidy(tt.content)
+			if got != tt.want {
+				t.Errorf("TidyWith() = %q, want %q", got, tt.want)
+			}
+			if again := Tidy(got); again != got {
+				t.Errorf("TidyWith() is not stable: %q, then %q", got, again)
+			}
+		})
+	}
+}
+
+// TestWriteTasksFile verifies that task files are tidied only with Options.TidyOnWrite.
+func TestWriteTasksFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.md")
+	content := "- [ ] a\n\n\n## Empty\n"
+
+	if err := WriteTasksFile(path, content, DefaultOptions()); err != nil {
+		t.Fatalf("WriteTasksFile() error: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != content {
+		t.Errorf("without tidy_on_write = %q, want %q", got, content)
+	}
+
+	opts := DefaultOptions()
+	opts.TidyOnWrite = true
+	if err := WriteTasksFile(path, content, opts); err != nil {
+		t.Fatalf("WriteTasksFile() error: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "- [ ] a\n" {
+		t.Errorf("with tidy_on_write = %q, want %q", got, "- [ ] a\n")
+	}
+}
+
+// TestProcessAndArchiveTidy verifies that archiving with Options.TidyOnWrite leaves no
+// emptied heading or blank line run behind in tasks.md.
+func TestProcessAndArchiveTidy(t *testing.T) {
+	opts := DefaultOptions()
+	opts.TidyOnWrite = true
+
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	archivePath := filepath.Join(dir, "archive.md")
+	content := "# Tasks\n\n## Done\n- [x] old @done(2026-01-01)\n\n## Work\n- [ ] a\n"
+	if err := WriteFile(tasksPath, content, opts); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	if _, archived, err := ProcessAndArchiveRouted(tasksPath, archivePath, nil, FixedDelay(2), opts); err != nil || archived[archivePath] != 1 {
+		t.Fatalf("ProcessAndArchiveRouted() = %v, %v; want 1 archived", archived, err)
+	}
+	want := "# Tasks\n\n## Work\n- [ ] a\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md = %q, want %q", got, want)
+	}
+}
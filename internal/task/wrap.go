@@ -0,0 +1,152 @@
+package task
+
+import "strings"
+
+// noBreakBefore are the characters a line never starts with when text is wrapped
+// between CJK characters: closing brackets and punctuation stay with the text before.
+const noBreakBefore = "、。，．・：；？！ー）」』】〕〉》ぁぃぅぇぉっゃゅょァィゥェォッャュョ,.:;?!)]}"
+
+// WrapTaskLine hard-wraps a newly added task line whose text (see TaskBody) is wider
+// than opts.WrapColumn: the line keeps the first part of the text and all of
+// its tags, so @due and the others still apply, and the rest follows as continuation
+// note lines indented TabWidth spaces deeper than the task. Widths count CJK and other
+// wide characters as 2 columns. Text breaks at spaces, and between two wide characters
+// unless the second is closing punctuation; a URL is never split, so a URL wider than
+// the column gets a line of its own. Lines that are not tasks, or fit, are returned
+// unchanged.
+func WrapTaskLine(line string, opts Options) string {
+	if opts.WrapColumn <= 0 || !IsTask(line, opts) {
+		return line
+	}
+	body := TaskBody(line, opts)
+	if textWidth(body) <= opts.WrapColumn {
+		return line
+	}
+	parts := wrapText(body, opts.WrapColumn)
+	indent := strings.Repeat(" ", GetIndentLevel(line)+TabWidth)
+	lines := []string{ReplaceBodyWith(line, parts[0], opts)}
+	for _, part := range parts[1:] {
+		lines = append(lines, indent+part)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapText splits text into lines of at most column display columns (see textWidth).
+// A piece wider than column on its own, such as a URL, gets a line to itself.
+func wrapText(text string, column int) []string {
+	var lines []string
+	var current strings.Builder
+	width := 0
+	for _, word := range strings.Fields(text) {
+		for i, piece := range wrapPieces(word) {
+			sep := ""
+			if i == 0 && width > 0 {
+				sep = " "
+			}
+			w := textWidth(piece)
+			if width > 0 && width+len(sep)+w > column {
+				lines = append(lines, current.String())
+				current.Reset()
+				width, sep = 0, ""
+			}
+			current.WriteString(sep + piece)
+			width += len(sep) + w
+		}
+	}
+	if width > 0 {
+		lines = append(lines, current.String())
+	}
+	return lines
+}
+
+// wrapPieces splits a word (text without spaces) where a line may break: before every
+// wide character, and after one, except before closing punctuation (noBreakBefore).
+// Words containing a URL are never split.
+func wrapPieces(word string) []string {
+	if urlPattern.MatchString(word) {
+		return []string{word}
+	}
+	var pieces []string
+	start := 0
+	prevWide := false
+	for i, r := range word {
+		wide := runeWidth(r) == 2
+		if i > start && (wide || prevWide) && !strings.ContainsRune(noBreakBefore, r) {
+			pieces = append(pieces, word[start:i])
+			start = i
+		}
+		prevWide = wide
+	}
+	return append(pieces, word[start:])
+}
+
+// TaskRef is a task line found in a file, e.g. by LongTasks.
+type TaskRef struct {
+	Line  int    // 0-indexed line number
+	Text  string // task text without marker and tags (see TaskBody)
+	Width int    // display columns of Text (see textWidth)
+}
+
+// IsLongTask reports whether line is an open task whose text (see TaskBody) is wider
+// than maxLen columns, wide characters counting as 2. Always false when maxLen is 0.
+func IsLongTask(line string, maxLen int, opts Options) bool {
+	if maxLen <= 0 || !IsTask(line, opts) || IsCompleted(line, opts) {
+		return false
+	}
+	return textWidth(TaskBody(line, opts)) > maxLen
+}
+
+// LongTasks is LongTasksWith with DefaultOptions.
+func LongTasks(content string, maxLen int) []TaskRef {
+	return LongTasksWith(content, maxLen, DefaultOptions())
+}
+
+// LongTasksWith returns the open tasks of content whose text is wider than maxLen columns
+// (see IsLongTask), the [tasks] max_task_length rule that a task this long should be
+// split up. Tasks in code blocks and quotes are ignored; maxLen 0 returns nil.
+func LongTasksWith(content string, maxLen int, opts Options) []TaskRef {
+	if maxLen <= 0 {
+		return nil
+	}
+	var long []TaskRef
+	for _, pl := range ParseLines(content, opts) {
+		if pl.IsTask && IsLongTask(pl.Content, maxLen, opts) {
+			text := TaskBody(pl.Content, opts)
+			long = append(long, TaskRef{Line: pl.LineNumber, Text: text, Width: textWidth(text)})
+		}
+	}
+	return long
+}
+
+// textWidth returns the number of terminal columns s takes (see runeWidth).
+func textWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// runeWidth returns 2 for the East Asian wide and full-width characters (CJK
+// ideographs, kana, hangul, full-width forms, and emoji) and 1 for anything else,
+// half-width katakana included.
+func runeWidth(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115F, // hangul jamo
+		r >= 0x2E80 && r <= 0x303E,   // CJK radicals, symbols, and punctuation
+		r >= 0x3041 && r <= 0x33FF,   // kana and CJK compatibility
+		r >= 0x3400 && r <= 0x4DBF,   // CJK extension A
+		r >= 0x4E00 && r <= 0x9FFF,   // CJK unified ideographs
+		r >= 0xA000 && r <= 0xA4CF,   // yi
+		r >= 0xAC00 && r <= 0xD7A3,   // hangul syllables
+		r >= 0xF900 && r <= 0xFAFF,   // CJK compatibility ideographs
+		r >= 0xFE30 && r <= 0xFE4F,   // CJK compatibility forms
+		r >= 0xFF00 && r <= 0xFF60,   // full-width forms
+		r >= 0xFFE0 && r <= 0xFFE6,   // full-width signs
+		r >= 0x1F300 && r <= 0x1F64F, // pictographs and emoticons
+		r >= 0x1F900 && r <= 0x1F9FF, // supplemental pictographs
+		r >= 0x20000 && r <= 0x3FFFD: // CJK extensions B and later
+		return 2
+	}
+	return 1
+}
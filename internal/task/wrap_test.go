@@ -0,0 +1,89 @@
+package task
+
+import "testing"
+
+// TestWrapTaskLine verifies word and CJK wrapping at the column, with tags kept on the
+// task line and URLs never split.
+func TestWrapTaskLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		column int
+		line   string
+		want   string
+	}{
+		{"off", 0, "- [ ] buy milk and bread", "- [ ] buy milk and bread"},
+		{"exactly at the column", 10, "- [ ] buy milk a", "- [ ] buy milk a"},
+		{"one over the column", 10, "- [ ] buy milk ab", "- [ ] buy milk\n  ab"},
+		{"several lines", 10, "- [ ] one two three four five six", "- [ ] one two\n  three four\n  five six"},
+		{"tags stay on the task line", 10, "- [ ] buy milk and bread @due(2026-10-20)", "- [ ] buy milk @due(2026-10-20)\n  and bread"},
+		{"tags do not count", 10, "- [ ] buy milk @due(2026-10-20)", "- [ ] buy milk @due(2026-10-20)"},
+		{"subtask indentation", 10, "    - [ ] buy milk and bread", "    - [ ] buy milk\n      and bread"},
+		{"CJK counts two columns", 10, "- [ ] 牛乳とパンを買う", "- [ ] 牛乳とパン\n  を買う"},
+		{"CJK exactly at the column", 10, "- [ ] 牛乳とパン", "- [ ] 牛乳とパン"},
+		{"closing punctuation kept", 10, "- [ ] 牛乳とパン。卵も", "- [ ] 牛乳とパ\n  ン。卵も"},
+		{"half-width katakana counts one", 10, "- [ ] ﾃﾞｰﾀﾍﾞｰｽ", "- [ ] ﾃﾞｰﾀﾍﾞｰｽ"},
+		{"URL not split", 20, "- [ ] read https://example.com/a/very/long/path today", "- [ ] read\n  https://example.com/a/very/long/path\n  today"},
+		{"URL at the boundary", 24, "- [ ] see https://example.com/a", "- [ ] see\n  https://example.com/a"},
+		{"CJK text around a URL", 10, "- [ ] 資料https://example.com/docを読む", "- [ ] 資料https://example.com/docを読む"},
+		{"not a task", 10, "a note that is long enough to wrap", "a note that is long enough to wrap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.WrapColumn = tt.column
+			if got := WrapTaskLine(tt.line, opts); got != tt.want {
+				t.Errorf("WrapTaskLine(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestInsertChildWraps verifies that subtasks added with InsertChild are wrapped too.
+func TestInsertChildWraps(t *testing.T) {
+	opts := DefaultOptions()
+	opts.WrapColumn = 10
+
+	got, _, err := InsertChild("- [ ] parent\n", 1, "buy milk and bread", opts)
+	if err != nil {
+		t.Fatalf("InsertChild() error: %v", err)
+	}
+	want := "- [ ] parent\n  - [ ] buy milk\n    and bread\n"
+	if got != want {
+		t.Errorf("InsertChild() = %q, want %q", got, want)
+	}
+}
+
+// TestLongTasks verifies that LongTasks reports open tasks whose text, without tags,
+// is wider than the limit, counting CJK characters as two columns.
+func TestLongTasks(t *testing.T) {
+	content := "# Tasks\n" +
+		"- [ ] buy milk and bread\n" + // 1: 18 columns
+		"- [ ] buy milk @due(2026-10-20)\n" + // 2: tags do not count
+		"  - [ ] 牛乳とパンと卵買う\n" + // 3: 18 columns
+		"- [ ] 牛乳\n" + // 4
+		"- [x] buy milk and bread again @done(2026-01-18)\n" + // 5: completed
+		"```\n- [ ] buy milk and bread in code\n```\n" +
+		"a note that is long enough to report\n"
+
+	got := LongTasks(content, 10)
+	want := []TaskRef{
+		{Line: 1, Text: "buy milk and bread", Width: 18},
+		{Line: 3, Text: "牛乳とパンと卵買う", Width: 18},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("LongTasksWith() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("LongTasksWith()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if got := LongTasks(content, 18); len(got) != 0 {
+		t.Errorf("LongTasksWith(18) = %+v, want none at exactly the limit", got)
+	}
+	if got := LongTasks(content, 0); got != nil {
+		t.Errorf("LongTasksWith(0) = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,140 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/yostos/tiny-task-tool/internal/task"
+)
+
+// maxActivities is how many entries the activity log keeps; older ones are dropped.
+const maxActivities = 50
+
+// ActivityKind is the kind of change an Activity records.
+type ActivityKind int
+
+const (
+	ActivityComplete ActivityKind = iota // tasks checked: X on a parent, or @done tags after editing
+	ActivityReopen                       // tasks unchecked: X on a parent, or R on a ## heading
+	ActivityAdd                          // lines pasted with p or P, or tasks restored from the archive
+	ActivityDelete                       // a task cut with d
+	ActivityArchive                      // completed tasks archived with a
+	ActivityMove                         // a task moved to another workspace with m
+)
+
+// Activity is one change the TUI made to tasks.md, kept in memory for the activity
+// log (L). Line and Block say where and what the change was as it was written, so it
+// can be reversed: a deleted or moved Block was cut out at Line, and a pasted Block
+// was inserted below Line.
+type Activity struct {
+	Time   time.Time
+	Kind   ActivityKind
+	Count  int    // tasks changed, or lines for Add and Delete
+	Text   string // task text or ## heading the change applied to ("" if none)
+	Target string // workspace the task was moved to (Move only)
+	Line   int    // 0-indexed line in tasks.md, -1 when the change has no single place
+	Block  string // lines cut, pasted, or moved (Add, Delete, and Move only)
+}
+
+// String describes a for the activity log, e.g. "Deleted 2 line(s): Buy milk".
+func (a Activity) String() string {
+	n := strconv.Itoa(a.Count)
+	var s string
+	switch a.Kind {
+	case ActivityComplete:
+		s = "Completed " + n + " task(s)"
+	case ActivityReopen:
+		s = "Reopened " + n + " task(s)"
+	case ActivityAdd:
+		s = "Added " + n + " line(s)"
+	case ActivityDelete:
+		s = "Deleted " + n + " line(s)"
+	case ActivityArchive:
+		s = "Archived " + n + " task(s)"
+	case ActivityMove:
+		s = "Moved to " + a.Target
+	}
+	if a.Text != "" {
+		s += ": " + a.Text
+	}
+	return s
+}
+
+// recordActivity adds a to the activity log with the time now, dropping the oldest
+// entry once there are more than maxActivities.
+func (m Model) recordActivity(a Activity) Model {
+	a.Time = time.Now()
+	m.activities = append(m.activities, a)
+	if len(m.activities) > maxActivities {
+		m.activities = m.activities[len(m.activities)-maxActivities:]
+	}
+	return m
+}
+
+// recordMove adds a move reported by msg to the activity log.
+func (m Model) recordMove(msg MoveFinishedMsg) Model {
+	first, _, _ := strings.Cut(msg.Block, "\n")
+	return m.recordActivity(Activity{Kind: ActivityMove, Count: 1, Text: task.TaskBody(first, m.taskOpts), Target: msg.Target, Line: msg.Line, Block: msg.Block})
+}
+
+// activityLines returns the activity log, newest first, one "15:04 <change>" line per
+// entry, at most limit lines.
+func (m Model) activityLines(limit int) []string {
+	if len(m.activities) == 0 {
+		return []string{"No activity yet"}
+	}
+	var lines []string
+	for i := len(m.activities) - 1; i >= 0 && len(lines) < limit; i-- {
+		a := m.activities[i]
+		lines = append(lines, a.Time.Format("15:04")+"  "+a.String())
+	}
+	return lines
+}
+
+// overlayActivity renders the activity log overlay on top of the base view.
+func (m Model) overlayActivity(base string) string {
+	// border (2) + title (1) + blank and close hint (2) + margin (2)
+	limit := m.height - 7
+	if limit < 1 {
+		limit = 1
+	}
+	width := m.width - 6
+	if width > 60 {
+		width = 60
+	}
+	if width < 1 {
+		width = 1
+	}
+
+	lines := m.activityLines(limit)
+	for i, line := range lines {
+		lines[i] = truncateByDisplayWidth(line, width)
+	}
+	content := strings.Join(append(lines, "", "Press any key to close"), "\n")
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		Padding(0, 1).
+		Width(width + 2)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Align(lipgloss.Center).
+		Width(width)
+
+	box := boxStyle.Render(titleStyle.Render("Recent activity") + "\n" + content)
+
+	x := (m.width - lipgloss.Width(box)) / 2
+	y := (m.height - lipgloss.Height(box)) / 2
+	if x < 0 {
+		x = 0
+	}
+	if y < 0 {
+		y = 0
+	}
+	return placeOverlay(x, y, box, base)
+}
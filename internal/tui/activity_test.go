@@ -0,0 +1,114 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/yostos/tiny-task-tool/internal/config"
+)
+
+// TestActivityString verifies the activity log wording of each kind of change.
+func TestActivityString(t *testing.T) {
+	tests := []struct {
+		activity Activity
+		want     string
+	}{
+		{Activity{Kind: ActivityComplete, Count: 2, Text: "Trip"}, "Completed 2 task(s): Trip"},
+		{Activity{Kind: ActivityComplete, Count: 1}, "Completed 1 task(s)"},
+		{Activity{Kind: ActivityReopen, Count: 3, Text: "Packing"}, "Reopened 3 task(s): Packing"},
+		{Activity{Kind: ActivityAdd, Count: 2, Text: "a"}, "Added 2 line(s): a"},
+		{Activity{Kind: ActivityDelete, Count: 2, Text: "a"}, "Deleted 2 line(s): a"},
+		{Activity{Kind: ActivityArchive, Count: 4}, "Archived 4 task(s)"},
+		{Activity{Kind: ActivityMove, Count: 1, Text: "a", Target: "home"}, "Moved to home: a"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.activity.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// TestRecordActivity verifies that the log keeps the newest maxActivities entries and
+// lists them newest first.
+func TestRecordActivity(t *testing.T) {
+	var m Model
+	if got := m.activityLines(10); len(got) != 1 || got[0] != "No activity yet" {
+		t.Errorf("empty log = %q", got)
+	}
+
+	for i := 1; i <= maxActivities+5; i++ {
+		m = m.recordActivity(Activity{Kind: ActivityArchive, Count: i, Line: -1})
+	}
+	if len(m.activities) != maxActivities {
+		t.Fatalf("len(activities) = %d, want %d", len(m.activities), maxActivities)
+	}
+	if first := m.activities[0].Count; first != 6 {
+		t.Errorf("oldest entry = %d, want 6", first)
+	}
+	if m.activities[0].Time.IsZero() {
+		t.Error("entries should have a time")
+	}
+
+	lines := m.activityLines(2)
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "Archived 55 task(s)") || !strings.HasSuffix(lines[1], "Archived 54 task(s)") {
+		t.Errorf("activityLines(2) = %q, want the two newest", lines)
+	}
+}
+
+// TestActivityLog verifies that deleting and pasting are recorded with their place and
+// lines, and that L opens the overlay and any key closes it.
+func TestActivityLog(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "- [ ] a\n  - [ ] a1\n- [ ] b\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+	press := func(m Model, key string) (Model, tea.Cmd) {
+		newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		return newModel.(Model), cmd
+	}
+
+	m, _ = press(m, "v")
+	m, cmd := press(m, "d")
+	newModel, _ = m.Update(cmd())
+	m = newModel.(Model)
+	m.cursor = 0
+	m.lines = []string{"- [ ] b", ""}
+	m, cmd = press(m, "p")
+	newModel, _ = m.Update(cmd())
+	m = newModel.(Model)
+
+	want := []Activity{
+		{Kind: ActivityDelete, Count: 2, Text: "a", Line: 0, Block: "- [ ] a\n  - [ ] a1"},
+		{Kind: ActivityAdd, Count: 2, Text: "a", Line: 0, Block: "- [ ] a\n  - [ ] a1"},
+	}
+	if len(m.activities) != len(want) {
+		t.Fatalf("activities = %+v, want %+v", m.activities, want)
+	}
+	for i, a := range m.activities {
+		a.Time = want[i].Time
+		if a != want[i] {
+			t.Errorf("activity %d = %+v, want %+v", i, a, want[i])
+		}
+	}
+
+	m, _ = press(m, "esc")
+	m, _ = press(m, "L")
+	if !m.showActivity || !strings.Contains(m.View(), "Deleted 2 line(s): a") {
+		t.Fatal("L should show the activity log")
+	}
+	m, _ = press(m, "j")
+	if m.showActivity {
+		t.Error("any key should close the activity log")
+	}
+}
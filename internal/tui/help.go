@@ -0,0 +1,198 @@
+package tui
+
+// mode is the state of the TUI that decides which keys do what. The help overlay
+// lists the keys of the current mode (see helpTables).
+type mode int
+
+const (
+	modeNormal  mode = iota // scrolling tasks.md
+	modeSearch              // typing a search query after /
+	modeSelect              // select mode (v): a cursor on one line
+	modeEdit                // inline edit (i) of the selected task
+	modeArchive             // archive view (A)
+)
+
+// String returns the name of the mode shown in the help title.
+func (md mode) String() string {
+	switch md {
+	case modeSearch:
+		return "Search"
+	case modeSelect:
+		return "Select"
+	case modeEdit:
+		return "Edit"
+	case modeArchive:
+		return "Archive"
+	}
+	return "Normal"
+}
+
+// currentMode returns the mode the keys pressed now are handled in.
+func (m Model) currentMode() mode {
+	switch {
+	case m.inlineEditing:
+		return modeEdit
+	case m.searching:
+		return modeSearch
+	case m.archiveMode:
+		return modeArchive
+	case m.cursorMode:
+		return modeSelect
+	}
+	return modeNormal
+}
+
+// helpEntry is a line of the help overlay: the keys and what they do. An entry with
+// an action lists the keys configured for it in [keybindings] instead, after arrow
+// (e.g. "↑/k"), and an entry with filters lists the saved filters ([ui] filters), if
+// any. An entry with none of these is a blank line.
+type helpEntry struct {
+	keys    string
+	action  action
+	arrow   string
+	desc    string
+	filters bool
+}
+
+// scrollHelp lists the configurable keys that scroll the view.
+var scrollHelp = []helpEntry{
+	{action: actionUp, arrow: "↑", desc: "Scroll up"},
+	{action: actionDown, arrow: "↓", desc: "Scroll down"},
+	{action: actionTop, desc: "Go to top"},
+	{action: actionBottom, desc: "Go to bottom"},
+	{action: actionHalfPageUp, desc: "Half page up"},
+	{action: actionHalfPageDown, desc: "Half page down"},
+	{keys: "PgUp/PgDn", desc: "Page up / down"},
+}
+
+// cursorHelp lists the configurable keys that move the select-mode cursor.
+var cursorHelp = []helpEntry{
+	{action: actionUp, arrow: "↑", desc: "Cursor up"},
+	{action: actionDown, arrow: "↓", desc: "Cursor down"},
+	{action: actionTop, desc: "First line"},
+	{action: actionBottom, desc: "Last line"},
+	{action: actionHalfPageUp, desc: "Half page up"},
+	{action: actionHalfPageDown, desc: "Half page down"},
+}
+
+// helpTables are the keys of each mode, in the order the help overlay lists them.
+var helpTables = map[mode][]helpEntry{
+	modeNormal: concatHelp(scrollHelp, []helpEntry{
+		{},
+		{keys: "e", desc: "Open editor"},
+		{keys: "a", desc: "Archive tasks"},
+		{keys: "r/d", desc: "Reload / git diff"},
+		{keys: "/", desc: "Search (n/N jump)"},
+		{keys: "v", desc: "Select mode"},
+		{keys: "A/u", desc: "Archive / by due"},
+		{keys: "L/T/f", desc: "Log/today/future"},
+		{},
+		{filters: true},
+		{keys: "q", desc: "Quit"},
+		{keys: "?/h", desc: "Help"},
+	}),
+	modeSearch: {
+		{keys: "Enter", desc: "Search and jump"},
+		{keys: "Backspace", desc: "Delete a character"},
+		{keys: "Esc", desc: "Cancel"},
+		{},
+		{keys: "n/N", desc: "Next / prev match"},
+		{keys: "Esc", desc: "Clear highlight"},
+		{},
+		{keys: "F1", desc: "Help"},
+	},
+	modeSelect: concatHelp(cursorHelp, []helpEntry{
+		{keys: "PgUp/PgDn", desc: "Scroll a page"},
+		{keys: ".", desc: "Center cursor"},
+		{},
+		{keys: "X", desc: "Toggle subtasks"},
+		{keys: "i", desc: "Edit text"},
+		{keys: "o", desc: "Open link"},
+		{keys: "m", desc: "Move to workspace"},
+		{keys: "T", desc: "Start / stop timer"},
+		{keys: "*", desc: "Pin / unpin"},
+		{keys: "d/y/p", desc: "Cut / copy / paste"},
+		{keys: "P", desc: "Paste clipboard"},
+		{keys: "z", desc: "Fold ## section"},
+		{keys: "R", desc: "Reset ## section"},
+		{keys: "u/Ctrl+r", desc: "Undo / redo"},
+		{},
+		{keys: "v/Esc", desc: "Leave select mode"},
+		{keys: "?/h", desc: "Help"},
+	}),
+	modeEdit: {
+		{keys: "Enter", desc: "Save"},
+		{keys: "Esc", desc: "Cancel"},
+		{keys: "←/→", desc: "Move cursor"},
+		{keys: "Home/End", desc: "Start / end"},
+		{keys: "Bksp/Del", desc: "Delete a character"},
+		{},
+		{keys: "Tab", desc: "Complete tag"},
+		{keys: "↑/↓", desc: "Choose completion"},
+		{},
+		{keys: "F1", desc: "Help"},
+	},
+	modeArchive: concatHelp(cursorHelp, []helpEntry{
+		{},
+		{keys: "u", desc: "Restore task"},
+		{keys: "o", desc: "Section order"},
+		{},
+		{keys: "A/Esc", desc: "Back to tasks.md"},
+		{keys: "q", desc: "Quit"},
+		{keys: "?/h", desc: "Help"},
+	}),
+}
+
+// concatHelp joins help tables.
+func concatHelp(tables ...[]helpEntry) []helpEntry {
+	var entries []helpEntry
+	for _, table := range tables {
+		entries = append(entries, table...)
+	}
+	return entries
+}
+
+// helpLines renders the help table of md as overlay lines, with the keys configured
+// in [keybindings] and the saved filters filled in.
+func (m Model) helpLines(md mode) []string {
+	lines := []string{""}
+	for _, entry := range helpTables[md] {
+		switch {
+		case entry.filters:
+			if len(m.config.UI.Filters) == 0 {
+				continue
+			}
+			for _, f := range m.config.UI.Filters {
+				lines = append(lines, "  "+padRight(f.Key, 12)+"Filter: "+f.Name)
+			}
+			lines = append(lines, "  "+padRight("0", 12)+"Clear filter", "")
+		case entry.action != actionNone:
+			lines = append(lines, "  "+padRight(formatKeys(m.actionKeys(entry.action), entry.arrow), 12)+entry.desc)
+		case entry.keys == "":
+			lines = append(lines, "")
+		default:
+			lines = append(lines, "  "+padRight(entry.keys, 12)+entry.desc)
+		}
+	}
+	return lines
+}
+
+// actionKeys returns the keys configured for a in [keybindings].
+func (m Model) actionKeys(a action) []string {
+	kb := m.config.Keybindings
+	switch a {
+	case actionUp:
+		return kb.Up
+	case actionDown:
+		return kb.Down
+	case actionTop:
+		return kb.Top
+	case actionBottom:
+		return kb.Bottom
+	case actionHalfPageUp:
+		return kb.HalfPageUp
+	case actionHalfPageDown:
+		return kb.HalfPageDown
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/yostos/tiny-task-tool/internal/config"
+)
+
+// TestHelpByMode verifies that the help overlay names the current mode in its title
+// and lists only the keys of that mode.
+func TestHelpByMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(m Model) Model
+		mode    mode
+		want    []string
+		notWant []string
+	}{
+		{"normal", func(m Model) Model { return m }, modeNormal,
+			[]string{"Help: Normal", "Open editor", "Scroll up"}, []string{"Toggle subtasks", "Complete tag"}},
+		{"search", func(m Model) Model { m.searching = true; return m }, modeSearch,
+			[]string{"Help: Search", "Search and jump", "Next / prev match"}, []string{"Open editor"}},
+		{"select", func(m Model) Model { m.cursorMode = true; return m }, modeSelect,
+			[]string{"Help: Select", "Toggle subtasks", "Cursor up"}, []string{"Open editor", "Scroll up"}},
+		{"edit", func(m Model) Model { m.cursorMode, m.inlineEditing = true, true; return m }, modeEdit,
+			[]string{"Help: Edit", "Complete tag", "Save"}, []string{"Toggle subtasks"}},
+		{"archive", func(m Model) Model { m.archiveMode, m.cursorMode = true, true; return m }, modeArchive,
+			[]string{"Help: Archive", "Restore task", "Back to tasks.md"}, []string{"Open editor", "Toggle subtasks"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(config.Default(), "- [ ] Task")
+			newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 30})
+			m = tt.setup(newModel.(Model))
+			if got := m.currentMode(); got != tt.mode {
+				t.Errorf("currentMode() = %v, want %v", got, tt.mode)
+			}
+			m.showHelp = true
+			view := m.View()
+			for _, s := range tt.want {
+				if !strings.Contains(view, s) {
+					t.Errorf("help should contain %q:\n%s", s, view)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(view, s) {
+					t.Errorf("help should not contain %q:\n%s", s, view)
+				}
+			}
+		})
+	}
+}
+
+// TestHelpLinesConfigured verifies that configured keybindings and saved filters are
+// filled in, in every mode that lists them.
+func TestHelpLinesConfigured(t *testing.T) {
+	cfg := config.Default()
+	cfg.Keybindings.Up = []string{"k", "ctrl+p"}
+	cfg.UI.Filters = []config.FilterConfig{{Key: "1", Name: "Waiting", Query: "@waiting"}}
+	m := New(cfg, "- [ ] Task")
+
+	normal := strings.Join(m.helpLines(modeNormal), "\n")
+	if !strings.Contains(normal, "↑/k/ctrl+p") || !strings.Contains(normal, "Filter: Waiting") {
+		t.Errorf("normal help = %q, want the configured up keys and the filter", normal)
+	}
+	selectHelp := strings.Join(m.helpLines(modeSelect), "\n")
+	if !strings.Contains(selectHelp, "↑/k/ctrl+p") || strings.Contains(selectHelp, "Filter: Waiting") {
+		t.Errorf("select help = %q, want the configured up keys and no filters", selectHelp)
+	}
+}
+
+// TestHelpF1WhileTyping verifies that F1 opens the help while typing a search, where
+// ? is part of the query.
+func TestHelpF1WhileTyping(t *testing.T) {
+	m := New(config.Default(), "- [ ] Task")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 30})
+	m = newModel.(Model)
+	m.searching = true
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	m = newModel.(Model)
+	if m.showHelp || m.searchInput != "?" {
+		t.Fatalf("? while searching: showHelp %v, input %q; want it typed", m.showHelp, m.searchInput)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyF1})
+	m = newModel.(Model)
+	if !m.showHelp || !strings.Contains(m.View(), "Help: Search") {
+		t.Error("F1 while searching should open the search help")
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = newModel.(Model)
+	if m.showHelp || !m.searching || m.searchInput != "?" {
+		t.Errorf("closing help: showHelp %v, searching %v, input %q; want the search kept", m.showHelp, m.searching, m.searchInput)
+	}
+}
+
+// TestHelpTablesFit verifies that every help line fits the overlay box on one line.
+func TestHelpTablesFit(t *testing.T) {
+	m := New(config.Default(), "")
+	for _, md := range []mode{modeNormal, modeSearch, modeSelect, modeEdit, modeArchive} {
+		for _, line := range m.helpLines(md) {
+			if width := lipgloss.Width(line); width > 32 {
+				t.Errorf("%v help line %q is %d wide, want at most 32", md, line, width)
+			}
+		}
+	}
+}
@@ -2,23 +2,96 @@
 package tui
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 
 	"github.com/yostos/tiny-task-tool/internal/cli"
 	"github.com/yostos/tiny-task-tool/internal/config"
+	"github.com/yostos/tiny-task-tool/internal/git"
 	"github.com/yostos/tiny-task-tool/internal/task"
 )
 
 // statusTimeout is the duration after which status messages auto-clear.
 const statusTimeout = 3 * time.Second
 
+const (
+	// autoSyncIdleWindow postpones a scheduled sync if a key was pressed this recently.
+	autoSyncIdleWindow = 30 * time.Second
+	// autoSyncMaxBackoff caps the retry delay after consecutive sync failures.
+	autoSyncMaxBackoff = time.Hour
+	// autoSyncWarnFailures is the number of consecutive failures that triggers a persistent warning.
+	autoSyncWarnFailures = 3
+)
+
+// integrityLossRatio is the share of committed lines that tasks.md may lose
+// before the startup integrity check (git.integrity_check) warns.
+const integrityLossRatio = 0.3
+
+// guardOp identifies a file-modifying operation held back by the tasks.md guard.
+type guardOp string
+
+const (
+	guardOpDoneTags     guardOp = "done-tags"
+	guardOpEditDoneTags guardOp = "edit-done-tags" // after the editor, with reload
+	guardOpArchive      guardOp = "archive"
+
+	guardOpToggleChildren guardOp = "toggle-children"
+	guardOpRestore        guardOp = "restore"
+	guardOpTrack          guardOp = "track"
+	guardOpInlineEdit     guardOp = "inline-edit"
+	guardOpPin            guardOp = "pin"
+	guardOpMove           guardOp = "move"
+	guardOpDelete         guardOp = "delete"
+	guardOpPaste          guardOp = "paste"
+	guardOpImport         guardOp = "import"
+	guardOpReset          guardOp = "reset"
+)
+
+// restorePrompt is shown after u in the archive view, asking how to restore the task.
+const restorePrompt = "Restore task: k keep done / o reopen / esc cancel"
+
+// rebasePrompt is shown when a sync's push was rejected because the remote history
+// diverged (see git.ErrNonFastForward).
+const rebasePrompt = "Remote history diverged. r pull --rebase and push / esc abort"
+
+// Smallest terminal size the normal view is laid out for.
+const (
+	minWidth  = 40
+	minHeight = 10
+)
+
+// tooSmallMessage replaces the normal view while the terminal is below minWidth x minHeight.
+const tooSmallMessage = "Terminal too small (need ≥ 40x10)"
+
+// quitPrompt and quitPromptNoRemote are shown after q when git.confirm_quit_if_dirty
+// is set and the working directory has changes that are not committed or not pushed.
+const (
+	quitPrompt         = "Unsynced changes. Quit anyway? (y/n/s=sync)"
+	quitPromptNoRemote = "Uncommitted changes. Quit anyway? (y/n)"
+)
+
+// guardPrompt is shown while a guarded operation waits for confirmation.
+const guardPrompt = "tasks.md does not look like a task list. Modify anyway? (y/n)"
+
 // Model represents the TUI application state.
 type Model struct {
 	config      *config.Config
@@ -28,11 +101,183 @@ type Model struct {
 	ready       bool
 	width       int
 	height      int
+	tooSmall    bool
 	err         error
 	status      string
 	tasksPath   string
 	archivePath string
 	showHelp    bool
+	showDiff    bool
+	diffView    viewport.Model
+
+	// Compiled [ui.link_patterns] for hyperlinks and the open-link key
+	links []task.LinkPattern
+
+	// Task settings passed to every task package call (see config.Config.TaskOptions)
+	taskOpts task.Options
+
+	// Styled viewport content from the last displayContent call (see renderCache)
+	render *renderCache
+
+	// Saved filter currently applied to the view (nil = unfiltered)
+	filter     *task.Query
+	filterName string
+
+	// Quick filter (T): the tasks completed today. quickReturn is the view it
+	// replaced, restored when T is pressed again. midnightPending is set while a
+	// MidnightMsg is scheduled.
+	quickFilter     bool
+	quickReturn     quickView
+	midnightPending bool
+
+	// f shows the future tasks display.hide_future hides (see futureLines)
+	showFuture bool
+
+	// Set once the first load has been checked against tasks.open_limit
+	openLimitChecked bool
+
+	// Scheduled auto-sync state
+	syncing      bool
+	syncFailures int
+	syncWarning  string
+	lastKeyPress time.Time
+
+	// Running sync (see startSync): syncEvents delivers its progress and result, and
+	// syncCancel stops it. quitOnSyncStop quits once a sync stopped by Ctrl+C has ended.
+	syncEvents     <-chan tea.Msg
+	syncCancel     context.CancelFunc
+	quitOnSyncStop bool
+
+	// Startup integrity check: persistent warning until the diff is viewed with g
+	integrityWarning string
+
+	// Search: "/" types a query in the footer, n/N jump between matching lines
+	searching   bool
+	searchInput string
+	searchQuery string // confirmed query, highlighted until cleared with esc
+
+	// Inline edit: i edits the text of the selected task in the footer. inlineInput
+	// holds the text being typed; inlineLine and inlineText are the line being edited
+	// and its content when editing started.
+	inlineEditing bool
+	inlineInput   textinput.Model
+	inlineLine    int
+	inlineText    string
+
+	// Tag completion in the inline edit: inlineTags are the tags already used in the
+	// file (see task.FileTags) and inlineChoice the highlighted suggestion.
+	inlineTags   []string
+	inlineChoice int
+
+	// Editor round trip: content before the edit, and what git.pull_before_edit found
+	// ("working offline", "remote ahead"), shown with the status after the edit
+	editing        bool
+	preEditContent string
+	editWarning    string
+
+	// Undo history (see Snapshot): changes that u undoes and ctrl+r redoes, newest last
+	undoStack []Snapshot
+	redoStack []Snapshot
+
+	// Status to show once the pending reload finishes (instead of "Reloaded")
+	reloadStatus string
+
+	// Footer git indicator ("*", "↑N", "✓"); empty when no remote is configured
+	gitIndicator string
+
+	// Footer streak segment (ui.show_streak). archive.md is read once per day and
+	// cached in streakArchive; a zero streakDay makes the next streakCmd read it again.
+	streakText    string
+	streakDay     time.Time
+	streakArchive string
+
+	// Select mode: cursor is the selected line (0-indexed line of content)
+	cursorMode bool
+	cursor     int
+
+	// Folded "## " sections, keyed by heading text so they stay folded across reloads.
+	// View only: tasks.md is not changed.
+	collapsed map[string]bool
+
+	// Time tracking: T starts a timer on the selected task and T again adds the
+	// elapsed time to its @track tag. trackText locates the line if it moved meanwhile.
+	tracking     bool
+	trackLine    int
+	trackText    string
+	trackStart   time.Time
+	trackElapsed time.Duration
+
+	// Quit confirmation (git.confirm_quit_if_dirty): quitPending waits for y/n/s after q,
+	// quitCanSync offers s when a remote exists, and quitAfterSync quits once s's sync succeeds.
+	quitPending   bool
+	quitCanSync   bool
+	quitAfterSync bool
+	// quitArchiving is set while q's archive pass (archive.on_quit) runs; the TUI quits
+	// once it succeeds. Keys other than ctrl+c wait for it.
+	quitArchiving bool
+
+	// Diverged remote: rebasePending waits for r (rebase and push again) or another key
+	// (abort with rebaseHint) after a sync's push was rejected. ttt never force-pushes.
+	rebasePending bool
+	rebaseHint    string
+
+	// Move to another workspace: movePending waits for the number of one of moveTargets
+	// after m; moveTarget is the chosen workspace.
+	movePending bool
+	moveTargets []string
+	moveTarget  string
+
+	// Yank buffer: d cuts the selected task and the lines nested under it into
+	// yankBuffer and y copies them there; p pastes it below the selected line, as
+	// often as wanted (see task.PasteBlock).
+	yankBuffer []string
+
+	// Activity log: the changes made to tasks.md in this session, oldest first and at
+	// most maxActivities; L shows them in an overlay (showActivity).
+	activities   []Activity
+	showActivity bool
+
+	// Section reset: resetPending waits for y/n after R on a ## heading; resetSection
+	// is that section (see task.ResetSection).
+	resetPending bool
+	resetSection task.Section
+
+	// tasks.md guard: pending operation awaiting y/n, and whether the user already confirmed
+	guardPending   guardOp
+	guardConfirmed bool
+
+	// Archive view: archive.md shown read-only with the select cursor.
+	// restorePending waits for k/o after u; restoreKeepDone is the answer.
+	archiveMode     bool
+	archiveLines    []string
+	restorePending  bool
+	restoreKeepDone bool
+
+	// Archive view order: o shows the date sections oldest first (archiveAscending).
+	// archiveContent is the archive files as loaded (see task.LoadArchives), and
+	// archiveOrder maps each line of archiveLines to its line in archiveContent
+	// (see task.ArchiveSectionOrder).
+	archiveAscending bool
+	archiveContent   string
+	archiveOrder     []int
+
+	// archiveFiles are the files joined in archiveContent. Older monthly files are
+	// left out while they exceed archiveViewBudget (archiveMore); archiveAll loads
+	// them all once the cursor reaches the end of the view.
+	archiveFiles []task.ArchiveFile
+	archiveMore  bool
+	archiveAll   bool
+
+	// Due-date view: u shows the open tasks of tasks.md by @due date (see task.SortByDue)
+	// in dueView, read-only. Scrolling keys scroll it; any other key closes it.
+	showDue bool
+	dueView viewport.Model
+
+	// Split view (ui.split_view): the archive files in archivePane, read-only, right
+	// of tasks.md while the window is wide enough (see splitWidths). Tab moves the
+	// focus to it (archivePaneFocus) and back; it scrolls on its own.
+	archivePane      viewport.Model
+	archivePaneFocus bool
 }
 
 // New creates a new TUI model.
@@ -46,12 +291,62 @@ func New(cfg *config.Config, content string) Model {
 		lines = strings.Split(trimmed, "\n")
 	}
 	return Model{
-		config:  cfg,
-		content: content,
-		lines:   lines,
+		config:   cfg,
+		content:  content,
+		lines:    lines,
+		links:    cfg.LinkPatterns(),
+		taskOpts: cfg.TaskOptions(),
+		render:   &renderCache{},
 	}
 }
 
+// renderCache keeps the styled viewport lines between displayContent calls, so lines
+// are only styled again when the text shown or the search highlight changes: moving
+// the cursor restyles just the selected row, and redraws such as opening the help
+// overlay restyle nothing. It is a pointer so every copy of the Model shares it.
+type renderCache struct {
+	key             string   // search state and display lines the cache was built from
+	lines           []string // each row styled without the cursor
+	cursorRow       int      // row shown selected in renderedContent (-1 = none)
+	renderedContent string   // lines joined, with cursorRow selected
+
+	// Rows of renderedContent and their display widths, for mainView
+	widths    []int
+	rows      []string
+	rowWidths []int
+	track     string // styled scrollbar track cell
+
+	// Footer "est. remaining" total (see remainingEstimate) and what it was computed from
+	estimateKey estimateKey
+	estimate    time.Duration
+
+	// Rendered footer bar and the text and width it was rendered from
+	footerKey string
+	footer    string
+
+	// "## " sections of tasks.md (see sections) and the content they were computed from
+	sectionsKey string
+	sections    []task.Section
+
+	// Future tasks of tasks.md (see futureLines) and the day and content they were
+	// computed from
+	futureKey   estimateKey
+	future      map[int]bool
+	futureTasks int
+
+	// Open tasks of tasks.md (see openTasks) and the content they were counted in
+	openKey string
+	open    int
+}
+
+// estimateKey is what the footer "est. remaining" total depends on. Comparing it
+// does not copy the content, so a cache hit costs next to nothing per frame.
+type estimateKey struct {
+	day        string
+	filterName string
+	content    string
+}
+
 // NewWithPaths creates a new TUI model with file paths for edit/archive/reload.
 func NewWithPaths(cfg *config.Config, content, tasksPath, archivePath string) Model {
 	m := New(cfg, content)
@@ -60,19 +355,39 @@ func NewWithPaths(cfg *config.Config, content, tasksPath, archivePath string) Mo
 	return m
 }
 
+// WithLogger returns m with the debug events of its task operations sent to logger.
+func (m Model) WithLogger(logger *slog.Logger) Model {
+	m.taskOpts.Logger = logger
+	return m
+}
+
 // Init initializes the model.
 // Always adds @done tags to completed tasks at startup.
 // If archive.auto is enabled, also runs auto-archive.
+// If git.auto_sync_minutes is set, also schedules the first auto-sync.
+// The footer git indicator is refreshed here and after every reload (i.e. after each write).
 func (m Model) Init() tea.Cmd {
+	var cmd tea.Cmd
 	if m.config.Archive.Auto {
-		return m.archiveCmd()
+		cmd = m.archiveCmd()
+	} else {
+		cmd = m.addDoneTagsCmd()
+	}
+
+	cmds := []tea.Cmd{cmd, m.gitStatusCmd(), m.streakCmd()}
+	if m.config.Git.IntegrityCheck {
+		cmds = append(cmds, m.integrityCheckCmd())
+	}
+	if m.config.Git.AutoSyncMinutes > 0 {
+		cmds = append(cmds, m.autoSyncTickCmd())
 	}
-	return m.addDoneTagsCmd()
+	return tea.Batch(cmds...)
 }
 
 // Update handles messages and updates the model.
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
+	m = m.recordUndo(msg)
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -81,19 +396,38 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		m.tooSmall = msg.Width < minWidth || msg.Height < minHeight
 
 		headerHeight := 0
 		footerHeight := 1
 		verticalMargins := headerHeight + footerHeight
 
+		viewportHeight := msg.Height - verticalMargins
+		if viewportHeight < 0 {
+			viewportHeight = 0
+		}
+		fullWidth := msg.Width
+		if m.config.UI.Scrollbar && fullWidth > 0 {
+			fullWidth-- // rightmost column is the scrollbar
+		}
+		viewportWidth, paneWidth := m.splitWidths(msg.Width)
+		if m.config.UI.Scrollbar && viewportWidth > 0 {
+			viewportWidth-- // the scrollbar is on the right of the tasks.md pane
+		}
 		if !m.ready {
-			m.viewport = viewport.New(msg.Width, msg.Height-verticalMargins)
-			m.viewport.SetContent(m.content)
+			m.viewport = viewport.New(viewportWidth, viewportHeight)
+			m.viewport.SetContent(m.displayContent())
 			m.ready = true
 		} else {
-			m.viewport.Width = msg.Width
-			m.viewport.Height = msg.Height - verticalMargins
+			m.viewport.Width = viewportWidth
+			m.viewport.Height = viewportHeight
+		}
+		m.archivePane.Width, m.archivePane.Height = paneWidth, viewportHeight
+		if paneWidth == 0 {
+			m.archivePaneFocus = false
 		}
+		m.diffView.Width, m.diffView.Height = m.diffViewSize()
+		m.dueView.Width, m.dueView.Height = fullWidth, viewportHeight
 
 	case statusMsg:
 		m.status = string(msg)
@@ -107,26 +441,62 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.err = msg.err
 		return m, nil
 
+	case PullBeforeEditMsg:
+		m.status = ""
+		if msg.Content != "" {
+			m.preEditContent = msg.Content
+		}
+		m.editWarning = pullWarning(msg.Err)
+		return m, m.editCmd()
+
+	case UndoFinishedMsg:
+		return m.undoFinished(msg)
+
 	case EditFinishedMsg:
 		if msg.Err != nil {
+			m.editing = false
 			m, cmd := m.setStatusWithTimeout("Error: " + msg.Err.Error())
 			return m, cmd
 		}
+		// The editor may have changed anything, so the undo history no longer applies
+		m = m.clearUndo()
 		// Add @done tags, then reload
 		return m, m.addDoneTagsAndReloadCmd()
 
 	case ArchiveFinishedMsg:
+		if m.quitArchiving {
+			// archive.on_quit: quit only once the archive is written
+			m.quitArchiving = false
+			if msg.Err != nil {
+				return m.setStatusWithTimeout("Archive error (quit cancelled): " + msg.Err.Error())
+			}
+			return m, m.quitCmd()
+		}
 		if msg.Err != nil {
 			m, cmd := m.setStatusWithTimeout("Archive error: " + msg.Err.Error())
 			return m, cmd
 		}
-		if msg.Count > 0 {
+		switch {
+		case msg.Tagged > 0 && msg.Count > 0:
+			m.status = "Tagged " + strconv.Itoa(msg.Tagged) + ", archived " + strconv.Itoa(msg.Count)
+		case msg.Count > 0:
 			m.status = "Archived " + strconv.Itoa(msg.Count) + " task(s)"
-			// Reload to show updated content, status will be set with timeout after reload
-			return m, m.reloadCmd()
+		case msg.Tagged > 0:
+			m.status = "Tagged " + strconv.Itoa(msg.Tagged) + ", no tasks to archive"
+		default:
+			m, cmd := m.setStatusWithTimeout("No tasks to archive")
+			return m, cmd
 		}
-		m, cmd := m.setStatusWithTimeout("No tasks to archive")
-		return m, cmd
+		if msg.Tagged > 0 {
+			m = m.recordActivity(Activity{Kind: ActivityComplete, Count: msg.Tagged, Line: -1})
+		}
+		if msg.Count > 0 {
+			m = m.recordActivity(Activity{Kind: ActivityArchive, Count: msg.Count, Line: -1})
+		}
+		// Reload to show updated content, status will be set with timeout after reload.
+		// Archived tasks left tasks.md, so the cached archive is stale.
+		m.streakDay = time.Time{}
+		return m, m.reloadCmd()
 
 	case ReloadFinishedMsg:
 		if msg.Err != nil {
@@ -135,17 +505,315 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.content = msg.Content
 		m.lines = parseLines(msg.Content)
-		m.viewport.SetContent(msg.Content)
-		m, cmd := m.setStatusWithTimeout("Reloaded")
+		m = m.snapCursor()
+		m.viewport.SetContent(m.displayContent())
+		if m.cursorMode {
+			m = m.scrollToCursor()
+		}
+		status := "Reloaded"
+		if m.reloadStatus != "" {
+			status = m.reloadStatus
+			m.reloadStatus = ""
+		}
+		if !m.openLimitChecked {
+			// Once per session, the first load warns about too many open tasks
+			m.openLimitChecked = true
+			if warning := task.OpenLimitMessage(m.openTasks(), m.config.Tasks.OpenLimit); warning != "" {
+				status = warning
+			}
+		}
+		m, cmd := m.setStatusWithTimeout(status)
+		return m, tea.Batch(cmd, m.gitStatusCmd(), m.streakCmd(), m.archivePaneCmd())
+
+	case ArchivePaneMsg:
+		return m.archivePaneLoaded(msg)
+
+	case ConfigChangedMsg:
+		m, cmd := m.configChanged(msg)
+		return m, cmd
+
+	case MidnightMsg:
+		// Filter on the new date; the quick filter keeps the tick going
+		m.midnightPending = false
+		if m.filter == nil {
+			return m, nil
+		}
+		m = m.snapCursor()
+		m.viewport.SetContent(m.displayContent())
+		if m.quickFilter {
+			return m.midnightCmd()
+		}
+		return m, nil
+
+	case GitStatusMsg:
+		m.gitIndicator = gitIndicator(msg)
+		return m, nil
+
+	case StreakMsg:
+		// A failed read keeps the last segment rather than showing a wrong streak
+		if msg.Err == nil {
+			m.streakDay = msg.Day
+			m.streakArchive = msg.Archive
+			m.streakText = formatStreak(msg.Today, msg.Streak)
+		}
+		return m, nil
+
+	case QuitCheckMsg:
+		// Quit unless there is something to lose; a failed check does not block quitting
+		if msg.Err != nil || (!msg.Status.Dirty && msg.Status.Ahead == 0) {
+			return m, tea.Quit
+		}
+		m.quitPending = true
+		m.quitCanSync = msg.Status.HasRemote
+		return m, nil
+
+	case IntegrityCheckMsg:
+		// A failed check (e.g. not a git repository) is not worth interrupting startup for
+		if msg.Err == nil && lostTooManyLines(msg.Lines, msg.Added, msg.Deleted) {
+			m.integrityWarning = "tasks.md lost " + strconv.Itoa(msg.Deleted) + " lines since last commit — press g to view diff"
+		}
+		return m, nil
+
+	case DiffFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Diff error: " + msg.Err.Error())
+			return m, cmd
+		}
+		width, height := m.diffViewSize()
+		m.diffView = viewport.New(width, height)
+		m.diffView.SetContent(renderDiff(msg.Diff))
+		m.showDiff = true
+		return m, nil
+
+	case AutoSyncTickMsg:
+		if m.syncing || time.Since(m.lastKeyPress) < autoSyncIdleWindow {
+			return m, m.autoSyncTickCmd()
+		}
+		return m.startSync()
+
+	case SyncProgressMsg:
+		m.status = msg.Step + "..."
+		return m, waitForSyncEvent(m.syncEvents)
+
+	case SyncFinishedMsg:
+		m.syncing = false
+		if m.syncCancel != nil {
+			m.syncCancel()
+			m.syncCancel = nil
+			m.syncEvents = nil
+		}
+		if m.quitOnSyncStop {
+			return m, tea.Quit
+		}
+		if msg.RebaseHint != "" {
+			// The next auto-sync is scheduled once the prompt is answered
+			m.rebasePending = true
+			m.rebaseHint = msg.RebaseHint
+			return m, nil
+		}
+		if m.quitAfterSync {
+			// s in the quit confirmation: quit only once the changes are pushed
+			m.quitAfterSync = false
+			if msg.Err != nil {
+				m, cmd := m.setStatusWithTimeout("Sync error: " + msg.Err.Error())
+				return m, cmd
+			}
+			return m, tea.Quit
+		}
+		if msg.NoRemote {
+			return m, m.autoSyncTickCmd()
+		}
+		if msg.Err != nil {
+			m.syncFailures++
+			if m.syncFailures >= autoSyncWarnFailures {
+				m.syncWarning = "Auto-sync failing (" + strconv.Itoa(m.syncFailures) + " times)"
+			}
+			m, cmd := m.setStatusWithTimeout("Sync error: " + msg.Err.Error())
+			return m, tea.Batch(cmd, m.autoSyncTickCmd())
+		}
+		m.syncFailures = 0
+		m.syncWarning = ""
+		m.status = "Synced"
+		if msg.Resolved > 0 {
+			m.reloadStatus = "Synced, merged " + strconv.Itoa(msg.Resolved) + " task(s) done on several devices"
+		}
+		if msg.HookErr != nil {
+			m.reloadStatus = "Synced, but post-sync hook failed: " + msg.HookErr.Error()
+		}
+		// Pull may have changed the file; reload and schedule the next sync
+		return m, tea.Batch(m.reloadCmd(), m.autoSyncTickCmd())
+
+	case ToggleChildrenFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Error: " + msg.Err.Error())
+			return m, cmd
+		}
+		if msg.Count == 0 {
+			m, cmd := m.setStatusWithTimeout("No subtasks to toggle")
+			return m, cmd
+		}
+		kind := ActivityReopen
+		if msg.Completed {
+			kind = ActivityComplete
+			m.reloadStatus = "Completed " + strconv.Itoa(msg.Count) + " subtask(s)"
+		} else {
+			m.reloadStatus = "Reopened " + strconv.Itoa(msg.Count) + " subtask(s)"
+		}
+		m = m.recordActivity(Activity{Kind: kind, Count: msg.Count, Text: msg.Task, Line: msg.Line})
+		return m, m.reloadCmd()
+
+	case ArchiveLoadedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Error: " + msg.Err.Error())
+			return m, cmd
+		}
+		if msg.Refresh && !m.archiveMode {
+			// Left the archive view while the refresh was running
+			return m, nil
+		}
+		line := m.archiveFileLine(m.cursor)
+		if !m.archiveMode {
+			m.archiveMode = true
+			m.cursorMode = true
+			m.cursor = 0
+			m.viewport.GotoTop()
+		}
+		m.archiveFiles = msg.Files
+		m.archiveMore = msg.More
+		m = m.sortArchive(msg.Content)
+		if i := slices.Index(m.archiveOrder, line); msg.Refresh && line >= 0 && i >= 0 {
+			// Keep the cursor on the same line once older files are added
+			m.cursor = i
+		}
+		m = m.snapCursor()
+		m.viewport.SetContent(m.displayContent())
+		return m, nil
+
+	case RestoreFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Restore error: " + msg.Err.Error())
+			return m, cmd
+		}
+		m.reloadStatus = "Restored " + strconv.Itoa(msg.Count) + " task(s) to tasks.md"
+		m = m.recordActivity(Activity{Kind: ActivityAdd, Count: msg.Count, Line: -1})
+		m.streakDay = time.Time{}
+		return m, tea.Batch(m.reloadCmd(), m.loadArchiveCmd(true))
+
+	case OpenURLFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Open error: " + msg.Err.Error())
+			return m, cmd
+		}
+		m, cmd := m.setStatusWithTimeout("Opened " + msg.URL)
 		return m, cmd
 
+	case TrackTickMsg:
+		// Ticks of a stopped (or restarted) timer end their chain
+		if !m.tracking || !msg.Start.Equal(m.trackStart) {
+			return m, nil
+		}
+		return m, trackTickCmd(m.trackStart)
+
+	case InlineEditFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Edit error: " + msg.Err.Error())
+			return m, cmd
+		}
+		m.reloadStatus = "Task updated"
+		return m, m.reloadCmd()
+
+	case MoveFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Move error: " + msg.Err.Error())
+			// A failed commit comes after both files were written
+			if msg.Moved {
+				m = m.recordMove(msg)
+				return m, tea.Batch(cmd, m.reloadCmd())
+			}
+			return m, cmd
+		}
+		m.reloadStatus = "Moved to " + msg.Target
+		m = m.recordMove(msg)
+		return m, m.reloadCmd()
+
+	case DeleteFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Delete error: " + msg.Err.Error())
+			return m, cmd
+		}
+		m.yankBuffer = strings.Split(msg.Block, "\n")
+		m.reloadStatus = "Deleted " + strconv.Itoa(len(m.yankBuffer)) + " line(s), p to paste"
+		m = m.recordActivity(Activity{Kind: ActivityDelete, Count: len(m.yankBuffer), Text: task.TaskBody(m.yankBuffer[0], m.taskOpts), Line: msg.Line, Block: msg.Block})
+		return m, m.reloadCmd()
+
+	case ResetFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Reset error: " + msg.Err.Error())
+			return m, cmd
+		}
+		m.reloadStatus = "Reset " + strconv.Itoa(msg.Count) + " task(s) in " + msg.Heading
+		m = m.recordActivity(Activity{Kind: ActivityReopen, Count: msg.Count, Text: msg.Heading, Line: -1})
+		return m, m.reloadCmd()
+
+	case PasteFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Paste error: " + msg.Err.Error())
+			return m, cmd
+		}
+		m.reloadStatus = "Pasted " + strconv.Itoa(msg.Count) + " line(s)"
+		if msg.Clipboard {
+			m.reloadStatus = "Imported " + strconv.Itoa(msg.Count) + " task(s) from the clipboard"
+		}
+		first, _, _ := strings.Cut(msg.Block, "\n")
+		m = m.recordActivity(Activity{Kind: ActivityAdd, Count: msg.Count, Text: task.TaskBody(first, m.taskOpts), Line: msg.Line, Block: msg.Block})
+		return m, m.reloadCmd()
+
+	case PinFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Pin error: " + msg.Err.Error())
+			return m, cmd
+		}
+		m.reloadStatus = "Unpinned"
+		if msg.Pinned {
+			m.reloadStatus = "Pinned"
+		}
+		return m, m.reloadCmd()
+
+	case TrackFinishedMsg:
+		if msg.Err != nil {
+			m, cmd := m.setStatusWithTimeout("Track error: " + msg.Err.Error() + " (" + formatElapsed(msg.Elapsed) + " not recorded)")
+			return m, cmd
+		}
+		m.reloadStatus = "Tracked " + formatElapsed(msg.Elapsed)
+		return m, m.reloadCmd()
+
+	case GuardBlockedMsg:
+		m.editing = false
+		m.quitArchiving = false // the guard prompt cancels an archive-on-quit
+		m.guardPending = msg.Op
+		return m, nil
+
 	case AddDoneTagsFinishedMsg:
 		if msg.Err != nil {
+			m.editing = false
 			m, cmd := m.setStatusWithTimeout("Error: " + msg.Err.Error())
 			return m, cmd
 		}
 		if msg.Count > 0 {
-			m.status = strconv.Itoa(msg.Count) + " task(s) marked as done"
+			m = m.recordActivity(Activity{Kind: ActivityComplete, Count: msg.Count, Line: -1})
+		}
+		if m.editing {
+			m.editing = false
+			added, removed := task.DiffLines(m.preEditContent, msg.Content)
+			m.reloadStatus = editStatus(msg.Count, msg.Escalated, added, removed)
+			if m.editWarning != "" {
+				m.reloadStatus += " (" + m.editWarning + ")"
+				m.editWarning = ""
+			}
+			return m, m.reloadCmd()
+		}
+		if msg.Count > 0 || msg.Escalated > 0 {
+			m.status = processStatus(msg.Count, msg.Escalated)
 			// Reload to show updated content, status will be set with timeout after reload
 			return m, m.reloadCmd()
 		}
@@ -154,12 +822,33 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	m.viewport, cmd = m.viewport.Update(msg)
+	if m.cursorMode {
+		// Whatever else scrolled the view, the cursor stays on screen
+		m = m.clampCursor()
+	}
 	return m, cmd
 }
 
 // handleKeyPress processes key press events.
 func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	key := msg.String()
+	m.lastKeyPress = time.Now()
+
+	// Ctrl+C still quits, but first stops git so no pull or push is left running
+	if key == "ctrl+c" && m.syncCancel != nil {
+		m.syncCancel()
+		m.quitOnSyncStop = true
+		m.status = "Stopping sync..."
+		return m, nil
+	}
+
+	// Only ctrl+c (above, or the fixed keys below) quits without waiting for q's archive
+	if m.quitArchiving {
+		if key == "ctrl+c" {
+			return m, tea.Quit
+		}
+		return m, nil
+	}
 
 	// If help overlay is shown, any key closes it
 	if m.showHelp {
@@ -167,30 +856,171 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	// So does the activity log
+	if m.showActivity {
+		m.showActivity = false
+		return m, nil
+	}
+
+	if m.guardPending != "" {
+		return m.handleGuardKeyPress(key)
+	}
+
+	if m.quitPending {
+		return m.handleQuitKeyPress(key)
+	}
+
+	if m.rebasePending {
+		return m.handleRebaseKeyPress(key)
+	}
+
+	if m.movePending {
+		return m.handleMoveKeyPress(key)
+	}
+
+	if m.resetPending {
+		return m.handleResetKeyPress(key)
+	}
+
+	if m.restorePending {
+		return m.handleRestoreKeyPress(key)
+	}
+
+	if m.showDiff {
+		return m.handleDiffKeyPress(key)
+	}
+
+	// F1 opens the help in every mode, also while typing, where ? is text
+	if key == "f1" {
+		m.showHelp = true
+		return m, nil
+	}
+
+	if m.showDue {
+		return m.handleDueKeyPress(key), nil
+	}
+
+	if m.searching {
+		return m.handleSearchKeyPress(msg)
+	}
+
+	if m.inlineEditing {
+		return m.handleInlineEditKeyPress(msg)
+	}
+
+	if m.splitActive() {
+		if key == "tab" {
+			m.archivePaneFocus = !m.archivePaneFocus
+			return m, nil
+		}
+		if m.archivePaneFocus {
+			if model, ok := m.handleArchivePaneKeyPress(key); ok {
+				return model, nil
+			}
+		}
+	}
+
+	// While the integrity warning is shown, g opens the diff against HEAD instead of Top
+	if key == "g" && m.integrityWarning != "" {
+		m.integrityWarning = ""
+		return m, m.integrityDiffCmd()
+	}
+
+	if m.archiveMode {
+		return m.handleArchiveKeyPress(key)
+	}
+
+	if key == "T" && m.tracking {
+		m.tracking = false
+		m.trackElapsed = time.Since(m.trackStart)
+		return m, m.trackCmd()
+	}
+
+	if m.cursorMode {
+		if model, cmd, ok := m.handleCursorKeyPress(key); ok {
+			return model, cmd
+		}
+	}
+
 	// Fixed keybindings (not configurable)
 	switch key {
-	case "q", "ctrl+c":
+	case "q":
+		return m.quit()
+	case "ctrl+c":
 		return m, tea.Quit
 	case "up":
 		m.viewport.ScrollUp(1)
 	case "down":
 		m.viewport.ScrollDown(1)
 	case "e":
+		m.editing = true
+		m.preEditContent = m.content
+		m.editWarning = ""
+		if cmd := m.pullBeforeEditCmd(); cmd != nil {
+			m.status = "Pulling..."
+			return m, cmd
+		}
 		return m, m.editCmd()
 	case "a":
 		return m, m.archiveCmd()
 	case "r":
 		return m, m.reloadCmd()
+	case "d":
+		return m, m.diffCmd()
 	case "?", "h":
 		m.showHelp = true
 		return m, nil
-	}
-
-	// Configurable keybindings
-	action := m.matchAction(key)
-	switch action {
-	case actionUp:
-		m.viewport.ScrollUp(1)
+	case "v":
+		m.cursorMode = true
+		if rows := m.visibleLines(); m.viewport.YOffset < len(rows) {
+			m.cursor = rows[m.viewport.YOffset]
+		}
+		m = m.snapCursor()
+		m.viewport.SetContent(m.displayContent())
+		return m, nil
+	case "T":
+		return m.toggleQuickFilter()
+	case "f":
+		m, cmd := m.toggleFuture()
+		return m, cmd
+	case "X", "o", "i", "z", "*", "m", "y", "p", "P", "R":
+		m, cmd := m.setStatusWithTimeout("Press v to select a task first")
+		return m, cmd
+	case "A":
+		return m, m.loadArchiveCmd(false)
+	case "L":
+		m.showActivity = true
+		return m, nil
+	case "u":
+		return m.openDueView()
+	case "/":
+		m.searching = true
+		m.searchInput = ""
+		return m, nil
+	case "n", "N":
+		if m.searchQuery == "" {
+			return m, nil
+		}
+		direction := 1
+		if key == "N" {
+			direction = -1
+		}
+		return m.searchJump(direction, false)
+	case "esc":
+		if m.searchQuery != "" {
+			m.searchQuery = ""
+			m.viewport.SetContent(m.displayContent())
+		}
+		return m, nil
+	case "0", "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		return m.applyFilterKey(key)
+	}
+
+	// Configurable keybindings
+	action := m.matchAction(key)
+	switch action {
+	case actionUp:
+		m.viewport.ScrollUp(1)
 	case actionDown:
 		m.viewport.ScrollDown(1)
 	case actionTop:
@@ -201,234 +1031,2973 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.viewport.HalfPageUp()
 	case actionHalfPageDown:
 		m.viewport.HalfPageDown()
+	default:
+		switch key {
+		case "pgup":
+			m.viewport.PageUp()
+		case "pgdown":
+			m.viewport.PageDown()
+		}
+	}
+
+	return m, nil
+}
+
+// editStatus composes the status shown after returning from the editor
+// from the number of tasks tagged @done or escalated and the lines the edit added and removed.
+func editStatus(tagged, escalated, added, removed int) string {
+	var parts []string
+	if added > 0 || removed > 0 {
+		parts = append(parts, "File reloaded ("+strconv.Itoa(added)+" lines added, "+strconv.Itoa(removed)+" removed)")
+	}
+	if tagged > 0 || escalated > 0 {
+		parts = append(parts, processStatus(tagged, escalated))
+	}
+	if len(parts) == 0 {
+		return "No changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// processStatus describes a @done pass: "2 task(s) marked as done, 1 task(s) escalated".
+func processStatus(tagged, escalated int) string {
+	var parts []string
+	if tagged > 0 {
+		parts = append(parts, strconv.Itoa(tagged)+" task(s) marked as done")
+	}
+	if escalated > 0 {
+		parts = append(parts, strconv.Itoa(escalated)+" task(s) escalated")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// handleQuitKeyPress answers the quit confirmation: y quits, s syncs first (when a
+// remote exists), any other key cancels.
+func (m Model) handleQuitKeyPress(key string) (tea.Model, tea.Cmd) {
+	m.quitPending = false
+	switch {
+	case key == "y":
+		return m, tea.Quit
+	case key == "s" && m.quitCanSync:
+		m.quitAfterSync = true
+		m.status = "Syncing..."
+		return m.startSync()
+	}
+	return m, nil
+}
+
+// handleRebaseKeyPress answers the prompt shown when a sync's push was rejected
+// because the remote history diverged: r rebases the local commits onto the remote
+// and pushes again, any other key stops and shows how to do it by hand.
+func (m Model) handleRebaseKeyPress(key string) (tea.Model, tea.Cmd) {
+	m.rebasePending = false
+	if key == "r" {
+		m.status = "Rebasing..."
+		return m.runSync(m.rebaseCmd)
+	}
+	m.quitAfterSync = false
+	m, cmd := m.setStatusWithTimeout("Sync aborted, nothing was pushed; " + m.rebaseHint)
+	return m, tea.Batch(cmd, m.autoSyncTickCmd())
+}
+
+// startMove asks which workspace the selected task moves to: every workspace
+// but the active one, numbered from 1 in the order of WorkspaceNames.
+func (m Model) startMove() (Model, tea.Cmd) {
+	if m.cursor >= len(m.lines) || !task.IsTask(m.lines[m.cursor], m.taskOpts) {
+		return m.setStatusWithTimeout("Not a task")
+	}
+	var targets []string
+	for _, name := range m.config.WorkspaceNames() {
+		if name != m.config.Workspace() {
+			targets = append(targets, name)
+		}
+	}
+	if len(targets) == 0 {
+		return m.setStatusWithTimeout("No other workspace to move to (see [[workspaces]] in config)")
+	}
+	m.movePending = true
+	m.moveTargets = targets
+	return m, nil
+}
+
+// handleMoveKeyPress answers the move prompt: the number of a workspace moves the
+// task there, any other key cancels.
+func (m Model) handleMoveKeyPress(key string) (tea.Model, tea.Cmd) {
+	m.movePending = false
+	n, err := strconv.Atoi(key)
+	if err != nil || n < 1 || n > len(m.moveTargets) {
+		m, cmd := m.setStatusWithTimeout("Cancelled")
+		return m, cmd
+	}
+	m.moveTarget = m.moveTargets[n-1]
+	return m, m.moveCmd()
+}
+
+// movePrompt lists the workspaces a task can move to, e.g. "Move to: 1 home  2 side (other key cancels)".
+// Only the first nine are offered, one key each.
+func movePrompt(targets []string) string {
+	var parts []string
+	for i, name := range targets {
+		if i == 9 {
+			break
+		}
+		parts = append(parts, strconv.Itoa(i+1)+" "+name)
+	}
+	return "Move to: " + strings.Join(parts, "  ") + " (other key cancels)"
+}
+
+// startReset asks whether to reset the ## section whose heading is selected: its
+// completed tasks are unchecked for another round of a reusable checklist.
+func (m Model) startReset() (Model, tea.Cmd) {
+	for _, s := range m.sections() {
+		if s.Line != m.cursor {
+			continue
+		}
+		if s.Tasks == s.Open {
+			return m.setStatusWithTimeout("Nothing to reset in " + s.Heading)
+		}
+		m.resetPending = true
+		m.resetSection = s
+		return m, nil
+	}
+	return m.setStatusWithTimeout("Not a ## heading")
+}
+
+// handleResetKeyPress answers the reset prompt: y resets the section, any other key cancels.
+func (m Model) handleResetKeyPress(key string) (tea.Model, tea.Cmd) {
+	m.resetPending = false
+	if key != "y" {
+		m, cmd := m.setStatusWithTimeout("Cancelled")
+		return m, cmd
+	}
+	return m, m.resetCmd()
+}
+
+// resetPrompt asks to confirm the reset of s, e.g. "Reset 3 checked task(s) in Packing? (y/n)".
+func resetPrompt(s task.Section) string {
+	return fmt.Sprintf("Reset %d checked task(s) in %s? (y/n)", s.Tasks-s.Open, s.Heading)
+}
+
+// handleGuardKeyPress answers the tasks.md guard prompt.
+// "y" runs the pending operation and skips the guard for the rest of the session;
+// any other key cancels it.
+func (m Model) handleGuardKeyPress(key string) (tea.Model, tea.Cmd) {
+	op := m.guardPending
+	m.guardPending = ""
+	if key != "y" {
+		m, cmd := m.setStatusWithTimeout("Cancelled")
+		return m, cmd
+	}
+
+	m.guardConfirmed = true
+	switch op {
+	case guardOpArchive:
+		return m, m.archiveCmd()
+	case guardOpToggleChildren:
+		return m, m.toggleChildrenCmd()
+	case guardOpRestore:
+		return m, m.restoreCmd()
+	case guardOpTrack:
+		return m, m.trackCmd()
+	case guardOpInlineEdit:
+		return m, m.inlineEditCmd()
+	case guardOpPin:
+		return m, m.pinCmd()
+	case guardOpMove:
+		return m, m.moveCmd()
+	case guardOpDelete:
+		return m, m.deleteCmd()
+	case guardOpPaste:
+		return m, m.pasteCmd()
+	case guardOpImport:
+		return m, m.importCmd()
+	case guardOpReset:
+		return m, m.resetCmd()
+	case guardOpEditDoneTags:
+		// Back to the edit flow, so the status reports the lines the editor changed
+		m.editing = true
+		return m, m.addDoneTagsAndReloadCmd()
+	}
+	return m, m.addDoneTagsCmd()
+}
+
+// handleArchiveKeyPress processes keys in the archive view. The cursor moves as in
+// select mode, u restores the selected task, o toggles the section order, and esc or A
+// returns to tasks.md. Keys that modify tasks.md directly (e, a, X) and filters are
+// ignored here.
+func (m Model) handleArchiveKeyPress(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "q":
+		return m.quit()
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "A":
+		m.archiveMode = false
+		m.archiveLines = nil
+		m.archiveContent = ""
+		m.archiveOrder = nil
+		m.archiveFiles = nil
+		m.archiveMore = false
+		m.archiveAll = false
+		m.cursorMode = false
+		m.cursor = 0
+		m.viewport.SetContent(m.displayContent())
+		m.viewport.GotoTop()
+		return m, nil
+	case "?", "h":
+		m.showHelp = true
+		return m, nil
+	case "u":
+		if m.cursor >= len(m.archiveLines) || !task.IsTask(m.archiveLines[m.cursor], m.taskOpts) {
+			m, cmd := m.setStatusWithTimeout("Select an archived task to restore")
+			return m, cmd
+		}
+		m.restorePending = true
+		return m, nil
+	case "o":
+		// Keep the cursor on the same line of archive.md
+		line := m.archiveFileLine(m.cursor)
+		m.archiveAscending = !m.archiveAscending
+		m = m.sortArchive(m.archiveContent)
+		if i := slices.Index(m.archiveOrder, line); line >= 0 && i >= 0 {
+			m.cursor = i
+		}
+		m = m.moveCursor(0)
+		if m.archiveAscending {
+			// The oldest sections come first, so they are needed now
+			return m.loadAllArchives(nil)
+		}
+		return m, nil
+	case "v", "X":
+		return m, nil
+	}
+
+	if model, cmd, ok := m.handleCursorKeyPress(key); ok {
+		if m, ok := model.(Model); ok && m.archiveMode && m.viewport.AtBottom() {
+			return m.loadAllArchives(cmd)
+		}
+		return model, cmd
+	}
+	return m, nil
+}
+
+// loadAllArchives loads the older monthly archive files left out of the archive view,
+// if any, along with cmd.
+func (m Model) loadAllArchives(cmd tea.Cmd) (tea.Model, tea.Cmd) {
+	if !m.archiveMore || m.archiveAll {
+		return m, cmd
+	}
+	m.archiveAll = true
+	return m, tea.Batch(cmd, m.loadArchiveCmd(true))
+}
+
+// sortArchive shows content as the archive view, with its date sections in the
+// order archiveAscending selects.
+func (m Model) sortArchive(content string) Model {
+	m.archiveContent = content
+	m.archiveLines = parseLines(task.SortArchiveSections(content, m.archiveAscending))
+	m.archiveOrder = task.ArchiveSectionOrder(content, m.archiveAscending)
+	return m
+}
+
+// archiveFileLine returns the line of archive.md shown at row n of the archive view,
+// or -1 for a blank line added between sections.
+func (m Model) archiveFileLine(n int) int {
+	if n < 0 || n >= len(m.archiveOrder) {
+		return -1
+	}
+	return m.archiveOrder[n]
+}
+
+// handleRestoreKeyPress answers the restore prompt: k keeps the task completed
+// with its @done tag, o reopens it; any other key cancels.
+func (m Model) handleRestoreKeyPress(key string) (tea.Model, tea.Cmd) {
+	m.restorePending = false
+	switch key {
+	case "k":
+		m.restoreKeepDone = true
+	case "o":
+		m.restoreKeepDone = false
+	default:
+		m, cmd := m.setStatusWithTimeout("Cancelled")
+		return m, cmd
+	}
+	return m, m.restoreCmd()
+}
+
+// openDueView shows the open tasks of tasks.md sorted by @due date, each prefixed
+// with the days left or overdue (see task.SortByDue).
+func (m Model) openDueView() (Model, tea.Cmd) {
+	content := m.dueContent(time.Now())
+	if content == "" {
+		return m.setStatusWithTimeout("No open tasks")
+	}
+	m.dueView = viewport.New(m.viewport.Width, m.viewport.Height)
+	m.dueView.SetContent(content)
+	m.showDue = true
+	return m, nil
+}
+
+// dueContent renders the due-date view: the lines of task.SortByDue, with the task
+// text after the label colored as in the main view.
+func (m Model) dueContent(now time.Time) string {
+	var rows []string
+	for _, line := range task.SortByDue(task.ParseLines(m.content, m.taskOpts), now) {
+		text := strings.TrimLeft(m.lines[line.LineNumber], " \t")
+		label := strings.TrimSuffix(line.Content, text) // indentation and label
+		rows = append(rows, label+m.renderLine(text, false))
+	}
+	return strings.Join(rows, "\n")
+}
+
+// handleDueKeyPress scrolls the due-date view with the scrolling keys and closes it
+// on any other key.
+func (m Model) handleDueKeyPress(key string) Model {
+	switch key {
+	case "up":
+		m.dueView.ScrollUp(1)
+		return m
+	case "down":
+		m.dueView.ScrollDown(1)
+		return m
+	}
+	switch m.matchAction(key) {
+	case actionUp:
+		m.dueView.ScrollUp(1)
+	case actionDown:
+		m.dueView.ScrollDown(1)
+	case actionTop:
+		m.dueView.GotoTop()
+	case actionBottom:
+		m.dueView.GotoBottom()
+	case actionHalfPageUp:
+		m.dueView.HalfPageUp()
+	case actionHalfPageDown:
+		m.dueView.HalfPageDown()
+	default:
+		m.showDue = false
+	}
+	return m
+}
+
+// quickFilterName is the footer name of the T quick filter.
+const quickFilterName = "done today"
+
+// quickView is the view the T quick filter replaced: its filter and scroll position.
+type quickView struct {
+	filter     *task.Query
+	filterName string
+	offset     int
+}
+
+// toggleQuickFilter shows only the tasks completed today, with the tasks they are
+// nested under (see task.DoneTodayQuery), or returns to the view shown before.
+func (m Model) toggleQuickFilter() (tea.Model, tea.Cmd) {
+	if m.quickFilter {
+		m.quickFilter = false
+		m.filter = m.quickReturn.filter
+		m.filterName = m.quickReturn.filterName
+		m.viewport.SetContent(m.displayContent())
+		m.viewport.SetYOffset(m.quickReturn.offset)
+		m.quickReturn = quickView{}
+		return m, nil
+	}
+
+	q, err := task.CompileQuery(task.DoneTodayQuery, m.taskOpts)
+	if err != nil {
+		return m.setStatusWithTimeout("Filter error: " + err.Error())
+	}
+	q = q.WithParents()
+	if len(q.FilterLines(m.content, time.Now())) == 0 {
+		return m.setStatusWithTimeout("No tasks completed today")
+	}
+	m.quickReturn = quickView{filter: m.filter, filterName: m.filterName, offset: m.viewport.YOffset}
+	m.quickFilter = true
+	m.filter = q
+	m.filterName = quickFilterName
+	m.viewport.SetContent(m.displayContent())
+	m.viewport.GotoTop()
+	return m.midnightCmd()
+}
+
+// MidnightMsg is sent when the date changes, so that filters on today's date
+// (due:today, done:today, the T quick filter) are applied for the new day.
+type MidnightMsg struct{}
+
+// midnightCmd schedules a MidnightMsg for the next midnight, unless one is pending.
+func (m Model) midnightCmd() (Model, tea.Cmd) {
+	if m.midnightPending {
+		return m, nil
+	}
+	m.midnightPending = true
+	now := time.Now()
+	next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	return m, tea.Tick(next.Sub(now), func(time.Time) tea.Msg { return MidnightMsg{} })
+}
+
+// applyFilterKey applies the saved filter bound to key, or clears the filter for "0".
+// Keys without a configured filter are ignored.
+func (m Model) applyFilterKey(key string) (tea.Model, tea.Cmd) {
+	if key == "0" {
+		m.quickFilter = false
+		m.filter = nil
+		m.filterName = ""
+		m.viewport.SetContent(m.displayContent())
+		m.viewport.GotoTop()
+		return m, nil
+	}
+
+	for _, f := range m.config.UI.Filters {
+		if f.Key != key {
+			continue
+		}
+		q, err := task.CompileQuery(f.Query, m.taskOpts)
+		if err != nil {
+			return m.setStatusWithTimeout("Filter error: " + err.Error())
+		}
+		m.filter = q
+		m.filterName = f.Name
+		m.quickFilter = false
+		m.viewport.SetContent(m.displayContent())
+		m.viewport.GotoTop()
+		return m, nil
+	}
+
+	return m, nil
+}
+
+// handleSearchKeyPress edits the search query typed after "/".
+// Enter highlights the query and jumps to the first match; esc cancels.
+func (m Model) handleSearchKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.searching = false
+		m.searchInput = ""
+		return m, nil
+	case tea.KeyEnter:
+		m.searching = false
+		m.searchQuery = m.searchInput
+		m.searchInput = ""
+		m.viewport.SetContent(m.displayContent())
+		if m.searchQuery == "" {
+			return m, nil
+		}
+		return m.searchJump(1, true)
+	case tea.KeyBackspace:
+		if r := []rune(m.searchInput); len(r) > 0 {
+			m.searchInput = string(r[:len(r)-1])
+		}
+		return m, nil
+	case tea.KeySpace:
+		m.searchInput += " "
+		return m, nil
+	case tea.KeyRunes:
+		m.searchInput += string(msg.Runes)
+		return m, nil
+	}
+	return m, nil
+}
+
+// searchJump moves to the next (direction 1) or previous (-1) visible line matching
+// the search query, wrapping around. The search starts from the cursor in select mode
+// and from the top of the screen otherwise; inclusive also accepts that line itself.
+func (m Model) searchJump(direction int, inclusive bool) (tea.Model, tea.Cmd) {
+	rows := m.visibleLines()
+	lines := m.shownLines()
+	if len(rows) == 0 {
+		return m.setStatusWithTimeout("Not found: " + m.searchQuery)
+	}
+
+	start := m.viewport.YOffset
+	if m.cursorMode {
+		if row := m.cursorRow(rows); row >= 0 {
+			start = row
+		}
+	}
+	if !inclusive {
+		start += direction
+	}
+
+	opts := m.config.SearchOptions()
+	for i := 0; i < len(rows); i++ {
+		row := ((start+i*direction)%len(rows) + len(rows)) % len(rows)
+		if len(task.FindMatches(lines[rows[row]], m.searchQuery, opts)) == 0 {
+			continue
+		}
+		if m.cursorMode {
+			m.cursor = rows[row]
+			return m.moveCursor(0), nil
+		}
+		m.viewport.SetYOffset(row)
+		return m, nil
+	}
+	return m.setStatusWithTimeout("Not found: " + m.searchQuery)
+}
+
+// handleCursorKeyPress processes keys that behave differently in select mode.
+// Navigation keys move the cursor instead of scrolling, and the viewport follows it.
+// Returns ok=false for keys that fall through to the normal bindings.
+func (m Model) handleCursorKeyPress(key string) (tea.Model, tea.Cmd, bool) {
+	switch key {
+	case "esc", "v":
+		m.cursorMode = false
+		m.viewport.SetContent(m.displayContent())
+		return m, nil, true
+	case "X":
+		return m, m.toggleChildrenCmd(), true
+	case "o":
+		model, cmd := m.openLink()
+		return model, cmd, true
+	case "T":
+		model, cmd := m.startTracking()
+		return model, cmd, true
+	case "i":
+		model, cmd := m.startInlineEdit()
+		return model, cmd, true
+	case "z":
+		model, cmd := m.toggleSection()
+		return model, cmd, true
+	case "m":
+		model, cmd := m.startMove()
+		return model, cmd, true
+	case "R":
+		model, cmd := m.startReset()
+		return model, cmd, true
+	case "*":
+		if m.cursor >= len(m.lines) || !task.IsTask(m.lines[m.cursor], m.taskOpts) {
+			model, cmd := m.setStatusWithTimeout("Not a task")
+			return model, cmd, true
+		}
+		return m, m.pinCmd(), true
+	case "d":
+		if m.cursor >= len(m.lines) || !task.IsTask(m.lines[m.cursor], m.taskOpts) {
+			model, cmd := m.setStatusWithTimeout("Not a task")
+			return model, cmd, true
+		}
+		return m, m.deleteCmd(), true
+	case "y":
+		model, cmd := m.yank()
+		return model, cmd, true
+	case "u", "ctrl+r":
+		model, cmd := m.undo(key == "ctrl+r")
+		return model, cmd, true
+	case "p":
+		if len(m.yankBuffer) == 0 {
+			model, cmd := m.setStatusWithTimeout("Nothing to paste (d or y a task first)")
+			return model, cmd, true
+		}
+		return m, m.pasteCmd(), true
+	case "P":
+		return m, m.importCmd(), true
+	case "up":
+		return m.moveCursor(-1), nil, true
+	case "down":
+		return m.moveCursor(1), nil, true
+	case ".":
+		return m.centerCursor(), nil, true
+	}
+
+	half := m.viewport.Height / 2
+	if half < 1 {
+		half = 1
+	}
+	switch m.matchAction(key) {
+	case actionUp:
+		return m.moveCursor(-1), nil, true
+	case actionDown:
+		return m.moveCursor(1), nil, true
+	case actionTop:
+		return m.moveCursor(-len(m.shownLines())), nil, true
+	case actionBottom:
+		return m.moveCursor(len(m.shownLines())), nil, true
+	case actionHalfPageUp:
+		return m.moveCursor(-half), nil, true
+	case actionHalfPageDown:
+		return m.moveCursor(half), nil, true
+	}
+
+	// Page keys scroll the view rather than the cursor, which then stays on screen
+	switch key {
+	case "pgup":
+		m.viewport.PageUp()
+		return m.clampCursor(), nil, true
+	case "pgdown":
+		m.viewport.PageDown()
+		return m.clampCursor(), nil, true
+	}
+
+	return m, nil, false
+}
+
+// yank copies the selected task and the lines nested under it (see
+// task.ExtractSubtree) into the yank buffer, leaving tasks.md unchanged.
+func (m Model) yank() (Model, tea.Cmd) {
+	block, _, err := task.ExtractSubtreeWith(m.content, m.cursor, m.taskOpts)
+	if err != nil {
+		return m.setStatusWithTimeout("Not a task")
+	}
+	m.yankBuffer = strings.Split(block, "\n")
+	return m.setStatusWithTimeout("Yanked " + strconv.Itoa(len(m.yankBuffer)) + " line(s), p to paste")
+}
+
+// startInlineEdit loads the text of the selected task (see task.TaskBody) into the
+// footer input, with the cursor at the end.
+func (m Model) startInlineEdit() (Model, tea.Cmd) {
+	if m.cursor >= len(m.lines) || !task.IsTask(m.lines[m.cursor], m.taskOpts) {
+		return m.setStatusWithTimeout("Not a task")
+	}
+	m.inlineEditing = true
+	m.inlineLine = m.cursor
+	m.inlineText = m.lines[m.cursor]
+	m.inlineInput = newInlineInput(task.TaskBody(m.inlineText, m.taskOpts))
+	m.inlineTags = task.FileTags(m.content, m.taskOpts)
+	m.inlineChoice = 0
+	return m, nil
+}
+
+// newInlineInput returns the footer input of the inline edit holding text, with the
+// cursor at the end. The cursor does not blink, so the footer only changes on a key.
+func newInlineInput(text string) textinput.Model {
+	input := textinput.New()
+	input.Prompt = "edit: "
+	input.Cursor.SetMode(cursor.CursorStatic)
+	input.Focus()
+	input.SetValue(text)
+	input.CursorEnd()
+	return input
+}
+
+// handleInlineEditKeyPress edits the task text in the footer. Enter writes it back
+// to tasks.md, Esc cancels; other keys go to the input (see textinput.KeyMap).
+// While a tag is being typed, ↑/↓ choose among its suggestions (see tagSuggestions)
+// and Tab completes it.
+func (m Model) handleInlineEditKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyTab:
+		return m.completeTag(), nil
+	case tea.KeyUp:
+		if m.inlineChoice > 0 {
+			m.inlineChoice--
+		}
+		return m, nil
+	case tea.KeyDown:
+		if m.inlineChoice < len(m.tagSuggestions())-1 {
+			m.inlineChoice++
+		}
+		return m, nil
+	}
+
+	// Any other key changes the input, so the suggestions start over
+	m.inlineChoice = 0
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.inlineEditing = false
+		return m, nil
+	case tea.KeyEnter:
+		m.inlineEditing = false
+		body := strings.TrimSpace(m.inlineInput.Value())
+		if body == "" {
+			m, cmd := m.setStatusWithTimeout("Task text cannot be empty")
+			return m, cmd
+		}
+		if body == task.TaskBody(m.inlineText, m.taskOpts) {
+			m, cmd := m.setStatusWithTimeout("No changes")
+			return m, cmd
+		}
+		return m, m.inlineEditCmd()
+	}
+	var cmd tea.Cmd
+	m.inlineInput, cmd = m.inlineInput.Update(msg)
+	return m, cmd
+}
+
+// inlineEditView renders the inline edit input followed by the tag suggestions with
+// the highlighted one in reverse video.
+func (m Model) inlineEditView() string {
+	reverse := lipgloss.NewStyle().Reverse(true)
+	view := m.inlineInput.View()
+
+	suggestions := m.tagSuggestions()
+	if len(suggestions) == 0 {
+		return view
+	}
+	for i, tag := range suggestions {
+		if i == m.inlineChoice {
+			suggestions[i] = reverse.Render(tag)
+		}
+	}
+	return view + "  [" + strings.Join(suggestions, " ") + "]"
+}
+
+// tagPrefix returns the start (in runes) and text of the tag being typed before the
+// inline edit cursor: a word starting with "@" or "#" that has no "(" yet.
+// Reports false when the cursor is not at the end of such a word.
+func (m Model) tagPrefix() (int, string, bool) {
+	input := []rune(m.inlineInput.Value())
+	pos := m.inlineInput.Position()
+	start := pos
+	for start > 0 && !unicode.IsSpace(input[start-1]) {
+		start--
+	}
+	word := string(input[start:pos])
+	if word == "" || (word[0] != '@' && word[0] != '#') || strings.Contains(word, "(") {
+		return 0, "", false
+	}
+	return start, word, true
+}
+
+// tagSuggestions returns the completions of the tag being typed: the known tags
+// (see task.SuggestTags), then the other tags already used in the file.
+func (m Model) tagSuggestions() []string {
+	_, prefix, ok := m.tagPrefix()
+	if !ok {
+		return nil
+	}
+	suggestions := task.SuggestTags(prefix)
+	for _, tag := range m.inlineTags {
+		if strings.HasPrefix(tag, prefix) && tag != prefix && !slices.Contains(suggestions, tag) {
+			suggestions = append(suggestions, tag)
+		}
+	}
+	return suggestions
+}
+
+// completeTag replaces the tag being typed with the highlighted suggestion.
+// Without suggestions the input is left unchanged.
+func (m Model) completeTag() Model {
+	suggestions := m.tagSuggestions()
+	if len(suggestions) == 0 {
+		return m
+	}
+	start, _, _ := m.tagPrefix()
+	tag := []rune(suggestions[min(m.inlineChoice, len(suggestions)-1)])
+	input := []rune(m.inlineInput.Value())
+	m.inlineInput.SetValue(string(slices.Concat(input[:start], tag, input[m.inlineInput.Position():])))
+	m.inlineInput.SetCursor(start + len(tag))
+	m.inlineChoice = 0
+	return m
+}
+
+// startTracking starts the timer on the selected task.
+func (m Model) startTracking() (Model, tea.Cmd) {
+	if m.cursor >= len(m.lines) || !task.IsTask(m.lines[m.cursor], m.taskOpts) {
+		return m.setStatusWithTimeout("Not a task")
+	}
+	m.tracking = true
+	m.trackLine = m.cursor
+	m.trackText = m.lines[m.cursor]
+	m.trackStart = time.Now()
+	return m, trackTickCmd(m.trackStart)
+}
+
+// shownLines returns the lines of the file on screen: archive.md in the archive view,
+// tasks.md otherwise.
+func (m Model) shownLines() []string {
+	if m.archiveMode {
+		return m.archiveLines
+	}
+	return m.lines
+}
+
+// openLink opens the first link (URL or [ui.link_patterns] match) on the selected line.
+func (m Model) openLink() (Model, tea.Cmd) {
+	lines := m.shownLines()
+	if m.cursor < len(lines) {
+		if links := task.FindLinks(lines[m.cursor], m.links); len(links) > 0 {
+			return m, openURLCmd(links[0].URL)
+		}
+	}
+	return m.setStatusWithTimeout("No link on this line")
+}
+
+// toggleSection folds the "## " section whose heading is selected, or unfolds it
+// if it is folded.
+func (m Model) toggleSection() (Model, tea.Cmd) {
+	for _, s := range m.sections() {
+		if s.Line != m.cursor {
+			continue
+		}
+		if m.collapsed[s.Heading] {
+			delete(m.collapsed, s.Heading)
+		} else {
+			if m.collapsed == nil {
+				m.collapsed = make(map[string]bool)
+			}
+			m.collapsed[s.Heading] = true
+		}
+		return m.moveCursor(0), nil
+	}
+	return m.setStatusWithTimeout("Not a ## heading")
+}
+
+// foldedSections returns the folded sections of tasks.md by heading line.
+func (m Model) foldedSections() map[int]task.Section {
+	if len(m.collapsed) == 0 || m.archiveMode {
+		return nil
+	}
+	folded := make(map[int]task.Section)
+	for _, s := range m.sections() {
+		if m.collapsed[s.Heading] {
+			folded[s.Line] = s
+		}
+	}
+	return folded
+}
+
+// sections returns the "## " sections of tasks.md (see task.Sections). They are
+// computed once per content, so redraws and cursor moves reuse the counts.
+func (m Model) sections() []task.Section {
+	if m.render == nil {
+		return task.Sections(m.content, m.taskOpts)
+	}
+	if m.render.sectionsKey != m.content {
+		m.render.sectionsKey = m.content
+		m.render.sections = task.Sections(m.content, m.taskOpts)
+	}
+	return m.render.sections
+}
+
+// openTasks returns the number of open tasks in tasks.md (see task.CountOpenTasks),
+// counted once per content.
+func (m Model) openTasks() int {
+	if m.render == nil {
+		return task.CountOpenTasks(m.content, m.taskOpts)
+	}
+	if m.render.openKey != m.content {
+		m.render.openKey = m.content
+		m.render.open = task.CountOpenTasks(m.content, m.taskOpts)
+	}
+	return m.render.open
+}
+
+// progressSections returns the sections whose heading gets a "(done/total)" suffix
+// by heading line: sections with tasks, when ui.show_progress is set. The archive
+// view has none.
+func (m Model) progressSections() map[int]task.Section {
+	if !m.config.UI.ShowProgress || m.archiveMode {
+		return nil
+	}
+	progress := make(map[int]task.Section)
+	for _, s := range m.sections() {
+		if s.Tasks > 0 {
+			progress[s.Line] = s
+		}
+	}
+	return progress
+}
+
+// visibleLines returns the line numbers shown in the viewport, in display order.
+// Saved filters, folded sections, display.completed_to_bottom, and ui.pinned_first
+// apply to tasks.md only; pinned tasks go to the top after completed ones move down.
+func (m Model) visibleLines() []int {
+	rows := m.unfoldedLines()
+	pinned := m.config.UI.PinnedFirst == config.PinnedFirstView || m.config.UI.PinnedFirst == config.PinnedFirstFile
+	if m.archiveMode || (!pinned && !m.config.Display.CompletedToBottom) {
+		return rows
+	}
+	parsed := task.ParseLines(m.content, m.taskOpts)
+	shown := make([]task.ParsedLine, 0, len(rows))
+	for _, n := range rows {
+		shown = append(shown, parsed[n])
+	}
+	if m.config.Display.CompletedToBottom {
+		shown = task.PartitionByCompletion(shown)
+	}
+	if pinned {
+		shown = task.PinnedFirstWith(shown, m.taskOpts)
+	}
+	for i, line := range shown {
+		rows[i] = line.LineNumber
+	}
+	return rows
+}
+
+// unfoldedLines returns the line numbers left by the saved filter, hidden future
+// tasks, and folded sections, in file order.
+func (m Model) unfoldedLines() []int {
+	var rows []int
+	if m.filter != nil && !m.archiveMode {
+		rows = m.filter.FilterLines(m.content, time.Now())
+	} else {
+		rows = make([]int, len(m.shownLines()))
+		for i := range rows {
+			rows[i] = i
+		}
+	}
+	if future, _ := m.futureLines(); len(future) > 0 && !m.showFuture {
+		shown := rows[:0]
+		for _, n := range rows {
+			if !future[n] {
+				shown = append(shown, n)
+			}
+		}
+		rows = shown
+	}
+
+	folded := m.foldedSections()
+	if len(folded) == 0 {
+		return rows
+	}
+	shown := rows[:0]
+	end := -1 // lines before end belong to a folded section
+	for _, n := range rows {
+		if s, ok := folded[n]; ok {
+			end = s.End
+		} else if n < end {
+			continue
+		}
+		shown = append(shown, n)
+	}
+	return shown
+}
+
+// futureLines returns the lines display.hide_future hides and how many tasks they
+// hold: the open tasks of tasks.md starting after today, with their subtasks (see
+// task.FutureLines). They are computed once per content and day.
+func (m Model) futureLines() (map[int]bool, int) {
+	if !m.config.Display.HideFuture || m.archiveMode {
+		return nil, 0
+	}
+	now := time.Now()
+	if m.render == nil {
+		return task.FutureLines(m.content, now, m.taskOpts)
+	}
+	key := estimateKey{day: now.Format("2006-01-02"), content: m.content}
+	if m.render.future == nil || m.render.futureKey != key {
+		m.render.futureKey = key
+		m.render.future, m.render.futureTasks = task.FutureLines(m.content, now, m.taskOpts)
+	}
+	return m.render.future, m.render.futureTasks
+}
+
+// toggleFuture shows or hides again the future tasks display.hide_future hides.
+func (m Model) toggleFuture() (Model, tea.Cmd) {
+	if !m.config.Display.HideFuture {
+		return m.setStatusWithTimeout("Future tasks are shown (display.hide_future is off)")
+	}
+	m.showFuture = !m.showFuture
+	m = m.snapCursor()
+	m.viewport.SetContent(m.displayContent())
+	if m.cursorMode {
+		m = m.scrollToCursor()
+	}
+	if m.showFuture {
+		return m.setStatusWithTimeout("Showing future tasks")
+	}
+	return m.setStatusWithTimeout("Hiding future tasks")
+}
+
+// sectionProgress is appended to the heading of an unfolded section, e.g. " (3/7)"
+// for 3 of 7 tasks completed.
+func sectionProgress(s task.Section) string {
+	return " (" + itoa(s.Tasks-s.Open) + "/" + itoa(s.Tasks) + ")"
+}
+
+// sectionSummary is appended to the heading of a folded section, e.g. " (12 tasks, 3 open) ▸".
+func sectionSummary(s task.Section) string {
+	tasks := itoa(s.Tasks) + " tasks"
+	if s.Tasks == 1 {
+		tasks = "1 task"
+	}
+	return " (" + tasks + ", " + itoa(s.Open) + " open) ▸"
+}
+
+// cursorRow returns the display row of the cursor, or -1 if its line is not shown.
+func (m Model) cursorRow(rows []int) int {
+	for i, n := range rows {
+		if n == m.cursor {
+			return i
+		}
+	}
+	return -1
+}
+
+// snapCursor moves the cursor onto a visible line after the content or filter changed:
+// the first visible line at or after the old position, or the last one.
+func (m Model) snapCursor() Model {
+	rows := m.visibleLines()
+	if len(rows) == 0 {
+		m.cursor = 0
+		return m
+	}
+	// Pinned tasks are shown out of file order, so first check the cursor itself
+	if m.cursorRow(rows) >= 0 {
+		return m
+	}
+	for _, n := range rows {
+		if n >= m.cursor {
+			m.cursor = n
+			return m
+		}
+	}
+	m.cursor = rows[len(rows)-1]
+	return m
+}
+
+// moveCursor moves the cursor by delta visible rows (clamped) and scrolls
+// the viewport just enough to keep it on screen.
+func (m Model) moveCursor(delta int) Model {
+	rows := m.visibleLines()
+	if len(rows) == 0 {
+		return m
+	}
+
+	row := m.cursorRow(rows)
+	if row < 0 {
+		row = 0
+	}
+	row += delta
+	if row < 0 {
+		row = 0
+	}
+	if row >= len(rows) {
+		row = len(rows) - 1
+	}
+	m.cursor = rows[row]
+
+	m.viewport.SetContent(m.displayContent())
+	return m.scrollToCursor()
+}
+
+// scrollToCursor scrolls the viewport just enough to show the cursor's row.
+func (m Model) scrollToCursor() Model {
+	row := m.cursorRow(m.visibleLines())
+	if row < 0 {
+		return m
+	}
+	if row < m.viewport.YOffset {
+		m.viewport.SetYOffset(row)
+	} else if m.viewport.Height > 0 && row >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(row - m.viewport.Height + 1)
+	}
+	return m
+}
+
+// clampCursor keeps the cursor on screen after the view scrolled without it: a
+// cursor that scrolled out moves to the nearest visible row, the first or the last.
+func (m Model) clampCursor() Model {
+	rows := m.visibleLines()
+	row := m.cursorRow(rows)
+	if len(rows) == 0 || row < 0 || m.viewport.Height <= 0 {
+		return m
+	}
+	top := m.viewport.YOffset
+	bottom := min(top+m.viewport.Height, len(rows)) - 1
+	switch {
+	case row < top:
+		row = top
+	case row > bottom:
+		row = bottom
+	default:
+		return m
+	}
+	m.cursor = rows[row]
+	m.viewport.SetContent(m.displayContent())
+	return m
+}
+
+// centerCursor scrolls the viewport so that the cursor's row is in the middle,
+// as far as the content allows (vim's zz).
+func (m Model) centerCursor() Model {
+	row := m.cursorRow(m.visibleLines())
+	if row < 0 {
+		return m
+	}
+	m.viewport.SetYOffset(row - m.viewport.Height/2)
+	return m
+}
+
+// handleDiffKeyPress processes key presses while the diff overlay is shown.
+// Scroll keys move the diff; q, esc, and d close it.
+func (m Model) handleDiffKeyPress(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "q", "esc", "d":
+		m.showDiff = false
+		return m, nil
+	case "up":
+		m.diffView.ScrollUp(1)
+		return m, nil
+	case "down":
+		m.diffView.ScrollDown(1)
+		return m, nil
+	}
+
+	switch m.matchAction(key) {
+	case actionUp:
+		m.diffView.ScrollUp(1)
+	case actionDown:
+		m.diffView.ScrollDown(1)
+	case actionTop:
+		m.diffView.GotoTop()
+	case actionBottom:
+		m.diffView.GotoBottom()
+	case actionHalfPageUp:
+		m.diffView.HalfPageUp()
+	case actionHalfPageDown:
+		m.diffView.HalfPageDown()
+	}
+
+	return m, nil
+}
+
+// action represents a keybinding action.
+type action int
+
+const (
+	actionNone action = iota
+	actionUp
+	actionDown
+	actionTop
+	actionBottom
+	actionHalfPageUp
+	actionHalfPageDown
+)
+
+// matchAction returns the action for the pressed key.
+func (m Model) matchAction(key string) action {
+	switch {
+	case m.matchKey(key, m.config.Keybindings.Up):
+		return actionUp
+	case m.matchKey(key, m.config.Keybindings.Down):
+		return actionDown
+	case m.matchKey(key, m.config.Keybindings.Top):
+		return actionTop
+	case m.matchKey(key, m.config.Keybindings.Bottom):
+		return actionBottom
+	case m.matchKey(key, m.config.Keybindings.HalfPageUp):
+		return actionHalfPageUp
+	case m.matchKey(key, m.config.Keybindings.HalfPageDown):
+		return actionHalfPageDown
+	default:
+		return actionNone
+	}
+}
+
+// matchKey checks if the pressed key matches any of the configured keys.
+// Configured keys are compared in normalized form (see config.NormalizeKey).
+func (m Model) matchKey(pressed string, configured []string) bool {
+	for _, k := range configured {
+		if normalized, err := config.NormalizeKey(k); err == nil {
+			k = normalized
+		}
+		if pressed == k {
+			return true
+		}
+	}
+	return false
+}
+
+// View renders the UI.
+func (m Model) View() string {
+	if !m.ready {
+		return "Initializing..."
+	}
+
+	if m.tooSmall {
+		return m.tooSmallView()
+	}
+
+	var base string
+	if m.showDue {
+		base = m.dueView.View()
+	} else if m.splitActive() {
+		base = m.splitView(m.mainView())
+	} else {
+		base = m.mainView()
+	}
+	base += "\n" + m.footerView()
+
+	if m.showHelp {
+		return m.overlayHelp(base)
+	}
+
+	if m.showDiff {
+		return m.overlayDiff(base)
+	}
+
+	if m.showActivity {
+		return m.overlayActivity(base)
+	}
+
+	return base
+}
+
+// mainView renders the viewport, with the scrollbar when it is on. The output is
+// the same as viewport.View, but built from the rows cached by displayContent: only
+// the rows in view are cut and padded, and none is measured or restyled again, so
+// holding down a scroll key stays cheap on long files. Falls back to viewport.View
+// when the cache does not hold the viewport content.
+func (m Model) mainView() string {
+	cache := m.render
+	w, h := m.viewport.Width, m.viewport.Height
+	if cache == nil || len(cache.rows) == 0 || len(cache.rows) != m.viewport.TotalLineCount() || w <= 0 || h <= 0 {
+		view := m.viewport.View()
+		if m.config.UI.Scrollbar {
+			view = m.withScrollbar(view)
+		}
+		return view
+	}
+
+	var start, size int
+	if m.config.UI.Scrollbar {
+		start, size = scrollbarThumb(h, len(cache.rows), m.viewport.YOffset)
+		if cache.track == "" {
+			cache.track = lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("│")
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < h; i++ {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		width := 0
+		if n := m.viewport.YOffset + i; n >= 0 && n < len(cache.rows) {
+			row := cache.rows[n]
+			width = cache.rowWidths[n]
+			if width > w {
+				row = ansi.Cut(row, 0, w)
+				width = ansi.StringWidth(row)
+			}
+			b.WriteString(row)
+		}
+		b.WriteString(strings.Repeat(" ", max(0, w-width)))
+		if m.config.UI.Scrollbar {
+			if i >= start && i < start+size {
+				b.WriteString("█")
+			} else {
+				b.WriteString(cache.track)
+			}
+		}
+	}
+	return b.String()
+}
+
+// withScrollbar appends a one-column scrollbar to each line of the viewport output.
+// The viewport is one column narrower when the scrollbar is on, so lines come padded to fit.
+func (m Model) withScrollbar(view string) string {
+	lines := strings.Split(view, "\n")
+	start, size := scrollbarThumb(len(lines), m.viewport.TotalLineCount(), m.viewport.YOffset)
+	track := lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("│")
+	for i := range lines {
+		if i >= start && i < start+size {
+			lines[i] += "█"
+		} else {
+			lines[i] += track
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// scrollbarThumb returns the first row and the number of rows of the scrollbar thumb
+// in a track of height rows, for total content lines scrolled down by offset.
+// The thumb is at least one row and fills the track when everything fits.
+func scrollbarThumb(height, total, offset int) (start, size int) {
+	if height <= 0 {
+		return 0, 0
+	}
+	if total <= height {
+		return 0, height
+	}
+
+	size = (height*height + total/2) / total
+	if size < 1 {
+		size = 1
+	}
+	maxOffset := total - height
+	if offset > maxOffset {
+		offset = maxOffset
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	start = (offset*(height-size) + maxOffset/2) / maxOffset
+	return start, size
+}
+
+// tooSmallView renders tooSmallMessage centered in the window, cut to the window width.
+// The model state is untouched, so the normal view comes back once the window grows.
+func (m Model) tooSmallView() string {
+	if m.width <= 0 || m.height <= 0 {
+		return ""
+	}
+	message := tooSmallMessage
+	if lipgloss.Width(message) > m.width {
+		message = truncateByDisplayWidth(message, m.width)
+	}
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, message)
+}
+
+// footerView renders the footer bar.
+func (m Model) footerView() string {
+	style := lipgloss.NewStyle().
+		Background(lipgloss.Color("240")).
+		Foreground(lipgloss.Color("252")).
+		Width(m.width)
+
+	// The archive pane's position replaces the tasks.md segments while it has the focus
+	paneFocus := m.archivePaneFocus && m.splitActive() && !m.showDue
+
+	// Left side: key hints or status message. Select mode's hints are fitted to the
+	// room the right side leaves (see fitHints).
+	var left string
+	var hints []string
+	if m.guardPending != "" {
+		left = guardPrompt
+	} else if m.quitPending {
+		left = quitPromptNoRemote
+		if m.quitCanSync {
+			left = quitPrompt
+		}
+	} else if m.rebasePending {
+		left = rebasePrompt
+	} else if m.movePending {
+		left = movePrompt(m.moveTargets)
+	} else if m.resetPending {
+		left = resetPrompt(m.resetSection)
+	} else if m.searching {
+		left = "/" + m.searchInput
+	} else if m.inlineEditing {
+		left = m.inlineEditView()
+	} else if m.restorePending {
+		left = restorePrompt
+	} else if m.status != "" {
+		left = m.status
+	} else if m.integrityWarning != "" {
+		left = m.integrityWarning
+	} else if m.syncWarning != "" {
+		left = m.syncWarning
+	} else if warning := m.configWarning(); warning != "" {
+		left = warning
+	} else if m.showDue {
+		left = "-- BY DUE DATE (read-only) -- any other key returns"
+	} else if paneFocus {
+		left = "-- ARCHIVE PANE (read-only) -- Tab or esc back to tasks.md"
+	} else if m.archiveMode {
+		order := "newest first"
+		if m.archiveAscending {
+			order = "oldest first"
+		}
+		left = "-- ARCHIVE (" + order + ") -- o order | u restore | esc back"
+	} else if m.cursorMode {
+		hints = selectHints
+	} else if m.splitActive() {
+		left = "? help | e edit | a archive | Tab archive pane | q quit"
+	} else {
+		left = "? help | e edit | a archive | q quit"
+	}
+
+	// Right side: scroll position and version
+	totalLines := len(m.shownLines())
+	currentLine := m.viewport.YOffset + 1
+	if paneFocus {
+		totalLines = m.archivePane.TotalLineCount()
+		currentLine = m.archivePane.YOffset + 1
+	}
+	if currentLine > totalLines {
+		currentLine = totalLines
+	}
+	if totalLines == 0 {
+		totalLines = 1
+		currentLine = 1
+	}
+	position := formatPosition(currentLine, totalLines)
+	if paneFocus {
+		position = "[archive] " + position
+	} else {
+		if m.filterName != "" {
+			position = "[filter: " + m.filterName + "] " + position
+		}
+		if _, hidden := m.futureLines(); hidden > 0 && !m.showFuture {
+			position = "[" + itoa(hidden) + " hidden] " + position
+		}
+		if m.searchQuery != "" && !m.archiveMode {
+			position = "[search: " + m.searchQuery + "] " + position
+		}
+	}
+	if m.tracking {
+		position = "[track " + formatElapsed(time.Since(m.trackStart)) + "] " + position
+	}
+	if est := m.remainingEstimate(); est > 0 && !paneFocus {
+		position = "est. remaining: " + task.FormatEffort(est) + " " + position
+	}
+	if m.gitIndicator != "" {
+		position = m.gitIndicator + " " + position
+	}
+	if m.streakText != "" {
+		position = m.streakText + "  " + position
+	}
+	// With tasks.open_limit, the open task count leads, in the warning color above the limit
+	var openCount string
+	overLimit := false
+	if limit := m.config.Tasks.OpenLimit; limit > 0 && !m.archiveMode && !paneFocus {
+		open := m.openTasks()
+		openCount = itoa(open) + " open"
+		overLimit = open > limit
+		position = openCount + "  " + position
+	}
+	right := position + versionLabel
+
+	// The bar is always one line: hints that do not fit are left out, and what is still
+	// too wide is cut, the left side first. The mode and its exit hint are kept over
+	// the right side. Before the first WindowSizeMsg the width is unknown and nothing
+	// is cut.
+	if m.width <= 0 {
+		if hints != nil {
+			left = selectPrefix + strings.Join(hints, " | ")
+		}
+	} else {
+		rightRoom := m.width
+		if hints != nil {
+			rightRoom -= ansi.StringWidth(selectPrefix+hints[0]) + 1
+		}
+		right = ansi.Truncate(right, max(rightRoom, 0), "")
+		room := max(m.width-ansi.StringWidth(right), 0)
+		if hints != nil {
+			left = fitHints(selectPrefix, hints, room-1)
+		}
+		left = ansi.Truncate(left, room, "…")
+	}
+
+	// The bar is rendered again only when its text or the window width changes
+	key := itoa(m.width) + "\x00" + left + "\x00" + right
+	if m.render != nil && m.render.footerKey == key {
+		return m.render.footer
+	}
+
+	// Calculate padding
+	leftWidth := ansi.StringWidth(left)
+	rightWidth := ansi.StringWidth(right)
+	padding := m.width - leftWidth - rightWidth
+	if padding < 0 {
+		padding = 0
+	}
+
+	footer := style.Render(left + strings.Repeat(" ", padding) + right)
+	if overLimit {
+		bar := style.UnsetWidth()
+		footer = bar.Render(left+strings.Repeat(" ", padding)) +
+			bar.Foreground(lipgloss.Color(warningColor)).Bold(true).Render(openCount) +
+			bar.Render(strings.TrimPrefix(right, openCount))
+	}
+	if m.render != nil {
+		m.render.footerKey = key
+		m.render.footer = footer
+	}
+	return footer
+}
+
+// selectPrefix and selectHints make up the footer in select mode. The hints are in
+// order of importance: fitHints leaves them out from the end when the window is narrow.
+const selectPrefix = "-- SELECT -- "
+
+var selectHints = []string{"esc exit", "i edit", "X toggle subtasks", "m move", "z fold", "* pin", "T track"}
+
+// fitHints returns prefix followed by as many of hints, joined by " | ", as fit in
+// width columns. The first hint is always included.
+func fitHints(prefix string, hints []string, width int) string {
+	n := len(hints)
+	for n > 1 && ansi.StringWidth(prefix+strings.Join(hints[:n], " | ")) > width {
+		n--
+	}
+	return prefix + strings.Join(hints[:n], " | ")
+}
+
+// warningColor (yellow) marks the footer's open task count above tasks.open_limit
+// and, with display.warn_long_tasks, tasks longer than tasks.max_task_length.
+const warningColor = "3"
+
+// versionLabel is the static end of the footer's right side.
+var versionLabel = " ttt " + cli.Version
+
+// remainingEstimate sums the @est of the open tasks in view: all of tasks.md, or the
+// tasks left by the saved filter. Tasks in folded sections still count. Nothing is
+// counted in the archive view. The total is cached until the content, filter, or day changes.
+func (m Model) remainingEstimate() time.Duration {
+	if m.archiveMode {
+		return 0
+	}
+	now := time.Now()
+	key := estimateKey{day: now.Format("2006-01-02"), filterName: m.filterName, content: m.content}
+	if m.render != nil && m.render.estimateKey == key {
+		return m.render.estimate
+	}
+
+	lines := task.ParseLines(m.content, m.taskOpts)
+	if m.filter != nil {
+		rows := m.filter.FilterLines(m.content, now)
+		shown := make([]task.ParsedLine, 0, len(rows))
+		for _, n := range rows {
+			shown = append(shown, lines[n])
+		}
+		lines = shown
+	}
+	est := task.RemainingEstimate(lines)
+
+	if m.render != nil {
+		m.render.estimateKey = key
+		m.render.estimate = est
+	}
+	return est
+}
+
+// formatElapsed formats a tracking duration as h:mm:ss.
+func formatElapsed(d time.Duration) string {
+	seconds := int(d / time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+func formatPosition(current, total int) string {
+	return "[" + itoa(current) + "/" + itoa(total) + "]"
+}
+
+func itoa(i int) string {
+	if i == 0 {
+		return "0"
+	}
+	var b [20]byte
+	idx := len(b)
+	for i > 0 {
+		idx--
+		b[idx] = byte('0' + i%10)
+		i /= 10
+	}
+	return string(b[idx:])
+}
+
+// displayContent returns the content as rendered in the viewport.
+// With display.relative_done_date, @done dates are shown relative to today.
+// When a saved filter is active, only matching tasks (with their headings) are shown.
+// Folded sections show only their heading, followed by their task counts.
+// Styled lines are reused from the render cache when nothing shown has changed.
+func (m Model) displayContent() string {
+	now := time.Now()
+	lines := m.shownLines()
+	rows := m.visibleLines()
+	folded := m.foldedSections()
+	progress := m.progressSections()
+	shown := make([]string, len(rows))
+	cursorRow := -1
+	for i, n := range rows {
+		line := lines[n]
+		if m.config.Display.RelativeDoneDate {
+			line = task.FormatDoneTagsRelative(line, now)
+		}
+		if s, ok := folded[n]; ok {
+			line += sectionSummary(s)
+		} else if s, ok := progress[n]; ok {
+			line += sectionProgress(s)
+		}
+		shown[i] = line
+		if m.cursorMode && n == m.cursor {
+			cursorRow = i
+		}
+	}
+
+	cache := m.render
+	if cache == nil {
+		cache = &renderCache{}
+	}
+	key := fmt.Sprintf("%t\x00%s\x00%d\x00%s", m.archiveMode, m.searchQuery, m.viewport.Width, strings.Join(shown, "\n"))
+	if key == cache.key && cursorRow == cache.cursorRow {
+		return cache.renderedContent
+	}
+	if key != cache.key {
+		cache.key = key
+		cache.lines = make([]string, len(shown))
+		cache.widths = make([]int, len(shown))
+		for i, line := range shown {
+			cache.lines[i] = expandTabs(m.renderLine(line, false))
+			cache.widths[i] = ansi.StringWidth(cache.lines[i])
+		}
+	}
+
+	out, widths := cache.lines, cache.widths
+	if cursorRow >= 0 {
+		out = slices.Clone(cache.lines)
+		widths = slices.Clone(cache.widths)
+		// The selected row, a folded heading with its summary too, is padded to the
+		// viewport width so the highlight spans the whole row
+		selected := shown[cursorRow]
+		selected += strings.Repeat(" ", max(0, m.viewport.Width-ansi.StringWidth(expandTabs(selected))))
+		out[cursorRow] = expandTabs(m.renderLine(selected, true))
+		widths[cursorRow] = ansi.StringWidth(out[cursorRow])
+	}
+	cache.rows, cache.rowWidths = out, widths
+	cache.cursorRow = cursorRow
+	cache.renderedContent = strings.Join(out, "\n")
+	return cache.renderedContent
+}
+
+// expandTabs replaces tabs with four spaces, as lipgloss does when it renders the
+// viewport, so the cached display widths of rows are the widths shown.
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	return strings.ReplaceAll(s, "\t", "    ")
+}
+
+// colorize applies per-line colors: completed tasks use the done color,
+// and open tasks with @priority(A/B/C) use the matching theme color.
+func (m Model) colorize(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = m.renderLine(line, false)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderLine styles one line with its theme color (see lineColor).
+// The selected line is shown in reverse video on top of its color.
+// With display.hyperlinks, links are wrapped in OSC 8 sequences so terminals make them clickable.
+// Matches of the search query are highlighted.
+func (m Model) renderLine(line string, selected bool) string {
+	style := lipgloss.NewStyle().TabWidth(lipgloss.NoTabConversion)
+	color, colored := m.lineColor(line)
+	if colored {
+		style = style.Foreground(color)
+	}
+	if selected {
+		style = style.Reverse(true)
+		if line == "" {
+			line = " "
+		}
+	}
+	render := func(s string) string {
+		if s == "" || (!colored && !selected) {
+			return s
+		}
+		return style.Render(s)
+	}
+
+	var links []task.Link
+	if m.config.Display.Hyperlinks {
+		links = task.FindLinks(line, m.links)
+	}
+	var matches []task.Match
+	if m.searchQuery != "" && !m.archiveMode {
+		matches = task.FindMatches(line, m.searchQuery, m.config.SearchOptions())
+	}
+	if len(links) == 0 && len(matches) == 0 {
+		return render(line)
+	}
+
+	// Cut the line at link and match boundaries and style each piece separately,
+	// so the escape sequences stay outside the styling
+	highlight := style.Reverse(false).Background(lipgloss.Color("3")).Foreground(lipgloss.Color("0"))
+	cuts := []int{0, len(line)}
+	for _, link := range links {
+		cuts = append(cuts, link.Start, link.End)
+	}
+	for _, match := range matches {
+		cuts = append(cuts, match.Start, match.End)
+	}
+	sort.Ints(cuts)
+
+	var b strings.Builder
+	for i := 1; i < len(cuts); i++ {
+		start, end := cuts[i-1], cuts[i]
+		if start == end {
+			continue
+		}
+		piece := render(line[start:end])
+		for _, match := range matches {
+			if match.Start <= start && end <= match.End {
+				piece = highlight.Render(line[start:end])
+				break
+			}
+		}
+		for _, link := range links {
+			if link.Start <= start && end <= link.End {
+				piece = hyperlink(link.URL, piece)
+				break
+			}
+		}
+		b.WriteString(piece)
+	}
+	return b.String()
+}
+
+// hyperlink wraps text in an OSC 8 terminal hyperlink to url.
+// Terminals without OSC 8 support show the text only.
+func hyperlink(url, text string) string {
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// lineColor returns the theme color for a line, if any.
+// Completed tasks take precedence over priority, so finished work always looks done.
+// With display.warn_long_tasks, an open task that should be split up (see
+// task.IsLongTask) is shown in warningColor instead of its priority color.
+func (m Model) lineColor(line string) (lipgloss.Color, bool) {
+	theme := m.config.Theme
+	if task.IsCompleted(line, m.taskOpts) {
+		return lipgloss.Color(theme.Done), theme.Done != ""
+	}
+	if !task.IsTask(line, m.taskOpts) {
+		return "", false
+	}
+	if m.config.Display.WarnLongTasks && task.IsLongTask(line, m.config.Tasks.MaxTaskLength, m.taskOpts) {
+		return lipgloss.Color(warningColor), true
+	}
+
+	priority, ok := task.ParsePriority(line)
+	if !ok {
+		return "", false
+	}
+	var color string
+	switch priority {
+	case "A":
+		color = theme.PriorityA
+	case "B":
+		color = theme.PriorityB
+	case "C":
+		color = theme.PriorityC
+	}
+	return lipgloss.Color(color), color != ""
+}
+
+// parseLines splits content into lines, handling trailing newlines.
+func parseLines(content string) []string {
+	trimmed := strings.TrimSuffix(content, "\n")
+	if trimmed == "" {
+		return []string{}
+	}
+	return strings.Split(trimmed, "\n")
+}
+
+// Message types
+type statusMsg string
+type errMsg struct{ err error }
+
+// ClearStatusMsg is sent when the status message timeout expires.
+type ClearStatusMsg struct{}
+
+// PullBeforeEditMsg is sent when the git.pull_before_edit pull is done and the editor
+// can open. Content is tasks.md after the pull ("" if it could not be read), and Err
+// what git.FastForwardPull returned.
+type PullBeforeEditMsg struct {
+	Content string
+	Err     error
+}
+
+// EditFinishedMsg is sent when the editor closes.
+type EditFinishedMsg struct{ Err error }
+
+// ArchiveFinishedMsg is sent when archiving completes.
+// Tagged is the number of tasks that received @done tags in the same pass.
+type ArchiveFinishedMsg struct {
+	Undoable
+	Tagged int
+	Count  int
+	Err    error
+}
+
+// ReloadFinishedMsg is sent when reload completes.
+type ReloadFinishedMsg struct {
+	Content string
+	Err     error
+}
+
+// ConfigChangedMsg is sent when config.toml has been saved while the TUI runs
+// (see config.Watch). Err is set when the new config.toml failed to load.
+type ConfigChangedMsg struct {
+	Config *config.Config
+	Err    error
+}
+
+// configChanged applies the settings of a changed config.toml that take effect
+// without a restart (see config.Config.Reloaded) and redraws with them. A config.toml
+// that failed to load leaves the current settings in place with a warning.
+func (m Model) configChanged(msg ConfigChangedMsg) (Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m.setStatusWithTimeout("Config error (keeping current settings): " + msg.Err.Error())
+	}
+	split := m.splitActive()
+	m.config = m.config.Reloaded(msg.Config)
+	m.links = m.config.LinkPatterns()
+	m.render = &renderCache{} // theme colors are not part of the cache key
+	if m.ready {
+		// Scrollbar and split view change the pane widths
+		updated, _ := m.Update(tea.WindowSizeMsg{Width: m.width, Height: m.height})
+		m = updated.(Model)
+		m.viewport.SetContent(m.displayContent())
+		if m.cursorMode {
+			m = m.scrollToCursor()
+		}
+	}
+	m, cmd := m.setStatusWithTimeout("Config reloaded")
+	if m.splitActive() && !split {
+		return m, tea.Batch(cmd, m.archivePaneCmd())
+	}
+	return m, cmd
+}
+
+// configWarning returns the footer warning kept while config.toml is not in use: in
+// safe mode, or when lines of it could not be read or settings in it are not valid
+// (those settings keep the defaults).
+// A config.toml reloaded without errors clears it.
+func (m Model) configWarning() string {
+	if m.config.SafeMode {
+		return "safe mode: config.toml ignored (using defaults)"
+	}
+	errs := m.config.ConfigErrors
+	if len(errs) == 0 {
+		return ""
+	}
+	warning := "config error: " + errs[0]
+	if len(errs) > 1 {
+		warning += " (+" + strconv.Itoa(len(errs)-1) + " more)"
+	}
+	return warning + " (using defaults)"
+}
+
+// DiffFinishedMsg is sent when the working tree diff has been collected.
+type DiffFinishedMsg struct {
+	Diff string
+	Err  error
+}
+
+// GitStatusMsg reports the working directory's git state for the footer indicator.
+type GitStatusMsg struct {
+	HasRemote bool
+	Dirty     bool
+	Ahead     int
+	Err       error
+}
+
+// StreakMsg reports today's completions and the current streak for the footer
+// (ui.show_streak), with the archive content it was computed from for the per-day cache.
+type StreakMsg struct {
+	Day     time.Time
+	Archive string
+	Today   int
+	Streak  int
+	Err     error
+}
+
+// QuitCheckMsg reports the git state checked after q (git.confirm_quit_if_dirty).
+type QuitCheckMsg struct {
+	Status git.RepoStatus
+	Err    error
+}
+
+// IntegrityCheckMsg reports how tasks.md differs from the last commit
+// (git.integrity_check). Lines is the current number of lines in the file.
+type IntegrityCheckMsg struct {
+	Added   int
+	Deleted int
+	Lines   int
+	Err     error
+}
+
+// AutoSyncTickMsg is sent when a scheduled auto-sync is due.
+type AutoSyncTickMsg struct{}
+
+// SyncFinishedMsg is sent when a scheduled sync completes.
+// NoRemote is set when no remote is configured and the sync was skipped.
+type SyncFinishedMsg struct {
+	NoRemote bool
+	Resolved int   // duplicate completed tasks merged after the pull (see task.ResolveDoneConflicts)
+	HookErr  error // git.post_sync_hook failed; the sync itself succeeded
+	Err      error
+	// Set when the push was rejected because the remote diverged (git.ErrNonFastForward):
+	// how to integrate the remote commits by hand (see git.RebaseHint).
+	RebaseHint string
+}
+
+// SyncProgressMsg is sent as each step of a running sync starts (see git.SyncContext).
+type SyncProgressMsg struct {
+	Step string // git.SyncPulling, git.SyncCommitting, or git.SyncPushing
+}
+
+// ToggleChildrenFinishedMsg is sent when the subtasks of the selected task were toggled.
+// Completed reports the direction: true if they were completed, false if reopened.
+type ToggleChildrenFinishedMsg struct {
+	Undoable
+	Count     int
+	Completed bool
+	Line      int    // 0-indexed line of the selected task
+	Task      string // text of the selected task (see task.TaskBody)
+	Err       error
+}
+
+// GuardBlockedMsg is sent when an operation was not run because tasks.md
+// does not look like a task list. Op is run if the user confirms.
+type GuardBlockedMsg struct {
+	Op guardOp
+}
+
+// ArchiveLoadedMsg is sent when the archive files have been read for the archive view
+// (see task.Archives). Refresh is set when reloading an archive view that is already open.
+type ArchiveLoadedMsg struct {
+	Content string
+	Files   []task.ArchiveFile
+	More    bool
+	Refresh bool
+	Err     error
+}
+
+// RestoreFinishedMsg is sent when an archived task was moved back to tasks.md.
+// Count is the number of task lines restored (the task and its subtasks).
+type RestoreFinishedMsg struct {
+	Undoable
+	Count int
+	Err   error
+}
+
+// OpenURLFinishedMsg is sent when the system URL handler has been started for URL.
+type OpenURLFinishedMsg struct {
+	URL string
+	Err error
+}
+
+// MoveFinishedMsg is sent after the selected task was moved to another workspace.
+// Moved is set when both files were written, even if a commit then failed; Block is
+// then the moved task and the lines nested under it, cut out of tasks.md at Line.
+type MoveFinishedMsg struct {
+	Undoable
+	Target string
+	Moved  bool
+	Line   int
+	Block  string
+	Err    error
+}
+
+// PinFinishedMsg is sent after the selected task's @pin tag was toggled.
+type PinFinishedMsg struct {
+	Undoable
+	Pinned bool
+	Err    error
+}
+
+// DeleteFinishedMsg is sent after the selected task was cut out of tasks.md at Line.
+// Block is the cut task and the lines nested under it, for the yank buffer.
+type DeleteFinishedMsg struct {
+	Undoable
+	Block string
+	Line  int
+	Err   error
+}
+
+// ResetFinishedMsg is sent after a ## section of tasks.md was reset.
+// Count is the number of tasks unchecked.
+type ResetFinishedMsg struct {
+	Undoable
+	Heading string
+	Count   int
+	Err     error
+}
+
+// PasteFinishedMsg is sent after the yank buffer was pasted into tasks.md below Line,
+// the selected line. Count is the number of lines of Block, the buffer pasted.
+type PasteFinishedMsg struct {
+	Undoable
+	Count     int
+	Line      int
+	Block     string
+	Clipboard bool // P: Block came from the clipboard, and Count is its tasks
+	Err       error
+}
+
+// InlineEditFinishedMsg is sent after the inline-edited task text was written back.
+type InlineEditFinishedMsg struct {
+	Undoable
+	Err error
+}
+
+// TrackTickMsg refreshes the elapsed time in the footer while a timer started at Start runs.
+type TrackTickMsg struct {
+	Start time.Time
+}
+
+// TrackFinishedMsg is sent after the elapsed time has been added to the tracked task.
+type TrackFinishedMsg struct {
+	Undoable
+	Elapsed time.Duration
+	Err     error
+}
+
+// AddDoneTagsFinishedMsg is sent when adding @done tags completes.
+// Content is the file as read before tagging (set after an edit).
+type AddDoneTagsFinishedMsg struct {
+	Count     int
+	Escalated int // overdue tasks raised to @p1 (tasks.escalate_overdue_days)
+	Content   string
+	Err       error
+}
+
+// editCmd returns a command that launches the external editor.
+// It uses tea.ExecProcess to suspend the TUI and run the editor.
+func (m Model) editCmd() tea.Cmd {
+	parts, err := m.config.EditorArgs(m.tasksPath)
+	if err != nil {
+		return func() tea.Msg {
+			return EditFinishedMsg{Err: err}
+		}
+	}
+	if len(parts) == 0 {
+		return func() tea.Msg {
+			return EditFinishedMsg{Err: nil}
+		}
+	}
+	c := exec.Command(parts[0], parts[1:]...)
+	return tea.ExecProcess(c, func(err error) tea.Msg {
+		return EditFinishedMsg{Err: err}
+	})
+}
+
+// pullBeforeEditCmd returns a command that fast-forwards from origin before the editor
+// opens, or nil when git.pull_before_edit is off or git is disabled.
+func (m Model) pullBeforeEditCmd() tea.Cmd {
+	if !m.config.Git.PullBeforeEdit || m.config.Git.Mode == config.GitModeDisabled || m.tasksPath == "" {
+		return nil
+	}
+	tasksPath := m.tasksPath
+	dir := filepath.Dir(tasksPath)
+
+	return func() tea.Msg {
+		if !git.HasRemote(dir, "origin") {
+			return PullBeforeEditMsg{}
+		}
+		err := git.FastForwardPull(dir, git.PullBeforeEditTimeout)
+		content, _ := task.LoadFile(tasksPath, m.taskOpts)
+		return PullBeforeEditMsg{Content: content, Err: err}
+	}
+}
+
+// pullWarning describes a failed pull before editing for the status after the edit.
+func pullWarning(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, git.ErrOffline):
+		return "working offline"
+	case errors.Is(err, git.ErrRemoteAhead):
+		return "remote ahead, run ttt sync"
+	}
+	return "pull failed"
+}
+
+// archiveCmd returns a command that archives old completed tasks.
+func (m Model) archiveCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	archivePath := m.archivePath
+	delayFor := m.config.ArchiveDelay()
+	commit := m.config.Git.AutoCommit && m.config.Archive.Tombstone
+	routes, routesErr := m.config.ArchiveRoutes()
+	guard := m.guardCheck()
+	paths := []string{tasksPath, archivePath}
+	for _, path := range routes {
+		paths = append(paths, path)
+	}
+
+	return m.undoable("archive", func() tea.Msg {
+		if routesErr != nil {
+			return ArchiveFinishedMsg{Err: routesErr}
+		}
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpArchive}
+		}
+		// Add @done tags and archive old completed tasks in a single read-write cycle
+		tagged, archived, err := task.ProcessAndArchiveRouted(tasksPath, archivePath, routes, delayFor, m.taskOpts)
+		count := 0
+		files := []string{tasksPath}
+		for path, n := range archived {
+			count += n
+			files = append(files, path)
+		}
+		if err == nil && count > 0 && commit {
+			// All changed files land in the same commit
+			err = commitArchive(tasksPath, files, count)
+		}
+		return ArchiveFinishedMsg{Tagged: tagged, Count: count, Err: err}
+	}, paths...)
+}
+
+// commitArchive commits tasks.md and the archive files written by an archive pass together.
+// Files outside the working directory's repository are left out.
+func commitArchive(tasksPath string, files []string, count int) error {
+	dir := filepath.Dir(tasksPath)
+	var rel []string
+	for _, f := range files {
+		r, err := filepath.Rel(dir, f)
+		if err != nil || strings.HasPrefix(r, "..") {
+			continue
+		}
+		rel = append(rel, r)
+	}
+	sort.Strings(rel)
+
+	err := git.CommitAll(dir, git.Message(git.ActionArchive, time.Now(), count), rel...)
+	if err != nil {
+		return fmt.Errorf("archived, but git commit failed: %w", err)
+	}
+	return nil
+}
+
+// reloadCmd returns a command that reloads the tasks file.
+func (m Model) reloadCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+
+	return func() tea.Msg {
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		return ReloadFinishedMsg{Content: content, Err: err}
+	}
+}
+
+// addDoneTagsCmd returns a command that adds @done tags to completed tasks.
+func (m Model) addDoneTagsCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	guard := m.guardCheck()
+
+	return func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpDoneTags}
+		}
+		tagged, escalated, err := task.ProcessFile(tasksPath, m.taskOpts)
+		return AddDoneTagsFinishedMsg{Count: tagged, Escalated: escalated, Err: err}
+	}
+}
+
+// addDoneTagsAndReloadCmd returns a command that adds @done tags and then reloads.
+func (m Model) addDoneTagsAndReloadCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	guard := m.guardCheck()
+
+	return func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpEditDoneTags}
+		}
+		// Keep the edited content so the edit can be told apart from the tagging
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		if err != nil {
+			return AddDoneTagsFinishedMsg{Count: 0, Err: err}
+		}
+		tagged, escalated, err := task.ProcessFile(tasksPath, m.taskOpts)
+		if err != nil {
+			return AddDoneTagsFinishedMsg{Count: 0, Err: err}
+		}
+		return AddDoneTagsFinishedMsg{Count: tagged, Escalated: escalated, Content: content, Err: nil}
+	}
+}
+
+// toggleChildrenCmd returns a command that completes all subtasks of the selected task,
+// or reopens them if they are all completed already. The task itself is unchanged.
+func (m Model) toggleChildrenCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	line := m.cursor
+	expected := ""
+	if line < len(m.lines) {
+		expected = m.lines[line]
+	}
+	guard := m.guardCheck()
+
+	return m.undoable("subtask toggle", func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpToggleChildren}
+		}
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		if err != nil {
+			return ToggleChildrenFinishedMsg{Err: err}
+		}
+		// Line numbers come from the displayed content; refuse if the file changed since
+		lines := strings.Split(content, "\n")
+		if line < 0 || line >= len(lines) || lines[line] != expected {
+			return ToggleChildrenFinishedMsg{Err: errors.New("tasks.md changed on disk, press r to reload")}
+		}
+
+		complete := !task.ChildrenCompleted(content, line, m.taskOpts)
+		newContent, count := task.ToggleSubtreeChildren(content, line, complete, m.taskOpts)
+		if count > 0 {
+			if err := task.WriteTasksFile(tasksPath, newContent, m.taskOpts); err != nil {
+				return ToggleChildrenFinishedMsg{Err: err}
+			}
+		}
+		return ToggleChildrenFinishedMsg{Count: count, Completed: complete, Line: line, Task: task.TaskBody(expected, m.taskOpts)}
+	}, tasksPath)
+}
+
+// inlineEditCmd returns a command that writes the inline-edited text into the task
+// line (see task.ReplaceBody). It refuses if the line changed on disk since editing started.
+func (m Model) inlineEditCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	line := m.inlineLine
+	expected := m.inlineText
+	body := strings.TrimSpace(m.inlineInput.Value())
+	guard := m.guardCheck()
+
+	return m.undoable("inline edit", func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpInlineEdit}
+		}
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		if err != nil {
+			return InlineEditFinishedMsg{Err: err}
+		}
+		lines := strings.Split(content, "\n")
+		if line >= len(lines) || lines[line] != expected {
+			return InlineEditFinishedMsg{Err: errors.New("tasks.md changed on disk, press r to reload")}
+		}
+		lines[line] = task.ReplaceBodyWith(lines[line], body, m.taskOpts)
+		if err := task.WriteTasksFile(tasksPath, strings.Join(lines, "\n"), m.taskOpts); err != nil {
+			return InlineEditFinishedMsg{Err: err}
+		}
+		return InlineEditFinishedMsg{}
+	}, tasksPath)
+}
+
+// moveCmd returns a command that moves the selected task, with its subtasks, to the
+// [tasks] inbox_heading section of the moveTarget workspace (see task.ExtractSubtree).
+// The target is written first, so a failed write never loses the task. With
+// git.auto_commit, tasks.md is committed in both workspaces.
+func (m Model) moveCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	line := m.cursor
+	expected := ""
+	if line < len(m.lines) {
+		expected = m.lines[line]
+	}
+	name := m.moveTarget
+	from := m.config.Workspace()
+	target, targetErr := m.config.ForWorkspace(name)
+	commit := m.config.Git.AutoCommit
+	guard := m.guardCheck()
+	// Undo puts the task back in both workspaces
+	paths := []string{tasksPath}
+	if targetErr == nil {
+		if targetPath, err := target.TasksPath(); err == nil && targetPath != tasksPath {
+			paths = append(paths, targetPath)
+		}
+	}
+
+	return m.undoable("move", func() tea.Msg {
+		if targetErr != nil {
+			return MoveFinishedMsg{Target: name, Err: targetErr}
+		}
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpMove}
+		}
+		targetPath, err := target.TasksPath()
+		if err != nil {
+			return MoveFinishedMsg{Target: name, Err: err}
+		}
+		if targetPath == tasksPath {
+			return MoveFinishedMsg{Target: name, Err: fmt.Errorf("workspace %q uses the same tasks file", name)}
+		}
+		targetContent, err := task.LoadFile(targetPath, m.taskOpts)
+		if err != nil {
+			return MoveFinishedMsg{Target: name, Err: err}
+		}
+		if !target.LooksLikeTaskFile(targetContent) {
+			return MoveFinishedMsg{Target: name, Err: fmt.Errorf("%s does not look like a task list", targetPath)}
+		}
+
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		if err != nil {
+			return MoveFinishedMsg{Target: name, Err: err}
+		}
+		lines := strings.Split(content, "\n")
+		if line >= len(lines) || lines[line] != expected {
+			return MoveFinishedMsg{Target: name, Err: errors.New("tasks.md changed on disk, press r to reload")}
+		}
+		subtree, remaining, err := task.ExtractSubtreeWith(content, line, m.taskOpts)
+		if err != nil {
+			return MoveFinishedMsg{Target: name, Err: err}
+		}
+
+		newTarget, _ := task.InsertUnderHeading(target.WithTitle(targetContent), target.Tasks.InboxHeading, subtree, m.taskOpts)
+		if err := task.WriteTasksFile(targetPath, newTarget, m.taskOpts); err != nil {
+			return MoveFinishedMsg{Target: name, Err: err}
+		}
+		if err := task.WriteTasksFile(tasksPath, remaining, m.taskOpts); err != nil {
+			return MoveFinishedMsg{Target: name, Err: fmt.Errorf("copied to %s, but not removed from tasks.md: %w", name, err)}
+		}
+
+		if commit {
+			text := task.TaskBody(expected, m.taskOpts)
+			now := time.Now()
+			err := git.CommitAll(filepath.Dir(targetPath), git.Message(git.ActionMoveFrom, now, from, text), filepath.Base(targetPath))
+			if err == nil {
+				err = git.CommitAll(filepath.Dir(tasksPath), git.Message(git.ActionMoveTo, now, name, text), filepath.Base(tasksPath))
+			}
+			if err != nil {
+				return MoveFinishedMsg{Target: name, Moved: true, Line: line, Block: subtree, Err: fmt.Errorf("moved, but git commit failed: %w", err)}
+			}
+		}
+		return MoveFinishedMsg{Target: name, Moved: true, Line: line, Block: subtree}
+	}, paths...)
+}
+
+// deleteCmd returns a command that cuts the selected task and the lines nested under
+// it out of tasks.md (see task.ExtractSubtree). The cut block goes to the yank buffer
+// once it was written.
+func (m Model) deleteCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	line := m.cursor
+	expected := ""
+	if line < len(m.lines) {
+		expected = m.lines[line]
+	}
+	guard := m.guardCheck()
+
+	return m.undoable("delete", func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpDelete}
+		}
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		if err != nil {
+			return DeleteFinishedMsg{Err: err}
+		}
+		lines := strings.Split(content, "\n")
+		if line >= len(lines) || lines[line] != expected {
+			return DeleteFinishedMsg{Err: errors.New("tasks.md changed on disk, press r to reload")}
+		}
+		block, remaining, err := task.ExtractSubtreeWith(content, line, m.taskOpts)
+		if err != nil {
+			return DeleteFinishedMsg{Err: err}
+		}
+		if err := task.WriteTasksFile(tasksPath, remaining, m.taskOpts); err != nil {
+			return DeleteFinishedMsg{Err: err}
+		}
+		return DeleteFinishedMsg{Block: block, Line: line}
+	}, tasksPath)
+}
+
+// pasteCmd returns a command that pastes the yank buffer below the selected line of
+// tasks.md, indented like it (see task.PasteBlock). The buffer is kept for more pastes.
+func (m Model) pasteCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	line := m.cursor
+	expected := ""
+	if line < len(m.lines) {
+		expected = m.lines[line]
+	}
+	block := strings.Join(m.yankBuffer, "\n")
+	count := len(m.yankBuffer)
+	guard := m.guardCheck()
+
+	return m.undoable("paste", func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpPaste}
+		}
+		if err := pasteIntoFile(tasksPath, line, expected, block, m.taskOpts); err != nil {
+			return PasteFinishedMsg{Err: err}
+		}
+		return PasteFinishedMsg{Count: count, Line: line, Block: block}
+	}, tasksPath)
+}
+
+// importCmd returns a command that reads the clipboard, turns its lines into tasks
+// (see task.LinesToTasks), and pastes them below the selected line of tasks.md as p does.
+func (m Model) importCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	line := m.cursor
+	expected := ""
+	if line < len(m.lines) {
+		expected = m.lines[line]
+	}
+	guard := m.guardCheck()
+
+	return m.undoable("import", func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpImport}
+		}
+		text, err := readClipboard()
+		if err != nil {
+			return PasteFinishedMsg{Clipboard: true, Err: err}
+		}
+		block := task.LinesToTasksWith(text, m.taskOpts)
+		if block == "" {
+			return PasteFinishedMsg{Clipboard: true, Err: errors.New("the clipboard is empty")}
+		}
+		if err := pasteIntoFile(tasksPath, line, expected, block, m.taskOpts); err != nil {
+			return PasteFinishedMsg{Clipboard: true, Err: err}
+		}
+		count := 0
+		for _, l := range strings.Split(block, "\n") {
+			if task.IsTask(l, m.taskOpts) {
+				count++
+			}
+		}
+		return PasteFinishedMsg{Count: count, Line: line, Block: block, Clipboard: true}
+	}, tasksPath)
+}
+
+// pasteIntoFile pastes block below line of the tasks file (see task.PasteBlock), as
+// long as the line still reads expected.
+func pasteIntoFile(tasksPath string, line int, expected, block string, opts task.Options) error {
+	content, err := task.LoadFile(tasksPath, opts)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(content, "\n")
+	if line >= len(lines) || lines[line] != expected {
+		return errors.New("tasks.md changed on disk, press r to reload")
+	}
+	newContent, err := task.PasteBlock(content, line, block, opts)
+	if err != nil {
+		return err
+	}
+	return task.WriteTasksFile(tasksPath, newContent, opts)
+}
+
+// resetCmd returns a command that unchecks the completed tasks of the ## section
+// chosen with R and removes their @done tags (see task.ResetSection).
+func (m Model) resetCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	line := m.resetSection.Line
+	heading := m.resetSection.Heading
+	expected := ""
+	if line < len(m.lines) {
+		expected = m.lines[line]
+	}
+	guard := m.guardCheck()
+
+	return m.undoable("reset", func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpReset}
+		}
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		if err != nil {
+			return ResetFinishedMsg{Err: err}
+		}
+		lines := strings.Split(content, "\n")
+		if line >= len(lines) || lines[line] != expected {
+			return ResetFinishedMsg{Err: errors.New("tasks.md changed on disk, press r to reload")}
+		}
+		newContent, count, err := task.ResetSectionWith(content, heading, m.taskOpts)
+		if err != nil {
+			return ResetFinishedMsg{Err: err}
+		}
+		if err := task.WriteTasksFile(tasksPath, newContent, m.taskOpts); err != nil {
+			return ResetFinishedMsg{Err: err}
+		}
+		return ResetFinishedMsg{Heading: heading, Count: count}
+	}, tasksPath)
+}
+
+// pinCmd returns a command that toggles the @pin tag of the selected task (see
+// task.TogglePin). With ui.pinned_first = "file", a newly pinned task is also moved
+// to the top of tasks.md.
+func (m Model) pinCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	line := m.cursor
+	expected := ""
+	if line < len(m.lines) {
+		expected = m.lines[line]
 	}
+	toFile := m.config.UI.PinnedFirst == config.PinnedFirstFile
+	guard := m.guardCheck()
 
-	return m, nil
+	return m.undoable("pin", func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpPin}
+		}
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		if err != nil {
+			return PinFinishedMsg{Err: err}
+		}
+		lines := strings.Split(content, "\n")
+		if line >= len(lines) || lines[line] != expected {
+			return PinFinishedMsg{Err: errors.New("tasks.md changed on disk, press r to reload")}
+		}
+		lines[line] = task.TogglePin(lines[line], m.taskOpts)
+		pinned := task.IsPinnedWith(lines[line], m.taskOpts)
+		content = strings.Join(lines, "\n")
+		if pinned && toFile {
+			content = task.PinnedFirstContent(content, m.taskOpts)
+		}
+		if err := task.WriteTasksFile(tasksPath, content, m.taskOpts); err != nil {
+			return PinFinishedMsg{Err: err}
+		}
+		return PinFinishedMsg{Pinned: pinned}
+	}, tasksPath)
 }
 
-// action represents a keybinding action.
-type action int
+// trackTickCmd schedules the next footer refresh of the timer started at start.
+func trackTickCmd(start time.Time) tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return TrackTickMsg{Start: start}
+	})
+}
 
-const (
-	actionNone action = iota
-	actionUp
-	actionDown
-	actionTop
-	actionBottom
-	actionHalfPageUp
-	actionHalfPageDown
-)
+// trackCmd returns a command that adds the stopped timer's elapsed time to the
+// @track tag of the tracked task. The task is looked up by its text if edits
+// moved it to another line.
+func (m Model) trackCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	line := m.trackLine
+	expected := m.trackText
+	elapsed := m.trackElapsed
+	guard := m.guardCheck()
 
-// matchAction returns the action for the pressed key.
-func (m Model) matchAction(key string) action {
-	switch {
-	case m.matchKey(key, m.config.Keybindings.Up):
-		return actionUp
-	case m.matchKey(key, m.config.Keybindings.Down):
-		return actionDown
-	case m.matchKey(key, m.config.Keybindings.Top):
-		return actionTop
-	case m.matchKey(key, m.config.Keybindings.Bottom):
-		return actionBottom
-	case m.matchKey(key, m.config.Keybindings.HalfPageUp):
-		return actionHalfPageUp
-	case m.matchKey(key, m.config.Keybindings.HalfPageDown):
-		return actionHalfPageDown
+	return m.undoable("time tracking", func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpTrack}
+		}
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		if err != nil {
+			return TrackFinishedMsg{Elapsed: elapsed, Err: err}
+		}
+		lines := strings.Split(content, "\n")
+		if line >= len(lines) || lines[line] != expected {
+			line = slices.Index(lines, expected)
+			if line < 0 {
+				return TrackFinishedMsg{Elapsed: elapsed, Err: errors.New("tracked task not found in tasks.md")}
+			}
+		}
+		lines[line] = task.AddTrackedTime(lines[line], elapsed)
+		if err := task.WriteTasksFile(tasksPath, strings.Join(lines, "\n"), m.taskOpts); err != nil {
+			return TrackFinishedMsg{Elapsed: elapsed, Err: err}
+		}
+		return TrackFinishedMsg{Elapsed: elapsed}
+	}, tasksPath)
+}
+
+// openURL opens url with the system handler. Replaceable in tests.
+var openURL = func(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
 	default:
-		return actionNone
+		cmd = exec.Command("xdg-open", url)
 	}
+	return cmd.Run()
 }
 
-// matchKey checks if the pressed key matches any of the configured keys.
-func (m Model) matchKey(pressed string, configured []string) bool {
-	for _, k := range configured {
-		if pressed == k {
-			return true
+// clipboardCommands are the commands that print the clipboard on Linux and other
+// systems, tried in order; wl-paste only under Wayland.
+var clipboardCommands = [][]string{
+	{"wl-paste", "--no-newline"},
+	{"xclip", "-selection", "clipboard", "-o"},
+	{"xsel", "--clipboard", "--output"},
+}
+
+// readClipboard returns the text on the system clipboard. Replaceable in tests.
+var readClipboard = func() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("pbpaste")
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", "Get-Clipboard")
+	default:
+		for _, args := range clipboardCommands {
+			if args[0] == "wl-paste" && os.Getenv("WAYLAND_DISPLAY") == "" {
+				continue
+			}
+			if _, err := exec.LookPath(args[0]); err == nil {
+				cmd = exec.Command(args[0], args[1:]...)
+				break
+			}
+		}
+		if cmd == nil {
+			return "", errors.New("no clipboard command found (install wl-clipboard, xclip, or xsel)")
 		}
 	}
-	return false
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", cmd.Args[0], err)
+	}
+	return string(output), nil
 }
 
-// View renders the UI.
-func (m Model) View() string {
-	if !m.ready {
-		return "Initializing..."
+// openURLCmd returns a command that opens url in the browser.
+func openURLCmd(url string) tea.Cmd {
+	return func() tea.Msg {
+		return OpenURLFinishedMsg{URL: url, Err: openURL(url)}
 	}
+}
 
-	base := m.viewport.View() + "\n" + m.footerView()
+// archiveViewBudget is how much of the archive files the archive view reads at first
+// (see task.LoadArchives); older monthly files are read when they are scrolled to.
+const archiveViewBudget = 1 << 20
 
-	if m.showHelp {
-		return m.overlayHelp(base)
+// loadArchiveCmd returns a command that reads archive.md and the monthly archive
+// files for the archive view, all of them once archiveAll is set. No archive files
+// are shown as empty.
+func (m Model) loadArchiveCmd(refresh bool) tea.Cmd {
+	dir := filepath.Dir(m.archivePath)
+	budget := int64(archiveViewBudget)
+	if m.archiveAll {
+		budget = 0
 	}
 
-	return base
+	return func() tea.Msg {
+		archives, err := task.LoadArchives(dir, budget, m.taskOpts)
+		return ArchiveLoadedMsg{Content: archives.Content, Files: archives.Files, More: archives.More, Refresh: refresh, Err: err}
+	}
 }
 
-// footerView renders the footer bar.
-func (m Model) footerView() string {
-	style := lipgloss.NewStyle().
-		Background(lipgloss.Color("240")).
-		Foreground(lipgloss.Color("252")).
-		Width(m.width)
+// restoreCmd returns a command that moves the selected archived task (with its subtasks)
+// to the end of tasks.md. Unless restoreKeepDone is set, the tasks are reopened.
+// tasks.md is written before the archive file, so a failure never loses the task.
+func (m Model) restoreCmd() tea.Cmd {
+	tasksPath := m.tasksPath
+	keepDone := m.restoreKeepDone
+	archives := task.Archives{Content: m.archiveContent, Files: m.archiveFiles}
+	archivePath, line, ok := archives.Locate(m.archiveFileLine(m.cursor))
+	name := filepath.Base(archivePath)
+	expected := ""
+	if m.cursor < len(m.archiveLines) {
+		expected = m.archiveLines[m.cursor]
+	}
+	guard := m.guardCheck()
 
-	// Left side: key hints or status message
-	var left string
-	if m.status != "" {
-		left = m.status
-	} else {
-		left = "? help | e edit | a archive | q quit"
+	return m.undoable("restore", func() tea.Msg {
+		if !guard() {
+			return GuardBlockedMsg{Op: guardOpRestore}
+		}
+		if !ok {
+			return RestoreFinishedMsg{Err: errors.New("selected line is not a task")}
+		}
+		archive, err := task.LoadFile(archivePath, m.taskOpts)
+		if err != nil {
+			return RestoreFinishedMsg{Err: err}
+		}
+		// Line numbers come from the displayed archive; refuse if the file changed since
+		lines := strings.Split(archive, "\n")
+		if line >= len(lines) || lines[line] != expected {
+			return RestoreFinishedMsg{Err: errors.New(name + " changed on disk, reopen the archive view")}
+		}
+
+		newArchive, restored := task.RestoreTaskWith(archive, line, m.taskOpts)
+		if restored == "" {
+			return RestoreFinishedMsg{Err: errors.New("selected line is not a task")}
+		}
+		if !keepDone {
+			restored, _ = task.ReopenTasks(restored, m.taskOpts)
+		}
+
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return RestoreFinishedMsg{Err: err}
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if err := task.WriteTasksFile(tasksPath, content+restored, m.taskOpts); err != nil {
+			return RestoreFinishedMsg{Err: err}
+		}
+		if err := task.WriteFile(archivePath, newArchive, m.taskOpts); err != nil {
+			return RestoreFinishedMsg{Err: fmt.Errorf("task added to tasks.md, but %s could not be updated: %w", name, err)}
+		}
+
+		count := 0
+		for _, l := range strings.Split(restored, "\n") {
+			if task.IsTask(l, m.taskOpts) {
+				count++
+			}
+		}
+		return RestoreFinishedMsg{Count: count}
+	}, tasksPath, archivePath)
+}
+
+// guardCheck returns a function, run inside a command, that reports whether
+// tasks.md may be modified. It passes once the user has confirmed the guard prompt,
+// or when the file cannot be read (the operation itself then reports the error).
+func (m Model) guardCheck() func() bool {
+	if m.guardConfirmed {
+		return func() bool { return true }
 	}
+	cfg := m.config
+	tasksPath := m.tasksPath
 
-	// Right side: scroll position and version
-	totalLines := len(m.lines)
-	currentLine := m.viewport.YOffset + 1
-	if currentLine > totalLines {
-		currentLine = totalLines
+	return func() bool {
+		content, err := task.LoadFile(tasksPath, m.taskOpts)
+		if err != nil {
+			return true
+		}
+		return cfg.LooksLikeTaskFile(content)
 	}
-	if totalLines == 0 {
-		totalLines = 1
-		currentLine = 1
+}
+
+// diffCmd returns a command that collects uncommitted changes in the working directory
+// (only the working directory in parent-repo mode).
+func (m Model) diffCmd() tea.Cmd {
+	dir := filepath.Dir(m.tasksPath)
+	paths := m.config.GitPaths()
+	disabled := m.config.Git.Mode == config.GitModeDisabled
+
+	return func() tea.Msg {
+		if disabled {
+			return DiffFinishedMsg{Err: fmt.Errorf("git.mode is \"disabled\"")}
+		}
+		diff, err := git.Diff(dir, paths...)
+		return DiffFinishedMsg{Diff: diff, Err: err}
 	}
-	position := formatPosition(currentLine, totalLines)
-	version := "ttt " + cli.Version
-	right := lipgloss.NewStyle().
-		Align(lipgloss.Right).
-		Render(position + " " + version)
+}
 
-	// Calculate padding
-	leftWidth := lipgloss.Width(left)
-	rightWidth := lipgloss.Width(right)
-	padding := m.width - leftWidth - rightWidth
-	if padding < 0 {
-		padding = 0
+// gitStatusCmd returns a command that collects the git state for the footer indicator.
+// Returns nil when the model has no file paths (e.g. in tests) or git is disabled.
+func (m Model) gitStatusCmd() tea.Cmd {
+	if m.tasksPath == "" || m.config.Git.Mode == config.GitModeDisabled {
+		return nil
+	}
+	dir := filepath.Dir(m.tasksPath)
+	paths := m.config.GitPaths()
+
+	return func() tea.Msg {
+		status, err := git.Status(dir, paths...)
+		return GitStatusMsg{HasRemote: status.HasRemote, Dirty: status.Dirty, Ahead: status.Ahead, Err: err}
+	}
+}
+
+// streakCmd returns a command that counts today's completions and the streak across
+// tasks.md and archive.md. archive.md is read only on the first call of each day (or
+// after an archive or restore); otherwise the cached content is used.
+// Returns nil unless ui.show_streak is set.
+func (m Model) streakCmd() tea.Cmd {
+	if !m.config.UI.ShowStreak {
+		return nil
 	}
+	content := m.content
+	archivePath := m.archivePath
+	cachedDay := m.streakDay
+	archive := m.streakArchive
 
-	footer := left + strings.Repeat(" ", padding) + right
-	return style.Render(footer)
+	return func() tea.Msg {
+		now := time.Now()
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if !day.Equal(cachedDay) {
+			archive = ""
+			if archivePath != "" {
+				var err error
+				archive, err = task.LoadAllArchivesWith(filepath.Dir(archivePath), m.taskOpts)
+				if err != nil {
+					return StreakMsg{Err: err}
+				}
+			}
+		}
+		contents := []string{content, archive}
+		return StreakMsg{
+			Day:     day,
+			Archive: archive,
+			Today:   task.DoneOn(contents, now, m.taskOpts),
+			Streak:  task.StreakWith(contents, now, m.taskOpts),
+		}
+	}
 }
 
-func formatPosition(current, total int) string {
-	return "[" + itoa(current) + "/" + itoa(total) + "]"
+// formatStreak formats the footer streak segment, e.g. "today: 4 ✓ · streak: 6d".
+func formatStreak(today, streak int) string {
+	return "today: " + strconv.Itoa(today) + " ✓ · streak: " + strconv.Itoa(streak) + "d"
 }
 
-func itoa(i int) string {
-	if i == 0 {
-		return "0"
-	}
-	var b [20]byte
-	idx := len(b)
-	for i > 0 {
-		idx--
-		b[idx] = byte('0' + i%10)
-		i /= 10
+// quit answers q: with archive.on_quit, it first archives (see ArchiveFinishedMsg, which
+// quits once the archive is written, or cancels the quit on an error), then quits as
+// quitCmd does.
+func (m Model) quit() (Model, tea.Cmd) {
+	if !m.config.Archive.OnQuit || m.tasksPath == "" {
+		return m, m.quitCmd()
 	}
-	return string(b[idx:])
+	m.quitArchiving = true
+	m.status = "Archiving..."
+	return m, m.archiveCmd()
 }
 
-// parseLines splits content into lines, handling trailing newlines.
-func parseLines(content string) []string {
-	trimmed := strings.TrimSuffix(content, "\n")
-	if trimmed == "" {
-		return []string{}
+// quitCmd quits the TUI, or with git.confirm_quit_if_dirty first checks the git state
+// (see QuitCheckMsg) so unsynced changes can be confirmed.
+func (m Model) quitCmd() tea.Cmd {
+	if !m.config.Git.ConfirmQuitIfDirty || m.config.Git.Mode == config.GitModeDisabled || m.tasksPath == "" {
+		return tea.Quit
+	}
+	dir := filepath.Dir(m.tasksPath)
+	paths := m.config.GitPaths()
+
+	return func() tea.Msg {
+		status, err := git.Status(dir, paths...)
+		return QuitCheckMsg{Status: status, Err: err}
 	}
-	return strings.Split(trimmed, "\n")
 }
 
-// Message types
-type statusMsg string
-type errMsg struct{ err error }
+// integrityCheckCmd returns a command that compares tasks.md with the last commit.
+func (m Model) integrityCheckCmd() tea.Cmd {
+	if m.tasksPath == "" {
+		return nil
+	}
+	dir := filepath.Dir(m.tasksPath)
+	path := filepath.Base(m.tasksPath)
+	lines := countLines(m.content)
 
-// ClearStatusMsg is sent when the status message timeout expires.
-type ClearStatusMsg struct{}
+	return func() tea.Msg {
+		added, deleted, err := git.DiffStat(dir, path)
+		return IntegrityCheckMsg{Added: added, Deleted: deleted, Lines: lines, Err: err}
+	}
+}
 
-// EditFinishedMsg is sent when the editor closes.
-type EditFinishedMsg struct{ Err error }
+// integrityDiffCmd returns a command that collects the changes to tasks.md since the last commit.
+func (m Model) integrityDiffCmd() tea.Cmd {
+	dir := filepath.Dir(m.tasksPath)
+	path := filepath.Base(m.tasksPath)
 
-// ArchiveFinishedMsg is sent when archiving completes.
-type ArchiveFinishedMsg struct {
-	Count int
-	Err   error
+	return func() tea.Msg {
+		diff, err := git.DiffFile(dir, path)
+		return DiffFinishedMsg{Diff: diff, Err: err}
+	}
 }
 
-// ReloadFinishedMsg is sent when reload completes.
-type ReloadFinishedMsg struct {
-	Content string
-	Err     error
+// lostTooManyLines reports whether deleted exceeds integrityLossRatio of the lines
+// in the committed file, reconstructed from the current line count and the diff stat.
+func lostTooManyLines(lines, added, deleted int) bool {
+	committed := lines - added + deleted
+	return committed > 0 && float64(deleted) > integrityLossRatio*float64(committed)
 }
 
-// AddDoneTagsFinishedMsg is sent when adding @done tags completes.
-type AddDoneTagsFinishedMsg struct {
-	Count int
-	Err   error
+// countLines returns the number of lines in content; a trailing newline does not start a new line.
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
 }
 
-// editCmd returns a command that launches the external editor.
-// It uses tea.ExecProcess to suspend the TUI and run the editor.
-func (m Model) editCmd() tea.Cmd {
-	editorCmd := m.config.EditorCommand(m.tasksPath)
-	// Parse the command to get program and args
-	parts := strings.Fields(editorCmd)
+// gitIndicator formats the footer git indicator: "*" for uncommitted changes,
+// "↑N" for commits waiting to be pushed, "✓" when clean and pushed.
+// Empty when no remote is configured or the state could not be read.
+func gitIndicator(msg GitStatusMsg) string {
+	if !msg.HasRemote || msg.Err != nil {
+		return ""
+	}
+	var parts []string
+	if msg.Dirty {
+		parts = append(parts, "*")
+	}
+	if msg.Ahead > 0 {
+		parts = append(parts, "↑"+strconv.Itoa(msg.Ahead))
+	}
 	if len(parts) == 0 {
-		return func() tea.Msg {
-			return EditFinishedMsg{Err: nil}
-		}
+		return "✓"
 	}
-	c := exec.Command(parts[0], parts[1:]...)
-	return tea.ExecProcess(c, func(err error) tea.Msg {
-		return EditFinishedMsg{Err: err}
+	return strings.Join(parts, " ")
+}
+
+// startSync runs git sync in the background. The returned command waits for its
+// first event: a SyncProgressMsg as each step starts, then a SyncFinishedMsg.
+// Each SyncProgressMsg is answered with a wait for the next event.
+func (m Model) startSync() (Model, tea.Cmd) {
+	return m.runSync(m.syncCmd)
+}
+
+// runSync runs the git command built by sync (syncCmd or rebaseCmd) in the background,
+// delivering its events as startSync describes.
+func (m Model) runSync(sync func(ctx context.Context, progress func(step string)) tea.Cmd) (Model, tea.Cmd) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// Room for every step and the result, so the sync never blocks on the TUI
+	events := make(chan tea.Msg, 4)
+	run := sync(ctx, func(step string) {
+		events <- SyncProgressMsg{Step: step}
 	})
+	go func() {
+		events <- run()
+	}()
+
+	m.syncing = true
+	m.syncEvents = events
+	m.syncCancel = cancel
+	return m, waitForSyncEvent(events)
 }
 
-// archiveCmd returns a command that archives old completed tasks.
-func (m Model) archiveCmd() tea.Cmd {
+// waitForSyncEvent returns a command that waits for the next event of a running sync.
+func waitForSyncEvent(events <-chan tea.Msg) tea.Cmd {
+	if events == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// syncCmd returns a command that runs git sync in the working directory, calling
+// progress as each step starts; cancelling ctx stops it.
+// In parent-repo mode only the working directory is committed.
+// git.post_sync_hook runs afterwards when the sync pulled or committed something.
+func (m Model) syncCmd(ctx context.Context, progress func(step string)) tea.Cmd {
+	dir := filepath.Dir(m.tasksPath)
 	tasksPath := m.tasksPath
-	archivePath := m.archivePath
-	delayDays := m.config.Archive.DelayDays
+	paths := m.config.GitPaths()
+	// Validated when the config was loaded
+	hook, _ := m.config.PostSyncHookArgs()
 
 	return func() tea.Msg {
-		// First, add @done tags to newly completed tasks
-		_, err := task.ProcessFileWithDoneTags(tasksPath)
+		if !git.HasRemote(dir, "origin") {
+			return SyncFinishedMsg{NoRemote: true}
+		}
+		before := git.HeadCommit(dir)
+		resolved := 0
+		err := git.SyncContext(ctx, dir, progress, func() error {
+			var err error
+			resolved, err = task.ResolveDoneConflictsFile(tasksPath, m.taskOpts)
+			return err
+		}, paths...)
 		if err != nil {
-			return ArchiveFinishedMsg{Count: 0, Err: err}
+			return syncFailed(dir, err)
 		}
-
-		// Then archive old completed tasks
-		count, err := task.Archive(tasksPath, archivePath, delayDays)
-		return ArchiveFinishedMsg{Count: count, Err: err}
+		_, hookErr := git.PostSyncHook(dir, before, hook, "TTT_TASKS_FILE="+tasksPath)
+		return SyncFinishedMsg{Resolved: resolved, HookErr: hookErr}
 	}
 }
 
-// reloadCmd returns a command that reloads the tasks file.
-func (m Model) reloadCmd() tea.Cmd {
+// rebaseCmd returns a command that rebases the local commits onto the diverged remote
+// branch and pushes them (see git.RebaseAndPush), then runs git.post_sync_hook.
+func (m Model) rebaseCmd(ctx context.Context, progress func(step string)) tea.Cmd {
+	dir := filepath.Dir(m.tasksPath)
 	tasksPath := m.tasksPath
+	hook, _ := m.config.PostSyncHookArgs()
 
 	return func() tea.Msg {
-		content, err := task.LoadFile(tasksPath)
-		return ReloadFinishedMsg{Content: content, Err: err}
+		before := git.HeadCommit(dir)
+		if err := git.RebaseAndPush(ctx, dir, progress); err != nil {
+			return syncFailed(dir, err)
+		}
+		_, hookErr := git.PostSyncHook(dir, before, hook, "TTT_TASKS_FILE="+tasksPath)
+		return SyncFinishedMsg{HookErr: hookErr}
 	}
 }
 
-// addDoneTagsCmd returns a command that adds @done tags to completed tasks.
-func (m Model) addDoneTagsCmd() tea.Cmd {
-	tasksPath := m.tasksPath
-
-	return func() tea.Msg {
-		count, err := task.ProcessFileWithDoneTags(tasksPath)
-		return AddDoneTagsFinishedMsg{Count: count, Err: err}
+// syncFailed is the SyncFinishedMsg for a sync in dir that failed with err, with the
+// rebase hint set when the remote history diverged.
+func syncFailed(dir string, err error) SyncFinishedMsg {
+	msg := SyncFinishedMsg{Err: err}
+	if errors.Is(err, git.ErrNonFastForward) {
+		msg.RebaseHint = git.RebaseHint(dir)
 	}
+	return msg
 }
 
-// addDoneTagsAndReloadCmd returns a command that adds @done tags and then reloads.
-func (m Model) addDoneTagsAndReloadCmd() tea.Cmd {
-	tasksPath := m.tasksPath
+// autoSyncTickCmd schedules the next auto-sync.
+// Returns nil when git.auto_sync_minutes is 0.
+func (m Model) autoSyncTickCmd() tea.Cmd {
+	if m.config.Git.AutoSyncMinutes <= 0 {
+		return nil
+	}
+	return tea.Tick(m.autoSyncDelay(), func(t time.Time) tea.Msg {
+		return AutoSyncTickMsg{}
+	})
+}
 
-	return func() tea.Msg {
-		count, err := task.ProcessFileWithDoneTags(tasksPath)
-		if err != nil {
-			return AddDoneTagsFinishedMsg{Count: 0, Err: err}
-		}
-		return AddDoneTagsFinishedMsg{Count: count, Err: nil}
+// autoSyncDelay returns the delay until the next auto-sync.
+// The configured interval doubles with each consecutive failure, capped at autoSyncMaxBackoff
+// (or the interval itself when it is longer than the cap).
+func (m Model) autoSyncDelay() time.Duration {
+	interval := time.Duration(m.config.Git.AutoSyncMinutes) * time.Minute
+	delay := interval
+	for i := 0; i < m.syncFailures && delay < autoSyncMaxBackoff; i++ {
+		delay *= 2
 	}
+	if delay > autoSyncMaxBackoff && interval < autoSyncMaxBackoff {
+		delay = autoSyncMaxBackoff
+	}
+	return delay
 }
 
 // setStatusWithTimeout sets the status message and returns a command that clears it after timeout.
@@ -441,32 +4010,9 @@ func (m Model) setStatusWithTimeout(status string) (Model, tea.Cmd) {
 
 // overlayHelp renders the help overlay on top of the base view.
 func (m Model) overlayHelp(base string) string {
-	// Build help content with configured keybindings
-	upKeys := formatKeys(m.config.Keybindings.Up, "↑")
-	downKeys := formatKeys(m.config.Keybindings.Down, "↓")
-	topKeys := formatKeys(m.config.Keybindings.Top, "")
-	bottomKeys := formatKeys(m.config.Keybindings.Bottom, "")
-	halfPageUpKeys := formatKeys(m.config.Keybindings.HalfPageUp, "")
-	halfPageDownKeys := formatKeys(m.config.Keybindings.HalfPageDown, "")
-
-	helpLines := []string{
-		"",
-		"  " + padRight(upKeys, 12) + "Scroll up",
-		"  " + padRight(downKeys, 12) + "Scroll down",
-		"  " + padRight(topKeys, 12) + "Go to top",
-		"  " + padRight(bottomKeys, 12) + "Go to bottom",
-		"  " + padRight(halfPageUpKeys, 12) + "Half page up",
-		"  " + padRight(halfPageDownKeys, 12) + "Half page down",
-		"",
-		"  " + padRight("e", 12) + "Open editor",
-		"  " + padRight("a", 12) + "Archive tasks",
-		"  " + padRight("r", 12) + "Reload",
-		"",
-		"  " + padRight("q", 12) + "Quit",
-		"  " + padRight("?/h", 12) + "Help",
-		"",
-		"  Press any key to close",
-	}
+	// The keys of the current mode, with the configured keybindings
+	md := m.currentMode()
+	helpLines := append(m.helpLines(md), "", "  Press any key to close")
 
 	helpContent := strings.Join(helpLines, "\n")
 
@@ -482,7 +4028,7 @@ func (m Model) overlayHelp(base string) string {
 		Align(lipgloss.Center).
 		Width(32)
 
-	helpBox := helpStyle.Render(titleStyle.Render("Help") + helpContent)
+	helpBox := helpStyle.Render(titleStyle.Render("Help: "+md.String()) + helpContent)
 
 	// Center the help box on screen
 	helpWidth := lipgloss.Width(helpBox)
@@ -502,6 +4048,65 @@ func (m Model) overlayHelp(base string) string {
 	return placeOverlay(x, y, helpBox, base)
 }
 
+// diffViewSize returns the inner width and height of the diff overlay viewport.
+// The overlay leaves a one-cell margin around the screen edge.
+func (m Model) diffViewSize() (int, int) {
+	// border (2) + padding (2) + margin (2)
+	width := m.width - 6
+	// border (2) + title (1) + margin (2)
+	height := m.height - 5
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return width, height
+}
+
+// overlayDiff renders the scrollable diff overlay on top of the base view.
+func (m Model) overlayDiff(base string) string {
+	width, _ := m.diffViewSize()
+
+	diffStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		Padding(0, 1)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Align(lipgloss.Center).
+		Width(width)
+
+	diffBox := diffStyle.Render(titleStyle.Render("Diff") + "\n" + m.diffView.View())
+
+	return placeOverlay(1, 1, diffBox, base)
+}
+
+// renderDiff colors a unified diff for display: added lines green, removed lines red.
+// Returns "No changes" when the diff is empty.
+func renderDiff(diff string) string {
+	if diff == "" {
+		return "No changes"
+	}
+
+	addedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	removedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			// File headers are left uncolored
+		case strings.HasPrefix(line, "+"):
+			lines[i] = addedStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = removedStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // formatKeys formats keybindings for display, prepending arrow key if provided.
 func formatKeys(keys []string, arrowKey string) string {
 	if arrowKey != "" && len(keys) > 0 {
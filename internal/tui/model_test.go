@@ -1,13 +1,22 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
 	"github.com/yostos/tiny-task-tool/internal/config"
+	"github.com/yostos/tiny-task-tool/internal/git"
+	"github.com/yostos/tiny-task-tool/internal/task"
 )
 
 // Test constants
@@ -124,6 +133,89 @@ func TestUpdateWindowSize(t *testing.T) {
 	}
 }
 
+// TestUpdateWindowTooSmall verifies that undersized windows render a centered notice
+// that fits the window, and that the normal view returns once the size recovers.
+func TestUpdateWindowTooSmall(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int
+		height int
+	}{
+		{"1x1", 1, 1},
+		{"10x3", 10, 3},
+		{"39x9", 39, 9},
+		{"wide but short", 80, 9},
+		{"tall but narrow", 39, 24},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			m := New(cfg, "- [ ] Task 1\n- [ ] Task 2\n")
+
+			newModel, _ := m.Update(tea.WindowSizeMsg{Width: tt.width, Height: tt.height})
+			m = newModel.(Model)
+
+			view := m.View()
+			lines := strings.Split(view, "\n")
+			if len(lines) > tt.height {
+				t.Errorf("View() has %d lines, want at most %d", len(lines), tt.height)
+			}
+			for i, line := range lines {
+				if w := lipgloss.Width(line); w > tt.width {
+					t.Errorf("line %d width = %d, want at most %d", i, w, tt.width)
+				}
+			}
+			if tt.width >= 10 && !strings.Contains(view, "Terminal") {
+				t.Errorf("View() = %q, want too-small notice", view)
+			}
+
+			// Layout helpers must not panic at any size
+			base := m.viewport.View() + "\n" + m.footerView()
+			_ = m.overlayHelp(base)
+			_ = m.overlayDiff(base)
+
+			newModel, _ = m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+			m = newModel.(Model)
+			view = m.View()
+			if strings.Contains(view, "Terminal too small") || !strings.Contains(view, "Task 1") {
+				t.Errorf("View() after resize = %q, want normal view", view)
+			}
+		})
+	}
+}
+
+// TestViewHeight verifies that the view is exactly as tall as the window at common
+// widths, in normal and select mode: the footer never wraps onto a second line.
+func TestViewHeight(t *testing.T) {
+	for _, width := range []int{40, 60, 80} {
+		for _, selectMode := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%dx14 select %v", width, selectMode), func(t *testing.T) {
+				m := New(config.Default(), "# Tasks\n- [ ] Task 1 @est(1h)\n- [ ] Task 2\n")
+				newModel, _ := m.Update(tea.WindowSizeMsg{Width: width, Height: 14})
+				m = newModel.(Model)
+				m.streakText = "🔥 3 days"
+				if selectMode {
+					newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}})
+					m = newModel.(Model)
+				}
+
+				view := m.View()
+				if got := lipgloss.Height(view); got != m.height {
+					t.Errorf("View() height = %d, want %d:\n%s", got, m.height, view)
+				}
+				footer := m.footerView()
+				if lipgloss.Height(footer) != 1 || lipgloss.Width(footer) != width {
+					t.Errorf("footer = %q, want one line %d wide", footer, width)
+				}
+				if selectMode && !strings.Contains(footer, "-- SELECT -- esc exit") {
+					t.Errorf("footer = %q, want the select mode hints", footer)
+				}
+			})
+		}
+	}
+}
+
 // TestUpdateScroll verifies that Update() handles scroll key presses.
 // Arrow keys and vim-style keys should scroll the viewport.
 func TestUpdateScroll(t *testing.T) {
@@ -189,6 +281,10 @@ func TestMatchKey(t *testing.T) {
 		{"no match", "j", []string{"k"}, false},
 		{"match in list", "k", []string{"j", "k"}, true},
 		{"modifier key match", "ctrl+u", []string{"ctrl+u"}, true},
+		{"capitalized modifier matches", "ctrl+p", []string{"Ctrl+P"}, true},
+		{"capitalized named key matches", "home", []string{"Home"}, true},
+		{"alias matches", "pgup", []string{"PageUp"}, true},
+		{"letter case still matters", "g", []string{"G"}, false},
 		{"empty config", "x", []string{}, false},
 	}
 
@@ -219,6 +315,8 @@ func TestMatchAction(t *testing.T) {
 		{"G maps to bottom", "G", actionBottom},
 		{"ctrl+u maps to half page up", "ctrl+u", actionHalfPageUp},
 		{"ctrl+d maps to half page down", "ctrl+d", actionHalfPageDown},
+		{"home maps to top", "home", actionTop},
+		{"end maps to bottom", "end", actionBottom},
 		{"unknown key maps to none", "x", actionNone},
 	}
 
@@ -353,6 +451,8 @@ func TestUpdateArchiveFinishedMsg(t *testing.T) {
 		expectedStatus string
 	}{
 		{"archived 3 tasks", ArchiveFinishedMsg{Count: 3, Err: nil}, "Archived 3 task(s)"},
+		{"tagged and archived", ArchiveFinishedMsg{Tagged: 2, Count: 3, Err: nil}, "Tagged 2, archived 3"},
+		{"tagged only", ArchiveFinishedMsg{Tagged: 2, Count: 0, Err: nil}, "Tagged 2, no tasks to archive"},
 		{"no tasks to archive", ArchiveFinishedMsg{Count: 0, Err: nil}, "No tasks to archive"},
 	}
 
@@ -361,7 +461,7 @@ func TestUpdateArchiveFinishedMsg(t *testing.T) {
 			newModel, _ := m.Update(tt.msg)
 			updated := newModel.(Model)
 
-			if tt.msg.Count == 0 && updated.status != tt.expectedStatus {
+			if updated.status != tt.expectedStatus {
 				t.Errorf("ArchiveFinishedMsg status = %q, want %q", updated.status, tt.expectedStatus)
 			}
 		})
@@ -753,6 +853,8 @@ func TestUpdateAddDoneTagsFinishedMsg(t *testing.T) {
 	}{
 		{"modified 3 tasks", AddDoneTagsFinishedMsg{Count: 3, Err: nil}, "3 task(s) marked as done"},
 		{"modified 1 task", AddDoneTagsFinishedMsg{Count: 1, Err: nil}, "1 task(s) marked as done"},
+		{"escalated 2 tasks", AddDoneTagsFinishedMsg{Escalated: 2, Err: nil}, "2 task(s) escalated"},
+		{"tagged and escalated", AddDoneTagsFinishedMsg{Count: 1, Escalated: 2, Err: nil}, "1 task(s) marked as done, 2 task(s) escalated"},
 		{"no tasks modified", AddDoneTagsFinishedMsg{Count: 0, Err: nil}, ""},
 	}
 
@@ -762,7 +864,7 @@ func TestUpdateAddDoneTagsFinishedMsg(t *testing.T) {
 			updated := newModel.(Model)
 
 			// Status should be set correctly
-			if tt.msg.Count > 0 && updated.status != tt.expectedStatus {
+			if tt.msg.Count+tt.msg.Escalated > 0 && updated.status != tt.expectedStatus {
 				t.Errorf("AddDoneTagsFinishedMsg status = %q, want %q", updated.status, tt.expectedStatus)
 			}
 
@@ -915,3 +1017,3085 @@ func TestPlaceOverlayWithMultibyteBackground(t *testing.T) {
 		})
 	}
 }
+
+// TestUpdateDiffKey verifies that 'd' key triggers the git diff command.
+func TestUpdateDiffKey(t *testing.T) {
+	cfg := config.Default()
+	m := New(cfg, "- [ ] Task")
+	m.tasksPath = testTasksPath
+
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+
+	if cmd == nil {
+		t.Error("'d' key should return a command for git diff")
+	}
+}
+
+// TestUpdateDiffFinishedMsg verifies that DiffFinishedMsg opens the diff overlay,
+// showing "No changes" for an empty diff and the diff text otherwise.
+func TestUpdateDiffFinishedMsg(t *testing.T) {
+	cfg := config.Default()
+
+	tests := []struct {
+		name     string
+		diff     string
+		expected string
+	}{
+		{"empty diff", "", "No changes"},
+		{"added line", "@@ -1 +1,2 @@\n+- [ ] New task", "+- [ ] New task"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(cfg, "- [ ] Task")
+			newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+			m = newModel.(Model)
+
+			newModel, _ = m.Update(DiffFinishedMsg{Diff: tt.diff})
+			m = newModel.(Model)
+
+			if !m.showDiff {
+				t.Fatal("showDiff should be true after DiffFinishedMsg")
+			}
+			if !strings.Contains(m.View(), tt.expected) {
+				t.Errorf("View() should contain %q", tt.expected)
+			}
+		})
+	}
+}
+
+// TestGitModeInTUI verifies that git is never run with git.mode = "disabled" and that
+// the footer indicator and diff look only at the working dir in parent-repo mode.
+func TestGitModeInTUI(t *testing.T) {
+	root := t.TempDir()
+	for _, args := range [][]string{{"init"}, {"remote", "add", "origin", "https://example.com/dotfiles.git"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v error: %v\n%s", args, err, output)
+		}
+	}
+	dir := filepath.Join(root, ".ttt")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Mkdir() error: %v", err)
+	}
+	tasksPath := filepath.Join(dir, "tasks.md")
+	if err := os.WriteFile(filepath.Join(root, ".zshrc"), []byte("export X=1\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Git.Mode = config.GitModeDisabled
+	m := NewWithPaths(cfg, "", tasksPath, filepath.Join(dir, "archive.md"))
+	if m.gitStatusCmd() != nil {
+		t.Error("gitStatusCmd() should be nil when git is disabled")
+	}
+	if msg, ok := m.diffCmd()().(DiffFinishedMsg); !ok || msg.Err == nil {
+		t.Errorf("diffCmd() = %#v, want an error when git is disabled", msg)
+	}
+
+	cfg.Git.Mode = config.GitModeParentRepo
+	if msg := m.gitStatusCmd()().(GitStatusMsg); !msg.HasRemote || msg.Dirty {
+		t.Errorf("gitStatusCmd() = %#v, want clean: changes outside the working dir don't count", msg)
+	}
+	if msg := m.diffCmd()().(DiffFinishedMsg); msg.Err != nil || msg.Diff != "" {
+		t.Errorf("diffCmd() = %#v, want an empty diff", msg)
+	}
+}
+
+// TestQuitConfirm verifies git.confirm_quit_if_dirty: q quits at once on a clean
+// tree and otherwise asks first; s syncs and quits only if the sync succeeds.
+func TestQuitConfirm(t *testing.T) {
+	dir := t.TempDir()
+	initCmd := exec.Command("git", "init")
+	initCmd.Dir = dir
+	if output, err := initCmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init error: %v\n%s", err, output)
+	}
+	tasksPath := filepath.Join(dir, "tasks.md")
+	if err := os.WriteFile(tasksPath, []byte("- [ ] Task\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	isQuit := func(cmd tea.Cmd) bool {
+		if cmd == nil {
+			return false
+		}
+		_, ok := cmd().(tea.QuitMsg)
+		return ok
+	}
+	press := func(m Model, key string) (Model, tea.Cmd) {
+		newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		return newModel.(Model), cmd
+	}
+
+	cfg := config.Default()
+	m := NewWithPaths(cfg, "- [ ] Task\n", tasksPath, filepath.Join(dir, "archive.md"))
+	if _, cmd := press(m, "q"); !isQuit(cmd) {
+		t.Error("q should quit at once when confirm_quit_if_dirty is off")
+	}
+
+	cfg.Git.ConfirmQuitIfDirty = true
+	m, cmd := press(m, "q")
+	msg, ok := cmd().(QuitCheckMsg)
+	if !ok || msg.Err != nil || !msg.Status.Dirty || msg.Status.HasRemote {
+		t.Fatalf("q with an untracked tasks.md = %#v, want a dirty status without remote", msg)
+	}
+	newModel, _ := m.Update(msg)
+	m = newModel.(Model)
+	if !m.quitPending || !strings.Contains(m.footerView(), quitPromptNoRemote) {
+		t.Fatalf("quitPending = %v, footer = %q", m.quitPending, m.footerView())
+	}
+	if m, cmd = press(m, "s"); m.quitPending || cmd != nil {
+		t.Error("s without a remote should cancel, not sync")
+	}
+
+	newModel, _ = m.Update(QuitCheckMsg{Status: git.RepoStatus{HasRemote: true, Ahead: 2}})
+	m = newModel.(Model)
+	if !strings.Contains(m.footerView(), quitPrompt) {
+		t.Errorf("footer = %q, want %q", m.footerView(), quitPrompt)
+	}
+	m, cmd = press(m, "s")
+	if !m.quitAfterSync || cmd == nil {
+		t.Fatal("s should start a sync before quitting")
+	}
+	// The returned command only clears the status after a timeout; the TUI keeps running
+	newModel, _ = m.Update(SyncFinishedMsg{Err: fmt.Errorf("push failed")})
+	m = newModel.(Model)
+	if m.quitAfterSync || !strings.HasPrefix(m.status, "Sync error:") {
+		t.Errorf("failed sync: status = %q, want an error and no quit", m.status)
+	}
+	m.quitAfterSync = true
+	if _, cmd := m.Update(SyncFinishedMsg{}); !isQuit(cmd) {
+		t.Error("a successful sync after s should quit")
+	}
+
+	newModel, _ = m.Update(QuitCheckMsg{Status: git.RepoStatus{HasRemote: true, Dirty: true}})
+	m = newModel.(Model)
+	if _, cmd := press(m, "y"); !isQuit(cmd) {
+		t.Error("y should quit")
+	}
+	if _, cmd := m.Update(QuitCheckMsg{Status: git.RepoStatus{HasRemote: true}}); !isQuit(cmd) {
+		t.Error("a clean, pushed tree should quit without asking")
+	}
+}
+
+// TestArchiveOnQuit verifies that with archive.on_quit, q archives before quitting,
+// keys wait for it except ctrl+c, and a failed archive cancels the quit.
+func TestArchiveOnQuit(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	archivePath := filepath.Join(dir, "archive.md")
+	content := "- [ ] Open\n- [x] Old @done(2026-01-02)\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	isQuit := func(cmd tea.Cmd) bool {
+		if cmd == nil {
+			return false
+		}
+		_, ok := cmd().(tea.QuitMsg)
+		return ok
+	}
+	press := func(m Model, key string) (Model, tea.Cmd) {
+		newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		return newModel.(Model), cmd
+	}
+
+	cfg := config.Default()
+	cfg.Git.AutoCommit = false
+	cfg.Archive.OnQuit = true
+	m := NewWithPaths(cfg, content, tasksPath, archivePath)
+
+	m, cmd := press(m, "q")
+	if !m.quitArchiving || m.status != "Archiving..." || cmd == nil {
+		t.Fatalf("q = quitArchiving %v, status %q; want the archive to start", m.quitArchiving, m.status)
+	}
+	if waiting, cmd := press(m, "j"); !waiting.quitArchiving || cmd != nil {
+		t.Error("keys should wait for the archive")
+	}
+	newModel, ctrlC := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if !isQuit(ctrlC) || !newModel.(Model).quitArchiving {
+		t.Error("ctrl+c should quit at once while archiving")
+	}
+
+	msg, ok := cmd().(ArchiveFinishedMsg)
+	if !ok || msg.Err != nil || msg.Count != 1 {
+		t.Fatalf("archive on quit = %#v, want 1 task archived", msg)
+	}
+	newModel, cmd = m.Update(msg)
+	if m = newModel.(Model); m.quitArchiving || !isQuit(cmd) {
+		t.Errorf("after the archive: quitArchiving = %v, want to quit", m.quitArchiving)
+	}
+	if archived, _ := os.ReadFile(archivePath); !strings.Contains(string(archived), "Old") {
+		t.Errorf("archive.md = %q, want the old task", archived)
+	}
+
+	m, _ = press(m, "q")
+	newModel, _ = m.Update(ArchiveFinishedMsg{Err: errors.New("disk full")})
+	m = newModel.(Model)
+	if m.quitArchiving || !strings.Contains(m.status, "quit cancelled") || !strings.Contains(m.status, "disk full") {
+		t.Errorf("failed archive: quitArchiving = %v, status %q; want the quit cancelled", m.quitArchiving, m.status)
+	}
+
+	cfg.Archive.OnQuit = false
+	if m, cmd := press(m, "q"); m.quitArchiving || !isQuit(cmd) {
+		t.Error("q should quit at once when archive.on_quit is off")
+	}
+}
+
+// TestUpdateDiffFinishedMsgWithError verifies that diff errors are shown in status
+// and the overlay is not opened.
+func TestUpdateDiffFinishedMsgWithError(t *testing.T) {
+	cfg := config.Default()
+	m := New(cfg, "- [ ] Task")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(DiffFinishedMsg{Err: fmt.Errorf("not a git repository")})
+	m = newModel.(Model)
+
+	if m.showDiff {
+		t.Error("showDiff should be false when diff fails")
+	}
+	if m.status != "Diff error: not a git repository" {
+		t.Errorf("status = %q, want %q", m.status, "Diff error: not a git repository")
+	}
+}
+
+// TestDiffOverlayKeys verifies that q, esc, and d close the diff overlay
+// without quitting, while scroll keys keep it open.
+func TestDiffOverlayKeys(t *testing.T) {
+	cfg := config.Default()
+
+	tests := []struct {
+		name     string
+		key      tea.KeyMsg
+		wantOpen bool
+	}{
+		{"q closes", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}}, false},
+		{"esc closes", tea.KeyMsg{Type: tea.KeyEsc}, false},
+		{"d closes", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}}, false},
+		{"j scrolls", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}}, true},
+		{"down scrolls", tea.KeyMsg{Type: tea.KeyDown}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(cfg, "- [ ] Task")
+			newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+			m = newModel.(Model)
+			newModel, _ = m.Update(DiffFinishedMsg{Diff: strings.Repeat("+line\n", 50)})
+			m = newModel.(Model)
+
+			newModel, cmd := m.Update(tt.key)
+			m = newModel.(Model)
+
+			if m.showDiff != tt.wantOpen {
+				t.Errorf("showDiff = %v, want %v", m.showDiff, tt.wantOpen)
+			}
+			if cmd != nil {
+				t.Error("diff overlay keys should not return a command")
+			}
+		})
+	}
+}
+
+// TestRenderDiff verifies that renderDiff() keeps every diff line and
+// returns "No changes" for an empty diff.
+func TestRenderDiff(t *testing.T) {
+	if got := renderDiff(""); got != "No changes" {
+		t.Errorf("renderDiff(\"\") = %q, want %q", got, "No changes")
+	}
+
+	diff := "--- a/tasks.md\n+++ b/tasks.md\n@@ -1 +1 @@\n-- [ ] Old\n+- [x] Old"
+	got := renderDiff(diff)
+	for _, line := range strings.Split(diff, "\n") {
+		if !strings.Contains(got, line) {
+			t.Errorf("renderDiff() should contain %q", line)
+		}
+	}
+}
+
+// TestLostTooManyLines verifies the 30% deletion threshold of the integrity check.
+func TestLostTooManyLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   int
+		added   int
+		deleted int
+		want    bool
+	}{
+		{"unchanged", 10, 0, 0, false},
+		{"small edit", 10, 1, 1, false},
+		{"exactly 30%", 7, 0, 3, false},
+		{"over 30%", 6, 0, 4, true},
+		{"emptied", 0, 0, 10, true},
+		{"replaced with new lines", 10, 10, 10, true},
+		{"new file", 5, 5, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lostTooManyLines(tt.lines, tt.added, tt.deleted); got != tt.want {
+				t.Errorf("lostTooManyLines(%d, %d, %d) = %v, want %v", tt.lines, tt.added, tt.deleted, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIntegrityWarning verifies that a large loss shows a persistent footer warning
+// and that g then opens the diff instead of scrolling to the top.
+func TestIntegrityWarning(t *testing.T) {
+	cfg := config.Default()
+	m := New(cfg, "- [ ] Task\n")
+	m.tasksPath = testTasksPath
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(IntegrityCheckMsg{Deleted: 1, Lines: 9})
+	m = newModel.(Model)
+	if m.integrityWarning != "" {
+		t.Fatalf("integrityWarning = %q for a small loss, want empty", m.integrityWarning)
+	}
+
+	newModel, _ = m.Update(IntegrityCheckMsg{Deleted: 40, Lines: 1})
+	m = newModel.(Model)
+	want := "tasks.md lost 40 lines since last commit — press g to view diff"
+	if m.integrityWarning != want {
+		t.Fatalf("integrityWarning = %q, want %q", m.integrityWarning, want)
+	}
+	if !strings.Contains(m.footerView(), "tasks.md lost 40 lines") {
+		t.Error("footer should show the integrity warning")
+	}
+
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	m = newModel.(Model)
+	if cmd == nil {
+		t.Fatal("g should return the diff command while the warning is shown")
+	}
+	if m.integrityWarning != "" {
+		t.Errorf("integrityWarning = %q after viewing the diff, want empty", m.integrityWarning)
+	}
+
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	if cmd != nil {
+		t.Error("g should scroll to the top again once the warning is gone")
+	}
+}
+
+// TestInitWithAutoSync verifies that Init() still returns a command when
+// git.auto_sync_minutes is set, and autoSyncTickCmd() is nil when it is 0.
+func TestInitWithAutoSync(t *testing.T) {
+	cfg := config.Default()
+	m := New(cfg, "- [ ] Task")
+	m.tasksPath = testTasksPath
+
+	if m.autoSyncTickCmd() != nil {
+		t.Error("autoSyncTickCmd() should be nil when auto_sync_minutes is 0")
+	}
+
+	cfg.Git.AutoSyncMinutes = 5
+	if m.Init() == nil {
+		t.Error("Init() should return a command when auto-sync is enabled")
+	}
+	if m.autoSyncTickCmd() == nil {
+		t.Error("autoSyncTickCmd() should schedule a tick when auto_sync_minutes > 0")
+	}
+}
+
+// TestAutoSyncTickMsg verifies that a scheduled tick starts a sync only when no sync
+// is running and no key was pressed within the last 30 seconds.
+func TestAutoSyncTickMsg(t *testing.T) {
+	cfg := config.Default()
+	cfg.Git.AutoSyncMinutes = 5
+
+	tests := []struct {
+		name        string
+		syncing     bool
+		lastKey     time.Time
+		wantSyncing bool
+	}{
+		{"idle starts sync", false, time.Time{}, true},
+		{"old keystroke starts sync", false, time.Now().Add(-time.Minute), true},
+		{"recent keystroke postpones", false, time.Now().Add(-10 * time.Second), false},
+		{"running sync is not restarted", true, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(cfg, "- [ ] Task")
+			m.tasksPath = testTasksPath
+			m.syncing = tt.syncing
+			m.lastKeyPress = tt.lastKey
+
+			newModel, cmd := m.Update(AutoSyncTickMsg{})
+			m = newModel.(Model)
+
+			if m.syncing != tt.wantSyncing {
+				t.Errorf("syncing = %v, want %v", m.syncing, tt.wantSyncing)
+			}
+			if cmd == nil {
+				t.Error("AutoSyncTickMsg should return a sync or reschedule command")
+			}
+		})
+	}
+}
+
+// TestSyncProgress verifies that each sync step is shown in the footer while the sync
+// runs, and that Ctrl+C during a sync stops it and quits once it has ended.
+func TestSyncProgress(t *testing.T) {
+	cfg := config.Default()
+	m := New(cfg, "- [ ] Task")
+	m.tasksPath = filepath.Join(t.TempDir(), "tasks.md")
+
+	isQuit := func(cmd tea.Cmd) bool {
+		if cmd == nil {
+			return false
+		}
+		_, ok := cmd().(tea.QuitMsg)
+		return ok
+	}
+
+	// No remote here: the background sync ends right away with NoRemote
+	m, cmd := m.startSync()
+	if !m.syncing || m.syncCancel == nil || cmd == nil {
+		t.Fatal("startSync() should start a cancellable sync and wait for its events")
+	}
+
+	newModel, next := m.Update(SyncProgressMsg{Step: git.SyncPulling})
+	m = newModel.(Model)
+	if m.status != "Pulling..." || !strings.Contains(m.footerView(), "Pulling...") {
+		t.Errorf("status = %q, want %q in the footer", m.status, "Pulling...")
+	}
+	if next == nil {
+		t.Fatal("SyncProgressMsg should wait for the next sync event")
+	}
+	if msg, ok := next().(SyncFinishedMsg); !ok || !msg.NoRemote {
+		t.Errorf("next event = %#v, want SyncFinishedMsg{NoRemote: true}", msg)
+	}
+
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	m = newModel.(Model)
+	if isQuit(cmd) || !m.quitOnSyncStop || m.status != "Stopping sync..." {
+		t.Errorf("Ctrl+C during sync: quit = %v, status = %q; want the sync stopped first", isQuit(cmd), m.status)
+	}
+	if _, cmd := m.Update(SyncFinishedMsg{Err: fmt.Errorf("sync cancelled: context canceled")}); !isQuit(cmd) {
+		t.Error("the TUI should quit once the stopped sync has ended")
+	}
+}
+
+// TestSyncDivergedPrompt verifies the prompt after a push rejected because the remote
+// diverged: any key but r aborts with instructions, r starts a rebase and push.
+func TestSyncDivergedPrompt(t *testing.T) {
+	m := New(config.Default(), "- [ ] Task")
+	m.tasksPath = filepath.Join(t.TempDir(), "tasks.md")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+	m = newModel.(Model)
+
+	diverged := SyncFinishedMsg{
+		Err:        fmt.Errorf("%w; nothing was pushed", git.ErrNonFastForward),
+		RebaseHint: "run 'git pull --rebase origin main' in /tasks, resolve any conflicts, then sync again",
+	}
+	newModel, _ = m.Update(diverged)
+	m = newModel.(Model)
+	if !m.rebasePending || !strings.Contains(m.footerView(), rebasePrompt) {
+		t.Fatalf("diverged sync should show the rebase prompt, footer = %q", m.footerView())
+	}
+	if m.syncFailures != 0 {
+		t.Errorf("syncFailures = %d, a diverged remote is asked about, not counted", m.syncFailures)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(Model)
+	if m.rebasePending || m.status != "Sync aborted, nothing was pushed; "+diverged.RebaseHint {
+		t.Errorf("esc: pending = %v, status = %q", m.rebasePending, m.status)
+	}
+
+	newModel, _ = m.Update(diverged)
+	m = newModel.(Model)
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	m = newModel.(Model)
+	if m.rebasePending || !m.syncing || m.status != "Rebasing..." || cmd == nil {
+		t.Fatalf("r: pending = %v, syncing = %v, status = %q; want a running rebase", m.rebasePending, m.syncing, m.status)
+	}
+	// Not a repository here, so the rebase fails without a hint
+	if msg, ok := cmd().(SyncFinishedMsg); !ok || msg.Err == nil || msg.RebaseHint != "" {
+		t.Errorf("rebase result = %#v, want an error", msg)
+	}
+}
+
+// TestKeyPressRecordsTime verifies that key presses update lastKeyPress,
+// which is used to postpone auto-sync while the user is active.
+func TestKeyPressRecordsTime(t *testing.T) {
+	cfg := config.Default()
+	m := New(cfg, "- [ ] Task")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	m = newModel.(Model)
+
+	if time.Since(m.lastKeyPress) > time.Second {
+		t.Errorf("lastKeyPress = %v, want about now", m.lastKeyPress)
+	}
+}
+
+// TestSyncFinishedMsg verifies status and failure tracking for scheduled syncs:
+// success resets failures, and three consecutive failures show a persistent warning.
+func TestSyncFinishedMsg(t *testing.T) {
+	cfg := config.Default()
+	cfg.Git.AutoSyncMinutes = 5
+	m := New(cfg, "- [ ] Task")
+	m.tasksPath = testTasksPath
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	for i := 1; i <= 3; i++ {
+		m.syncing = true
+		newModel, _ = m.Update(SyncFinishedMsg{Err: fmt.Errorf("push failed")})
+		m = newModel.(Model)
+		if m.syncing {
+			t.Error("syncing should be false after SyncFinishedMsg")
+		}
+		if m.syncFailures != i {
+			t.Errorf("syncFailures = %d, want %d", m.syncFailures, i)
+		}
+	}
+	if m.status != "Sync error: push failed" {
+		t.Errorf("status = %q, want %q", m.status, "Sync error: push failed")
+	}
+	if m.syncWarning != "Auto-sync failing (3 times)" {
+		t.Errorf("syncWarning = %q, want %q", m.syncWarning, "Auto-sync failing (3 times)")
+	}
+
+	// Warning stays in the footer after the status clears
+	newModel, _ = m.Update(ClearStatusMsg{})
+	m = newModel.(Model)
+	if !strings.Contains(m.View(), "Auto-sync failing (3 times)") {
+		t.Error("View() should show the persistent sync warning")
+	}
+
+	newModel, cmd := m.Update(SyncFinishedMsg{})
+	m = newModel.(Model)
+	if m.syncFailures != 0 || m.syncWarning != "" {
+		t.Errorf("success should reset failures, got failures=%d warning=%q", m.syncFailures, m.syncWarning)
+	}
+	if m.status != "Synced" {
+		t.Errorf("status = %q, want %q", m.status, "Synced")
+	}
+	if cmd == nil {
+		t.Error("successful sync should return reload and reschedule commands")
+	}
+
+	newModel, _ = m.Update(SyncFinishedMsg{Resolved: 2})
+	m = newModel.(Model)
+	if want := "Synced, merged 2 task(s) done on several devices"; m.reloadStatus != want {
+		t.Errorf("reloadStatus = %q, want %q", m.reloadStatus, want)
+	}
+
+	// A failing post-sync hook is a warning; the sync still counts as successful
+	m.syncFailures = 1
+	newModel, _ = m.Update(SyncFinishedMsg{HookErr: fmt.Errorf("exit status 1")})
+	m = newModel.(Model)
+	if want := "Synced, but post-sync hook failed: exit status 1"; m.reloadStatus != want {
+		t.Errorf("reloadStatus = %q, want %q", m.reloadStatus, want)
+	}
+	if m.syncFailures != 0 {
+		t.Errorf("syncFailures = %d after a hook failure, want 0", m.syncFailures)
+	}
+}
+
+// TestAutoSyncDelay verifies exponential backoff: the interval doubles with each
+// consecutive failure and is capped at one hour.
+func TestAutoSyncDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		minutes  int
+		failures int
+		expected time.Duration
+	}{
+		{"no failures", 5, 0, 5 * time.Minute},
+		{"one failure", 5, 1, 10 * time.Minute},
+		{"three failures", 5, 3, 40 * time.Minute},
+		{"capped at an hour", 5, 10, time.Hour},
+		{"interval above cap is kept", 90, 2, 90 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Git.AutoSyncMinutes = tt.minutes
+			m := New(cfg, "")
+			m.syncFailures = tt.failures
+
+			if got := m.autoSyncDelay(); got != tt.expected {
+				t.Errorf("autoSyncDelay() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestViewRelativeDoneDate verifies that display.relative_done_date renders @done dates
+// relative to today in the view while leaving the model content unchanged.
+func TestViewRelativeDoneDate(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	content := "- [x] Task @done(" + today + ")"
+
+	tests := []struct {
+		name     string
+		relative bool
+		expected string
+	}{
+		{"absolute by default", false, "@done(" + today + ")"},
+		{"relative when enabled", true, "@done(今日)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Display.RelativeDoneDate = tt.relative
+			m := New(cfg, content)
+			newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+			m = newModel.(Model)
+
+			if !strings.Contains(m.View(), tt.expected) {
+				t.Errorf("View() should contain %q", tt.expected)
+			}
+			if m.content != content {
+				t.Errorf("content = %q, want unchanged %q", m.content, content)
+			}
+
+			newModel, _ = m.Update(ReloadFinishedMsg{Content: content})
+			m = newModel.(Model)
+			if !strings.Contains(m.View(), tt.expected) {
+				t.Errorf("View() after reload should contain %q", tt.expected)
+			}
+		})
+	}
+}
+
+// TestFilterKeys verifies that a number key applies the saved filter bound to it,
+// the footer shows "[filter: <name>]", and "0" clears the filter.
+func TestFilterKeys(t *testing.T) {
+	cfg := config.Default()
+	cfg.UI.Filters = []config.FilterConfig{
+		{Name: "waiting", Query: "@waiting", Key: "1"},
+	}
+	content := "# Work\n- [ ] Review @waiting\n- [ ] Write docs\n"
+	m := New(cfg, content)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'1'}})
+	m = newModel.(Model)
+
+	view := m.View()
+	if !strings.Contains(view, "[filter: waiting]") {
+		t.Error("footer should show [filter: waiting]")
+	}
+	if !strings.Contains(view, "Review @waiting") {
+		t.Error("filtered view should contain matching task")
+	}
+	if strings.Contains(view, "Write docs") {
+		t.Error("filtered view should not contain non-matching task")
+	}
+	if m.content != content {
+		t.Error("filtering should not change the model content")
+	}
+
+	// Unbound number keys do nothing
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'5'}})
+	m = newModel.(Model)
+	if m.filterName != "waiting" {
+		t.Errorf("filterName = %q, want %q after unbound key", m.filterName, "waiting")
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'0'}})
+	m = newModel.(Model)
+
+	view = m.View()
+	if strings.Contains(view, "[filter:") {
+		t.Error("footer should not show a filter after 0")
+	}
+	if !strings.Contains(view, "Write docs") {
+		t.Error("unfiltered view should contain all tasks")
+	}
+}
+
+// TestFilterPersistsAcrossReload verifies that the active filter is reapplied
+// when the file is reloaded.
+func TestFilterPersistsAcrossReload(t *testing.T) {
+	cfg := config.Default()
+	cfg.UI.Filters = []config.FilterConfig{
+		{Name: "waiting", Query: "@waiting", Key: "1"},
+	}
+	m := New(cfg, "- [ ] Review @waiting\n")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'1'}})
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(ReloadFinishedMsg{Content: "- [ ] Review @waiting\n- [ ] New task\n"})
+	m = newModel.(Model)
+
+	if strings.Contains(m.View(), "New task") {
+		t.Error("filter should still apply after reload")
+	}
+}
+
+// TestHelpOverlayListsFilters verifies that configured filters and "0 Clear filter"
+// appear in the help overlay.
+func TestHelpOverlayListsFilters(t *testing.T) {
+	cfg := config.Default()
+	cfg.UI.Filters = []config.FilterConfig{
+		{Name: "waiting", Query: "@waiting", Key: "1"},
+	}
+	m := New(cfg, "- [ ] Task")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	m = newModel.(Model)
+	m.showHelp = true
+
+	view := m.View()
+	if !strings.Contains(view, "Filter: waiting") {
+		t.Error("help overlay should list configured filters")
+	}
+	if !strings.Contains(view, "Clear filter") {
+		t.Error("help overlay should list the clear filter key")
+	}
+}
+
+// TestLineColor verifies priority coloring: A/B/C use the theme colors, completed tasks
+// use the done color even when they have a priority, and other lines are uncolored.
+func TestLineColor(t *testing.T) {
+	cfg := config.Default()
+	cfg.Theme.PriorityB = "#ffaa00"
+	m := New(cfg, "")
+
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+		colored  bool
+	}{
+		{"priority A is red", "- [ ] Ship @priority(A)", "1", true},
+		{"priority B uses override", "- [ ] Review @priority(B)", "#ffaa00", true},
+		{"priority C is blue", "- [ ] Tidy @priority(C)", "4", true},
+		{"no priority", "- [ ] Plain task", "", false},
+		{"completed beats priority", "- [x] Ship @priority(A) @done(2026-01-18)", "240", true},
+		{"completed without priority", "- [x] Done @done(2026-01-18)", "240", true},
+		{"heading with priority text", "# Notes about @priority(A)", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			color, ok := m.lineColor(tt.line)
+			if ok != tt.colored || string(color) != tt.expected {
+				t.Errorf("lineColor(%q) = (%q, %v), want (%q, %v)", tt.line, color, ok, tt.expected, tt.colored)
+			}
+		})
+	}
+}
+
+// TestLineColorLongTask verifies that display.warn_long_tasks shows open tasks longer
+// than tasks.max_task_length in the warning color, over their priority color.
+func TestLineColorLongTask(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tasks.MaxTaskLength = 10
+	cfg.Display.WarnLongTasks = true
+	m := New(cfg, "")
+
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+		colored  bool
+	}{
+		{"long task", "- [ ] buy milk and bread", warningColor, true},
+		{"long beats priority", "- [ ] buy milk and bread @priority(A)", warningColor, true},
+		{"CJK counts two columns", "- [ ] 牛乳とパンを買う", warningColor, true},
+		{"short task", "- [ ] buy milk @due(2026-10-20)", "", false},
+		{"long completed task", "- [x] buy milk and bread @done(2026-01-18)", "240", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			color, ok := m.lineColor(tt.line)
+			if ok != tt.colored || string(color) != tt.expected {
+				t.Errorf("lineColor(%q) = (%q, %v), want (%q, %v)", tt.line, color, ok, tt.expected, tt.colored)
+			}
+		})
+	}
+
+	m.config.Display.WarnLongTasks = false
+	if _, ok := m.lineColor("- [ ] buy milk and bread"); ok {
+		t.Error("lineColor() colored a long task with warn_long_tasks off")
+	}
+}
+
+// TestColorizeKeepsText verifies that colorize() keeps every line's text intact.
+func TestColorizeKeepsText(t *testing.T) {
+	cfg := config.Default()
+	m := New(cfg, "")
+	content := "# Tasks\n- [ ] Ship @priority(A)\n\t- [x] Sub @done(2026-01-18)"
+
+	got := m.colorize(content)
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.Contains(got, line) {
+			t.Errorf("colorize() should contain %q", line)
+		}
+	}
+}
+
+// TestGuardBlocksNonTaskFile verifies that @done tagging and archiving are not run
+// on a large file with almost no tasks, and that the guard passes for a task list.
+func TestGuardBlocksNonTaskFile(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	archivePath := filepath.Join(dir, "archive.md")
+	readme := strings.Repeat("Some documentation line\n", 300) + "- [x] Example checkbox\n"
+	if err := os.WriteFile(tasksPath, []byte(readme), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), readme, tasksPath, archivePath)
+
+	if msg, ok := m.addDoneTagsCmd()().(GuardBlockedMsg); !ok || msg.Op != guardOpDoneTags {
+		t.Errorf("addDoneTagsCmd() = %#v, want GuardBlockedMsg{Op: done-tags}", msg)
+	}
+	if msg, ok := m.archiveCmd()().(GuardBlockedMsg); !ok || msg.Op != guardOpArchive {
+		t.Errorf("archiveCmd() = %#v, want GuardBlockedMsg{Op: archive}", msg)
+	}
+
+	got, _ := os.ReadFile(tasksPath)
+	if string(got) != readme {
+		t.Error("guarded file should not be modified")
+	}
+
+	if err := os.WriteFile(tasksPath, []byte("- [ ] Task\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if _, ok := m.addDoneTagsCmd()().(AddDoneTagsFinishedMsg); !ok {
+		t.Error("addDoneTagsCmd() should run on a task list")
+	}
+}
+
+// TestGuardPrompt verifies that GuardBlockedMsg shows a y/n prompt, that "y" confirms
+// and reruns the operation, and that any other key cancels it.
+func TestGuardPrompt(t *testing.T) {
+	cfg := config.Default()
+
+	tests := []struct {
+		name          string
+		key           rune
+		wantConfirmed bool
+	}{
+		{"confirm", 'y', true},
+		{"cancel", 'n', false},
+		{"other key cancels", 'q', false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewWithPaths(cfg, "- [ ] Task", testTasksPath, testArchivePath)
+			newModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+			m = newModel.(Model)
+
+			newModel, _ = m.Update(GuardBlockedMsg{Op: guardOpArchive})
+			m = newModel.(Model)
+			if !strings.Contains(m.View(), "Modify anyway? (y/n)") {
+				t.Fatal("View() should show the guard prompt")
+			}
+
+			newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{tt.key}})
+			m = newModel.(Model)
+
+			if m.guardPending != "" {
+				t.Error("guardPending should be cleared after answering")
+			}
+			if m.guardConfirmed != tt.wantConfirmed {
+				t.Errorf("guardConfirmed = %v, want %v", m.guardConfirmed, tt.wantConfirmed)
+			}
+			if cmd == nil {
+				t.Error("answering should return the operation or the status timeout")
+			}
+			if !tt.wantConfirmed && m.status != "Cancelled" {
+				t.Errorf("status = %q, want %q", m.status, "Cancelled")
+			}
+		})
+	}
+}
+
+// TestGuardPromptAfterEdit verifies that confirming the guard after the editor
+// reruns the tagging with reload, so the status still reports the edited lines.
+func TestGuardPromptAfterEdit(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	edited := strings.Repeat("Some documentation line\n", 300) + "- [x] Example checkbox\n"
+	if err := os.WriteFile(tasksPath, []byte(edited), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), "- [ ] Task", tasksPath, filepath.Join(dir, "archive.md"))
+	m.editing = true
+	m.preEditContent = "- [ ] Task"
+
+	msg := m.addDoneTagsAndReloadCmd()()
+	if blocked, ok := msg.(GuardBlockedMsg); !ok || blocked.Op != guardOpEditDoneTags {
+		t.Fatalf("addDoneTagsAndReloadCmd() = %#v, want GuardBlockedMsg{Op: edit-done-tags}", msg)
+	}
+	newModel, _ := m.Update(msg)
+	m = newModel.(Model)
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	m = newModel.(Model)
+	if cmd == nil {
+		t.Fatal("confirming should rerun the tagging")
+	}
+
+	newModel, _ = m.Update(cmd())
+	m = newModel.(Model)
+	if !strings.HasPrefix(m.reloadStatus, "File reloaded (") || !strings.Contains(m.reloadStatus, "1 task(s) marked as done") {
+		t.Errorf("reloadStatus = %q, want the edited lines and the tagged task", m.reloadStatus)
+	}
+}
+
+// TestEditStatus verifies the status message after returning from the editor
+// for each combination of @done tagging and line changes.
+func TestEditStatus(t *testing.T) {
+	tests := []struct {
+		name                              string
+		tagged, escalated, added, removed int
+		expected                          string
+	}{
+		{"tags only", 2, 0, 0, 0, "2 task(s) marked as done"},
+		{"edits only", 0, 0, 2, 1, "File reloaded (2 lines added, 1 removed)"},
+		{"both", 1, 0, 1, 1, "File reloaded (1 lines added, 1 removed), 1 task(s) marked as done"},
+		{"escalated", 0, 2, 1, 0, "File reloaded (1 lines added, 0 removed), 2 task(s) escalated"},
+		{"neither", 0, 0, 0, 0, "No changes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := editStatus(tt.tagged, tt.escalated, tt.added, tt.removed); got != tt.expected {
+				t.Errorf("editStatus() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestEditRoundTripStatus verifies that the status after an edit is computed from the
+// pre-edit content and the edited file, and shown once the reload finishes.
+func TestEditRoundTripStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		edited   string
+		tagged   int
+		reloaded string
+		expected string
+	}{
+		{"tags only", "- [x] Task", 1, "- [x] Task @done(2026-01-21)", "1 task(s) marked as done"},
+		{"edits only", "- [ ] Task\n- [ ] New", 0, "- [ ] Task\n- [ ] New", "File reloaded (1 lines added, 0 removed)"},
+		{"both", "- [x] Task\n- [ ] New", 1, "- [x] Task @done(2026-01-21)\n- [ ] New", "File reloaded (2 lines added, 1 removed), 1 task(s) marked as done"},
+		{"neither", "- [ ] Task", 0, "- [ ] Task", "No changes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// "tags only" starts from an already checked task that lacks its @done tag
+			before := "- [ ] Task"
+			if tt.name == "tags only" {
+				before = "- [x] Task"
+			}
+			m := NewWithPaths(config.Default(), before, testTasksPath, testArchivePath)
+			newModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+			m = newModel.(Model)
+
+			newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+			m = newModel.(Model)
+
+			newModel, _ = m.Update(AddDoneTagsFinishedMsg{Count: tt.tagged, Content: tt.edited})
+			m = newModel.(Model)
+
+			newModel, _ = m.Update(ReloadFinishedMsg{Content: tt.reloaded})
+			m = newModel.(Model)
+
+			if m.status != tt.expected {
+				t.Errorf("status = %q, want %q", m.status, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSelectMode verifies that v enters select mode at the top visible line,
+// navigation keys move the cursor instead of scrolling, and esc leaves select mode.
+func TestSelectMode(t *testing.T) {
+	content := strings.Repeat("- [ ] Task\n", 30)
+	m := New(config.Default(), content)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 11})
+	m = newModel.(Model)
+
+	press := func(key string) {
+		t.Helper()
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		if key == "esc" {
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		}
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+
+	press("v")
+	if !m.cursorMode || m.cursor != 0 {
+		t.Fatalf("after v: cursorMode=%v cursor=%d, want true, 0", m.cursorMode, m.cursor)
+	}
+
+	press("j")
+	press("j")
+	if m.cursor != 2 || m.viewport.YOffset != 0 {
+		t.Errorf("after jj: cursor=%d offset=%d, want 2, 0", m.cursor, m.viewport.YOffset)
+	}
+
+	press("G")
+	if m.cursor != 29 {
+		t.Errorf("after G: cursor=%d, want 29", m.cursor)
+	}
+	if m.cursor < m.viewport.YOffset || m.cursor >= m.viewport.YOffset+m.viewport.Height {
+		t.Errorf("cursor %d should be visible (offset %d, height %d)", m.cursor, m.viewport.YOffset, m.viewport.Height)
+	}
+
+	press("k")
+	if m.cursor != 28 {
+		t.Errorf("after k: cursor=%d, want 28", m.cursor)
+	}
+
+	press("esc")
+	if m.cursorMode {
+		t.Error("esc should leave select mode")
+	}
+}
+
+// TestSelectModeSkipsFilteredLines verifies that the cursor only moves between
+// lines shown by the active filter.
+func TestSelectModeSkipsFilteredLines(t *testing.T) {
+	cfg := config.Default()
+	cfg.UI.Filters = []config.FilterConfig{{Name: "home", Query: "@home", Key: "1"}}
+	m := New(cfg, "- [ ] a @home\n- [ ] b\n- [ ] c @home\n")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	for _, key := range []string{"1", "v", "j"} {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = newModel.(Model)
+	}
+
+	if m.cursor != 2 {
+		t.Errorf("cursor = %d, want 2 (line 1 is filtered out)", m.cursor)
+	}
+}
+
+// TestOpenLimit verifies the footer's open task count with tasks.open_limit and the
+// warning shown by the first load only.
+func TestOpenLimit(t *testing.T) {
+	content := "- [ ] A\n  - [ ] A1\n- [x] B @done(2026-01-18)\n- [ ] C\n"
+	cfg := config.Default()
+	cfg.Tasks.OpenLimit = 2
+	m := New(cfg, content)
+	update := func(msg tea.Msg) {
+		t.Helper()
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+	update(tea.WindowSizeMsg{Width: 100, Height: 10})
+
+	if !strings.Contains(m.footerView(), "3 open") {
+		t.Errorf("footer = %q, want the open count", m.footerView())
+	}
+	if w := lipgloss.Width(m.footerView()); w != 100 {
+		t.Errorf("footer width above the limit = %d, want 100", w)
+	}
+	update(ReloadFinishedMsg{Content: content})
+	if want := "You have 3 open tasks (limit 2) — consider archiving or pruning"; m.status != want {
+		t.Errorf("status after first load = %q, want %q", m.status, want)
+	}
+	update(ReloadFinishedMsg{Content: content})
+	if m.status != "Reloaded" {
+		t.Errorf("status after second load = %q, want Reloaded", m.status)
+	}
+	update(ReloadFinishedMsg{Content: "- [ ] A\n"})
+	if !strings.Contains(m.footerView(), "1 open") {
+		t.Errorf("footer = %q, want the new open count", m.footerView())
+	}
+
+	cfg = config.Default()
+	m = New(cfg, content)
+	update(tea.WindowSizeMsg{Width: 100, Height: 10})
+	update(ReloadFinishedMsg{Content: content})
+	if strings.Contains(m.footerView(), " open") || m.status != "Reloaded" {
+		t.Errorf("open_limit off: footer %q, status %q; want no count or warning", m.footerView(), m.status)
+	}
+}
+
+// TestHideFuture verifies that display.hide_future hides open tasks starting after
+// today with their subtasks, counts them in the footer, and that f shows them again.
+func TestHideFuture(t *testing.T) {
+	later := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	today := time.Now().Format("2006-01-02")
+	content := "# Tasks\n- [ ] Later @start(" + later + ")\n  - [ ] Sub\n- [ ] Now @start(" + today + ")\n- [ ] Plain"
+	cfg := config.Default()
+	cfg.Display.HideFuture = true
+	m := New(cfg, content)
+	update := func(msg tea.Msg) {
+		t.Helper()
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+	press := func(key string) {
+		t.Helper()
+		update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	}
+	update(tea.WindowSizeMsg{Width: 80, Height: 10})
+
+	if got := fmt.Sprint(m.visibleLines()); got != "[0 3 4]" {
+		t.Errorf("visible lines = %s, want [0 3 4]", got)
+	}
+	if !strings.Contains(m.footerView(), "[2 hidden]") {
+		t.Errorf("footer = %q, want [2 hidden]", m.footerView())
+	}
+
+	press("f")
+	if got := fmt.Sprint(m.visibleLines()); got != "[0 1 2 3 4]" || m.status != "Showing future tasks" {
+		t.Errorf("after f: lines %s, status %q; want all lines shown", got, m.status)
+	}
+	if strings.Contains(m.footerView(), "hidden") {
+		t.Errorf("footer = %q, want no hidden count while shown", m.footerView())
+	}
+
+	// In select mode, hiding again moves the cursor off a hidden task
+	press("v")
+	press("j")
+	if m.cursor != 1 {
+		t.Fatalf("cursor = %d, want 1", m.cursor)
+	}
+	press("f")
+	if m.cursor != 3 || m.status != "Hiding future tasks" {
+		t.Errorf("after f again: cursor %d, status %q; want 3, Hiding future tasks", m.cursor, m.status)
+	}
+
+	cfg = config.Default()
+	m = New(cfg, content)
+	update(tea.WindowSizeMsg{Width: 80, Height: 10})
+	if got := fmt.Sprint(m.visibleLines()); got != "[0 1 2 3 4]" || strings.Contains(m.footerView(), "hidden") {
+		t.Errorf("hide_future off: lines %s, footer %q; want everything shown", got, m.footerView())
+	}
+}
+
+// TestQuickFilter verifies that T shows the tasks completed today with their parent
+// tasks, that T again returns to the previous filter and scroll position, and that
+// the midnight tick keeps running while the quick filter is on.
+func TestQuickFilter(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	content := strings.Repeat("- [ ] Open @home\n", 20) +
+		"# Work\n- [ ] Release @home\n  - [x] Changelog @done(" + today + ")\n- [x] Old @done(2020-01-01)\n"
+	cfg := config.Default()
+	cfg.UI.Filters = []config.FilterConfig{{Name: "home", Query: "@home", Key: "1"}}
+	m := New(cfg, content)
+	update := func(msg tea.Msg) tea.Cmd {
+		t.Helper()
+		newModel, cmd := m.Update(msg)
+		m = newModel.(Model)
+		return cmd
+	}
+	press := func(key string) tea.Cmd {
+		t.Helper()
+		return update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	}
+
+	update(tea.WindowSizeMsg{Width: 80, Height: 10})
+	press("1")
+	press("j")
+	press("j")
+	press("j")
+
+	if cmd := press("T"); cmd == nil || !m.midnightPending {
+		t.Error("T should schedule the midnight tick")
+	}
+	if got := fmt.Sprint(m.visibleLines()); m.filterName != "done today" || got != "[20 21 22]" || m.viewport.YOffset != 0 {
+		t.Errorf("after T: filter %q, lines %s, offset %d; want done today, [20 21 22], 0", m.filterName, got, m.viewport.YOffset)
+	}
+	if !strings.Contains(m.footerView(), "[filter: done today]") {
+		t.Errorf("footer = %q, want the quick filter shown", m.footerView())
+	}
+
+	// Midnight applies the filter again and schedules the next tick
+	if cmd := update(MidnightMsg{}); cmd == nil || !m.midnightPending {
+		t.Error("MidnightMsg with the quick filter on should schedule the next tick")
+	}
+
+	press("T")
+	if m.filterName != "home" || m.viewport.YOffset != 3 {
+		t.Errorf("T again: filter %q, offset %d; want home, 3", m.filterName, m.viewport.YOffset)
+	}
+
+	// Without the quick filter, the tick ends
+	m.midnightPending = true
+	if cmd := update(MidnightMsg{}); cmd != nil || m.midnightPending {
+		t.Error("MidnightMsg without the quick filter should not schedule another tick")
+	}
+
+	m.content = strings.ReplaceAll(content, today, "2020-01-02")
+	m.lines = parseLines(m.content)
+	press("T")
+	if m.filterName != "home" || m.status != "No tasks completed today" {
+		t.Errorf("T with nothing done today: filter %q, status %q", m.filterName, m.status)
+	}
+}
+
+// TestSelectModeScroll verifies that scrolling the view in select mode keeps the
+// cursor on screen, that . centers the view on the cursor, and that a reload which
+// shortens the file scrolls back to the cursor.
+func TestSelectModeScroll(t *testing.T) {
+	cfg := config.Default()
+	cfg.UI.Filters = []config.FilterConfig{{Name: "home", Query: "@home", Key: "1"}}
+	content := strings.Repeat("- [ ] Task @home\n", 30)
+	m := New(cfg, content)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 11})
+	m = newModel.(Model)
+
+	press := func(msg tea.KeyMsg) {
+		t.Helper()
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+	runes := func(key string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)} }
+
+	tests := []struct {
+		name       string
+		key        tea.KeyMsg
+		wantCursor int
+		wantOffset int
+	}{
+		{"enter select mode", runes("v"), 0, 0},
+		{"cursor inside the view", runes("j"), 1, 0},
+		{"page down clamps to the top row", tea.KeyMsg{Type: tea.KeyPgDown}, 10, 10},
+		{"page down again", tea.KeyMsg{Type: tea.KeyPgDown}, 20, 20},
+		{"page down at the bottom", tea.KeyMsg{Type: tea.KeyPgDown}, 20, 20},
+		{"last line", runes("G"), 29, 20},
+		{"page up clamps to the bottom row", tea.KeyMsg{Type: tea.KeyPgUp}, 19, 10},
+		{"center", runes("."), 19, 14},
+		{"first line", runes("g"), 0, 0},
+		{"center near the top", runes("."), 0, 0},
+	}
+
+	press(runes("1"))
+	for _, tt := range tests {
+		press(tt.key)
+		if m.cursor != tt.wantCursor || m.viewport.YOffset != tt.wantOffset {
+			t.Errorf("%s: cursor=%d offset=%d, want %d, %d", tt.name, m.cursor, m.viewport.YOffset, tt.wantCursor, tt.wantOffset)
+		}
+	}
+
+	inView := func(name string) {
+		t.Helper()
+		row := m.cursorRow(m.visibleLines())
+		if row < m.viewport.YOffset || row >= m.viewport.YOffset+m.viewport.Height {
+			t.Errorf("%s: cursor row %d out of view (offset %d, height %d)", name, row, m.viewport.YOffset, m.viewport.Height)
+		}
+	}
+
+	// A reload that shortens the file moves the cursor to the last line
+	press(runes("G"))
+	newModel, _ = m.Update(ReloadFinishedMsg{Content: strings.Repeat("- [ ] Task @home\n", 12)})
+	m = newModel.(Model)
+	if m.cursor != 11 {
+		t.Errorf("after shortening reload: cursor=%d, want 11", m.cursor)
+	}
+	inView("after shortening reload")
+
+	// A reload that hides the lines above the cursor scrolls up to it
+	newModel, _ = m.Update(ReloadFinishedMsg{Content: content})
+	m = newModel.(Model)
+	press(runes("G"))
+	press(runes("k"))
+	press(runes("k"))
+	press(runes("k"))
+	press(runes("k"))
+	if m.cursor != 25 || m.viewport.YOffset != 20 {
+		t.Fatalf("before reload: cursor=%d offset=%d, want 25, 20", m.cursor, m.viewport.YOffset)
+	}
+	newContent := strings.Repeat("- [ ] Task\n", 20) + strings.Repeat("- [ ] Task @home\n", 40)
+	newModel, _ = m.Update(ReloadFinishedMsg{Content: newContent})
+	m = newModel.(Model)
+	if m.cursor != 25 {
+		t.Errorf("after hiding reload: cursor=%d, want 25", m.cursor)
+	}
+	inView("after hiding reload")
+}
+
+// TestToggleChildrenKey verifies that X toggles the subtasks of the selected task
+// on disk, and that X outside select mode only shows a hint.
+func TestToggleChildrenKey(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "- [ ] Parent\n  - [ ] Child 1\n  - [x] Child 2 @done(2026-01-19)\n- [ ] Other\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'X'}})
+	m = newModel.(Model)
+	if m.status != "Press v to select a task first" {
+		t.Errorf("status = %q, want hint to enter select mode", m.status)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}})
+	m = newModel.(Model)
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'X'}})
+	if cmd == nil {
+		t.Fatal("X in select mode should return a command")
+	}
+
+	msg, ok := cmd().(ToggleChildrenFinishedMsg)
+	if !ok || msg.Err != nil || msg.Count != 1 || !msg.Completed {
+		t.Fatalf("toggle result = %#v, want 1 subtask completed", msg)
+	}
+	got, _ := os.ReadFile(tasksPath)
+	if !strings.HasPrefix(string(got), "- [ ] Parent\n  - [x] Child 1 @done(") {
+		t.Errorf("tasks.md after toggle =\n%s", got)
+	}
+
+	newModel, _ = m.Update(msg)
+	m = newModel.(Model)
+	if m.reloadStatus != "Completed 1 subtask(s)" {
+		t.Errorf("reloadStatus = %q, want %q", m.reloadStatus, "Completed 1 subtask(s)")
+	}
+}
+
+// TestImportClipboard verifies that P in select mode turns the clipboard's lines into
+// tasks below the selected task, indented like it, and reports an empty clipboard.
+func TestImportClipboard(t *testing.T) {
+	clipboard := "Buy milk\n* Call Bob\n  - [x] Called\n"
+	var clipboardErr error
+	orig := readClipboard
+	readClipboard = func() (string, error) { return clipboard, clipboardErr }
+	defer func() { readClipboard = orig }()
+
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "## Errands\n  - [ ] a\n    - [ ] a1\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	m := NewWithPaths(config.Default(), content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+	press := func(m Model, key string) (Model, tea.Cmd) {
+		newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		return newModel.(Model), cmd
+	}
+
+	if m, _ := press(m, "P"); m.status != "Press v to select a task first" {
+		t.Errorf("P outside select mode: status = %q", m.status)
+	}
+
+	m, _ = press(m, "v")
+	m.cursor = 1
+	m, cmd := press(m, "P")
+	if cmd == nil {
+		t.Fatal("P in select mode should return a command")
+	}
+	msg, ok := cmd().(PasteFinishedMsg)
+	if !ok || msg.Err != nil || msg.Count != 3 || !msg.Clipboard {
+		t.Fatalf("import result = %#v, want 3 tasks from the clipboard", msg)
+	}
+	want := "## Errands\n  - [ ] a\n    - [ ] a1\n  - [ ] Buy milk\n  - [ ] Call Bob\n    - [x] Called\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md after P = %q, want %q", got, want)
+	}
+	newModel, cmd = m.Update(msg)
+	m = newModel.(Model)
+	if m.reloadStatus != "Imported 3 task(s) from the clipboard" || cmd == nil {
+		t.Errorf("reloadStatus = %q, want the import reported and a reload", m.reloadStatus)
+	}
+
+	clipboard = "\n\n"
+	m.lines = parseLines(want)
+	_, cmd = press(m, "P")
+	if msg, ok := cmd().(PasteFinishedMsg); !ok || msg.Err == nil || !strings.Contains(msg.Err.Error(), "empty") {
+		t.Errorf("import of an empty clipboard = %#v, want an error", msg)
+	}
+
+	clipboardErr = errors.New("no clipboard command found")
+	_, cmd = press(m, "P")
+	if msg, ok := cmd().(PasteFinishedMsg); !ok || msg.Err != clipboardErr {
+		t.Errorf("import without a clipboard = %#v, want its error", msg)
+	}
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("failed imports changed tasks.md to %q", got)
+	}
+}
+
+// TestYankAndPaste verifies that d cuts the selected task with its subtasks into the
+// yank buffer, y copies a task there, and p pastes the buffer below the selected line,
+// indented like it, as often as wanted.
+func TestYankAndPaste(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "- [ ] a\n  - [ ] a1\n- [ ] b\n  - [ ] b1\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	press := func(m Model, key string) (Model, tea.Cmd) {
+		newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		return newModel.(Model), cmd
+	}
+	// reload stands in for the reload that follows every write
+	reload := func(m Model, cursor int) Model {
+		got, _ := os.ReadFile(tasksPath)
+		m.content = string(got)
+		m.lines = parseLines(m.content)
+		m.cursor = cursor
+		return m
+	}
+
+	m, _ = press(m, "v")
+	m, _ = press(m, "p")
+	if m.status != "Nothing to paste (d or y a task first)" {
+		t.Errorf("p with an empty buffer: status = %q", m.status)
+	}
+
+	m, cmd := press(m, "d")
+	if cmd == nil {
+		t.Fatal("d in select mode should return a command")
+	}
+	msg, ok := cmd().(DeleteFinishedMsg)
+	if !ok || msg.Err != nil || msg.Block != "- [ ] a\n  - [ ] a1" {
+		t.Fatalf("delete result = %#v, want the a subtree", msg)
+	}
+	newModel, _ = m.Update(msg)
+	m = newModel.(Model)
+	if m.reloadStatus != "Deleted 2 line(s), p to paste" {
+		t.Errorf("reloadStatus = %q", m.reloadStatus)
+	}
+	want := "- [ ] b\n  - [ ] b1\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md after d = %q, want %q", got, want)
+	}
+
+	// Pasted below a subtask, the block takes its indentation
+	m = reload(m, 1)
+	m, cmd = press(m, "p")
+	if msg, ok := cmd().(PasteFinishedMsg); !ok || msg.Err != nil || msg.Count != 2 {
+		t.Fatalf("paste result = %#v, want 2 lines", msg)
+	}
+	want = "- [ ] b\n  - [ ] b1\n  - [ ] a\n    - [ ] a1\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md after p = %q, want %q", got, want)
+	}
+
+	// The buffer stays for another paste, below the subtasks of the selected task
+	m = reload(m, 0)
+	m, cmd = press(m, "p")
+	if msg, ok := cmd().(PasteFinishedMsg); !ok || msg.Err != nil {
+		t.Fatalf("second paste result = %#v", msg)
+	}
+	want = "- [ ] b\n  - [ ] b1\n  - [ ] a\n    - [ ] a1\n- [ ] a\n  - [ ] a1\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md after second p = %q, want %q", got, want)
+	}
+
+	// y replaces the buffer without changing tasks.md
+	m = reload(m, 1)
+	m, _ = press(m, "y")
+	if m.status != "Yanked 1 line(s), p to paste" || len(m.yankBuffer) != 1 || m.yankBuffer[0] != "- [ ] b1" {
+		t.Errorf("after y: status = %q, buffer = %q", m.status, m.yankBuffer)
+	}
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md after y = %q, want it unchanged", got)
+	}
+}
+
+// TestToggleChildrenDetectsExternalChange verifies that the toggle is refused when the
+// selected line no longer matches the file on disk.
+func TestToggleChildrenDetectsExternalChange(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	if err := os.WriteFile(tasksPath, []byte("- [ ] Changed\n  - [ ] Child\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), "- [ ] Parent\n  - [ ] Child\n", tasksPath, filepath.Join(dir, "archive.md"))
+	m.cursorMode = true
+
+	msg, ok := m.toggleChildrenCmd()().(ToggleChildrenFinishedMsg)
+	if !ok || msg.Err == nil {
+		t.Errorf("toggleChildrenCmd() = %#v, want error", msg)
+	}
+}
+
+// TestTrackTime verifies that T starts a timer on the selected task, shows it in the
+// footer, and on the second press adds the elapsed time to the task's @track tag.
+func TestTrackTime(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "# Tasks\n- [ ] Write report @track(1h)\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'T'}})
+	m = newModel.(Model)
+	// Outside select mode T is the today's-completions filter, not the timer
+	if m.tracking || m.status != "No tasks completed today" {
+		t.Errorf("T outside select mode: tracking = %v, status = %q", m.tracking, m.status)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}})
+	m = newModel.(Model)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'T'}})
+	m = newModel.(Model)
+	if m.tracking || m.status != "Not a task" {
+		t.Errorf("T on a heading: tracking = %v, status = %q", m.tracking, m.status)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = newModel.(Model)
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'T'}})
+	m = newModel.(Model)
+	if !m.tracking || cmd == nil {
+		t.Fatalf("T on a task should start tracking with a tick, tracking = %v", m.tracking)
+	}
+
+	// Pretend 25 minutes have passed
+	m.trackStart = m.trackStart.Add(-25 * time.Minute)
+	if footer := m.footerView(); !strings.Contains(footer, "[track 0:25:00]") {
+		t.Errorf("footer = %q, want elapsed time", footer)
+	}
+	if _, cmd := m.Update(TrackTickMsg{Start: m.trackStart}); cmd == nil {
+		t.Error("tick of the running timer should schedule the next one")
+	}
+	if _, cmd := m.Update(TrackTickMsg{Start: m.trackStart.Add(-time.Hour)}); cmd != nil {
+		t.Error("tick of an earlier timer should end its chain")
+	}
+
+	// The line moved while tracking: it is found by its text
+	if err := os.WriteFile(tasksPath, []byte("# Tasks\n- [ ] New task\n- [ ] Write report @track(1h)\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'T'}})
+	m = newModel.(Model)
+	if m.tracking || cmd == nil {
+		t.Fatalf("second T should stop tracking and write, tracking = %v", m.tracking)
+	}
+	msg, ok := cmd().(TrackFinishedMsg)
+	if !ok || msg.Err != nil {
+		t.Fatalf("track result = %#v, want success", msg)
+	}
+	got, _ := os.ReadFile(tasksPath)
+	if want := "# Tasks\n- [ ] New task\n- [ ] Write report @track(1h25m)\n"; string(got) != want {
+		t.Errorf("tasks.md after tracking =\n%s\nwant\n%s", got, want)
+	}
+
+	newModel, _ = m.Update(msg)
+	m = newModel.(Model)
+	if !strings.HasPrefix(m.reloadStatus, "Tracked 0:25:") {
+		t.Errorf("reloadStatus = %q, want tracked time", m.reloadStatus)
+	}
+}
+
+// TestCollapseSection verifies that z folds the selected "## " section (nested
+// headings included), shows its task counts on the heading, and keeps it folded
+// with fresh counts across a reload.
+func TestCollapseSection(t *testing.T) {
+	content := "# Tasks\n## Work\n### Backend\n- [ ] a\n- [x] b\n## Home\n- [ ] c\n## Someday"
+	m := New(config.Default(), content)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	press := func(m Model, key string) Model {
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		return newModel.(Model)
+	}
+
+	m = press(m, "z")
+	if m.status != "Press v to select a task first" || len(m.collapsed) != 0 {
+		t.Errorf("z outside select mode: status = %q, collapsed = %v", m.status, m.collapsed)
+	}
+
+	m = press(m, "v")
+	m = press(m, "z")
+	if m.status != "Not a ## heading" {
+		t.Errorf("z on a level 1 heading: status = %q", m.status)
+	}
+
+	m.cursor = 1
+	m = press(m, "z")
+	if got, want := fmt.Sprint(m.visibleLines()), "[0 1 5 6 7]"; got != want {
+		t.Errorf("visible lines = %s, want %s", got, want)
+	}
+	if display := m.displayContent(); !strings.Contains(display, "## Work (2 tasks, 1 open) ▸") || strings.Contains(display, "Backend") {
+		t.Errorf("folded display =\n%s", display)
+	}
+	// The selected folded heading is padded like any selected row, so the highlight spans the view
+	if row := strings.Split(m.displayContent(), "\n")[1]; lipgloss.Width(row) != m.viewport.Width {
+		t.Errorf("selected heading row %q is %d wide, want %d", row, lipgloss.Width(row), m.viewport.Width)
+	}
+
+	// Heading at the end of the file: nothing to hide, counts still shown
+	m.cursor = 7
+	m = press(m, "z")
+	if display := m.displayContent(); !strings.Contains(display, "## Someday (0 tasks, 0 open) ▸") {
+		t.Errorf("folded EOF heading display =\n%s", display)
+	}
+
+	// Counts follow the reloaded content; the section stays folded
+	newModel, _ = m.Update(ReloadFinishedMsg{Content: "# Tasks\n## Work\n### Backend\n- [ ] a\n- [ ] b\n- [ ] new\n## Home\n"})
+	m = newModel.(Model)
+	if display := m.displayContent(); !strings.Contains(display, "## Work (3 tasks, 3 open) ▸") || strings.Contains(display, "new") {
+		t.Errorf("display after reload =\n%s", display)
+	}
+
+	m.cursor = 1
+	m = press(m, "z")
+	if got, want := len(m.visibleLines()), 7; got != want {
+		t.Errorf("visible lines after unfolding = %d, want %d", got, want)
+	}
+}
+
+// TestSectionProgress verifies the "(done/total)" heading suffix: shown only in the
+// view, updated on reload, replaced by the summary when folded, and off with
+// ui.show_progress = false.
+func TestSectionProgress(t *testing.T) {
+	content := "# Tasks\n## Work\n- [x] a @done(2026-01-20)\n- [ ] b\n  - [ ] b1\n## Notes\ntext\n"
+	m := New(config.Default(), content)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	display := m.displayContent()
+	if !strings.Contains(display, "## Work (1/3)\n") {
+		t.Errorf("display =\n%s\nwant ## Work (1/3)", display)
+	}
+	if strings.Contains(display, "## Notes (") || strings.Contains(display, "# Tasks (") {
+		t.Errorf("sections without tasks and level 1 headings should have no suffix:\n%s", display)
+	}
+	if m.lines[1] != "## Work" || m.content != content {
+		t.Errorf("the suffix must not change the content, line = %q", m.lines[1])
+	}
+
+	// Counts follow the reloaded content
+	newModel, _ = m.Update(ReloadFinishedMsg{Content: "## Work\n- [x] a @done(2026-01-20)\n- [x] b @done(2026-01-20)\n"})
+	m = newModel.(Model)
+	if display := m.displayContent(); !strings.Contains(display, "## Work (2/2)") {
+		t.Errorf("display after reload =\n%s", display)
+	}
+
+	// A folded section shows its summary instead
+	m.cursorMode = true
+	m.cursor = 0
+	m, _ = m.toggleSection()
+	if display := m.displayContent(); !strings.Contains(display, "## Work (2 tasks, 0 open) ▸") || strings.Contains(display, "(2/2)") {
+		t.Errorf("folded display =\n%s", display)
+	}
+
+	cfg := config.Default()
+	cfg.UI.ShowProgress = false
+	m = New(cfg, content)
+	if display := m.displayContent(); strings.Contains(display, "(1/3)") {
+		t.Errorf("ui.show_progress = false should hide the suffix:\n%s", display)
+	}
+}
+
+// TestInlineEdit verifies editing the selected task's text in the footer: the marker
+// and tags are kept, Esc cancels, and Enter writes the line back.
+func TestInlineEdit(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "- [x] Buy milk @done(2026-01-20)\n- [ ] Other\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	m = newModel.(Model)
+
+	press := func(m Model, msgs ...tea.KeyMsg) (Model, tea.Cmd) {
+		var cmd tea.Cmd
+		for _, msg := range msgs {
+			var newModel tea.Model
+			newModel, cmd = m.Update(msg)
+			m = newModel.(Model)
+		}
+		return m, cmd
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	space := tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}} // as the terminal sends it
+
+	m, _ = press(m, runes("i"))
+	if m.inlineEditing || m.status != "Press v to select a task first" {
+		t.Errorf("i outside select mode: editing = %v, status = %q", m.inlineEditing, m.status)
+	}
+
+	m, _ = press(m, runes("v"), runes("i"))
+	if !m.inlineEditing || m.inlineInput.Value() != "Buy milk" {
+		t.Fatalf("i in select mode: editing = %v, input = %q", m.inlineEditing, m.inlineInput.Value())
+	}
+	if footer := m.footerView(); !strings.Contains(footer, "edit: Buy milk") {
+		t.Errorf("footer = %q, want the edit input", footer)
+	}
+
+	// Keys go to the input, not to navigation or quit
+	m, _ = press(m, runes("q"), tea.KeyMsg{Type: tea.KeyBackspace}, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.inlineEditing {
+		t.Fatal("Esc should cancel the edit")
+	}
+	if got, _ := os.ReadFile(tasksPath); string(got) != content {
+		t.Errorf("cancelled edit changed tasks.md:\n%s", got)
+	}
+
+	// "Buy milk" → "Buy oat milk": move before "milk" and type
+	m, _ = press(m, runes("i"))
+	for range "milk" {
+		m, _ = press(m, tea.KeyMsg{Type: tea.KeyLeft})
+	}
+	m, cmd := press(m, runes("oat"), space, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.inlineEditing || cmd == nil {
+		t.Fatalf("Enter should finish editing and write, editing = %v", m.inlineEditing)
+	}
+	msg, ok := cmd().(InlineEditFinishedMsg)
+	if !ok || msg.Err != nil {
+		t.Fatalf("edit result = %#v, want success", msg)
+	}
+	want := "- [x] Buy oat milk @done(2026-01-20)\n- [ ] Other\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md after edit = %q, want %q", got, want)
+	}
+	m, _ = press(m)
+	newModel, _ = m.Update(msg)
+	if m = newModel.(Model); m.reloadStatus != "Task updated" {
+		t.Errorf("reloadStatus = %q, want %q", m.reloadStatus, "Task updated")
+	}
+
+	// The line changed on disk since editing started: nothing is written
+	m.inlineText = "- [x] Buy milk @done(2026-01-20)"
+	if msg, ok := m.inlineEditCmd()().(InlineEditFinishedMsg); !ok || msg.Err == nil {
+		t.Errorf("inlineEditCmd() = %#v, want error", msg)
+	}
+}
+
+// TestInlineEditEmail verifies that an email address in the task text is edited as
+// text: the "@b" of "a@b.com" is not kept as a tag.
+func TestInlineEditEmail(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "- [ ] Email a@b.com @due(2026-01-25)\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewWithPaths(config.Default(), content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	m = newModel.(Model)
+
+	for _, msg := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune("v")},
+		{Type: tea.KeyRunes, Runes: []rune("i")},
+	} {
+		newModel, _ = m.Update(msg)
+		m = newModel.(Model)
+	}
+	if got := m.inlineInput.Value(); got != "Email a@b.com" {
+		t.Fatalf("input = %q, want %q", got, "Email a@b.com")
+	}
+
+	m.inlineInput.SetValue("Email c@d.com")
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m = newModel.(Model); cmd == nil {
+		t.Fatal("Enter should write the edit")
+	}
+	if msg, ok := cmd().(InlineEditFinishedMsg); !ok || msg.Err != nil {
+		t.Fatalf("edit result = %#v, want success", msg)
+	}
+	want := "- [ ] Email c@d.com @due(2026-01-25)\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md after edit = %q, want %q", got, want)
+	}
+}
+
+// TestInlineEditTagCompletion verifies the tag suggestions and Tab completion in the inline edit.
+func TestInlineEditTagCompletion(t *testing.T) {
+	content := "- [ ] Buy milk\n- [ ] Call Bob #phone @waiting(bob)\n"
+	m := New(config.Default(), content)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+	m = newModel.(Model)
+
+	press := func(m Model, msgs ...tea.KeyMsg) Model {
+		for _, msg := range msgs {
+			newModel, _ := m.Update(msg)
+			m = newModel.(Model)
+		}
+		return m
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+	space := tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}} // as the terminal sends it
+	tab := tea.KeyMsg{Type: tea.KeyTab}
+
+	m = press(m, runes("v"), runes("i"), space, runes("@d"))
+	if got := fmt.Sprint(m.tagSuggestions()); got != "[@done @due]" {
+		t.Errorf("suggestions for @d = %s, want [@done @due]", got)
+	}
+	if footer := m.footerView(); !strings.Contains(footer, "@done") || !strings.Contains(footer, "@due") {
+		t.Errorf("footer = %q, want the suggestions", footer)
+	}
+
+	// ↓ highlights @due and Tab completes it
+	m = press(m, tea.KeyMsg{Type: tea.KeyDown}, tab)
+	if got := m.inlineInput.Value(); got != "Buy milk @due" || m.inlineInput.Position() != len([]rune(got)) {
+		t.Errorf("after Tab: input = %q, pos = %d", got, m.inlineInput.Position())
+	}
+	if got := m.tagSuggestions(); got != nil {
+		t.Errorf("suggestions for a whole tag = %v, want none", got)
+	}
+
+	// Tags already used in the file complete too
+	m = press(m, space, runes("#p"), tab, space, runes("@w"), tab)
+	if got := m.inlineInput.Value(); got != "Buy milk @due #phone @waiting" {
+		t.Errorf("after file tag completion: input = %q", got)
+	}
+
+	// Without suggestions Tab does nothing
+	m = press(m, space, runes("@zz"), tab, space, runes("x"), tab)
+	if got := m.inlineInput.Value(); got != "Buy milk @due #phone @waiting @zz x" {
+		t.Errorf("Tab without suggestions changed input to %q", got)
+	}
+	if !m.inlineEditing {
+		t.Error("Tab should not leave the inline edit")
+	}
+}
+
+// TestGitIndicator verifies the footer git indicator for each repository state.
+func TestGitIndicator(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      GitStatusMsg
+		expected string
+	}{
+		{"no remote", GitStatusMsg{Dirty: true, Ahead: 2}, ""},
+		{"error", GitStatusMsg{HasRemote: true, Err: fmt.Errorf("no branch")}, ""},
+		{"clean", GitStatusMsg{HasRemote: true}, "✓"},
+		{"uncommitted", GitStatusMsg{HasRemote: true, Dirty: true}, "*"},
+		{"ahead", GitStatusMsg{HasRemote: true, Ahead: 3}, "↑3"},
+		{"both", GitStatusMsg{HasRemote: true, Dirty: true, Ahead: 1}, "* ↑1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gitIndicator(tt.msg); got != tt.expected {
+				t.Errorf("gitIndicator() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestFooterShowsGitIndicator verifies that GitStatusMsg updates the footer.
+func TestFooterShowsGitIndicator(t *testing.T) {
+	m := New(config.Default(), "- [ ] Task")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(GitStatusMsg{HasRemote: true, Dirty: true, Ahead: 2})
+	m = newModel.(Model)
+
+	if !strings.Contains(m.footerView(), "* ↑2 ") {
+		t.Errorf("footer should contain git indicator, got %q", m.footerView())
+	}
+
+	newModel, _ = m.Update(GitStatusMsg{})
+	m = newModel.(Model)
+	if strings.Contains(m.footerView(), "✓") || strings.Contains(m.footerView(), "↑") {
+		t.Error("footer should omit the indicator without a remote")
+	}
+}
+
+// TestArchiveTombstoneSingleCommit verifies that with archive.tombstone and git.auto_commit,
+// the archive pass records tasks.md and archive.md in one commit.
+func TestArchiveTombstoneSingleCommit(t *testing.T) {
+	dir := t.TempDir()
+	runGit := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v error: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	runGit("init")
+	runGit("config", "user.email", "test@example.com")
+	runGit("config", "user.name", "Test User")
+
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "- [ ] Open\n- [x] Old @done(2020-01-01)\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	runGit("add", "tasks.md")
+	runGit("commit", "-m", "initial")
+
+	cfg := config.Default()
+	cfg.Git.AutoCommit = true
+	cfg.Archive.Tombstone = true
+	m := NewWithPaths(cfg, content, tasksPath, filepath.Join(dir, "archive.md"))
+
+	msg, ok := m.archiveCmd()().(ArchiveFinishedMsg)
+	if !ok || msg.Err != nil || msg.Count != 1 {
+		t.Fatalf("archiveCmd() = %#v, want 1 archived task", msg)
+	}
+
+	files := strings.Fields(runGit("show", "--name-only", "--format=", "HEAD"))
+	if strings.Join(files, " ") != "archive.md tasks.md" {
+		t.Errorf("HEAD files = %q, want both archive.md and tasks.md", files)
+	}
+	if status := runGit("status", "--porcelain"); status != "" {
+		t.Errorf("working tree should be clean after archive commit, got %q", status)
+	}
+}
+
+// TestArchiveNothingToDo verifies that the startup and manual archive passes write
+// nothing and commit nothing when no task needs tagging or archiving.
+func TestArchiveNothingToDo(t *testing.T) {
+	dir := t.TempDir()
+	runGit := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v error: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	runGit("init")
+	runGit("config", "user.email", "test@example.com")
+	runGit("config", "user.name", "Test User")
+
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "- [ ] Open\n- [x] Done today @done(" + time.Now().Format("2006-01-02") + ")\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(tasksPath, old, old); err != nil {
+		t.Fatalf("Chtimes() error: %v", err)
+	}
+	runGit("add", "tasks.md")
+	runGit("commit", "-m", "initial")
+	head := runGit("rev-parse", "HEAD")
+
+	cfg := config.Default()
+	cfg.Git.AutoCommit = true
+	cfg.Archive.Tombstone = true
+	m := NewWithPaths(cfg, content, tasksPath, filepath.Join(dir, "archive.md"))
+
+	if msg, ok := m.archiveCmd()().(ArchiveFinishedMsg); !ok || msg.Err != nil || msg.Tagged != 0 || msg.Count != 0 {
+		t.Errorf("archiveCmd() = %#v, want nothing tagged or archived", msg)
+	}
+	if msg, ok := m.addDoneTagsCmd()().(AddDoneTagsFinishedMsg); !ok || msg.Err != nil || msg.Count != 0 {
+		t.Errorf("addDoneTagsCmd() = %#v, want nothing tagged", msg)
+	}
+
+	if info, err := os.Stat(tasksPath); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("tasks.md was rewritten (stat %v, err %v)", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "archive.md")); !os.IsNotExist(err) {
+		t.Error("archive.md should not be created")
+	}
+	if status := runGit("status", "--porcelain"); status != "" {
+		t.Errorf("working tree = %q, want clean", status)
+	}
+	if got := runGit("rev-parse", "HEAD"); got != head {
+		t.Error("a commit was created")
+	}
+}
+
+// TestArchiveViewRestore verifies that A opens archive.md with a cursor, u asks how to
+// restore, and the selected task (with its subtask) moves back to tasks.md.
+func TestArchiveViewRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       rune
+		wantTasks string
+	}{
+		{
+			name:      "keep done",
+			key:       'k',
+			wantTasks: "- [ ] Open\n- [x] Plan @done(2026-01-20)\n  - [x] Book room @done(2026-01-20)\n",
+		},
+		{
+			name:      "reopen",
+			key:       'o',
+			wantTasks: "- [ ] Open\n- [ ] Plan\n  - [ ] Book room\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tasksPath := filepath.Join(dir, "tasks.md")
+			archivePath := filepath.Join(dir, "archive.md")
+			archive := "## 2026-01-20\n\n- [x] Plan @done(2026-01-20)\n  - [x] Book room @done(2026-01-20)\n"
+			if err := os.WriteFile(tasksPath, []byte("- [ ] Open"), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+			if err := os.WriteFile(archivePath, []byte(archive), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+
+			m := NewWithPaths(config.Default(), "- [ ] Open", tasksPath, archivePath)
+			newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+			m = newModel.(Model)
+
+			_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'A'}})
+			newModel, _ = m.Update(cmd())
+			m = newModel.(Model)
+			if !m.archiveMode || !strings.Contains(m.View(), "Book room") {
+				t.Fatalf("A should open the archive view, got:\n%s", m.View())
+			}
+
+			// u on the header is refused; move down to the task
+			newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+			m = newModel.(Model)
+			if m.restorePending {
+				t.Error("u on a header should not ask to restore")
+			}
+			m = m.moveCursor(2)
+
+			newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+			m = newModel.(Model)
+			if !m.restorePending || !strings.Contains(m.View(), restorePrompt) {
+				t.Fatal("u on a task should show the restore prompt")
+			}
+
+			_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{tt.key}})
+			msg, ok := cmd().(RestoreFinishedMsg)
+			if !ok || msg.Err != nil || msg.Count != 2 {
+				t.Fatalf("restore result = %#v, want 2 tasks restored", msg)
+			}
+
+			gotTasks, _ := os.ReadFile(tasksPath)
+			if string(gotTasks) != tt.wantTasks {
+				t.Errorf("tasks.md =\n%q\nwant:\n%q", gotTasks, tt.wantTasks)
+			}
+			gotArchive, _ := os.ReadFile(archivePath)
+			if string(gotArchive) != "" {
+				t.Errorf("archive.md = %q, want empty", gotArchive)
+			}
+		})
+	}
+}
+
+// TestArchiveViewMonthlyFiles verifies that the archive view shows archive.md and the
+// monthly archive files together, loads older months once the cursor reaches the end,
+// and restores a task from the file it is in.
+func TestArchiveViewMonthlyFiles(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	files := map[string]string{
+		"tasks.md":           "- [ ] Open\n",
+		"archive.md":         "## 2026-02-10\n\n- [x] Feb @done(2026-02-10)\n",
+		"archive-2026-01.md": "## 2026-01-20\n\n- [x] Jan @done(2026-01-20)\n",
+		"archive-2025-12.md": "## 2025-12-05\n\n- [x] Dec @done(2025-12-05)\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+
+	m := NewWithPaths(config.Default(), "- [ ] Open", tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'A'}})
+	newModel, _ = m.Update(cmd())
+	m = newModel.(Model)
+	view := m.View()
+	if !strings.Contains(view, "Feb") || !strings.Contains(view, "Jan") || !strings.Contains(view, "Dec") {
+		t.Fatalf("archive view should show every archive file, got:\n%s", view)
+	}
+
+	// Select "Jan" (header, blank, Feb, blank, header, blank, Jan) and restore it
+	m = m.moveCursor(6)
+	if m.archiveLines[m.cursor] != "- [x] Jan @done(2026-01-20)" {
+		t.Fatalf("cursor on %q, want the January task", m.archiveLines[m.cursor])
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newModel.(Model)
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'k'}})
+	m = newModel.(Model)
+	if msg, ok := cmd().(RestoreFinishedMsg); !ok || msg.Err != nil || msg.Count != 1 {
+		t.Fatalf("restore result = %#v, want 1 task restored", msg)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "archive-2026-01.md")); string(got) != "" {
+		t.Errorf("archive-2026-01.md = %q, want the task and its empty section removed", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "archive.md")); string(got) != files["archive.md"] {
+		t.Errorf("archive.md = %q, want it unchanged", got)
+	}
+
+	// Older files past the budget are loaded once the cursor reaches the end
+	m.archiveMore = true
+	m.archiveFiles = m.archiveFiles[:1]
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'G'}})
+	m = newModel.(Model)
+	if !m.archiveAll || cmd == nil {
+		t.Fatal("G at the end of a partial archive should load the older files")
+	}
+}
+
+// TestArchiveViewOrder verifies that o shows the archive oldest first without changing
+// archive.md, keeps the cursor on its line, and that restore still finds the task.
+func TestArchiveViewOrder(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	archivePath := filepath.Join(dir, "archive.md")
+	archive := "## 2026-01-20\n\n- [x] New @done(2026-01-20)\n\n## 2026-01-18\n\n- [x] Old @done(2026-01-18)\n- [x] Older @done(2026-01-18)\n"
+	if err := os.WriteFile(tasksPath, []byte("- [ ] Open\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if err := os.WriteFile(archivePath, []byte(archive), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), "- [ ] Open\n", tasksPath, archivePath)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	m = newModel.(Model)
+	newModel, _ = m.Update(m.loadArchiveCmd(false)())
+	m = newModel.(Model)
+	if footer := m.footerView(); !strings.Contains(footer, "-- ARCHIVE (newest first) --") {
+		t.Errorf("footer = %q, want newest first", footer)
+	}
+
+	// Cursor on "New", then o: the older section comes first and the cursor follows "New"
+	m = m.moveCursor(2)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	m = newModel.(Model)
+	if m.archiveLines[0] != "## 2026-01-18" {
+		t.Errorf("first line = %q, want the oldest section", m.archiveLines[0])
+	}
+	if got := m.archiveLines[m.cursor]; got != "- [x] New @done(2026-01-20)" {
+		t.Errorf("cursor on %q, want the task selected before o", got)
+	}
+	if footer := m.footerView(); !strings.Contains(footer, "-- ARCHIVE (oldest first) --") {
+		t.Errorf("footer = %q, want oldest first", footer)
+	}
+	if got, _ := os.ReadFile(archivePath); string(got) != archive {
+		t.Errorf("o changed archive.md:\n%s", got)
+	}
+
+	// Restore "Older", shown at a different row than its line in archive.md
+	m = m.moveCursor(-len(m.archiveLines))
+	m = m.moveCursor(3)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newModel.(Model)
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'k'}})
+	m = newModel.(Model)
+	if msg, ok := cmd().(RestoreFinishedMsg); !ok || msg.Err != nil || msg.Count != 1 {
+		t.Fatalf("restore result = %#v, want 1 task restored", msg)
+	}
+	if got, _ := os.ReadFile(tasksPath); string(got) != "- [ ] Open\n- [x] Older @done(2026-01-18)\n" {
+		t.Errorf("tasks.md = %q, want Older restored", got)
+	}
+
+	// o again returns to newest first
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	if m = newModel.(Model); m.archiveAscending || m.archiveLines[0] != "## 2026-01-20" {
+		t.Errorf("second o should show newest first, first line %q", m.archiveLines[0])
+	}
+}
+
+// TestArchiveViewLeave verifies that esc returns to tasks.md and that the restore prompt can be cancelled.
+func TestArchiveViewLeave(t *testing.T) {
+	m := New(config.Default(), "- [ ] Open\n")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+	newModel, _ = m.Update(ArchiveLoadedMsg{Content: "## 2026-01-20\n- [x] Done @done(2026-01-20)\n"})
+	m = newModel.(Model)
+
+	m = m.moveCursor(1)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newModel.(Model)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(Model)
+	if m.restorePending || !m.archiveMode || m.status != "Cancelled" {
+		t.Errorf("esc at the prompt should cancel and stay in the archive view (status %q)", m.status)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(Model)
+	if m.archiveMode || m.cursorMode || !strings.Contains(m.View(), "Open") {
+		t.Errorf("esc should return to tasks.md, got:\n%s", m.View())
+	}
+
+	// A refresh arriving after leaving must not reopen the view
+	newModel, _ = m.Update(ArchiveLoadedMsg{Content: "", Refresh: true})
+	m = newModel.(Model)
+	if m.archiveMode {
+		t.Error("late archive refresh should be ignored")
+	}
+}
+
+// TestRenderLineHyperlinks verifies that links become OSC 8 hyperlinks, that the text is
+// unchanged, and that display.hyperlinks = false turns them off.
+func TestRenderLineHyperlinks(t *testing.T) {
+	cfg := config.Default()
+	cfg.UI.LinkPatterns = map[string]string{`JIRA-(\d+)`: "https://jira.example.com/browse/JIRA-$1"}
+	m := New(cfg, "")
+
+	got := m.renderLine("- [ ] Fix JIRA-12 @due(2026-01-20)", false)
+	want := "- [ ] Fix " + hyperlink("https://jira.example.com/browse/JIRA-12", "JIRA-12") + " @due(2026-01-20)"
+	if got != want {
+		t.Errorf("renderLine() = %q, want %q", got, want)
+	}
+	if w := lipgloss.Width(got); w != len("- [ ] Fix JIRA-12 @due(2026-01-20)") {
+		t.Errorf("display width = %d, hyperlink escapes should not take space", w)
+	}
+
+	cfg.Display.Hyperlinks = false
+	if got := m.renderLine("- [ ] Fix JIRA-12", false); got != "- [ ] Fix JIRA-12" {
+		t.Errorf("renderLine() with hyperlinks off = %q", got)
+	}
+}
+
+// TestOpenLinkKey verifies that o opens the first link on the selected line.
+func TestOpenLinkKey(t *testing.T) {
+	var opened []string
+	orig := openURL
+	openURL = func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+	defer func() { openURL = orig }()
+
+	cfg := config.Default()
+	cfg.UI.LinkPatterns = map[string]string{`#(\d+)`: "https://example.com/issues/$1"}
+	m := New(cfg, "- [ ] No link\n- [ ] Fix #42 and #43\n")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	m = newModel.(Model)
+	if m.status != "Press v to select a task first" {
+		t.Errorf("status = %q, want hint to enter select mode", m.status)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}})
+	m = newModel.(Model)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	m = newModel.(Model)
+	if m.status != "No link on this line" {
+		t.Errorf("status = %q, want no-link message", m.status)
+	}
+
+	m = m.moveCursor(1)
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	if cmd == nil {
+		t.Fatal("o on a line with a link should return a command")
+	}
+	msg := cmd()
+	if len(opened) != 1 || opened[0] != "https://example.com/issues/42" {
+		t.Errorf("opened = %v, want the first link", opened)
+	}
+	newModel, _ = m.Update(msg)
+	m = newModel.(Model)
+	if m.status != "Opened https://example.com/issues/42" {
+		t.Errorf("status = %q", m.status)
+	}
+}
+
+// TestSearch verifies typing a query with "/", jumping with n/N (wrapping around),
+// and clearing the highlight with esc.
+func TestSearch(t *testing.T) {
+	cfg := config.Default()
+	cfg.Search.NormalizeWidth = true
+	content := "- [ ] ＰＲ４２をレビュー\n- [ ] Deploy\n- [ ] pr 7\n- [ ] Close PR42\n" + strings.Repeat("- [ ] Filler\n", 10)
+	m := New(cfg, content)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 3})
+	m = newModel.(Model)
+	m.viewport.SetYOffset(1)
+
+	press := func(msg tea.KeyMsg) {
+		t.Helper()
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	press(runes("/"))
+	press(runes("pr"))
+	press(runes("4"))
+	press(runes("3"))
+	press(tea.KeyMsg{Type: tea.KeyBackspace})
+	press(runes("2"))
+	if !m.searching || !strings.Contains(m.footerView(), "/pr42") {
+		t.Fatalf("footer = %q, want the typed query", m.footerView())
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.searching || m.searchQuery != "pr42" {
+		t.Fatalf("searching = %v, searchQuery = %q; want confirmed \"pr42\"", m.searching, m.searchQuery)
+	}
+	if m.viewport.YOffset != 3 {
+		t.Errorf("YOffset = %d after enter, want 3 (first match from the top of the screen)", m.viewport.YOffset)
+	}
+	if !strings.Contains(m.footerView(), "[search: pr42]") {
+		t.Errorf("footer = %q, want search indicator", m.footerView())
+	}
+
+	press(runes("n"))
+	if m.viewport.YOffset != 0 {
+		t.Errorf("YOffset = %d after n, want 0 (wrapped to the full-width match)", m.viewport.YOffset)
+	}
+	press(runes("N"))
+	if m.viewport.YOffset != 3 {
+		t.Errorf("YOffset = %d after N, want 3", m.viewport.YOffset)
+	}
+
+	line := "- [ ] ＰＲ４２をレビュー"
+	if got := m.renderLine(line, false); !strings.Contains(got, "ＰＲ４２") || lipgloss.Width(got) != lipgloss.Width(line) {
+		t.Errorf("renderLine() text = %q, want %q", got, line)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.searchQuery != "" {
+		t.Errorf("searchQuery = %q after esc, want empty", m.searchQuery)
+	}
+
+	press(runes("/"))
+	press(runes("nothing"))
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.status != "Not found: nothing" {
+		t.Errorf("status = %q, want not found", m.status)
+	}
+}
+
+// TestScrollbarThumb verifies thumb size and position for several content/viewport ratios.
+func TestScrollbarThumb(t *testing.T) {
+	tests := []struct {
+		name      string
+		height    int
+		total     int
+		offset    int
+		wantStart int
+		wantSize  int
+	}{
+		{"everything fits", 10, 5, 0, 0, 10},
+		{"exactly fits", 10, 10, 0, 0, 10},
+		{"half visible, top", 10, 20, 0, 0, 5},
+		{"half visible, middle", 10, 20, 5, 3, 5},
+		{"half visible, bottom", 10, 20, 10, 5, 5},
+		{"tenth visible, top", 10, 100, 0, 0, 1},
+		{"tenth visible, middle", 10, 100, 45, 5, 1},
+		{"tenth visible, bottom", 10, 100, 90, 9, 1},
+		{"tiny share keeps one row", 10, 1000, 0, 0, 1},
+		{"offset past the end is clamped", 10, 20, 50, 5, 5},
+		{"no track", 0, 20, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, size := scrollbarThumb(tt.height, tt.total, tt.offset)
+			if start != tt.wantStart || size != tt.wantSize {
+				t.Errorf("scrollbarThumb(%d, %d, %d) = (%d, %d), want (%d, %d)",
+					tt.height, tt.total, tt.offset, start, size, tt.wantStart, tt.wantSize)
+			}
+		})
+	}
+}
+
+// TestConfigChanged verifies that a changed config.toml applies its display settings
+// to the running TUI and keeps the file settings, and that one that failed to load
+// leaves the settings as they were with a warning.
+func TestConfigChanged(t *testing.T) {
+	cfg := config.Default()
+	cfg.File.WorkingDir = "~/tasks"
+	m := New(cfg, "- [ ] Task")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 40, Height: 10})
+	m = newModel.(Model)
+	if m.viewport.Width != 40 {
+		t.Fatalf("viewport width = %d, want 40", m.viewport.Width)
+	}
+
+	next := config.Default()
+	next.File.WorkingDir = "~/elsewhere"
+	next.UI.Scrollbar = true
+	next.Theme.Done = "8"
+	newModel, _ = m.Update(ConfigChangedMsg{Config: next})
+	m = newModel.(Model)
+	if m.viewport.Width != 39 {
+		t.Errorf("viewport width with scrollbar = %d, want 39", m.viewport.Width)
+	}
+	if m.config.Theme.Done != "8" {
+		t.Errorf("Theme.Done = %q, want %q", m.config.Theme.Done, "8")
+	}
+	if m.config.File.WorkingDir != "~/tasks" {
+		t.Errorf("WorkingDir = %q, want %q kept", m.config.File.WorkingDir, "~/tasks")
+	}
+	if m.status != "Config reloaded" {
+		t.Errorf("status = %q, want %q", m.status, "Config reloaded")
+	}
+
+	newModel, _ = m.Update(ConfigChangedMsg{Err: errors.New("bad key")})
+	m = newModel.(Model)
+	if !strings.HasPrefix(m.status, "Config error") || !strings.Contains(m.status, "bad key") {
+		t.Errorf("status = %q, want a config error", m.status)
+	}
+	if m.config.Theme.Done != "8" || !m.config.UI.Scrollbar {
+		t.Error("failed config.toml changed the settings")
+	}
+}
+
+// TestConfigWarning verifies that the footer keeps a warning while config.toml has
+// unreadable lines or is ignored in safe mode, and that a clean reload clears it.
+func TestConfigWarning(t *testing.T) {
+	tests := []struct {
+		name     string
+		errors   []string
+		safeMode bool
+		want     string
+	}{
+		{"clean", nil, false, ""},
+		{"one error", []string{"line 2: incomplete number"}, false, "config error: line 2: incomplete number (using defaults)"},
+		{"two errors", []string{"line 2: incomplete number", "line 5: expected newline"}, false, "config error: line 2: incomplete number (+1 more) (using defaults)"},
+		{"safe mode", nil, true, "safe mode: config.toml ignored (using defaults)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.ConfigErrors = tt.errors
+			cfg.SafeMode = tt.safeMode
+			m := New(cfg, "- [ ] Task")
+			newModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 10})
+			m = newModel.(Model)
+			if got := m.configWarning(); got != tt.want {
+				t.Errorf("configWarning() = %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && !strings.Contains(m.footerView(), tt.want) {
+				t.Errorf("footer = %q, want the warning", m.footerView())
+			}
+		})
+	}
+
+	cfg := config.Default()
+	cfg.ConfigErrors = []string{"line 2: incomplete number"}
+	m := New(cfg, "- [ ] Task")
+	newModel, _ := m.Update(ConfigChangedMsg{Config: config.Default()})
+	m = newModel.(Model)
+	if got := m.configWarning(); got != "" {
+		t.Errorf("configWarning() after a clean reload = %q, want empty", got)
+	}
+}
+
+// TestViewScrollbar verifies that the scrollbar takes the last column without widening
+// the view, and follows scrolling, resizing, and reloads.
+func TestViewScrollbar(t *testing.T) {
+	cfg := config.Default()
+	cfg.UI.Scrollbar = true
+	m := New(cfg, strings.TrimSuffix(strings.Repeat("- [ ] Task\n", 36), "\n"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 40, Height: 10})
+	m = newModel.(Model)
+
+	thumbRows := func() []int {
+		t.Helper()
+		lines := strings.Split(m.View(), "\n")
+		var rows []int
+		for i, line := range lines[:len(lines)-1] {
+			if w := lipgloss.Width(line); w != 40 {
+				t.Fatalf("line %d width = %d, want 40", i, w)
+			}
+			if strings.HasSuffix(line, "█") {
+				rows = append(rows, i)
+			}
+		}
+		return rows
+	}
+
+	if got := thumbRows(); len(got) != 2 || got[0] != 0 {
+		t.Errorf("thumb rows at top = %v, want [0 1]", got)
+	}
+
+	m.viewport.GotoBottom()
+	if got := thumbRows(); len(got) != 2 || got[1] != 8 {
+		t.Errorf("thumb rows at bottom = %v, want [7 8]", got)
+	}
+
+	newModel, _ = m.Update(tea.WindowSizeMsg{Width: 40, Height: 19})
+	m = newModel.(Model)
+	if got := thumbRows(); len(got) != 9 {
+		t.Errorf("thumb rows after resize = %v, want 9 rows", got)
+	}
+
+	newModel, _ = m.Update(ReloadFinishedMsg{Content: "- [ ] Only task"})
+	m = newModel.(Model)
+	if got := thumbRows(); len(got) != 18 {
+		t.Errorf("thumb rows after reload = %v, want the whole track", got)
+	}
+}
+
+// TestStreakFooter verifies the ui.show_streak footer segment and that archive.md
+// is read again only after an archive or restore (or on a new day).
+func TestStreakFooter(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	archivePath := filepath.Join(dir, "archive.md")
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	yesterday := now.AddDate(0, 0, -1).Format("2006-01-02")
+	archive := "## " + yesterday + "\n- [x] Old @done(" + yesterday + ")\n"
+	if err := os.WriteFile(archivePath, []byte(archive), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg := config.Default()
+	m := NewWithPaths(cfg, "- [x] New @done("+today+")\n", tasksPath, archivePath)
+	m.width = 120
+	if m.streakCmd() != nil {
+		t.Fatal("streakCmd() should be nil without ui.show_streak")
+	}
+
+	cfg.UI.ShowStreak = true
+	updated, _ := m.Update(m.streakCmd()())
+	m = updated.(Model)
+	if footer := m.footerView(); !strings.Contains(footer, "today: 1 ✓ · streak: 2d") {
+		t.Fatalf("footer = %q, want the streak segment", footer)
+	}
+
+	// The cached archive is used for the rest of the day
+	if err := os.WriteFile(archivePath, nil, 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	updated, _ = m.Update(m.streakCmd()())
+	m = updated.(Model)
+	if m.streakText != "today: 1 ✓ · streak: 2d" {
+		t.Errorf("streakText = %q, want the cached archive to be used", m.streakText)
+	}
+
+	m.streakDay = time.Time{}
+	updated, _ = m.Update(m.streakCmd()())
+	m = updated.(Model)
+	if m.streakText != "today: 1 ✓ · streak: 1d" {
+		t.Errorf("streakText = %q, want archive.md read again", m.streakText)
+	}
+}
+
+// TestFormatStreak verifies the footer streak segment.
+func TestFormatStreak(t *testing.T) {
+	if got := formatStreak(4, 6); got != "today: 4 ✓ · streak: 6d" {
+		t.Errorf("formatStreak(4, 6) = %q", got)
+	}
+}
+
+// TestCompletedToBottomView verifies display.completed_to_bottom: completed tasks are
+// shown below the open ones of their section, before pinned tasks go to the top, and
+// select mode follows the display order.
+func TestCompletedToBottomView(t *testing.T) {
+	content := "# Tasks\n- [x] a\n  - [ ] a1\n- [ ] b\n- [ ] c @pin\n## Done soon\n- [x] d\n- [ ] e\n"
+	cfg := config.Default()
+	m := New(cfg, content)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	m = newModel.(Model)
+
+	cfg.Display.CompletedToBottom = true
+	if got, want := fmt.Sprint(m.visibleLines()), "[0 3 4 1 2 5 7 6]"; got != want {
+		t.Errorf("visibleLines() = %s, want %s", got, want)
+	}
+	cfg.UI.PinnedFirst = config.PinnedFirstView
+	if got, want := fmt.Sprint(m.visibleLines()), "[0 4 3 1 2 5 7 6]"; got != want {
+		t.Errorf("visibleLines() with pinned_first = %s, want %s", got, want)
+	}
+
+	for _, key := range []string{"v", "j", "j", "j"} {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = newModel.(Model)
+	}
+	if m.cursor != 1 {
+		t.Errorf("cursor = %d, want the completed task on line 1", m.cursor)
+	}
+}
+
+// TestPinnedFirstView verifies ui.pinned_first: pinned tasks are shown first with
+// "view" and moved in tasks.md with "file", and * toggles the @pin tag.
+func TestPinnedFirstView(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "# Tasks\n- [ ] a\n- [ ] b @pin\n  - [ ] b1\n- [ ] c\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg := config.Default()
+	m := NewWithPaths(cfg, content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	m = newModel.(Model)
+	if got, want := fmt.Sprint(m.visibleLines()), "[0 1 2 3 4]"; got != want {
+		t.Errorf("visibleLines() with pinned_first off = %s, want %s", got, want)
+	}
+
+	cfg.UI.PinnedFirst = config.PinnedFirstView
+	if got, want := fmt.Sprint(m.visibleLines()), "[0 2 3 1 4]"; got != want {
+		t.Errorf("visibleLines() with pinned_first view = %s, want %s", got, want)
+	}
+
+	press := func(m Model, keys ...string) (Model, tea.Cmd) {
+		var cmd tea.Cmd
+		for _, key := range keys {
+			var newModel tea.Model
+			newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+			m = newModel.(Model)
+		}
+		return m, cmd
+	}
+
+	m, _ = press(m, "*")
+	if m.status != "Press v to select a task first" {
+		t.Errorf("* outside select mode: status = %q", m.status)
+	}
+
+	// Select mode follows the display order: the pinned task comes right after the title
+	m, _ = press(m, "v", "j")
+	if m.cursor != 2 {
+		t.Fatalf("cursor = %d, want the pinned task on line 2", m.cursor)
+	}
+	m, cmd := press(m, "j", "j", "*")
+	if m.cursor != 1 || cmd == nil {
+		t.Fatalf("cursor = %d, want line 1 and a pin command", m.cursor)
+	}
+	if msg, ok := cmd().(PinFinishedMsg); !ok || msg.Err != nil || !msg.Pinned {
+		t.Fatalf("pin result = %#v, want pinned", msg)
+	}
+	want := "# Tasks\n- [ ] a @pin\n- [ ] b @pin\n  - [ ] b1\n- [ ] c\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md after pin in view mode = %q, want %q", got, want)
+	}
+
+	// In file mode unpinning leaves the task in place; pinning moves it to the top
+	cfg.UI.PinnedFirst = config.PinnedFirstFile
+	m.content = want
+	m.lines = parseLines(want)
+	m.cursor = 1
+	if msg := m.pinCmd()().(PinFinishedMsg); msg.Err != nil || msg.Pinned {
+		t.Fatalf("unpin result = %#v, want unpinned", msg)
+	}
+	want = "# Tasks\n- [ ] a\n- [ ] b @pin\n  - [ ] b1\n- [ ] c\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md after unpin = %q, want %q", got, want)
+	}
+	m.content = want
+	m.lines = parseLines(want)
+	m.cursor = 4
+	if msg := m.pinCmd()().(PinFinishedMsg); msg.Err != nil || !msg.Pinned {
+		t.Fatalf("pin result = %#v, want pinned", msg)
+	}
+	want = "# Tasks\n- [ ] b @pin\n  - [ ] b1\n- [ ] c @pin\n- [ ] a\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md after pin in file mode = %q, want %q", got, want)
+	}
+}
+
+// TestMoveToWorkspace verifies m in select mode: the footer lists the other
+// workspaces by number and the chosen one gets the task with its subtasks.
+func TestMoveToWorkspace(t *testing.T) {
+	workDir := t.TempDir()
+	homeDir := t.TempDir()
+	content := "- [ ] Review PR\n- [ ] Book dentist\n  - [ ] Call clinic\n"
+	if err := os.WriteFile(filepath.Join(workDir, "tasks.md"), []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "tasks.md"), []byte("## Inbox\n- [ ] Water plants\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Git.AutoCommit = false
+	m := NewWithPaths(cfg, content, filepath.Join(workDir, "tasks.md"), filepath.Join(workDir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+	m = newModel.(Model)
+
+	press := func(m Model, keys ...string) (Model, tea.Cmd) {
+		var cmd tea.Cmd
+		for _, key := range keys {
+			var newModel tea.Model
+			newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+			m = newModel.(Model)
+		}
+		return m, cmd
+	}
+
+	m, _ = press(m, "m")
+	if m.movePending || m.status != "Press v to select a task first" {
+		t.Errorf("m outside select mode: movePending = %v, status = %q", m.movePending, m.status)
+	}
+	m, _ = press(m, "v", "j", "m")
+	if m.movePending || !strings.HasPrefix(m.status, "No other workspace") {
+		t.Errorf("m without workspaces: movePending = %v, status = %q", m.movePending, m.status)
+	}
+
+	cfg.Workspaces = []config.WorkspaceConfig{{Name: "work", WorkingDir: workDir}, {Name: "home", WorkingDir: homeDir}}
+	if err := cfg.UseWorkspace("work"); err != nil {
+		t.Fatalf("UseWorkspace() error: %v", err)
+	}
+	m, _ = press(m, "m")
+	if footer := m.footerView(); !m.movePending || !strings.Contains(footer, "Move to: 1 default  2 home") {
+		t.Fatalf("movePending = %v, footer = %q", m.movePending, footer)
+	}
+	m, _ = press(m, "x")
+	if m.movePending || m.status != "Cancelled" {
+		t.Errorf("other key: movePending = %v, status = %q", m.movePending, m.status)
+	}
+
+	m, cmd := press(m, "m", "2")
+	if cmd == nil {
+		t.Fatal("choosing a workspace should return a move command")
+	}
+	msg, ok := cmd().(MoveFinishedMsg)
+	if !ok || msg.Err != nil || msg.Target != "home" {
+		t.Fatalf("move result = %#v, want success", msg)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workDir, "tasks.md")); string(got) != "- [ ] Review PR\n" {
+		t.Errorf("source tasks.md = %q", got)
+	}
+	want := "## Inbox\n- [ ] Water plants\n- [ ] Book dentist\n  - [ ] Call clinic\n"
+	if got, _ := os.ReadFile(filepath.Join(homeDir, "tasks.md")); string(got) != want {
+		t.Errorf("target tasks.md = %q, want %q", got, want)
+	}
+
+	// The line changed on disk since it was selected: nothing is written
+	if msg := m.moveCmd()().(MoveFinishedMsg); msg.Err == nil || msg.Moved {
+		t.Errorf("move of a changed line = %#v, want an error", msg)
+	}
+}
+
+// TestMovePrompt verifies the workspace list in the move prompt.
+func TestMovePrompt(t *testing.T) {
+	if got := movePrompt([]string{"home", "side"}); got != "Move to: 1 home  2 side (other key cancels)" {
+		t.Errorf("movePrompt() = %q", got)
+	}
+}
+
+// TestDisplayContentCache verifies that displayContent reuses styled lines while only the
+// cursor moves, restyles them when the text or search changes, and always matches an
+// uncached render.
+func TestDisplayContentCache(t *testing.T) {
+	cfg := config.Default()
+	m := New(cfg, "# Tasks\n- [ ] a @priority(A)\n- [x] b @done(2026-01-18)\n- [ ] c\n")
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	m = newModel.(Model)
+
+	uncached := func(m Model) string {
+		m.render = nil
+		return m.displayContent()
+	}
+	check := func(step string, m Model) {
+		t.Helper()
+		if got, want := m.displayContent(), uncached(m); got != want {
+			t.Errorf("%s: displayContent() = %q, want %q", step, got, want)
+		}
+	}
+
+	check("initial", m)
+	styled := m.render.lines
+
+	m.cursorMode = true
+	m.cursor = 1
+	check("cursor on line 1", m)
+	m.cursor = 3
+	check("cursor on line 3", m)
+	if &m.render.lines[0] != &styled[0] {
+		t.Error("moving the cursor restyled every line")
+	}
+
+	m.showHelp = true
+	check("help shown", m)
+	if &m.render.lines[0] != &styled[0] {
+		t.Error("showing help restyled every line")
+	}
+
+	m.searchQuery = "c"
+	check("search", m)
+	m.lines[3] = "- [x] c"
+	check("content changed", m)
+	if &m.render.lines[0] == &styled[0] {
+		t.Error("changed content did not restyle the lines")
+	}
+}
+
+// TestMainView verifies that mainView renders the same rows as viewport.View (with the
+// scrollbar appended) at every scroll position, for lines that need cutting, wide
+// characters, and tabs.
+func TestMainView(t *testing.T) {
+	content := "# Tasks\n" +
+		"- [ ] " + strings.Repeat("long task ", 12) + "@priority(A)\n" +
+		"- [ ] 日本語のタスク " + strings.Repeat("幅", 30) + "\n" +
+		"\t- [ ] tabbed @due(2026-03-01)\n" +
+		"- [x] done @done(2026-01-18)\n"
+	for i := 0; i < 20; i++ {
+		content += "- [ ] task " + strconv.Itoa(i) + "\n"
+	}
+
+	for _, scrollbar := range []bool{false, true} {
+		for _, cursor := range []bool{false, true} {
+			cfg := config.Default()
+			cfg.UI.Scrollbar = scrollbar
+			m := New(cfg, content)
+			newModel, _ := m.Update(tea.WindowSizeMsg{Width: 50, Height: 12})
+			m = newModel.(Model)
+			if cursor {
+				newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}})
+				m = newModel.(Model)
+			}
+			for step := 0; step < 25; step++ {
+				want := m.viewport.View()
+				if scrollbar {
+					want = m.withScrollbar(want)
+				}
+				if got := m.mainView(); got != want {
+					t.Fatalf("scrollbar=%t cursor=%t step %d: mainView() =\n%s\nwant\n%s", scrollbar, cursor, step, got, want)
+				}
+				newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+				m = newModel.(Model)
+			}
+		}
+	}
+}
+
+// BenchmarkScrollBurst measures Update and View for a burst of 200 'j' presses, as
+// sent by a held-down key, on a 3000-line file with the scrollbar on.
+func BenchmarkScrollBurst(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 3000; i++ {
+		switch i % 10 {
+		case 0:
+			fmt.Fprintf(&sb, "## Section %d\n", i)
+		case 3:
+			fmt.Fprintf(&sb, "- [x] Done %d @done(2026-01-01)\n", i)
+		default:
+			fmt.Fprintf(&sb, "- [ ] Task %d @priority(A) @est(1h) https://example.com/%d\n", i, i)
+		}
+	}
+	cfg := config.Default()
+	cfg.UI.Scrollbar = true
+	m := New(cfg, sb.String())
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = newModel.(Model)
+	_ = m.View()
+	down := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for k := 0; k < 200; k++ {
+			newModel, _ := m.Update(down)
+			m = newModel.(Model)
+			_ = m.View()
+		}
+	}
+}
+
+// TestEstimateFooter verifies the "est. remaining" footer segment: open tasks only,
+// limited to the saved filter, and hidden without estimates or in the archive view.
+func TestEstimateFooter(t *testing.T) {
+	cfg := config.Default()
+	content := "# Work\n- [ ] A @est(2h) @work\n- [ ] B @est(2h30m)\n- [x] C @est(1d) @done(2026-01-18)\n"
+	m := New(cfg, content)
+	m.width = 120
+
+	if footer := m.footerView(); !strings.Contains(footer, "est. remaining: 4h30m") {
+		t.Errorf("footer = %q, want est. remaining: 4h30m", footer)
+	}
+
+	q, err := task.CompileQuery("@work", task.DefaultOptions())
+	if err != nil {
+		t.Fatalf("CompileQuery() error: %v", err)
+	}
+	m.filter, m.filterName = q, "work"
+	if footer := m.footerView(); !strings.Contains(footer, "est. remaining: 2h ") {
+		t.Errorf("filtered footer = %q, want est. remaining: 2h", footer)
+	}
+
+	m.archiveMode = true
+	if footer := m.footerView(); strings.Contains(footer, "est. remaining") {
+		t.Errorf("archive footer = %q, want no estimate", footer)
+	}
+
+	m = New(cfg, "- [ ] A\n")
+	m.width = 120
+	if footer := m.footerView(); strings.Contains(footer, "est. remaining") {
+		t.Errorf("footer without estimates = %q", footer)
+	}
+}
+
+// TestDueView verifies that u shows open tasks by due date, that the scrolling keys
+// keep the view open, and that any other key returns without changing anything.
+func TestDueView(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+	content := "# Work\n- [ ] Undated\n- [ ] Today @due(" + today + ")\n- [x] Done @done(" + today + ")\n"
+	m := New(config.Default(), content)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newModel.(Model)
+	if !m.showDue {
+		t.Fatal("u did not open the due-date view")
+	}
+	view := m.View()
+	first := strings.Index(view, "(today) - [ ] Today")
+	second := strings.Index(view, "- [ ] Undated")
+	if first < 0 || second < first || strings.Contains(view, "Done") || strings.Contains(view, "# Work") {
+		t.Errorf("View() =\n%s\nwant the dated task first and only open tasks", view)
+	}
+	if footer := m.footerView(); !strings.Contains(footer, "-- BY DUE DATE (read-only) --") {
+		t.Errorf("footer = %q, want the due-date view hint", footer)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	m = newModel.(Model)
+	if !m.showDue {
+		t.Error("j closed the due-date view, want it to scroll")
+	}
+
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	m = newModel.(Model)
+	if m.showDue || m.editing || cmd != nil {
+		t.Errorf("e in the due-date view: showDue %v, editing %v, cmd %v; want it closed only", m.showDue, m.editing, cmd != nil)
+	}
+	if m.content != content {
+		t.Errorf("content changed to %q", m.content)
+	}
+}
+
+// TestResetSection verifies that R on a ## heading asks first and then unchecks the
+// completed tasks of that section only.
+func TestResetSection(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "## Packing\n- [x] Passport @done(2026-10-01)\n- [ ] Charger\n## Work\n- [x] Report @done(2026-10-01)\n- [ ] Review\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	press := func(m Model, key string) (Model, tea.Cmd) {
+		newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		return newModel.(Model), cmd
+	}
+
+	m, _ = press(m, "v")
+	m.cursor = 1
+	m, _ = press(m, "R")
+	if m.resetPending || m.status != "Not a ## heading" {
+		t.Errorf("R on a task: resetPending %v, status %q", m.resetPending, m.status)
+	}
+
+	m.cursor = 0
+	m, _ = press(m, "R")
+	if !m.resetPending || !strings.Contains(m.footerView(), "Reset 1 checked task(s) in Packing? (y/n)") {
+		t.Fatalf("R on a heading: resetPending %v, footer %q", m.resetPending, m.footerView())
+	}
+	m, cmd := press(m, "n")
+	if m.resetPending || cmd == nil {
+		t.Errorf("n should cancel the reset")
+	}
+	if got, _ := os.ReadFile(tasksPath); string(got) != content {
+		t.Errorf("tasks.md changed after n: %q", got)
+	}
+
+	m, _ = press(m, "R")
+	m, cmd = press(m, "y")
+	msg, ok := cmd().(ResetFinishedMsg)
+	if !ok || msg.Err != nil || msg.Count != 1 || msg.Heading != "Packing" {
+		t.Fatalf("reset result = %#v, want 1 task in Packing", msg)
+	}
+	newModel, _ = m.Update(msg)
+	m = newModel.(Model)
+	if m.reloadStatus != "Reset 1 task(s) in Packing" {
+		t.Errorf("reloadStatus = %q", m.reloadStatus)
+	}
+	want := "## Packing\n- [ ] Passport\n- [ ] Charger\n## Work\n- [x] Report @done(2026-10-01)\n- [ ] Review\n"
+	if got, _ := os.ReadFile(tasksPath); string(got) != want {
+		t.Errorf("tasks.md = %q, want %q", got, want)
+	}
+}
+
+// TestPullBeforeEdit verifies that e with git.pull_before_edit pulls before the editor
+// opens, and that an unreachable remote is reported with the status after the edit.
+func TestPullBeforeEdit(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	content := "- [ ] A\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "tasks.md"},
+		{"-c", "user.email=test@example.com", "-c", "user.name=Test User", "commit", "-q", "-m", "Initial"},
+		{"remote", "add", "origin", filepath.Join(dir, "missing.git")},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v error: %v\n%s", args, err, output)
+		}
+	}
+
+	cfg := config.Default()
+	cfg.Git.Mode = config.GitModeOwnRepo
+	m := NewWithPaths(cfg, content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	if got := newModel.(Model); !got.editing || got.status == "Pulling..." {
+		t.Errorf("e without pull_before_edit: editing %v, status %q", got.editing, got.status)
+	}
+
+	cfg.Git.PullBeforeEdit = true
+	m = NewWithPaths(cfg, content, tasksPath, filepath.Join(dir, "archive.md"))
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	m = newModel.(Model)
+	if m.status != "Pulling..." || cmd == nil {
+		t.Fatalf("e with pull_before_edit: status %q, cmd %v", m.status, cmd)
+	}
+	msg, ok := cmd().(PullBeforeEditMsg)
+	if !ok || !errors.Is(msg.Err, git.ErrOffline) || msg.Content != content {
+		t.Fatalf("pull result = %#v, want ErrOffline", msg)
+	}
+	newModel, cmd = m.Update(msg)
+	m = newModel.(Model)
+	if m.editWarning != "working offline" || cmd == nil {
+		t.Errorf("after pull: editWarning %q, cmd %v", m.editWarning, cmd)
+	}
+
+	newModel, _ = m.Update(AddDoneTagsFinishedMsg{Content: content + "- [ ] B\n"})
+	m = newModel.(Model)
+	if want := "File reloaded (1 lines added, 0 removed) (working offline)"; m.reloadStatus != want {
+		t.Errorf("reloadStatus = %q, want %q", m.reloadStatus, want)
+	}
+}
@@ -0,0 +1,133 @@
+package tui
+
+import (
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/yostos/tiny-task-tool/internal/task"
+)
+
+// minSplitWidth is the narrowest window ui.split_view splits: two panes of minWidth
+// and the separator. Narrower windows show tasks.md alone.
+const minSplitWidth = 2*minWidth + 1
+
+// ArchivePaneMsg is sent when the archive files have been read for the archive pane
+// of the split view (see task.LoadArchives).
+type ArchivePaneMsg struct {
+	Content string
+	Err     error
+}
+
+// splitWidths returns the width of the tasks.md pane, its scrollbar included, and of
+// the archive pane for a window width; the column between them is the separator.
+// right is 0 when ui.split_view is off or the window is narrower than minSplitWidth.
+func (m Model) splitWidths(width int) (left, right int) {
+	if !m.config.UI.SplitView || width < minSplitWidth {
+		return width, 0
+	}
+	left = (width - 1) / 2
+	return left, width - 1 - left
+}
+
+// splitActive reports whether the window is split into the tasks.md and archive panes.
+func (m Model) splitActive() bool {
+	_, right := m.splitWidths(m.width)
+	return right > 0
+}
+
+// archivePaneCmd returns a command that reads the archive files for the archive pane,
+// or nil when ui.split_view is off. Like the archive view, it reads at most
+// archiveViewBudget of them.
+func (m Model) archivePaneCmd() tea.Cmd {
+	if !m.config.UI.SplitView || m.archivePath == "" {
+		return nil
+	}
+	dir := filepath.Dir(m.archivePath)
+	return func() tea.Msg {
+		archives, err := task.LoadArchives(dir, archiveViewBudget, m.taskOpts)
+		return ArchivePaneMsg{Content: archives.Content, Err: err}
+	}
+}
+
+// archivePaneLoaded shows the archive files of msg in the archive pane, keeping its
+// scroll position as far as the new content allows.
+func (m Model) archivePaneLoaded(msg ArchivePaneMsg) (Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m.setStatusWithTimeout("Archive pane error: " + msg.Err.Error())
+	}
+	content := strings.TrimSuffix(msg.Content, "\n")
+	if content == "" {
+		content = "No archived tasks"
+	}
+	m.archivePane.SetContent(expandTabs(m.colorize(content)))
+	return m, nil
+}
+
+// handleArchivePaneKeyPress scrolls the archive pane while it has the focus: the
+// scroll keys of the main view move it, and esc gives the focus back to tasks.md.
+// ok is false for every other key, which works as usual.
+func (m Model) handleArchivePaneKeyPress(key string) (model Model, ok bool) {
+	switch key {
+	case "esc":
+		m.archivePaneFocus = false
+		return m, true
+	case "up":
+		m.archivePane.ScrollUp(1)
+		return m, true
+	case "down":
+		m.archivePane.ScrollDown(1)
+		return m, true
+	case "pgup":
+		m.archivePane.PageUp()
+		return m, true
+	case "pgdown":
+		m.archivePane.PageDown()
+		return m, true
+	}
+
+	switch m.matchAction(key) {
+	case actionUp:
+		m.archivePane.ScrollUp(1)
+	case actionDown:
+		m.archivePane.ScrollDown(1)
+	case actionTop:
+		m.archivePane.GotoTop()
+	case actionBottom:
+		m.archivePane.GotoBottom()
+	case actionHalfPageUp:
+		m.archivePane.HalfPageUp()
+	case actionHalfPageDown:
+		m.archivePane.HalfPageDown()
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// splitView puts the archive pane right of left, the rendered tasks.md pane, with a
+// separator column between them, drawn brighter while the archive pane has the focus.
+func (m Model) splitView(left string) string {
+	color := lipgloss.Color("240")
+	if m.archivePaneFocus {
+		color = lipgloss.Color("252")
+	}
+	separator := lipgloss.NewStyle().Foreground(color).Render("│")
+
+	leftRows := strings.Split(left, "\n")
+	rightRows := strings.Split(m.archivePane.View(), "\n")
+	var b strings.Builder
+	for i, row := range leftRows {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(row)
+		b.WriteString(separator)
+		if i < len(rightRows) {
+			b.WriteString(rightRows[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/yostos/tiny-task-tool/internal/config"
+)
+
+// TestSplitWidths verifies how the window is divided between the tasks.md pane, the
+// separator, and the archive pane, and that narrow windows are not split.
+func TestSplitWidths(t *testing.T) {
+	tests := []struct {
+		name      string
+		split     bool
+		width     int
+		wantLeft  int
+		wantRight int
+	}{
+		{"off", false, 120, 120, 0},
+		{"too narrow", true, minSplitWidth - 1, minSplitWidth - 1, 0},
+		{"narrowest split", true, minSplitWidth, minWidth, minWidth},
+		{"wide", true, 120, 59, 60},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.UI.SplitView = tt.split
+			left, right := New(cfg, "").splitWidths(tt.width)
+			if left != tt.wantLeft || right != tt.wantRight {
+				t.Errorf("splitWidths(%d) = %d, %d, want %d, %d", tt.width, left, right, tt.wantLeft, tt.wantRight)
+			}
+		})
+	}
+}
+
+// TestSplitView verifies that the archive pane is shown right of tasks.md, that Tab
+// moves the focus to it and the scroll keys then scroll only that pane, that the
+// footer shows the focused pane, and that a narrow window falls back to one pane.
+func TestSplitView(t *testing.T) {
+	dir := t.TempDir()
+	var archive strings.Builder
+	archive.WriteString("## 2026-01-20\n\n")
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&archive, "- [x] Archived %d @done(2026-01-20)\n", i)
+	}
+	archivePath := filepath.Join(dir, "archive.md")
+	if err := os.WriteFile(archivePath, []byte(archive.String()), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.UI.SplitView = true
+	content := strings.Repeat("- [ ] Task\n", 30)
+	m := NewWithPaths(cfg, content, filepath.Join(dir, "tasks.md"), archivePath)
+	update := func(msg tea.Msg) {
+		t.Helper()
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+	press := func(key string) {
+		t.Helper()
+		if key == "tab" {
+			update(tea.KeyMsg{Type: tea.KeyTab})
+		} else {
+			update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		}
+	}
+
+	update(tea.WindowSizeMsg{Width: 120, Height: 12})
+	update(m.archivePaneCmd()())
+	if m.viewport.Width != 59 || m.archivePane.Width != 60 {
+		t.Errorf("pane widths = %d, %d, want 59, 60", m.viewport.Width, m.archivePane.Width)
+	}
+	view := m.View()
+	if !strings.Contains(view, "Task") || !strings.Contains(view, "│- [x] Archived 1 ") {
+		t.Errorf("split View() should show both files side by side:\n%s", view)
+	}
+
+	press("j")
+	if m.viewport.YOffset != 1 || m.archivePane.YOffset != 0 {
+		t.Errorf("j in tasks.md: offsets %d, %d, want 1, 0", m.viewport.YOffset, m.archivePane.YOffset)
+	}
+
+	press("tab")
+	press("j")
+	press("j")
+	if !m.archivePaneFocus || m.viewport.YOffset != 1 || m.archivePane.YOffset != 2 {
+		t.Errorf("j in the archive pane: focus %v, offsets %d, %d, want true, 1, 2", m.archivePaneFocus, m.viewport.YOffset, m.archivePane.YOffset)
+	}
+	footer := m.footerView()
+	if !strings.Contains(footer, "ARCHIVE PANE") || !strings.Contains(footer, "[archive] [3/32]") {
+		t.Errorf("footer with the archive pane focused = %q", footer)
+	}
+
+	press("tab")
+	if m.archivePaneFocus || strings.Contains(m.footerView(), "[archive]") {
+		t.Error("tab should move the focus back to tasks.md")
+	}
+
+	// A narrow window shows tasks.md alone and drops the focus
+	press("tab")
+	update(tea.WindowSizeMsg{Width: minSplitWidth - 1, Height: 12})
+	if m.archivePaneFocus || m.viewport.Width != minSplitWidth-1 || strings.Contains(m.View(), "Archived") {
+		t.Errorf("narrow window: focus %v, width %d, archive shown %v", m.archivePaneFocus, m.viewport.Width, strings.Contains(m.View(), "Archived"))
+	}
+	press("tab")
+	if m.archivePaneFocus {
+		t.Error("tab should do nothing while the window is not split")
+	}
+}
@@ -0,0 +1,281 @@
+package tui
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/yostos/tiny-task-tool/internal/task"
+)
+
+// The undo history keeps at most maxUndoSnapshots changes, and drops the oldest ones
+// while the file contents it holds add up to more than maxUndoBytes.
+const (
+	maxUndoSnapshots = 30
+	maxUndoBytes     = 4 << 20
+)
+
+// FileState is the content of a file at one point; Exists is false for a file that
+// was not there yet, such as an archive file the change created.
+type FileState struct {
+	Content string
+	Exists  bool
+}
+
+// Snapshot is one change that u can undo: every file the operation may have written,
+// keyed by path, as it was before and after. Whole contents are kept rather than
+// diffs, so undo and redo are plain writes.
+type Snapshot struct {
+	Label  string // the operation, for the status: "delete", "archive", ...
+	Before map[string]FileState
+	After  map[string]FileState
+}
+
+// size is the number of bytes of file content s holds.
+func (s Snapshot) size() int {
+	n := 0
+	for _, f := range s.Before {
+		n += len(f.Content)
+	}
+	for _, f := range s.After {
+		n += len(f.Content)
+	}
+	return n
+}
+
+// Undoable is embedded in the messages of the operations u can undo. Undo is the
+// change the operation made, nil when it failed or changed nothing.
+type Undoable struct {
+	Undo *Snapshot
+}
+
+func (u Undoable) undoSnapshot() *Snapshot {
+	return u.Undo
+}
+
+// UndoFinishedMsg is sent after u (or ctrl+r, with Redo set) wrote Snapshot's files
+// back.
+type UndoFinishedMsg struct {
+	Snapshot Snapshot
+	Redo     bool
+	Err      error
+}
+
+// errUndoStale is returned when the files changed since the change being undone,
+// e.g. in the editor or by a sync; the undo history no longer applies then.
+var errUndoStale = errors.New("files changed on disk since, undo history cleared")
+
+// undoable wraps cmd, an operation that may write paths, so that the change it makes
+// can be undone: the files are read before and after cmd runs, and when any of them
+// changed, the Snapshot goes with cmd's message (see Undoable).
+func (m Model) undoable(label string, cmd tea.Cmd, paths ...string) tea.Cmd {
+	opts := m.taskOpts
+	return func() tea.Msg {
+		before, err := readStates(paths, opts)
+		msg := cmd()
+		if err != nil {
+			return msg
+		}
+		after, err := readStates(paths, opts)
+		if err != nil || sameStates(before, after) {
+			return msg
+		}
+		return withUndo(msg, &Snapshot{Label: label, Before: before, After: after})
+	}
+}
+
+// withUndo sets the Undo field of an operation's message.
+func withUndo(msg tea.Msg, s *Snapshot) tea.Msg {
+	switch msg := msg.(type) {
+	case ArchiveFinishedMsg:
+		msg.Undo = s
+		return msg
+	case ToggleChildrenFinishedMsg:
+		msg.Undo = s
+		return msg
+	case RestoreFinishedMsg:
+		msg.Undo = s
+		return msg
+	case MoveFinishedMsg:
+		msg.Undo = s
+		return msg
+	case PinFinishedMsg:
+		msg.Undo = s
+		return msg
+	case DeleteFinishedMsg:
+		msg.Undo = s
+		return msg
+	case ResetFinishedMsg:
+		msg.Undo = s
+		return msg
+	case PasteFinishedMsg:
+		msg.Undo = s
+		return msg
+	case InlineEditFinishedMsg:
+		msg.Undo = s
+		return msg
+	case TrackFinishedMsg:
+		msg.Undo = s
+		return msg
+	}
+	return msg
+}
+
+// readStates reads the files at paths; a missing file is not an error.
+func readStates(paths []string, opts task.Options) (map[string]FileState, error) {
+	states := make(map[string]FileState, len(paths))
+	for _, path := range paths {
+		content, err := task.LoadFile(path, opts)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			states[path] = FileState{}
+		case err != nil:
+			return nil, err
+		default:
+			states[path] = FileState{Content: content, Exists: true}
+		}
+	}
+	return states, nil
+}
+
+// sameStates reports whether a and b hold the same files with the same contents.
+func sameStates(a, b map[string]FileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, f := range a {
+		if g, ok := b[path]; !ok || f != g {
+			return false
+		}
+	}
+	return true
+}
+
+// writeStates writes every file back as states has it, removing the files that did
+// not exist. Contents are written as they are, without tidying (see task.WriteFile).
+func writeStates(states map[string]FileState, opts task.Options) error {
+	paths := make([]string, 0, len(states))
+	for path := range states {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		f := states[path]
+		if !f.Exists {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		if err := task.WriteFile(path, f.Content, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushSnapshot appends s to stack, dropping the oldest snapshots beyond
+// maxUndoSnapshots or maxUndoBytes. A snapshot over maxUndoBytes on its own is not
+// kept at all.
+func pushSnapshot(stack []Snapshot, s Snapshot) []Snapshot {
+	stack = append(stack, s)
+	total := 0
+	for _, s := range stack {
+		total += s.size()
+	}
+	for len(stack) > 0 && (len(stack) > maxUndoSnapshots || total > maxUndoBytes) {
+		total -= stack[0].size()
+		stack = stack[1:]
+	}
+	return stack
+}
+
+// recordUndo adds the change reported by msg, if any, to the undo history. A new
+// change makes the undone ones unreachable, so the redo stack is cleared.
+func (m Model) recordUndo(msg tea.Msg) Model {
+	u, ok := msg.(interface{ undoSnapshot() *Snapshot })
+	if !ok || u.undoSnapshot() == nil {
+		return m
+	}
+	m.undoStack = pushSnapshot(m.undoStack, *u.undoSnapshot())
+	m.redoStack = nil
+	return m
+}
+
+// clearUndo forgets the undo history, after the files were changed outside of it.
+func (m Model) clearUndo() Model {
+	m.undoStack = nil
+	m.redoStack = nil
+	return m
+}
+
+// undo writes back the files of the latest change (redo: of the latest undone
+// change). The snapshot is taken off its stack now and put on the other one once
+// UndoFinishedMsg reports the write.
+func (m Model) undo(redo bool) (Model, tea.Cmd) {
+	stack := &m.undoStack
+	if redo {
+		stack = &m.redoStack
+	}
+	if len(*stack) == 0 {
+		if redo {
+			return m.setStatusWithTimeout("Nothing to redo")
+		}
+		return m.setStatusWithTimeout("Nothing to undo")
+	}
+	s := (*stack)[len(*stack)-1]
+	*stack = (*stack)[:len(*stack)-1]
+
+	expected, target := s.After, s.Before
+	if redo {
+		expected, target = s.Before, s.After
+	}
+	opts := m.taskOpts
+	return m, func() tea.Msg {
+		paths := make([]string, 0, len(expected))
+		for path := range expected {
+			paths = append(paths, path)
+		}
+		current, err := readStates(paths, opts)
+		if err != nil {
+			return UndoFinishedMsg{Snapshot: s, Redo: redo, Err: err}
+		}
+		if !sameStates(current, expected) {
+			return UndoFinishedMsg{Snapshot: s, Redo: redo, Err: errUndoStale}
+		}
+		return UndoFinishedMsg{Snapshot: s, Redo: redo, Err: writeStates(target, opts)}
+	}
+}
+
+// undoFinished handles UndoFinishedMsg: the snapshot moves to the other stack and the
+// file is reloaded.
+func (m Model) undoFinished(msg UndoFinishedMsg) (Model, tea.Cmd) {
+	verb := "Undo"
+	if msg.Redo {
+		verb = "Redo"
+	}
+	switch {
+	case errors.Is(msg.Err, errUndoStale):
+		m = m.clearUndo()
+		return m.setStatusWithTimeout("Can't " + strings.ToLower(verb) + ": " + msg.Err.Error())
+	case msg.Err != nil:
+		// Nothing or not all was written; keep the change where it was to try again
+		if msg.Redo {
+			m.redoStack = append(m.redoStack, msg.Snapshot)
+		} else {
+			m.undoStack = append(m.undoStack, msg.Snapshot)
+		}
+		return m.setStatusWithTimeout(verb + " error: " + msg.Err.Error())
+	}
+	if msg.Redo {
+		m.undoStack = pushSnapshot(m.undoStack, msg.Snapshot)
+		m.reloadStatus = "Redone: " + msg.Snapshot.Label
+	} else {
+		m.redoStack = pushSnapshot(m.redoStack, msg.Snapshot)
+		m.reloadStatus = "Undone: " + msg.Snapshot.Label
+	}
+	return m, m.reloadCmd()
+}
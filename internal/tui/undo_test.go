@@ -0,0 +1,161 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/yostos/tiny-task-tool/internal/config"
+)
+
+// TestPushSnapshot verifies that the undo history drops its oldest snapshots beyond
+// maxUndoSnapshots or maxUndoBytes.
+func TestPushSnapshot(t *testing.T) {
+	sized := func(label string, n int) Snapshot {
+		return Snapshot{Label: label, Before: map[string]FileState{"tasks.md": {Content: strings.Repeat("x", n), Exists: true}}}
+	}
+
+	tests := []struct {
+		name   string
+		stack  []Snapshot
+		push   Snapshot
+		labels string
+	}{
+		{"empty", nil, sized("a", 10), "a"},
+		{"under both limits", []Snapshot{sized("a", 10), sized("b", 10)}, sized("c", 10), "a b c"},
+		{"over the byte limit", []Snapshot{sized("a", maxUndoBytes/2), sized("b", maxUndoBytes/2)}, sized("c", 10), "b c"},
+		{"too large on its own", []Snapshot{sized("a", 10)}, sized("b", maxUndoBytes+1), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var labels []string
+			for _, s := range pushSnapshot(tt.stack, tt.push) {
+				labels = append(labels, s.Label)
+			}
+			if got := strings.Join(labels, " "); got != tt.labels {
+				t.Errorf("pushSnapshot() = %q, want %q", got, tt.labels)
+			}
+		})
+	}
+
+	var stack []Snapshot
+	for i := 0; i < maxUndoSnapshots+5; i++ {
+		stack = pushSnapshot(stack, sized(strings.Repeat("s", i+1), 1))
+	}
+	if len(stack) != maxUndoSnapshots || len(stack[0].Label) != 6 {
+		t.Errorf("after %d pushes: %d snapshots, oldest %q", maxUndoSnapshots+5, len(stack), stack[0].Label)
+	}
+}
+
+// TestUndoRedo verifies that u writes back the files of the latest change and ctrl+r
+// writes the change again, and that the history is cleared after editing or when the
+// file changed on disk in between.
+func TestUndoRedo(t *testing.T) {
+	dir := t.TempDir()
+	tasksPath := filepath.Join(dir, "tasks.md")
+	archivePath := filepath.Join(dir, "archive.md")
+	content := "- [ ] a\n  - [ ] a1\n- [x] b @done(2020-01-01)\n"
+	if err := os.WriteFile(tasksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	m := NewWithPaths(config.Default(), content, tasksPath, archivePath)
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = newModel.(Model)
+
+	update := func(m Model, msg tea.Msg) (Model, tea.Cmd) {
+		newModel, cmd := m.Update(msg)
+		return newModel.(Model), cmd
+	}
+	press := func(m Model, key string) (Model, tea.Cmd) {
+		if key == "ctrl+r" {
+			return update(m, tea.KeyMsg{Type: tea.KeyCtrlR})
+		}
+		return update(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	}
+	// run runs cmd and passes its message to the model
+	run := func(m Model, cmd tea.Cmd) Model {
+		t.Helper()
+		if cmd == nil {
+			t.Fatal("expected a command")
+		}
+		m, _ = update(m, cmd())
+		return m
+	}
+	fileIs := func(t *testing.T, path, want string) {
+		t.Helper()
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+
+	m, _ = press(m, "v")
+	m, _ = press(m, "u")
+	if m.status != "Nothing to undo" {
+		t.Errorf("u with no history: status = %q", m.status)
+	}
+
+	m, cmd := press(m, "d")
+	m = run(m, cmd)
+	afterDelete := "- [x] b @done(2020-01-01)\n"
+	fileIs(t, tasksPath, afterDelete)
+	if len(m.undoStack) != 1 {
+		t.Fatalf("undo history after d: %d snapshots, want 1", len(m.undoStack))
+	}
+
+	m, cmd = press(m, "u")
+	m = run(m, cmd)
+	fileIs(t, tasksPath, content)
+	if m.reloadStatus != "Undone: delete" || len(m.undoStack) != 0 || len(m.redoStack) != 1 {
+		t.Errorf("after u: reloadStatus %q, %d undo, %d redo", m.reloadStatus, len(m.undoStack), len(m.redoStack))
+	}
+
+	m, cmd = press(m, "ctrl+r")
+	m = run(m, cmd)
+	fileIs(t, tasksPath, afterDelete)
+	if m.reloadStatus != "Redone: delete" || len(m.undoStack) != 1 || len(m.redoStack) != 0 {
+		t.Errorf("after ctrl+r: reloadStatus %q, %d undo, %d redo", m.reloadStatus, len(m.undoStack), len(m.redoStack))
+	}
+
+	// Undoing an archive pass removes the archive file it created
+	m, cmd = update(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m = run(m, cmd)
+	if _, err := os.Stat(archivePath); err != nil {
+		t.Fatalf("archive.md not written: %v", err)
+	}
+	m, cmd = press(m, "u")
+	m = run(m, cmd)
+	fileIs(t, tasksPath, afterDelete)
+	if _, err := os.Stat(archivePath); !os.IsNotExist(err) {
+		t.Errorf("archive.md should be removed by undo, stat error %v", err)
+	}
+
+	// A change made outside the TUI stops the undo
+	if err := os.WriteFile(tasksPath, []byte(afterDelete+"- [ ] c\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	m, cmd = press(m, "u")
+	m = run(m, cmd)
+	fileIs(t, tasksPath, afterDelete+"- [ ] c\n")
+	if !strings.HasPrefix(m.status, "Can't undo: files changed on disk") || len(m.undoStack)+len(m.redoStack) != 0 {
+		t.Errorf("stale undo: status %q, %d undo, %d redo", m.status, len(m.undoStack), len(m.redoStack))
+	}
+
+	// Editing in the editor clears the history
+	m.content = afterDelete + "- [ ] c\n"
+	m.lines = parseLines(m.content)
+	m.cursor = 0
+	m, cmd = press(m, "*")
+	m = run(m, cmd)
+	if len(m.undoStack) != 1 {
+		t.Fatalf("undo history after *: %d snapshots, want 1", len(m.undoStack))
+	}
+	m, _ = update(m, EditFinishedMsg{})
+	if len(m.undoStack)+len(m.redoStack) != 0 {
+		t.Errorf("history after editing: %d undo, %d redo", len(m.undoStack), len(m.redoStack))
+	}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,16 +20,31 @@ import (
 	"github.com/yostos/tiny-task-tool/internal/cli"
 	"github.com/yostos/tiny-task-tool/internal/config"
 	"github.com/yostos/tiny-task-tool/internal/git"
+	"github.com/yostos/tiny-task-tool/internal/task"
 	"github.com/yostos/tiny-task-tool/internal/tui"
 )
 
 func main() {
 	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
 
+// exitCodeError makes ttt exit with a specific code, e.g. a piped command's exit code.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+
+func (e *exitCodeError) Unwrap() error { return e.err }
+
 func run() error {
 	opts, err := cli.Parse(os.Args[1:])
 	if err != nil {
@@ -39,9 +61,66 @@ func run() error {
 		return nil
 	}
 
-	cfg, err := config.Load()
+	var timing *debugTimer
+	if opts.DebugTiming {
+		timing = newDebugTimer(os.Stderr)
+		defer timing.flush()
+	}
+
+	if opts.Verbose {
+		logPath, closeLog, err := startVerbose(os.Stderr, opts.LaunchesTUI())
+		if err != nil {
+			return err
+		}
+		defer closeLog()
+		if logPath != "" {
+			// Printed once the TUI has left the screen
+			defer fmt.Fprintln(os.Stderr, "Debug log written to "+logPath)
+		}
+	}
+
+	start := timing.now()
+	cfg, err := loadConfig(opts.SafeMode, os.Stderr)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
+	}
+	timing.since("config.Load", start)
+	if path, err := config.ConfigPath(); err == nil {
+		logger.Debug("config", "path", path)
+	}
+
+	if err := checkConfigErrors(cfg, opts.StrictConfig, os.Stderr); err != nil {
+		return err
+	}
+	if err := checkUnknownKeys(cfg, opts.StrictConfig, os.Stderr); err != nil {
+		return err
+	}
+
+	git.SetMessageStyle(cfg.Git.CommitPrefix, cfg.Git.CommitLanguage)
+
+	if opts.ListWS {
+		listWorkspaces(cfg, opts.Workspace)
+		return nil
+	}
+
+	if opts.Workspace != "" {
+		if err := cfg.UseWorkspace(opts.Workspace); err != nil {
+			return err
+		}
+	}
+
+	if opts.Force {
+		// --force disables the tasks.md guard for this run (CLI and TUI)
+		cfg.File.GuardLines = 0
+	}
+
+	// The target of "ttt move" is taken before ensureWorkingDir resolves git.mode "auto",
+	// so it gets the mode of its own directory
+	var moveTarget *config.Config
+	if opts.Move {
+		if moveTarget, err = cfg.ForWorkspace(opts.MoveTo); err != nil {
+			return err
+		}
 	}
 
 	if err := ensureWorkingDir(cfg); err != nil {
@@ -57,12 +136,103 @@ func run() error {
 		return syncTasks(cfg)
 	}
 
+	if opts.Doctor {
+		return doctor(cfg, opts.Fix)
+	}
+
+	if opts.Check {
+		return check(cfg)
+	}
+
+	if opts.Report {
+		return report(cfg, opts)
+	}
+
+	if opts.Archive && opts.Consolidate {
+		return consolidateArchive(cfg, opts.DryRun)
+	}
+
+	if opts.Archive {
+		return archiveTasks(cfg, opts.ArchiveTo)
+	}
+
+	if opts.Stats && opts.Estimates {
+		return estimates(cfg)
+	}
+
+	if opts.Stats {
+		return stats(cfg, opts.Weekdays)
+	}
+
+	if opts.Search != "" {
+		return search(cfg, opts.Search, opts.InArchive)
+	}
+
+	if opts.ListByDue {
+		return listByDue(cfg)
+	}
+
+	if opts.Move {
+		return moveTask(cfg, moveTarget, opts.MovePattern)
+	}
+
+	if opts.Done {
+		return doneTasks(cfg, opts.DoneMatch, opts.DoneAll)
+	}
+
+	if opts.Reset {
+		return resetSection(cfg, opts.ResetHeading)
+	}
+
+	if opts.Migrate {
+		return migrate(cfg, opts.MigrateFile, opts.MigrateOut)
+	}
+
 	if opts.Task != "" {
-		return addTask(cfg, opts.Task)
+		return addTask(cfg, opts.Task, opts.UnderLine)
 	}
 
 	// TUI mode
-	return runTUI(cfg)
+	return runTUI(cfg, timing)
+}
+
+// listWorkspaces prints the default workspace and all configured workspaces.
+// The active workspace (default when active is empty) is marked with "*".
+func listWorkspaces(cfg *config.Config, active string) {
+	if active == "" {
+		active = config.DefaultWorkspaceName
+	}
+	fmt.Print(formatWorkspaceList(cfg, active))
+}
+
+// formatWorkspaceList formats one "name  working_dir" line per workspace.
+func formatWorkspaceList(cfg *config.Config, active string) string {
+	type entry struct{ name, dir string }
+	entries := []entry{{config.DefaultWorkspaceName, cfg.File.WorkingDir}}
+	for _, ws := range cfg.Workspaces {
+		if ws.Name == config.DefaultWorkspaceName {
+			entries[0].dir = ws.WorkingDir
+			continue
+		}
+		entries = append(entries, entry{ws.Name, ws.WorkingDir})
+	}
+
+	width := 0
+	for _, e := range entries {
+		if len(e.name) > width {
+			width = len(e.name)
+		}
+	}
+
+	var b strings.Builder
+	for _, e := range entries {
+		marker := " "
+		if e.name == active {
+			marker = "*"
+		}
+		fmt.Fprintf(&b, "%s %-*s  %s\n", marker, width, e.name, e.dir)
+	}
+	return b.String()
 }
 
 func ensureWorkingDir(cfg *config.Config) error {
@@ -71,24 +241,34 @@ func ensureWorkingDir(cfg *config.Config) error {
 		return fmt.Errorf("failed to get working directory: %w", err)
 	}
 
+	created := false
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return fmt.Errorf("failed to create working directory: %w", err)
 		}
-
-		if err := initGitRepo(dir); err != nil {
-			return fmt.Errorf("failed to initialize git repository: %w", err)
-		}
-
-		if err := ensureRepoFiles(dir); err != nil {
-			return fmt.Errorf("failed to create repository files: %w", err)
-		}
+		created = true
 	} else if err != nil {
 		return fmt.Errorf("failed to access working directory: %w", err)
-	} else {
+	}
+
+	if err := resolveGitMode(cfg, dir); err != nil {
+		return err
+	}
+	logger.Debug("working dir", "workspace", cfg.Workspace(), "dir", dir, "created", created, "git_mode", cfg.Git.Mode)
+
+	// Only ttt's own repository is initialized and gets README.md and .gitignore
+	if cfg.Git.Mode == config.GitModeOwnRepo {
 		if err := ensureGitRepo(dir); err != nil {
 			return fmt.Errorf("failed to ensure git repository: %w", err)
 		}
+		if err := git.SetUser(dir, cfg.Git.UserName, cfg.Git.UserEmail); err != nil {
+			return fmt.Errorf("failed to set git user: %w", err)
+		}
+		if created {
+			if err := ensureRepoFiles(dir); err != nil {
+				return fmt.Errorf("failed to create repository files: %w", err)
+			}
+		}
 	}
 
 	tasksPath, err := cfg.TasksPath()
@@ -107,10 +287,33 @@ func ensureWorkingDir(cfg *config.Config) error {
 	return nil
 }
 
+// resolveGitMode replaces git.mode "auto" with the mode for dir: own-repo when dir
+// has its own .git or is not inside any repository, parent-repo when dir is inside
+// another repository (so no nested repository is created). parent-repo requires an
+// enclosing repository. With disabled, the git settings that would run git are turned off.
+func resolveGitMode(cfg *config.Config, dir string) error {
+	switch cfg.Git.Mode {
+	case config.GitModeAuto:
+		cfg.Git.Mode = config.GitModeOwnRepo
+		if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+			if _, err := git.Toplevel(dir); err == nil {
+				cfg.Git.Mode = config.GitModeParentRepo
+			}
+		}
+	case config.GitModeParentRepo:
+		if _, err := git.Toplevel(dir); err != nil {
+			return fmt.Errorf("git.mode is \"parent-repo\", but %w", err)
+		}
+	case config.GitModeDisabled:
+		cfg.Git.AutoCommit = false
+		cfg.Git.AutoSyncMinutes = 0
+		cfg.Git.IntegrityCheck = false
+	}
+	return nil
+}
+
 func initGitRepo(dir string) error {
-	cmd := exec.Command("git", "init")
-	cmd.Dir = dir
-	return cmd.Run()
+	return git.Init(dir)
 }
 
 func ensureGitRepo(dir string) error {
@@ -198,42 +401,339 @@ Desktop.ini
 	return nil
 }
 
-func addTask(cfg *config.Config, task string) error {
+// addTask adds text as a new open task: under its "heading:" prefix's section, or as
+// a subtask of the task on line under when under is not nil.
+func addTask(cfg *config.Config, text string, under *int) error {
+	topts := taskOptions(cfg)
 	tasksPath, err := cfg.TasksPath()
 	if err != nil {
 		return fmt.Errorf("failed to get tasks path: %w", err)
 	}
 
-	content, err := os.ReadFile(tasksPath)
+	pullBeforeEdit(cfg)
+
+	content, err := task.LoadFile(tasksPath, topts)
 	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to read tasks file: %w", err)
 	}
 
-	taskLine := fmt.Sprintf("- [ ] %s\n", task)
+	if !cfg.LooksLikeTaskFile(content) {
+		return fmt.Errorf("%s does not look like a task list; check working_dir or use --force to add anyway", tasksPath)
+	}
 
-	var newContent string
-	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
-		newContent = string(content) + "\n" + taskLine
-	} else {
-		newContent = string(content) + taskLine
+	// "work: review PR" goes under "## Work" when such a heading exists
+	heading := ""
+	if under == nil {
+		heading, text, _ = task.RouteByPrefixWith(content, text, topts)
+	}
+	if cfg.Tasks.WeekdayDue == task.WeekdayDueExpand {
+		// @due(fri) becomes this Friday's date
+		text = task.ExpandWeekdayDue(text, time.Now())
+	}
+
+	if cfg.IsDuplicateTask(content, text) {
+		fmt.Fprintf(os.Stderr, "Warning: skipped duplicate task: %s\n", text)
+		return nil
+	}
+
+	newContent, ins, parent, err := placeTask(cfg, content, heading, text, under)
+	if err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(tasksPath, []byte(newContent), 0644); err != nil {
+	if err := task.WriteTasksFile(tasksPath, newContent, topts); err != nil {
 		return fmt.Errorf("failed to write tasks file: %w", err)
 	}
 
 	if cfg.Git.AutoCommit {
-		if err := gitCommit(cfg, fmt.Sprintf("Add task: %s", task)); err != nil {
+		if err := gitCommit(cfg, git.ActionAdd, text); err != nil {
 			// Don't fail if git commit fails, just log it
 			fmt.Fprintf(os.Stderr, "Warning: git commit failed: %v\n", err)
 		}
 	}
 
-	fmt.Printf("Added: %s\n", task)
+	fmt.Println(addedMessage(text, ins, parent))
+	return nil
+}
+
+// placeTask adds text to content for addTask: at the end of the "## heading"
+// section (the end of the file if heading is "" or missing), or as a subtask of the
+// task on line under, with file.auto_title put on top of a file without headings.
+// parent is the line the parent task ends up on (0 without under).
+func placeTask(cfg *config.Config, content, heading, text string, under *int) (newContent string, ins task.Insertion, parent int, err error) {
+	topts := taskOptions(cfg)
+	if under == nil {
+		newContent, ins = task.InsertUnderHeading(cfg.WithTitle(content), heading, task.WrapTaskLine("- [ ] "+text, topts), topts)
+		return newContent, ins, 0, nil
+	}
+
+	// Insert before auto_title can add a heading, so the line number still matches
+	child, ins, err := task.InsertChild(content, *under, text, topts)
+	if err != nil {
+		return "", task.Insertion{}, 0, fmt.Errorf("cannot add subtask: %w", err)
+	}
+	newContent, parent = cfg.WithTitle(child), *under
+	if newContent != child {
+		// The title went on top, moving both tasks down
+		shift := strings.Count(newContent, "\n") - strings.Count(child, "\n")
+		ins.Line += shift
+		parent += shift
+		if ins.Heading == "" {
+			ins.Heading = cfg.File.AutoTitle
+		}
+	}
+	return newContent, ins, parent, nil
+}
+
+// addedMessage tells where addTask put text, e.g. `Added under "## Inbox" (line 14):
+// buy milk`; a subtask (parent > 0) names its parent's line too.
+func addedMessage(text string, ins task.Insertion, parent int) string {
+	where := fmt.Sprintf("line %d", ins.Line)
+	if parent > 0 {
+		where = fmt.Sprintf("line %d, subtask of line %d", ins.Line, parent)
+	}
+	if ins.Heading == "" {
+		return fmt.Sprintf("Added (%s): %s", where, text)
+	}
+	return fmt.Sprintf("Added under %q (%s): %s", ins.Heading, where, text)
+}
+
+// moveTask moves the task containing pattern, with its subtasks, from the active
+// workspace to the [tasks] inbox_heading section of target. The target is written
+// first, so a failed write never loses the task; with auto_commit each workspace
+// commits its side of the move.
+func moveTask(cfg, target *config.Config, pattern string) error {
+	topts, targetOpts := taskOptions(cfg), taskOptions(target)
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
+	targetPath, err := target.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
+	if tasksPath == targetPath {
+		return fmt.Errorf("workspaces %q and %q use the same tasks file", cfg.Workspace(), target.Workspace())
+	}
+	if err := ensureWorkingDir(target); err != nil {
+		return err
+	}
+
+	content, err := task.LoadFile(tasksPath, topts)
+	if err != nil {
+		return fmt.Errorf("failed to read tasks file: %w", err)
+	}
+	targetContent, err := task.LoadFile(targetPath, targetOpts)
+	if err != nil {
+		return fmt.Errorf("failed to read tasks file: %w", err)
+	}
+	if !cfg.LooksLikeTaskFile(content) {
+		return fmt.Errorf("%s does not look like a task list; check working_dir or use --force to move anyway", tasksPath)
+	}
+	if !target.LooksLikeTaskFile(targetContent) {
+		return fmt.Errorf("%s does not look like a task list; check working_dir or use --force to move anyway", targetPath)
+	}
+
+	line, err := findTaskLine(content, pattern, cfg.SearchOptions(), topts)
+	if err != nil {
+		return err
+	}
+	subtree, remaining, err := task.ExtractSubtreeWith(content, line, topts)
+	if err != nil {
+		return err
+	}
+	text := task.TaskBody(strings.SplitN(subtree, "\n", 2)[0], topts)
+
+	newTarget, _ := task.InsertUnderHeading(target.WithTitle(targetContent), target.Tasks.InboxHeading, subtree, targetOpts)
+	if err := task.WriteTasksFile(targetPath, newTarget, targetOpts); err != nil {
+		return fmt.Errorf("failed to write tasks file: %w", err)
+	}
+	if err := task.WriteTasksFile(tasksPath, remaining, topts); err != nil {
+		return fmt.Errorf("copied to %s, but failed to remove it from %s: %w", target.Workspace(), tasksPath, err)
+	}
+
+	if target.Git.AutoCommit {
+		if err := gitCommit(target, git.ActionMoveFrom, cfg.Workspace(), text); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: git commit failed in %s: %v\n", target.Workspace(), err)
+		}
+	}
+	if cfg.Git.AutoCommit {
+		if err := gitCommit(cfg, git.ActionMoveTo, target.Workspace(), text); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: git commit failed in %s: %v\n", cfg.Workspace(), err)
+		}
+	}
+
+	fmt.Printf("Moved: %s (%s → %s)\n", text, cfg.Workspace(), target.Workspace())
+	return nil
+}
+
+// findTaskLine returns the line (0-indexed) of the only task containing pattern,
+// compared like "ttt search". More than one match is an error listing them.
+func findTaskLine(content, pattern string, opts task.SearchOptions, topts task.Options) (int, error) {
+	var matches []task.ParsedLine
+	for _, line := range task.ParseLines(content, topts) {
+		if line.IsTask && len(task.FindMatches(line.Content, pattern, opts)) > 0 {
+			matches = append(matches, line)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("no task contains %q", pattern)
+	case 1:
+		return matches[0].LineNumber, nil
+	}
+	var b strings.Builder
+	for _, m := range matches {
+		fmt.Fprintf(&b, "\n  %d: %s", m.LineNumber+1, m.Content)
+	}
+	return 0, fmt.Errorf("%q matches %d tasks; use more of the text:%s", pattern, len(matches), b.String())
+}
+
+// doneTasks completes the open task containing match, or with all every such task,
+// the way checking it in the TUI does: subtasks cascade and tasks.md is committed
+// when auto_commit is on. More than one match without all is an error listing them.
+func doneTasks(cfg *config.Config, match string, all bool) error {
+	topts := taskOptions(cfg)
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
+	content, err := task.LoadFile(tasksPath, topts)
+	if err != nil {
+		return fmt.Errorf("failed to read tasks file: %w", err)
+	}
+	if !cfg.LooksLikeTaskFile(content) {
+		return fmt.Errorf("%s does not look like a task list; check working_dir or use --force to complete anyway", tasksPath)
+	}
+
+	matches, err := task.FindTaskByTextWith(content, match, cfg.SearchOptions(), topts)
+	if err != nil {
+		return err
+	}
+	split := strings.Split(content, "\n")
+	switch {
+	case len(matches) == 0:
+		return fmt.Errorf("no open task contains %q", match)
+	case len(matches) > 1 && !all:
+		var b strings.Builder
+		for _, i := range matches {
+			fmt.Fprintf(&b, "\n  %d: %s", i+1, split[i])
+		}
+		return fmt.Errorf("%q matches %d open tasks; use more of the text or --all:%s", match, len(matches), b.String())
+	}
+
+	texts := make([]string, len(matches))
+	for n, i := range matches {
+		texts[n] = task.TaskBody(split[i], topts)
+	}
+	newContent, completed := task.CompleteTasks(content, matches, topts)
+	if err := task.WriteTasksFile(tasksPath, newContent, topts); err != nil {
+		return fmt.Errorf("failed to write tasks file: %w", err)
+	}
+
+	if cfg.Git.AutoCommit {
+		if err := gitCommit(cfg, git.ActionDone, strings.Join(texts, ", ")); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: git commit failed: %v\n", err)
+		}
+	}
+
+	for _, text := range texts {
+		fmt.Printf("Done: %s\n", text)
+	}
+	if cascaded := completed - len(matches); cascaded > 0 {
+		fmt.Printf("Also completed %d subtask(s)\n", cascaded)
+	}
+	return nil
+}
+
+// resetSection unchecks the completed tasks of the ## section heading for another
+// round of a reusable checklist, and commits tasks.md when auto_commit is on.
+func resetSection(cfg *config.Config, heading string) error {
+	topts := taskOptions(cfg)
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
+	content, err := task.LoadFile(tasksPath, topts)
+	if err != nil {
+		return fmt.Errorf("failed to read tasks file: %w", err)
+	}
+	if !cfg.LooksLikeTaskFile(content) {
+		return fmt.Errorf("%s does not look like a task list; check working_dir or use --force to reset anyway", tasksPath)
+	}
+
+	newContent, count, err := task.ResetSectionWith(content, heading, topts)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		fmt.Printf("Nothing to reset in %s\n", heading)
+		return nil
+	}
+	if err := task.WriteTasksFile(tasksPath, newContent, topts); err != nil {
+		return fmt.Errorf("failed to write tasks file: %w", err)
+	}
+
+	if cfg.Git.AutoCommit {
+		if err := gitCommit(cfg, git.ActionReset, count, heading); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: git commit failed: %v\n", err)
+		}
+	}
+
+	fmt.Printf("Reset %d task(s) in %s\n", count, heading)
+	return nil
+}
+
+// migrate converts the ephe file path (see task.MigrateEphe) and appends it to
+// tasks.md, committing when auto_commit is on, or writes it to the new file out.
+// It prints the conversion report either way.
+func migrate(cfg *config.Config, path, out string) error {
+	topts := taskOptions(cfg)
+	content, err := task.LoadFile(path, topts)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	converted, result := task.MigrateEphe(content, topts)
+	converted = strings.TrimRight(converted, "\n") + "\n"
+
+	if out != "" {
+		if _, err := os.Stat(out); err == nil {
+			return fmt.Errorf("%s already exists; choose a new file for --out", out)
+		}
+		if err := task.WriteTasksFile(out, converted, topts); err != nil {
+			return fmt.Errorf("failed to write %s: %w", out, err)
+		}
+	} else {
+		if out, err = cfg.TasksPath(); err != nil {
+			return fmt.Errorf("failed to get tasks path: %w", err)
+		}
+		existing, err := task.LoadFile(out, topts)
+		if err != nil {
+			return fmt.Errorf("failed to read tasks file: %w", err)
+		}
+		if strings.TrimSpace(existing) != "" {
+			if !cfg.LooksLikeTaskFile(existing) {
+				return fmt.Errorf("%s does not look like a task list; check working_dir, use --out, or use --force to migrate anyway", out)
+			}
+			// Keep what tasks.md has and add the migrated tasks below it
+			converted = strings.TrimRight(existing, "\n") + "\n\n" + converted
+		}
+		if err := task.WriteTasksFile(out, converted, topts); err != nil {
+			return fmt.Errorf("failed to write tasks file: %w", err)
+		}
+		if cfg.Git.AutoCommit {
+			if err := gitCommit(cfg, git.ActionMigrate, result.Tasks, task.MigrateFromEphe); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: git commit failed: %v\n", err)
+			}
+		}
+	}
+
+	fmt.Printf("Migrated %s into %s: %d task(s), %d date(s) converted, %d line(s) left untouched\n",
+		path, out, result.Tasks, result.Dates, result.Untouched)
 	return nil
 }
 
-func runTUI(cfg *config.Config) error {
+func runTUI(cfg *config.Config, timing *debugTimer) error {
 	tasksPath, err := cfg.TasksPath()
 	if err != nil {
 		return fmt.Errorf("failed to get tasks path: %w", err)
@@ -244,14 +744,31 @@ func runTUI(cfg *config.Config) error {
 		return fmt.Errorf("failed to get archive path: %w", err)
 	}
 
-	content, err := os.ReadFile(tasksPath)
+	start := timing.now()
+	content, err := task.LoadFile(tasksPath, taskOptions(cfg))
 	if err != nil {
 		return fmt.Errorf("failed to read tasks file: %w", err)
 	}
+	timing.since("read tasks.md", start)
 
-	model := tui.NewWithPaths(cfg, string(content), tasksPath, archivePath)
+	var model tea.Model = tui.NewWithPaths(cfg, content, tasksPath, archivePath).WithLogger(logger)
+	if timing != nil {
+		model = &timedModel{Model: model, timing: timing}
+	}
 	p := tea.NewProgram(model, tea.WithAltScreen())
 
+	// Apply config.toml edits while running; without a watch the TUI runs as before.
+	// Safe mode keeps the defaults, so config.toml is not watched.
+	if !cfg.SafeMode {
+		stop, err := config.Watch(
+			func(next *config.Config) { p.Send(tui.ConfigChangedMsg{Config: next}) },
+			func(err error) { p.Send(tui.ConfigChangedMsg{Err: err}) },
+		)
+		if err == nil {
+			defer stop()
+		}
+	}
+
 	if _, err := p.Run(); err != nil {
 		return fmt.Errorf("failed to run TUI: %w", err)
 	}
@@ -259,30 +776,36 @@ func runTUI(cfg *config.Config) error {
 	return nil
 }
 
-func gitCommit(cfg *config.Config, message string) error {
+// pullBeforeEdit fast-forwards the working directory from origin when
+// git.pull_before_edit is on. Editing goes ahead either way: an unreachable remote or
+// one that diverged only prints a warning.
+func pullBeforeEdit(cfg *config.Config) {
+	if !cfg.Git.PullBeforeEdit || cfg.Git.Mode == config.GitModeDisabled {
+		return
+	}
 	dir, err := cfg.WorkingDir()
-	if err != nil {
-		return err
+	if err != nil || !git.HasRemote(dir, "origin") {
+		return
 	}
-
-	addCmd := exec.Command("git", "add", "-A")
-	addCmd.Dir = dir
-	if err := addCmd.Run(); err != nil {
-		return fmt.Errorf("git add failed: %w", err)
+	switch err := git.FastForwardPull(dir, git.PullBeforeEditTimeout); {
+	case errors.Is(err, git.ErrOffline):
+		fmt.Fprintln(os.Stderr, "Warning: working offline, origin not reachable")
+	case errors.Is(err, git.ErrRemoteAhead):
+		fmt.Fprintln(os.Stderr, "Warning: remote ahead, run 'ttt sync' to merge it")
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 	}
+}
 
-	// Check if there are changes to commit
-	diffCmd := exec.Command("git", "diff", "--cached", "--quiet")
-	diffCmd.Dir = dir
-	if err := diffCmd.Run(); err == nil {
-		// No changes to commit
-		return nil
+// gitCommit commits the changes in the working directory with the message of action
+// (see git.Message) and a timestamp. In parent-repo mode only the working directory
+// is staged and committed.
+func gitCommit(cfg *config.Config, action git.Action, args ...any) error {
+	dir, err := cfg.WorkingDir()
+	if err != nil {
+		return err
 	}
-
-	commitMsg := fmt.Sprintf("%s (%s)", message, time.Now().Format("2006-01-02 15:04"))
-	commitCmd := exec.Command("git", "commit", "-m", commitMsg)
-	commitCmd.Dir = dir
-	return commitCmd.Run()
+	return git.CommitAll(dir, git.Message(action, time.Now(), args...), cfg.GitPaths()...)
 }
 
 func setRemote(cfg *config.Config, url string) error {
@@ -290,6 +813,9 @@ func setRemote(cfg *config.Config, url string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get working directory: %w", err)
 	}
+	if err := checkGitCommand(cfg, "remote"); err != nil {
+		return err
+	}
 
 	// Ensure README.md and .gitignore exist before setting remote
 	if err := ensureRepoFiles(dir); err != nil {
@@ -304,16 +830,726 @@ func setRemote(cfg *config.Config, url string) error {
 	return nil
 }
 
+// checkGitCommand returns an error for a command that needs git when git.mode is
+// disabled, and for "ttt remote", which would change the remote of an enclosing repository.
+func checkGitCommand(cfg *config.Config, command string) error {
+	switch {
+	case cfg.Git.Mode == config.GitModeDisabled:
+		return fmt.Errorf("'%s' needs git, but git.mode is \"disabled\"", command)
+	case cfg.Git.Mode == config.GitModeParentRepo && command == "remote":
+		return fmt.Errorf("working_dir is inside another git repository (git.mode = \"parent-repo\"); set its remote with git instead")
+	}
+	return nil
+}
+
 func syncTasks(cfg *config.Config) error {
 	dir, err := cfg.WorkingDir()
 	if err != nil {
 		return fmt.Errorf("failed to get working directory: %w", err)
 	}
+	if err := checkGitCommand(cfg, "sync"); err != nil {
+		return err
+	}
+
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
 
-	if err := git.Sync(dir); err != nil {
+	hook, err := cfg.PostSyncHookArgs()
+	if err != nil {
 		return err
 	}
+	before := git.HeadCommit(dir)
 
-	fmt.Println("Sync completed successfully.")
+	// Ctrl+C stops a running pull or push instead of leaving git behind
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	progress := func(step string) {
+		fmt.Fprintf(os.Stderr, "%s...\n", step)
+	}
+
+	// Tasks completed on several devices come back as duplicates with different @done dates
+	resolved := 0
+	err = git.SyncContext(ctx, dir, progress, func() error {
+		n, err := task.ResolveDoneConflictsFile(tasksPath, taskOptions(cfg))
+		resolved = n
+		return err
+	}, cfg.GitPaths()...)
+	if errors.Is(err, git.ErrNonFastForward) {
+		// Never force-push: integrate the remote commits if the user agrees, or stop
+		fmt.Fprintln(os.Stderr, err)
+		if !isTerminal(os.Stdin) || !confirm(os.Stdin, os.Stderr, "Pull with --rebase and push again? [y/N] ") {
+			return fmt.Errorf("sync aborted, nothing was pushed; to integrate the remote changes, %s", git.RebaseHint(dir))
+		}
+		err = git.RebaseAndPush(ctx, dir, progress)
+	}
+	if err != nil {
+		return err
+	}
+
+	if resolved > 0 {
+		fmt.Printf("Merged %d task(s) completed on more than one device.\n", resolved)
+	}
+	fmt.Println("Sync completed successfully.")
+
+	// The sync has succeeded either way; a failing hook is only reported
+	if _, err := git.PostSyncHook(dir, before, hook, "TTT_TASKS_FILE="+tasksPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: post-sync hook failed: %v\n", err)
+	}
+	return nil
+}
+
+// confirm writes question to out and reports whether the answer read from in is
+// "y" or "yes" (in any case). Anything else, including end of input, means no.
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprint(out, question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// isTerminal reports whether f is a terminal, so a question can be answered.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// doctor checks tasks.md and archive.md for problems ttt would otherwise silently
+// misread: malformed task checkboxes (see task.LooksLikeBrokenTask), @est tags in
+// tasks.md that are not durations, invalid front matter settings, and archive date headers. With fix, what can be
+// repaired is written back. Tasks nested deeper than file.max_depth or longer than
+// tasks.max_task_length are reported too, to be fixed by hand.
+func doctor(cfg *config.Config, fix bool) error {
+	topts := taskOptions(cfg)
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
+	archivePath, err := cfg.ArchivePath()
+	if err != nil {
+		return fmt.Errorf("failed to get archive path: %w", err)
+	}
+
+	var report strings.Builder
+	problems := 0
+
+	content, err := task.LoadFile(tasksPath, topts)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read tasks file: %w", err)
+	}
+	if err == nil {
+		text, n, repaired := diagnoseTasks(config.TasksFileName, content, fix, topts)
+		report.WriteString(text)
+		problems += n
+		text, n = diagnoseEstimates(config.TasksFileName, content, topts)
+		report.WriteString(text)
+		problems += n
+		text, n = diagnoseDepth(config.TasksFileName, content, cfg.File.MaxDepth, topts)
+		report.WriteString(text)
+		problems += n
+		text, n = diagnoseLength(config.TasksFileName, content, cfg.Tasks.MaxTaskLength, topts)
+		report.WriteString(text)
+		problems += n
+		text, n = diagnoseFrontmatter(config.TasksFileName, content)
+		report.WriteString(text)
+		problems += n
+		if fix && repaired != content {
+			if !cfg.LooksLikeTaskFile(content) {
+				return fmt.Errorf("%s does not look like a task list; check working_dir or use --force to repair anyway", tasksPath)
+			}
+			if err := task.WriteTasksFile(tasksPath, repaired, topts); err != nil {
+				return fmt.Errorf("failed to write tasks file: %w", err)
+			}
+		}
+	}
+
+	content, err = task.LoadFile(archivePath, topts)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read archive file: %w", err)
+	}
+	if err == nil {
+		text, n, repaired := diagnoseArchive(content, fix, topts)
+		report.WriteString(text)
+		problems += n
+		if fix && repaired != content {
+			if err := task.WriteFile(archivePath, repaired, topts); err != nil {
+				return fmt.Errorf("failed to write archive file: %w", err)
+			}
+		}
+	}
+
+	if report.Len() == 0 {
+		report.WriteString("No problems found.\n")
+	}
+	fmt.Print(report.String())
+
+	if problems > 0 {
+		return fmt.Errorf("found %d problem(s)", problems)
+	}
+	return nil
+}
+
+// exitOpenLimit is the exit code of "ttt check" when tasks.md has more open tasks than
+// tasks.open_limit, so a shell prompt can tell it from an error (exit code 1).
+const exitOpenLimit = 3
+
+// check prints the number of open tasks in tasks.md, counted like the TUI footer
+// (see task.CountOpenTasks), with tasks.open_limit when set. Above the limit it fails
+// with exitOpenLimit.
+func check(cfg *config.Config) error {
+	topts := taskOptions(cfg)
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
+	content, err := task.LoadFile(tasksPath, topts)
+	if err != nil {
+		return fmt.Errorf("failed to read tasks file: %w", err)
+	}
+
+	text, warning := formatCheck(content, cfg.Tasks.OpenLimit, topts)
+	fmt.Println(text)
+	if warning != "" {
+		return &exitCodeError{code: exitOpenLimit, err: errors.New(warning)}
+	}
+	return nil
+}
+
+// formatCheck returns the line "ttt check" prints for content, "12 open tasks" or
+// "12 open tasks (limit 30)", and the warning of task.OpenLimitMessage.
+func formatCheck(content string, limit int, topts task.Options) (string, string) {
+	open := task.CountOpenTasks(content, topts)
+	text := fmt.Sprintf("%d open tasks", open)
+	if limit > 0 {
+		text += fmt.Sprintf(" (limit %d)", limit)
+	}
+	return text, task.OpenLimitMessage(open, limit)
+}
+
+// diagnoseTasks reports the lines of a task file named name that look like tasks but
+// are not, with the corrected form. With fix they are rewritten instead.
+// Returns the report text, the number of problems left unfixed, and the repaired content.
+func diagnoseTasks(name, content string, fix bool, topts task.Options) (string, int, string) {
+	broken := task.BrokenTasks(content, topts)
+	if len(broken) == 0 {
+		return "", 0, content
+	}
+	if fix {
+		repaired, count := task.RepairBrokenTasks(content, topts)
+		return fmt.Sprintf("%s: repaired %d malformed task(s)\n", name, count), 0, repaired
+	}
+
+	var b strings.Builder
+	for _, line := range broken {
+		suggestion, _ := task.LooksLikeBrokenTaskWith(line.Content, topts)
+		fmt.Fprintf(&b, "%s:%d: malformed task: %s (should be: %s)\n", name, line.LineNumber+1, line.Content, suggestion)
+	}
+	fmt.Fprintf(&b, "%s: run 'ttt doctor --fix' to repair %d malformed task(s)\n", name, len(broken))
+	return b.String(), len(broken), content
+}
+
+// diagnoseEstimates reports the tasks of a task file named name whose @est tag is not
+// a valid duration. They are ignored by estimate totals and cannot be fixed automatically.
+// Returns the report text and the number of problems.
+func diagnoseEstimates(name, content string, topts task.Options) (string, int) {
+	malformed := task.MalformedEstimates(content, topts)
+	var b strings.Builder
+	for _, line := range malformed {
+		fmt.Fprintf(&b, "%s:%d: unrecognized estimate (use e.g. @est(30m), @est(1h30m), @est(1d)): %s\n",
+			name, line.LineNumber+1, strings.TrimSpace(line.Content))
+	}
+	return b.String(), len(malformed)
+}
+
+// diagnoseDepth reports the tasks in content nested deeper than [file] max_depth
+// (see task.TooDeepTasks). They cannot be fixed automatically.
+// Returns the report text and the number of problems.
+func diagnoseDepth(name, content string, maxDepth int, topts task.Options) (string, int) {
+	deep := task.TooDeepTasks(content, maxDepth, topts)
+	var b strings.Builder
+	for _, line := range deep {
+		fmt.Fprintf(&b, "%s:%d: task nested deeper than [file] max_depth = %d: %s\n",
+			name, line.LineNumber+1, maxDepth, strings.TrimSpace(line.Content))
+	}
+	return b.String(), len(deep)
+}
+
+// diagnoseLength reports the open tasks of content whose text is wider than
+// [tasks] max_task_length columns (see task.LongTasks), so they can be split up.
+// Returns the report text and the number of problems.
+func diagnoseLength(name, content string, maxLen int, topts task.Options) (string, int) {
+	long := task.LongTasksWith(content, maxLen, topts)
+	var b strings.Builder
+	for _, ref := range long {
+		fmt.Fprintf(&b, "%s:%d: task text is %d columns, over [tasks] max_task_length = %d (consider splitting it): %s\n",
+			name, ref.Line+1, ref.Width, maxLen, ref.Text)
+	}
+	return b.String(), len(long)
+}
+
+// diagnoseFrontmatter reports the settings in the front matter of content that ttt
+// cannot use (see task.FrontmatterSettings); config.toml applies instead.
+// Returns the report text and the number of problems.
+func diagnoseFrontmatter(name, content string) (string, int) {
+	_, errs := task.FrontmatterSettings(content)
+	var b strings.Builder
+	for _, err := range errs {
+		fmt.Fprintf(&b, "%s: front matter: %v (config.toml applies instead)\n", name, err)
+	}
+	return b.String(), len(errs)
+}
+
+// diagnoseArchive builds the doctor report for archive.md content: date headers, then
+// malformed tasks (see diagnoseTasks). Empty when there is nothing to report.
+// Returns the report text, the number of problems left unfixed, and the repaired content.
+func diagnoseArchive(content string, fix bool, topts task.Options) (string, int, string) {
+	var b strings.Builder
+	problems := 0
+
+	repaired, count := task.RepairArchiveHeaders(content)
+	if count > 0 {
+		if fix {
+			fmt.Fprintf(&b, "archive.md: repaired %d date header(s)\n", count)
+		} else {
+			fmt.Fprintf(&b, "archive.md: %d date header(s) are not in YYYY-MM-DD form (run 'ttt doctor --fix')\n", count)
+			problems += count
+		}
+	}
+
+	for _, line := range task.InvalidArchiveHeaders(repaired) {
+		fmt.Fprintf(&b, "archive.md:%d: unrecognized date header: %s\n", line.LineNumber+1, line.Content)
+		problems++
+	}
+
+	text, n, repaired := diagnoseTasks(config.ArchiveFileName, repaired, fix, topts)
+	b.WriteString(text)
+	return b.String(), problems + n, repaired
+}
+
+// consolidateArchive merges duplicate date sections and duplicate tasks in archive.md,
+// sorts the sections newest first, and rewrites the file atomically.
+// With dryRun, only the summary is printed.
+func consolidateArchive(cfg *config.Config, dryRun bool) error {
+	topts := taskOptions(cfg)
+	archivePath, err := cfg.ArchivePath()
+	if err != nil {
+		return fmt.Errorf("failed to get archive path: %w", err)
+	}
+
+	content, err := task.LoadFile(archivePath, topts)
+	if os.IsNotExist(err) {
+		fmt.Println("No archive to consolidate.")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive file: %w", err)
+	}
+
+	consolidated, result := task.ConsolidateArchive(content, topts)
+	if dryRun {
+		fmt.Println("Dry run: " + consolidateSummary(result))
+		return nil
+	}
+	if consolidated == content {
+		fmt.Println("Archive is already consolidated.")
+		return nil
+	}
+
+	if err := task.ReplaceFile(archivePath, consolidated, topts); err != nil {
+		return fmt.Errorf("failed to write archive file: %w", err)
+	}
+	fmt.Println(consolidateSummary(result))
+
+	if cfg.Git.AutoCommit {
+		if err := gitCommit(cfg, git.ActionConsolidate); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: git commit failed: %v\n", err)
+		}
+	}
 	return nil
 }
+
+// consolidateSummary describes a consolidation:
+// "merged 6 duplicate sections, removed 3 duplicate lines".
+func consolidateSummary(result task.ConsolidateResult) string {
+	summary := fmt.Sprintf("merged %d duplicate sections, removed %d duplicate lines", result.MergedSections, result.RemovedLines)
+	if result.UnsortedLines > 0 {
+		summary += fmt.Sprintf(", kept %d unsorted lines under ## Unsorted", result.UnsortedLines)
+	}
+	return summary
+}
+
+// archiveTasks archives completed tasks older than archive.delay_days.
+// Tasks go to the file routed for their nearest heading ([archive] routes) or archive.md;
+// with to, everything goes to that file instead.
+func archiveTasks(cfg *config.Config, to string) error {
+	topts := taskOptions(cfg)
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
+	archivePath, err := cfg.ArchivePath()
+	if err != nil {
+		return fmt.Errorf("failed to get archive path: %w", err)
+	}
+	routes, err := cfg.ArchiveRoutes()
+	if err != nil {
+		return fmt.Errorf("failed to resolve archive routes: %w", err)
+	}
+	if to != "" {
+		if archivePath, err = config.ExpandPath(to); err != nil {
+			return err
+		}
+		routes = nil
+	}
+
+	content, err := task.LoadFile(tasksPath, topts)
+	if err != nil {
+		return fmt.Errorf("failed to read tasks file: %w", err)
+	}
+	if !cfg.LooksLikeTaskFile(content) {
+		return fmt.Errorf("%s does not look like a task list; check working_dir or use --force to archive anyway", tasksPath)
+	}
+
+	_, archived, err := task.ProcessAndArchiveRouted(tasksPath, archivePath, routes, cfg.ArchiveDelay(), topts)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, path := range sortedKeys(archived) {
+		fmt.Printf("Archived %d task(s) to %s\n", archived[path], path)
+		total += archived[path]
+	}
+	if total == 0 {
+		fmt.Println("No tasks to archive.")
+		return nil
+	}
+
+	if cfg.Git.AutoCommit {
+		if err := gitCommit(cfg, git.ActionArchive, total); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: git commit failed: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// search prints the lines of tasks.md that contain text, compared per [search].
+func search(cfg *config.Config, text string, archive bool) error {
+	topts := taskOptions(cfg)
+	if archive {
+		dir, err := cfg.WorkingDir()
+		if err != nil {
+			return err
+		}
+		archives, err := task.LoadArchives(dir, 0, topts)
+		if err != nil {
+			return fmt.Errorf("failed to read archive files: %w", err)
+		}
+		fmt.Print(formatArchiveSearch(archives, text, cfg.SearchOptions()))
+		return nil
+	}
+
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
+	content, err := task.LoadFile(tasksPath, topts)
+	if err != nil {
+		return fmt.Errorf("failed to read tasks file: %w", err)
+	}
+
+	fmt.Print(formatSearch(content, text, cfg.SearchOptions()))
+	return nil
+}
+
+// formatSearch lists matching lines as "<line number>: <line>", or "No matches." when there are none.
+func formatSearch(content, text string, opts task.SearchOptions) string {
+	var b strings.Builder
+	for i, line := range strings.Split(content, "\n") {
+		if len(task.FindMatches(line, text, opts)) > 0 {
+			fmt.Fprintf(&b, "%d: %s\n", i+1, line)
+		}
+	}
+	if b.Len() == 0 {
+		return "No matches.\n"
+	}
+	return b.String()
+}
+
+// formatArchiveSearch lists matching lines of the archive files as
+// "<file>:<line number>: <line>", or "No matches." when there are none.
+func formatArchiveSearch(archives task.Archives, text string, opts task.SearchOptions) string {
+	var b strings.Builder
+	for i, line := range strings.Split(archives.Content, "\n") {
+		if len(task.FindMatches(line, text, opts)) == 0 {
+			continue
+		}
+		if path, n, ok := archives.Locate(i); ok {
+			fmt.Fprintf(&b, "%s:%d: %s\n", filepath.Base(path), n+1, line)
+		}
+	}
+	if b.Len() == 0 {
+		return "No matches.\n"
+	}
+	return b.String()
+}
+
+// listByDue prints the open tasks of tasks.md ordered by @due date (see task.SortByDue).
+func listByDue(cfg *config.Config) error {
+	topts := taskOptions(cfg)
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
+	content, err := task.LoadFile(tasksPath, topts)
+	if err != nil {
+		return fmt.Errorf("failed to read tasks file: %w", err)
+	}
+
+	fmt.Print(formatByDue(content, time.Now(), topts))
+	return nil
+}
+
+// formatByDue lists the open tasks of content by @due date, one per line, or
+// "No open tasks." when there are none.
+func formatByDue(content string, now time.Time, topts task.Options) string {
+	var b strings.Builder
+	for _, line := range task.SortByDue(task.ParseLines(content, topts), now) {
+		b.WriteString(line.Content + "\n")
+	}
+	if b.Len() == 0 {
+		return "No open tasks.\n"
+	}
+	return b.String()
+}
+
+// loadConfig loads config.toml, or with --safe-mode ignores it and returns the
+// defaults, saying so on w.
+func loadConfig(safeMode bool, w io.Writer) (*config.Config, error) {
+	if safeMode {
+		cfg := config.Default()
+		cfg.SafeMode = true
+		fmt.Fprintln(w, "Safe mode: ignoring config.toml and using the defaults")
+		return cfg, nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg, nil
+}
+
+// checkConfigErrors prints a warning for each line of config.toml that could not be
+// read and was skipped, and each setting that was not valid and was reset (see
+// config.Config.ConfigErrors). With --strict-config, they are an error instead.
+func checkConfigErrors(cfg *config.Config, strict bool, w io.Writer) error {
+	if len(cfg.ConfigErrors) == 0 {
+		return nil
+	}
+	path, err := config.ConfigPath()
+	if err != nil {
+		path = "config.toml"
+	}
+	if strict {
+		return fmt.Errorf("invalid %s: %w", path, cfg.LoadError())
+	}
+	for _, e := range cfg.ConfigErrors {
+		fmt.Fprintf(w, "Warning: config error in %s (using the default): %s\n", path, e)
+	}
+	fmt.Fprintf(w, "Warning: %s was left as it is; fix it, or run ttt --safe-mode to ignore it\n", path)
+	return nil
+}
+
+// checkUnknownKeys reports keys in config.toml that ttt does not know: a warning per key,
+// or an error listing them all with --strict-config.
+func checkUnknownKeys(cfg *config.Config, strict bool, w io.Writer) error {
+	if len(cfg.UnknownKeys) == 0 {
+		return nil
+	}
+	path, err := config.ConfigPath()
+	if err != nil {
+		path = "config.toml"
+	}
+	if strict {
+		return fmt.Errorf("unknown keys in %s: %s", path, strings.Join(cfg.UnknownKeys, ", "))
+	}
+	for _, key := range cfg.UnknownKeys {
+		fmt.Fprintf(w, "Warning: ignoring unknown key in %s: %s\n", path, key)
+	}
+	return nil
+}
+
+// stats prints the current completion streak from archive.md.
+func stats(cfg *config.Config, weekdays bool) error {
+	output, err := formatStats(cfg, weekdays, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Println(output)
+	return nil
+}
+
+// formatStats reads the archive files (none means no streak; see task.LoadAllArchives)
+// and formats the streak line.
+func formatStats(cfg *config.Config, weekdays bool, now time.Time) (string, error) {
+	dir, err := cfg.WorkingDir()
+	if err != nil {
+		return "", err
+	}
+	archiveContent, err := task.LoadAllArchivesWith(dir, taskOptions(cfg))
+	if err != nil {
+		return "", fmt.Errorf("failed to read archive files: %w", err)
+	}
+
+	if weekdays {
+		return fmt.Sprintf("Current streak: %d weekday(s)", task.CurrentWeekdayStreak(archiveContent, now)), nil
+	}
+	return fmt.Sprintf("Current streak: %d day(s)", task.CurrentStreak(archiveContent, now)), nil
+}
+
+// estimates prints the remaining @est totals of tasks.md per heading.
+func estimates(cfg *config.Config) error {
+	topts := taskOptions(cfg)
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return fmt.Errorf("failed to get tasks path: %w", err)
+	}
+	content, err := task.LoadFile(tasksPath, topts)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read tasks file: %w", err)
+	}
+	fmt.Print(formatEstimates(content, topts))
+	return nil
+}
+
+// formatEstimates lists the remaining estimate of each heading's open tasks, then the total.
+func formatEstimates(content string, topts task.Options) string {
+	groups := task.EstimatesByHeading(content, topts)
+	if len(groups) == 0 {
+		return "No open tasks with @est estimates.\n"
+	}
+
+	var b strings.Builder
+	var total time.Duration
+	for _, g := range groups {
+		heading := g.Heading
+		if heading == "" {
+			heading = "(no heading)"
+		}
+		fmt.Fprintf(&b, "%s: %s (%d task(s))\n", heading, task.FormatEffort(g.Remaining), g.Tasks)
+		total += g.Remaining
+	}
+	fmt.Fprintf(&b, "Total remaining: %s\n", task.FormatEffort(total))
+	return b.String()
+}
+
+// report prints the today/week summary, or sends it to --pipe or --out.
+func report(cfg *config.Config, opts *cli.Options) error {
+	output, err := buildReport(cfg, opts, time.Now())
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case opts.ReportPipe != "":
+		return pipeReport(opts.ReportPipe, output)
+	case opts.ReportOut != "":
+		if err := os.WriteFile(opts.ReportOut, []byte(output), 0644); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		fmt.Printf("Report written to %s\n", opts.ReportOut)
+		return nil
+	default:
+		fmt.Print(output)
+		return nil
+	}
+}
+
+// buildReport reads tasks.md and the archive files (see task.LoadAllArchives) and
+// formats the report as Markdown or JSON, or the monthly summary of the archive
+// with --month.
+func buildReport(cfg *config.Config, opts *cli.Options, now time.Time) (string, error) {
+	topts := taskOptions(cfg)
+	tasksPath, err := cfg.TasksPath()
+	if err != nil {
+		return "", fmt.Errorf("failed to get tasks path: %w", err)
+	}
+
+	tasksContent, err := task.LoadFile(tasksPath, topts)
+	if err != nil {
+		return "", fmt.Errorf("failed to read tasks file: %w", err)
+	}
+	archiveContent, err := task.LoadAllArchivesWith(filepath.Dir(tasksPath), topts)
+	if err != nil {
+		return "", fmt.Errorf("failed to read archive files: %w", err)
+	}
+
+	if opts.ReportMonth != "" {
+		month, err := time.Parse("2006-01", opts.ReportMonth)
+		if err != nil {
+			return "", fmt.Errorf("invalid --month %q: use YYYY-MM", opts.ReportMonth)
+		}
+		return task.MonthlyReportWith(archiveContent, month.Year(), int(month.Month()), topts), nil
+	}
+
+	period := task.ReportToday
+	if opts.ReportWeek {
+		period = task.ReportWeek
+	}
+	r, err := task.BuildReport(tasksContent, archiveContent, period, now, topts)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.ReportJSON {
+		return r.JSON()
+	}
+	return r.Markdown(), nil
+}
+
+// pipeReport runs command with the report on stdin.
+// The command is split like the editor command (no shell is involved).
+// On failure its stderr is printed and ttt exits with the command's exit code.
+func pipeReport(command, input string) error {
+	args, err := config.SplitCommand(command)
+	if err != nil {
+		return fmt.Errorf("invalid --pipe command: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	if stderr.Len() > 0 {
+		fmt.Fprint(os.Stderr, stderr.String())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &exitCodeError{
+			code: exitErr.ExitCode(),
+			err:  fmt.Errorf("report command %q failed with exit code %d", args[0], exitErr.ExitCode()),
+		}
+	}
+	return fmt.Errorf("failed to run report command: %w", err)
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/yostos/tiny-task-tool/internal/config"
+	"github.com/yostos/tiny-task-tool/internal/task"
 )
 
 // TestEnsureRepoFilesCreatesReadme verifies that ensureRepoFiles creates README.md
@@ -121,3 +128,1169 @@ func TestEnsureRepoFilesDoesNotOverwrite(t *testing.T) {
 		t.Error(".gitignore was overwritten")
 	}
 }
+
+// TestFormatWorkspaceList verifies that the workspace list shows the default workspace
+// first, then configured workspaces, with "*" marking the active one.
+func TestFormatWorkspaceList(t *testing.T) {
+	cfg := config.Default()
+	cfg.Workspaces = []config.WorkspaceConfig{
+		{Name: "work", WorkingDir: "~/work-tasks"},
+	}
+
+	expected := "* default  ~/.ttt\n" +
+		"  work     ~/work-tasks\n"
+	if got := formatWorkspaceList(cfg, "default"); got != expected {
+		t.Errorf("formatWorkspaceList(default) = %q, want %q", got, expected)
+	}
+
+	expected = "  default  ~/.ttt\n" +
+		"* work     ~/work-tasks\n"
+	if got := formatWorkspaceList(cfg, "work"); got != expected {
+		t.Errorf("formatWorkspaceList(work) = %q, want %q", got, expected)
+	}
+}
+
+// TestDiagnoseArchive verifies the doctor report: non-standard headers are counted as
+// problems until --fix repairs them, and unparseable headers are always reported.
+func TestDiagnoseArchive(t *testing.T) {
+	content := "## 2026/1/18\n- [x] A\n\n## Someday\n- [x] B\n"
+
+	report, problems, _ := diagnoseArchive(content, false, task.DefaultOptions())
+	expected := "archive.md: 1 date header(s) are not in YYYY-MM-DD form (run 'ttt doctor --fix')\n" +
+		"archive.md:4: unrecognized date header: ## Someday\n"
+	if report != expected {
+		t.Errorf("report = %q, want %q", report, expected)
+	}
+	if problems != 2 {
+		t.Errorf("problems = %d, want 2", problems)
+	}
+
+	report, problems, repaired := diagnoseArchive(content, true, task.DefaultOptions())
+	expected = "archive.md: repaired 1 date header(s)\n" +
+		"archive.md:4: unrecognized date header: ## Someday\n"
+	if report != expected {
+		t.Errorf("report = %q, want %q", report, expected)
+	}
+	if problems != 1 {
+		t.Errorf("problems = %d, want 1", problems)
+	}
+	if repaired != "## 2026-01-18\n- [x] A\n\n## Someday\n- [x] B\n" {
+		t.Errorf("repaired = %q", repaired)
+	}
+
+	report, problems, _ = diagnoseArchive("## 2026-01-18\n- [x] A\n", false, task.DefaultOptions())
+	if report != "" || problems != 0 {
+		t.Errorf("clean archive: report = %q, problems = %d", report, problems)
+	}
+}
+
+// TestDiagnoseTasks verifies that doctor lists malformed checkboxes with their
+// corrected form, and that --fix rewrites them.
+func TestDiagnoseTasks(t *testing.T) {
+	content := "# Tasks\n- [x ] Parent\n  - [] Child\n- [ ] Fine\n"
+
+	report, problems, _ := diagnoseTasks("tasks.md", content, false, task.DefaultOptions())
+	expected := "tasks.md:2: malformed task: - [x ] Parent (should be: - [x] Parent)\n" +
+		"tasks.md:3: malformed task:   - [] Child (should be:   - [ ] Child)\n" +
+		"tasks.md: run 'ttt doctor --fix' to repair 2 malformed task(s)\n"
+	if report != expected || problems != 2 {
+		t.Errorf("report = %q, problems = %d; want %q, 2", report, problems, expected)
+	}
+
+	report, problems, repaired := diagnoseTasks("tasks.md", content, true, task.DefaultOptions())
+	if report != "tasks.md: repaired 2 malformed task(s)\n" || problems != 0 {
+		t.Errorf("fix: report = %q, problems = %d", report, problems)
+	}
+	if repaired != "# Tasks\n- [x] Parent\n  - [ ] Child\n- [ ] Fine\n" {
+		t.Errorf("repaired = %q", repaired)
+	}
+
+	// archive.md is checked for malformed tasks too
+	report, problems, _ = diagnoseArchive("## 2026-01-18\n- [x ] A\n", false, task.DefaultOptions())
+	if !strings.Contains(report, "archive.md:2: malformed task: - [x ] A") || problems != 1 {
+		t.Errorf("archive: report = %q, problems = %d", report, problems)
+	}
+}
+
+// TestDiagnoseEstimates verifies that @est tags that are not durations are reported
+// with their line numbers.
+func TestDiagnoseEstimates(t *testing.T) {
+	content := "- [ ] Fine @est(2h)\n- [ ] Vague @est(soon)\n"
+	report, problems := diagnoseEstimates("tasks.md", content, task.DefaultOptions())
+	want := "tasks.md:2: unrecognized estimate (use e.g. @est(30m), @est(1h30m), @est(1d)): - [ ] Vague @est(soon)\n"
+	if report != want || problems != 1 {
+		t.Errorf("diagnoseEstimates() = %q, %d; want %q, 1", report, problems, want)
+	}
+	if report, problems := diagnoseEstimates("tasks.md", "- [ ] Fine @est(2h)\n", task.DefaultOptions()); report != "" || problems != 0 {
+		t.Errorf("diagnoseEstimates() without problems = %q, %d", report, problems)
+	}
+}
+
+// TestConfirm verifies that only y or yes answers a question with yes.
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{" y \n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+		{"yep\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var out strings.Builder
+			if got := confirm(strings.NewReader(tt.input), &out, "Continue? [y/N] "); got != tt.want {
+				t.Errorf("confirm(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			if out.String() != "Continue? [y/N] " {
+				t.Errorf("question written = %q", out.String())
+			}
+		})
+	}
+}
+
+// TestDiagnoseDepth verifies the doctor report of tasks nested deeper than [file] max_depth.
+func TestDiagnoseDepth(t *testing.T) {
+	content := "- [ ] A\n  - [ ] B\n    - [ ] C\n"
+	report, problems := diagnoseDepth("tasks.md", content, 2, task.DefaultOptions())
+	want := "tasks.md:3: task nested deeper than [file] max_depth = 2: - [ ] C\n"
+	if report != want || problems != 1 {
+		t.Errorf("diagnoseDepth() = %q, %d; want %q, 1", report, problems, want)
+	}
+	if report, problems := diagnoseDepth("tasks.md", content, 0, task.DefaultOptions()); report != "" || problems != 0 {
+		t.Errorf("diagnoseDepth() without a limit = %q, %d", report, problems)
+	}
+}
+
+// TestDiagnoseLength verifies that open tasks over max_task_length are reported with
+// their width, CJK characters counting as two columns.
+func TestDiagnoseLength(t *testing.T) {
+	content := "- [ ] 請求書を送る\n- [ ] Send the invoice @due(2026-02-01)\n"
+	report, problems := diagnoseLength("tasks.md", content, 8, task.DefaultOptions())
+	want := "tasks.md:1: task text is 12 columns, over [tasks] max_task_length = 8 (consider splitting it): 請求書を送る\n" +
+		"tasks.md:2: task text is 16 columns, over [tasks] max_task_length = 8 (consider splitting it): Send the invoice\n"
+	if report != want || problems != 2 {
+		t.Errorf("diagnoseLength() = %q, %d; want %q, 2", report, problems, want)
+	}
+	if report, problems := diagnoseLength("tasks.md", content, 0, task.DefaultOptions()); report != "" || problems != 0 {
+		t.Errorf("diagnoseLength() without a limit = %q, %d", report, problems)
+	}
+}
+
+// TestDiagnoseFrontmatter verifies that invalid front matter settings are reported.
+func TestDiagnoseFrontmatter(t *testing.T) {
+	content := "---\ndelay_days: soon\ncascade: direct\n---\n- [ ] A\n"
+	report, problems := diagnoseFrontmatter("tasks.md", content)
+	want := "tasks.md: front matter: invalid delay_days \"soon\": must be a number of days >= 0 (config.toml applies instead)\n"
+	if report != want || problems != 1 {
+		t.Errorf("diagnoseFrontmatter() = %q, %d; want %q, 1", report, problems, want)
+	}
+	if report, problems := diagnoseFrontmatter("tasks.md", "---\ndelay_days: 7\n---\n"); report != "" || problems != 0 {
+		t.Errorf("diagnoseFrontmatter() with valid settings = %q, %d", report, problems)
+	}
+}
+
+// TestFormatEstimates verifies the per-heading output of ttt stats --estimates.
+func TestFormatEstimates(t *testing.T) {
+	content := "- [ ] Loose @est(15m)\n# Work\n- [ ] A @est(2h)\n- [ ] B @est(1h30m)\n- [x] C @est(1d) @done(2026-01-18)\n"
+	want := "(no heading): 15m (1 task(s))\n" +
+		"Work: 3h30m (2 task(s))\n" +
+		"Total remaining: 3h45m\n"
+	if got := formatEstimates(content, task.DefaultOptions()); got != want {
+		t.Errorf("formatEstimates() = %q, want %q", got, want)
+	}
+	if got := formatEstimates("- [ ] No estimate\n", task.DefaultOptions()); got != "No open tasks with @est estimates.\n" {
+		t.Errorf("formatEstimates() without estimates = %q", got)
+	}
+}
+
+// TestDoctor verifies that doctor --fix repairs both files and then finds nothing.
+func TestDoctor(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.File.WorkingDir = dir
+	tasksPath := filepath.Join(dir, "tasks.md")
+	archivePath := filepath.Join(dir, "archive.md")
+	if err := os.WriteFile(tasksPath, []byte("- [x ] Parent\n  - [ ] Child\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if err := os.WriteFile(archivePath, []byte("## 2026/1/18\n[x] Old\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	if err := doctor(cfg, false); err == nil || err.Error() != "found 3 problem(s)" {
+		t.Errorf("doctor() error = %v, want 3 problems", err)
+	}
+	if got, _ := os.ReadFile(tasksPath); string(got) != "- [x ] Parent\n  - [ ] Child\n" {
+		t.Errorf("doctor without --fix changed tasks.md: %q", got)
+	}
+
+	if err := doctor(cfg, true); err != nil {
+		t.Fatalf("doctor(fix) error: %v", err)
+	}
+	if got, _ := os.ReadFile(tasksPath); string(got) != "- [x] Parent\n  - [ ] Child\n" {
+		t.Errorf("tasks.md = %q", got)
+	}
+	if got, _ := os.ReadFile(archivePath); string(got) != "## 2026-01-18\n- [x] Old\n" {
+		t.Errorf("archive.md = %q", got)
+	}
+	if err := doctor(cfg, false); err != nil {
+		t.Errorf("doctor() after fix error = %v", err)
+	}
+}
+
+// TestPipeReport verifies that --pipe feeds the report to the command's stdin
+// and propagates the command's exit code on failure.
+func TestPipeReport(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.md")
+
+	if err := pipeReport("sh -c 'cat > \""+out+"\"'", "# Report\n"); err != nil {
+		t.Fatalf("pipeReport() error: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	if string(got) != "# Report\n" {
+		t.Errorf("piped input = %q, want %q", got, "# Report\n")
+	}
+
+	err = pipeReport(`sh -c "exit 3"`, "")
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != 3 {
+		t.Errorf("pipeReport() error = %v, want exit code 3", err)
+	}
+
+	if err := pipeReport(`echo "unterminated`, ""); err == nil {
+		t.Error("pipeReport() should reject an unterminated quote")
+	}
+}
+
+// TestArchiveTasks verifies that "ttt archive" follows [archive] routes by default
+// and sends everything to the --to file when given.
+func TestArchiveTasks(t *testing.T) {
+	oldDate := time.Now().AddDate(0, 0, -5).Format("2006-01-02")
+	tasks := "# Project X\n- [x] Ship X @done(" + oldDate + ")\n# Inbox\n- [x] Buy milk @done(" + oldDate + ")\n- [ ] Open\n"
+
+	tests := []struct {
+		name      string
+		to        string
+		wantFiles map[string][]string // file (relative to the working dir) -> tasks it must contain
+	}{
+		{
+			name: "routes",
+			wantFiles: map[string][]string{
+				"archives/project-x.md": {"Ship X"},
+				"archive.md":            {"Buy milk"},
+			},
+		},
+		{
+			name: "to",
+			to:   "export.md",
+			wantFiles: map[string][]string{
+				"export.md": {"Ship X", "Buy milk"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "tasks.md"), []byte(tasks), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+			cfg := config.Default()
+			cfg.File.WorkingDir = dir
+			cfg.Git.AutoCommit = false
+			cfg.Archive.Routes = map[string]string{"Project X": "archives/project-x.md"}
+
+			to := ""
+			if tt.to != "" {
+				to = filepath.Join(dir, tt.to)
+			}
+			if err := archiveTasks(cfg, to); err != nil {
+				t.Fatalf("archiveTasks() error: %v", err)
+			}
+
+			for file, wants := range tt.wantFiles {
+				got, err := os.ReadFile(filepath.Join(dir, file))
+				for _, want := range wants {
+					if err != nil || !strings.Contains(string(got), want) {
+						t.Errorf("%s = %q (err %v), want it to contain %q", file, got, err, want)
+					}
+				}
+			}
+			remaining, _ := os.ReadFile(filepath.Join(dir, "tasks.md"))
+			if string(remaining) != "# Project X\n# Inbox\n- [ ] Open\n" {
+				t.Errorf("tasks.md = %q", remaining)
+			}
+		})
+	}
+}
+
+// TestAddTaskRoutesByPrefix verifies that "ttt -t" puts a "heading:" task under
+// the matching section and appends anything else.
+func TestAddTaskRoutesByPrefix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.md")
+	if err := os.WriteFile(path, []byte("## Work\n- [ ] a\n\n## Home\n- [ ] b\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	cfg := config.Default()
+	cfg.File.WorkingDir = dir
+	cfg.Git.AutoCommit = false
+
+	for _, text := range []string{"work: review PR 42", "Fix bug: crash"} {
+		if err := addTask(cfg, text, nil); err != nil {
+			t.Fatalf("addTask(%q) error: %v", text, err)
+		}
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	want := "## Work\n- [ ] a\n- [ ] review PR 42\n\n## Home\n- [ ] b\n- [ ] Fix bug: crash\n"
+	if string(got) != want {
+		t.Errorf("tasks.md = %q, want %q", got, want)
+	}
+}
+
+// TestEnsureWorkingDirGitMode verifies how git.mode picks the repository: a working
+// dir inside another repository is not turned into a nested repository, and commits
+// there include only the working dir.
+func TestEnsureWorkingDirGitMode(t *testing.T) {
+	runGit := func(t *testing.T, dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v error: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	parentRepo := func(t *testing.T) string {
+		dir := t.TempDir()
+		runGit(t, dir, "init")
+		runGit(t, dir, "config", "user.email", "test@example.com")
+		runGit(t, dir, "config", "user.name", "Test User")
+		return dir
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		inParent bool
+		wantMode string
+		wantErr  bool
+	}{
+		{"auto inside a parent repo", config.GitModeAuto, true, config.GitModeParentRepo, false},
+		{"auto outside any repo", config.GitModeAuto, false, config.GitModeOwnRepo, false},
+		{"own-repo inside a parent repo", config.GitModeOwnRepo, true, config.GitModeOwnRepo, false},
+		{"parent-repo outside any repo", config.GitModeParentRepo, false, "", true},
+		{"disabled", config.GitModeDisabled, true, config.GitModeDisabled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if tt.inParent {
+				root = parentRepo(t)
+			}
+			dir := filepath.Join(root, ".ttt")
+			cfg := config.Default()
+			cfg.File.WorkingDir = dir
+			cfg.Git.Mode = tt.mode
+
+			err := ensureWorkingDir(cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("ensureWorkingDir() should return error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ensureWorkingDir() error: %v", err)
+			}
+			if cfg.Git.Mode != tt.wantMode {
+				t.Errorf("Git.Mode = %q, want %q", cfg.Git.Mode, tt.wantMode)
+			}
+			_, statErr := os.Stat(filepath.Join(dir, ".git"))
+			if ownRepo := statErr == nil; ownRepo != (tt.wantMode == config.GitModeOwnRepo) {
+				t.Errorf("own repository created = %v for mode %q", ownRepo, tt.wantMode)
+			}
+			if tt.wantMode == config.GitModeDisabled && cfg.Git.AutoCommit {
+				t.Error("AutoCommit should be off when git is disabled")
+			}
+		})
+	}
+
+	t.Run("commit in parent repo", func(t *testing.T) {
+		root := parentRepo(t)
+		cfg := config.Default()
+		cfg.File.WorkingDir = filepath.Join(root, ".ttt")
+		if err := ensureWorkingDir(cfg); err != nil {
+			t.Fatalf("ensureWorkingDir() error: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(root, ".zshrc"), []byte("export X=1\n"), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+
+		if err := addTask(cfg, "Buy milk", nil); err != nil {
+			t.Fatalf("addTask() error: %v", err)
+		}
+		files := strings.Fields(runGit(t, root, "show", "--name-only", "--format=", "HEAD"))
+		if strings.Join(files, " ") != ".ttt/tasks.md" {
+			t.Errorf("committed files = %q, want only .ttt/tasks.md", files)
+		}
+		if status := runGit(t, root, "status", "--porcelain"); strings.TrimSpace(status) != "?? .zshrc" {
+			t.Errorf("status = %q, want .zshrc left alone", status)
+		}
+	})
+
+	t.Run("git user from config", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.File.WorkingDir = filepath.Join(t.TempDir(), "tasks")
+		cfg.Git.UserName = "Task Bot"
+		cfg.Git.UserEmail = "bot@example.com"
+		if err := ensureWorkingDir(cfg); err != nil {
+			t.Fatalf("ensureWorkingDir() error: %v", err)
+		}
+		if email := runGit(t, cfg.File.WorkingDir, "config", "--local", "user.email"); strings.TrimSpace(email) != "bot@example.com" {
+			t.Errorf("user.email = %q, want %q", email, "bot@example.com")
+		}
+
+		// A user set in the repository is kept
+		runGit(t, cfg.File.WorkingDir, "config", "user.email", "me@example.com")
+		if err := ensureWorkingDir(cfg); err != nil {
+			t.Fatalf("ensureWorkingDir() error: %v", err)
+		}
+		if email := runGit(t, cfg.File.WorkingDir, "config", "--local", "user.email"); strings.TrimSpace(email) != "me@example.com" {
+			t.Errorf("user.email = %q, want the repository's own %q kept", email, "me@example.com")
+		}
+	})
+}
+
+// TestAddTaskUnder verifies that "ttt -t --under N" adds a subtask of the task on
+// line N, even when the text has a "heading:" prefix, and fails for a non-task line.
+func TestAddTaskUnder(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.md")
+	if err := os.WriteFile(path, []byte("## Work\n- [ ] Release\n  - [x] Tag\n- [ ] Other\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	cfg := config.Default()
+	cfg.File.WorkingDir = dir
+	cfg.Git.AutoCommit = false
+
+	line := 2
+	if err := addTask(cfg, "work: notes", &line); err != nil {
+		t.Fatalf("addTask() error: %v", err)
+	}
+	got, _ := os.ReadFile(path)
+	want := "## Work\n- [ ] Release\n  - [x] Tag\n  - [ ] work: notes\n- [ ] Other\n"
+	if string(got) != want {
+		t.Errorf("tasks.md = %q, want %q", got, want)
+	}
+
+	line = 1
+	if err := addTask(cfg, "x", &line); err == nil || !strings.Contains(err.Error(), "line 1 is not a task") {
+		t.Errorf("addTask() under a heading error = %v", err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != want {
+		t.Errorf("failed add changed tasks.md: %q", after)
+	}
+}
+
+// TestMoveTask verifies "ttt move": the task and its subtasks leave the source
+// workspace, land under the target's inbox heading, and both sides are committed.
+func TestMoveTask(t *testing.T) {
+	root := t.TempDir()
+	runGit := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v error: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	runGit("init")
+	runGit("config", "user.email", "test@example.com")
+	runGit("config", "user.name", "Test User")
+
+	workDir := filepath.Join(root, "work")
+	homeDir := filepath.Join(root, "home")
+	for dir, content := range map[string]string{
+		workDir: "# Work\n- [ ] Review PR\n- [ ] Book dentist\n  - [ ] Call clinic\n- [ ] Deploy\n",
+		homeDir: "# Home\n\n## Inbox\n- [ ] Water plants\n\n## Someday\n- [ ] Paint fence\n",
+	} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("MkdirAll() error: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "tasks.md"), []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+
+	newConfig := func() (*config.Config, *config.Config) {
+		cfg := config.Default()
+		cfg.Workspaces = []config.WorkspaceConfig{{Name: "work", WorkingDir: workDir}, {Name: "home", WorkingDir: homeDir}}
+		if err := cfg.UseWorkspace("work"); err != nil {
+			t.Fatalf("UseWorkspace() error: %v", err)
+		}
+		target, err := cfg.ForWorkspace("home")
+		if err != nil {
+			t.Fatalf("ForWorkspace() error: %v", err)
+		}
+		if err := ensureWorkingDir(cfg); err != nil {
+			t.Fatalf("ensureWorkingDir() error: %v", err)
+		}
+		return cfg, target
+	}
+
+	cfg, target := newConfig()
+	if err := moveTask(cfg, target, "dentist"); err != nil {
+		t.Fatalf("moveTask() error: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workDir, "tasks.md")); string(got) != "# Work\n- [ ] Review PR\n- [ ] Deploy\n" {
+		t.Errorf("source tasks.md = %q", got)
+	}
+	want := "# Home\n\n## Inbox\n- [ ] Water plants\n- [ ] Book dentist\n  - [ ] Call clinic\n\n## Someday\n- [ ] Paint fence\n"
+	if got, _ := os.ReadFile(filepath.Join(homeDir, "tasks.md")); string(got) != want {
+		t.Errorf("target tasks.md = %q, want %q", got, want)
+	}
+	log := runGit("log", "--format=%s")
+	if !strings.Contains(log, "Move task from work: Book dentist") || !strings.Contains(log, "Move task to home: Book dentist") {
+		t.Errorf("git log = %q, want a commit for each workspace", log)
+	}
+
+	for _, tt := range []struct{ pattern, wantErr string }{
+		{"dentist", `no task contains "dentist"`},
+		{"e", `"e" matches 2 tasks`},
+	} {
+		cfg, target := newConfig()
+		err := moveTask(cfg, target, tt.pattern)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("moveTask(%q) error = %v, want %q", tt.pattern, err, tt.wantErr)
+		}
+	}
+
+	cfg, _ = newConfig()
+	same, _ := cfg.ForWorkspace("work")
+	if err := moveTask(cfg, same, "Deploy"); err == nil || !strings.Contains(err.Error(), "same tasks file") {
+		t.Errorf("moveTask() to the same workspace error = %v", err)
+	}
+}
+
+// TestDoneTasks verifies that ttt done completes the matching open task with its
+// subtasks and commits, and that more than one match needs --all.
+func TestDoneTasks(t *testing.T) {
+	dir := t.TempDir()
+	runGit := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v error: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	runGit("init")
+	runGit("config", "user.email", "test@example.com")
+	runGit("config", "user.name", "Test User")
+
+	path := filepath.Join(dir, "tasks.md")
+	content := "# Tasks\n- [ ] 牛乳を買う\n  - [ ] 低脂肪\n- [ ] 牛乳パックを捨てる\n- [ ] 卵\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	cfg := config.Default()
+	cfg.File.WorkingDir = dir
+	cfg.Git.AutoCommit = true
+
+	for _, tt := range []struct{ match, wantErr string }{
+		{"牛乳", `"牛乳" matches 2 open tasks`},
+		{"パン", `no open task contains "パン"`},
+	} {
+		if err := doneTasks(cfg, tt.match, false); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("doneTasks(%q) error = %v, want %q", tt.match, err, tt.wantErr)
+		}
+	}
+	if got, _ := os.ReadFile(path); string(got) != content {
+		t.Errorf("tasks.md changed on error: %q", got)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if err := doneTasks(cfg, "買う", false); err != nil {
+		t.Fatalf("doneTasks() error: %v", err)
+	}
+	want := "# Tasks\n- [x] 牛乳を買う @done(" + today + ")\n  - [x] 低脂肪 @done(" + today + ")\n- [ ] 牛乳パックを捨てる\n- [ ] 卵\n"
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("tasks.md = %q, want %q", got, want)
+	}
+	if log := runGit("log", "--format=%s"); !strings.Contains(log, "Complete task: 牛乳を買う") {
+		t.Errorf("git log = %q, want a commit for the completed task", log)
+	}
+
+	// The completed task no longer matches, so "牛乳" is a single match now
+	if err := doneTasks(cfg, "牛乳", true); err != nil {
+		t.Fatalf("doneTasks(--all) error: %v", err)
+	}
+	if got, _ := os.ReadFile(path); !strings.Contains(string(got), "- [x] 牛乳パックを捨てる @done("+today+")") {
+		t.Errorf("tasks.md = %q, want the second task completed", got)
+	}
+}
+
+// TestResetSection verifies that ttt reset reopens one section and commits, and that
+// an unknown heading is an error.
+func TestResetSection(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.md")
+	content := "# Tasks\n## Packing\n- [x] Passport @done(2026-10-01)\n  - [x] Visa @done(2026-10-01)\n## Work\n- [x] Report @done(2026-10-01)\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	cfg := config.Default()
+	cfg.File.WorkingDir = dir
+	cfg.Git.AutoCommit = false
+
+	if err := resetSection(cfg, "Travel"); err == nil || !strings.Contains(err.Error(), `no ## heading "Travel"`) {
+		t.Errorf("resetSection(Travel) error = %v", err)
+	}
+	if err := resetSection(cfg, "Packing"); err != nil {
+		t.Fatalf("resetSection() error: %v", err)
+	}
+	want := "# Tasks\n## Packing\n- [ ] Passport\n  - [ ] Visa\n## Work\n- [x] Report @done(2026-10-01)\n"
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("tasks.md = %q, want %q", got, want)
+	}
+}
+
+// TestMigrate verifies that ttt migrate appends the converted ephe file to tasks.md,
+// writes a new file with --out, and never overwrites an existing --out file.
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.md")
+	if err := os.WriteFile(path, []byte("# Tasks\n- [ ] Existing\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	source := filepath.Join(dir, "ephe.md")
+	if err := os.WriteFile(source, []byte("* [ ] Plan\n    * [x] Book room ✅ 2026-01-17\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	cfg := config.Default()
+	cfg.File.WorkingDir = dir
+	cfg.Git.AutoCommit = false
+
+	if err := migrate(cfg, source, ""); err != nil {
+		t.Fatalf("migrate() error: %v", err)
+	}
+	want := "# Tasks\n- [ ] Existing\n\n- [ ] Plan\n  - [x] Book room @done(2026-01-17)\n"
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("tasks.md = %q, want %q", got, want)
+	}
+
+	out := filepath.Join(dir, "new.md")
+	if err := migrate(cfg, source, out); err != nil {
+		t.Fatalf("migrate(--out) error: %v", err)
+	}
+	want = "- [ ] Plan\n  - [x] Book room @done(2026-01-17)\n"
+	if got, _ := os.ReadFile(out); string(got) != want {
+		t.Errorf("%s = %q, want %q", out, got, want)
+	}
+	if err := migrate(cfg, source, out); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("migrate(existing --out) error = %v, want already exists", err)
+	}
+}
+
+// TestAddTaskWraps verifies that ttt -t wraps a long task with tasks.wrap_column.
+func TestAddTaskWraps(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.md")
+	if err := os.WriteFile(path, []byte("# Tasks\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	cfg := config.Default()
+	cfg.File.WorkingDir = dir
+	cfg.Git.AutoCommit = false
+	cfg.Tasks.WrapColumn = 20
+
+	if err := addTask(cfg, "read the release notes at https://example.com/releases/v2 @due(2026-10-20)", nil); err != nil {
+		t.Fatalf("addTask() error: %v", err)
+	}
+	want := "# Tasks\n- [ ] read the release @due(2026-10-20)\n  notes at\n  https://example.com/releases/v2\n"
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("tasks.md = %q, want %q", got, want)
+	}
+}
+
+// TestPlaceTask verifies where ttt -t puts a task and how it reports the position.
+func TestPlaceTask(t *testing.T) {
+	line := func(n int) *int { return &n }
+
+	tests := []struct {
+		name      string
+		content   string
+		autoTitle string
+		heading   string
+		under     *int
+		want      string
+		message   string
+	}{
+		{
+			name:    "routed under a heading",
+			content: "# Tasks\n\n## Work\n- [ ] a\n\n## Inbox\n- [ ] b\n",
+			heading: "Work",
+			want:    "# Tasks\n\n## Work\n- [ ] a\n- [ ] new\n\n## Inbox\n- [ ] b\n",
+			message: `Added under "## Work" (line 5): new`,
+		},
+		{
+			name:    "last section",
+			content: "# Tasks\n\n## Inbox\n- [ ] b\n",
+			want:    "# Tasks\n\n## Inbox\n- [ ] b\n- [ ] new\n",
+			message: `Added under "## Inbox" (line 5): new`,
+		},
+		{
+			name:    "no heading",
+			content: "- [ ] a\n",
+			want:    "- [ ] a\n- [ ] new\n",
+			message: "Added (line 2): new",
+		},
+		{
+			name:      "heading created by auto_title",
+			content:   "- [ ] a\n",
+			autoTitle: "# Tasks",
+			want:      "# Tasks\n\n- [ ] a\n- [ ] new\n",
+			message:   `Added under "# Tasks" (line 4): new`,
+		},
+		{
+			name:      "subtask below a created heading",
+			content:   "- [ ] a\n- [ ] b\n",
+			autoTitle: "# Tasks",
+			under:     line(1),
+			want:      "# Tasks\n\n- [ ] a\n  - [ ] new\n- [ ] b\n",
+			message:   `Added under "# Tasks" (line 4, subtask of line 3): new`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.File.AutoTitle = tt.autoTitle
+			got, ins, parent, err := placeTask(cfg, tt.content, tt.heading, "new", tt.under)
+			if err != nil {
+				t.Fatalf("placeTask() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("placeTask() = %q, want %q", got, tt.want)
+			}
+			if msg := addedMessage("new", ins, parent); msg != tt.message {
+				t.Errorf("addedMessage() = %q, want %q", msg, tt.message)
+			}
+		})
+	}
+}
+
+// TestAddTaskPullsBeforeEdit verifies that ttt -t with git.pull_before_edit adds to
+// the remote's latest tasks.md, and still adds when the remote is unreachable.
+func TestAddTaskPullsBeforeEdit(t *testing.T) {
+	runGit := func(t *testing.T, dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v error: %v\n%s", args, err, output)
+		}
+	}
+	// clone returns a clone of remote with a committer configured.
+	clone := func(t *testing.T, remote string) string {
+		dir := filepath.Join(t.TempDir(), "clone")
+		runGit(t, filepath.Dir(dir), "clone", "-q", remote, dir)
+		runGit(t, dir, "config", "user.email", "test@example.com")
+		runGit(t, dir, "config", "user.name", "Test User")
+		return dir
+	}
+
+	tests := []struct {
+		name   string
+		remote func(t *testing.T, bare string) string // origin URL of the working dir
+		want   string
+	}{
+		{
+			name:   "remote edit pulled",
+			remote: func(t *testing.T, bare string) string { return bare },
+			want:   "# Tasks\n- [ ] from laptop\n- [ ] new task\n",
+		},
+		{
+			name:   "unreachable remote",
+			remote: func(t *testing.T, bare string) string { return filepath.Join(t.TempDir(), "missing.git") },
+			want:   "# Tasks\n- [ ] new task\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bare := t.TempDir()
+			runGit(t, bare, "init", "-q", "--bare")
+			seed := clone(t, bare)
+			if err := os.WriteFile(filepath.Join(seed, "tasks.md"), []byte("# Tasks\n"), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+			runGit(t, seed, "add", "tasks.md")
+			runGit(t, seed, "commit", "-q", "-m", "Initial")
+			runGit(t, seed, "push", "-q", "origin", "HEAD")
+
+			dir := clone(t, bare)
+			if err := os.WriteFile(filepath.Join(seed, "tasks.md"), []byte("# Tasks\n- [ ] from laptop\n"), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+			runGit(t, seed, "commit", "-q", "-am", "Laptop edit")
+			runGit(t, seed, "push", "-q", "origin", "HEAD")
+			runGit(t, dir, "remote", "set-url", "origin", tt.remote(t, bare))
+
+			cfg := config.Default()
+			cfg.File.WorkingDir = dir
+			cfg.Git.Mode = config.GitModeOwnRepo
+			cfg.Git.AutoCommit = false
+			cfg.Git.PullBeforeEdit = true
+
+			if err := addTask(cfg, "new task", nil); err != nil {
+				t.Fatalf("addTask() error: %v", err)
+			}
+			if got, _ := os.ReadFile(filepath.Join(dir, "tasks.md")); string(got) != tt.want {
+				t.Errorf("tasks.md = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAddTaskWeekdayDue verifies that tasks.weekday_due = "expand" writes the date of a
+// weekday @due, and "keep" leaves the weekday in tasks.md.
+func TestAddTaskWeekdayDue(t *testing.T) {
+	tests := []struct {
+		name       string
+		weekdayDue string
+		want       string
+	}{
+		{"keep", task.WeekdayDueKeep, "- [ ] report @due(金曜)\n"},
+		{"expand", task.WeekdayDueExpand, "- [ ] report @due(" + time.Now().AddDate(0, 0, (int(time.Friday)-int(time.Now().Weekday())+7)%7).Format("2006-01-02") + ")\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := config.Default()
+			cfg.File.WorkingDir = dir
+			cfg.File.AutoTitle = ""
+			cfg.Git.AutoCommit = false
+			cfg.Tasks.WeekdayDue = tt.weekdayDue
+
+			if err := addTask(cfg, "report @due(金曜)", nil); err != nil {
+				t.Fatalf("addTask() error: %v", err)
+			}
+			if got, _ := os.ReadFile(filepath.Join(dir, "tasks.md")); string(got) != tt.want {
+				t.Errorf("tasks.md = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAddTaskRefusesWrongFile verifies that ttt -t leaves an oversized or binary
+// tasks.md untouched.
+func TestAddTaskRefusesWrongFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"oversized", strings.Repeat("- [ ] exported\n", 100000), "over the 1MB limit"},
+		{"binary", "PK\x03\x04\x00\x00- [ ] a\n", "looks like a binary file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "tasks.md")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+			cfg := config.Default()
+			cfg.File.WorkingDir = dir
+			cfg.Git.AutoCommit = false
+			cfg.File.MaxSizeMB = 1
+
+			err := addTask(cfg, "new task", nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("addTask() error = %v, want %q", err, tt.wantErr)
+			}
+			if got, _ := os.ReadFile(path); string(got) != tt.content {
+				t.Error("tasks.md was changed")
+			}
+		})
+	}
+}
+
+// TestConsolidateArchive verifies that --dry-run leaves archive.md alone and a real
+// run rewrites it with duplicate sections merged.
+func TestConsolidateArchive(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "archive.md")
+	messy := "## 2026-01-15\n\n- [x] A @done(2026-01-15)\n\n## 2026-01-20\n\n- [x] B @done(2026-01-20)\n\n## 2026-01-15\n\n- [x] A @done(2026-01-15)\n"
+	if err := os.WriteFile(path, []byte(messy), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	cfg := config.Default()
+	cfg.File.WorkingDir = dir
+	cfg.Git.AutoCommit = false
+
+	if err := consolidateArchive(cfg, true); err != nil {
+		t.Fatalf("consolidateArchive(dry run) error: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != messy {
+		t.Errorf("dry run changed archive.md:\n%s", got)
+	}
+
+	if err := consolidateArchive(cfg, false); err != nil {
+		t.Fatalf("consolidateArchive() error: %v", err)
+	}
+	want := "## 2026-01-20\n\n- [x] B @done(2026-01-20)\n\n## 2026-01-15\n\n- [x] A @done(2026-01-15)\n"
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("archive.md = %q, want %q", got, want)
+	}
+
+	summary := consolidateSummary(task.ConsolidateResult{MergedSections: 6, RemovedLines: 3})
+	if summary != "merged 6 duplicate sections, removed 3 duplicate lines" {
+		t.Errorf("consolidateSummary() = %q", summary)
+	}
+}
+
+// TestFormatSearch verifies line numbers and [search] width folding.
+func TestFormatSearch(t *testing.T) {
+	content := "# Work\n- [ ] ＰＲ４２をレビュー\n- [ ] Deploy\n"
+
+	tests := []struct {
+		name string
+		text string
+		opts task.SearchOptions
+		want string
+	}{
+		{"plain", "deploy", task.SearchOptions{}, "3: - [ ] Deploy\n"},
+		{"width folded", "pr42", task.SearchOptions{NormalizeWidth: true}, "2: - [ ] ＰＲ４２をレビュー\n"},
+		{"width kept", "pr42", task.SearchOptions{}, "No matches.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatSearch(content, tt.text, tt.opts); got != tt.want {
+				t.Errorf("formatSearch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFormatCheck verifies the "ttt check" line and the warning above tasks.open_limit.
+func TestFormatCheck(t *testing.T) {
+	content := "- [ ] A\n- [ ] B\n- [x] C @done(2026-01-18)\n"
+	tests := []struct {
+		limit       int
+		wantText    string
+		wantWarning bool
+	}{
+		{0, "2 open tasks", false},
+		{2, "2 open tasks (limit 2)", false},
+		{1, "2 open tasks (limit 1)", true},
+	}
+
+	for _, tt := range tests {
+		text, warning := formatCheck(content, tt.limit, task.DefaultOptions())
+		if text != tt.wantText || (warning != "") != tt.wantWarning {
+			t.Errorf("formatCheck(limit %d) = %q, %q; want %q, warning %v", tt.limit, text, warning, tt.wantText, tt.wantWarning)
+		}
+	}
+}
+
+// TestFormatByDue verifies the due-date listing and its empty message.
+func TestFormatByDue(t *testing.T) {
+	now := time.Date(2026, 2, 10, 9, 0, 0, 0, time.UTC)
+	content := "# Work\n- [ ] Report\n- [ ] Review @due(2026-02-12)\n- [x] Done @done(2026-02-09)\n"
+	want := "(2d) - [ ] Review @due(2026-02-12)\n- [ ] Report\n"
+	if got := formatByDue(content, now, task.DefaultOptions()); got != want {
+		t.Errorf("formatByDue() = %q, want %q", got, want)
+	}
+	if got := formatByDue("# Work\n", now, task.DefaultOptions()); got != "No open tasks.\n" {
+		t.Errorf("formatByDue() without tasks = %q, want %q", got, "No open tasks.\n")
+	}
+}
+
+// TestCheckUnknownKeys verifies that unknown config keys warn by default and fail with --strict-config.
+func TestCheckUnknownKeys(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.Default()
+
+	var buf bytes.Buffer
+	if err := checkUnknownKeys(cfg, true, &buf); err != nil || buf.Len() != 0 {
+		t.Errorf("checkUnknownKeys() without unknown keys = %v, output %q", err, buf.String())
+	}
+
+	cfg.UnknownKeys = []string{"archive.dely_days (line 3)", "ui.scrolbar (line 9)"}
+	if err := checkUnknownKeys(cfg, false, &buf); err != nil {
+		t.Fatalf("checkUnknownKeys() error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], ": archive.dely_days (line 3)") {
+		t.Errorf("warnings = %q, want one line per key", buf.String())
+	}
+
+	buf.Reset()
+	err := checkUnknownKeys(cfg, true, &buf)
+	if err == nil || !strings.Contains(err.Error(), "archive.dely_days (line 3), ui.scrolbar (line 9)") {
+		t.Errorf("checkUnknownKeys(strict) error = %v, want both keys", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("strict mode should not print warnings, got %q", buf.String())
+	}
+}
+
+// TestLoadConfigSafeMode verifies that --safe-mode ignores a broken config.toml and
+// uses the defaults, while a normal load reports the broken lines.
+func TestLoadConfigSafeMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	configDir := filepath.Join(tmpDir, "ttt")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	broken := "[archive]\nauto = true\ndelay_days = \"soon\"\n"
+	if err := os.WriteFile(filepath.Join(configDir, "config.toml"), []byte(broken), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	cfg, err := loadConfig(false, &buf)
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if cfg.SafeMode || len(cfg.ConfigErrors) != 1 || !cfg.Archive.Auto {
+		t.Errorf("loadConfig() = safe mode %v, errors %q, auto %v; want the valid keys and one error",
+			cfg.SafeMode, cfg.ConfigErrors, cfg.Archive.Auto)
+	}
+
+	cfg, err = loadConfig(true, &buf)
+	if err != nil {
+		t.Fatalf("loadConfig(safe mode) error: %v", err)
+	}
+	if !cfg.SafeMode || len(cfg.ConfigErrors) != 0 || cfg.Archive.Auto != config.Default().Archive.Auto {
+		t.Errorf("loadConfig(safe mode) = safe mode %v, errors %q, auto %v; want the defaults",
+			cfg.SafeMode, cfg.ConfigErrors, cfg.Archive.Auto)
+	}
+	if !strings.Contains(buf.String(), "Safe mode") {
+		t.Errorf("loadConfig(safe mode) output = %q, want a safe mode notice", buf.String())
+	}
+}
+
+// TestCheckConfigErrors verifies that unreadable config.toml lines are warnings, or an
+// error with --strict-config.
+func TestCheckConfigErrors(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.Default()
+
+	var buf bytes.Buffer
+	if err := checkConfigErrors(cfg, true, &buf); err != nil || buf.Len() != 0 {
+		t.Errorf("checkConfigErrors() without errors = %v, output %q", err, buf.String())
+	}
+
+	cfg.ConfigErrors = []string{"line 2: incomplete number", "line 5: expected newline"}
+	if err := checkConfigErrors(cfg, false, &buf); err != nil {
+		t.Fatalf("checkConfigErrors() error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], ": line 2: incomplete number") ||
+		!strings.Contains(lines[2], "--safe-mode") {
+		t.Errorf("warnings = %q, want one line per error and a --safe-mode hint", buf.String())
+	}
+
+	buf.Reset()
+	err := checkConfigErrors(cfg, true, &buf)
+	if err == nil || !strings.Contains(err.Error(), "line 2: incomplete number; line 5: expected newline") {
+		t.Errorf("checkConfigErrors(strict) error = %v, want both errors", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("strict mode should not print warnings, got %q", buf.String())
+	}
+}
+
+// TestFormatStats verifies the streak line, including when archive.md does not exist yet.
+func TestFormatStats(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.File.WorkingDir = dir
+	now := time.Date(2026, 1, 20, 9, 0, 0, 0, time.Local) // Tuesday
+
+	got, err := formatStats(cfg, false, now)
+	if err != nil || got != "Current streak: 0 day(s)" {
+		t.Errorf("formatStats() without archive.md = %q, %v", got, err)
+	}
+
+	archive := "## 2026-01-19\n- [x] A @done(2026-01-19)\n\n## 2026-01-16\n- [x] B @done(2026-01-16)\n"
+	if err := os.WriteFile(filepath.Join(dir, "archive.md"), []byte(archive), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	tests := []struct {
+		weekdays bool
+		want     string
+	}{
+		{false, "Current streak: 1 day(s)"},
+		{true, "Current streak: 2 weekday(s)"},
+	}
+	for _, tt := range tests {
+		got, err := formatStats(cfg, tt.weekdays, now)
+		if err != nil || got != tt.want {
+			t.Errorf("formatStats(weekdays=%v) = %q, %v; want %q", tt.weekdays, got, err, tt.want)
+		}
+	}
+
+	// Days archived to a monthly file count too
+	monthly := "## 2026-01-18\n- [x] C @done(2026-01-18)\n## 2026-01-17\n- [x] D @done(2026-01-17)\n"
+	if err := os.WriteFile(filepath.Join(dir, "archive-2026-01.md"), []byte(monthly), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if got, err := formatStats(cfg, false, now); err != nil || got != "Current streak: 4 day(s)" {
+		t.Errorf("formatStats() with a monthly archive = %q, %v; want 4 days", got, err)
+	}
+}
+
+// TestFormatArchiveSearch verifies that archive matches are listed with their file and line.
+func TestFormatArchiveSearch(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"archive.md":         "## 2026-02-10\n- [x] Review PR @done(2026-02-10)\n",
+		"archive-2026-01.md": "## 2026-01-20\n- [x] Deploy\n- [x] Review docs @done(2026-01-20)\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	archives, err := task.LoadArchives(dir, 0, task.DefaultOptions())
+	if err != nil {
+		t.Fatalf("LoadArchives() error: %v", err)
+	}
+
+	want := "archive.md:2: - [x] Review PR @done(2026-02-10)\n" +
+		"archive-2026-01.md:3: - [x] Review docs @done(2026-01-20)\n"
+	if got := formatArchiveSearch(archives, "review", task.SearchOptions{}); got != want {
+		t.Errorf("formatArchiveSearch() = %q, want %q", got, want)
+	}
+	if got := formatArchiveSearch(archives, "missing", task.SearchOptions{}); got != "No matches.\n" {
+		t.Errorf("formatArchiveSearch() = %q, want no matches", got)
+	}
+}
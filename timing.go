@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/yostos/tiny-task-tool/internal/tui"
+)
+
+// debugTimer records how long startup stages take for --debug-timing.
+// Lines are collected and written by flush, so they never mix with the TUI screen.
+// A nil *debugTimer records nothing and never reads the clock, so timing costs
+// nothing unless the flag is given.
+type debugTimer struct {
+	w     io.Writer
+	lines []string
+}
+
+func newDebugTimer(w io.Writer) *debugTimer {
+	return &debugTimer{w: w}
+}
+
+// now returns the current time, or the zero time when timing is disabled.
+func (t *debugTimer) now() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// since records the time elapsed since start for stage.
+func (t *debugTimer) since(stage string, start time.Time) {
+	if t == nil {
+		return
+	}
+	t.record(stage, time.Since(start))
+}
+
+// record records a measured duration for stage.
+func (t *debugTimer) record(stage string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.lines = append(t.lines, fmt.Sprintf("[timing] %-20s %v", stage, d))
+}
+
+// flush writes the recorded lines.
+func (t *debugTimer) flush() {
+	if t == nil {
+		return
+	}
+	for _, line := range t.lines {
+		fmt.Fprintln(t.w, line)
+	}
+	t.lines = nil
+}
+
+// timedModel wraps the TUI model to time the startup @done pass (ProcessContent,
+// run asynchronously from Init) and the first full render. Only used with --debug-timing.
+type timedModel struct {
+	tea.Model
+	timing    *debugTimer
+	initAt    time.Time
+	processed bool
+	sized     bool
+	rendered  bool
+}
+
+func (m *timedModel) Init() tea.Cmd {
+	m.initAt = time.Now()
+	return m.Model.Init()
+}
+
+func (m *timedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg.(type) {
+	case tea.WindowSizeMsg:
+		m.sized = true
+	case tui.AddDoneTagsFinishedMsg, tui.ArchiveFinishedMsg:
+		if !m.processed {
+			m.processed = true
+			m.timing.since("ProcessContent", m.initAt)
+		}
+	}
+	var cmd tea.Cmd
+	m.Model, cmd = m.Model.Update(msg)
+	return m, cmd
+}
+
+func (m *timedModel) View() string {
+	if !m.sized || m.rendered {
+		return m.Model.View()
+	}
+	m.rendered = true
+	start := time.Now()
+	view := m.Model.View()
+	m.timing.since("initial render", start)
+	return view
+}
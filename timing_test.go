@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/yostos/tiny-task-tool/internal/tui"
+)
+
+// TestDebugTimer verifies that a nil timer does nothing and an enabled one
+// prints each recorded stage on flush.
+func TestDebugTimer(t *testing.T) {
+	var disabled *debugTimer
+	if !disabled.now().IsZero() {
+		t.Error("nil timer now() should return the zero time")
+	}
+	disabled.since("config.Load", disabled.now())
+	disabled.flush()
+
+	var buf bytes.Buffer
+	timing := newDebugTimer(&buf)
+	timing.since("config.Load", timing.now())
+	timing.record("read tasks.md", 3*time.Millisecond)
+	if buf.Len() != 0 {
+		t.Errorf("timer wrote before flush: %q", buf.String())
+	}
+	timing.flush()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "[timing] config.Load") {
+		t.Errorf("line 0 = %q, want config.Load stage", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "[timing] read tasks.md") || !strings.HasSuffix(lines[1], "3ms") {
+		t.Errorf("line 1 = %q, want read tasks.md 3ms", lines[1])
+	}
+}
+
+// stubModel is a tea.Model that counts the messages it gets and the views it renders.
+type stubModel struct {
+	updates int
+	views   *int
+}
+
+func (m stubModel) Init() tea.Cmd { return nil }
+
+func (m stubModel) Update(tea.Msg) (tea.Model, tea.Cmd) {
+	m.updates++
+	return m, nil
+}
+
+func (m stubModel) View() string {
+	*m.views++
+	return "view"
+}
+
+// TestTimedModel verifies that timedModel passes everything to the wrapped model
+// and records the first @done pass and the first render after the window size, once each.
+func TestTimedModel(t *testing.T) {
+	var buf bytes.Buffer
+	views := 0
+	m := &timedModel{Model: stubModel{views: &views}, timing: newDebugTimer(&buf)}
+	m.Init()
+
+	// Before the window size is known, renders are not timed
+	if m.View() != "view" || len(m.timing.lines) != 0 {
+		t.Errorf("render before WindowSizeMsg was timed: %q", m.timing.lines)
+	}
+	for _, msg := range []tea.Msg{
+		tea.WindowSizeMsg{Width: 80, Height: 24},
+		tui.AddDoneTagsFinishedMsg{},
+		tui.ArchiveFinishedMsg{},
+	} {
+		m.Update(msg)
+	}
+	m.View()
+	m.View()
+
+	if got := m.Model.(stubModel).updates; got != 3 {
+		t.Errorf("wrapped model got %d messages, want 3", got)
+	}
+	if views != 3 {
+		t.Errorf("wrapped model rendered %d times, want 3", views)
+	}
+	m.timing.flush()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "[timing] ProcessContent") ||
+		!strings.HasPrefix(lines[1], "[timing] initial render") {
+		t.Errorf("timing lines = %q, want ProcessContent then initial render", lines)
+	}
+}
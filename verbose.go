@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/yostos/tiny-task-tool/internal/config"
+	"github.com/yostos/tiny-task-tool/internal/git"
+	"github.com/yostos/tiny-task-tool/internal/task"
+)
+
+// logger receives the debug events of --verbose; it discards them unless
+// startVerbose was called.
+var logger = slog.New(slog.DiscardHandler)
+
+// verboseLogPath returns the file --verbose logs to while the TUI runs, whose screen
+// stderr is: ttt/debug.log in XDG_CACHE_HOME if set, otherwise in os.UserCacheDir().
+func verboseLogPath() (string, error) {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, "ttt", "debug.log"), nil
+}
+
+// taskOptions returns the task settings of cfg, with the task package's debug
+// events going to logger.
+func taskOptions(cfg *config.Config) task.Options {
+	opts := cfg.TaskOptions()
+	opts.Logger = logger
+	return opts
+}
+
+// startVerbose sends the debug events of main and the task and git packages to w,
+// or, when tui is set, to the file at verboseLogPath, which is truncated so it holds
+// this run only. It returns that file's path ("" for w) and a function that closes it.
+func startVerbose(w io.Writer, tui bool) (path string, closeLog func() error, err error) {
+	closeLog = func() error { return nil }
+	if tui {
+		if path, err = verboseLogPath(); err != nil {
+			return "", nil, fmt.Errorf("failed to find the debug log: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", nil, fmt.Errorf("failed to create the debug log: %w", err)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to create the debug log: %w", err)
+		}
+		w, closeLog = f, f.Close
+	}
+
+	logger = slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	git.SetLogger(logger)
+	return path, closeLog, nil
+}
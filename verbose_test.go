@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yostos/tiny-task-tool/internal/config"
+	"github.com/yostos/tiny-task-tool/internal/git"
+	"github.com/yostos/tiny-task-tool/internal/task"
+)
+
+// TestVerboseDisabled verifies that without --verbose the logger discards the debug
+// events and taskOptions passes it, with the rest of the config's task settings.
+func TestVerboseDisabled(t *testing.T) {
+	if logger.Enabled(t.Context(), slog.LevelDebug) {
+		t.Error("logger should discard debug events until startVerbose is called")
+	}
+	opts := taskOptions(config.Default())
+	if opts.Logger != logger {
+		t.Error("taskOptions() should pass the package logger")
+	}
+	opts.Logger = nil
+	if want := config.Default().TaskOptions(); !reflect.DeepEqual(opts, want) {
+		t.Errorf("taskOptions() = %+v, want %+v", opts, want)
+	}
+}
+
+// TestVerboseLogPath verifies that the TUI debug log is in XDG_CACHE_HOME when set,
+// and in the user cache directory otherwise.
+func TestVerboseLogPath(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	if got, err := verboseLogPath(); err != nil || got != filepath.Join(cache, "ttt", "debug.log") {
+		t.Errorf("verboseLogPath() = %q, %v; want ttt/debug.log in XDG_CACHE_HOME", got, err)
+	}
+
+	t.Setenv("XDG_CACHE_HOME", "")
+	want, err := os.UserCacheDir()
+	if err != nil {
+		t.Skipf("no user cache directory: %v", err)
+	}
+	if got, err := verboseLogPath(); err != nil || got != filepath.Join(want, "ttt", "debug.log") {
+		t.Errorf("verboseLogPath() = %q, %v; want ttt/debug.log in %s", got, err, want)
+	}
+}
+
+// TestStartVerbose verifies that --verbose logs the key debug events of a run to
+// stderr for commands, and to the debug log file for the TUI.
+func TestStartVerbose(t *testing.T) {
+	t.Cleanup(func() {
+		logger = slog.New(slog.DiscardHandler)
+		git.SetLogger(logger)
+	})
+
+	var buf bytes.Buffer
+	path, closeLog, err := startVerbose(&buf, false)
+	if err != nil || path != "" {
+		t.Fatalf("startVerbose() = %q, %v; want no file", path, err)
+	}
+	defer closeLog()
+
+	cfg := config.Default()
+	cfg.File.WorkingDir = filepath.Join(t.TempDir(), "tasks")
+	if err := ensureWorkingDir(cfg); err != nil {
+		t.Fatalf("ensureWorkingDir() error: %v", err)
+	}
+	tasksPath, _ := cfg.TasksPath()
+	if err := task.WriteFile(tasksPath, "- [x] a\n", task.DefaultOptions()); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if err := archiveTasks(cfg, ""); err != nil {
+		t.Fatalf("archiveTasks() error: %v", err)
+	}
+
+	for _, want := range []string{
+		`msg="working dir" workspace=default dir=` + cfg.File.WorkingDir + ` created=true git_mode=own-repo`,
+		`msg=run command="git init" dir=` + cfg.File.WorkingDir + ` duration=`,
+		`msg="process content" lines=2 cascade=all tagged=1 escalated=0`,
+		`msg="archive cutoff" heading="" delay_days=2 cutoff=`,
+		`msg="filter archivable" lines=`,
+		`msg="write file" path=` + tasksPath + ` bytes=26`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log has no %q:\n%s", want, buf.String())
+		}
+	}
+
+	t.Run("TUI logs to a file", func(t *testing.T) {
+		cache := t.TempDir()
+		t.Setenv("XDG_CACHE_HOME", cache)
+		var stderr bytes.Buffer
+		path, closeLog, err := startVerbose(&stderr, true)
+		if err != nil {
+			t.Fatalf("startVerbose() error: %v", err)
+		}
+		if want := filepath.Join(cache, "ttt", "debug.log"); path != want {
+			t.Errorf("path = %q, want %q", path, want)
+		}
+		task.ProcessContent("- [x] a\n", taskOptions(config.Default()))
+		if err := closeLog(); err != nil {
+			t.Fatalf("closeLog() error: %v", err)
+		}
+
+		data, _ := os.ReadFile(path)
+		if !strings.Contains(string(data), `msg="process content" lines=2 cascade=all tagged=1`) {
+			t.Errorf("debug log = %q, want the process content event", data)
+		}
+		if stderr.Len() != 0 {
+			t.Errorf("stderr = %q, want nothing while the TUI runs", stderr.String())
+		}
+	})
+}